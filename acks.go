@@ -0,0 +1,141 @@
+// acks.go
+
+// This file contains the tracking of command acknowledgements from the drone.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ackTimeout = 500 * time.Millisecond // how long we wait for an ack before resending
+	ackRetries = 3                      // how many times we resend an unacknowledged command
+)
+
+// ackKey identifies a command awaiting acknowledgement, the drone echoes both fields in its response.
+type ackKey struct {
+	messageID MessageID
+	sequence  uint16
+}
+
+// expectAck registers interest in the response to a command, the payload of the response will be
+// sent on the returned channel.
+func (tello *Tello) expectAck(messageID MessageID, sequence uint16) chan []byte {
+	tello.ackMu.Lock()
+	defer tello.ackMu.Unlock()
+	if tello.acks == nil {
+		tello.acks = map[ackKey]chan []byte{}
+	}
+	ackChan := make(chan []byte, 1)
+	tello.acks[ackKey{messageID, sequence}] = ackChan
+	return ackChan
+}
+
+// forgetAck removes interest in the response to a command.
+func (tello *Tello) forgetAck(messageID MessageID, sequence uint16) {
+	tello.ackMu.Lock()
+	delete(tello.acks, ackKey{messageID, sequence})
+	tello.ackMu.Unlock()
+}
+
+// clearAcks forgets every command awaiting acknowledgement, those left over from an earlier
+// connection can never be answered.
+func (tello *Tello) clearAcks() {
+	tello.ackMu.Lock()
+	tello.acks = nil
+	tello.ackMu.Unlock()
+}
+
+// resolveAck passes the payload of pkt to whoever is waiting for it, if anyone.
+func (tello *Tello) resolveAck(pkt packet) (matched bool) {
+	key := ackKey{pkt.messageID, pkt.sequence}
+	tello.ackMu.Lock()
+	ackChan, matched := tello.acks[key]
+	if matched {
+		delete(tello.acks, key)
+	}
+	tello.ackMu.Unlock()
+	if matched {
+		ackChan <- pkt.payload
+	}
+	return matched
+}
+
+// sendAndWait sends a command to the drone and waits for it to be acknowledged, resending it
+// if no ack arrives within ackTimeout.  The payload of the acknowledgement is returned.
+func (tello *Tello) sendAndWait(ctx context.Context, pt uint8, messageID MessageID, payload []byte) (reply []byte, err error) {
+	ctx, span := tello.startSpan(ctx, "tello.command")
+	defer func() { endSpan(span, err) }()
+	span.SetAttribute("tello.message_id", int(messageID))
+
+	tello.ctrlMu.Lock()
+	if tello.ctrlState != connConnected {
+		tello.ctrlMu.Unlock()
+		return nil, ErrNotConnected
+	}
+	tello.ctrlSeq++
+	seq := tello.ctrlSeq
+	pkt := newPacket(pt, messageID, seq, len(payload))
+	copy(pkt.payload, payload)
+	buff := packetToBuffer(pkt)
+	ackChan := tello.expectAck(messageID, seq)
+	done := tello.ctrlDone
+	tello.ctrlMu.Unlock()
+	defer tello.forgetAck(messageID, seq)
+	span.SetAttribute("tello.sequence", int(seq))
+
+	clock := tello.cfg.getClock()
+	sent := clock.Now() // latency is measured from the first attempt, so lost packets count against the link
+	for attempt := 0; attempt <= ackRetries; attempt++ {
+		span.SetAttribute("tello.attempts", attempt+1)
+		if attempt > 0 {
+			span.AddEvent("resend")
+			tello.traffic.recordResend()
+		}
+		tello.ctrlMu.Lock()
+		tello.enqueue(buff)
+		tello.ctrlMu.Unlock()
+		select {
+		case reply = <-ackChan:
+			tello.link.recordAckLatency(clock.Now().Sub(sent))
+			return reply, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-done:
+			return nil, ErrNotConnected
+		case <-clock.After(ackTimeout):
+			// resend
+		}
+	}
+	return nil, &TimeoutError{Op: "acknowledgement"}
+}
+
+// commandAndWait sends a command via sendAndWait() and returns a *CommandError if the Tello refuses it.
+func (tello *Tello) commandAndWait(ctx context.Context, pt uint8, messageID MessageID, payload []byte) error {
+	reply, err := tello.sendAndWait(ctx, pt, messageID, payload)
+	if err != nil {
+		return err
+	}
+	return resultError(messageID, reply)
+}
@@ -0,0 +1,64 @@
+// tello project acks_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResolveAck(t *testing.T) {
+	drone := new(Tello)
+	ackChan := drone.expectAck(MsgDoLand, 42)
+	if drone.resolveAck(packet{messageID: MsgDoLand, sequence: 41}) {
+		t.Error("Ack matched the wrong sequence number")
+	}
+	if !drone.resolveAck(packet{messageID: MsgDoLand, sequence: 42, payload: []byte{0}}) {
+		t.Fatal("Ack not matched")
+	}
+	if reply := <-ackChan; len(reply) != 1 {
+		t.Errorf("Expected 1-byte reply, got % x", reply)
+	}
+	if drone.resolveAck(packet{messageID: MsgDoLand, sequence: 42}) {
+		t.Error("Ack matched twice")
+	}
+}
+
+func TestSendAndWaitRetries(t *testing.T) {
+	// a fake drone which ignores the first copy of each command
+	drone, fake := pushingDrone(t)
+	seen := map[uint16]bool{}
+	fake.serve(func(pkt packet) {
+		if !seen[pkt.sequence] {
+			seen[pkt.sequence] = true
+			return
+		}
+		fake.reply(pkt, 0)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := drone.TakeOffAndWait(ctx); err != nil {
+		t.Errorf("TakeOffAndWait failed with %v", err)
+	}
+}
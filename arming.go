@@ -0,0 +1,78 @@
+// arming.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+// WithArmInterlock makes the Tello start disarmed: until Arm() is called, TakeOff(), ThrowTakeOff(),
+// flips and stick updates with nonzero throttle are rejected, guarding against accidental launches
+// by buggy scripts during development.  Throttle from an autopilot is not sent while disarmed either.
+// Without this option the Tello is always armed.
+func WithArmInterlock() Option {
+	return func(tello *Tello) {
+		tello.ctrlDisarmed = true
+	}
+}
+
+// Arm releases the safety interlock set up by WithArmInterlock().
+func (tello *Tello) Arm() {
+	tello.ctrlMu.Lock()
+	tello.ctrlDisarmed = false
+	tello.ctrlMu.Unlock()
+}
+
+// Disarm engages the safety interlock, see WithArmInterlock().
+// ErrAirborne is returned, and the Tello stays armed, if FlightData says we are flying.
+func (tello *Tello) Disarm() error {
+	if fd := tello.GetFlightData(); fd.Flying || fd.State.IsAirborne() {
+		return ErrAirborne
+	}
+	tello.ctrlMu.Lock()
+	tello.ctrlDisarmed = true
+	tello.ctrlMu.Unlock()
+	return nil
+}
+
+// Armed returns false while the safety interlock is engaged.
+func (tello *Tello) Armed() bool {
+	tello.ctrlMu.RLock()
+	defer tello.ctrlMu.RUnlock()
+	return !tello.ctrlDisarmed
+}
+
+// armGate returns ErrDisarmed if the safety interlock is engaged.
+func (tello *Tello) armGate() error {
+	if !tello.Armed() {
+		return ErrDisarmed
+	}
+	return nil
+}
+
+// SetSticks is as UpdateSticks() but returns ErrDisarmed, and leaves the sticks alone,
+// if asked for nonzero throttle while the Tello is disarmed.
+func (tello *Tello) SetSticks(sm StickMessage) error {
+	tello.ctrlMu.Lock()
+	defer tello.ctrlMu.Unlock()
+	if tello.ctrlDisarmed && sm.Ly != 0 {
+		return ErrDisarmed
+	}
+	tello.setSticks(sm)
+	return nil
+}
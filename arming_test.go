@@ -0,0 +1,91 @@
+// arming_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestArmInterlock(t *testing.T) {
+	drone := NewTello(WithArmInterlock())
+	drone.sendQ.running = true // queue packets, but with no writer to send them
+	if drone.Armed() {
+		t.Fatal("Expected to start disarmed")
+	}
+	ctx := context.Background()
+	if err := drone.TakeOffAndWait(ctx); !errors.Is(err, ErrDisarmed) {
+		t.Errorf("Expected TakeOffAndWait() to be refused, got %v", err)
+	}
+	if err := drone.FlipCtx(ctx, FlipForward); !errors.Is(err, ErrDisarmed) {
+		t.Errorf("Expected FlipCtx() to be refused, got %v", err)
+	}
+	drone.TakeOff()
+	drone.ThrowTakeOff()
+	drone.Flip(FlipBackward)
+	if cmds := drone.sendQ.queues[prioCommand]; len(cmds) != 0 {
+		t.Errorf("Expected nothing to be sent while disarmed, got %d commands", len(cmds))
+	}
+
+	if err := drone.SetSticks(StickMessage{Ly: 1000}); !errors.Is(err, ErrDisarmed) {
+		t.Errorf("Expected throttle to be refused, got %v", err)
+	}
+	if err := drone.SetSticks(StickMessage{Rx: 1000}); err != nil {
+		t.Errorf("Expected zero throttle to be accepted, got %v", err)
+	}
+	drone.Up(50)
+	if drone.ctrlLy != 0 || drone.ctrlRx != 1000 {
+		t.Error("Expected Up() to be ignored")
+	}
+	drone.ctrlLy = 5000 // as set by anything else
+	drone.sendStickUpdate()
+	if s := drone.ctrlSent; s.ly != 0 || s.rx != 1000 {
+		t.Errorf("Expected the throttle to be held at zero, got %+v", s)
+	}
+
+	drone.Arm()
+	drone.Up(50)
+	drone.sendStickUpdate()
+	if s := drone.ctrlSent; s.ly == 0 {
+		t.Error("Expected the throttle to be sent when armed")
+	}
+	drone.TakeOff()
+	if cmds := drone.sendQ.queues[prioCommand]; len(cmds) != 1 {
+		t.Errorf("Expected the takeoff to be sent when armed, got %d commands", len(cmds))
+	}
+
+	drone.fd.Flying = true
+	if err := drone.Disarm(); !errors.Is(err, ErrAirborne) || !drone.Armed() {
+		t.Errorf("Expected to stay armed while flying, got %v", err)
+	}
+	drone.fd.Flying = false
+	if err := drone.Disarm(); err != nil || drone.Armed() {
+		t.Errorf("Expected to disarm on the ground, got %v", err)
+	}
+}
+
+func TestArmedByDefault(t *testing.T) {
+	if !new(Tello).Armed() {
+		t.Error("Expected a Tello without the interlock to be armed")
+	}
+}
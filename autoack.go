@@ -0,0 +1,57 @@
+// autoack.go
+
+// This file contains the acknowledgement of messages which the drone repeats until answered.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+// reack answers a repeat of a message which needs acknowledging, as marked in the protocol table, which has been dropped as a
+// duplicate by checkSequence(): our first ack must have been lost, and the drone will keep
+// resending the message, cluttering the link, until it hears one.  The message is not processed
+// again, so eg. a repeated file size does not restart the picture being received.
+// It returns false for messages which do not need acknowledging.
+func (tello *Tello) reack(pkt packet) bool {
+	switch pkt.messageID {
+	case MsgSetDateTime:
+		tello.sendDateTime()
+	case MsgFileSize:
+		tello.sendFileSize()
+	case MsgFileData:
+		if len(pkt.payload) < 12 {
+			break
+		}
+		chunk := payloadToFileChunk(pkt.payload)
+		tello.fdMu.Lock()
+		complete := tello.fileTemp.fID == chunk.fID && int(chunk.pieceNum) < len(tello.fileTemp.pieces) &&
+			tello.fileTemp.pieces[chunk.pieceNum].numChunks == 8
+		tello.fdMu.Unlock()
+		if complete {
+			tello.sendFileAckPiece(0, chunk.fID, chunk.pieceNum)
+		}
+	case MsgLogHeader:
+		if len(pkt.payload) >= 2 {
+			tello.sendLogHeaderAck(pkt.payload[:2])
+		}
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+// autoack_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello/protocol"
+)
+
+// countID returns how many of pkts have the given message ID.
+func countID(pkts []packet, id MessageID) (n int) {
+	for _, pkt := range pkts {
+		if pkt.messageID == id {
+			n++
+		}
+	}
+	return n
+}
+
+func TestReackCoversProtocol(t *testing.T) {
+	drone := NewTello()
+	drone.sendQ.running = true // queue the acks without a connection
+	for _, m := range protocol.Messages() {
+		pkt := packet{messageID: MessageID(m.ID), payload: make([]byte, 16)}
+		if got := drone.reack(pkt); got != m.Ack {
+			t.Errorf("%s: expected reack %v, got %v", m.Name, m.Ack, got)
+		}
+	}
+}
+
+func TestDuplicatesAreReacked(t *testing.T) {
+	drone, fake := pushingDrone(t)
+	fileSize := []byte{byte(FtJPEG), 0x10, 0, 0, 0, 0x34, 0x12}
+	fake.pushSeq(MsgFileSize, 5, fileSize...)
+	fake.pushSeq(MsgFileData, 6, 0x34, 0x12, 0, 0, 0, 0, 0, 0, 0, 0, 4, 0, 1, 2, 3, 4)
+	fake.pushSeq(MsgFileSize, 5, fileSize...) // our ack was lost, the drone repeats it
+	pkts := fake.recv(300 * time.Millisecond)
+	if n := countID(pkts, MsgFileSize); n != 2 {
+		t.Errorf("Expected the file size to be acknowledged twice, got %d", n)
+	}
+	drone.fdMu.Lock()
+	accum := drone.fileTemp.accumSize
+	drone.fdMu.Unlock()
+	if accum != 4 {
+		t.Errorf("Expected the repeated file size not to restart the picture, have %d bytes", accum)
+	}
+	if s := drone.Stats(); s.Duplicates != 1 {
+		t.Errorf("Expected 1 duplicate, got %d", s.Duplicates)
+	}
+}
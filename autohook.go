@@ -0,0 +1,90 @@
+// autohook.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+// Autopilots records which of the autopilots are flying the drone.
+type Autopilots struct {
+	Height, Yaw, XY, Orbit, Follow bool
+}
+
+// Any returns true if any autopilot is active.
+func (a Autopilots) Any() bool {
+	return a.Height || a.Yaw || a.XY || a.Orbit || a.Follow
+}
+
+// ActiveAutopilots returns which of the autopilots are currently active.
+func (tello *Tello) ActiveAutopilots() Autopilots {
+	return Autopilots{
+		Height: tello.IsAutoHeight(),
+		Yaw:    tello.IsAutoTurning(),
+		XY:     tello.IsAutoXY(),
+		Orbit:  tello.IsAutoOrbiting(),
+		Follow: tello.IsFollowing(),
+	}
+}
+
+// AutopilotOutput is passed to an AutopilotHook on every stick update while an autopilot is active.
+type AutopilotOutput struct {
+	Sticks     StickMessage // the stick values about to be sent, including any not driven by the autopilots
+	Active     Autopilots
+	FlightData FlightData
+}
+
+// AutopilotHook may inspect, modify or veto the stick values about to be sent while an autopilot is
+// flying the drone, eg. to add obstacle avoidance based on an external depth camera.  The returned
+// sticks are sent instead, unless allow is false in which case the sticks are centred for this
+// update and the drone holds its position while the autopilot carries on trying.
+// The hook is called from the goroutine which sends stick updates, every 40ms or so, so it must
+// return promptly.  It may call Hover() or the Cancel... funcs to give up altogether.
+type AutopilotHook func(out AutopilotOutput) (sticks StickMessage, allow bool)
+
+// SetAutopilotHook sets the AutopilotHook, nil removes it.
+func (tello *Tello) SetAutopilotHook(hook AutopilotHook) {
+	tello.ctrlMu.Lock()
+	tello.ctrlAutoHook = hook
+	tello.ctrlMu.Unlock()
+}
+
+// autopilotOverride runs the AutopilotHook, if one is set and an autopilot is active, and
+// returns the stick values it wants sent.  ctrlMu must not be held as the hook may call back.
+func (tello *Tello) autopilotOverride(yaw, ref float32) (sticks stickAxes, overridden bool) {
+	tello.ctrlMu.RLock()
+	hook := tello.ctrlAutoHook
+	target := tello.stickTargets(yaw, ref)
+	tello.ctrlMu.RUnlock()
+	if hook == nil {
+		return sticks, false
+	}
+	active := tello.ActiveAutopilots()
+	if !active.Any() {
+		return sticks, false
+	}
+	sm, allow := hook(AutopilotOutput{
+		Sticks:     StickMessage{Rx: target.rx, Ry: target.ry, Lx: target.lx, Ly: target.ly},
+		Active:     active,
+		FlightData: tello.GetFlightData(),
+	})
+	if allow {
+		sticks = stickAxes{rx: sm.Rx, ry: sm.Ry, lx: sm.Lx, ly: sm.Ly}
+	}
+	return sticks, true
+}
@@ -0,0 +1,67 @@
+// autohook_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "testing"
+
+func TestAutopilotHook(t *testing.T) {
+	drone := new(Tello)
+	drone.sendQ.running = true // queue packets, but with no writer to send them
+	var calls int
+	var seen AutopilotOutput
+	allow := true
+	drone.SetAutopilotHook(func(out AutopilotOutput) (StickMessage, bool) {
+		calls++
+		seen = out
+		out.Sticks.Ly /= 2
+		return out.Sticks, allow
+	})
+	sent := func() stickAxes {
+		drone.sendStickUpdate()
+		drone.ctrlMu.RLock()
+		defer drone.ctrlMu.RUnlock()
+		return drone.ctrlSent
+	}
+
+	drone.UpdateSticks(StickMessage{Rx: 100, Ly: 1000})
+	if s := sent(); calls != 0 || s.ly != 1000 {
+		t.Errorf("Expected the hook not to be called without an autopilot, got %d calls and %+v", calls, s)
+	}
+
+	drone.autoHeight = true
+	if s := sent(); calls != 1 || s.ly != 500 || s.rx != 100 {
+		t.Errorf("Expected the hook to halve the throttle, got %d calls and %+v", calls, s)
+	}
+	if !seen.Active.Height || seen.Active.XY || seen.Sticks.Ly != 1000 {
+		t.Errorf("Unexpected hook input %+v", seen)
+	}
+
+	allow = false
+	if s := sent(); s != (stickAxes{}) {
+		t.Errorf("Expected a veto to centre the sticks, got %+v", s)
+	}
+
+	drone.SetAutopilotHook(nil)
+	if s := sent(); calls != 2 || s.ly != 1000 {
+		t.Errorf("Expected the hook to be removed, got %d calls and %+v", calls, s)
+	}
+}
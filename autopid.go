@@ -0,0 +1,86 @@
+// autopid.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"time"
+
+	"github.com/SMerrony/tello/pid"
+)
+
+// PIDAxis identifies one of the autopilots' closed-loop controllers.
+type PIDAxis int
+
+// The autopilot controllers...
+const (
+	PIDHeight PIDAxis = iota // AutoFlyToHeight(), error in decimetres, drives the throttle (Ly)
+	PIDYaw                   // AutoTurnToYaw() etc., error in degrees, drives the yaw (Lx)
+	PIDX                     // AutoFlyToXY() sideways, error in metres, drives the roll (Rx)
+	PIDY                     // AutoFlyToXY() forwards, error in metres, drives the pitch (Ry)
+	numPIDAxes
+)
+
+// WithPID makes the autopilot controlling axis use a PID controller with the given gains,
+// instead of the default of stepped speeds.  The controller's output is in StickMessage units,
+// so eg. a Kp of 3000 for PIDHeight means 3000 per decimetre off target, and is limited to
+// the autopilots' maximum speed.  The speed passed to eg. AutoFlyToHeightConfig() scales it.
+// For PIDX and PIDY the setpoint is the distance to go in the drone's frame and the measured
+// value is always 0.
+// Use PID() to tune the controller while flying.
+func WithPID(axis PIDAxis, gains pid.Gains) Option {
+	return func(tello *Tello) {
+		if axis >= 0 && axis < numPIDAxes {
+			tello.cfg.pids[axis] = pid.New(gains, -autoPilotSpeedFast, autoPilotSpeedFast)
+		}
+	}
+}
+
+// PID returns the controller used by the autopilot for axis, or nil if it was not configured
+// via WithPID().  Its gains may be changed at any time, and its Telemetry() shows how it is
+// performing.
+func (tello *Tello) PID(axis PIDAxis) *pid.Controller {
+	if axis < 0 || axis >= numPIDAxes {
+		return nil
+	}
+	return tello.cfg.pids[axis]
+}
+
+// pidTimer provides the interval between successive autopilot updates.
+type pidTimer struct {
+	tello *Tello
+	last  time.Time
+}
+
+// next returns the time since the previous call, or 0 on the first.
+func (pt *pidTimer) next() (dt time.Duration) {
+	now := pt.tello.now()
+	if !pt.last.IsZero() {
+		dt = now.Sub(pt.last)
+	}
+	pt.last = now
+	return dt
+}
+
+// pidStick returns the stick value from ctl for the given setpoint and measurement, scaled by speed.
+func pidStick(ctl *pid.Controller, setpoint, measured float64, dt time.Duration, speed float32) int16 {
+	return clampStick(float32(ctl.Update(setpoint, measured, dt)) * speed)
+}
@@ -0,0 +1,71 @@
+// autopid_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello/pid"
+)
+
+func TestAutoFlyToHeightPID(t *testing.T) {
+	drone := NewTello(WithPID(PIDHeight, pid.Gains{Kp: 1000}))
+	if drone.PID(PIDYaw) != nil || drone.PID(numPIDAxes) != nil {
+		t.Error("Expected only the height PID to be configured")
+	}
+	ctl := drone.PID(PIDHeight)
+
+	done, err := drone.AutoFlyToHeight(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ly := func() int16 {
+		time.Sleep(3 * autopilotPeriodMs * time.Millisecond)
+		drone.ctrlMu.RLock()
+		defer drone.ctrlMu.RUnlock()
+		return drone.ctrlLy
+	}
+	if got := ly(); got != 10000 {
+		t.Errorf("Expected a throttle of 10000, got %d", got)
+	}
+	if tm := ctl.Telemetry(); tm.Setpoint != 10 || tm.Error != 10 {
+		t.Errorf("Unexpected telemetry %+v", tm)
+	}
+
+	ctl.SetGains(pid.Gains{Kp: 2000})
+	if got := ly(); got != 20000 {
+		t.Errorf("Expected retuning to take effect, got %d", got)
+	}
+
+	drone.fdMu.Lock()
+	drone.fd.Height = 10
+	drone.fdMu.Unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("AutoFlyToHeight did not complete")
+	}
+}
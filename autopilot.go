@@ -53,6 +53,14 @@ func (tello *Tello) CancelAutoFlyToHeight() {
 	tello.autoHeightMu.Unlock()
 }
 
+// IsAutoHeight tests whether we are currently auto-navigating vertically
+func (tello *Tello) IsAutoHeight() (set bool) {
+	tello.autoHeightMu.RLock()
+	set = tello.autoHeight
+	tello.autoHeightMu.RUnlock()
+	return set
+}
+
 // AutoFlyToHeight starts vertical movement to the specified height in decimetres
 // (so a value of 10 means 1m).
 // The func returns immediately and a Goroutine handles the navigation until either
@@ -74,11 +82,11 @@ func (tello *Tello) AutoFlyToHeight(dm int16) (done chan error, err error) {
 // the navigation is complete (or has been cancelled).
 func (tello *Tello) AutoFlyToHeightConfig(dm int16, speed float32, tolerance int16) (done chan error, err error) {
 	if speed < 0.25 { // Probably wouldn't move when getting closer with a value lower than 0.25
-		log.Println("WARN: AutoFly speed too low, increasing to 0.25")
+		tello.logln("WARN: AutoFly speed too low, increasing to 0.25")
 		speed = 0.25
 	}
 	if speed > 1 {
-		log.Println("WARN: AutoFly speed too high, decreasing to 1.0 (max speed)")
+		tello.logln("WARN: AutoFly speed too high, decreasing to 1.0 (max speed)")
 		speed = 1
 	}
 	//log.Printf("AutoFlyToHeight called with height: %d\n", dm)
@@ -101,8 +109,14 @@ func (tello *Tello) AutoFlyToHeightConfig(dm int16, speed float32, tolerance int
 
 	//log.Println("Autoheight set - starting goroutine")
 
+	ctl := tello.PID(PIDHeight)
+	if ctl != nil {
+		ctl.Reset()
+	}
+
 	go func() {
 		returnedError := errors.New("AutoFlyToHeight cancelled")
+		timer := pidTimer{tello: tello}
 		for {
 			// has autoflight been cancelled?
 			tello.autoHeightMu.RLock()
@@ -121,12 +135,26 @@ func (tello *Tello) AutoFlyToHeightConfig(dm int16, speed float32, tolerance int
 			}
 
 			tello.fdMu.RLock()
-			delta := dm - tello.fd.Height // delta will be positive if we are too low
+			height := tello.fd.Height
+			delta := dm - height // delta will be positive if we are too low
 			//log.Printf("Target: %d, Height: %d, Delta: %d\n", dm, tello.fd.Height, delta)
 			tello.fdMu.RUnlock()
+			dt := timer.next()
 
 			tello.ctrlMu.Lock()
+			if !tello.IsAutoHeight() { // cancelled meanwhile, eg. by Hover(), so leave the sticks alone
+				tello.ctrlMu.Unlock()
+				continue
+			}
 			switch {
+			case ctl != nil && (delta > tolerance || delta < -tolerance):
+				tello.ctrlLy = pidStick(ctl, float64(dm), float64(height), dt, speed)
+			case ctl != nil:
+				tello.ctrlLy = 0
+				returnedError = nil
+				tello.autoHeightMu.Lock()
+				tello.autoHeight = false
+				tello.autoHeightMu.Unlock()
 			case delta > 4:
 				tello.ctrlLy = int16(autoPilotSpeedFast * speed) // full throttle if >40cm off target
 			case delta > 0:
@@ -181,11 +209,11 @@ func (tello *Tello) AutoTurnToYaw(targetYaw float32) (done chan error, err error
 // You may explicitly cancel this operation via CancelAutoTurn().
 func (tello *Tello) AutoTurnToYawConfig(targetYaw, speed float32, tolerance int16) (done chan error, err error) {
 	if speed < 0.25 { // Probably wouldn't move when getting closer with a value lower than 0.25
-		log.Println("WARN: AutoTurn speed too low, increasing to 0.25")
+		tello.logln("WARN: AutoTurn speed too low, increasing to 0.25")
 		speed = 0.25
 	}
 	if speed > 1 {
-		log.Println("WARN: AutoTurn speed too high, decreasing to 1.0 (max speed)")
+		tello.logln("WARN: AutoTurn speed too high, decreasing to 1.0 (max speed)")
 		speed = 1
 	}
 	//log.Printf("AutoTurnToYaw called with target: %d\n", targetYaw)
@@ -213,8 +241,14 @@ func (tello *Tello) AutoTurnToYawConfig(targetYaw, speed float32, tolerance int1
 
 	//log.Println("autoYaw set - starting goroutine")
 
+	ctl := tello.PID(PIDYaw)
+	if ctl != nil {
+		ctl.Reset()
+	}
+
 	go func() {
 		returnedError := errors.New("AutoFlyToYaw cancelled")
+		timer := pidTimer{tello: tello}
 		for {
 			// has autoflight been cancelled?
 			tello.autoYawMu.RLock()
@@ -250,9 +284,22 @@ func (tello *Tello) AutoTurnToYawConfig(targetYaw, speed float32, tolerance int1
 			}
 
 			//log.Printf("Target: %d, Current: %d, Delta: %d\n", adjustedTarget, adjustedCurrent, delta)
+			dt := timer.next()
 
 			tello.ctrlMu.Lock()
+			if !tello.IsAutoTurning() { // cancelled meanwhile, eg. by Hover(), so leave the sticks alone
+				tello.ctrlMu.Unlock()
+				continue
+			}
 			switch {
+			case ctl != nil && int16(float32Abs(delta)) > tolerance:
+				tello.ctrlLx = pidStick(ctl, float64(adjustedTarget), float64(adjustedTarget-delta), dt, speed)
+			case ctl != nil:
+				tello.ctrlLx = 0
+				returnedError = nil
+				tello.autoYawMu.Lock()
+				tello.autoYaw = false
+				tello.autoYawMu.Unlock()
 			case delta > 10:
 				tello.ctrlLx = int16(autoPilotSpeedFast * speed)
 			case delta > 0:
@@ -395,19 +442,19 @@ func (tello *Tello) AutoFlyToXY(targetX, targetY float32) (done chan error, err
 // the navigation is complete (or has been cancelled).
 func (tello *Tello) AutoFlyToXYConfig(targetX, targetY, speedX, speedY, tolerance float32) (done chan error, err error) {
 	if speedX < 0.25 { // Probably wouldn't move when getting closer with a value lower than 0.25
-		log.Println("WARN: AutoFly speed too low, increasing to 0.25")
+		tello.logln("WARN: AutoFly speed too low, increasing to 0.25")
 		speedX = 0.25
 	}
 	if speedX > 1 {
-		log.Println("WARN: AutoFly speed too high, decreasing to 1.0 (max speed)")
+		tello.logln("WARN: AutoFly speed too high, decreasing to 1.0 (max speed)")
 		speedX = 1
 	}
 	if speedY < 0.25 { // Probably wouldn't move when getting closer with a value lower than 0.25
-		log.Println("WARN: AutoFly speed too low, increasing to 0.25")
+		tello.logln("WARN: AutoFly speed too low, increasing to 0.25")
 		speedY = 0.25
 	}
 	if speedY > 1 {
-		log.Println("WARN: AutoFly speed too high, decreasing to 1.0 (max speed)")
+		tello.logln("WARN: AutoFly speed too high, decreasing to 1.0 (max speed)")
 		speedY = 1
 	}
 	//log.Printf("FlyToXY called with XY: %d\n", dm)
@@ -437,11 +484,20 @@ func (tello *Tello) AutoFlyToXYConfig(targetX, targetY, speedX, speedY, toleranc
 	// adjust target relative to origin -SHOULD WE ADJUST YAW TOO???
 	targetX += originX
 	targetY += originY
+	yawOffset := tello.YawOffset()
 
 	done = make(chan error, 1) // won't block as we will close it to notify listeners
 
 	//log.Println("AutoXY set - starting goroutine")
 
+	ctlX, ctlY := tello.PID(PIDX), tello.PID(PIDY)
+	if ctlX != nil {
+		ctlX.Reset()
+	}
+	if ctlY != nil {
+		ctlY.Reset()
+	}
+
 	go func() {
 		var (
 			currentYaw         float32
@@ -449,6 +505,7 @@ func (tello *Tello) AutoFlyToXYConfig(targetX, targetY, speedX, speedY, toleranc
 			lowLight           bool
 		)
 		returnedError := errors.New("AutoFlyToXY cancelled")
+		timer := pidTimer{tello: tello}
 		for {
 			// has autoflight been cancelled?
 			tello.autoXYMu.RLock()
@@ -468,7 +525,7 @@ func (tello *Tello) AutoFlyToXYConfig(targetX, targetY, speedX, speedY, toleranc
 
 			// get current yaw & position
 			tello.fdMu.RLock()
-			currentYaw = tello.fd.IMU.Yaw
+			currentYaw = tello.fd.IMU.Yaw + yawOffset
 			currentX = tello.fd.MVO.PositionX
 			currentY = tello.fd.MVO.PositionY
 			lowLight = tello.fd.LightStrength == 1
@@ -483,13 +540,20 @@ func (tello *Tello) AutoFlyToXYConfig(targetX, targetY, speedX, speedY, toleranc
 			}
 
 			deltaX, deltaY := calcXYdeltas(currentYaw, currentX, currentY, targetX, targetY)
-			log.Println("Deltas: ", deltaX, ",", deltaY)
+			tello.logln("Deltas: ", deltaX, ",", deltaY)
+			dt := timer.next()
 
 			tello.ctrlMu.Lock()
+			if !tello.IsAutoXY() { // cancelled meanwhile, eg. by Hover(), so leave the sticks alone
+				tello.ctrlMu.Unlock()
+				continue
+			}
 
 			switch {
 			case deltaX <= tolerance && deltaX >= -tolerance:
 				tello.ctrlRx = 0
+			case ctlX != nil:
+				tello.ctrlRx = pidStick(ctlX, float64(deltaX), 0, dt, speedX)
 			case deltaX >= AutoXYNearTargetM:
 				tello.ctrlRx = int16(autoPilotSpeedFast * speedX) // full throttle if =>AutoXYNearTargetM off target
 			case deltaX <= -AutoXYNearTargetM:
@@ -504,6 +568,8 @@ func (tello *Tello) AutoFlyToXYConfig(targetX, targetY, speedX, speedY, toleranc
 			switch {
 			case deltaY <= tolerance && deltaY >= -tolerance:
 				tello.ctrlRy = 0
+			case ctlY != nil:
+				tello.ctrlRy = pidStick(ctlY, float64(deltaY), 0, dt, speedY)
 			case deltaY >= AutoXYNearTargetM:
 				tello.ctrlRy = int16(autoPilotSpeedFast * speedY) // full throttle if =>AutoXYNearTargetM off target
 			case deltaY <= -AutoXYNearTargetM:
@@ -0,0 +1,117 @@
+// battery.go
+
+// Battery time-remaining estimation and the low-battery supervisor.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "time"
+
+const (
+	nominalFlightTime  = 13 * time.Minute // flight time on a full battery, used until we have measured the discharge rate
+	dischargeWindow    = time.Minute      // period over which we measure the discharge rate
+	minDischargeWindow = 10 * time.Second // shortest period from which we trust a discharge rate
+	defaultReserve     = time.Minute      // flight time to keep in hand for landing
+)
+
+type batterySample struct {
+	at  time.Time
+	pct int8
+}
+
+// EstimatedFlightTimeLeft returns how much longer the Tello can fly before it eats into the
+// reserve margin set via WithBatteryReserve(), it is zero once the reserve has been reached.
+// The estimate is the most pessimistic of the drone's own report, the recent discharge rate,
+// and the nominal flight time scaled by the battery percentage.
+func (tello *Tello) EstimatedFlightTimeLeft() time.Duration {
+	tello.fdMu.RLock()
+	left := estimateFlightTimeLeft(tello.fd, tello.battSamples)
+	tello.fdMu.RUnlock()
+	left -= tello.cfg.getBatteryReserve()
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// estimateFlightTimeLeft combines the available estimates of the remaining flight time, ignoring any reserve.
+func estimateFlightTimeLeft(fd FlightData, samples []batterySample) time.Duration {
+	left := time.Duration(fd.BatteryPercentage) * nominalFlightTime / 100
+	if fd.DroneFlyTimeLeft > 0 {
+		if reported := time.Duration(fd.DroneFlyTimeLeft) * time.Second; reported < left {
+			left = reported
+		}
+	}
+	if len(samples) > 1 {
+		first, last := samples[0], samples[len(samples)-1]
+		elapsed := last.at.Sub(first.at)
+		if used := first.pct - last.pct; used > 0 && elapsed >= minDischargeWindow {
+			if measured := time.Duration(last.pct) * elapsed / time.Duration(used); measured < left {
+				left = measured
+			}
+		}
+	}
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// recordBattery keeps the recent in-flight battery readings used to measure the discharge rate.
+// fdMu must be held by the caller.
+func (tello *Tello) recordBattery(now time.Time) {
+	if !tello.fd.Flying {
+		tello.battSamples = tello.battSamples[:0] // discharge on the ground tells us nothing about flight
+		return
+	}
+	tello.battSamples = append(tello.battSamples, batterySample{at: now, pct: tello.fd.BatteryPercentage})
+	drop := 0
+	for drop < len(tello.battSamples)-1 && now.Sub(tello.battSamples[drop].at) > dischargeWindow {
+		drop++
+	}
+	tello.battSamples = append(tello.battSamples[:0], tello.battSamples[drop:]...)
+}
+
+// superviseBattery sends a single EvBatteryReserve Event per flight once the estimated flight time left
+// reaches the reserve, and then applies the policy set via WithLowBatteryAction().
+func (tello *Tello) superviseBattery() {
+	tello.fdMu.Lock()
+	if !tello.fd.Flying {
+		tello.battWarned = false
+		tello.fdMu.Unlock()
+		return
+	}
+	if tello.battWarned {
+		tello.fdMu.Unlock()
+		return
+	}
+	left := estimateFlightTimeLeft(tello.fd, tello.battSamples)
+	if left > tello.cfg.getBatteryReserve() {
+		tello.fdMu.Unlock()
+		return
+	}
+	tello.battWarned = true
+	tello.fdMu.Unlock()
+
+	tello.logf("Battery reserve reached, estimated flight time left: %v\n", left)
+	tello.emitEvent(EvBatteryReserve, left)
+	tello.applyPolicy(tello.cfg.lowBattery)
+}
@@ -0,0 +1,109 @@
+// tello project battery_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateFlightTimeLeft(t *testing.T) {
+	if left := estimateFlightTimeLeft(FlightData{BatteryPercentage: 50}, nil); left != nominalFlightTime/2 {
+		t.Errorf("Expected half the nominal flight time, got %v", left)
+	}
+	if left := estimateFlightTimeLeft(FlightData{BatteryPercentage: 50, DroneFlyTimeLeft: 60}, nil); left != time.Minute {
+		t.Errorf("Expected the drone's own report of 1m, got %v", left)
+	}
+	t0 := time.Now()
+	samples := []batterySample{{t0, 52}, {t0.Add(30 * time.Second), 51}, {t0.Add(time.Minute), 50}}
+	if left := estimateFlightTimeLeft(FlightData{BatteryPercentage: 50}, samples); left != nominalFlightTime/2 {
+		t.Errorf("Expected the nominal estimate to be more pessimistic than 2%%/min, got %v", left)
+	}
+	samples = []batterySample{{t0, 60}, {t0.Add(time.Minute), 50}}
+	if left := estimateFlightTimeLeft(FlightData{BatteryPercentage: 50}, samples); left != 5*time.Minute {
+		t.Errorf("Expected 5m at 10%%/min, got %v", left)
+	}
+}
+
+func TestRecordBattery(t *testing.T) {
+	tello := new(Tello)
+	t0 := time.Now()
+	tello.fd.Flying = true
+	for i := 0; i < 100; i++ {
+		tello.fd.BatteryPercentage = int8(100 - i/10)
+		tello.recordBattery(t0.Add(time.Duration(i) * time.Second))
+	}
+	if span := tello.battSamples[len(tello.battSamples)-1].at.Sub(tello.battSamples[0].at); span > dischargeWindow {
+		t.Errorf("Expected samples to be trimmed to %v, got %v", dischargeWindow, span)
+	}
+	tello.fd.Flying = false
+	tello.recordBattery(t0.Add(100 * time.Second))
+	if len(tello.battSamples) != 0 {
+		t.Errorf("Expected samples to be discarded on landing, got %d", len(tello.battSamples))
+	}
+}
+
+func TestSuperviseBattery(t *testing.T) {
+	tello := NewTello(WithBatteryReserve(2 * time.Minute))
+	events, stop := tello.ListenEvents()
+	defer stop()
+
+	tello.fd.Flying = true
+	tello.fd.BatteryPercentage = 50
+	tello.superviseBattery()
+	if tello.EstimatedFlightTimeLeft() != nominalFlightTime/2-2*time.Minute {
+		t.Errorf("Expected the reserve to be subtracted, got %v", tello.EstimatedFlightTimeLeft())
+	}
+
+	tello.fd.BatteryPercentage = 10
+	tello.superviseBattery()
+	tello.superviseBattery()
+	if tello.EstimatedFlightTimeLeft() != 0 {
+		t.Errorf("Expected no flight time left, got %v", tello.EstimatedFlightTimeLeft())
+	}
+	select {
+	case ev := <-events:
+		if ev.Type != EvBatteryReserve || ev.Data.(time.Duration) != nominalFlightTime/10 {
+			t.Errorf("Unexpected event %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No EvBatteryReserve event received")
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("Expected a single event per flight, got %+v", ev)
+	default:
+	}
+}
+
+func TestNoBatteryReserve(t *testing.T) {
+	tello := NewTello(WithBatteryReserve(0))
+	tello.fd.BatteryPercentage = 50
+	if left := tello.EstimatedFlightTimeLeft(); left != nominalFlightTime/2 {
+		t.Errorf("Expected no reserve to be subtracted, got %v", left)
+	}
+	tello = new(Tello)
+	tello.fd.BatteryPercentage = 50
+	if left := tello.EstimatedFlightTimeLeft(); left != nominalFlightTime/2-defaultReserve {
+		t.Errorf("Expected the default reserve to be subtracted, got %v", left)
+	}
+}
@@ -0,0 +1,180 @@
+// bitrate.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultBitrateInterval               = 2 * time.Second
+	defaultBitrateStepDownLoss           = 0.05
+	defaultBitrateStepUpLoss             = 0.01
+	defaultBitrateInterference           = 50
+	defaultBitrateStepUpAfter            = 3
+	defaultBitrateMin, defaultBitrateMax = Vbr1M, Vbr4M
+)
+
+// BitrateConfig configures the adaptive bitrate controller, see WithAdaptiveBitrate().
+// Zero values take the defaults shown.
+type BitrateConfig struct {
+	Min, Max        VBR           // the range of bitrates used, default Vbr1M to Vbr4M
+	Interval        time.Duration // how often the link is assessed, default 2s
+	StepDownLoss    float64       // smoothed fraction of video slices lost above which we step down, default 0.05
+	StepUpLoss      float64       // smoothed fraction of video slices lost below which we may step up, default 0.01
+	MaxInterference int           // WiFi interference above which we step down, default 50, we step up only below half of this
+	StepUpAfter     int           // consecutive good assessments needed before stepping up, default 3
+}
+
+func (bc BitrateConfig) withDefaults() BitrateConfig {
+	if bc.Min == VbrAuto {
+		bc.Min = defaultBitrateMin
+	}
+	if bc.Max == VbrAuto {
+		bc.Max = defaultBitrateMax
+	}
+	if bc.Max < bc.Min {
+		bc.Max = bc.Min
+	}
+	if bc.Interval <= 0 {
+		bc.Interval = defaultBitrateInterval
+	}
+	if bc.StepDownLoss <= 0 {
+		bc.StepDownLoss = defaultBitrateStepDownLoss
+	}
+	if bc.StepUpLoss <= 0 {
+		bc.StepUpLoss = defaultBitrateStepUpLoss
+	}
+	if bc.MaxInterference <= 0 {
+		bc.MaxInterference = defaultBitrateInterference
+	}
+	if bc.StepUpAfter <= 0 {
+		bc.StepUpAfter = defaultBitrateStepUpAfter
+	}
+	return bc
+}
+
+// bitrateState is the adaptive bitrate controller's state.
+type bitrateState struct {
+	mu      sync.Mutex
+	level   VBR  // the bitrate chosen, VbrAuto until the controller has run
+	good    int  // consecutive good assessments
+	applied bool // has level been sent since the connection started or congestion ended?
+}
+
+// WithAdaptiveBitrate enables a controller which steps the video bitrate down, one setting at a time,
+// while video slices are being lost or there is a lot of WiFi interference, and steps it back up once the
+// link has been good for a while.  This keeps the stream watchable as the drone approaches the edge of
+// its range.  The controller starts at cfg.Max on each connection.
+// If WithAdaptiveLink() is also used, its congestion throttling takes precedence while it lasts.
+func WithAdaptiveBitrate(cfg BitrateConfig) Option {
+	return func(tello *Tello) {
+		cfg = cfg.withDefaults()
+		tello.cfg.adaptiveBitrate = &cfg
+	}
+}
+
+// AdaptiveBitrate returns the bitrate currently chosen by the adaptive bitrate controller,
+// VbrAuto if it is not enabled or has not yet run.
+func (tello *Tello) AdaptiveBitrate() VBR {
+	tello.abr.mu.Lock()
+	defer tello.abr.mu.Unlock()
+	return tello.abr.level
+}
+
+// adaptBitrate assesses the link once, and steps the bitrate up or down as required.
+// It returns the bitrate now chosen.
+func (tello *Tello) adaptBitrate(cfg *BitrateConfig) VBR {
+	tello.fdMu.RLock()
+	interference := int(tello.fd.WifiInterference)
+	tello.fdMu.RUnlock()
+	ls := &tello.link
+	ls.mu.Lock()
+	loss, congested := ls.videoLoss, ls.congested
+	if !ls.videoSeen {
+		loss = 0
+	}
+	if !ls.wifiSeen {
+		interference = 0
+	}
+	ls.mu.Unlock()
+
+	abr := &tello.abr
+	abr.mu.Lock()
+	if congested {
+		abr.applied = false // adaptLink() has set the bitrate
+		level := abr.level
+		abr.mu.Unlock()
+		return level
+	}
+	was := abr.level
+	switch {
+	case abr.level == VbrAuto:
+		abr.level = cfg.Max
+	case loss > cfg.StepDownLoss || interference > cfg.MaxInterference:
+		abr.good = 0
+		if abr.level > cfg.Min {
+			abr.level--
+		}
+	case loss < cfg.StepUpLoss && interference < cfg.MaxInterference/2:
+		abr.good++
+		if abr.good >= cfg.StepUpAfter && abr.level < cfg.Max {
+			abr.level++
+			abr.good = 0
+		}
+	default:
+		abr.good = 0
+	}
+	level, send := abr.level, abr.level != was || !abr.applied
+	abr.applied = true
+	abr.mu.Unlock()
+
+	if send {
+		if level != was {
+			tello.logf("Video slice loss %.3f, WiFi interference %d, video bitrate setting now %d\n", loss, interference, level)
+			tello.emitEvent(EvVideoBitrate, level)
+		}
+		tello.SetVideoBitrate(level)
+	}
+	return level
+}
+
+// bitrateAdapter runs the adaptive bitrate controller, if enabled, until done is closed.
+func (tello *Tello) bitrateAdapter(done chan struct{}) {
+	cfg := tello.cfg.adaptiveBitrate
+	if cfg == nil {
+		return
+	}
+	tello.abr.mu.Lock()
+	tello.abr.level, tello.abr.good, tello.abr.applied = VbrAuto, 0, false
+	tello.abr.mu.Unlock()
+	clock := tello.cfg.getClock()
+	for {
+		tello.adaptBitrate(cfg)
+		select {
+		case <-done:
+			return
+		case <-clock.After(cfg.Interval):
+		}
+	}
+}
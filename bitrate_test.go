@@ -0,0 +1,110 @@
+// bitrate_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "testing"
+
+func TestAdaptBitrate(t *testing.T) {
+	drone := NewTello(WithAdaptiveBitrate(BitrateConfig{Min: Vbr1M5, Max: Vbr3M, StepUpAfter: 2}))
+	drone.sendQ.running = true // queue the bitrate commands without a connection
+	cfg := drone.cfg.adaptiveBitrate
+	sent := func() (vbrs []VBR) {
+		drone.sendQ.mu.Lock()
+		defer drone.sendQ.mu.Unlock()
+		for _, buff := range drone.sendQ.queues[prioCommand] {
+			if pkt := bufferToPacket(buff); pkt.messageID == MsgSetVideoBitrate {
+				vbrs = append(vbrs, VBR(pkt.payload[0]))
+			}
+		}
+		drone.sendQ.queues[prioCommand] = nil
+		return vbrs
+	}
+	events, stop := drone.ListenEvents()
+	defer stop()
+
+	if got := drone.adaptBitrate(cfg); got != Vbr3M {
+		t.Errorf("Expected to start at the maximum, got %v", got)
+	}
+	if vbrs := sent(); len(vbrs) != 1 || vbrs[0] != Vbr3M {
+		t.Errorf("Expected the maximum to be set, got %v", vbrs)
+	}
+
+	// slices are being lost, so step down to the minimum and stay there
+	drone.link.videoSeen, drone.link.videoLoss = true, 0.2
+	for _, want := range []VBR{Vbr2M, Vbr1M5, Vbr1M5} {
+		if got := drone.adaptBitrate(cfg); got != want {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	}
+	if vbrs := sent(); len(vbrs) != 2 {
+		t.Errorf("Expected two bitrate changes, got %v", vbrs)
+	}
+	if ev := <-events; ev.Type != EvVideoBitrate || ev.Data != Vbr3M {
+		t.Errorf("Unexpected event %+v", ev)
+	}
+
+	// interference alone also steps down, and holds the bitrate until it has halved
+	drone.link.videoLoss = 0
+	drone.link.wifiSeen = true
+	drone.fd.WifiInterference = 60
+	drone.abr.level = Vbr2M
+	if got := drone.adaptBitrate(cfg); got != Vbr1M5 {
+		t.Errorf("Expected interference to step down, got %v", got)
+	}
+	drone.fd.WifiInterference = 30
+	for i := 0; i < 3; i++ {
+		if got := drone.adaptBitrate(cfg); got != Vbr1M5 {
+			t.Errorf("Expected to hold the bitrate, got %v", got)
+		}
+	}
+
+	// a good link steps up after StepUpAfter assessments
+	drone.fd.WifiInterference = 10
+	for _, want := range []VBR{Vbr1M5, Vbr2M, Vbr2M, Vbr3M, Vbr3M, Vbr3M} {
+		if got := drone.adaptBitrate(cfg); got != want {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	}
+	sent()
+
+	// while adaptLink() is throttling we leave the bitrate alone, then restore ours
+	drone.link.congested = true
+	drone.adaptBitrate(cfg)
+	if vbrs := sent(); len(vbrs) != 0 {
+		t.Errorf("Expected no bitrate commands while congested, got %v", vbrs)
+	}
+	drone.link.congested = false
+	drone.adaptBitrate(cfg)
+	if vbrs := sent(); len(vbrs) != 1 || vbrs[0] != Vbr3M {
+		t.Errorf("Expected the bitrate to be restored, got %v", vbrs)
+	}
+}
+
+func TestBitrateConfigDefaults(t *testing.T) {
+	cfg := BitrateConfig{Min: Vbr4M, Max: Vbr2M}.withDefaults()
+	if cfg.Max != Vbr4M || cfg.Interval != defaultBitrateInterval || cfg.StepUpAfter != defaultBitrateStepUpAfter {
+		t.Errorf("Unexpected defaults %+v", cfg)
+	}
+	if new(Tello).AdaptiveBitrate() != VbrAuto {
+		t.Error("Expected VbrAuto when not enabled")
+	}
+}
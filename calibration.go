@@ -0,0 +1,131 @@
+// calibration.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+// CalibrationType selects what the Tello calibrates.
+type CalibrationType byte
+
+// Calibration types, these are the values sent by the official app...
+const (
+	CalibrationIMU     CalibrationType = 0 // the IMU, the drone is turned onto each side as the app instructs
+	CalibrationHorizon CalibrationType = 1 // the level (centre of gravity), the drone should sit on a flat surface
+)
+
+// CalibrationStage is the progress of a calibration reported in a CalibrationEvent.
+type CalibrationStage int
+
+// Calibration stages...
+const (
+	CalibrationStarted  CalibrationStage = iota // the drone has accepted the request
+	CalibrationProgress                         // the drone's ImuCalibrationState has changed, see State
+	CalibrationDone                             // the ImuCalibrationState has returned to zero
+	CalibrationFailed                           // the drone refused the request, see Err
+)
+
+// CalibrationEvent is the Data of an EvCalibration Event.
+type CalibrationEvent struct {
+	Type  CalibrationType
+	Stage CalibrationStage
+	State int8  // FlightData.ImuCalibrationState when the event was sent
+	Err   error // a *CommandError if Stage is CalibrationFailed
+}
+
+// CalibrateIMU asks the Tello to calibrate its IMU, which can cure drift.
+// Progress is reported via EvCalibration Events.  ErrAirborne is returned if we are flying.
+func (tello *Tello) CalibrateIMU() error {
+	return tello.calibrate(CalibrationIMU)
+}
+
+// CalibrateHorizon asks the Tello to calibrate its level, which can cure a steady drift to one side.
+// Progress is reported via EvCalibration Events.  ErrAirborne is returned if we are flying.
+func (tello *Tello) CalibrateHorizon() error {
+	return tello.calibrate(CalibrationHorizon)
+}
+
+func (tello *Tello) calibrate(ct CalibrationType) error {
+	if !tello.ControlConnected() {
+		return ErrNotConnected
+	}
+	tello.fdMu.Lock()
+	if tello.fd.Flying || tello.fd.State.IsAirborne() {
+		tello.fdMu.Unlock()
+		return ErrAirborne
+	}
+	tello.calType, tello.calRequested, tello.calRunning = ct, true, false
+	tello.calState, tello.calProgressed = tello.fd.ImuCalibrationState, false
+	tello.fdMu.Unlock()
+
+	tello.ctrlMu.Lock()
+	connected := tello.ctrlState == connConnected
+	if connected {
+		tello.ctrlSeq++
+		pkt := newPacket(ptSet, MsgDoCalibration, tello.ctrlSeq, 1)
+		pkt.payload[0] = byte(ct)
+		tello.enqueue(packetToBuffer(pkt))
+	}
+	tello.ctrlMu.Unlock()
+	if !connected { // disconnected since the check above
+		tello.fdMu.Lock()
+		tello.calRequested = false
+		tello.fdMu.Unlock()
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// calibrationAck handles the drone's reply to a calibration request.
+func (tello *Tello) calibrationAck(pkt packet) {
+	tello.fdMu.Lock()
+	if !tello.calRequested {
+		tello.fdMu.Unlock()
+		return
+	}
+	tello.calRequested = false
+	ev := CalibrationEvent{Type: tello.calType, Stage: CalibrationStarted, State: tello.calState}
+	if ev.Err = resultError(pkt.messageID, pkt.payload); ev.Err != nil {
+		ev.Stage = CalibrationFailed
+	} else {
+		tello.calRunning = true
+	}
+	tello.fdMu.Unlock()
+	tello.checkCommandResult(pkt)
+	tello.emitEvent(EvCalibration, ev)
+}
+
+// checkCalibration reports changes of the ImuCalibrationState while a calibration is running.
+func (tello *Tello) checkCalibration(state int8) {
+	tello.fdMu.Lock()
+	if !tello.calRunning || state == tello.calState {
+		tello.fdMu.Unlock()
+		return
+	}
+	tello.calState = state
+	ev := CalibrationEvent{Type: tello.calType, Stage: CalibrationProgress, State: state}
+	if state != 0 {
+		tello.calProgressed = true
+	} else if tello.calProgressed {
+		ev.Stage = CalibrationDone
+		tello.calRunning = false
+	}
+	tello.fdMu.Unlock()
+	tello.emitEvent(EvCalibration, ev)
+}
@@ -0,0 +1,98 @@
+// calibration_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCalibration(t *testing.T) {
+	drone := new(Tello)
+	drone.sendQ.running = true
+	drone.setCtrlState(connConnected)
+	events, stop := drone.ListenEvents()
+	defer stop()
+	next := func() CalibrationEvent {
+		for {
+			select {
+			case ev := <-events:
+				if ev.Type == EvCalibration {
+					return ev.Data.(CalibrationEvent)
+				}
+			default:
+				t.Fatal("Expected an EvCalibration event")
+			}
+		}
+	}
+
+	if err := drone.CalibrateIMU(); err != nil {
+		t.Fatal(err)
+	}
+	cmds := drone.sendQ.queues[prioCommand]
+	if pkt := bufferToPacket(cmds[len(cmds)-1]); pkt.messageID != MsgDoCalibration || pkt.payload[0] != byte(CalibrationIMU) {
+		t.Errorf("Expected an IMU calibration request, got %+v", pkt)
+	}
+	drone.checkCalibration(1) // not yet acknowledged, so ignored
+	drone.calibrationAck(packet{messageID: MsgDoCalibration, payload: []byte{0}})
+	if ev := next(); ev.Stage != CalibrationStarted || ev.Type != CalibrationIMU {
+		t.Errorf("Expected the calibration to start, got %+v", ev)
+	}
+	drone.checkCalibration(1)
+	drone.checkCalibration(1)
+	if ev := next(); ev.Stage != CalibrationProgress || ev.State != 1 {
+		t.Errorf("Expected progress, got %+v", ev)
+	}
+	drone.checkCalibration(0)
+	if ev := next(); ev.Stage != CalibrationDone {
+		t.Errorf("Expected the calibration to finish, got %+v", ev)
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("Unexpected event %+v", ev)
+	default:
+	}
+
+	if err := drone.CalibrateHorizon(); err != nil {
+		t.Fatal(err)
+	}
+	drone.calibrationAck(packet{messageID: MsgDoCalibration, payload: []byte{1}})
+	var ce *CommandError
+	if ev := next(); ev.Stage != CalibrationFailed || ev.Type != CalibrationHorizon || !errors.As(ev.Err, &ce) {
+		t.Errorf("Expected the calibration to be refused, got %+v", ev)
+	}
+
+	drone.fd.Flying = true
+	if err := drone.CalibrateIMU(); err != ErrAirborne {
+		t.Errorf("Expected ErrAirborne, got %v", err)
+	}
+
+	// a refused request leaves no calibration awaiting its ack
+	drone.fd.Flying = false
+	drone.setCtrlState(connDisconnected)
+	if err := drone.CalibrateIMU(); err != ErrNotConnected {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+	if drone.calRequested {
+		t.Error("Expected no calibration to be awaiting its ack")
+	}
+}
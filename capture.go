@@ -0,0 +1,95 @@
+// capture.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/SMerrony/tello/protocol"
+)
+
+// CaptureTransport wraps another Transport and records the control traffic in both directions
+// as a corpus (see protocol.ReadCorpus()), with the WiFi credentials and anything else which
+// could identify the drone removed.  Video and state data are not recorded.
+//
+//	f, _ := os.Create("session.corpus")
+//	ct := tello.NewCaptureTransport(nil, f)
+//	drone := tello.NewTello(tello.WithTransport(ct))
+//
+// Corpora recorded this way may be added to testdata/corpus, where the tests replay them
+// through the packet parsers.
+type CaptureTransport struct {
+	inner Transport
+	cw    *protocol.CorpusWriter
+}
+
+// NewCaptureTransport records the control traffic passing through inner to w, a nil inner
+// means UDPTransport.
+func NewCaptureTransport(inner Transport, w io.Writer) *CaptureTransport {
+	if inner == nil {
+		inner = UDPTransport{}
+	}
+	return &CaptureTransport{inner: inner, cw: protocol.NewCorpusWriter(w)}
+}
+
+// DialControl opens the control connection via the wrapped Transport and records its traffic.
+func (ct *CaptureTransport) DialControl(droneAddr string, dronePort, localPort int) (net.Conn, error) {
+	conn, err := ct.inner.DialControl(droneAddr, dronePort, localPort)
+	if err != nil {
+		return nil, err
+	}
+	return &captureConn{Conn: conn, cw: ct.cw}, nil
+}
+
+// ListenPackets passes through to the wrapped Transport, nothing is recorded.
+func (ct *CaptureTransport) ListenPackets(localPort int) (net.Conn, error) {
+	return ct.inner.ListenPackets(localPort)
+}
+
+// Err returns the first error writing the corpus, recording stops after an error but the
+// connection is unaffected.
+func (ct *CaptureTransport) Err() error {
+	return ct.cw.Err()
+}
+
+type captureConn struct {
+	net.Conn
+	cw *protocol.CorpusWriter
+}
+
+func (c *captureConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.cw.Write(time.Now(), false, b[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.cw.Write(time.Now(), true, b[:n])
+	}
+	return n, err
+}
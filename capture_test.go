@@ -0,0 +1,82 @@
+// capture_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/SMerrony/tello/protocol"
+)
+
+func TestCaptureTransport(t *testing.T) {
+	fake, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+	var corpus bytes.Buffer
+	ct := NewCaptureTransport(nil, &corpus)
+	conn, err := ct.DialControl("127.0.0.1", fake.LocalAddr().(*net.UDPAddr).Port, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write(packetToBuffer(newPacket(ptGet, MsgQuerySSID, 1, 0)))
+	buff := make([]byte, 64)
+	_, from, err := fake.ReadFromUDP(buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := newPacket(ptData1, MsgQuerySSID, 1, 0)
+	reply.fromDrone, reply.toDrone = true, false
+	reply.payload = []byte("\x00\x00MyHomeDrone")
+	fake.WriteToUDP(packetToBuffer(reply), from)
+	n, err := conn.Read(buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buff[:n], []byte("MyHomeDrone")) {
+		t.Error("Expected the client to see the real reply")
+	}
+
+	if err := ct.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(corpus.String(), "4d79486f6d65") { // "MyHome"
+		t.Error("Expected the SSID to be removed from the corpus")
+	}
+	pkts, err := protocol.ReadCorpus(&corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkts) != 2 || !pkts[0].ToDrone || pkts[1].ToDrone {
+		t.Fatalf("Got %+v", pkts)
+	}
+	pkt, err := protocol.Parse(pkts[1].Data)
+	if err != nil || string(pkt.Payload[2:]) != protocol.SanitizedSSID {
+		t.Errorf("Got %+v, %v", pkt, err)
+	}
+}
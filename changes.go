@@ -0,0 +1,152 @@
+// changes.go
+
+// Change detection on FlightData, for when StreamFlightData() is too chatty.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"math"
+	"sync"
+)
+
+// ChangeDetector decides which changes in a FlightData value are worth reporting, create one with
+// OnChange() or OnCrossing().  The value func may return NaN while the value is not yet known,
+// nothing is then reported.
+type ChangeDetector struct {
+	name   string
+	value  func(fd FlightData) float64
+	report func(prev, cur float64) bool
+}
+
+// OnChange reports whenever the chosen value has moved by at least threshold since it was last reported,
+// eg. OnChange("height", func(fd tello.FlightData) float64 { return float64(fd.Height) }, 2)
+func OnChange(name string, value func(fd FlightData) float64, threshold float64) ChangeDetector {
+	return ChangeDetector{name: name, value: value, report: func(prev, cur float64) bool {
+		return math.Abs(cur-prev) >= threshold
+	}}
+}
+
+// OnCrossing reports whenever the chosen value moves from one side of the limit to the other.  A value
+// is below the limit once it is less than limit, and above it once it reaches limit+hysteresis; in between
+// it stays on the side last reported, so that a value jittering around the limit is reported only once,
+// eg. to decide to return while the link is still usable:
+//
+//	drone.WatchFlightData(tello.OnCrossing("wifi", tello.WifiStrengthValue, 40, 10))
+func OnCrossing(name string, value func(fd FlightData) float64, limit, hysteresis float64) ChangeDetector {
+	return ChangeDetector{name: name, value: value, report: func(prev, cur float64) bool {
+		if prev < limit {
+			return cur >= limit+hysteresis
+		}
+		return cur < limit
+	}}
+}
+
+// WifiStrengthValue returns the WiFi strength for a ChangeDetector, or NaN if it has not yet been received.
+func WifiStrengthValue(fd FlightData) float64 {
+	if fd.WifiStrength == 0 && fd.WifiInterference == 0 {
+		return math.NaN()
+	}
+	return float64(fd.WifiStrength)
+}
+
+// LightStrengthValue returns the light strength for a ChangeDetector, or NaN if it has not yet been received.
+func LightStrengthValue(fd FlightData) float64 {
+	if fd.LightStrengthUpdated.IsZero() {
+		return math.NaN()
+	}
+	return float64(fd.LightStrength)
+}
+
+// FlightDataChange is sent by WatchFlightData() when a ChangeDetector reports a change.
+type FlightDataChange struct {
+	Name            string     // as given to the ChangeDetector
+	Previous, Value float64    // the previously reported and the new value
+	Data            FlightData // the complete FlightData which triggered the change
+}
+
+type watcher struct {
+	detectors []ChangeDetector
+	refs      []float64 // the last reported value for each detector, NaN until the first is known
+}
+
+type watchList struct {
+	mu       sync.Mutex
+	watchers map[chan FlightDataChange]*watcher
+}
+
+// WatchFlightData returns a channel that receives a FlightDataChange whenever one of the detectors
+// reports a change, and a function to stop watching.  The first known value of each detector after
+// the call is taken as its starting point and is not reported.
+// N.B. Changes are not queued indefinitely, if the channel is not consumed they are lost.
+func (tello *Tello) WatchFlightData(detectors ...ChangeDetector) (<-chan FlightDataChange, func()) {
+	wl := &tello.watches
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	if wl.watchers == nil {
+		wl.watchers = map[chan FlightDataChange]*watcher{}
+	}
+	res := make(chan FlightDataChange, eventChanSize)
+	w := &watcher{detectors: detectors, refs: make([]float64, len(detectors))}
+	for i := range w.refs {
+		w.refs[i] = math.NaN()
+	}
+	wl.watchers[res] = w
+	return res, func() {
+		wl.mu.Lock()
+		defer wl.mu.Unlock()
+		if _, present := wl.watchers[res]; present {
+			delete(wl.watchers, res)
+			close(res)
+		}
+	}
+}
+
+// checkWatchers runs every watcher's detectors against the latest FlightData.
+func (tello *Tello) checkWatchers() {
+	wl := &tello.watches
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	if len(wl.watchers) == 0 {
+		return
+	}
+	fd := tello.GetFlightData()
+	for ch, w := range wl.watchers {
+		for i, d := range w.detectors {
+			v := d.value(fd)
+			if math.IsNaN(v) {
+				continue
+			}
+			if math.IsNaN(w.refs[i]) {
+				w.refs[i] = v
+				continue
+			}
+			if !d.report(w.refs[i], v) {
+				continue
+			}
+			select {
+			case ch <- FlightDataChange{Name: d.name, Previous: w.refs[i], Value: v, Data: fd}:
+			default:
+			}
+			w.refs[i] = v
+		}
+	}
+}
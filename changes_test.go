@@ -0,0 +1,104 @@
+// tello project changes_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchFlightData(t *testing.T) {
+	tello := new(Tello)
+	changes, stop := tello.WatchFlightData(
+		OnChange("battery", func(fd FlightData) float64 { return float64(fd.BatteryPercentage) }, 1),
+		OnChange("height", func(fd FlightData) float64 { return float64(fd.Height) }, 2),
+		OnCrossing("wifi", func(fd FlightData) float64 { return float64(fd.WifiStrength) }, 50, 0),
+	)
+
+	set := func(batt int8, height int16, wifi uint8) {
+		tello.fd.BatteryPercentage, tello.fd.Height, tello.fd.WifiStrength = batt, height, wifi
+		tello.checkWatchers()
+	}
+	set(90, 0, 90) // starting point, not reported
+	set(90, 1, 80)
+	set(90, 2, 60)
+	set(89, 3, 40)
+	set(89, 1, 30) // only 1dm from the last reported height
+	stop()
+
+	var got []FlightDataChange
+	for c := range changes {
+		got = append(got, c)
+	}
+	want := []FlightDataChange{
+		{Name: "height", Previous: 0, Value: 2},
+		{Name: "battery", Previous: 90, Value: 89},
+		{Name: "wifi", Previous: 90, Value: 40},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].Previous != want[i].Previous || got[i].Value != want[i].Value {
+			t.Errorf("Change %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestOnCrossingHysteresis(t *testing.T) {
+	tello := new(Tello)
+	changes, stop := tello.WatchFlightData(
+		OnCrossing("wifi", WifiStrengthValue, 40, 10),
+		OnCrossing("light", LightStrengthValue, 1, 0),
+	)
+	set := func(wifi, light uint8) {
+		tello.fd.WifiStrength, tello.fd.LightStrength = wifi, light
+		tello.fd.LightStrengthUpdated = time.Now()
+		tello.checkWatchers()
+	}
+	tello.checkWatchers() // nothing received yet
+	set(90, 0)            // starting point
+	set(39, 0)            // crossed
+	set(35, 0)
+	set(45, 0) // not yet recovered
+	set(38, 0)
+	set(50, 1) // recovered, and light crossed
+	stop()
+
+	var got []FlightDataChange
+	for c := range changes {
+		got = append(got, c)
+	}
+	want := []FlightDataChange{
+		{Name: "wifi", Previous: 90, Value: 39},
+		{Name: "wifi", Previous: 39, Value: 50},
+		{Name: "light", Previous: 0, Value: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].Previous != want[i].Previous || got[i].Value != want[i].Value {
+			t.Errorf("Change %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// close.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"sort"
+)
+
+// WithLandOnClose sets whether Close() lands a Tello which is airborne, the default is to land.
+func WithLandOnClose(land bool) Option {
+	return func(tello *Tello) { tello.cfg.closeNoLand = !land }
+}
+
+// Close shuts everything down in one call, suitable for defer in user programs and signal handlers.
+// If the Tello is airborne, any automatic flight is cancelled and it is asked to land, unless
+// WithLandOnClose(false) was set, and Close waits for the landing to be acknowledged or ctx to be done.
+// The stick listener and interval shooting are then stopped, every video sink is flushed and closed,
+// and the control and video connections are closed.
+// Recordings, OSC senders, webhooks and StickPlayer playback are not owned by the Tello, so Close
+// leaves them running: callers should stop them, or cancel Play()'s context, before calling Close.
+// A webhook may instead be stopped afterwards, so that it can report the disconnection.
+// The first error encountered is returned, the shutdown continues regardless.
+// The Tello may be connected again afterwards.
+func (tello *Tello) Close(ctx context.Context) (err error) {
+	keep := func(e error) {
+		if err == nil {
+			err = e
+		}
+	}
+	if !tello.cfg.closeNoLand && tello.ControlConnected() && tello.GetFlightState().IsAirborne() {
+		tello.Hover()
+		keep(tello.LandAndWait(ctx))
+	}
+
+	tello.StopStickListener()
+	tello.StopIntervalShooting()
+
+	tello.sinksMu.Lock()
+	names := make([]string, 0, len(tello.sinks))
+	for name := range tello.sinks {
+		names = append(names, name)
+	}
+	tello.sinksMu.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		keep(tello.RemoveVideoSink(name))
+	}
+
+	tello.ControlDisconnect()
+	return err
+}
@@ -0,0 +1,95 @@
+// close_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello/sim"
+)
+
+func TestCloseLands(t *testing.T) {
+	drone, s := simDrone(t)
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := drone.AddVideoSink("file", NewWriterSink(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	if err := drone.TakeOffAndWait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !drone.GetFlightState().IsAirborne() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !drone.GetFlightState().IsAirborne() {
+		t.Fatal("Never took off")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := drone.Close(ctx); err != nil {
+		t.Errorf("Close failed with %v", err)
+	}
+	if phase := s.State().Phase; phase != sim.Landing && phase != sim.Grounded {
+		t.Errorf("Expected the drone to be landing, phase is %v", phase)
+	}
+	if drone.ControlConnected() {
+		t.Error("Expected Close to disconnect")
+	}
+	if sinks := drone.VideoSinks(); len(sinks) != 0 {
+		t.Errorf("Expected the video sinks to be closed, got %v", sinks)
+	}
+}
+
+func TestCloseWithoutLanding(t *testing.T) {
+	drone, s := simDrone(t, WithLandOnClose(false))
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	if err := drone.TakeOffAndWait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !drone.GetFlightState().IsAirborne() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !drone.GetFlightState().IsAirborne() {
+		t.Fatal("Never took off")
+	}
+	if err := drone.Close(context.Background()); err != nil {
+		t.Errorf("Close failed with %v", err)
+	}
+	if s.State().Phase == sim.Landing {
+		t.Error("Expected the drone to be left flying")
+	}
+
+	// and Close is harmless when not connected
+	if err := NewTello().Close(context.Background()); err != nil {
+		t.Errorf("Close when not connected failed with %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+// main.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Command tello-corpus records a session with a Tello, or with the simulator, as a sanitized
+// corpus of control packets for the tello package's replay tests.
+//
+// The drone is connected to, asked for its settings and, with -fly, taken off, hovered briefly
+// and landed; all the control traffic is recorded with the WiFi name, password and other
+// identifying data removed.  Add the result to testdata/corpus and run the tests with
+// -update-corpus to record the expected parse results, which later test runs check.
+//
+// Usage:
+//
+//	tello-corpus [-sim] [-fly] [-duration 20s] [-o session.corpus]
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/SMerrony/tello"
+	"github.com/SMerrony/tello/sim"
+)
+
+const hover = 5 * time.Second // with -fly, how long to hover before landing
+
+func main() {
+	out := flag.String("o", "", "file to write the corpus to, the default is standard output")
+	duration := flag.Duration("duration", 20*time.Second, "how long to record for")
+	useSim := flag.Bool("sim", false, "record a session with the built-in simulator rather than a drone")
+	fly := flag.Bool("fly", false, "take off, hover briefly and land during the recording")
+	flag.Parse()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	ct := tello.NewCaptureTransport(nil, w)
+	opts := []tello.Option{tello.WithTransport(ct)}
+	if *useSim {
+		s := sim.New()
+		if err := s.Listen("127.0.0.1:0"); err != nil {
+			log.Fatalf("Could not start the simulator - %v", err)
+		}
+		defer s.Close()
+		opts = append(opts, tello.WithAddress("127.0.0.1", s.Addr().Port), tello.WithLocalControlPort(freePort()))
+	}
+	drone := tello.NewTello(opts...)
+	if err := drone.ControlConnectDefault(); err != nil {
+		log.Fatalf("Could not connect - %v", err)
+	}
+	start := time.Now()
+
+	drone.GetVersion()
+	drone.GetSSID()
+	drone.GetWifiRegion()
+	drone.GetActivationTime()
+	drone.GetMaxHeight()
+	drone.GetLowBatteryThreshold()
+	drone.GetVideoBitrate()
+
+	if *fly {
+		drone.TakeOff()
+		time.Sleep(hover)
+		drone.Land()
+	}
+	if left := *duration - time.Since(start); left > 0 {
+		time.Sleep(left)
+	}
+	drone.ControlDisconnect()
+	if err := ct.Err(); err != nil {
+		log.Fatalf("Could not write the corpus - %v", err)
+	}
+}
+
+// freePort finds an unused local UDP port for the client when talking to the simulator.
+func freePort() int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		log.Fatalf("Could not find a free port - %v", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
@@ -0,0 +1,364 @@
+// input.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/SMerrony/tello/protocol"
+)
+
+// a datagram is a single UDP payload, or a run of bytes taken from a hex dump
+type datagram struct {
+	time     time.Time    // zero if not known
+	src, dst *net.UDPAddr // nil if not known
+	label    string       // describes where the datagram came from if src does not
+	data     []byte
+}
+
+// corpora recorded by tello-corpus start with this line
+const corpusMagic = "# tello corpus"
+
+var (
+	connReq = []byte("conn_req:")
+	connAck = []byte("conn_ack:")
+)
+
+// readHex reads text containing hex dumps, such as the library's logs or the output of
+// hexdump -C or xxd, and calls fn with each paragraph of hex bytes.
+func readHex(r io.Reader, fn func(datagram)) error {
+	var run []byte
+	flush := func() {
+		if len(run) > 0 {
+			fn(datagram{data: run})
+			run = nil
+		}
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		b, ok := hexLine(sc.Text())
+		if !ok {
+			flush()
+			continue
+		}
+		run = append(run, b...)
+	}
+	flush()
+	return sc.Err()
+}
+
+// hexLine extracts the hex bytes from one line of text, ok is false if it has none.
+func hexLine(line string) (b []byte, ok bool) {
+	if i := strings.IndexByte(line, '|'); i >= 0 { // hexdump -C's ASCII column
+		line = line[:i]
+	}
+	tokens := strings.Fields(line)
+	if len(tokens) == 0 {
+		return nil, false
+	}
+	if t := tokens[0]; strings.HasSuffix(t, ":") && isHex(strings.TrimSuffix(t, ":")) { // xxd or similar offset
+		tokens = tokens[1:]
+	} else if len(tokens) > 1 && len(t) > 2 && isHex(t) { // hexdump offset
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 1 && len(tokens[0]) >= 2*protocol.MinPacketSize && len(tokens[0])%2 == 0 {
+		b, err := hex.DecodeString(tokens[0]) // one contiguous string of hex
+		return b, err == nil
+	}
+	// otherwise take the longest run of byte-sized hex tokens, but ignore stray numbers in text
+	var best, cur []byte
+	all := true
+	for _, t := range tokens {
+		if len(t) == 2 && isHex(t) {
+			v, _ := hex.DecodeString(t)
+			cur = append(cur, v[0])
+			if len(cur) > len(best) {
+				best = cur
+			}
+			continue
+		}
+		if len(t) == 4 && isHex(t) { // xxd groups of two bytes
+			v, _ := hex.DecodeString(t)
+			cur = append(cur, v...)
+			if len(cur) > len(best) {
+				best = cur
+			}
+			continue
+		}
+		all = false
+		cur = nil
+	}
+	if len(best) == 0 || (!all && len(best) < 2) {
+		return nil, false
+	}
+	return best, true
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// a chunk is part of a datagram, either a packet or text connection message, or bytes which
+// could not be decoded
+type chunk struct {
+	data   []byte
+	pkt    *protocol.Packet
+	text   string
+	badErr error
+}
+
+// split finds the packets within data, resynchronising on the next header after any junk.
+// A datagram which is not a valid packet is returned whole with the reason.
+func split(data []byte, whole bool) (chunks []chunk) {
+	if whole {
+		if bytes.HasPrefix(data, connReq) || bytes.HasPrefix(data, connAck) {
+			return []chunk{{data: data, text: connText(data)}}
+		}
+		pkt, err := protocol.Parse(data)
+		if err != nil {
+			return []chunk{{data: data, badErr: err}}
+		}
+		if size := protocol.Size(data); size < len(data) {
+			return append([]chunk{{data: data[:size], pkt: &pkt}}, split(data[size:], false)...)
+		}
+		return []chunk{{data: data, pkt: &pkt}}
+	}
+	junk := 0
+	for i := 0; i < len(data); {
+		var c chunk
+		switch {
+		case data[i] == protocol.Header:
+			if size := protocol.Size(data[i:]); size >= protocol.MinPacketSize && i+size <= len(data) {
+				if pkt, err := protocol.Parse(data[i : i+size]); err == nil {
+					c = chunk{data: data[i : i+size], pkt: &pkt}
+				}
+			}
+		case bytes.HasPrefix(data[i:], connReq) || bytes.HasPrefix(data[i:], connAck):
+			n := len(connReq) + 2
+			if i+n > len(data) {
+				n = len(data) - i
+			}
+			c = chunk{data: data[i : i+n], text: connText(data[i : i+n])}
+		}
+		if c.data == nil {
+			i++
+			continue
+		}
+		if junk < i {
+			chunks = append(chunks, chunk{data: data[junk:i], badErr: junkErr(data[junk:i])})
+		}
+		chunks = append(chunks, c)
+		i += len(c.data)
+		junk = i
+	}
+	if junk < len(data) {
+		chunks = append(chunks, chunk{data: data[junk:], badErr: junkErr(data[junk:])})
+	}
+	return chunks
+}
+
+// junkErr explains why bytes were skipped, giving the reason if they look like a damaged packet.
+func junkErr(junk []byte) error {
+	if junk[0] == protocol.Header {
+		if _, err := protocol.Parse(junk); err != nil {
+			return err
+		}
+	}
+	return errors.New("not a packet")
+}
+
+// connText describes a connection request or acknowledgement, which carry the client's video port.
+func connText(data []byte) string {
+	name := string(data[:len(connReq)-1])
+	if len(data) >= len(connReq)+2 {
+		return fmt.Sprintf("%s video port %d", name, binary.LittleEndian.Uint16(data[len(connReq):]))
+	}
+	return name
+}
+
+// pcap file magic numbers, as read little-endian
+const (
+	pcapMicros     = 0xa1b2c3d4
+	pcapMicrosSwap = 0xd4c3b2a1
+	pcapNanos      = 0xa1b23c4d
+	pcapNanosSwap  = 0x4d3cb2a1
+	pcapNG         = 0x0a0d0d0a
+)
+
+// pcap link types
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLinuxSLL = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+	linkSLL2     = 276
+)
+
+func isPcap(magic []byte) bool {
+	if len(magic) < 4 {
+		return false
+	}
+	switch binary.LittleEndian.Uint32(magic) {
+	case pcapMicros, pcapMicrosSwap, pcapNanos, pcapNanosSwap, pcapNG:
+		return true
+	}
+	return false
+}
+
+// readPcap reads a classic libpcap capture and calls fn with each UDP datagram.
+func readPcap(r io.Reader, fn func(datagram)) error {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return fmt.Errorf("reading pcap header: %w", err)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	nanos := false
+	switch binary.LittleEndian.Uint32(hdr[0:]) {
+	case pcapMicros:
+	case pcapNanos:
+		nanos = true
+	case pcapMicrosSwap:
+		order = binary.BigEndian
+	case pcapNanosSwap:
+		order, nanos = binary.BigEndian, true
+	case pcapNG:
+		return errors.New("pcapng is not supported, convert it with: editcap -F pcap in.pcapng out.pcap")
+	default:
+		return errors.New("not a pcap file")
+	}
+	link := order.Uint32(hdr[20:]) & 0xffff
+	var rec [16]byte
+	for {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("reading pcap record: %w", err)
+		}
+		sec, frac, n := order.Uint32(rec[0:]), order.Uint32(rec[4:]), order.Uint32(rec[8:])
+		if n > 256*1024 {
+			return fmt.Errorf("pcap record of %d bytes is too large", n)
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return fmt.Errorf("reading pcap record: %w", err)
+		}
+		if !nanos {
+			frac *= 1000
+		}
+		src, dst, payload, ok := udpPayload(link, frame)
+		if ok {
+			fn(datagram{time: time.Unix(int64(sec), int64(frac)), src: src, dst: dst, data: payload})
+		}
+	}
+}
+
+// udpPayload extracts the UDP payload from a captured frame, ok is false if it is not UDP over IP.
+func udpPayload(link uint32, frame []byte) (src, dst *net.UDPAddr, payload []byte, ok bool) {
+	var ip []byte
+	switch link {
+	case linkNull:
+		if len(frame) < 4 {
+			return
+		}
+		ip = frame[4:]
+	case linkEthernet:
+		if len(frame) < 14 {
+			return
+		}
+		etherType, off := binary.BigEndian.Uint16(frame[12:]), 14
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(frame) >= off+4 { // VLAN tags
+			etherType, off = binary.BigEndian.Uint16(frame[off+2:]), off+4
+		}
+		if etherType != 0x0800 && etherType != 0x86dd {
+			return
+		}
+		ip = frame[off:]
+	case linkRaw, linkIPv4, linkIPv6:
+		ip = frame
+	case linkLinuxSLL:
+		if len(frame) < 16 {
+			return
+		}
+		ip = frame[16:]
+	case linkSLL2:
+		if len(frame) < 20 {
+			return
+		}
+		ip = frame[20:]
+	default:
+		return
+	}
+	if len(ip) < 1 {
+		return
+	}
+	var udp []byte
+	var srcIP, dstIP net.IP
+	switch ip[0] >> 4 {
+	case 4:
+		ihl := int(ip[0]&0x0f) * 4
+		if len(ip) < 20 || ihl < 20 || len(ip) < ihl || ip[9] != 17 {
+			return
+		}
+		if binary.BigEndian.Uint16(ip[6:])&0x1fff != 0 { // not the first fragment
+			return
+		}
+		srcIP, dstIP, udp = net.IP(ip[12:16]), net.IP(ip[16:20]), ip[ihl:]
+	case 6:
+		if len(ip) < 40 || ip[6] != 17 {
+			return
+		}
+		srcIP, dstIP, udp = net.IP(ip[8:24]), net.IP(ip[24:40]), ip[40:]
+	default:
+		return
+	}
+	if len(udp) < 8 {
+		return
+	}
+	end := int(binary.BigEndian.Uint16(udp[4:]))
+	if end < 8 || end > len(udp) {
+		end = len(udp) // truncated by the snap length
+	}
+	src = &net.UDPAddr{IP: srcIP, Port: int(binary.BigEndian.Uint16(udp[0:]))}
+	dst = &net.UDPAddr{IP: dstIP, Port: int(binary.BigEndian.Uint16(udp[2:]))}
+	return src, dst, udp[8:end], true
+}
@@ -0,0 +1,137 @@
+// input_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SMerrony/tello/protocol"
+)
+
+func TestHexLine(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want []byte
+	}{
+		{"cc 58 00 7c", []byte{0xcc, 0x58, 0, 0x7c}},
+		{"00000010  72 65 71 3a  |req:|", []byte{0x72, 0x65, 0x71, 0x3a}},
+		{"00000000: cc58 007c 6854  .X.|hT", []byte{0xcc, 0x58, 0, 0x7c, 0x68, 0x54}},
+		{"cc58007c6854000000b289", []byte{0xcc, 0x58, 0, 0x7c, 0x68, 0x54, 0, 0, 0, 0xb2, 0x89}},
+		{"Bad packet from Tello, bad CRC8: cc 58", []byte{0xcc, 0x58}},
+		{"Unknown message from Tello - ID: <84>, Size 11, Type: 5", nil},
+		{"", nil},
+	} {
+		got, ok := hexLine(tc.line)
+		if ok != (tc.want != nil) || !bytes.Equal(got, tc.want) {
+			t.Errorf("%q: got % x, %v", tc.line, got, ok)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	takeoff := protocol.Encode(protocol.Packet{Type: protocol.TypeSet, ToDrone: true, MessageID: 0x0054})
+	data := append([]byte{1, 2}, takeoff...)
+	data = append(data, "conn_ack:\x96\x17"...)
+	data = append(data, takeoff...)
+	chunks := split(data, false)
+	if len(chunks) != 4 || chunks[0].badErr == nil || chunks[1].pkt == nil ||
+		chunks[2].text != "conn_ack video port 6038" || chunks[3].pkt == nil {
+		t.Fatalf("Got %+v", chunks)
+	}
+}
+
+// pcapOf builds a little-endian microsecond pcap containing Ethernet/IPv4/UDP frames
+func pcapOf(payloads ...[]byte) []byte {
+	var b bytes.Buffer
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], pcapMicros)
+	binary.LittleEndian.PutUint32(hdr[20:], linkEthernet)
+	b.Write(hdr)
+	for i, pl := range payloads {
+		frame := make([]byte, 14+20+8+len(pl))
+		binary.BigEndian.PutUint16(frame[12:], 0x0800)
+		ip := frame[14:]
+		ip[0], ip[9] = 0x45, 17
+		copy(ip[12:], []byte{192, 168, 10, 2})
+		copy(ip[16:], []byte{192, 168, 10, 1})
+		udp := ip[20:]
+		binary.BigEndian.PutUint16(udp[0:], 8800)
+		binary.BigEndian.PutUint16(udp[2:], 8889)
+		binary.BigEndian.PutUint16(udp[4:], uint16(8+len(pl)))
+		copy(udp[8:], pl)
+		rec := make([]byte, 16)
+		binary.LittleEndian.PutUint32(rec[0:], uint32(1700000000+i))
+		binary.LittleEndian.PutUint32(rec[4:], 250000)
+		binary.LittleEndian.PutUint32(rec[8:], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(rec[12:], uint32(len(frame)))
+		b.Write(rec)
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+func TestDecodePcap(t *testing.T) {
+	status := protocol.Encode(protocol.Packet{Type: protocol.TypeData1, FromDrone: true, MessageID: 0x0056, Sequence: 7,
+		Payload: append([]byte{5, 0}, make([]byte, 22)...)})
+	capture := pcapOf([]byte("conn_req:\x96\x17"), status, []byte{0xcc, 1, 2})
+	var out bytes.Buffer
+	if err := decode(&out, bytes.NewReader(capture), "auto", options{port: 8889}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Got %d lines:\n%s", len(lines), out.String())
+	}
+	if !strings.HasSuffix(lines[0], "192.168.10.2:8800 > 192.168.10.1:8889 conn_req video port 6038") {
+		t.Errorf("Got %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "<- FlightStatus(0x0056) Data1 seq=7 len=24") ||
+		!strings.Contains(lines[2], "Height=5 ") {
+		t.Errorf("Got %q, %q", lines[1], lines[2])
+	}
+	if !strings.Contains(lines[3], "too short") {
+		t.Errorf("Got %q", lines[3])
+	}
+
+	out.Reset()
+	ids, _ := parseIDs("flightstatus")
+	decode(&out, bytes.NewReader(capture), "pcap", options{ids: ids})
+	if n := strings.Count(out.String(), "\n"); n != 2 {
+		t.Errorf("Filtered output has %d lines:\n%s", n, out.String())
+	}
+}
+
+func TestDecodeCorpus(t *testing.T) {
+	status := protocol.Encode(protocol.Packet{Type: protocol.TypeData1, FromDrone: true, MessageID: 0x001a, Payload: []byte{90, 3}})
+	corpus := "# tello corpus v1\n+0.250000 < " + fmt.Sprintf("%x", status) + "\n"
+	var out bytes.Buffer
+	if err := decode(&out, strings.NewReader(corpus), "auto", options{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "+0.250000 <- WifiStrength(0x001a) Data1 seq=0 len=2\n    Strength=90 Interference=3\n"; out.String() != want {
+		t.Errorf("Got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,236 @@
+// main.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Command tello-decode pretty-prints Tello control packets, showing message names, direction flags,
+// sequence numbers and the decoded fields of the payloads whose layouts are known, to speed up
+// protocol research.
+//
+// It reads pcap captures (eg. from tcpdump -w or Wireshark saved as pcap), corpora recorded with
+// tello-corpus, and text containing hex dumps such as the library's log of unknown messages or the
+// output of hexdump -C or xxd.  Packets
+// are found within the hex by their header and CRCs, so surrounding text is ignored.
+//
+// Usage:
+//
+//	tello-decode [-format auto|hex|pcap|corpus] [-port 8889] [-id FlightStatus,0x1050] [-raw] [file...]
+//
+// With no files, standard input is read.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/SMerrony/tello/protocol"
+)
+
+const maxBytesShown = 32 // []byte fields longer than this are abbreviated
+
+type options struct {
+	port int             // in pcaps, only show datagrams to or from this port, 0 for all
+	ids  map[uint16]bool // only show these messages, nil for all
+	raw  bool            // show the raw bytes of each packet
+}
+
+func main() {
+	format := flag.String("format", "auto", "input format: auto, hex, pcap or corpus")
+	port := flag.Int("port", 8889, "in pcap files, only decode datagrams to or from this UDP port, 0 for all")
+	ids := flag.String("id", "", "comma-separated message names or IDs to show, eg. FlightStatus,0x1050")
+	raw := flag.Bool("raw", false, "also show the raw bytes of each packet")
+	flag.Parse()
+
+	opts := options{port: *port, raw: *raw}
+	if *ids != "" {
+		var err error
+		if opts.ids, err = parseIDs(*ids); err != nil {
+			log.Fatal(err)
+		}
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	if flag.NArg() == 0 {
+		if err := decode(out, os.Stdin, *format, opts); err != nil {
+			out.Flush()
+			log.Fatal(err)
+		}
+		return
+	}
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			out.Flush()
+			log.Fatal(err)
+		}
+		err = decode(out, f, *format, opts)
+		f.Close()
+		if err != nil {
+			out.Flush()
+			log.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+// parseIDs parses a list of message names or numeric IDs.
+func parseIDs(list string) (map[uint16]bool, error) {
+	names := make(map[string]uint16)
+	for _, m := range protocol.Messages() {
+		names[strings.ToLower(m.Name)] = m.ID
+	}
+	ids := make(map[uint16]bool)
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if id, ok := names[strings.ToLower(s)]; ok {
+			ids[id] = true
+			continue
+		}
+		id, err := strconv.ParseUint(s, 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("unknown message %q", s)
+		}
+		ids[uint16(id)] = true
+	}
+	return ids, nil
+}
+
+func decode(w io.Writer, r io.Reader, format string, opts options) error {
+	br := bufio.NewReader(r)
+	if format == "auto" {
+		format = "hex"
+		if magic, _ := br.Peek(len(corpusMagic)); isPcap(magic) {
+			format = "pcap"
+		} else if string(magic) == corpusMagic {
+			format = "corpus"
+		}
+	}
+	switch format {
+	case "hex":
+		return readHex(br, func(dg datagram) { show(w, dg, split(dg.data, false), opts) })
+	case "corpus":
+		pkts, err := protocol.ReadCorpus(br)
+		for _, p := range pkts {
+			dg := datagram{label: fmt.Sprintf("+%.6f", p.Offset.Seconds()), data: p.Data}
+			show(w, dg, split(dg.data, true), opts)
+		}
+		return err
+	case "pcap":
+		return readPcap(br, func(dg datagram) {
+			if opts.port == 0 || dg.src.Port == opts.port || dg.dst.Port == opts.port {
+				show(w, dg, split(dg.data, true), opts)
+			}
+		})
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// show prints the chunks of a datagram.
+func show(w io.Writer, dg datagram, chunks []chunk, opts options) {
+	var prefix string
+	if !dg.time.IsZero() {
+		prefix = dg.time.Format("15:04:05.000000 ")
+	}
+	if dg.src != nil {
+		prefix += fmt.Sprintf("%v > %v ", dg.src, dg.dst)
+	}
+	if dg.label != "" {
+		prefix += dg.label + " "
+	}
+	for _, c := range chunks {
+		switch {
+		case c.pkt != nil:
+			if opts.ids != nil && !opts.ids[c.pkt.MessageID] {
+				continue
+			}
+			fmt.Fprintf(w, "%s%s\n", prefix, describe(*c.pkt))
+			if opts.raw {
+				fmt.Fprintf(w, "    raw: % x\n", c.data)
+			}
+			payload, err := protocol.Decode(*c.pkt)
+			if payload != nil {
+				fmt.Fprintf(w, "    %s\n", formatFields(payload))
+			}
+			if err != nil {
+				fmt.Fprintf(w, "    ! %v\n", err)
+			}
+			if payload == nil && len(c.pkt.Payload) > 0 {
+				fmt.Fprintf(w, "    payload: % x\n", c.pkt.Payload)
+			}
+		case c.text != "":
+			if opts.ids == nil {
+				fmt.Fprintf(w, "%s%s\n", prefix, c.text)
+			}
+		default:
+			if opts.ids == nil {
+				fmt.Fprintf(w, "%s! %v\n", prefix, c.badErr)
+			}
+		}
+	}
+}
+
+// describe summarises a packet's header.
+func describe(pkt protocol.Packet) string {
+	dir := "--"
+	switch {
+	case pkt.ToDrone && pkt.FromDrone:
+		dir = "<>"
+	case pkt.ToDrone:
+		dir = "->"
+	case pkt.FromDrone:
+		dir = "<-"
+	}
+	s := fmt.Sprintf("%s %s(0x%04x) %s", dir, protocol.Name(pkt.MessageID), pkt.MessageID, protocol.TypeName(pkt.Type))
+	if pkt.Subtype != 0 {
+		s += fmt.Sprintf("/%d", pkt.Subtype)
+	}
+	return s + fmt.Sprintf(" seq=%d len=%d", pkt.Sequence, len(pkt.Payload))
+}
+
+// formatFields formats a decoded payload as name=value pairs.
+func formatFields(payload interface{}) string {
+	v := reflect.ValueOf(payload)
+	t := v.Type()
+	parts := make([]string, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		var s string
+		switch val := f.Interface().(type) {
+		case []byte:
+			if len(val) > maxBytesShown {
+				s = fmt.Sprintf("[% x ...] (%d bytes)", val[:maxBytesShown], len(val))
+			} else {
+				s = fmt.Sprintf("[% x]", val)
+			}
+		case string:
+			s = strconv.Quote(val)
+		default:
+			s = fmt.Sprint(val)
+		}
+		parts = append(parts, t.Field(i).Name+"="+s)
+	}
+	return strings.Join(parts, " ")
+}
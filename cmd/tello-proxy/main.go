@@ -0,0 +1,57 @@
+// main.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Command tello-proxy connects to a Tello once and re-exposes its control and video ports locally,
+// so that several programs - eg. a telemetry dashboard and a flight program - can share one drone.
+//
+// Point each client at the proxy instead of the drone, eg. with tello.WithAddress(proxyHost, 8889).
+// Every client receives everything the drone sends on the control channel, and the video stream is
+// copied to whichever video port each client asked for in its connection request.  Clients on the
+// same machine as the proxy must each use their own local control and video ports.
+//
+// The proxy does not keep the drone alive by itself, so if every client goes away the drone's own
+// failsafe will take over as usual.
+//
+// Usage:
+//
+//	tello-proxy [-drone 192.168.10.1:8889] [-listen :8889] [-video 6040] [-expiry 5s]
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+)
+
+func main() {
+	droneAddr := flag.String("drone", "192.168.10.1:8889", "address of the drone's control port")
+	listenAddr := flag.String("listen", ":8889", "local address for client control connections")
+	videoPort := flag.Int("video", 6040, "local port on which the proxy receives video from the drone")
+	expiry := flag.Duration("expiry", 5*time.Second, "forget clients which have sent nothing for this long")
+	flag.Parse()
+
+	p, err := newProxy(*droneAddr, *listenAddr, *videoPort, *expiry)
+	if err != nil {
+		log.Fatalf("Could not start proxy - %v", err)
+	}
+	log.Printf("Proxying %s for clients on %s\n", *droneAddr, p.clientConn.LocalAddr())
+	p.run()
+}
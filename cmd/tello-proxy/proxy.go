@@ -0,0 +1,196 @@
+// proxy.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	maxPacket  = 4096
+	droneStale = 3 * time.Second // after this long without hearing from the drone we reconnect to it
+)
+
+var (
+	connReq = []byte("conn_req:")
+	connAck = []byte("conn_ack:")
+)
+
+type client struct {
+	addr      *net.UDPAddr // where the client sends control packets from
+	videoAddr *net.UDPAddr // where the client wants video, nil until it has sent a connection request
+	awaitAck  bool         // has the client sent a connection request we have not yet answered?
+	lastSeen  time.Time
+}
+
+type proxy struct {
+	droneConn  *net.UDPConn // connected to the drone's control port
+	clientConn *net.UDPConn // clients send control packets here
+	videoConn  *net.UDPConn // the drone sends video here
+	videoPort  int
+	expiry     time.Duration
+
+	mu        sync.Mutex // mu protects the following
+	clients   map[string]*client
+	lastDrone time.Time // when we last heard from the drone
+}
+
+func newProxy(droneAddr, listenAddr string, videoPort int, expiry time.Duration) (p *proxy, err error) {
+	p = &proxy{videoPort: videoPort, expiry: expiry, clients: map[string]*client{}}
+	raddr, err := net.ResolveUDPAddr("udp", droneAddr)
+	if err != nil {
+		return nil, err
+	}
+	if p.droneConn, err = net.DialUDP("udp", nil, raddr); err != nil {
+		return nil, err
+	}
+	laddr, err := net.ResolveUDPAddr("udp", listenAddr)
+	if err != nil {
+		p.close()
+		return nil, err
+	}
+	if p.clientConn, err = net.ListenUDP("udp", laddr); err != nil {
+		p.close()
+		return nil, err
+	}
+	if p.videoConn, err = net.ListenUDP("udp", &net.UDPAddr{Port: videoPort}); err != nil {
+		p.close()
+		return nil, err
+	}
+	p.videoPort = p.videoConn.LocalAddr().(*net.UDPAddr).Port // in case an ephemeral port was requested
+	return p, nil
+}
+
+// run relays packets until the proxy is closed.
+func (p *proxy) run() {
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() { defer wg.Done(); p.fromClients() }()
+	go func() { defer wg.Done(); p.fromDrone() }()
+	go func() { defer wg.Done(); p.video() }()
+	wg.Wait()
+}
+
+func (p *proxy) close() {
+	for _, c := range []*net.UDPConn{p.droneConn, p.clientConn, p.videoConn} {
+		if c != nil {
+			c.Close()
+		}
+	}
+}
+
+// fromClients forwards client packets to the drone, handling connection requests ourselves.
+func (p *proxy) fromClients() {
+	buff := make([]byte, maxPacket)
+	for {
+		n, addr, err := p.clientConn.ReadFromUDP(buff)
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		c, known := p.clients[addr.String()]
+		if !known {
+			log.Printf("New client %v\n", addr)
+			c = &client{addr: addr}
+			p.clients[addr.String()] = c
+		}
+		c.lastSeen = time.Now()
+		if !bytes.HasPrefix(buff[:n], connReq) || n < 11 {
+			p.mu.Unlock()
+			p.droneConn.Write(buff[:n])
+			continue
+		}
+		c.videoAddr = &net.UDPAddr{IP: addr.IP, Port: int(buff[9]) | int(buff[10])<<8}
+		droneUp := time.Since(p.lastDrone) < droneStale
+		if droneUp {
+			p.clientConn.WriteToUDP(connAckFor(c), c.addr)
+		} else {
+			c.awaitAck = true
+		}
+		p.mu.Unlock()
+		if !droneUp {
+			req := []byte("conn_req:lh")
+			req[9], req[10] = byte(p.videoPort), byte(p.videoPort>>8)
+			p.droneConn.Write(req)
+		}
+	}
+}
+
+// fromDrone copies every drone packet to every current client.
+func (p *proxy) fromDrone() {
+	buff := make([]byte, maxPacket)
+	for {
+		n, err := p.droneConn.Read(buff)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue // eg. ICMP port unreachable before the drone is up
+		}
+		isAck := bytes.HasPrefix(buff[:n], connAck)
+		p.mu.Lock()
+		p.lastDrone = time.Now()
+		for key, c := range p.clients {
+			if time.Since(c.lastSeen) > p.expiry {
+				log.Printf("Forgetting client %v\n", c.addr)
+				delete(p.clients, key)
+				continue
+			}
+			switch {
+			case isAck && c.awaitAck:
+				c.awaitAck = false
+				p.clientConn.WriteToUDP(connAckFor(c), c.addr)
+			case !isAck:
+				p.clientConn.WriteToUDP(buff[:n], c.addr)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// video copies the drone's video packets to each client's requested video port.
+func (p *proxy) video() {
+	buff := make([]byte, maxPacket)
+	for {
+		n, _, err := p.videoConn.ReadFromUDP(buff)
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		for _, c := range p.clients {
+			if c.videoAddr != nil {
+				p.videoConn.WriteToUDP(buff[:n], c.videoAddr)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// connAckFor returns a connection acknowledgement confirming the video port the client asked for.
+func connAckFor(c *client) []byte {
+	return append(append([]byte{}, connAck...), byte(c.videoAddr.Port), byte(c.videoAddr.Port>>8))
+}
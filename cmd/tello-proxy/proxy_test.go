@@ -0,0 +1,102 @@
+// proxy_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) *net.UDPConn {
+	t.Helper()
+	c, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func read(t *testing.T, c *net.UDPConn) []byte {
+	t.Helper()
+	buff := make([]byte, maxPacket)
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := c.ReadFromUDP(buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buff[:n]
+}
+
+func TestProxy(t *testing.T) {
+	// a fake drone that acks connections, echoes everything else, and streams video on request
+	drone := listen(t)
+	go func() {
+		buff := make([]byte, maxPacket)
+		for {
+			n, addr, err := drone.ReadFromUDP(buff)
+			if err != nil {
+				return
+			}
+			if bytes.HasPrefix(buff[:n], connReq) {
+				drone.WriteToUDP([]byte("conn_ack:\x00\x00"), addr)
+				video := &net.UDPAddr{IP: addr.IP, Port: int(buff[9]) | int(buff[10])<<8}
+				drone.WriteToUDP([]byte("\x00\x80frame"), video)
+				continue
+			}
+			drone.WriteToUDP(buff[:n], addr)
+		}
+	}()
+
+	p, err := newProxy(drone.LocalAddr().String(), "127.0.0.1:0", 0, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.close()
+	go p.run()
+	proxyAddr := p.clientConn.LocalAddr().(*net.UDPAddr)
+
+	a, b := listen(t), listen(t)
+	aVideo, bVideo := listen(t), listen(t)
+	for _, cl := range []struct{ ctrl, video *net.UDPConn }{{a, aVideo}, {b, bVideo}} {
+		port := cl.video.LocalAddr().(*net.UDPAddr).Port
+		cl.ctrl.WriteToUDP([]byte{'c', 'o', 'n', 'n', '_', 'r', 'e', 'q', ':', byte(port), byte(port >> 8)}, proxyAddr)
+		ack := read(t, cl.ctrl)
+		if !bytes.HasPrefix(ack, connAck) || int(ack[9])|int(ack[10])<<8 != port {
+			t.Errorf("Expected conn_ack for port %d, got % x", port, ack)
+		}
+	}
+
+	// the first client's connection prompted the drone to send a video packet
+	if v := read(t, aVideo); !bytes.Equal(v, []byte("\x00\x80frame")) {
+		t.Errorf("Unexpected video % x", v)
+	}
+
+	a.WriteToUDP([]byte("hello"), proxyAddr)
+	for _, c := range []*net.UDPConn{a, b} {
+		if got := read(t, c); string(got) != "hello" {
+			t.Errorf("Expected the drone's reply to be shared, got %q", got)
+		}
+	}
+}
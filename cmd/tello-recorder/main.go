@@ -0,0 +1,108 @@
+// main.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Command tello-recorder is a set-and-forget data collection rig: it keeps a Tello connected and
+// continuously records its video, as segment files, and its telemetry, as JSON Lines.  A new
+// recording and telemetry file are started every -rotate period, and it reconnects whenever contact
+// with the drone is lost.  The drone is flown by other means, eg. via tello-proxy; the recorder only
+// asks it to land once its battery falls to -land-at percent.  The files are named after the drone's
+// -serial number, if given, so that those from several drones can be told apart.
+//
+// Its health is served as JSON at /health on the -health address, with the status 503 if the drone
+// is not connected or its telemetry has stopped, for use by monitoring systems.
+//
+// Usage:
+//
+//	tello-recorder [-drone 192.168.10.1:8889] [-serial 0TQDG7REDB0N8X] [-dir .] [-segment 5m] [-rotate 1h] [-health :8080]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/SMerrony/tello"
+	"github.com/SMerrony/tello/sim"
+)
+
+func main() {
+	droneAddr := flag.String("drone", "192.168.10.1:8889", "address of the drone's control port")
+	serial := flag.String("serial", "", "the drone's serial number, to name the files")
+	useSim := flag.Bool("sim", false, "record the built-in simulator rather than a drone")
+	dir := flag.String("dir", ".", "directory for the recordings and telemetry")
+	segment := flag.Duration("segment", 5*time.Minute, "the longest video segment")
+	rotate := flag.Duration("rotate", time.Hour, "how often to start a new recording and telemetry file")
+	period := flag.Duration("period", 200*time.Millisecond, "how often to write telemetry")
+	landAt := flag.Int("land-at", 20, "land when the battery percentage falls to this")
+	retry := flag.Duration("retry", 5*time.Second, "how long to wait before reconnecting")
+	healthAddr := flag.String("health", ":8080", "address on which to serve /health, empty for none")
+	flag.Parse()
+
+	if *rotate < time.Second || *period < time.Millisecond {
+		log.Fatal("-rotate must be at least 1s and -period at least 1ms")
+	}
+	opts := []tello.Option{tello.WithLowBatteryAction(tello.FailsafeLand), tello.WithSerialNumber(*serial)}
+	if *useSim {
+		s := sim.New(sim.WithVideo())
+		if err := s.Listen("127.0.0.1:0"); err != nil {
+			log.Fatalf("Could not start the simulator - %v", err)
+		}
+		defer s.Close()
+		*droneAddr = s.Addr().String()
+		opts = append(opts, tello.WithLocalControlPort(tello.AnyPort), tello.WithVideoPort(tello.AnyPort))
+	}
+	host, port, err := net.SplitHostPort(*droneAddr)
+	if err != nil {
+		log.Fatalf("Bad drone address - %v", err)
+	}
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		log.Fatalf("Bad drone port - %v", err)
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		log.Fatalf("Could not create %s - %v", *dir, err)
+	}
+
+	r := newRecorder(config{dir: *dir, segment: *segment, rotate: *rotate, period: *period, landAt: int8(*landAt), retry: *retry},
+		append(opts, tello.WithAddress(host, portNum))...)
+	if *healthAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/health", r)
+		srv := &http.Server{Addr: *healthAddr, Handler: mux}
+		go func() {
+			if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("Could not serve health - %v", err)
+			}
+		}()
+		defer srv.Close()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("Recording %s to %s\n", *droneAddr, *dir)
+	r.run(ctx)
+}
@@ -0,0 +1,246 @@
+// recorder.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SMerrony/tello"
+)
+
+const (
+	telemetrySuffix = ".telemetry.jsonl"
+	minStaleAfter   = 2 * time.Second // the least time without telemetry before we are unhealthy
+)
+
+// config holds the recorder's settings, see the flags in main.go.
+type config struct {
+	dir     string
+	segment time.Duration // the longest video segment
+	rotate  time.Duration // how often a new recording and telemetry file are started
+	period  time.Duration // how often telemetry is written
+	landAt  int8          // land when the battery percentage falls to this
+	retry   time.Duration // how long to wait before reconnecting
+}
+
+// status is the recorder's health, as served over HTTP.
+type status struct {
+	Connected          bool      `json:"connected"`
+	ConnectedSince     time.Time `json:"connected_since"`
+	Connections        int       `json:"connections"`
+	LastError          string    `json:"last_error,omitempty"`
+	BatteryPercentage  int8      `json:"battery_percentage"`
+	FlightState        string    `json:"flight_state"`
+	LastTelemetry      time.Time `json:"last_telemetry"`
+	TelemetryFile      string    `json:"telemetry_file"`
+	TelemetryLines     int64     `json:"telemetry_lines"`
+	VideoSegments      int       `json:"video_segments"` // completed segments
+	LowBatteryLandings int       `json:"low_battery_landings"`
+}
+
+// telemetryLine is a line of a telemetry file.
+type telemetryLine struct {
+	Time       time.Time        `json:"time"`
+	FlightData tello.FlightData `json:"flight_data"`
+}
+
+// recorder keeps a drone connected and records its video and telemetry until stopped.
+type recorder struct {
+	cfg      config
+	opts     []tello.Option // how to reach the drone
+	mu       sync.Mutex     // protects the fields below
+	status   status
+	finished int  // segments completed by earlier recordings
+	landing  bool // have we asked the drone to land?
+}
+
+// files are the recording and telemetry file currently being written.
+type files struct {
+	rec       *tello.Recording
+	telemetry *os.File
+	enc       *json.Encoder
+	opened    time.Time
+}
+
+func newRecorder(cfg config, opts ...tello.Option) *recorder {
+	return &recorder{cfg: cfg, opts: opts}
+}
+
+// run records, reconnecting whenever the connection fails or is lost, until ctx is done.
+func (r *recorder) run(ctx context.Context) {
+	for {
+		err := r.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Recording interrupted - %v, retrying in %v\n", err, r.cfg.retry)
+		r.mu.Lock()
+		r.status.Connected = false
+		r.status.LastError = err.Error()
+		r.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.cfg.retry):
+		}
+	}
+}
+
+// session connects to the drone and records until ctx is done or contact is lost.
+func (r *recorder) session(ctx context.Context) (err error) {
+	drone := tello.NewTello(r.opts...)
+	if err = drone.ControlConnectDefault(); err != nil {
+		return err
+	}
+	defer drone.ControlDisconnect()
+	if _, err = drone.VideoConnectDefault(); err != nil {
+		return err
+	}
+	drone.GetVideoSpsPps() // start the video
+	telemetry, err := drone.StreamFlightData(false, r.cfg.period/time.Millisecond)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.status.Connected = true
+	r.status.ConnectedSince = time.Now()
+	r.status.Connections++
+	r.mu.Unlock()
+	log.Println("Connected, recording")
+
+	var f *files
+	defer func() {
+		if f != nil {
+			r.closeFiles(f)
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case fd, ok := <-telemetry:
+			if !ok {
+				return errors.New("Lost contact with the drone")
+			}
+			now := time.Now()
+			if f == nil || now.Sub(f.opened) >= r.cfg.rotate {
+				if f != nil {
+					r.closeFiles(f)
+				}
+				if f, err = r.openFiles(drone, fd.SerialNumber, now); err != nil {
+					return err
+				}
+			}
+			if err = f.enc.Encode(telemetryLine{Time: now, FlightData: fd}); err != nil {
+				return err
+			}
+			r.mu.Lock()
+			r.status.BatteryPercentage = fd.BatteryPercentage
+			r.status.FlightState = fd.State.String()
+			r.status.LastTelemetry = now
+			r.status.TelemetryLines++
+			r.status.VideoSegments = r.finished + len(f.rec.Segments())
+			r.mu.Unlock()
+			r.checkBattery(drone, fd)
+		}
+	}
+}
+
+// openFiles starts a new recording and telemetry file, named as the library names its files.
+func (r *recorder) openFiles(drone *tello.Tello, serial string, now time.Time) (*files, error) {
+	prefix := "tello"
+	if serial != "" {
+		prefix += "-" + serial
+	}
+	rec, err := drone.StartRecording(tello.RecordingConfig{Dir: r.cfg.dir, Prefix: prefix, MaxDuration: r.cfg.segment})
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Join(r.cfg.dir, prefix+"-"+now.Format("20060102-150405")+telemetrySuffix)
+	tf, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		rec.Stop()
+		return nil, err
+	}
+	r.mu.Lock()
+	r.status.TelemetryFile = name
+	r.status.TelemetryLines = 0
+	r.mu.Unlock()
+	log.Printf("Recording to %s\n", name)
+	return &files{rec: rec, telemetry: tf, enc: json.NewEncoder(tf), opened: now}, nil
+}
+
+// closeFiles finishes the recording and telemetry file, logging any errors.
+func (r *recorder) closeFiles(f *files) {
+	if err := f.rec.Stop(); err != nil {
+		log.Printf("Error recording video - %v\n", err)
+	}
+	if err := f.telemetry.Close(); err != nil {
+		log.Printf("Error writing %s - %v\n", f.telemetry.Name(), err)
+	}
+	r.mu.Lock()
+	r.finished += len(f.rec.Segments())
+	r.status.VideoSegments = r.finished
+	r.mu.Unlock()
+}
+
+// checkBattery asks the drone to land, once per flight, when its battery falls to the configured level.
+func (r *recorder) checkBattery(drone *tello.Tello, fd tello.FlightData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !fd.State.IsAirborne() {
+		r.landing = false
+		return
+	}
+	if r.landing || fd.BatteryPercentage > r.cfg.landAt {
+		return
+	}
+	r.landing = true
+	r.status.LowBatteryLandings++
+	log.Printf("Battery at %d%%, landing\n", fd.BatteryPercentage)
+	drone.Land()
+}
+
+// ServeHTTP reports the status as JSON, with 503 Service Unavailable if we are not connected
+// or telemetry has stopped arriving.
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	staleAfter := 3 * r.cfg.period
+	if staleAfter < minStaleAfter {
+		staleAfter = minStaleAfter
+	}
+	r.mu.Lock()
+	st := r.status
+	r.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if !st.Connected || time.Since(st.LastTelemetry) > staleAfter {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(st)
+}
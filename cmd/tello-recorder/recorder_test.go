@@ -0,0 +1,129 @@
+// recorder_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello"
+	"github.com/SMerrony/tello/sim"
+)
+
+// simOptions starts a simulator and returns the options to reach it, and to name files after its serial number.
+func simOptions(t *testing.T, opts ...sim.Option) []tello.Option {
+	s := sim.New(append([]sim.Option{sim.WithVideo()}, opts...)...)
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return []tello.Option{tello.WithAddress("127.0.0.1", s.Addr().Port),
+		tello.WithLocalControlPort(tello.AnyPort), tello.WithVideoPort(tello.AnyPort), tello.WithSerialNumber("0TQSIM00000001")}
+}
+
+func health(r *recorder) (code int, st status) {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	json.Unmarshal(w.Body.Bytes(), &st)
+	return w.Code, st
+}
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{dir: dir, segment: time.Second, rotate: 1500 * time.Millisecond, period: 100 * time.Millisecond, landAt: 20, retry: 100 * time.Millisecond}
+	r := newRecorder(cfg, simOptions(t)...)
+	if code, _ := health(r); code != http.StatusServiceUnavailable {
+		t.Errorf("got health %d before connecting", code)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.run(ctx)
+		close(done)
+	}()
+	time.Sleep(3500 * time.Millisecond)
+	code, st := health(r)
+	cancel()
+	<-done
+	if code != http.StatusOK || !st.Connected || st.Connections != 1 || st.TelemetryLines == 0 {
+		t.Errorf("got health %d %+v", code, st)
+	}
+
+	telemetry, _ := filepath.Glob(filepath.Join(dir, "tello-0TQSIM00000001-*"+telemetrySuffix))
+	if len(telemetry) < 2 {
+		t.Fatalf("expected rotated telemetry files, got %v", telemetry)
+	}
+	f, err := os.Open(telemetry[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	for sc := bufio.NewScanner(f); sc.Scan(); lines++ {
+		var line telemetryLine
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil || line.Time.IsZero() || line.FlightData.SerialNumber == "" {
+			t.Fatalf("bad telemetry line %q - %v", sc.Text(), err)
+		}
+	}
+	if lines < 5 {
+		t.Errorf("got only %d telemetry lines", lines)
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.h264"))
+	if len(segments) == 0 {
+		t.Error("no video segments recorded")
+	}
+	if partial, _ := filepath.Glob(filepath.Join(dir, "*.part")); len(partial) > 0 {
+		t.Errorf("unfinished segments %v", partial)
+	}
+}
+
+func TestLowBatteryLanding(t *testing.T) {
+	drone := tello.NewTello(simOptions(t, sim.WithBattery(18))...)
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	defer drone.ControlDisconnect()
+	drone.TakeOff()
+	deadline := time.Now().Add(5 * time.Second)
+	for !drone.GetFlightState().IsAirborne() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	r := newRecorder(config{landAt: 20})
+	r.checkBattery(drone, drone.GetFlightData())
+	r.checkBattery(drone, drone.GetFlightData())
+	for drone.GetFlightState() != tello.StateGrounded && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if fs := drone.GetFlightState(); fs != tello.StateGrounded {
+		t.Errorf("drone is %v", fs)
+	}
+	if _, st := health(r); st.LowBatteryLandings != 1 {
+		t.Errorf("got %d landings", st.LowBatteryLandings)
+	}
+}
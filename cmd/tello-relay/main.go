@@ -0,0 +1,101 @@
+// main.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Command tello-relay carries a Tello's control and video traffic over a single TCP connection, so that
+// the drone can be piloted across networks, eg. with the relay on a Raspberry Pi within WiFi range of the
+// drone and the operator elsewhere.
+//
+// On the operator's machine, use a RelayTransport instead of talking to the drone directly:
+//
+//	rt, err := tello.DialRelay("pi.example.com:8899")
+//	drone := tello.NewTello(tello.WithTransport(rt))
+//
+// If the operator's machine cannot accept connections from the relay, run the relay with -listen and
+// dial it as above.  If the relay cannot accept connections, eg. because it is behind NAT, run it with
+// -connect pointing at the operator's machine, which should accept the TCP connection and pass it to
+// tello.NewRelayTransport().  With -connect the relay keeps redialling until it is stopped.
+//
+// With -websocket the relay accepts WebSocket connections instead, so that a browser-based ground station,
+// ie. a program using this package compiled to WASM, can reach the drone:
+//
+//	rt, err := tello.DialWebSocketRelay("ws://pi.example.com:8080/")
+//
+// Usage:
+//
+//	tello-relay [-listen :8899 | -connect host:port | -websocket :8080] [-retry 2s]
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/SMerrony/tello"
+)
+
+func main() {
+	listenAddr := flag.String("listen", ":8899", "TCP address on which to accept the operator's connection")
+	connectAddr := flag.String("connect", "", "TCP address of the operator's machine to connect to instead of listening")
+	retry := flag.Duration("retry", 2*time.Second, "with -connect, how long to wait before redialling")
+	wsAddr := flag.String("websocket", "", "HTTP address on which to accept the operator's WebSocket connection instead")
+	flag.Parse()
+
+	if *wsAddr != "" {
+		log.Printf("Relaying for WebSocket operators on %s\n", *wsAddr)
+		log.Fatal(http.ListenAndServe(*wsAddr, tello.ServeWebSocketRelay(tello.UDPTransport{})))
+	}
+
+	if *connectAddr != "" {
+		for {
+			conn, err := net.DialTimeout("tcp", *connectAddr, 5*time.Second)
+			if err != nil {
+				log.Printf("Could not connect to %s - %v\n", *connectAddr, err)
+			} else {
+				serve(conn)
+			}
+			time.Sleep(*retry)
+		}
+	}
+
+	ln, err := net.Listen("tcp", *listenAddr)
+	if err != nil {
+		log.Fatalf("Could not listen - %v", err)
+	}
+	log.Printf("Relaying for operators on %s\n", ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Fatalf("Could not accept - %v", err)
+		}
+		serve(conn) // one operator at a time, as the drone's ports can only be opened once
+	}
+}
+
+func serve(conn net.Conn) {
+	log.Printf("Relaying for %v\n", conn.RemoteAddr())
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetNoDelay(true)
+	}
+	err := tello.ServeRelay(conn, tello.UDPTransport{})
+	log.Printf("Relay for %v ended - %v\n", conn.RemoteAddr(), err)
+}
@@ -0,0 +1,164 @@
+// dashboard.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/SMerrony/tello"
+)
+
+const (
+	dashboardPeriod   = 200 * time.Millisecond
+	dashboardEvents   = 8 // the number of recent events shown
+	bitrateQueryEvery = 5 * time.Second
+	horizonWidth      = 31
+	horizonHeight     = 9
+	horizonPitchScale = 5.0 // degrees of pitch per row of the artificial horizon
+	barWidth          = 20
+	clearScreen       = "\x1b[H\x1b[2J"
+	hideCursor        = "\x1b[?25l"
+	showCursor        = "\x1b[?25h"
+)
+
+// vbrNames are the VideoBitrate values as shown on the dashboard.
+var vbrNames = map[tello.VBR]string{
+	tello.VbrAuto: "auto", tello.Vbr1M: "1 Mbps", tello.Vbr1M5: "1.5 Mbps",
+	tello.Vbr2M: "2 Mbps", tello.Vbr3M: "3 Mbps", tello.Vbr4M: "4 Mbps",
+}
+
+// runDashboard shows the drone's telemetry and recent events, redrawing the terminal until
+// interrupted or the connection is lost.
+func runDashboard(args []string) error {
+	var conn connection
+	fs := flag.NewFlagSet("dashboard", flag.ExitOnError)
+	conn.addFlags(fs)
+	fs.Parse(args)
+
+	drone, closer, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closer()
+	telemetry, err := drone.StreamFlightData(false, dashboardPeriod/time.Millisecond)
+	if err != nil {
+		return err
+	}
+	events, stop := drone.ListenEvents()
+	defer stop()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	out := bufio.NewWriter(os.Stdout)
+	fmt.Fprint(out, hideCursor)
+	defer func() {
+		fmt.Fprint(out, showCursor)
+		out.Flush()
+	}()
+	var (
+		recent    []string
+		lastQuery time.Time
+		latest    tello.FlightData
+	)
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case ev := <-events:
+			recent = append(recent, ev.Time.Format("15:04:05 ")+ev.String())
+			if len(recent) > dashboardEvents {
+				recent = recent[1:]
+			}
+		case fd, ok := <-telemetry:
+			if !ok {
+				return fmt.Errorf("lost the connection to the drone")
+			}
+			latest = fd
+			if time.Since(lastQuery) > bitrateQueryEvery {
+				drone.GetVideoBitrate()
+				lastQuery = time.Now()
+			}
+			fmt.Fprint(out, clearScreen)
+			renderDashboard(out, latest, recent)
+			out.Flush()
+		}
+	}
+}
+
+// renderDashboard draws one frame of the dashboard.
+func renderDashboard(w io.Writer, fd tello.FlightData, events []string) {
+	pitch, roll, yaw := tello.QuatToEulerDeg(fd.IMU.QuaternionX, fd.IMU.QuaternionY, fd.IMU.QuaternionZ, fd.IMU.QuaternionW)
+	fmt.Fprintf(w, "Tello %s  %s\n\n", fd.SSID, fd.State)
+	fmt.Fprintf(w, "Battery  %s %3d%%\n", bar(float64(fd.BatteryPercentage)/100), fd.BatteryPercentage)
+	fmt.Fprintf(w, "WiFi     %s %3d%%\n", bar(float64(fd.WifiStrength)/100), fd.WifiStrength)
+	fmt.Fprintf(w, "Height   %5.1f m    Speed %4.1f m/s    Video %s\n", fd.HeightM(), float64(fd.GroundSpeed)/10, vbrNames[fd.VideoBitrate])
+	fmt.Fprintf(w, "Pitch %+4.0f°  Roll %+4.0f°  Yaw %+4.0f°\n\n", pitch, roll, yaw)
+	for _, line := range horizon(float64(pitch), float64(roll)) {
+		fmt.Fprintf(w, "  |%s|\n", line)
+	}
+	fmt.Fprintln(w, "\nEvents")
+	for _, ev := range events {
+		fmt.Fprintf(w, "  %s\n", ev)
+	}
+}
+
+// bar draws a horizontal bar filled in proportion to frac, which is clamped to 0..1.
+func bar(frac float64) string {
+	n := int(math.Round(math.Max(0, math.Min(1, frac)) * barWidth))
+	return "[" + strings.Repeat("#", n) + strings.Repeat(" ", barWidth-n) + "]"
+}
+
+// horizon draws an artificial horizon for the given attitude in degrees: the sky is blank, the
+// horizon is '-' and the ground '.', with the aircraft fixed at the centre as '+'.  The horizon
+// moves down as the nose rises, and tilts against the roll.
+func horizon(pitch, roll float64) []string {
+	cx, cy := horizonWidth/2, horizonHeight/2
+	slope := math.Tan(roll*math.Pi/180) / 2 // characters are about twice as tall as they are wide
+	lines := make([]string, horizonHeight)
+	for y := range lines {
+		row := make([]byte, horizonWidth)
+		for x := range row {
+			hy := math.Round(float64(cy) + pitch/horizonPitchScale - slope*float64(x-cx))
+			switch {
+			case x == cx && y == cy:
+				row[x] = '+'
+			case float64(y) < hy:
+				row[x] = ' '
+			case float64(y) == hy:
+				row[x] = '-'
+			default:
+				row[x] = '.'
+			}
+		}
+		lines[y] = string(row)
+	}
+	return lines
+}
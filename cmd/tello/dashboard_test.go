@@ -0,0 +1,77 @@
+// dashboard_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/SMerrony/tello"
+)
+
+func TestBar(t *testing.T) {
+	for frac, want := range map[float64]string{
+		0:   "[" + strings.Repeat(" ", barWidth) + "]",
+		0.5: "[" + strings.Repeat("#", barWidth/2) + strings.Repeat(" ", barWidth/2) + "]",
+		1.5: "[" + strings.Repeat("#", barWidth) + "]",
+	} {
+		if got := bar(frac); got != want {
+			t.Errorf("bar(%v) = %q, want %q", frac, got, want)
+		}
+	}
+}
+
+func TestHorizon(t *testing.T) {
+	level := horizon(0, 0)
+	if len(level) != horizonHeight {
+		t.Fatalf("got %d rows, want %d", len(level), horizonHeight)
+	}
+	mid := horizonHeight / 2
+	if strings.Trim(level[mid], "-+") != "" || strings.TrimSpace(level[0]) != "" || strings.Trim(level[horizonHeight-1], ".") != "" {
+		t.Errorf("level horizon not drawn across the middle:\n%s", strings.Join(level, "\n"))
+	}
+	// nose up: the horizon drops below the centre
+	if up := horizon(2*horizonPitchScale, 0); strings.Trim(up[mid+2], "-") != "" {
+		t.Errorf("horizon not lowered for nose up:\n%s", strings.Join(up, "\n"))
+	}
+	// rolled right: the horizon rises on the right
+	rolled := horizon(0, 45)
+	if rolled[0][horizonWidth-1] != '.' || rolled[horizonHeight-1][0] != ' ' {
+		t.Errorf("horizon not tilted for roll:\n%s", strings.Join(rolled, "\n"))
+	}
+}
+
+func TestRenderDashboard(t *testing.T) {
+	var fd tello.FlightData
+	fd.BatteryPercentage = 50
+	fd.Height = 12
+	fd.IMU.QuaternionW = 1
+	var buf bytes.Buffer
+	renderDashboard(&buf, fd, []string{"12:00:00 takeoff"})
+	out := buf.String()
+	for _, want := range []string{"Battery  " + bar(0.5) + "  50%", "Height     1.2 m", "|" + strings.Repeat(" ", horizonWidth) + "|", "12:00:00 takeoff"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dashboard lacks %q:\n%s", want, out)
+		}
+	}
+}
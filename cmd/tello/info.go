@@ -0,0 +1,185 @@
+// info.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SMerrony/tello"
+)
+
+const infoPollPeriod = 100 * time.Millisecond
+
+// report is the drone information printed by tello info.
+type report struct {
+	SerialNumber        string     `json:"serial_number,omitempty"` // only if given with -serial
+	FirmwareVersion     string     `json:"firmware_version"`
+	LoaderVersion       string     `json:"loader_version"`
+	SSID                string     `json:"ssid"`
+	WifiRegion          string     `json:"wifi_region"`
+	ActivationTime      *time.Time `json:"activation_time"` // nil if the drone has never been activated, or did not say
+	MaxHeightM          uint8      `json:"max_height_m"`
+	LowBatteryThreshold uint8      `json:"low_battery_threshold"`
+	VideoBitrate        string     `json:"video_bitrate"` // empty if the drone did not say
+	BatteryPercentage   int8       `json:"battery_percentage"`
+	Unanswered          []string   `json:"unanswered,omitempty"` // the queries the drone did not answer in time
+}
+
+// runInfo queries everything the drone will tell us about itself and prints it, as text or JSON.
+func runInfo(args []string) error {
+	var conn connection
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	conn.addFlags(fs)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	timeout := fs.Duration("timeout", 3*time.Second, "how long to wait for the drone's replies")
+	fs.Parse(args)
+
+	drone, closer, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closer()
+	r := gatherInfo(drone, *timeout)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	writeReport(os.Stdout, r)
+	return nil
+}
+
+// infoQueries are the queries tello info sends, with the message ID of each reply.
+var infoQueries = []struct {
+	name  string
+	reply tello.MessageID
+	send  func(drone *tello.Tello)
+}{
+	{"firmware version", tello.MsgQueryVersion, (*tello.Tello).GetVersion},
+	{"SSID", tello.MsgQuerySSID, (*tello.Tello).GetSSID},
+	{"WiFi region", tello.MsgQueryWifiRegion, (*tello.Tello).GetWifiRegion},
+	{"activation time", tello.MsgQueryActivationTime, (*tello.Tello).GetActivationTime},
+	{"maximum height", tello.MsgQueryHeightLimit, (*tello.Tello).GetMaxHeight},
+	{"low battery threshold", tello.MsgQueryLowBattThresh, (*tello.Tello).GetLowBatteryThreshold},
+	{"video bitrate", tello.MsgQueryVideoBitrate, (*tello.Tello).GetVideoBitrate},
+}
+
+// gatherInfo sends every query, then waits up to timeout for the replies.
+func gatherInfo(drone *tello.Tello, timeout time.Duration) report {
+	for _, q := range infoQueries {
+		q.send(drone)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	loader, _ := drone.GetLoaderVersionAndWait(ctx)
+	for {
+		// a reply has arrived if the traffic statistics have counted it, whatever its value
+		answered := map[tello.MessageID]bool{}
+		for id, ms := range drone.Stats().Received {
+			answered[id] = ms.Packets > 0
+		}
+		r := newReport(drone.GetFlightData(), loader, answered)
+		if len(r.Unanswered) == 0 || ctx.Err() != nil {
+			return r
+		}
+		time.Sleep(infoPollPeriod)
+	}
+}
+
+// newReport extracts the report from fd and the boot loader version, listing as unanswered the
+// queries whose replies are not in answered; their values in the report are not to be trusted.
+func newReport(fd tello.FlightData, loader string, answered map[tello.MessageID]bool) report {
+	r := report{
+		SerialNumber:        fd.SerialNumber,
+		FirmwareVersion:     fd.Version,
+		LoaderVersion:       loader,
+		SSID:                fd.SSID,
+		WifiRegion:          fd.WifiRegion,
+		MaxHeightM:          fd.MaxHeight,
+		LowBatteryThreshold: fd.LowBatteryThreshold,
+		BatteryPercentage:   fd.BatteryPercentage,
+	}
+	if answered[tello.MsgQueryVideoBitrate] {
+		r.VideoBitrate = vbrNames[fd.VideoBitrate]
+	}
+	if !fd.ActivationTime.IsZero() {
+		at := fd.ActivationTime.UTC()
+		r.ActivationTime = &at
+	}
+	for _, q := range infoQueries {
+		if !answered[q.reply] {
+			r.Unanswered = append(r.Unanswered, q.name)
+		}
+	}
+	if !answered[tello.MsgQueryLoaderVersion] {
+		r.Unanswered = append(r.Unanswered, "loader version")
+	}
+	return r
+}
+
+// unanswered returns true if the query with the given name is in r.Unanswered.
+func (r report) unanswered(name string) bool {
+	for _, u := range r.Unanswered {
+		if u == name {
+			return true
+		}
+	}
+	return false
+}
+
+// writeReport prints r as aligned text, with "unknown" for the values the drone did not send.
+func writeReport(w io.Writer, r report) {
+	known := func(query, value string) string {
+		if query != "" && r.unanswered(query) || value == "" {
+			return "unknown"
+		}
+		return value
+	}
+	activated := "never"
+	if r.ActivationTime != nil {
+		activated = r.ActivationTime.Format(time.RFC3339)
+	}
+	for _, f := range [][2]string{
+		{"Serial number", known("", r.SerialNumber)},
+		{"Firmware version", known("firmware version", r.FirmwareVersion)},
+		{"Loader version", known("loader version", r.LoaderVersion)},
+		{"SSID", known("SSID", r.SSID)},
+		{"WiFi region", known("WiFi region", r.WifiRegion)},
+		{"Activated", known("activation time", activated)},
+		{"Maximum height", known("maximum height", fmt.Sprintf("%d m", r.MaxHeightM))},
+		{"Low battery threshold", known("low battery threshold", fmt.Sprintf("%d%%", r.LowBatteryThreshold))},
+		{"Video bitrate", known("video bitrate", r.VideoBitrate)},
+		{"Battery", fmt.Sprintf("%d%%", r.BatteryPercentage)},
+	} {
+		fmt.Fprintf(w, "%-22s %s\n", f[0]+":", f[1])
+	}
+	if len(r.Unanswered) > 0 {
+		fmt.Fprintf(w, "\nNo reply to: %s\n", strings.Join(r.Unanswered, ", "))
+	}
+}
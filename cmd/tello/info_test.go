@@ -0,0 +1,72 @@
+// info_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello"
+)
+
+func TestGatherInfo(t *testing.T) {
+	conn := connection{useSim: true, serial: "0TQSIM00000001"}
+	drone, closer, err := conn.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer()
+	r := gatherInfo(drone, 5*time.Second)
+	if len(r.Unanswered) > 0 {
+		t.Fatalf("unanswered queries: %v", r.Unanswered)
+	}
+	if r.SerialNumber != "0TQSIM00000001" || r.SSID != "TELLO-SIM" || r.WifiRegion != "US" || r.MaxHeightM != 10 || r.LoaderVersion == "" {
+		t.Errorf("unexpected report %+v", r)
+	}
+	if r.ActivationTime == nil || !r.ActivationTime.Equal(time.Unix(1540000000, 0)) {
+		t.Errorf("got activation time %v", r.ActivationTime)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	var fd tello.FlightData
+	fd.SerialNumber = "0TQZH1234567"
+	fd.MaxHeight = 30
+	r := newReport(fd, "", map[tello.MessageID]bool{tello.MsgQueryHeightLimit: true, tello.MsgQueryVideoBitrate: true})
+	var buf bytes.Buffer
+	writeReport(&buf, r)
+	out := buf.String()
+	for _, want := range []string{
+		"Serial number:         0TQZH1234567\n",
+		"Activated:             unknown\n", // not "never", as the drone did not say
+		"Maximum height:        30 m\n",
+		"Low battery threshold: unknown\n",
+		"Video bitrate:         auto\n",
+		"No reply to: firmware version, SSID, WiFi region, activation time, low battery threshold, loader version\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+}
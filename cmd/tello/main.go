@@ -0,0 +1,129 @@
+// main.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Command tello is a toolbox for working with a Tello from the terminal.
+//
+// Usage:
+//
+//	tello <command> [flags]
+//
+// The commands are:
+//
+//	dashboard   show live telemetry and events
+//	info        print the drone's firmware, identity and settings
+//	probe       send undocumented messages to a grounded drone and record the replies
+//
+// Every command accepts -addr to give the drone's address, -serial to give its serial number,
+// and -sim to use the built-in simulator instead of a drone.  Run tello <command> -h for the command's other flags.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+
+	"github.com/SMerrony/tello"
+	"github.com/SMerrony/tello/sim"
+)
+
+// command is a subcommand, which parses its own flags from args.
+type command struct {
+	run   func(args []string) error
+	usage string
+}
+
+var commands = map[string]command{
+	"dashboard": {runDashboard, "show live telemetry and events"},
+	"info":      {runInfo, "print the drone's firmware, identity and settings"},
+	"probe":     {runProbe, "send undocumented messages to a grounded drone and record the replies"},
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "tello: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		log.Fatalf("tello %s: %v", os.Args[1], err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: tello <command> [flags]\n\nThe commands are:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", name, commands[name].usage)
+	}
+}
+
+// connection holds the flags, common to every command, which say how to reach the drone.
+type connection struct {
+	addr   string
+	port   int
+	useSim bool
+	serial string
+}
+
+func (c *connection) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.addr, "addr", "192.168.10.1", "the drone's IP address")
+	fs.IntVar(&c.port, "port", 8889, "the drone's control port")
+	fs.BoolVar(&c.useSim, "sim", false, "use the built-in simulator rather than a drone")
+	fs.StringVar(&c.serial, "serial", "", "the drone's serial number, which the binary protocol cannot query")
+}
+
+// connect connects to the drone, or starts the simulator and connects to that.  The returned
+// function disconnects, and stops the simulator.
+func (c *connection) connect(opts ...tello.Option) (drone *tello.Tello, closer func(), err error) {
+	addr, port := c.addr, c.port
+	stopSim := func() {}
+	if c.useSim {
+		s := sim.New(sim.WithVideo())
+		if err = s.Listen("127.0.0.1:0"); err != nil {
+			return nil, nil, fmt.Errorf("could not start the simulator - %v", err)
+		}
+		stopSim = func() { s.Close() }
+		addr, port = "127.0.0.1", s.Addr().Port
+		opts = append(opts, tello.WithLocalControlPort(tello.AnyPort), tello.WithVideoPort(tello.AnyPort))
+	}
+	drone = tello.NewTello(append([]tello.Option{tello.WithAddress(addr, port), tello.WithSerialNumber(c.serial)}, opts...)...)
+	if err = drone.ControlConnectDefault(); err != nil {
+		stopSim()
+		return nil, nil, fmt.Errorf("could not connect to %s - %v", net.JoinHostPort(addr, fmt.Sprint(port)), err)
+	}
+	return drone, func() {
+		drone.ControlDisconnect()
+		stopSim()
+	}, nil
+}
@@ -0,0 +1,265 @@
+// probe.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/SMerrony/tello"
+	"github.com/SMerrony/tello/protocol"
+)
+
+const (
+	probeBaseline     = 2 * time.Second // how long to listen for the drone's regular messages before probing
+	probeGroundedWait = 3 * time.Second // how long to wait for telemetry showing the drone on the ground
+)
+
+// neverProbe are the known messages which probe will not send even with -known, as they move
+// the drone, change its WiFi, video or camera settings or limits, or break the connection.
+var neverProbe = map[tello.MessageID]bool{
+	tello.MsgDoConnect: true, tello.MsgSetSSID: true, tello.MsgSetSSIDPass: true, tello.MsgSetWifiRegion: true,
+	tello.MsgSetStick: true, tello.MsgDoTakeoff: true, tello.MsgDoLand: true, tello.MsgDoFlip: true,
+	tello.MsgDoThrowTakeoff: true, tello.MsgDoPalmLand: true, tello.MsgDoSmartVideo: true, tello.MsgDoBounce: true,
+	tello.MsgDoCalibration: true, tello.MsgSetHeightLimit: true, tello.MsgSetLowBattThresh: true, tello.MsgSetAttitude: true,
+	tello.MsgSetVideoBitrate: true, tello.MsgSetDynAdjRate: true, tello.MsgEisSetting: true, tello.MsgExposureVals: true,
+	tello.MsgSwitchPicVideo: true,
+}
+
+// probeConfig says which messages to probe, and how.
+type probeConfig struct {
+	from, to   uint16
+	packetType uint8
+	known      bool          // also probe messages listed by the protocol package
+	wait       time.Duration // how long to collect replies to each message
+	baseline   time.Duration
+}
+
+// probeReply is a packet received while waiting for the reply to a probe.
+type probeReply struct {
+	MessageID uint16  `json:"message_id"`
+	Name      string  `json:"name"`
+	Type      uint8   `json:"packet_type"`
+	Sequence  uint16  `json:"sequence"`
+	Payload   string  `json:"payload"` // hex
+	AfterMs   float64 `json:"after_ms"`
+}
+
+// probeResult is the record of one probe, a message without replies is recorded too.
+type probeResult struct {
+	MessageID uint16       `json:"message_id"`
+	Name      string       `json:"name"`
+	Type      uint8        `json:"packet_type"`
+	Replies   []probeReply `json:"replies"`
+}
+
+// runProbe sends each message ID in a range, with an empty payload, to a drone on the ground and
+// records the replies as JSON lines.  It refuses to run without -i-understand, and stops the motors
+// if the drone reports that they have started.
+func runProbe(args []string) error {
+	var conn connection
+	fs := flag.NewFlagSet("probe", flag.ExitOnError)
+	conn.addFlags(fs)
+	confirmed := fs.Bool("i-understand", false, "confirm that the propellers are removed and the drone is on the ground")
+	from := fs.Uint("from", 0, "the first message ID to probe")
+	to := fs.Uint("to", 0x10ff, "the last message ID to probe")
+	packetType := fs.Uint("type", 1, "the packet type to send, 0-7, eg. 1 for get or 5 for set")
+	known := fs.Bool("known", false, "also probe the documented messages, except those which move the drone or change its settings")
+	wait := fs.Duration("wait", 500*time.Millisecond, "how long to collect replies to each message")
+	out := fs.String("o", "", "write the results to this file rather than the standard output")
+	fs.Parse(args)
+
+	if !*confirmed {
+		return errors.New("probing sends undocumented messages which may do anything, even start the motors - " +
+			"remove the propellers, put the drone on the ground and use -i-understand")
+	}
+	if *from > *to || *to > 0xffff || *packetType > 7 {
+		return errors.New("-from and -to must be message IDs 0-0xffff in order, and -type 0-7")
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	drone, closer, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closer()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	enc := json.NewEncoder(w)
+	cfg := probeConfig{from: uint16(*from), to: uint16(*to), packetType: uint8(*packetType),
+		known: *known, wait: *wait, baseline: probeBaseline}
+	return probe(ctx, drone, cfg, func(r probeResult) error {
+		if len(r.Replies) > 0 {
+			log.Printf("0x%04x: %d replies", r.MessageID, len(r.Replies))
+		}
+		return enc.Encode(r)
+	})
+}
+
+// grounded returns true if fd shows the drone on the ground with its motors stopped.
+func grounded(fd tello.FlightData) bool {
+	return fd.OnGround && !fd.Flying && !fd.EmOpen
+}
+
+// statusCount returns how many flight status messages the drone has sent.
+func statusCount(drone *tello.Tello) uint64 {
+	return drone.Stats().Received[tello.MsgFlightStatus].Packets
+}
+
+// statusAfter waits for the drone to send two more flight status messages than count, so that
+// GetFlightData() holds a status sent after count was taken, and returns it.  It fails if the control
+// connection is lost or no such status arrives within probeGroundedWait.
+func statusAfter(drone *tello.Tello, count uint64) (fd tello.FlightData, err error) {
+	deadline := time.Now().Add(probeGroundedWait)
+	for statusCount(drone) < count+2 {
+		if !drone.ControlConnected() {
+			return fd, errors.New("the control connection was lost")
+		}
+		if time.Now().After(deadline) {
+			return fd, errors.New("the drone stopped reporting its flight status")
+		}
+		time.Sleep(infoPollPeriod)
+	}
+	return drone.GetFlightData(), nil
+}
+
+// stopMotors stops the motors of a drone which is not grounded.  The binary protocol has no emergency
+// stop, so the sticks are centred and the drone is told to land, which also stops motors running on
+// the ground.
+func stopMotors(drone *tello.Tello) {
+	drone.Hover()
+	drone.Land()
+}
+
+// probe sends each message ID in the range given by cfg and passes the replies to record.  Replies
+// are packets with the probed ID, or with any ID not seen while listening beforehand for the
+// baseline period.  It stops if ctx is done, and stops the motors if they start.  Each probe waits
+// for a fresh flight status, so it also stops if the drone stops reporting or the connection is lost.
+func probe(ctx context.Context, drone *tello.Tello, cfg probeConfig, record func(probeResult) error) error {
+	fd, err := statusAfter(drone, statusCount(drone))
+	if err != nil {
+		return err
+	}
+	if !grounded(fd) {
+		return errors.New("the drone is not known to be on the ground with its motors stopped")
+	}
+	received := make(chan tello.Packet, 256)
+	drone.SetPacketHook(func(dir tello.Direction, pkt tello.Packet) {
+		if dir != tello.DirReceived {
+			return
+		}
+		select {
+		case received <- pkt:
+		default:
+		}
+	})
+	defer drone.SetPacketHook(nil)
+
+	regular := make(map[tello.MessageID]bool)
+	for _, pkt := range collect(ctx, received, cfg.baseline) {
+		regular[pkt.MessageID] = true
+	}
+	for id := int(cfg.from); id <= int(cfg.to); id++ {
+		msgID := tello.MessageID(id)
+		if _, documented := protocol.Lookup(uint16(id)); neverProbe[msgID] || (documented && !cfg.known) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		collect(ctx, received, 0) // discard anything left over from the last probe
+		count := statusCount(drone)
+		sent := time.Now()
+		if err := drone.SendRawCommand(msgID, cfg.packetType, nil); err != nil {
+			return err
+		}
+		res := probeResult{MessageID: uint16(id), Name: msgID.String(), Type: cfg.packetType, Replies: []probeReply{}}
+		for _, pkt := range collect(ctx, received, cfg.wait) {
+			if pkt.MessageID != msgID && regular[pkt.MessageID] {
+				continue
+			}
+			res.Replies = append(res.Replies, probeReply{
+				MessageID: uint16(pkt.MessageID),
+				Name:      pkt.MessageID.String(),
+				Type:      pkt.Type,
+				Sequence:  pkt.Sequence,
+				Payload:   hex.EncodeToString(pkt.Payload),
+				AfterMs:   float64(pkt.Time.Sub(sent)) / float64(time.Millisecond),
+			})
+		}
+		fd, statusErr := statusAfter(drone, count)
+		if err := record(res); err != nil {
+			return err
+		}
+		if statusErr != nil {
+			return fmt.Errorf("stopped after message 0x%04x as %v", id, statusErr)
+		}
+		if !grounded(fd) {
+			stopMotors(drone)
+			return fmt.Errorf("stopped after message 0x%04x as the drone's motors started, stopping them", id)
+		}
+	}
+	return nil
+}
+
+// collect returns the packets received within d, or those already waiting if d is 0.
+func collect(ctx context.Context, received <-chan tello.Packet, d time.Duration) (pkts []tello.Packet) {
+	if d == 0 {
+		for {
+			select {
+			case pkt := <-received:
+				pkts = append(pkts, pkt)
+			default:
+				return pkts
+			}
+		}
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		select {
+		case pkt := <-received:
+			pkts = append(pkts, pkt)
+		case <-timer.C:
+			return pkts
+		case <-ctx.Done():
+			return pkts
+		}
+	}
+}
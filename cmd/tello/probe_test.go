@@ -0,0 +1,98 @@
+// probe_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello"
+)
+
+func TestProbe(t *testing.T) {
+	conn := connection{useSim: true}
+	drone, closer, err := conn.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer()
+	cfg := probeConfig{from: 0x0045, to: 0x0055, packetType: 1, known: true, wait: 200 * time.Millisecond, baseline: 500 * time.Millisecond}
+	results := make(map[uint16]probeResult)
+	err = probe(context.Background(), drone, cfg, func(r probeResult) error {
+		results[r.MessageID] = r
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []tello.MessageID{tello.MsgSetStick, tello.MsgDoTakeoff, tello.MsgDoLand} {
+		if _, ok := results[uint16(id)]; ok {
+			t.Errorf("%v was probed", id)
+		}
+	}
+	version, ok := results[uint16(tello.MsgQueryVersion)]
+	if !ok || len(version.Replies) != 1 || !strings.HasPrefix(version.Replies[0].Payload, "00") || version.Replies[0].Name != "QueryVersion" {
+		t.Errorf("got version probe %+v", version)
+	}
+	if unknown, ok := results[0x0048]; !ok || len(unknown.Replies) != 0 {
+		t.Errorf("got unknown probe %+v", unknown)
+	}
+	if !grounded(drone.GetFlightData()) {
+		t.Error("drone left the ground")
+	}
+
+	// documented messages are skipped unless asked for
+	cfg.known = false
+	results = make(map[uint16]probeResult)
+	probe(context.Background(), drone, cfg, func(r probeResult) error {
+		results[r.MessageID] = r
+		return nil
+	})
+	if _, ok := results[uint16(tello.MsgQueryVersion)]; ok {
+		t.Error("documented message probed without known")
+	}
+}
+
+func TestProbeRequiresConfirmation(t *testing.T) {
+	if err := runProbe([]string{"-sim"}); err == nil || !strings.Contains(err.Error(), "-i-understand") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestStatusAfter(t *testing.T) {
+	conn := connection{useSim: true}
+	drone, closer, err := conn.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer()
+	count := statusCount(drone)
+	if _, err := statusAfter(drone, count); err != nil || statusCount(drone) < count+2 {
+		t.Errorf("got %v, %d statuses after %d", err, statusCount(drone), count)
+	}
+	drone.ControlDisconnect()
+	if _, err := statusAfter(drone, statusCount(drone)); err == nil || !strings.Contains(err.Error(), "connection was lost") {
+		t.Errorf("got %v once disconnected", err)
+	}
+}
@@ -0,0 +1,178 @@
+// corpus_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello/protocol"
+)
+
+// The corpus tests replay recorded sessions, see cmd/tello-corpus, through both the protocol
+// package's decoder and the client, and compare the results with those recorded when the corpus
+// was added.  Run go test -run TestCorpus -update-corpus to accept intentional changes.
+
+var updateCorpus = flag.Bool("update-corpus", false, "rewrite the expected results of the corpus replay tests")
+
+const corpusSentinel = 0xfffe // pushed after batches of replayed packets to know they have been handled
+
+func TestCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "corpus", "*.corpus"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("No corpus files")
+	}
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			f, err := os.Open(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkts, err := protocol.ReadCorpus(f)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := replayCorpus(t, pkts)
+			golden := strings.TrimSuffix(file, ".corpus") + ".golden"
+			if *updateCorpus {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v, run the test with -update-corpus to create it", err)
+			}
+			if !bytes.Equal(got, want) {
+				gotLines, wantLines := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+				for i := range wantLines {
+					if i >= len(gotLines) || gotLines[i] != wantLines[i] {
+						t.Fatalf("%s differs from line %d:\nwant %s\ngot  %s", golden, i+1, wantLines[i], lineOrEOF(gotLines, i))
+					}
+				}
+				t.Fatalf("%s differs, got %d extra lines", golden, len(gotLines)-len(wantLines))
+			}
+		})
+	}
+}
+
+func lineOrEOF(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return "EOF"
+}
+
+// replayCorpus returns the protocol package's decoding of each packet, followed by the client's
+// flight data after receiving every packet sent by the drone.
+func replayCorpus(t *testing.T, pkts []protocol.CorpusPacket) []byte {
+	var out bytes.Buffer
+	for _, p := range pkts {
+		dir := "<"
+		if p.ToDrone {
+			dir = ">"
+		}
+		pkt, err := protocol.Parse(p.Data)
+		if err != nil {
+			fmt.Fprintf(&out, "%s %q\n", dir, p.Data)
+			continue
+		}
+		payload, err := protocol.Decode(pkt)
+		fmt.Fprintf(&out, "%s %s %s", dir, protocol.Name(pkt.MessageID), corpusFields(payload))
+		if err != nil {
+			fmt.Fprintf(&out, " error: %v", err)
+		}
+		out.WriteByte('\n')
+	}
+
+	drone, fake := pushingDrone(t)
+	handled := make(chan struct{}, 1)
+	drone.HandleMessage(corpusSentinel, func(RawMessage) { handled <- struct{}{} })
+	settle := func() {
+		fake.pushSeq(corpusSentinel, 0)
+		select {
+		case <-handled:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for the client to handle replayed packets")
+		}
+	}
+	sent := 0
+	for _, p := range pkts {
+		if p.ToDrone || len(p.Data) == 0 || p.Data[0] != msgHdr {
+			continue
+		}
+		fake.pushRaw(p.Data)
+		if sent++; sent%32 == 0 {
+			settle()
+		}
+	}
+	settle()
+
+	js, err := json.MarshalIndent(stableFlightData(drone.GetFlightData()), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.WriteString("# client flight data\n")
+	out.Write(js)
+	out.WriteByte('\n')
+	return out.Bytes()
+}
+
+// corpusFields formats a decoded payload, abbreviating byte slices to their length.
+func corpusFields(payload interface{}) string {
+	if payload == nil {
+		return "-"
+	}
+	v := reflect.ValueOf(payload)
+	parts := make([]string, v.NumField())
+	for i := range parts {
+		val := v.Field(i).Interface()
+		if b, ok := val.([]byte); ok {
+			val = fmt.Sprintf("[%d bytes]", len(b))
+		}
+		parts[i] = fmt.Sprintf("%s:%v", v.Type().Field(i).Name, val)
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// stableFlightData clears the fields which depend on when packets arrived rather than their contents.
+func stableFlightData(fd FlightData) FlightData {
+	fd.LightStrengthUpdated = time.Time{}
+	fd.SessionFlyTime, fd.TotalFlyTime = 0, 0
+	fd.SessionDistance, fd.TotalDistance = 0, 0
+	fd.GroundSpeedSmoothed, fd.VerticalSpeedSmoothed, fd.HeightSmoothed, fd.WifiStrengthSmoothed = 0, 0, 0, 0
+	return fd
+}
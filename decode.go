@@ -0,0 +1,179 @@
+// decode.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"image"
+)
+
+// Decoder turns the raw H.264 video stream into images.
+// Implementations are available in the decoder package.
+type Decoder interface {
+	// Decode is passed the video data in the order it was received, and returns any frames
+	// which it completes, eg. as *image.YCbCr.
+	Decode(data []byte) ([]image.Image, error)
+	// Close releases the Decoder's resources.
+	Close() error
+}
+
+// WithDecoder sets the Decoder used to provide the frames from SubscribeDecodedFrames().
+// The Tello does not close the Decoder.
+func WithDecoder(d Decoder) Option {
+	return func(tello *Tello) { tello.cfg.decoder = d }
+}
+
+// SubscribeDecodedFrames returns a channel of decoded video frames, and a func to unsubscribe.
+// A Decoder must have been set via WithDecoder(), and video must be connected as usual for any
+// frames to arrive.  Decoding only happens while there are subscribers.
+// If the decoder falls behind, video data is dropped, which may cause artefacts until the
+// next keyframe; if a subscriber falls behind, frames are dropped for it.
+func (tello *Tello) SubscribeDecodedFrames() (<-chan image.Image, func(), error) {
+	dec := tello.cfg.decoder
+	if dec == nil {
+		return nil, nil, errors.New("No Decoder set, see WithDecoder()")
+	}
+	tello.decMu.Lock()
+	defer tello.decMu.Unlock()
+	if tello.decListeners == nil {
+		tello.decListeners = map[chan image.Image]chan image.Image{}
+	}
+	if tello.decQ == nil {
+		prev := tello.decDone
+		tello.decQ = make(chan []byte, tello.cfg.getVideoBufSize())
+		tello.decDone = make(chan struct{})
+		go tello.decodeFrames(dec, tello.decQ, prev, tello.decDone)
+	}
+	res := make(chan image.Image, decodedFramesBuf)
+	tello.decListeners[res] = res
+	return res, func() {
+		tello.decMu.Lock()
+		defer tello.decMu.Unlock()
+		if _, present := tello.decListeners[res]; present {
+			delete(tello.decListeners, res)
+			close(res)
+			if len(tello.decListeners) == 0 {
+				close(tello.decQ)
+				tello.decQ = nil
+				for ch := range tello.decKeyWaiters {
+					delete(tello.decKeyWaiters, ch)
+					close(ch)
+				}
+			}
+		}
+	}, nil
+}
+
+const decodedFramesBuf = 2 // frames are big, and stale ones are of little use
+
+// queueForDecoding passes video data to the decoding goroutine, if there is one.
+func (tello *Tello) queueForDecoding(data []byte) {
+	tello.decMu.RLock()
+	defer tello.decMu.RUnlock()
+	if tello.decQ == nil {
+		return
+	}
+	select {
+	case tello.decQ <- data:
+	default: // so we don't block
+	}
+}
+
+// decodeFrames decodes the data from q and sends the frames to the subscribers until q is closed,
+// then closes done.  As a Decoder need not be safe for concurrent use, it first waits for prev to be
+// closed by any earlier decodeFrames, which may still be busy after a quick resubscription.
+func (tello *Tello) decodeFrames(dec Decoder, q <-chan []byte, prev <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	if prev != nil {
+		<-prev
+	}
+	for data := range q {
+		tello.decMu.Lock()
+		tello.countSlices(data)
+		tello.decMu.Unlock()
+		frames, err := dec.Decode(data)
+		if err != nil {
+			tello.logf("Video decoding error - %v\n", err)
+		}
+		if len(frames) == 0 {
+			continue
+		}
+		tello.decMu.Lock()
+		for _, f := range frames {
+			tello.decOut++
+			for ch, key := range tello.decKeyWaiters {
+				if key != 0 && tello.decOut >= key {
+					ch <- f // buffered, and only sent once
+					delete(tello.decKeyWaiters, ch)
+				}
+			}
+			for ch := range tello.decListeners {
+				select {
+				case ch <- f:
+				default: // so we don't block
+				}
+			}
+		}
+		tello.decMu.Unlock()
+	}
+}
+
+// countSlices adds the slices starting in data to decFed, and sets the keyframe awaited by any
+// decodedKeyframe() waiters which do not have one yet.  decMu must be locked.
+func (tello *Tello) countSlices(data []byte) {
+	for i := 0; i+3 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+		switch data[i+3] & 0x1f {
+		case 1:
+			tello.decFed++
+		case nalIDR:
+			tello.decFed++
+			for ch, key := range tello.decKeyWaiters {
+				if key == 0 {
+					tello.decKeyWaiters[ch] = tello.decFed
+				}
+			}
+		}
+	}
+}
+
+// decodedKeyframe returns a channel which receives a frame decoded from the first keyframe passed to
+// the Decoder after the call, and a func to stop waiting.  The Decoder may be asynchronous, so the
+// frame is matched by counting: the Tello sends one slice per frame, so the Nth frame returned by
+// the Decoder is from the Nth slice passed to it, or a later one if it has dropped any.  The channel
+// is closed if decoding stops before the frame arrives.
+func (tello *Tello) decodedKeyframe() (<-chan image.Image, func()) {
+	tello.decMu.Lock()
+	defer tello.decMu.Unlock()
+	if tello.decKeyWaiters == nil {
+		tello.decKeyWaiters = map[chan image.Image]uint64{}
+	}
+	res := make(chan image.Image, 1)
+	tello.decKeyWaiters[res] = 0
+	return res, func() {
+		tello.decMu.Lock()
+		defer tello.decMu.Unlock()
+		delete(tello.decKeyWaiters, res)
+	}
+}
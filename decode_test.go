@@ -0,0 +1,119 @@
+// decode_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"image"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeDecoder makes one frame, as wide as the data, from each call to Decode.
+type fakeDecoder struct{}
+
+func (fakeDecoder) Decode(data []byte) ([]image.Image, error) {
+	return []image.Image{image.NewGray(image.Rect(0, 0, len(data), 1))}, nil
+}
+
+func (fakeDecoder) Close() error { return nil }
+
+// gatedDecoder signals entered on each call to Decode, which then waits for release to be closed.
+type gatedDecoder struct {
+	entered, release chan struct{}
+	busy, overlapped int32
+}
+
+func (d *gatedDecoder) Decode(data []byte) ([]image.Image, error) {
+	if !atomic.CompareAndSwapInt32(&d.busy, 0, 1) {
+		atomic.StoreInt32(&d.overlapped, 1)
+	}
+	d.entered <- struct{}{}
+	<-d.release
+	atomic.StoreInt32(&d.busy, 0)
+	return []image.Image{image.NewGray(image.Rect(0, 0, len(data), 1))}, nil
+}
+
+func (d *gatedDecoder) Close() error { return nil }
+
+func TestSubscribeDecodedFrames(t *testing.T) {
+	if _, _, err := new(Tello).SubscribeDecodedFrames(); err == nil {
+		t.Error("Expected an error without a Decoder")
+	}
+
+	drone := NewTello(WithDecoder(fakeDecoder{}))
+	drone.queueForDecoding([]byte{1}) // no subscribers, so nothing to do
+	frames, unsubscribe, err := drone.SubscribeDecodedFrames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	drone.queueForDecoding([]byte{1, 2, 3})
+	select {
+	case img := <-frames:
+		if img.Bounds().Dx() != 3 {
+			t.Errorf("Unexpected frame %v", img.Bounds())
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("No frame decoded")
+	}
+
+	unsubscribe()
+	unsubscribe() // harmless
+	if _, open := <-frames; open {
+		t.Error("Expected the channel to be closed")
+	}
+	drone.decMu.RLock()
+	defer drone.decMu.RUnlock()
+	if drone.decQ != nil {
+		t.Error("Expected decoding to stop without subscribers")
+	}
+}
+
+func TestResubscribeDecodedFrames(t *testing.T) {
+	dec := &gatedDecoder{entered: make(chan struct{}, 10), release: make(chan struct{})}
+	drone := NewTello(WithDecoder(dec))
+	_, unsubscribe, _ := drone.SubscribeDecodedFrames()
+	drone.queueForDecoding([]byte{1})
+	<-dec.entered
+	unsubscribe() // while the first Decode is still running
+
+	frames, unsubscribe, _ := drone.SubscribeDecodedFrames()
+	defer unsubscribe()
+	drone.queueForDecoding([]byte{1, 2})
+	select {
+	case <-dec.entered:
+		t.Error("Expected the new subscription to wait for the earlier Decode")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(dec.release)
+	for decoded := false; !decoded; { // the earlier Decode's frame may come first
+		select {
+		case img := <-frames:
+			decoded = img.Bounds().Dx() == 2
+		case <-time.After(3 * time.Second):
+			t.Fatal("No frame decoded after resubscribing")
+		}
+	}
+	if atomic.LoadInt32(&dec.overlapped) != 0 {
+		t.Error("Expected no concurrent calls to Decode")
+	}
+}
@@ -0,0 +1,172 @@
+// ffmpeg.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+/*
+Package decoder provides implementations of tello.Decoder for turning the drone's H.264 video
+into images, eg.
+
+	dec, err := decoder.NewFFmpeg(960, 720)
+	...
+	defer dec.Close()
+	drone := tello.NewTello(tello.WithDecoder(dec))
+	...
+	frames, unsubscribe, err := drone.SubscribeDecodedFrames()
+
+FFmpeg pipes the video through an external ffmpeg process, which must be installed and on the
+PATH.  LibAV decodes in-process via cgo and needs the libavcodec development files; it is only
+built with the libav build tag, ie. go build -tags libav.
+
+The frames are *image.YCbCr in 4:2:0 format, so the planes may be used directly by YUV-based
+pipelines, or the frames treated as any other image.Image.
+*/
+package decoder
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// FFmpegPath is the ffmpeg executable used by NewFFmpeg().
+var FFmpegPath = "ffmpeg"
+
+const ffmpegFramesBuf = 8 // frames held for Decode() to collect, older ones are dropped
+
+// ffmpegCommand returns the command which decodes H.264 on stdin to raw YUV 4:2:0 on stdout.
+var ffmpegCommand = func(width, height int) *exec.Cmd {
+	return exec.Command(FFmpegPath, "-loglevel", "error",
+		"-f", "h264", "-i", "pipe:0",
+		"-f", "rawvideo", "-pix_fmt", "yuv420p", "-s", strconv.Itoa(width)+"x"+strconv.Itoa(height),
+		"pipe:1")
+}
+
+// FFmpeg is a Decoder which pipes the video through an external ffmpeg process.
+// Frames are scaled to the size given to NewFFmpeg(), the Tello sends 960x720 normally
+// and 1280x720 in wide mode.
+type FFmpeg struct {
+	width, height int
+	cmd           *exec.Cmd
+	stdin         io.WriteCloser
+	frames        chan image.Image
+	readerDone    chan struct{}
+	mu            sync.Mutex // protects err
+	err           error      // from reading ffmpeg's output
+}
+
+// NewFFmpeg starts an ffmpeg process to decode video to frames of the given size.
+func NewFFmpeg(width, height int) (*FFmpeg, error) {
+	if width <= 0 || height <= 0 || width%2 != 0 || height%2 != 0 {
+		return nil, errors.New("Frame width and height must be positive and even")
+	}
+	f := &FFmpeg{
+		width:      width,
+		height:     height,
+		cmd:        ffmpegCommand(width, height),
+		frames:     make(chan image.Image, ffmpegFramesBuf),
+		readerDone: make(chan struct{}),
+	}
+	var err error
+	if f.stdin, err = f.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	stdout, err := f.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = f.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Cannot start ffmpeg: %v", err)
+	}
+	go f.readFrames(stdout)
+	return f, nil
+}
+
+// readFrames reads fixed-size raw frames from ffmpeg until it exits.
+func (f *FFmpeg) readFrames(r io.Reader) {
+	defer close(f.readerDone)
+	for {
+		img := image.NewYCbCr(image.Rect(0, 0, f.width, f.height), image.YCbCrSubsampleRatio420)
+		if err := readPlanes(r, img); err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF { // ffmpeg has exited
+				f.setErr(err)
+			}
+			return
+		}
+		select {
+		case f.frames <- img:
+		default: // drop the oldest frame to make room, we are the only sender
+			select {
+			case <-f.frames:
+			default:
+			}
+			f.frames <- img
+		}
+	}
+}
+
+// readPlanes fills the Y, Cb and Cr planes of img from r.
+func readPlanes(r io.Reader, img *image.YCbCr) (err error) {
+	for _, plane := range [][]byte{img.Y, img.Cb, img.Cr} {
+		if _, err = io.ReadFull(r, plane); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FFmpeg) setErr(err error) {
+	f.mu.Lock()
+	f.err = err
+	f.mu.Unlock()
+}
+
+// Decode passes the data to ffmpeg and returns any frames it has finished since the last call.
+// As ffmpeg works asynchronously, the frames for this data will usually be returned by a later call.
+func (f *FFmpeg) Decode(data []byte) (frames []image.Image, err error) {
+	if _, err = f.stdin.Write(data); err != nil {
+		return nil, err
+	}
+	for {
+		select {
+		case img := <-f.frames:
+			frames = append(frames, img)
+		default:
+			f.mu.Lock()
+			err = f.err
+			f.mu.Unlock()
+			return frames, err
+		}
+	}
+}
+
+// Close stops the ffmpeg process.
+func (f *FFmpeg) Close() error {
+	f.stdin.Close()
+	<-f.readerDone
+	err := f.cmd.Wait()
+	if err != nil {
+		return fmt.Errorf("ffmpeg: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// ffmpeg_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package decoder
+
+import (
+	"image"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello"
+)
+
+var _ tello.Decoder = (*FFmpeg)(nil)
+
+// TestHelperProcess stands in for ffmpeg, passing its input straight through as if it were
+// already raw video.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("DECODER_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	io.Copy(os.Stdout, os.Stdin)
+	os.Exit(0)
+}
+
+func TestFFmpeg(t *testing.T) {
+	ffmpegCommand = func(width, height int) *exec.Cmd {
+		cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+		cmd.Env = append(os.Environ(), "DECODER_WANT_HELPER_PROCESS=1")
+		return cmd
+	}
+	if _, err := NewFFmpeg(3, 2); err == nil {
+		t.Error("Expected an odd width to be refused")
+	}
+	dec, err := NewFFmpeg(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two 4x2 frames are 12 bytes each: 8 of Y, then 2 each of Cb and Cr
+	raw := []byte{
+		1, 2, 3, 4, 5, 6, 7, 8, 100, 101, 200, 201,
+		9, 9, 9, 9, 9, 9, 9, 9, 110, 111, 210, 211,
+	}
+	var frames []image.Image
+	got, err := dec.Decode(raw[:5]) // frames may be split across writes
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames = append(frames, got...)
+	if _, err = dec.Decode(raw[5:]); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); len(frames) < 2 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		got, err = dec.Decode(nil)
+		frames = append(frames, got...)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("Expected 2 frames, got %d", len(frames))
+	}
+	first := frames[0].(*image.YCbCr)
+	if first.Bounds().Dx() != 4 || first.Y[7] != 8 || first.Cb[1] != 101 || first.Cr[0] != 200 {
+		t.Errorf("First frame not decoded correctly: %+v", first)
+	}
+	if second := frames[1].(*image.YCbCr); second.Cr[1] != 211 {
+		t.Errorf("Second frame not decoded correctly: %+v", second)
+	}
+	if err := dec.Close(); err != nil {
+		t.Error(err)
+	}
+}
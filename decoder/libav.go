@@ -0,0 +1,155 @@
+//go:build libav && cgo
+// +build libav,cgo
+
+// libav.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package decoder
+
+/*
+#cgo pkg-config: libavcodec libavutil
+#include <stdlib.h>
+#include <string.h>
+#include <libavcodec/avcodec.h>
+#include <libavutil/frame.h>
+#include <libavutil/pixfmt.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"image"
+	"math"
+	"unsafe"
+)
+
+// LibAV is a Decoder which uses libavcodec in-process via cgo.
+// It is only available when built with the libav build tag.
+type LibAV struct {
+	ctx    *C.AVCodecContext
+	parser *C.AVCodecParserContext
+	pkt    *C.AVPacket
+	frame  *C.AVFrame
+}
+
+// NewLibAV returns an H.264 decoder using libavcodec.
+func NewLibAV() (*LibAV, error) {
+	codec := C.avcodec_find_decoder(C.AV_CODEC_ID_H264)
+	if codec == nil {
+		return nil, errors.New("libavcodec has no H.264 decoder")
+	}
+	l := &LibAV{
+		ctx:    C.avcodec_alloc_context3(codec),
+		parser: C.av_parser_init(C.int(C.AV_CODEC_ID_H264)),
+		pkt:    C.av_packet_alloc(),
+		frame:  C.av_frame_alloc(),
+	}
+	if l.ctx == nil || l.parser == nil || l.pkt == nil || l.frame == nil {
+		l.Close()
+		return nil, errors.New("Cannot allocate libavcodec decoder")
+	}
+	if C.avcodec_open2(l.ctx, codec, nil) < 0 {
+		l.Close()
+		return nil, errors.New("Cannot open libavcodec H.264 decoder")
+	}
+	return l, nil
+}
+
+// Decode parses the data into H.264 packets, decodes them and returns any completed frames.
+func (l *LibAV) Decode(data []byte) (frames []image.Image, err error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	// the parser may read a little beyond the end of the data, which must be zeroed
+	buf := C.malloc(C.size_t(len(data) + C.AV_INPUT_BUFFER_PADDING_SIZE))
+	if buf == nil {
+		return nil, errors.New("Cannot allocate decoding buffer")
+	}
+	defer C.free(buf)
+	C.memset(buf, 0, C.size_t(len(data)+C.AV_INPUT_BUFFER_PADDING_SIZE))
+	C.memcpy(buf, unsafe.Pointer(&data[0]), C.size_t(len(data)))
+
+	in, size := (*C.uint8_t)(buf), C.int(len(data))
+	for size > 0 {
+		var out *C.uint8_t
+		var outSize C.int
+		n := C.av_parser_parse2(l.parser, l.ctx, &out, &outSize, in, size,
+			C.int64_t(math.MinInt64), C.int64_t(math.MinInt64), 0) // AV_NOPTS_VALUE
+		if n < 0 {
+			return frames, errors.New("Error parsing H.264 stream")
+		}
+		in = (*C.uint8_t)(unsafe.Pointer(uintptr(unsafe.Pointer(in)) + uintptr(n)))
+		size -= n
+		if outSize == 0 {
+			continue
+		}
+		l.pkt.data, l.pkt.size = out, outSize
+		if C.avcodec_send_packet(l.ctx, l.pkt) < 0 {
+			err = errors.New("Error decoding H.264 packet") // carry on with the rest of the data
+			continue
+		}
+		for C.avcodec_receive_frame(l.ctx, l.frame) == 0 {
+			if img := l.copyFrame(); img != nil {
+				frames = append(frames, img)
+			}
+		}
+	}
+	return frames, err
+}
+
+// copyFrame copies the current 4:2:0 frame to Go memory, it returns nil for other formats.
+func (l *LibAV) copyFrame() image.Image {
+	f := l.frame
+	if f.format != C.AV_PIX_FMT_YUV420P && f.format != C.AV_PIX_FMT_YUVJ420P {
+		return nil
+	}
+	w, h := int(f.width), int(f.height)
+	img := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	copyPlane(img.Y, img.YStride, f.data[0], int(f.linesize[0]), w, h)
+	copyPlane(img.Cb, img.CStride, f.data[1], int(f.linesize[1]), (w+1)/2, (h+1)/2)
+	copyPlane(img.Cr, img.CStride, f.data[2], int(f.linesize[2]), (w+1)/2, (h+1)/2)
+	return img
+}
+
+func copyPlane(dst []byte, dstStride int, src *C.uint8_t, srcStride, w, h int) {
+	for y := 0; y < h; y++ {
+		row := C.GoBytes(unsafe.Pointer(uintptr(unsafe.Pointer(src))+uintptr(y*srcStride)), C.int(w))
+		copy(dst[y*dstStride:], row)
+	}
+}
+
+// Close frees the decoder.
+func (l *LibAV) Close() error {
+	if l.parser != nil {
+		C.av_parser_close(l.parser)
+		l.parser = nil
+	}
+	if l.ctx != nil {
+		C.avcodec_free_context(&l.ctx)
+	}
+	if l.frame != nil {
+		C.av_frame_free(&l.frame)
+	}
+	if l.pkt != nil {
+		C.av_packet_free(&l.pkt)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// discover.go
+
+// Discovery of Tellos on the local network.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sort"
+	"time"
+)
+
+// DiscoveredDrone describes a Tello which answered a discovery probe.
+type DiscoveredDrone struct {
+	Addr string // IP address, suitable for ControlConnect() or WithAddress()
+	SSID string // eg. "TELLO-ABCDEF", if the drone answered the query
+}
+
+const maxScanHosts = 1024 // refuse to scan networks larger than this
+
+var telloAPNet = net.IPNet{IP: net.IPv4(192, 168, 10, 0), Mask: net.CIDRMask(24, 32)}
+
+// Discover looks for Tellos for up to timeout.  If we are connected to a Tello's own WiFi access point
+// the drone at its usual address is probed, otherwise every host on each of our local IPv4 networks
+// of up to maxScanHosts addresses is probed, to find drones in station mode.
+func Discover(timeout time.Duration) ([]DiscoveredDrone, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var candidates []net.IP
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok || ipn.IP.To4() == nil || ipn.IP.IsLoopback() {
+			continue
+		}
+		if telloAPNet.Contains(ipn.IP) {
+			candidates = append(candidates, net.ParseIP(defaultTelloAddr))
+			continue
+		}
+		if hosts, err := subnetHosts(ipn); err == nil {
+			candidates = append(candidates, hosts...)
+		}
+	}
+	return discover(candidates, defaultTelloControlPort, timeout)
+}
+
+// DiscoverCIDR probes every host in the given network, eg. "192.168.1.0/24", for Tellos in station mode
+// and returns those which answer within timeout.
+func DiscoverCIDR(cidr string, timeout time.Duration) ([]DiscoveredDrone, error) {
+	_, ipn, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := subnetHosts(ipn)
+	if err != nil {
+		return nil, err
+	}
+	return discover(hosts, defaultTelloControlPort, timeout)
+}
+
+// subnetHosts lists the host addresses of an IPv4 network, excluding the network and broadcast addresses.
+func subnetHosts(ipn *net.IPNet) (hosts []net.IP, err error) {
+	base := ipn.IP.Mask(ipn.Mask).To4()
+	ones, bits := ipn.Mask.Size()
+	if base == nil || bits != 32 {
+		return nil, errors.New("Only IPv4 networks can be scanned")
+	}
+	size := 1 << uint(bits-ones)
+	if size > maxScanHosts {
+		return nil, errors.New("Network too large to scan")
+	}
+	first, last := 1, size-1
+	if size <= 2 { // /31 and /32 have no network or broadcast address
+		first, last = 0, size
+	}
+	start := uint32(base[0])<<24 | uint32(base[1])<<16 | uint32(base[2])<<8 | uint32(base[3])
+	for i := first; i < last; i++ {
+		n := start + uint32(i)
+		hosts = append(hosts, net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n)))
+	}
+	return hosts, nil
+}
+
+// discover sends a connection request to each candidate and collects the replies until timeout,
+// drones which acknowledge are then asked for their SSID.
+func discover(candidates []net.IP, port int, timeout time.Duration) ([]DiscoveredDrone, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	vp := uint16(defaultTelloVideoPort)
+	req := []byte("conn_req:lh")
+	req[9], req[10] = byte(vp), byte(vp>>8)
+	for _, ip := range candidates {
+		conn.WriteToUDP(req, &net.UDPAddr{IP: ip, Port: port})
+	}
+
+	found := map[string]*DiscoveredDrone{}
+	deadline := time.Now().Add(timeout)
+	conn.SetReadDeadline(deadline)
+	buff := make([]byte, 2048)
+	for {
+		n, from, err := conn.ReadFromUDP(buff)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				break
+			}
+			continue // eg. ICMP port unreachable from a host which is not a drone
+		}
+		key := from.IP.String()
+		reply := buff[:n]
+		d, known := found[key]
+		switch {
+		case bytes.HasPrefix(reply, []byte("conn_ack")):
+			if !known {
+				found[key] = &DiscoveredDrone{Addr: key}
+				conn.WriteToUDP(packetToBuffer(newPacket(ptGet, MsgQuerySSID, 0, 0)), from)
+			}
+		case !known:
+			// not a drone which has acknowledged us
+		case n >= minPktSize && reply[0] == msgHdr:
+			if pkt := bufferToPacket(reply); pkt.messageID == MsgQuerySSID && len(pkt.payload) > 2 {
+				d.SSID = string(pkt.payload[2:])
+			}
+		}
+	}
+
+	res := make([]DiscoveredDrone, 0, len(found))
+	for _, d := range found {
+		res = append(res, *d)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Addr < res[j].Addr })
+	return res, nil
+}
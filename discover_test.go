@@ -0,0 +1,82 @@
+// tello project discover_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSubnetHosts(t *testing.T) {
+	_, ipn, _ := net.ParseCIDR("192.168.1.0/30")
+	hosts, err := subnetHosts(ipn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 || !hosts[0].Equal(net.IPv4(192, 168, 1, 1)) || !hosts[1].Equal(net.IPv4(192, 168, 1, 2)) {
+		t.Errorf("Unexpected hosts %v", hosts)
+	}
+	_, ipn, _ = net.ParseCIDR("10.0.0.0/8")
+	if _, err := subnetHosts(ipn); err == nil {
+		t.Error("Expected an error for a huge network")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	fake, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+	go func() {
+		buff := make([]byte, 1024)
+		for {
+			n, addr, err := fake.ReadFromUDP(buff)
+			if err != nil {
+				return
+			}
+			switch {
+			case bytes.HasPrefix(buff[:n], []byte("conn_req:")):
+				fake.WriteToUDP([]byte("conn_ack:\x96\x17"), addr)
+				fake.WriteToUDP(nil, addr) // an empty datagram must not upset the scan
+			case buff[0] == msgHdr:
+				reply := newPacket(ptGet, MsgQuerySSID, 0, 14)
+				copy(reply.payload[2:], "TELLO-ABCDEF")
+				fake.WriteToUDP(packetToBuffer(reply), addr)
+			}
+		}
+	}()
+
+	port := fake.LocalAddr().(*net.UDPAddr).Port
+	found, err := discover([]net.IP{net.IPv4(127, 0, 0, 1), net.IPv4(127, 0, 0, 2)}, port, 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("Expected 1 drone, got %+v", found)
+	}
+	if d := found[0]; d.Addr != "127.0.0.1" || d.SSID != "TELLO-ABCDEF" {
+		t.Errorf("Unexpected drone %+v", d)
+	}
+}
@@ -0,0 +1,49 @@
+// drone.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+// Drone is the high-level flight interface which is implemented both by Tello, which uses
+// the binary protocol, and by sdk.Client, which uses the official text SDK.
+// Applications written against Drone may use either transport.
+type Drone interface {
+	ControlConnectDefault() error
+	ControlConnected() bool
+	ControlDisconnect()
+	GetFlightData() FlightData
+	UpdateSticks(sm StickMessage)
+	TakeOff()
+	Land()
+	Hover()
+	Forward(pct int)
+	Backward(pct int)
+	Left(pct int)
+	Right(pct int)
+	Up(pct int)
+	Down(pct int)
+	Clockwise(pct int)
+	Anticlockwise(pct int)
+	Flip(dir FlipType)
+	VideoConnectDefault() (<-chan []byte, error)
+	VideoDisconnect()
+}
+
+var _ Drone = (*Tello)(nil)
@@ -0,0 +1,124 @@
+// envelope.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "math"
+
+// fullStickTilt is roughly the tilt, in degrees, reached with the right stick fully deflected.
+const fullStickTilt = 25.0
+
+// envelopeMargin is the fraction of a speed limit above which the sticks start to be scaled down,
+// so that the drone eases up to the limit rather than overshooting it.
+const envelopeMargin = 0.8
+
+// Envelope sets the limits enforced on the stick values sent to the Tello, whether they come from
+// UpdateSticks(), the helper funcs or an autopilot.  Zero values mean no limit.
+// The limits are applied client-side by scaling down the sticks, so they are approximate: the speeds
+// are estimated from the MVO data, which is only available over a textured surface.
+type Envelope struct {
+	MaxSpeed         float64 // horizontal speed in m/s
+	MaxVerticalSpeed float64 // climb and descent rate in m/s
+	MaxTilt          float64 // pitch and roll in degrees
+}
+
+// WithEnvelope sets the initial flight envelope, see SetEnvelope().
+func WithEnvelope(env Envelope) Option {
+	return func(tello *Tello) { tello.cfg.envelope = env }
+}
+
+// SetEnvelope changes the flight envelope, eg. to enable a 'kid mode' with gentle limits.
+// Envelope{} removes all the limits, including any set by WithEnvelope().
+func (tello *Tello) SetEnvelope(env Envelope) {
+	tello.ctrlMu.Lock()
+	tello.ctrlEnvelope = &env
+	tello.ctrlMu.Unlock()
+}
+
+// envelope returns the flight envelope set by SetEnvelope(), or failing that WithEnvelope().
+// ctrlMu must be held.
+func (tello *Tello) envelope() Envelope {
+	if tello.ctrlEnvelope != nil {
+		return *tello.ctrlEnvelope
+	}
+	return tello.cfg.envelope
+}
+
+// envelopeReadings holds the measurements needed by limitEnvelope().
+type envelopeReadings struct {
+	right, forward float64 // horizontal velocity in m/s, relative to the drone's heading
+	vspeed         float64 // m/s, positive when climbing
+	tilt           float64 // degrees from level
+}
+
+func (tello *Tello) readEnvelope() (er envelopeReadings) {
+	tello.fdMu.RLock()
+	mvo, imu := tello.fd.MVO, tello.fd.IMU
+	tello.fdMu.RUnlock()
+	right, forward := calcXYdeltas(imu.Yaw, 0, 0, float32(mvo.VelocityX), float32(mvo.VelocityY))
+	er.right, er.forward = float64(right)/100, float64(forward)/100
+	er.vspeed = float64(mvo.VelocityZ) / 100
+	pitch, roll, _ := QuatToEulerDeg(imu.QuaternionX, imu.QuaternionY, imu.QuaternionZ, imu.QuaternionW)
+	er.tilt = math.Max(math.Abs(float64(pitch)), math.Abs(float64(roll)))
+	return er
+}
+
+// speedFactor scales a stick down from 1 to 0 as the speed goes from envelopeMargin of the limit to the limit.
+func speedFactor(speed, limit float64) float64 {
+	if limit <= 0 || speed <= limit*envelopeMargin {
+		return 1
+	}
+	return math.Max(0, (limit-speed)/(limit*(1-envelopeMargin)))
+}
+
+// limitEnvelope scales the sticks down to keep within the flight envelope.  ctrlMu must be held.
+// Only the stick inputs which would increase the speed are limited, so the drone can always be slowed.
+func (tello *Tello) limitEnvelope(target stickAxes, er envelopeReadings) stickAxes {
+	env := tello.envelope()
+	if env == (Envelope{}) {
+		return target
+	}
+	// the right stick's push along the direction of travel is scaled down, pushing against or across it is not
+	if speed := math.Hypot(er.right, er.forward); speed > 0 {
+		ur, uf := er.right/speed, er.forward/speed
+		along := float64(target.rx)*ur + float64(target.ry)*uf
+		if k := speedFactor(speed, env.MaxSpeed); k < 1 && along > 0 {
+			cut := along * (1 - k)
+			target.rx = clampStick(float32(float64(target.rx) - cut*ur))
+			target.ry = clampStick(float32(float64(target.ry) - cut*uf))
+		}
+	}
+	if env.MaxTilt > 0 {
+		k := env.MaxTilt / fullStickTilt // the tilt the sticks ask for
+		if er.tilt > env.MaxTilt {
+			k = math.Min(k, env.MaxTilt/er.tilt) // and the tilt we have
+		}
+		if k < 1 {
+			target.rx = int16(math.Round(float64(target.rx) * k))
+			target.ry = int16(math.Round(float64(target.ry) * k))
+		}
+	}
+	// likewise only the throttle in the direction of travel is limited
+	if climbing := target.ly > 0; (climbing && er.vspeed > 0) || (!climbing && er.vspeed < 0) {
+		target.ly = int16(math.Round(float64(target.ly) * speedFactor(math.Abs(er.vspeed), env.MaxVerticalSpeed)))
+	}
+	return target
+}
@@ -0,0 +1,73 @@
+// envelope_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "testing"
+
+func TestLimitEnvelope(t *testing.T) {
+	full := stickAxes{rx: 32767, ry: -32767, lx: 1000, ly: 32767}
+	tests := []struct {
+		name string
+		env  Envelope
+		er   envelopeReadings
+		want stickAxes
+	}{
+		{"no envelope", Envelope{}, envelopeReadings{forward: 9, tilt: 40}, full},
+		{"slow", Envelope{MaxSpeed: 2}, envelopeReadings{forward: -1}, full},
+		{"at speed limit", Envelope{MaxSpeed: 2}, envelopeReadings{forward: -2}, stickAxes{rx: 32767, lx: 1000, ly: 32767}},
+		{"near speed limit", Envelope{MaxSpeed: 4}, envelopeReadings{forward: -3.5}, stickAxes{rx: 32767, ry: -20479, lx: 1000, ly: 32767}},
+		{"braking at speed limit", Envelope{MaxSpeed: 2}, envelopeReadings{forward: 2}, full},
+		{"diagonal at speed limit", Envelope{MaxSpeed: 2}, envelopeReadings{right: 1.2, forward: -1.6}, stickAxes{rx: 5243, ry: 3932, lx: 1000, ly: 32767}},
+		{"tilt cap", Envelope{MaxTilt: 12.5}, envelopeReadings{}, stickAxes{rx: 16384, ry: -16384, lx: 1000, ly: 32767}},
+		{"over tilt", Envelope{MaxTilt: 12.5}, envelopeReadings{tilt: 25}, stickAxes{rx: 16384, ry: -16384, lx: 1000, ly: 32767}},
+		{"well over tilt", Envelope{MaxTilt: 10}, envelopeReadings{tilt: 40}, stickAxes{rx: 8192, ry: -8192, lx: 1000, ly: 32767}},
+		{"climbing too fast", Envelope{MaxVerticalSpeed: 1}, envelopeReadings{vspeed: 1}, stickAxes{rx: 32767, ry: -32767, lx: 1000}},
+	}
+	for _, tt := range tests {
+		drone := NewTello(WithEnvelope(tt.env))
+		if got := drone.limitEnvelope(full, tt.er); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	// the drone can always be slowed down
+	drone := NewTello(WithEnvelope(Envelope{MaxVerticalSpeed: 1}))
+	down := stickAxes{ly: -32767}
+	if got := drone.limitEnvelope(down, envelopeReadings{vspeed: 1}); got != down {
+		t.Errorf("descending while climbing: got %+v", got)
+	}
+	// SetEnvelope() overrides WithEnvelope(), even to remove the limits
+	up := stickAxes{ly: 32767}
+	drone.SetEnvelope(Envelope{})
+	if got := drone.limitEnvelope(up, envelopeReadings{vspeed: 1}); got != up {
+		t.Errorf("climbing without an envelope: got %+v", got)
+	}
+}
+
+func TestReadEnvelope(t *testing.T) {
+	drone := new(Tello)
+	drone.fd.MVO.VelocityX, drone.fd.MVO.VelocityY, drone.fd.MVO.VelocityZ = 30, 40, -20
+	drone.fd.IMU.QuaternionW = 1
+	er := drone.readEnvelope()
+	if er.right != 0.3 || er.forward != 0.4 || er.vspeed != -0.2 || er.tilt != 0 {
+		t.Errorf("got %+v", er)
+	}
+}
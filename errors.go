@@ -0,0 +1,68 @@
+// errors.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors that applications may want to branch on, test for them with errors.Is().
+var (
+	ErrAlreadyConnected = errors.New("Tello already connected")
+	ErrConnecting       = errors.New("Tello connection attempt already in progress")
+	ErrNotConnected     = errors.New("Tello not connected")
+	ErrTimeout          = errors.New("Timeout waiting for Tello")
+	ErrBadPacket        = errors.New("Bad packet from Tello")
+	ErrAirborne         = errors.New("Tello is airborne")
+	ErrDisarmed         = errors.New("Tello is disarmed")
+)
+
+// TimeoutError reports what we were waiting for when the Tello failed to respond.
+// errors.Is(err, ErrTimeout) is true for every TimeoutError.
+type TimeoutError struct {
+	Op string // what we were waiting for, eg. "acknowledgement"
+}
+
+func (e *TimeoutError) Error() string {
+	return "Timeout waiting for " + e.Op + " from Tello"
+}
+
+// Is makes a TimeoutError match ErrTimeout.
+func (e *TimeoutError) Is(target error) bool { return target == ErrTimeout }
+
+// Timeout always returns true, as for net.Error.
+func (e *TimeoutError) Timeout() bool { return true }
+
+// PacketError reports a malformed packet received from the Tello.
+// errors.Is(err, ErrBadPacket) is true for every PacketError.
+type PacketError struct {
+	Reason string // what is wrong with the packet
+	Data   []byte // the raw packet
+}
+
+func (e *PacketError) Error() string {
+	return fmt.Sprintf("Bad packet from Tello, %s: % x", e.Reason, e.Data)
+}
+
+// Is makes a PacketError match ErrBadPacket.
+func (e *PacketError) Is(target error) bool { return target == ErrBadPacket }
@@ -0,0 +1,79 @@
+// errors_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParsePacket(t *testing.T) {
+	good := packetToBuffer(newPacket(ptData2, MsgFlightStatus, 3, 24))
+	pkt, err := parsePacket(good)
+	if err != nil {
+		t.Fatalf("Expected a good packet to parse, got %v", err)
+	}
+	if pkt.messageID != MsgFlightStatus || pkt.sequence != 3 || len(pkt.payload) != 24 {
+		t.Errorf("Unexpected packet %+v", pkt)
+	}
+
+	corrupt := append([]byte(nil), good...)
+	corrupt[12] ^= 0xff
+	badHeader := append([]byte(nil), good...)
+	badHeader[0] = 0
+	for name, buff := range map[string][]byte{
+		"short":     good[:5],
+		"truncated": good[:len(good)-1],
+		"header":    badHeader,
+		"crc":       corrupt,
+	} {
+		_, err := parsePacket(buff)
+		var pe *PacketError
+		if !errors.Is(err, ErrBadPacket) || !errors.As(err, &pe) {
+			t.Errorf("%s: expected a PacketError, got %v", name, err)
+		}
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	drone := new(Tello)
+	if _, err := drone.sendAndWait(context.Background(), ptSet, MsgDoTakeoff, nil); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+	drone.setCtrlState(connConnecting)
+	if err := drone.ControlConnect("127.0.0.1", 8889, 0); !errors.Is(err, ErrConnecting) {
+		t.Errorf("Expected ErrConnecting, got %v", err)
+	}
+	drone.setCtrlState(connConnected)
+	if err := drone.ControlConnect("127.0.0.1", 8889, 0); !errors.Is(err, ErrAlreadyConnected) {
+		t.Errorf("Expected ErrAlreadyConnected, got %v", err)
+	}
+
+	var err error = &TimeoutError{Op: "acknowledgement"}
+	if !errors.Is(err, ErrTimeout) {
+		t.Error("Expected a TimeoutError to match ErrTimeout")
+	}
+	if err.Error() != "Timeout waiting for acknowledgement from Tello" {
+		t.Errorf("Unexpected message %q", err)
+	}
+}
@@ -0,0 +1,125 @@
+// events.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType identifies the kind of an Event.
+type EventType int
+
+// Event types...
+const (
+	EvFlightState     EventType = iota // the high-level flight state has changed, Data is a FlightStateChange
+	EvCommandRefused                   // the drone refused a command, Data is a *CommandError
+	EvBatteryReserve                   // the estimated flight time left has reached the reserve, Data is the time.Duration left
+	EvOverheat                         // the drone's temperature warning has been raised or cleared, Data is true when raised
+	EvWindWarning                      // the drone's wind warning has been raised or cleared, Data is true when raised
+	EvIMUWarning                       // the drone has reported an IMU problem, or that it is resolved, Data is true when raised
+	EvListenerPanic                    // a listener Goroutine panicked and its connection has been closed, Data is a *ListenerPanic
+	EvManualNeutral                    // Hover() has stopped all automatic flight and zeroed the sticks, Data is nil
+	EvCalibration                      // a calibration has started, progressed, finished or been refused, Data is a CalibrationEvent
+	EvVideoBitrate                     // the adaptive bitrate controller has changed the video bitrate, Data is the new VBR
+	EvVideoSinkFailed                  // a video sink has returned an error and been closed, Data is its VideoSinkStatus
+	EvPhoto                            // a picture has been accepted, refused, received or lost in transfer, Data is a PhotoEvent
+	EvFlightLog                        // a flight log has been saved after landing, Data is a FlightLogSaved
+	EvStaleTelemetry                   // a category of telemetry has stopped arriving, or resumed, Data is a StaleTelemetry
+	EvDisconnected                     // the control connection has closed, Data is true if contact was lost
+)
+
+var eventTypeNames = [...]string{"FlightState", "CommandRefused", "BatteryReserve", "Overheat",
+	"WindWarning", "IMUWarning", "ListenerPanic", "ManualNeutral", "Calibration", "VideoBitrate", "VideoSinkFailed",
+	"Photo", "FlightLog", "StaleTelemetry", "Disconnected"}
+
+func (et EventType) String() string {
+	if et < 0 || int(et) >= len(eventTypeNames) {
+		return fmt.Sprintf("EventType(%d)", int(et))
+	}
+	return eventTypeNames[et]
+}
+
+// Event is a notification of something happening on the Tello.
+type Event struct {
+	Type EventType
+	Time time.Time
+	Data interface{} // depends on Type, see the EventType constants
+}
+
+const eventChanSize = 10
+
+// ListenEvents returns a channel that will receive Events as they occur, and a function to stop listening.
+// N.B. Events are not queued indefinitely, if the channel is not consumed they are lost.
+func (tello *Tello) ListenEvents() (<-chan Event, func()) {
+	tello.evMu.Lock()
+	defer tello.evMu.Unlock()
+	if tello.evListeners == nil {
+		tello.evListeners = map[chan Event]chan Event{}
+	}
+	res := make(chan Event, eventChanSize)
+	tello.evListeners[res] = res
+	return res, func() {
+		tello.evMu.Lock()
+		defer tello.evMu.Unlock()
+		if _, present := tello.evListeners[res]; present {
+			delete(tello.evListeners, res)
+			close(res)
+		}
+	}
+}
+
+// String returns a one-line description of the event, eg. "Tello Grounded -> TakingOff".
+func (ev Event) String() string {
+	switch d := ev.Data.(type) {
+	case error:
+		return fmt.Sprintf("Tello %s: %v", ev.Type, d)
+	case FlightStateChange:
+		return fmt.Sprintf("Tello %s -> %s", d.From, d.To)
+	case time.Duration:
+		return fmt.Sprintf("Tello %s: %v left", ev.Type, d.Round(time.Second))
+	case bool:
+		switch {
+		case ev.Type == EvDisconnected && d:
+			return "Tello lost contact"
+		case ev.Type == EvDisconnected:
+			return "Tello disconnected"
+		}
+		return fmt.Sprintf("Tello %s: %v", ev.Type, d)
+	case nil:
+		return "Tello " + ev.Type.String()
+	}
+	return fmt.Sprintf("Tello %s: %+v", ev.Type, ev.Data)
+}
+
+// emitEvent sends an Event to every listener without blocking.
+func (tello *Tello) emitEvent(et EventType, data interface{}) {
+	ev := Event{Type: et, Time: time.Now(), Data: data}
+	tello.evMu.RLock()
+	for l := range tello.evListeners {
+		select {
+		case l <- ev:
+		default:
+		}
+	}
+	tello.evMu.RUnlock()
+}
@@ -23,9 +23,20 @@
 
 package tello
 
+import (
+	"context"
+	"time"
+)
+
 // TakeOff sends a normal takeoff request to the Tello.
 // Any previously set origin is invalidated.
+// Nothing is sent if the Tello is disarmed or the pre-flight gate is closed, see WithArmInterlock()
+// and WithPreflightGate().
 func (tello *Tello) TakeOff() {
+	if err := tello.takeOffGate(); err != nil {
+		tello.logf("Warning: not taking off - %v\n", err)
+		return
+	}
 	tello.ctrlMu.Lock()
 
 	tello.autoXYMu.Lock()
@@ -33,15 +44,36 @@ func (tello *Tello) TakeOff() {
 	tello.autoXYMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgDoTakeoff, tello.ctrlSeq, 0)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	pkt := newPacket(ptSet, MsgDoTakeoff, tello.ctrlSeq, 0)
+	tello.enqueue(packetToBuffer(pkt))
 
 	tello.ctrlMu.Unlock()
 }
 
+// TakeOffAndWait sends a normal takeoff request to the Tello and waits for it to be acknowledged,
+// resending it if necessary.  It returns early with an error if ctx is done, and returns a
+// *CommandError if the Tello refuses to take off, ErrDisarmed if the Tello is disarmed, or a
+// *PreflightError if the pre-flight gate is closed.
+// Any previously set origin is invalidated.
+func (tello *Tello) TakeOffAndWait(ctx context.Context) (err error) {
+	if err := tello.takeOffGate(); err != nil {
+		return err
+	}
+	tello.autoXYMu.Lock()
+	tello.homeValid = false // origin is invalidated until flying and reset
+	tello.autoXYMu.Unlock()
+
+	return tello.commandAndWait(ctx, ptSet, MsgDoTakeoff, nil)
+}
+
 // ThrowTakeOff initiates a 'throw and go' launch.
 // Any previously set origin is invalidated.
+// Nothing is sent if the Tello is disarmed or the pre-flight gate is closed, see TakeOff().
 func (tello *Tello) ThrowTakeOff() {
+	if err := tello.takeOffGate(); err != nil {
+		tello.logf("Warning: not taking off - %v\n", err)
+		return
+	}
 	tello.ctrlMu.Lock()
 
 	tello.autoXYMu.Lock()
@@ -49,32 +81,75 @@ func (tello *Tello) ThrowTakeOff() {
 	tello.autoXYMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptGet, msgDoThrowTakeoff, tello.ctrlSeq, 0)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	pkt := newPacket(ptGet, MsgDoThrowTakeoff, tello.ctrlSeq, 0)
+	tello.enqueue(packetToBuffer(pkt))
 
 	tello.ctrlMu.Unlock()
 }
 
+// ThrowTakeOffCtx is as ThrowTakeOff() but waits for the Tello to acknowledge it, see TakeOffAndWait().
+func (tello *Tello) ThrowTakeOffCtx(ctx context.Context) (err error) {
+	if err := tello.takeOffGate(); err != nil {
+		return err
+	}
+	tello.autoXYMu.Lock()
+	tello.homeValid = false // origin is invalidated until flying and reset
+	tello.autoXYMu.Unlock()
+
+	return tello.commandAndWait(ctx, ptGet, MsgDoThrowTakeoff, nil)
+}
+
 // Land sends a normal Land request to the Tello.
 func (tello *Tello) Land() {
 	tello.ctrlMu.Lock()
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgDoLand, tello.ctrlSeq, 1)
-	pkt.payload[0] = 0 // see StopLanding() for use of this field
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	pkt := newPacket(ptSet, MsgDoLand, tello.ctrlSeq, 1)
+	pkt.payload[0] = 0 // see CancelLanding() for use of this field
+	tello.ctrlStopLanding = false
+	tello.enqueue(packetToBuffer(pkt))
 }
 
-// StopLanding cancels a land command.
-func (tello *Tello) StopLanding() {
+// LandAndWait sends a normal Land request to the Tello and waits for it to be acknowledged,
+// resending it if necessary.  It returns early with an error if ctx is done, and returns a
+// *CommandError if the Tello refuses to land.
+func (tello *Tello) LandAndWait(ctx context.Context) (err error) {
+	tello.ctrlMu.Lock()
+	tello.ctrlStopLanding = false
+	tello.ctrlMu.Unlock()
+	return tello.commandAndWait(ctx, ptSet, MsgDoLand, []byte{0})
+}
+
+// CancelLanding aborts a landing in progress, eg. when you notice the drone is descending
+// into water; the drone should then hover.  It uses the same message as Land() with the
+// 'stop' flag set, so it is not held up by other queued commands.
+func (tello *Tello) CancelLanding() {
 	tello.ctrlMu.Lock()
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgDoLand, tello.ctrlSeq, 1)
+	pkt := newPacket(ptSet, MsgDoLand, tello.ctrlSeq, 1)
 	pkt.payload[0] = 1
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.ctrlStopLanding = true
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// CancelLandingAndWait aborts a landing in progress and waits for the Tello to acknowledge it,
+// resending it if necessary.  It returns early with an error if ctx is done, and returns a
+// *CommandError if the Tello refuses.
+func (tello *Tello) CancelLandingAndWait(ctx context.Context) (err error) {
+	tello.ctrlMu.Lock()
+	tello.ctrlStopLanding = true
+	tello.ctrlMu.Unlock()
+	return tello.commandAndWait(ctx, ptSet, MsgDoLand, []byte{1})
+}
+
+// StopLanding cancels a land command.
+//
+// Deprecated: use CancelLanding.
+func (tello *Tello) StopLanding() {
+	tello.CancelLanding()
 }
 
 // PalmLand initiates a Palm Landing.
@@ -83,37 +158,79 @@ func (tello *Tello) PalmLand() {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgDoPalmLand, tello.ctrlSeq, 1)
+	pkt := newPacket(ptSet, MsgDoPalmLand, tello.ctrlSeq, 1)
 	pkt.payload[0] = 0
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// PalmLandCtx is as PalmLand() but waits for the Tello to acknowledge it, see TakeOffAndWait().
+func (tello *Tello) PalmLandCtx(ctx context.Context) (err error) {
+	return tello.commandAndWait(ctx, ptSet, MsgDoPalmLand, []byte{0})
 }
 
 // Bounce toggles the bouncing mode of the Tello.
+// Our idea of the mode only changes when the Tello acknowledges the request.
 func (tello *Tello) Bounce() {
 	tello.ctrlMu.Lock()
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgDoBounce, tello.ctrlSeq, 1)
+	pkt := newPacket(ptSet, MsgDoBounce, tello.ctrlSeq, 1)
+	pkt.payload[0] = tello.bounceRequest()
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// BounceCtx is as Bounce() but waits for the Tello to acknowledge it, see TakeOffAndWait().
+func (tello *Tello) BounceCtx(ctx context.Context) (err error) {
+	tello.ctrlMu.Lock()
+	mode := tello.bounceRequest()
+	tello.ctrlMu.Unlock()
+	return tello.commandAndWait(ctx, ptSet, MsgDoBounce, []byte{mode})
+}
+
+// bounceRequest returns the payload to toggle the bouncing mode, and notes it for bounceAck().
+// ctrlMu must be held.
+func (tello *Tello) bounceRequest() byte {
+	tello.ctrlBounceReq = 0x30
 	if tello.ctrlBouncing {
-		pkt.payload[0] = 0x31
-		tello.ctrlBouncing = false
-	} else {
-		pkt.payload[0] = 0x30
-		tello.ctrlBouncing = true
+		tello.ctrlBounceReq = 0x31
 	}
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	return tello.ctrlBounceReq
+}
+
+// bounceAck records the bouncing mode last requested once the Tello has accepted it.
+func (tello *Tello) bounceAck(pkt packet) {
+	if !tello.checkCommandResult(pkt) {
+		return
+	}
+	tello.ctrlMu.Lock()
+	tello.ctrlBouncing = tello.ctrlBounceReq == 0x30
+	tello.ctrlMu.Unlock()
 }
 
 // Flip sends a flip flight command to the Tello.
+// Nothing is sent if the Tello is disarmed, see WithArmInterlock().
 func (tello *Tello) Flip(dir FlipType) {
 	tello.ctrlMu.Lock()
 	defer tello.ctrlMu.Unlock()
+	if tello.ctrlDisarmed {
+		tello.logf("Warning: not flipping - %v\n", ErrDisarmed)
+		return
+	}
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptFlip, msgDoFlip, tello.ctrlSeq, 1)
+	pkt := newPacket(ptFlip, MsgDoFlip, tello.ctrlSeq, 1)
 	pkt.payload[0] = byte(dir)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// FlipCtx is as Flip() but waits for the Tello to acknowledge it, see TakeOffAndWait().
+// N.B. The ack arrives when the flip starts, not when it is complete.
+func (tello *Tello) FlipCtx(ctx context.Context, dir FlipType) (err error) {
+	if err := tello.armGate(); err != nil {
+		return err
+	}
+	return tello.commandAndWait(ctx, ptFlip, MsgDoFlip, []byte{byte(dir)})
 }
 
 // StartSmartVideo begins a preprogrammed 'smart video' flight action.
@@ -122,33 +239,71 @@ func (tello *Tello) StartSmartVideo(cmd SvCmd) {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgDoSmartVideo, tello.ctrlSeq, 1)
+	pkt := newPacket(ptSet, MsgDoSmartVideo, tello.ctrlSeq, 1)
 	pkt.payload[0] = byte(cmd) | 0x01
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.enqueue(packetToBuffer(pkt))
+	tello.ctrlSmartVideo = cmd
+}
+
+// StartSmartVideoCtx is as StartSmartVideo() but waits for the Tello to acknowledge it, see TakeOffAndWait().
+func (tello *Tello) StartSmartVideoCtx(ctx context.Context, cmd SvCmd) (err error) {
+	tello.ctrlMu.Lock()
+	tello.ctrlSmartVideo = cmd
+	tello.ctrlMu.Unlock()
+	return tello.commandAndWait(ctx, ptSet, MsgDoSmartVideo, []byte{byte(cmd) | 0x01})
 }
 
-// StopSmartVideo begins a preprogrammed 'smart video' flight action.
+// StopSmartVideo ends a preprogrammed 'smart video' flight action.
 func (tello *Tello) StopSmartVideo(cmd SvCmd) {
 	tello.ctrlMu.Lock()
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgDoSmartVideo, tello.ctrlSeq, 1)
+	pkt := newPacket(ptSet, MsgDoSmartVideo, tello.ctrlSeq, 1)
 	pkt.payload[0] = byte(cmd)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.enqueue(packetToBuffer(pkt))
+	tello.ctrlSmartVideo = 0
+}
+
+// StopSmartVideoCtx is as StopSmartVideo() but waits for the Tello to acknowledge it, see TakeOffAndWait().
+func (tello *Tello) StopSmartVideoCtx(ctx context.Context, cmd SvCmd) (err error) {
+	tello.ctrlMu.Lock()
+	tello.ctrlSmartVideo = 0
+	tello.ctrlMu.Unlock()
+	return tello.commandAndWait(ctx, ptSet, MsgDoSmartVideo, []byte{byte(cmd)})
 }
 
 // *** The following are 'macro' commands which are here purely
 // *** to make the Tello easier to use in some circumstances.
 
-// Hover simply sets the sticks to zero which should halt all motion - useful as a panic action!
+// Hover halts all motion - useful as a panic action!
+// Any automatic flight is cancelled, a smart video manoeuvre started by StartSmartVideo() is stopped,
+// queued commands are discarded and the sticks are zeroed and sent at once.
+// An EvManualNeutral Event confirms that the drone is now under manual control with neutral sticks.
 func (tello *Tello) Hover() {
+	tello.CancelAutoFlyToHeight()
+	tello.CancelAutoTurn()
+	tello.CancelAutoFlyToXY()
+	tello.CancelAutoOrbit()
+	tello.CancelFollow()
+	tello.CancelReturnToHome()
+
 	tello.ctrlMu.Lock()
 	tello.ctrlLx = 0
 	tello.ctrlLy = 0
 	tello.ctrlRx = 0
 	tello.ctrlRy = 0
+	tello.ctrlSent = stickAxes{} // no ramping down, stop now
+	tello.ctrlSticksExpire = time.Time{}
+	sv := tello.ctrlSmartVideo
+	tello.sendQ.flush()
 	tello.ctrlMu.Unlock()
+
+	if sv != 0 {
+		tello.StopSmartVideo(sv)
+	}
+	tello.sendStickUpdate()
+	tello.emitEvent(EvManualNeutral, nil)
 }
 
 // Forward tells the drone to start moving forward at a given speed between 0 and 100.
@@ -0,0 +1,154 @@
+// flightCommands_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ackingDrone starts a fake drone which acknowledges every command, and a Tello connected to it.
+func ackingDrone(t *testing.T) *Tello {
+	drone, fake := pushingDrone(t)
+	fake.serve(func(pkt packet) { fake.reply(pkt, 0) })
+	return drone
+}
+
+func TestHover(t *testing.T) {
+	drone := new(Tello)
+	drone.sendQ.running = true // queue packets, but with no writer to send them
+	events, stop := drone.ListenEvents()
+	defer stop()
+
+	drone.GetSSID()
+	drone.Land()
+	drone.StartSmartVideo(Sv360)
+	drone.UpdateSticks(StickMessage{Rx: 10000, Lx: -10000})
+	drone.autoYaw, drone.autoXY, drone.autoHeight, drone.autoOrbit, drone.autoFollow = true, true, true, true, true
+
+	drone.Hover()
+
+	if drone.IsAutoTurning() || drone.IsAutoXY() || drone.IsAutoHeight() || drone.IsAutoOrbiting() || drone.IsFollowing() {
+		t.Error("Expected all automatic flight to be cancelled")
+	}
+	q := &drone.sendQ
+	if len(q.queues[prioQuery]) != 0 {
+		t.Error("Expected queued queries to be discarded")
+	}
+	if len(q.queues[prioEmergency]) != 1 {
+		t.Error("Expected the queued landing to be kept")
+	}
+	if cmds := q.queues[prioCommand]; len(cmds) != 1 {
+		t.Errorf("Expected only the smart video stop to be queued, got %d commands", len(cmds))
+	} else if pkt := bufferToPacket(cmds[0]); pkt.messageID != MsgDoSmartVideo || pkt.payload[0] != byte(Sv360) {
+		t.Errorf("Expected a smart video stop, got %+v", pkt)
+	}
+	if sticks := q.queues[prioStick]; len(sticks) != 1 {
+		t.Error("Expected a stick update to be queued")
+	} else if pkt := bufferToPacket(sticks[0]); pkt.payload[0] != 0x00 || pkt.payload[1] != 0x04 {
+		t.Errorf("Expected neutral sticks, got % x", pkt.payload)
+	}
+	select {
+	case ev := <-events:
+		if ev.Type != EvManualNeutral {
+			t.Errorf("Expected EvManualNeutral, got %v", ev.Type)
+		}
+	default:
+		t.Error("Expected an EvManualNeutral event")
+	}
+}
+
+func TestCancelLanding(t *testing.T) {
+	drone := ackingDrone(t)
+	drone.updateFlightState(func(FlightState) FlightState { return StateHovering })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := drone.LandAndWait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the state changes just after the ack is passed on
+	if err := drone.WaitFor(ctx, func(fd FlightData) bool { return fd.State == StateLanding }); err != nil {
+		t.Fatalf("Expected to be landing, got %v", err)
+	}
+	if err := drone.CancelLandingAndWait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := drone.WaitFor(ctx, func(fd FlightData) bool { return fd.State == StateHovering }); err != nil {
+		t.Errorf("Expected the landing to be cancelled, got %v", err)
+	}
+}
+
+func TestCtxVariants(t *testing.T) {
+	drone := ackingDrone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cmds := map[string]func(context.Context) error{
+		"ThrowTakeOff":           drone.ThrowTakeOffCtx,
+		"PalmLand":               drone.PalmLandCtx,
+		"Bounce":                 drone.BounceCtx,
+		"TakePicture":            drone.TakePictureCtx,
+		"SetVideoWide":           drone.SetVideoWideCtx,
+		"SetVideoNormal":         drone.SetVideoNormalCtx,
+		"Flip":                   func(ctx context.Context) error { return drone.FlipCtx(ctx, FlipBackward) },
+		"SetVideoBitrate":        func(ctx context.Context) error { return drone.SetVideoBitrateCtx(ctx, Vbr2M) },
+		"SetLowBatteryThreshold": func(ctx context.Context) error { return drone.SetLowBatteryThresholdCtx(ctx, 25) },
+		"StartSmartVideo":        func(ctx context.Context) error { return drone.StartSmartVideoCtx(ctx, Sv360) },
+		"StopSmartVideo":         func(ctx context.Context) error { return drone.StopSmartVideoCtx(ctx, Sv360) },
+	}
+	for name, cmd := range cmds {
+		if err := cmd(ctx); err != nil {
+			t.Errorf("%sCtx: %v", name, err)
+		}
+	}
+	if !drone.ctrlBouncing {
+		t.Error("Expected BounceCtx to toggle bouncing on")
+	}
+
+	cancelled, stop := context.WithCancel(context.Background())
+	stop()
+	if err := drone.PalmLandCtx(cancelled); err == nil {
+		t.Error("Expected an error when the context is already cancelled")
+	}
+}
+
+func TestBounceOnAck(t *testing.T) {
+	drone, fake := pushingDrone(t)
+	var result int32 = 1
+	fake.serve(func(pkt packet) { fake.reply(pkt, byte(atomic.LoadInt32(&result))) })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := drone.BounceCtx(ctx); err == nil || drone.ctrlBouncing {
+		t.Errorf("Expected a refused bounce to leave bouncing off, got %v", err)
+	}
+	atomic.StoreInt32(&result, 0)
+	if err := drone.BounceCtx(ctx); err != nil || !drone.ctrlBouncing {
+		t.Errorf("Expected bouncing on once acknowledged, got %v", err)
+	}
+	if err := drone.BounceCtx(ctx); err != nil || drone.ctrlBouncing {
+		t.Errorf("Expected bouncing off once acknowledged, got %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+// flightdata.go
+
+// Serialisation of FlightData for logging and downstream services.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+)
+
+// FlightDataSchemaVersion is incremented whenever FlightData fields are renamed or removed, or the
+// binary layout changes.  It is sent as "schema_version" in the JSON and as the first byte of the binary form.
+const FlightDataSchemaVersion = 4
+
+// MarshalJSON adds the schema version to the standard encoding of FlightData.
+func (fd FlightData) MarshalJSON() ([]byte, error) {
+	type plain FlightData // drop our methods to avoid recursion
+	return json.Marshal(struct {
+		SchemaVersion int `json:"schema_version"`
+		plain
+	}{FlightDataSchemaVersion, plain(fd)})
+}
+
+// MarshalText encodes a FlightState by name, eg. in JSON.
+func (fs FlightState) MarshalText() ([]byte, error) {
+	return []byte(fs.String()), nil
+}
+
+// UnmarshalText decodes a FlightState encoded by MarshalText().
+func (fs *FlightState) UnmarshalText(text []byte) error {
+	for i, name := range flightStateNames {
+		if name == string(text) {
+			*fs = FlightState(i)
+			return nil
+		}
+	}
+	return errors.New("Unknown FlightState: " + string(text))
+}
+
+// MarshalBinary encodes FlightData in a compact little-endian form suitable for high-rate logging.
+func (fd FlightData) MarshalBinary() ([]byte, error) {
+	w := binWriter{buf: make([]byte, 0, 256)}
+	w.u8(FlightDataSchemaVersion)
+	w.time(fd.ActivationTime)
+	w.bools(fd.BatteryCritical, fd.BatteryLow, fd.BatteryState, fd.DownVisualState,
+		fd.DroneHover, fd.EmOpen, fd.ErrorState, fd.FactoryMode)
+	w.bools(fd.Flying, fd.FrontIn, fd.FrontLSC, fd.FrontOut,
+		fd.GravityState, fd.ImuState, fd.OnGround, fd.OutageRecording)
+	w.bools(fd.PowerState, fd.PressureState, fd.TemperatureHigh, fd.WindState)
+	w.i16(fd.BatteryMilliVolts)
+	w.u8(uint8(fd.BatteryPercentage))
+	w.u8(fd.CameraState)
+	w.i16(fd.DroneFlyTimeLeft)
+	w.i16(fd.EastSpeed)
+	w.u8(fd.ElectricalMachineryState)
+	w.u8(fd.FlyMode)
+	w.i16(fd.FlyTime)
+	w.i16(fd.GroundSpeed)
+	w.f32(fd.GroundSpeedSmoothed)
+	w.i16(fd.Height)
+	w.f32(fd.HeightSmoothed)
+	w.f32(fd.IMU.QuaternionW)
+	w.f32(fd.IMU.QuaternionX)
+	w.f32(fd.IMU.QuaternionY)
+	w.f32(fd.IMU.QuaternionZ)
+	w.i16(fd.IMU.Temperature)
+	w.f32(fd.IMU.Yaw)
+	w.f32(fd.IMU.BaroAltitude)
+	w.u8(uint8(fd.ImuCalibrationState))
+	w.u8(fd.LightStrength)
+	w.time(fd.LightStrengthUpdated)
+	w.u8(fd.LowBatteryThreshold)
+	w.u8(fd.MaxHeight)
+	w.i16(int16(fd.MissionPad.ID))
+	w.i16(fd.MissionPad.X)
+	w.i16(fd.MissionPad.Y)
+	w.i16(fd.MissionPad.Z)
+	w.i16(fd.MissionPad.Pitch)
+	w.i16(fd.MissionPad.Roll)
+	w.i16(fd.MissionPad.Yaw)
+	w.time(fd.MissionPad.Updated)
+	w.f32(fd.MVO.PositionX)
+	w.f32(fd.MVO.PositionY)
+	w.f32(fd.MVO.PositionZ)
+	w.i16(fd.MVO.VelocityX)
+	w.i16(fd.MVO.VelocityY)
+	w.i16(fd.MVO.VelocityZ)
+	w.i16(fd.NorthSpeed)
+	w.str(fd.SerialNumber)
+	w.f32(fd.SessionDistance)
+	w.i64(int64(fd.SessionFlyTime))
+	w.i16(fd.SmartVideoExitMode)
+	w.str(fd.SSID)
+	w.u8(uint8(fd.State))
+	w.u8(uint8(fd.ThrowFlyTimer))
+	w.i16(fd.ToF)
+	w.f32(fd.TotalDistance)
+	w.i64(int64(fd.TotalFlyTime))
+	w.str(fd.Version)
+	w.i16(fd.VerticalSpeed)
+	w.f32(fd.VerticalSpeedSmoothed)
+	w.u8(uint8(fd.VideoBitrate))
+	w.u8(fd.WifiInterference)
+	w.str(fd.WifiRegion)
+	w.u8(fd.WifiStrength)
+	w.f32(fd.WifiStrengthSmoothed)
+	return w.buf, nil
+}
+
+// UnmarshalBinary decodes FlightData encoded by MarshalBinary().
+func (fd *FlightData) UnmarshalBinary(data []byte) error {
+	r := binReader{buf: data}
+	if v := r.u8(); v != FlightDataSchemaVersion {
+		return errors.New("Unsupported FlightData schema version")
+	}
+	var f FlightData
+	f.ActivationTime = r.time()
+	r.bools(&f.BatteryCritical, &f.BatteryLow, &f.BatteryState, &f.DownVisualState,
+		&f.DroneHover, &f.EmOpen, &f.ErrorState, &f.FactoryMode)
+	r.bools(&f.Flying, &f.FrontIn, &f.FrontLSC, &f.FrontOut,
+		&f.GravityState, &f.ImuState, &f.OnGround, &f.OutageRecording)
+	r.bools(&f.PowerState, &f.PressureState, &f.TemperatureHigh, &f.WindState)
+	f.BatteryMilliVolts = r.i16()
+	f.BatteryPercentage = int8(r.u8())
+	f.CameraState = r.u8()
+	f.DroneFlyTimeLeft = r.i16()
+	f.EastSpeed = r.i16()
+	f.ElectricalMachineryState = r.u8()
+	f.FlyMode = r.u8()
+	f.FlyTime = r.i16()
+	f.GroundSpeed = r.i16()
+	f.GroundSpeedSmoothed = r.f32()
+	f.Height = r.i16()
+	f.HeightSmoothed = r.f32()
+	f.IMU.QuaternionW = r.f32()
+	f.IMU.QuaternionX = r.f32()
+	f.IMU.QuaternionY = r.f32()
+	f.IMU.QuaternionZ = r.f32()
+	f.IMU.Temperature = r.i16()
+	f.IMU.Yaw = r.f32()
+	f.IMU.BaroAltitude = r.f32()
+	f.ImuCalibrationState = int8(r.u8())
+	f.LightStrength = r.u8()
+	f.LightStrengthUpdated = r.time()
+	f.LowBatteryThreshold = r.u8()
+	f.MaxHeight = r.u8()
+	f.MissionPad.ID = int(r.i16())
+	f.MissionPad.X = r.i16()
+	f.MissionPad.Y = r.i16()
+	f.MissionPad.Z = r.i16()
+	f.MissionPad.Pitch = r.i16()
+	f.MissionPad.Roll = r.i16()
+	f.MissionPad.Yaw = r.i16()
+	f.MissionPad.Updated = r.time()
+	f.MVO.PositionX = r.f32()
+	f.MVO.PositionY = r.f32()
+	f.MVO.PositionZ = r.f32()
+	f.MVO.VelocityX = r.i16()
+	f.MVO.VelocityY = r.i16()
+	f.MVO.VelocityZ = r.i16()
+	f.NorthSpeed = r.i16()
+	f.SerialNumber = r.str()
+	f.SessionDistance = r.f32()
+	f.SessionFlyTime = time.Duration(r.i64())
+	f.SmartVideoExitMode = r.i16()
+	f.SSID = r.str()
+	f.State = FlightState(r.u8())
+	f.ThrowFlyTimer = int8(r.u8())
+	f.ToF = r.i16()
+	f.TotalDistance = r.f32()
+	f.TotalFlyTime = time.Duration(r.i64())
+	f.Version = r.str()
+	f.VerticalSpeed = r.i16()
+	f.VerticalSpeedSmoothed = r.f32()
+	f.VideoBitrate = VBR(r.u8())
+	f.WifiInterference = r.u8()
+	f.WifiRegion = r.str()
+	f.WifiStrength = r.u8()
+	f.WifiStrengthSmoothed = r.f32()
+	if r.short {
+		return errors.New("FlightData binary data is truncated")
+	}
+	*fd = f
+	return nil
+}
+
+type binWriter struct {
+	buf []byte
+}
+
+func (w *binWriter) u8(v uint8) { w.buf = append(w.buf, v) }
+
+func (w *binWriter) i16(v int16) { w.buf = append(w.buf, byte(v), byte(uint16(v)>>8)) }
+
+func (w *binWriter) i64(v int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(v))
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *binWriter) f32(v float32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], math.Float32bits(v))
+	w.buf = append(w.buf, b[:]...)
+}
+
+// bools packs up to 8 flags into a single byte
+func (w *binWriter) bools(flags ...bool) {
+	var b byte
+	for i, f := range flags {
+		if f {
+			b |= 1 << uint(i)
+		}
+	}
+	w.u8(b)
+}
+
+// time is encoded as Unix nanoseconds, with 0 for the zero Time
+func (w *binWriter) time(t time.Time) {
+	if t.IsZero() {
+		w.i64(0)
+		return
+	}
+	w.i64(t.UnixNano())
+}
+
+// str is encoded as a single length byte followed by (at most 255 bytes of) the string
+func (w *binWriter) str(s string) {
+	if len(s) > math.MaxUint8 {
+		s = s[:math.MaxUint8]
+	}
+	w.u8(uint8(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+type binReader struct {
+	buf   []byte
+	short bool // set if we tried to read past the end of buf
+}
+
+func (r *binReader) next(n int) []byte {
+	if len(r.buf) < n {
+		r.short = true
+		r.buf = nil
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *binReader) u8() uint8 { return r.next(1)[0] }
+
+func (r *binReader) i16() int16 { return int16(binary.LittleEndian.Uint16(r.next(2))) }
+
+func (r *binReader) i64() int64 { return int64(binary.LittleEndian.Uint64(r.next(8))) }
+
+func (r *binReader) f32() float32 { return math.Float32frombits(binary.LittleEndian.Uint32(r.next(4))) }
+
+func (r *binReader) bools(flags ...*bool) {
+	b := r.u8()
+	for i, f := range flags {
+		*f = b&(1<<uint(i)) != 0
+	}
+}
+
+func (r *binReader) time() time.Time {
+	ns := r.i64()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+func (r *binReader) str() string {
+	return string(r.next(int(r.u8())))
+}
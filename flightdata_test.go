@@ -0,0 +1,212 @@
+// tello project flightdata_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sampleFlightData() FlightData {
+	return FlightData{
+		ActivationTime:       time.Unix(1540000000, 0),
+		BatteryLow:           true,
+		BatteryPercentage:    42,
+		Flying:               true,
+		WindState:            true,
+		Height:               -3,
+		HeightSmoothed:       -2.5,
+		IMU:                  IMUData{QuaternionW: 1, Yaw: -90.5, Temperature: 61, BaroAltitude: 12.5},
+		LightStrengthUpdated: time.Unix(1600000000, 123),
+		MissionPad:           MissionPad{ID: MissionPadNone, X: -20},
+		MVO:                  MVOData{PositionX: 1.5, VelocityZ: -7},
+		SessionFlyTime:       90 * time.Second,
+		SSID:                 "TELLO-ABCDEF",
+		SerialNumber:         "0TQDG7REDB0N8X",
+		State:                StateHovering,
+		TotalDistance:        123.25,
+		Version:              "01.04.92.01",
+		VideoBitrate:         Vbr2M,
+		WifiRegion:           "GB",
+		WifiStrength:         90,
+		WifiStrengthSmoothed: 88.5,
+	}
+}
+
+func TestFlightDataBinary(t *testing.T) {
+	fd := sampleFlightData()
+	b, err := fd.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got FlightData
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fd, got) {
+		t.Errorf("Round trip mismatch\nsent: %+v\ngot:  %+v", fd, got)
+	}
+	if err := got.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Error("Expected an error for truncated data")
+	}
+	b[0]++
+	if err := got.UnmarshalBinary(b); err == nil {
+		t.Error("Expected an error for an unknown schema version")
+	}
+}
+
+func TestFlightDataJSON(t *testing.T) {
+	fd := sampleFlightData()
+	b, err := json.Marshal(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"schema_version":4`, `"wifi_region":"GB"`, `"state":"Hovering"`, `"battery_percentage":42`, `"ssid":"TELLO-ABCDEF"`, `"serial_number":"0TQDG7REDB0N8X"`} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("Expected %s in %s", want, b)
+		}
+	}
+	var got FlightData
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.LightStrengthUpdated.Equal(fd.LightStrengthUpdated) {
+		t.Errorf("Expected time %v, got %v", fd.LightStrengthUpdated, got.LightStrengthUpdated)
+	}
+	if !got.ActivationTime.Equal(fd.ActivationTime) {
+		t.Errorf("Expected activation time %v, got %v", fd.ActivationTime, got.ActivationTime)
+	}
+	got.LightStrengthUpdated = fd.LightStrengthUpdated // JSON does not preserve the Location
+	got.ActivationTime = fd.ActivationTime
+	if !reflect.DeepEqual(fd, got) {
+		t.Errorf("Round trip mismatch\nsent: %+v\ngot:  %+v", fd, got)
+	}
+}
+
+func TestFlightDataConcurrency(t *testing.T) {
+	drone, fake := pushingDrone(t)
+
+	// two simultaneous requests must not both start a streamer
+	results := make(chan error, 2)
+	streams := make(chan (<-chan FlightData), 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			ch, err := drone.StreamFlightData(false, 1)
+			if err == nil {
+				streams <- ch
+			}
+			results <- err
+		}()
+	}
+	failures := 0
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			failures++
+		}
+	}
+	if failures != 1 {
+		t.Fatalf("Expected exactly one stream to start, %d failed", failures)
+	}
+	stream := <-streams
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(2)
+	go func() { // the drone
+		defer wg.Done()
+		status := newPacket(ptData2, MsgFlightStatus, 0, 24)
+		bitrate := newPacket(ptData2, MsgQueryVideoBitrate, 0, 1)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			status.payload[2] = byte(i)
+			bitrate.payload[0] = byte(i % 5)
+			fake.pushRaw(packetToBuffer(status))
+			fake.pushRaw(packetToBuffer(bitrate))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	go func() { // a reader polling the flight data
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			drone.GetFlightData()
+			_, unlisten := drone.ListenFiles()
+			unlisten()
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		<-stream
+	}
+	close(stop)
+	wg.Wait()
+
+	drone.ControlDisconnect()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-stream:
+			if !ok {
+				if _, err := drone.StreamFlightData(false, 1); err != nil {
+					t.Errorf("Expected streaming to be possible again, got %v", err)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("Expected the stream to close on disconnect")
+		}
+	}
+}
+
+func TestSlowFileListener(t *testing.T) {
+	drone := new(Tello)
+	files, unlisten := drone.ListenFiles()
+	done := make(chan struct{})
+	go func() { // nobody reads files, which must neither block reassembly nor ListenFiles()
+		for i := 0; i < 2*filesChanSize; i++ {
+			receivePicture(drone, []byte{0xff, 0xd8, byte(i)})
+		}
+		_, unlistenOther := drone.ListenFiles()
+		unlistenOther()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Reassembly blocked on a listener which is not reading")
+	}
+	if n := len(files); n != filesChanSize {
+		t.Errorf("Expected %d files queued for the listener, got %d", filesChanSize, n)
+	}
+	unlisten()
+}
@@ -0,0 +1,126 @@
+// flightstate.go
+
+// This file derives a high-level flight state from the drone's status.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "time"
+
+// FlightState is a high-level summary of what the drone is doing.
+type FlightState int
+
+// Flight states...
+const (
+	StateGrounded         FlightState = iota // on the ground, motors stopped
+	StateTakingOff                           // takeoff acknowledged, not yet at hovering height
+	StateHovering                            // airborne and holding position
+	StateFlying                              // airborne and moving
+	StateLanding                             // land acknowledged, not yet on the ground
+	StateEmergencyStopped                    // motors stopped while not on the ground
+)
+
+var flightStateNames = [...]string{"Grounded", "TakingOff", "Hovering", "Flying", "Landing", "EmergencyStopped"}
+
+func (fs FlightState) String() string {
+	if fs < 0 || int(fs) >= len(flightStateNames) {
+		return "Unknown"
+	}
+	return flightStateNames[fs]
+}
+
+// IsAirborne returns true if the drone is off the ground in this state.
+func (fs FlightState) IsAirborne() bool {
+	return fs == StateTakingOff || fs == StateHovering || fs == StateFlying || fs == StateLanding
+}
+
+// FlightStateChange is the Data of an EvFlightState Event.
+type FlightStateChange struct {
+	From, To FlightState
+}
+
+const (
+	takeoffHeightDm = 3               // height above which we consider a takeoff to be complete
+	takeoffTimeout  = 5 * time.Second // how long after its ack a takeoff may take to set the flying flag
+)
+
+// nextFlightState derives the new flight state from the current one, which was entered age ago,
+// and fresh status data.  A takeoff is kept until the drone reports that it is flying, unless that
+// takes longer than takeoffTimeout, as the status can lag the takeoff ack.
+func nextFlightState(cur FlightState, fd FlightData, age time.Duration) FlightState {
+	switch {
+	case cur == StateTakingOff && !fd.Flying && age < takeoffTimeout:
+		return StateTakingOff
+	case fd.OnGround && !fd.Flying:
+		return StateGrounded
+	case !fd.Flying && !fd.EmOpen && cur.IsAirborne() && cur != StateLanding:
+		// motors have stopped and we're not on the ground...
+		return StateEmergencyStopped
+	case !fd.Flying:
+		if cur == StateEmergencyStopped {
+			return cur
+		}
+		return StateGrounded
+	case cur == StateLanding:
+		return StateLanding
+	case cur == StateTakingOff && !fd.DroneHover && fd.Height < takeoffHeightDm:
+		return StateTakingOff
+	case fd.DroneHover:
+		return StateHovering
+	default:
+		return StateFlying
+	}
+}
+
+// GetFlightState returns the current high-level flight state of the drone.
+func (tello *Tello) GetFlightState() (fs FlightState) {
+	tello.fdMu.RLock()
+	fs = tello.fd.State
+	tello.fdMu.RUnlock()
+	return fs
+}
+
+// updateFlightState moves to the state derived by next from the current state, sending an
+// EvFlightState Event if it has changed.  next is called with fdMu held.
+func (tello *Tello) updateFlightState(next func(cur FlightState) FlightState) {
+	tello.fdMu.Lock()
+	from := tello.fd.State
+	to := next(from)
+	tello.fd.State = to
+	if to != from {
+		tello.stateSince = tello.now()
+	}
+	tookOff := from == StateGrounded && to.IsAirborne()
+	var fd FlightData
+	if tookOff {
+		tello.headingRef = tello.fd.IMU.Yaw
+		tello.headingRefValid = true
+		fd = tello.fd
+	}
+	tello.fdMu.Unlock()
+	if tookOff {
+		tello.recordTakeoff(fd)
+	}
+	if to != from {
+		tello.flightLogTransition(from, to)
+		tello.emitEvent(EvFlightState, FlightStateChange{From: from, To: to})
+	}
+}
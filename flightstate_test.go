@@ -0,0 +1,63 @@
+// tello project flightstate_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextFlightState(t *testing.T) {
+	grounded := FlightData{OnGround: true}
+	climbing := FlightData{Flying: true, EmOpen: true, Height: 1}
+	hovering := FlightData{Flying: true, EmOpen: true, DroneHover: true, Height: 8}
+	moving := FlightData{Flying: true, EmOpen: true, Height: 8}
+	dropped := FlightData{Height: 8}
+
+	tests := []struct {
+		cur  FlightState
+		fd   FlightData
+		age  time.Duration
+		want FlightState
+	}{
+		{StateGrounded, grounded, 0, StateGrounded},
+		{StateTakingOff, grounded, 0, StateTakingOff}, // the status lags the takeoff ack
+		{StateTakingOff, dropped, 0, StateTakingOff},
+		{StateTakingOff, grounded, takeoffTimeout, StateGrounded},
+		{StateTakingOff, FlightData{EmOpen: true}, takeoffTimeout, StateGrounded},
+		{StateTakingOff, climbing, 0, StateTakingOff},
+		{StateTakingOff, climbing, takeoffTimeout, StateTakingOff},
+		{StateTakingOff, hovering, 0, StateHovering},
+		{StateHovering, moving, 0, StateFlying},
+		{StateFlying, hovering, 0, StateHovering},
+		{StateLanding, hovering, 0, StateLanding},
+		{StateLanding, grounded, 0, StateGrounded},
+		{StateFlying, dropped, 0, StateEmergencyStopped},
+		{StateEmergencyStopped, dropped, 0, StateEmergencyStopped},
+		{StateEmergencyStopped, grounded, 0, StateGrounded},
+	}
+	for _, tc := range tests {
+		if got := nextFlightState(tc.cur, tc.fd, tc.age); got != tc.want {
+			t.Errorf("From %v after %v with %+v expected %v, got %v", tc.cur, tc.age, tc.fd, tc.want, got)
+		}
+	}
+}
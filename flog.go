@@ -22,86 +22,307 @@
 package tello
 
 import (
+	"io"
 	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SMerrony/tello/protocol"
+)
+
+const (
+	maxFlightLogPackets = 36000 // bounds the memory used recording one flight, about an hour of log data
+	logAckRetryPeriod   = 500 * time.Millisecond
+	logAckRetries       = 5
 )
 
+// The Tello has no known command to download a stored log, the official app builds its flight
+// log from the stream of log data messages sent during a connection.  WithFlightLogDir() does
+// the same, saving the stream in the corpus format used by the protocol package, so that it can
+// be read back with ReadFlightLog() or inspected with cmd/tello-decode.
+
+// WithFlightLogDir saves the drone's flight log for each flight, from takeoff until it is back on
+// the ground, to a file in dir.  An EvFlightLog event reports each file once the Tello has landed.
+func WithFlightLogDir(dir string) Option {
+	return func(tello *Tello) { tello.cfg.flightLogDir = dir }
+}
+
+// FlightLogSaved describes a flight log saved via WithFlightLogDir(), it is the Data of an EvFlightLog event.
+type FlightLogSaved struct {
+	Path    string
+	Packets int   // log data messages saved
+	Err     error // why the log could not be saved, if it was not
+}
+
+// flightLogger collects the log data messages of the current flight, and tracks the handshake
+// which starts the drone sending them.
+type flightLogger struct {
+	mu       sync.Mutex
+	active   bool
+	start    time.Time
+	packets  []flightLogPacket
+	ackGen   int  // incremented for each log header, so that superseded ack retries stop
+	dataSeen bool // has log data arrived since the last log header?
+}
+
+type flightLogPacket struct {
+	at   time.Time
+	data []byte
+}
+
+// recordFlightLog keeps a log data message if we are saving this flight.
+func (tello *Tello) recordFlightLog(pkt packet) {
+	if tello.cfg.flightLogDir == "" {
+		return
+	}
+	fl := &tello.flog
+	fl.mu.Lock()
+	if fl.active && len(fl.packets) < maxFlightLogPackets {
+		fl.packets = append(fl.packets, flightLogPacket{at: tello.now(), data: packetToBuffer(pkt)})
+	}
+	fl.mu.Unlock()
+}
+
+// flightLogTransition starts recording at takeoff, and saves the log once we are back on the ground.
+func (tello *Tello) flightLogTransition(from, to FlightState) {
+	if tello.cfg.flightLogDir == "" {
+		return
+	}
+	fl := &tello.flog
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	switch {
+	case from == StateGrounded && to.IsAirborne():
+		fl.active, fl.start, fl.packets = true, tello.now(), nil
+	case to == StateGrounded && fl.active:
+		start, packets := fl.start, fl.packets
+		fl.active, fl.packets = false, nil
+		go tello.saveFlightLog(start, packets)
+	}
+}
+
+func (tello *Tello) saveFlightLog(start time.Time, packets []flightLogPacket) {
+	saved := FlightLogSaved{
+		Path:    filepath.Join(tello.cfg.flightLogDir, fileNamePrefix(tello.GetFlightData().SerialNumber, "-")+"-"+start.Format("20060102-150405")+".flog"),
+		Packets: len(packets),
+	}
+	saved.Err = writeFlightLog(saved.Path, packets)
+	if saved.Err != nil {
+		tello.logf("Error: saving flight log - %v\n", saved.Err)
+	}
+	tello.emitEvent(EvFlightLog, saved)
+}
+
+func writeFlightLog(path string, packets []flightLogPacket) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	cw := protocol.NewCorpusWriter(f)
+	for _, p := range packets {
+		cw.Write(p.at, false, p.data)
+	}
+	if err := cw.Err(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadFlightLog reads a flight log saved via WithFlightLogDir(), or any corpus containing log data
+// messages, and returns its records.  MVO and IMU records may be decoded with their MVO() and IMU() methods.
+func ReadFlightLog(r io.Reader) (recs []FlightLogRecord, err error) {
+	pkts, err := protocol.ReadCorpus(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, cp := range pkts {
+		if cp.ToDrone {
+			continue
+		}
+		pkt, err := parsePacket(cp.Data)
+		if err != nil || pkt.messageID != MsgLogData {
+			continue
+		}
+		for _, rec := range logRecords(pkt.payload) {
+			rec.Offset = cp.Offset
+			recs = append(recs, rec)
+		}
+	}
+	return recs, nil
+}
+
+// ackLogHeader answers the log header the drone sends after connecting, echoing its ID, which
+// must be done before the drone will send any log data.  The drone repeats the header until it is
+// acknowledged, but the handshake is unreliable, so the ack is also resent every logAckRetryPeriod
+// until log data arrives, up to logAckRetries times.
 func (tello *Tello) ackLogHeader(id []byte) {
+	if len(id) < 2 {
+		return
+	}
+	tello.flog.mu.Lock()
+	tello.flog.ackGen++
+	tello.flog.dataSeen = false
+	gen := tello.flog.ackGen
+	tello.flog.mu.Unlock()
+
+	id = []byte{id[0], id[1]}
+	tello.sendLogHeaderAck(id)
+	go func() {
+		clock := tello.cfg.getClock()
+		for i := 0; i < logAckRetries; i++ {
+			<-clock.After(logAckRetryPeriod)
+			tello.flog.mu.Lock()
+			done := tello.flog.ackGen != gen || tello.flog.dataSeen
+			tello.flog.mu.Unlock()
+			if done || !tello.ControlConnected() {
+				return
+			}
+			tello.sendLogHeaderAck(id)
+		}
+		tello.logln("Warning: the drone has not sent log data after the log header was acknowledged")
+	}()
+}
+
+func (tello *Tello) sendLogHeaderAck(id []byte) {
 	tello.ctrlMu.Lock()
 	defer tello.ctrlMu.Unlock()
 	tello.ctrlSeq++
-	pkt := newPacket(ptData1, msgLogHeader, tello.ctrlSeq, 3)
+	pkt := newPacket(ptData1, MsgLogHeader, tello.ctrlSeq, 3)
 	pkt.payload[1] = id[0]
 	pkt.payload[2] = id[1]
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.enqueue(packetToBuffer(pkt))
 }
 
-func (tello *Tello) parseLogPacket(data []byte) {
+// setLogDataSeen records whether log data has arrived since the log header handshake.
+func (tello *Tello) setLogDataSeen(seen bool) {
+	tello.flog.mu.Lock()
+	tello.flog.dataSeen = seen
+	tello.flog.mu.Unlock()
+}
+
+// LogDataActive returns true if the drone has sent log data since the log header handshake, the
+// log data carries the MVO and IMU fields of FlightData.
+func (tello *Tello) LogDataActive() bool {
+	tello.flog.mu.Lock()
+	defer tello.flog.mu.Unlock()
+	return tello.flog.dataSeen
+}
+
+// FlightLogRecord is one record from the drone's flight log stream, with the obfuscation removed.
+type FlightLogRecord struct {
+	Offset time.Duration // since the start of the log, zero for live records
+	Type   uint16        // the record type, eg. 0x001d for MVO and 0x0800 for IMU data
+	Data   []byte        // the whole record including its 10-byte header
+}
+
+// logRecordHeaderLen is the length of the unobfuscated header of each flight log record.
+const logRecordHeaderLen = 10
+
+// logRecords splits the payload of a log data message into its records.
+func logRecords(data []byte) (recs []FlightLogRecord) {
 	pos := 1
 	if len(data) < 2 {
-		return
+		return nil
 	}
 	for pos < len(data)-6 {
 		if data[pos] != logRecordSeparator {
-			//log.Println("Error parsing log record (bad separator)")
 			break
 		}
 		recLen := int(uint8(data[pos+1])) + int(uint8(data[pos+2]))<<8
-		logRecType := uint16(data[pos+4]) + uint16(data[pos+5])<<8
-		//log.Printf("Flight Log - Rec type: %x, len:%d\n", logRecType, recLen)
-		xorBuf := make([]byte, 256)
-		xorVal := data[pos+6]
-		switch logRecType {
+		if recLen == 0 {
+			break
+		}
+		n := recLen
+		if pos+n > len(data) {
+			n = len(data) - pos
+		}
+		rec := FlightLogRecord{Type: uint16(data[pos+4]) + uint16(data[pos+5])<<8, Data: make([]byte, n)}
+		copy(rec.Data, data[pos:pos+n])
+		for i := logRecordHeaderLen; i < n; i++ {
+			rec.Data[i] ^= data[pos+6] // the header holds the key
+		}
+		recs = append(recs, rec)
+		pos += recLen
+	}
+	return recs
+}
+
+// padded returns the record data extended with zeroes, so that fields beyond a short record read as zero.
+func (r FlightLogRecord) padded() []byte {
+	buf := make([]byte, 256)
+	copy(buf, r.Data)
+	return buf
+}
+
+// mvo decodes an MVO record, flags says which fields are valid.
+func (r FlightLogRecord) mvo() (mvo MVOData, flags uint8) {
+	const offset = 10
+	buf := r.padded()
+	flags = buf[offset+76]
+	mvo.VelocityX = int16(buf[offset+2]) + int16(buf[offset+3])<<8
+	mvo.VelocityY = int16(buf[offset+4]) + int16(buf[offset+5])<<8
+	mvo.VelocityZ = -(int16(buf[offset+6]) + int16(buf[offset+7])<<8)
+	mvo.PositionY = bytesToFloat32(buf[offset+8 : offset+13])
+	mvo.PositionX = bytesToFloat32(buf[offset+12 : offset+17])
+	mvo.PositionZ = bytesToFloat32(buf[offset+16 : offset+21])
+	return mvo, flags
+}
+
+// MVO decodes a visual odometry record, ok is false if this is not one.
+func (r FlightLogRecord) MVO() (mvo MVOData, ok bool) {
+	if r.Type != logRecNewMVO {
+		return mvo, false
+	}
+	mvo, _ = r.mvo()
+	return mvo, true
+}
+
+// IMU decodes an IMU record, ok is false if this is not one.
+func (r FlightLogRecord) IMU() (imu IMUData, ok bool) {
+	if r.Type != logRecIMU {
+		return imu, false
+	}
+	const offset = 10
+	buf := r.padded()
+	imu.BaroAltitude = bytesToFloat32(buf[offset+44 : offset+49])
+	imu.QuaternionW = bytesToFloat32(buf[offset+48 : offset+53])
+	imu.QuaternionX = bytesToFloat32(buf[offset+52 : offset+57])
+	imu.QuaternionY = bytesToFloat32(buf[offset+56 : offset+61])
+	imu.QuaternionZ = bytesToFloat32(buf[offset+60 : offset+65])
+	imu.Temperature = (int16(buf[offset+106]) + int16(buf[offset+107])<<8) / 100
+	imu.Yaw = quatToYawDeg(imu.QuaternionX, imu.QuaternionY, imu.QuaternionZ, imu.QuaternionW)
+	return imu, true
+}
+
+func (tello *Tello) parseLogPacket(data []byte) {
+	for _, rec := range logRecords(data) {
+		switch rec.Type {
 		case logRecNewMVO:
-			//log.Println("NewMOV rec found")
-			for i := 0; i < recLen && pos+i < len(data); i++ {
-				xorBuf[i] = data[pos+i] ^ xorVal
-			}
-			offset := 10
-			flags := data[offset+76]
+			mvo, flags := rec.mvo()
 			tello.fdMu.Lock()
 			if flags&logValidVelX != 0 {
-				tello.fd.MVO.VelocityX = (int16(xorBuf[offset+2]) + int16(xorBuf[offset+3])<<8)
+				tello.fd.MVO.VelocityX = mvo.VelocityX
 			}
 			if flags&logValidVelY != 0 {
-				tello.fd.MVO.VelocityY = (int16(xorBuf[offset+4]) + int16(xorBuf[offset+5])<<8)
+				tello.fd.MVO.VelocityY = mvo.VelocityY
 			}
 			if flags&logValidVelZ != 0 {
-				tello.fd.MVO.VelocityZ = -(int16(xorBuf[offset+6]) + int16(xorBuf[offset+7])<<8)
+				tello.fd.MVO.VelocityZ = mvo.VelocityZ
 			}
-			// if flags&logValidPosY != 0 {
-			// 	tello.fd.MVO.PositionY = bytesToFloat32(xorBuf[offset+8 : offset+13])
-			// }
-			// if flags&logValidPosX != 0 {
-			// 	tello.fd.MVO.PositionX = bytesToFloat32(xorBuf[offset+12 : offset+17])
-			// }
-			// if flags&logValidPosZ != 0 {
-			// 	tello.fd.MVO.PositionZ = bytesToFloat32(xorBuf[offset+16 : offset+21])
-			// }
 			if flags&logValidPosY != 0 && flags&logValidPosX != 0 && flags&logValidPosZ != 0 {
-				tello.fd.MVO.PositionY = bytesToFloat32(xorBuf[offset+8 : offset+13])
-				tello.fd.MVO.PositionX = bytesToFloat32(xorBuf[offset+12 : offset+17])
-				tello.fd.MVO.PositionZ = bytesToFloat32(xorBuf[offset+16 : offset+21])
+				tello.fd.MVO.PositionX, tello.fd.MVO.PositionY, tello.fd.MVO.PositionZ = mvo.PositionX, mvo.PositionY, mvo.PositionZ
 			}
+			tello.accumulateDistance(tello.now())
 			tello.fdMu.Unlock()
 		case logRecIMU:
-			//log.Println("IMU rec found")
-			for i := 0; i < recLen && pos+i < len(data); i++ {
-				xorBuf[i] = data[pos+i] ^ xorVal
-			}
-			offset := 10
+			imu, _ := rec.IMU()
 			tello.fdMu.Lock()
-			tello.fd.IMU.QuaternionW = bytesToFloat32(xorBuf[offset+48 : offset+53])
-			tello.fd.IMU.QuaternionX = bytesToFloat32(xorBuf[offset+52 : offset+57])
-			tello.fd.IMU.QuaternionY = bytesToFloat32(xorBuf[offset+56 : offset+61])
-			tello.fd.IMU.QuaternionZ = bytesToFloat32(xorBuf[offset+60 : offset+65])
-			tello.fd.IMU.Temperature = (int16(xorBuf[offset+106]) + int16(xorBuf[offset+107])<<8) / 100
-			tello.fd.IMU.Yaw = quatToYawDeg(tello.fd.IMU.QuaternionX,
-				tello.fd.IMU.QuaternionY,
-				tello.fd.IMU.QuaternionZ,
-				tello.fd.IMU.QuaternionW)
+			tello.fd.IMU = imu
 			tello.fdMu.Unlock()
 		}
-		pos += recLen
 	}
 }
 
@@ -22,9 +22,14 @@
 package tello
 
 import (
+	"context"
 	"log"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/SMerrony/tello/sim"
 )
 
 func TestAckHeader(t *testing.T) {
@@ -82,3 +87,112 @@ func TestQuatToYawDeg(t *testing.T) {
 		t.Errorf("y: %f\n", y)
 	}
 }
+
+func TestFlightLogSaved(t *testing.T) {
+	dir := t.TempDir()
+	drone, _ := simDrone(t, WithFlightLogDir(dir))
+	events, stop := drone.ListenEvents()
+	defer stop()
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := drone.TakeOffAndWait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := drone.WaitForTakeoff(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := drone.LandAndWait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved FlightLogSaved
+	for saved.Path == "" {
+		select {
+		case ev := <-events:
+			if ev.Type == EvFlightLog {
+				saved = ev.Data.(FlightLogSaved)
+			}
+		case <-ctx.Done():
+			t.Fatal("No flight log saved")
+		}
+	}
+	if saved.Err != nil || saved.Packets == 0 || filepath.Dir(saved.Path) != dir {
+		t.Fatalf("Unexpected %+v", saved)
+	}
+	f, err := os.Open(saved.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	recs, err := ReadFlightLog(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mvos, imus int
+	for _, rec := range recs {
+		if _, ok := rec.MVO(); ok {
+			mvos++
+		}
+		if imu, ok := rec.IMU(); ok {
+			imus++
+			if imu.Temperature != 45 {
+				t.Errorf("Expected the simulated IMU temperature, got %d", imu.Temperature)
+			}
+		}
+	}
+	if mvos != saved.Packets || imus != saved.Packets {
+		t.Errorf("Expected %d MVO and IMU records, got %d and %d", saved.Packets, mvos, imus)
+	}
+}
+
+func TestLogHeaderHandshake(t *testing.T) {
+	s := sim.New(sim.WithLogAckLoss(2))
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	drone := NewTello(WithAddress("127.0.0.1", s.Addr().Port), WithLocalControlPort(AnyPort), WithVideoPort(AnyPort))
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	defer drone.ControlDisconnect()
+	deadline := time.Now().Add(5 * time.Second)
+	for !drone.LogDataActive() {
+		if time.Now().After(deadline) {
+			t.Fatal("No log data after the log header handshake")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestLogHeaderAckRetry(t *testing.T) {
+	drone, fake := pushingDrone(t)
+	acks := func(within time.Duration) (n int) {
+		for _, pkt := range fake.recv(within) {
+			if pkt.messageID != MsgLogHeader {
+				continue
+			}
+			if pkt.payload[1] != 0x34 || pkt.payload[2] != 0x12 {
+				t.Errorf("Log header ack has the wrong ID % x", pkt.payload)
+			}
+			n++
+		}
+		return n
+	}
+
+	fake.pushSeq(MsgLogHeader, 1, 0x34, 0x12, 'v', '1')
+	if n := acks(logAckRetryPeriod*2 + logAckRetryPeriod/2); n != 3 {
+		t.Errorf("Expected the log header to be acknowledged 3 times, got %d", n)
+	}
+	fake.pushSeq(MsgLogData, 2, 0)
+	time.Sleep(50 * time.Millisecond)
+	if !drone.LogDataActive() {
+		t.Error("Expected log data to be active")
+	}
+	if n := acks(logAckRetryPeriod * 2); n != 0 {
+		t.Errorf("Expected no more acks once log data arrived, got %d", n)
+	}
+}
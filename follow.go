@@ -0,0 +1,172 @@
+// follow.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"time"
+)
+
+// Target is an observation of a tracked target, as seen by the drone's camera.
+type Target struct {
+	Found bool    // false if the target is not currently in view
+	X, Y  float32 // offset of the target's centre from the centre of the image, -1 to 1, right and down are positive
+	Size  float32 // apparent size of the target, eg. its height as a fraction of the image's
+}
+
+// TargetSource supplies the latest observation of a target, eg. from an OpenCV person
+// tracker fed by the video stream.  Target() is called on every cycle of the follow
+// controller so it should return promptly; an error stops the controller.
+type TargetSource interface {
+	Target() (Target, error)
+}
+
+// TargetSourceFunc adapts an ordinary func to the TargetSource interface.
+type TargetSourceFunc func() (Target, error)
+
+// Target calls f().
+func (f TargetSourceFunc) Target() (Target, error) { return f() }
+
+// FollowConfig configures Follow().  The gains are the fraction of full stick applied per
+// unit of error, zero values get the defaults.
+type FollowConfig struct {
+	Size         float32       // the apparent Target.Size to hold, so how close to follow; 0 turns and climbs but does not move forwards or back
+	YawGain      float32       // turn towards the target, default 1
+	ThrottleGain float32       // climb or descend to centre the target vertically, default 0.5
+	PitchGain    float32       // move forwards or back to hold the Size, default 1
+	LostTimeout  time.Duration // how long the target may be out of view before giving up, 0 means never
+}
+
+const (
+	followDefaultYawGain      = 1.0
+	followDefaultThrottleGain = 0.5
+	followDefaultPitchGain    = 1.0
+)
+
+// CancelFollow stops any Follow() in progress.  The drone should stop moving.
+func (tello *Tello) CancelFollow() {
+	tello.autoFollowMu.Lock()
+	tello.autoFollow = false
+	tello.autoFollowMu.Unlock()
+}
+
+// IsFollowing tests whether we are currently following a target.
+func (tello *Tello) IsFollowing() (set bool) {
+	tello.autoFollowMu.RLock()
+	set = tello.autoFollow
+	tello.autoFollowMu.RUnlock()
+	return set
+}
+
+// Follow starts a controller which keeps the target supplied by src centred in the camera view
+// and at the configured apparent size, by turning, climbing and moving forwards or back.
+// If the target is lost from view the drone hovers until it is found again, or LostTimeout expires.
+// It may not be combined with the other autopilots, except that the sideways (Rx) stick is
+// left to the caller.
+// The func returns immediately and a Goroutine handles the navigation until either
+// it is cancelled via CancelFollow(), src returns an error, or the target is lost.
+// The caller may optionally listen on the 'done' channel for a signal that
+// following has stopped.
+func (tello *Tello) Follow(src TargetSource, cfg FollowConfig) (done chan error, err error) {
+	if src == nil {
+		return nil, errors.New("Follow needs a TargetSource")
+	}
+	if tello.IsAutoHeight() || tello.IsAutoTurning() || tello.IsAutoXY() || tello.IsAutoOrbiting() {
+		return nil, errors.New("Cannot follow while AutoFlying")
+	}
+	if cfg.YawGain == 0 {
+		cfg.YawGain = followDefaultYawGain
+	}
+	if cfg.ThrottleGain == 0 {
+		cfg.ThrottleGain = followDefaultThrottleGain
+	}
+	if cfg.PitchGain == 0 {
+		cfg.PitchGain = followDefaultPitchGain
+	}
+
+	tello.autoFollowMu.Lock()
+	if tello.autoFollow {
+		tello.autoFollowMu.Unlock()
+		return nil, errors.New("Already following")
+	}
+	tello.autoFollow = true
+	tello.autoFollowMu.Unlock()
+
+	done = make(chan error, 1) // buffered so it won't block
+
+	go func() {
+		returnedError := errors.New("Follow cancelled")
+		lastSeen := tello.now()
+		for {
+			if !tello.IsFollowing() {
+				tello.ctrlMu.Lock()
+				tello.ctrlRy, tello.ctrlLx, tello.ctrlLy = 0, 0, 0
+				tello.ctrlMu.Unlock()
+				tello.sendStickUpdate()
+				done <- returnedError
+				close(done)
+				return
+			}
+
+			target, err := src.Target()
+			switch {
+			case err != nil:
+				returnedError = err
+				tello.CancelFollow()
+				continue
+			case target.Found:
+				lastSeen = tello.now()
+			case cfg.LostTimeout > 0 && tello.now().Sub(lastSeen) > cfg.LostTimeout:
+				returnedError = errors.New("Follow target lost")
+				tello.CancelFollow()
+				continue
+			}
+			ry, lx, ly := followSticks(target, cfg)
+
+			tello.ctrlMu.Lock()
+			if !tello.IsFollowing() { // cancelled meanwhile, eg. by Hover(), so leave the sticks alone
+				tello.ctrlMu.Unlock()
+				continue
+			}
+			tello.ctrlRy, tello.ctrlLx, tello.ctrlLy = ry, lx, ly
+			tello.ctrlMu.Unlock()
+
+			time.Sleep(autopilotPeriodMs * time.Millisecond)
+		}
+	}()
+
+	return done, nil
+}
+
+// followSticks converts the target's error into pitch, yaw and throttle stick values,
+// which are all neutral if the target is not in view.
+func followSticks(t Target, cfg FollowConfig) (ry, lx, ly int16) {
+	if !t.Found {
+		return 0, 0, 0
+	}
+	lx = clampStick(cfg.YawGain * t.X * autoPilotSpeedFast)
+	ly = clampStick(-cfg.ThrottleGain * t.Y * autoPilotSpeedFast)
+	if cfg.Size > 0 && t.Size > 0 {
+		ry = clampStick(cfg.PitchGain * (cfg.Size - t.Size) / cfg.Size * autoPilotSpeedFast)
+	}
+	return ry, lx, ly
+}
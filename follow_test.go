@@ -0,0 +1,105 @@
+// follow_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFollowSticks(t *testing.T) {
+	cfg := FollowConfig{Size: 0.5, YawGain: 1, ThrottleGain: 0.5, PitchGain: 1}
+	ry, lx, ly := followSticks(Target{Found: true, X: 0.5, Y: -0.5, Size: 0.25}, cfg)
+	if lx <= 0 || ly <= 0 || ry <= 0 {
+		t.Errorf("Target up, right and far: got ry %d, lx %d, ly %d", ry, lx, ly)
+	}
+	ry, lx, ly = followSticks(Target{Found: true, Size: 1}, cfg)
+	if lx != 0 || ly != 0 || ry >= 0 {
+		t.Errorf("Target centred and close: got ry %d, lx %d, ly %d", ry, lx, ly)
+	}
+	if ry, lx, ly = followSticks(Target{X: 1, Y: 1, Size: 1}, cfg); ry != 0 || lx != 0 || ly != 0 {
+		t.Error("Expected neutral sticks when the target is not found")
+	}
+	if ry, _, _ = followSticks(Target{Found: true, Size: 0.1}, FollowConfig{PitchGain: 1}); ry != 0 {
+		t.Error("Expected no pitch when no Size is set")
+	}
+}
+
+func TestFollow(t *testing.T) {
+	drone := new(Tello)
+	var mu sync.Mutex
+	target := Target{Found: true, X: 0.5}
+	var srcErr error
+	src := TargetSourceFunc(func() (Target, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return target, srcErr
+	})
+
+	done, err := drone.Follow(src, FollowConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := drone.Follow(src, FollowConfig{}); err == nil {
+		t.Error("Expected a second Follow to be refused")
+	}
+	time.Sleep(50 * time.Millisecond)
+	drone.ctrlMu.RLock()
+	lx := drone.ctrlLx
+	drone.ctrlMu.RUnlock()
+	if lx <= 0 {
+		t.Errorf("Expected to turn right towards the target, got %d", lx)
+	}
+
+	fail := errors.New("tracker failed")
+	mu.Lock()
+	srcErr = fail
+	mu.Unlock()
+	select {
+	case err := <-done:
+		if err != fail {
+			t.Errorf("Expected the source's error, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Follow did not stop")
+	}
+	if drone.IsFollowing() || drone.ctrlLx != 0 {
+		t.Error("Expected following to have stopped with neutral sticks")
+	}
+
+	mu.Lock()
+	srcErr, target = nil, Target{}
+	mu.Unlock()
+	if done, err = drone.Follow(src, FollowConfig{LostTimeout: 100 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected losing the target to be an error")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Follow did not give up on a lost target")
+	}
+}
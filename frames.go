@@ -0,0 +1,177 @@
+// frames.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"math"
+)
+
+// The drone's MVO (visual odometry) frame has its X axis to the right and its Y axis forwards of
+// the drone's heading when it was powered on, in metres.  Yaw is measured clockwise in degrees, so
+// a yaw of 90 faces along +X.  The body frame is centred on the drone with X to its right and Y
+// ahead of its nose.  A RoomFrame is a user-defined frame with its own origin and heading, so
+// that positions and waypoints may be given in eg. the coordinates of a floor plan.
+
+// RoomFrame is a horizontal frame of reference defined in terms of the MVO frame.
+type RoomFrame struct {
+	OriginX, OriginY float32 // the MVO position of the room's origin
+	Heading          float32 // the MVO heading, in degrees, of the room's +Y axis
+}
+
+// ToMVO converts a position in the room to the MVO frame.
+func (rf RoomFrame) ToMVO(x, y float32) (mx, my float32) {
+	dx, dy := bodyToWorld(rf.Heading, x, y)
+	return rf.OriginX + dx, rf.OriginY + dy
+}
+
+// FromMVO converts an MVO position to the room frame.
+func (rf RoomFrame) FromMVO(mx, my float32) (x, y float32) {
+	return calcXYdeltas(rf.Heading, rf.OriginX, rf.OriginY, mx, my)
+}
+
+// HeadingToMVO converts a heading in the room to the MVO frame, the result is between -180 and +180.
+func (rf RoomFrame) HeadingToMVO(deg float32) float32 {
+	return normaliseYaw(deg + rf.Heading)
+}
+
+// HeadingFromMVO converts an MVO heading to the room frame, the result is between -180 and +180.
+func (rf RoomFrame) HeadingFromMVO(deg float32) float32 {
+	return normaliseYaw(deg - rf.Heading)
+}
+
+// WaypointsToMVO converts a list of (x, y) waypoints in the room to the MVO frame.
+func (rf RoomFrame) WaypointsToMVO(waypoints [][2]float32) [][2]float32 {
+	mvo := make([][2]float32, len(waypoints))
+	for i, wp := range waypoints {
+		mvo[i][0], mvo[i][1] = rf.ToMVO(wp[0], wp[1])
+	}
+	return mvo
+}
+
+// ToNED converts a position in the room and a height in decimetres to a local North-East-Down
+// frame, in metres, where the room's +Y axis is North.
+func (rf RoomFrame) ToNED(x, y float32, heightDm int16) (north, east, down float32) {
+	return y, x, -float32(heightDm) / 10
+}
+
+// BodyToMVO converts a displacement right and forward of a drone with the given yaw to the MVO frame.
+func BodyToMVO(yaw, right, forward float32) (dx, dy float32) {
+	return bodyToWorld(yaw, right, forward)
+}
+
+// MVOToBody converts an MVO displacement to distances right and forward of a drone with the given yaw.
+func MVOToBody(yaw, dx, dy float32) (right, forward float32) {
+	return calcXYdeltas(yaw, 0, 0, dx, dy)
+}
+
+// EstimateYawOffset returns the difference between the heading of a movement from (fromX, fromY)
+// to (toX, toY) in the MVO frame and the IMU yaw of the drone, which should have been flying
+// straight forwards.  The IMU yaw drifts, so its zero need not stay aligned with the MVO frame;
+// pass the result to SetYawOffset() to correct for this.  At least a metre or so of travel gives
+// a useful estimate.
+func EstimateYawOffset(fromX, fromY, toX, toY, yaw float32) float32 {
+	heading := float32(math.Atan2(float64(toX-fromX), float64(toY-fromY)) * 180 / math.Pi)
+	return normaliseYaw(heading - yaw)
+}
+
+// SetYawOffset sets the correction, in degrees, added to the IMU yaw to give the heading in the
+// MVO frame, it is used by AutoFlyToXY() and the room frame funcs.  Also see EstimateYawOffset().
+func (tello *Tello) SetYawOffset(deg float32) {
+	tello.autoXYMu.Lock()
+	tello.yawOffset = normaliseYaw(deg)
+	tello.autoXYMu.Unlock()
+}
+
+// YawOffset returns the correction set by SetYawOffset().
+func (tello *Tello) YawOffset() (deg float32) {
+	tello.autoXYMu.RLock()
+	deg = tello.yawOffset
+	tello.autoXYMu.RUnlock()
+	return deg
+}
+
+// mvoHeading returns the drone's heading in the MVO frame.
+func (tello *Tello) mvoHeading() float32 {
+	yaw := tello.GetFlightData().IMU.Yaw
+	return normaliseYaw(yaw + tello.YawOffset())
+}
+
+// RoomFrameHere returns a RoomFrame whose origin is the drone's current position and whose +Y
+// axis is the way it is facing.
+func (tello *Tello) RoomFrameHere() RoomFrame {
+	fd := tello.GetFlightData()
+	return RoomFrame{OriginX: fd.MVO.PositionX, OriginY: fd.MVO.PositionY, Heading: tello.mvoHeading()}
+}
+
+// SetRoomFrame sets the RoomFrame used by RoomPosition() and AutoFlyToRoomXY().
+func (tello *Tello) SetRoomFrame(rf RoomFrame) {
+	tello.autoXYMu.Lock()
+	tello.roomFrame = rf
+	tello.roomFrameValid = true
+	tello.autoXYMu.Unlock()
+}
+
+// GetRoomFrame returns the RoomFrame set by SetRoomFrame(), ok is false if none has been set.
+func (tello *Tello) GetRoomFrame() (rf RoomFrame, ok bool) {
+	tello.autoXYMu.RLock()
+	rf, ok = tello.roomFrame, tello.roomFrameValid
+	tello.autoXYMu.RUnlock()
+	return rf, ok
+}
+
+// RoomPosition returns the drone's position and heading in the room frame.
+func (tello *Tello) RoomPosition() (x, y, heading float32, err error) {
+	rf, ok := tello.GetRoomFrame()
+	if !ok {
+		return 0, 0, 0, errors.New("Room frame has not been set")
+	}
+	fd := tello.GetFlightData()
+	x, y = rf.FromMVO(fd.MVO.PositionX, fd.MVO.PositionY)
+	return x, y, rf.HeadingFromMVO(tello.mvoHeading()), nil
+}
+
+// AutoFlyToRoomXY is as AutoFlyToXY() but the target is given in the room frame.
+// The home point must have been set as usual.
+func (tello *Tello) AutoFlyToRoomXY(x, y float32) (done chan error, err error) {
+	tello.autoXYMu.RLock()
+	rf, ok := tello.roomFrame, tello.roomFrameValid
+	homeX, homeY := tello.homeX, tello.homeY
+	tello.autoXYMu.RUnlock()
+	if !ok {
+		return nil, errors.New("Room frame has not been set")
+	}
+	mx, my := rf.ToMVO(x, y)
+	return tello.AutoFlyToXY(mx-homeX, my-homeY)
+}
+
+// normaliseYaw brings an angle in degrees into the range -180 to +180.
+func normaliseYaw(deg float32) float32 {
+	deg = float32(math.Mod(float64(deg), 360))
+	switch {
+	case deg > 180:
+		deg -= 360
+	case deg < -180:
+		deg += 360
+	}
+	return deg
+}
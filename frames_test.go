@@ -0,0 +1,94 @@
+// frames_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "testing"
+
+func near(a, b float32) bool { return float32Abs(a-b) < 1e-4 }
+
+func TestRoomFrame(t *testing.T) {
+	// a room whose +Y axis points along MVO +X, with its origin at MVO (1, 2)
+	rf := RoomFrame{OriginX: 1, OriginY: 2, Heading: 90}
+	if mx, my := rf.ToMVO(0, 3); !near(mx, 4) || !near(my, 2) {
+		t.Errorf("ToMVO(0, 3) = %v, %v, expected 4, 2", mx, my)
+	}
+	if mx, my := rf.ToMVO(1, 0); !near(mx, 1) || !near(my, 1) {
+		t.Errorf("ToMVO(1, 0) = %v, %v, expected 1, 1", mx, my)
+	}
+	for _, p := range [][2]float32{{0, 0}, {1.5, -2}, {-3, 4}} {
+		mx, my := rf.ToMVO(p[0], p[1])
+		if x, y := rf.FromMVO(mx, my); !near(x, p[0]) || !near(y, p[1]) {
+			t.Errorf("Round trip of %v gave %v, %v", p, x, y)
+		}
+	}
+	if h := rf.HeadingToMVO(135); h != -135 {
+		t.Errorf("HeadingToMVO(135) = %v, expected -135", h)
+	}
+	if h := rf.HeadingFromMVO(-135); h != 135 {
+		t.Errorf("HeadingFromMVO(-135) = %v, expected 135", h)
+	}
+	wps := rf.WaypointsToMVO([][2]float32{{0, 3}})
+	if len(wps) != 1 || !near(wps[0][0], 4) || !near(wps[0][1], 2) {
+		t.Errorf("Unexpected waypoints %v", wps)
+	}
+	if n, e, d := rf.ToNED(1, 2, 15); n != 2 || e != 1 || d != -1.5 {
+		t.Errorf("ToNED(1, 2, 15) = %v, %v, %v", n, e, d)
+	}
+}
+
+func TestBodyFrame(t *testing.T) {
+	if dx, dy := BodyToMVO(90, 0, 1); !near(dx, 1) || !near(dy, 0) {
+		t.Errorf("Forward at yaw 90 should be +X, got %v, %v", dx, dy)
+	}
+	if r, f := MVOToBody(90, 1, 0); !near(r, 0) || !near(f, 1) {
+		t.Errorf("+X at yaw 90 should be forward, got %v, %v", r, f)
+	}
+}
+
+func TestYawOffset(t *testing.T) {
+	// the IMU says 10 degrees but we actually flew along +X
+	if off := EstimateYawOffset(0, 0, 2, 0, 10); !near(off, 80) {
+		t.Errorf("Expected an offset of 80, got %v", off)
+	}
+	if off := EstimateYawOffset(0, 0, 0, -1, 170); !near(off, 10) {
+		t.Errorf("Expected an offset of 10, got %v", off)
+	}
+
+	drone := new(Tello)
+	drone.SetYawOffset(370)
+	if off := drone.YawOffset(); off != 10 {
+		t.Errorf("Expected the offset to be normalised to 10, got %v", off)
+	}
+	drone.fd.IMU.Yaw = 80
+	drone.fd.MVO.PositionX, drone.fd.MVO.PositionY = 3, 4
+	if _, _, _, err := drone.RoomPosition(); err == nil {
+		t.Error("Expected an error without a room frame")
+	}
+	drone.SetRoomFrame(drone.RoomFrameHere())
+	if rf, _ := drone.GetRoomFrame(); rf.Heading != 90 || rf.OriginX != 3 {
+		t.Errorf("Unexpected room frame %+v", rf)
+	}
+	drone.fd.MVO.PositionX = 5
+	if x, y, h, err := drone.RoomPosition(); err != nil || !near(x, 0) || !near(y, 2) || h != 0 {
+		t.Errorf("Expected to be 2m ahead in the room, got %v, %v, %v, %v", x, y, h, err)
+	}
+}
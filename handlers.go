@@ -0,0 +1,138 @@
+// handlers.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "time"
+
+// RawMessage is a message received from the Tello, as passed to a MessageHandler and kept by
+// WithUnknownCapture().
+type RawMessage struct {
+	MessageID  MessageID
+	PacketType uint8
+	Sequence   uint16
+	Payload    []byte
+	Received   time.Time
+}
+
+// MessageHandler is called with messages received from the Tello, see HandleMessage().
+// It is called from the control listener Goroutine so it must not block, and the message
+// is its own to keep.
+type MessageHandler func(msg RawMessage)
+
+type msgHandler struct {
+	id int
+	fn MessageHandler
+}
+
+// HandleMessage registers handler to be called with every message received with ID messageID,
+// whether or not the package handles that message itself, and returns a func to unregister it.
+// This allows undocumented messages to be decoded without changing the package.
+// Messages which the package does not recognise and which have no handler are logged, the
+// first time each ID is seen, and may be kept for later analysis via WithUnknownCapture().
+func (tello *Tello) HandleMessage(messageID MessageID, handler MessageHandler) (remove func()) {
+	tello.msgMu.Lock()
+	defer tello.msgMu.Unlock()
+	if tello.msgHandlers == nil {
+		tello.msgHandlers = map[MessageID][]msgHandler{}
+	}
+	tello.msgNextID++
+	id := tello.msgNextID
+	tello.msgHandlers[messageID] = append(tello.msgHandlers[messageID], msgHandler{id: id, fn: handler})
+	return func() {
+		tello.msgMu.Lock()
+		defer tello.msgMu.Unlock()
+		hs := tello.msgHandlers[messageID]
+		for i, h := range hs {
+			if h.id == id {
+				tello.msgHandlers[messageID] = append(hs[:i:i], hs[i+1:]...)
+				break
+			}
+		}
+		if len(tello.msgHandlers[messageID]) == 0 {
+			delete(tello.msgHandlers, messageID)
+		}
+	}
+}
+
+// WithUnknownCapture keeps the most recent n messages which neither the package nor a
+// HandleMessage() handler recognised, see UnknownMessages().
+func WithUnknownCapture(n int) Option {
+	return func(tello *Tello) { tello.cfg.unknownCapture = n }
+}
+
+// UnknownMessages returns the messages kept via WithUnknownCapture(), oldest first.
+func (tello *Tello) UnknownMessages() []RawMessage {
+	tello.msgMu.RLock()
+	defer tello.msgMu.RUnlock()
+	res := make([]RawMessage, 0, len(tello.unknownRing))
+	res = append(res, tello.unknownRing[tello.unknownNext:]...)
+	return append(res, tello.unknownRing[:tello.unknownNext]...)
+}
+
+func rawMessage(pkt packet, now time.Time) RawMessage {
+	return RawMessage{
+		MessageID:  pkt.messageID,
+		PacketType: pkt.packetType,
+		Sequence:   pkt.sequence,
+		Payload:    append([]byte(nil), pkt.payload...),
+		Received:   now,
+	}
+}
+
+// dispatchMessage passes pkt to any handlers registered for it, it returns true if there were any.
+func (tello *Tello) dispatchMessage(pkt packet) (handled bool) {
+	tello.msgMu.RLock()
+	hs := tello.msgHandlers[pkt.messageID]
+	tello.msgMu.RUnlock()
+	for _, h := range hs {
+		h.fn(rawMessage(pkt, tello.now()))
+	}
+	return len(hs) > 0
+}
+
+// unknownMessage notes a message which the package does not recognise.
+func (tello *Tello) unknownMessage(pkt packet, handled bool) {
+	if handled {
+		return
+	}
+	tello.msgMu.Lock()
+	defer tello.msgMu.Unlock()
+	if !tello.unknownSeen[pkt.messageID] {
+		if tello.unknownSeen == nil {
+			tello.unknownSeen = map[MessageID]bool{}
+		}
+		tello.unknownSeen[pkt.messageID] = true
+		tello.logf("Unknown message from Tello - ID: <%d>, Size %d, Type: %d\n% x\n",
+			pkt.messageID, pkt.size13, pkt.packetType, pkt.payload)
+	}
+	n := tello.cfg.unknownCapture
+	if n <= 0 {
+		return
+	}
+	msg := rawMessage(pkt, tello.now())
+	if len(tello.unknownRing) < n {
+		tello.unknownRing = append(tello.unknownRing, msg)
+		return
+	}
+	tello.unknownRing[tello.unknownNext] = msg
+	tello.unknownNext = (tello.unknownNext + 1) % n
+}
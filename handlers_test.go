@@ -0,0 +1,165 @@
+// handlers_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDrone is the drone's end of a loopback control connection to a Tello, see pushingDrone().
+type fakeDrone struct {
+	conn   *net.UDPConn
+	client *net.UDPAddr // the Tello's end
+	mu     sync.Mutex   // protects seqs
+	seqs   map[MessageID]uint16
+}
+
+// pushingDrone returns a Tello, configured with opts, connected to a fake drone without the connection
+// handshake.  The fake drone sends the Tello packets and sees what it sends.
+func pushingDrone(t *testing.T, opts ...Option) (*Tello, *fakeDrone) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	drone := NewTello(opts...)
+	drone.startControl(client)
+	drone.setCtrlState(connConnected)
+	t.Cleanup(drone.ControlDisconnect)
+	return drone, &fakeDrone{conn: conn, client: client.LocalAddr().(*net.UDPAddr), seqs: map[MessageID]uint16{}}
+}
+
+// push sends a message with the next sequence number for its ID, the first being 7.
+func (f *fakeDrone) push(msgID MessageID, payload ...byte) {
+	f.mu.Lock()
+	f.seqs[msgID]++
+	seq := 6 + f.seqs[msgID]
+	f.mu.Unlock()
+	f.pushSeq(msgID, seq, payload...)
+}
+
+// pushSeq sends a message with the given sequence number, eg. to repeat or reorder messages.
+// Messages with sequence number 0 are not checked for order, see checkSequence().
+func (f *fakeDrone) pushSeq(msgID MessageID, seq uint16, payload ...byte) {
+	pkt := newPacket(ptData1, msgID, seq, len(payload))
+	copy(pkt.payload, payload)
+	f.pushRaw(packetToBuffer(pkt))
+}
+
+// reply answers pkt with a message of the same ID and sequence number, as the drone acknowledges commands.
+func (f *fakeDrone) reply(pkt packet, payload ...byte) {
+	ack := newPacket(ptSet, pkt.messageID, pkt.sequence, len(payload))
+	copy(ack.payload, payload)
+	f.pushRaw(packetToBuffer(ack))
+}
+
+// pushRaw sends data to the Tello as it is.
+func (f *fakeDrone) pushRaw(data []byte) {
+	f.conn.WriteToUDP(data, f.client)
+}
+
+// recv returns the binary packets the Tello sends within the given time.
+func (f *fakeDrone) recv(within time.Duration) (pkts []packet) {
+	buff := make([]byte, 2048)
+	f.conn.SetReadDeadline(time.Now().Add(within))
+	for {
+		n, _, err := f.conn.ReadFromUDP(buff)
+		if err != nil {
+			return pkts
+		}
+		if pkt, err := parsePacket(buff[:n]); err == nil {
+			pkts = append(pkts, pkt)
+		}
+	}
+}
+
+// serve calls handle, in a Goroutine, with each binary packet the Tello sends.
+func (f *fakeDrone) serve(handle func(pkt packet)) {
+	go func() {
+		buff := make([]byte, 2048)
+		for {
+			n, _, err := f.conn.ReadFromUDP(buff)
+			if err != nil {
+				return
+			}
+			if pkt, err := parsePacket(buff[:n]); err == nil {
+				handle(pkt)
+			}
+		}
+	}()
+}
+
+func TestHandleMessage(t *testing.T) {
+	drone, fake := pushingDrone(t, WithUnknownCapture(2))
+	msgs := make(chan RawMessage, 10)
+	remove := drone.HandleMessage(0x1234, func(msg RawMessage) { msgs <- msg })
+	drone.HandleMessage(MsgWifiStrength, func(msg RawMessage) { msgs <- msg }) // known messages are passed on too
+
+	fake.push(0x1234, 1, 2, 3)
+	fake.push(MsgWifiStrength, 90, 10)
+	for _, want := range []RawMessage{{MessageID: 0x1234, Payload: []byte{1, 2, 3}}, {MessageID: MsgWifiStrength, Payload: []byte{90, 10}}} {
+		select {
+		case msg := <-msgs:
+			if msg.MessageID != want.MessageID || !bytes.Equal(msg.Payload, want.Payload) || msg.Sequence != 7 || msg.Received.IsZero() {
+				t.Errorf("Expected %+v, got %+v", want, msg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for a message")
+		}
+	}
+	if n := len(drone.UnknownMessages()); n != 0 {
+		t.Errorf("Expected handled messages not to be captured, got %d", n)
+	}
+
+	// once the handler is removed the message is unknown, and the most recent are kept
+	remove()
+	for i := byte(0); i < 3; i++ {
+		fake.push(0x1234, i)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		unknown := drone.UnknownMessages()
+		if len(unknown) == 2 && unknown[1].Payload[0] == 2 {
+			if unknown[0].Payload[0] != 1 {
+				t.Errorf("Expected the oldest kept first, got %+v", unknown)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for unknown messages, got %+v", unknown)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case msg := <-msgs:
+		t.Errorf("Unexpected message after removing the handler %+v", msg)
+	default:
+	}
+}
@@ -0,0 +1,91 @@
+// headless.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "math"
+
+// SetHeadless turns headless mode on or off.  In headless mode the right stick (and so Forward(),
+// Left() etc.) moves the drone relative to the direction it faced at takeoff rather than to the
+// way its nose is currently pointing, so pushing forward always moves it away from a pilot who
+// stood behind it at takeoff however it has turned since.
+// If the takeoff heading is not known, eg. because we connected in flight, the current heading
+// is used.  Also see ResetHeadlessHeading().
+// The autopilots are not affected.
+func (tello *Tello) SetHeadless(on bool) {
+	if on {
+		tello.fdMu.Lock()
+		if !tello.headingRefValid {
+			tello.headingRef = tello.fd.IMU.Yaw
+			tello.headingRefValid = true
+		}
+		tello.fdMu.Unlock()
+	}
+	tello.ctrlMu.Lock()
+	tello.ctrlHeadless = on
+	tello.ctrlMu.Unlock()
+}
+
+// IsHeadless tests whether headless mode is on.
+func (tello *Tello) IsHeadless() (on bool) {
+	tello.ctrlMu.RLock()
+	on = tello.ctrlHeadless
+	tello.ctrlMu.RUnlock()
+	return on
+}
+
+// ResetHeadlessHeading makes the drone's current heading the 'forward' direction for headless mode.
+func (tello *Tello) ResetHeadlessHeading() {
+	tello.fdMu.Lock()
+	tello.headingRef = tello.fd.IMU.Yaw
+	tello.headingRefValid = true
+	tello.fdMu.Unlock()
+}
+
+// headlessHeading returns the current yaw and the headless reference heading.
+func (tello *Tello) headlessHeading() (yaw, ref float32) {
+	tello.fdMu.RLock()
+	yaw, ref = tello.fd.IMU.Yaw, tello.headingRef
+	tello.fdMu.RUnlock()
+	return yaw, ref
+}
+
+// stickTargets returns the stick values to head for, with the right stick rotated from the
+// reference frame into the drone's own if in headless mode.  ctrlMu must be held.
+func (tello *Tello) stickTargets(yaw, ref float32) stickAxes {
+	target := stickAxes{rx: tello.ctrlRx, ry: tello.ctrlRy, lx: tello.ctrlLx, ly: tello.ctrlLy}
+	if tello.ctrlHeadless && !tello.IsAutoXY() && !tello.IsAutoOrbiting() && !tello.IsFollowing() {
+		target.rx, target.ry = headlessRotate(target.rx, target.ry, yaw, ref)
+	}
+	return target
+}
+
+// headlessRotate converts right stick values given relative to a drone facing ref into the
+// equivalent for one facing yaw, both in degrees.
+func headlessRotate(rx, ry int16, yaw, ref float32) (int16, int16) {
+	x, y := bodyToWorld(ref, float32(rx), float32(ry))
+	dx, dy := calcXYdeltas(yaw, 0, 0, x, y)
+	return clampStick(dx), clampStick(dy)
+}
+
+func clampStick(v float32) int16 {
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(float64(v)))))
+}
@@ -0,0 +1,84 @@
+// headless_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "testing"
+
+func TestHeadlessRotate(t *testing.T) {
+	tests := []struct {
+		rx, ry         int16
+		yaw, ref       float32
+		wantRx, wantRy int16
+	}{
+		{0, 10000, 0, 0, 0, 10000},
+		{0, 10000, 90, 0, -10000, 0},    // turned right, so 'forward' is to our left
+		{10000, 0, -90, 0, 0, -10000},   // turned left, so 'right' is behind us
+		{0, 10000, 180, 0, 0, -10000},   // facing the pilot
+		{0, 10000, 45, 45, 0, 10000},    // reference follows the takeoff heading
+		{32767, 32767, 45, 0, 0, 32767}, // clamped
+	}
+	for _, tt := range tests {
+		rx, ry := headlessRotate(tt.rx, tt.ry, tt.yaw, tt.ref)
+		if rx != tt.wantRx || ry != tt.wantRy {
+			t.Errorf("headlessRotate(%d, %d, %v, %v) = %d, %d, expected %d, %d",
+				tt.rx, tt.ry, tt.yaw, tt.ref, rx, ry, tt.wantRx, tt.wantRy)
+		}
+	}
+}
+
+func TestHeadless(t *testing.T) {
+	drone := new(Tello)
+	drone.fd.IMU.Yaw = 30
+	drone.fd.OnGround = true
+	drone.updateFlightState(func(FlightState) FlightState { return StateTakingOff })
+	drone.fd.IMU.Yaw = 120
+
+	drone.SetHeadless(true)
+	if !drone.IsHeadless() {
+		t.Fatal("Expected headless mode")
+	}
+	drone.Forward(50)
+	drone.ctrlMu.Lock()
+	got := drone.stickTargets(drone.headlessHeading())
+	drone.ctrlMu.Unlock()
+	if got.rx != -16350 || got.ry != 0 {
+		t.Errorf("Expected forward to be relative to the takeoff heading, got %+v", got)
+	}
+
+	drone.ResetHeadlessHeading()
+	drone.ctrlMu.Lock()
+	got = drone.stickTargets(drone.headlessHeading())
+	drone.ctrlMu.Unlock()
+	if got.rx != 0 || got.ry != 16350 {
+		t.Errorf("Expected forward to be relative to the reset heading, got %+v", got)
+	}
+
+	drone.autoXY = true
+	drone.ResetHeadlessHeading()
+	drone.fd.IMU.Yaw = 0
+	drone.ctrlMu.Lock()
+	got = drone.stickTargets(drone.headlessHeading())
+	drone.ctrlMu.Unlock()
+	if got.rx != 0 || got.ry != 16350 {
+		t.Errorf("Expected the autopilot's sticks to be left alone, got %+v", got)
+	}
+}
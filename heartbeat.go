@@ -0,0 +1,53 @@
+// heartbeat.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "time"
+
+// groundedHeartbeatPeriod is the interval between heartbeats while grounded, see WithGroundedHeartbeat().
+const groundedHeartbeatPeriod = time.Second
+
+// WithGroundedHeartbeat stops the keepalive stick updates while the Tello is Grounded, as some
+// firmware can react to stick packets before takeoff.  A version query is sent every second instead
+// so that the drone still hears from us, and stick updates resume as soon as the Tello is airborne.
+// N.B. Stick inputs are ignored until then, so stick-based motor starts are not possible.
+func WithGroundedHeartbeat(enable bool) Option {
+	return func(tello *Tello) { tello.cfg.groundedHeartbeat = enable }
+}
+
+// sendKeepAlive sends the regular stick update, or a heartbeat if it is due and we are grounded
+// with WithGroundedHeartbeat() set.  lastBeat holds the time of the previous heartbeat.
+func (tello *Tello) sendKeepAlive(tick int, lastBeat *time.Time) {
+	congested := tello.adaptLink()
+	if tello.cfg.groundedHeartbeat && tello.GetFlightState() == StateGrounded {
+		now := tello.cfg.getClock().Now()
+		if lastBeat.IsZero() || now.Sub(*lastBeat) >= groundedHeartbeatPeriod {
+			tello.GetVersion()
+			*lastBeat = now
+		}
+		return
+	}
+	*lastBeat = time.Time{} // beat straight away if we land
+	if !congested || tick%2 == 0 {
+		tello.sendStickUpdate()
+	}
+}
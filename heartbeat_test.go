@@ -0,0 +1,65 @@
+// heartbeat_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroundedHeartbeat(t *testing.T) {
+	clock := &stepClock{t: time.Unix(1600000000, 0)}
+	drone := NewTello(WithClock(clock), WithGroundedHeartbeat(true))
+	drone.sendQ.running = true // queue packets, but with no writer to send them
+	sent := func() (sticks, queries int) {
+		drone.sendQ.mu.Lock()
+		defer drone.sendQ.mu.Unlock()
+		sticks, queries = len(drone.sendQ.queues[prioStick]), len(drone.sendQ.queues[prioQuery])
+		drone.sendQ.queues[prioStick], drone.sendQ.queues[prioQuery] = nil, nil
+		return sticks, queries
+	}
+
+	var lastBeat time.Time
+	for tick := 0; tick < 10; tick++ {
+		drone.sendKeepAlive(tick, &lastBeat)
+		clock.t = clock.t.Add(200 * time.Millisecond)
+	}
+	if sticks, queries := sent(); sticks != 0 || queries != 2 {
+		t.Errorf("Expected 2 heartbeats and no sticks while grounded, got %d and %d", queries, sticks)
+	}
+
+	drone.fdMu.Lock()
+	drone.fd.State = StateFlying
+	drone.fdMu.Unlock()
+	drone.sendKeepAlive(10, &lastBeat)
+	if sticks, queries := sent(); sticks != 1 || queries != 0 {
+		t.Errorf("Expected sticks and no heartbeat while flying, got %d and %d", sticks, queries)
+	}
+
+	// the default is to send sticks regardless
+	drone = NewTello(WithClock(clock))
+	drone.sendQ.running = true
+	drone.sendKeepAlive(0, &lastBeat)
+	if sticks, queries := sent(); sticks != 1 || queries != 0 {
+		t.Errorf("Expected sticks by default, got %d sticks and %d heartbeats", sticks, queries)
+	}
+}
@@ -0,0 +1,85 @@
+// history.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"sync"
+	"time"
+)
+
+// WithHistory keeps the last n FlightData samples in memory for History(), one is taken with
+// each flight status message, ie. about 10 per second.  The default is 0, no history.
+func WithHistory(n int) Option {
+	return func(tello *Tello) { tello.cfg.historySize = n }
+}
+
+// FlightSample is the FlightData as it was at Time.
+type FlightSample struct {
+	Time time.Time
+	FlightData
+}
+
+// historyRing holds the samples kept for History().
+type historyRing struct {
+	mu      sync.Mutex
+	samples []FlightSample
+	next    int // where the next sample goes once samples is full
+}
+
+// recordHistory adds the current FlightData to the history, if enabled.
+func (tello *Tello) recordHistory() {
+	n := tello.cfg.historySize
+	if n <= 0 {
+		return
+	}
+	sample := FlightSample{Time: tello.now(), FlightData: tello.GetFlightData()}
+	h := &tello.history
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.samples) < n {
+		h.samples = append(h.samples, sample)
+		return
+	}
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % n
+}
+
+// History returns the FlightData samples taken in the last since, oldest first, or all of the
+// samples kept if since is zero.  Samples are only kept if WithHistory() was set.
+// The result is a copy which the caller may keep, eg. to graph the height or compute a rate of climb.
+func (tello *Tello) History(since time.Duration) []FlightSample {
+	h := &tello.history
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ordered := make([]FlightSample, 0, len(h.samples))
+	ordered = append(ordered, h.samples[h.next:]...)
+	ordered = append(ordered, h.samples[:h.next]...)
+	if since <= 0 {
+		return ordered
+	}
+	from := tello.now().Add(-since)
+	first := len(ordered)
+	for first > 0 && !ordered[first-1].Time.Before(from) {
+		first--
+	}
+	return ordered[first:]
+}
@@ -0,0 +1,64 @@
+// history_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	clock := &stepClock{t: time.Unix(1600000000, 0)}
+	drone := NewTello(WithClock(clock), WithHistory(3))
+	if h := drone.History(0); len(h) != 0 {
+		t.Errorf("Expected no history yet, got %v", h)
+	}
+	for height := int16(1); height <= 5; height++ {
+		drone.fdMu.Lock()
+		drone.fd.Height = height
+		drone.fdMu.Unlock()
+		drone.recordHistory()
+		clock.t = clock.t.Add(100 * time.Millisecond)
+	}
+	heights := func(samples []FlightSample) (hs []int16) {
+		for _, s := range samples {
+			hs = append(hs, s.Height)
+		}
+		return hs
+	}
+	if hs := heights(drone.History(0)); len(hs) != 3 || hs[0] != 3 || hs[2] != 5 {
+		t.Errorf("Expected the last 3 heights oldest first, got %v", hs)
+	}
+	// the samples were taken 300ms, 200ms and 100ms ago
+	if hs := heights(drone.History(250 * time.Millisecond)); len(hs) != 2 || hs[0] != 4 {
+		t.Errorf("Expected the last 2 heights, got %v", hs)
+	}
+	if hs := drone.History(time.Millisecond); len(hs) != 0 {
+		t.Errorf("Expected nothing so recent, got %v", hs)
+	}
+
+	drone = NewTello()
+	drone.recordHistory()
+	if h := drone.History(0); len(h) != 0 {
+		t.Errorf("Expected no history by default, got %v", h)
+	}
+}
@@ -0,0 +1,144 @@
+// home.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"errors"
+)
+
+// ReturnToHomeConfig configures ReturnToHome().
+type ReturnToHomeConfig struct {
+	Speed  float32 // 0.25 to 1, the fraction of the autopilots' maximum speed to fly at, 0 means 1
+	Height int16   // height in decimetres to move to once above the takeoff point, 0 leaves it unchanged
+	Land   bool    // land once home
+}
+
+// TakeoffPosition returns the MVO position recorded when the drone last left the ground,
+// ok is false if it is not known, eg. because we connected in flight.
+func (tello *Tello) TakeoffPosition() (x, y float32, ok bool) {
+	tello.autoXYMu.RLock()
+	x, y, ok = tello.takeoffX, tello.takeoffY, tello.takeoffValid
+	tello.autoXYMu.RUnlock()
+	return x, y, ok
+}
+
+// recordTakeoff notes the MVO position as the drone leaves the ground, making it the
+// home point too unless one has already been set.
+func (tello *Tello) recordTakeoff(fd FlightData) {
+	tello.autoXYMu.Lock()
+	tello.takeoffX, tello.takeoffY = fd.MVO.PositionX, fd.MVO.PositionY
+	tello.takeoffValid = true
+	if !tello.homeValid {
+		tello.homeX, tello.homeY = tello.takeoffX, tello.takeoffY
+		tello.homeYaw = fd.IMU.Yaw
+		if tello.homeYaw < 0 {
+			tello.homeYaw += 360
+		}
+		tello.homeValid = true
+	}
+	tello.autoXYMu.Unlock()
+}
+
+// ReturnToHome flies the drone back to the position it took off from using AutoFlyToXY(), then
+// optionally changes height and lands.
+// N.B. The drone flies in a straight line at its current height: there is no obstacle
+// avoidance so it is up to the caller to ensure that the path is clear, to limit the speed
+// via cfg.Speed, and to call CancelReturnToHome() (or Hover()) if anything gets in the way.
+// The MVO must be working, so the usual AutoFlyToXY() restrictions on light and floor apply.
+// The func returns immediately and a Goroutine handles the navigation until either
+// it is complete or cancelled via CancelReturnToHome().
+// The caller may optionally listen on the 'done' channel for a signal that
+// the navigation is complete (or has been cancelled).
+func (tello *Tello) ReturnToHome(cfg ReturnToHomeConfig) (done chan error, err error) {
+	if cfg.Speed == 0 {
+		cfg.Speed = 1
+	}
+	if cfg.Height > AutoHeightLimitDm || cfg.Height < 0 {
+		return nil, errors.New("Verical navigation limit exceeded")
+	}
+
+	tello.autoXYMu.Lock()
+	switch {
+	case !tello.takeoffValid:
+		tello.autoXYMu.Unlock()
+		return nil, errors.New("Takeoff position is not known")
+	case tello.rthCancel != nil:
+		tello.autoXYMu.Unlock()
+		return nil, errors.New("Already returning home")
+	}
+	// AutoFlyToXY() works relative to the home point
+	targetX, targetY := tello.takeoffX-tello.homeX, tello.takeoffY-tello.homeY
+	ctx, cancel := context.WithCancel(context.Background())
+	tello.rthCancel = cancel
+	tello.autoXYMu.Unlock()
+
+	flying, err := tello.AutoFlyToXYConfig(targetX, targetY, cfg.Speed, cfg.Speed, AutoXYToleranceM)
+	if err != nil {
+		tello.CancelReturnToHome()
+		return nil, err
+	}
+
+	done = make(chan error, 1) // buffered so it won't block
+
+	go func() {
+		err := waitAutopilot(ctx, flying, nil, tello.CancelAutoFlyToXY)
+		if err == nil && cfg.Height != 0 {
+			climbing, herr := tello.AutoFlyToHeightConfig(cfg.Height, cfg.Speed, 0)
+			err = waitAutopilot(ctx, climbing, herr, tello.CancelAutoFlyToHeight)
+		}
+		if err == nil && cfg.Land {
+			if err = tello.LandAndWait(ctx); err == nil {
+				err = tello.WaitForLanding(ctx)
+			}
+		}
+		if err == context.Canceled {
+			err = errors.New("ReturnToHome cancelled")
+		}
+		tello.autoXYMu.Lock()
+		tello.rthCancel = nil
+		tello.autoXYMu.Unlock()
+		cancel()
+		done <- err
+		close(done)
+	}()
+
+	return done, nil
+}
+
+// CancelReturnToHome aborts any ReturnToHome() in progress, the drone should stop where it is.
+func (tello *Tello) CancelReturnToHome() {
+	tello.autoXYMu.Lock()
+	cancel := tello.rthCancel
+	tello.autoXYMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// IsReturningHome tests whether a ReturnToHome() is in progress.
+func (tello *Tello) IsReturningHome() (set bool) {
+	tello.autoXYMu.RLock()
+	set = tello.rthCancel != nil
+	tello.autoXYMu.RUnlock()
+	return set
+}
@@ -0,0 +1,99 @@
+// home_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReturnToHome(t *testing.T) {
+	drone := new(Tello)
+	if _, err := drone.ReturnToHome(ReturnToHomeConfig{}); err == nil {
+		t.Error("Expected ReturnToHome to fail before takeoff")
+	}
+
+	drone.fd.MVO.PositionX, drone.fd.MVO.PositionY = 1, 2
+	drone.updateFlightState(func(FlightState) FlightState { return StateTakingOff })
+	if x, y, ok := drone.TakeoffPosition(); !ok || x != 1 || y != 2 {
+		t.Fatalf("Expected the takeoff position to be recorded, got %v, %v, %v", x, y, ok)
+	}
+	if !drone.IsHomeSet() {
+		t.Error("Expected the takeoff position to become the home point")
+	}
+
+	// fly away, then back
+	drone.fdMu.Lock()
+	drone.fd.MVO.PositionX, drone.fd.MVO.PositionY = 4, 6
+	drone.fdMu.Unlock()
+	done, err := drone.ReturnToHome(ReturnToHomeConfig{Speed: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !drone.IsReturningHome() {
+		t.Error("Expected to be returning home")
+	}
+	if _, err := drone.ReturnToHome(ReturnToHomeConfig{}); err == nil {
+		t.Error("Expected a second ReturnToHome to be refused")
+	}
+	time.Sleep(100 * time.Millisecond)
+	drone.ctrlMu.RLock()
+	rx, ry := drone.ctrlRx, drone.ctrlRy
+	drone.ctrlMu.RUnlock()
+	if rx >= 0 || ry >= 0 {
+		t.Errorf("Expected to be heading back, got sticks %d, %d", rx, ry)
+	}
+	drone.fdMu.Lock()
+	drone.fd.MVO.PositionX, drone.fd.MVO.PositionY = 1.1, 1.9
+	drone.fdMu.Unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("ReturnToHome did not complete")
+	}
+	if drone.IsReturningHome() {
+		t.Error("Expected the return to be over")
+	}
+
+	// and abort
+	drone.fdMu.Lock()
+	drone.fd.MVO.PositionX = 5
+	drone.fdMu.Unlock()
+	if done, err = drone.ReturnToHome(ReturnToHomeConfig{Land: true}); err != nil {
+		t.Fatal(err)
+	}
+	drone.CancelReturnToHome()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected a cancelled return to report an error")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("ReturnToHome was not cancelled")
+	}
+	if drone.IsAutoXY() {
+		t.Error("Expected cancelling to stop AutoFlyToXY")
+	}
+}
@@ -0,0 +1,169 @@
+// interval.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+const (
+	pictureTransferTimeout = 15 * time.Second // how long we wait for a picture to arrive before giving up on it
+	intervalShotBufSize    = 10
+)
+
+// IntervalShot describes a picture taken by StartIntervalShooting().
+type IntervalShot struct {
+	Path       string     // where the picture was saved, "" if it was not
+	Taken      time.Time  // when the picture was requested
+	FlightData FlightData // the flight data when the picture was requested
+	Err        error      // why the picture was not saved, eg. a *TimeoutError if it never arrived
+}
+
+// WithPictureDir sets the directory in which StartIntervalShooting() saves pictures,
+// the default is the current directory.
+func WithPictureDir(dir string) Option {
+	return func(tello *Tello) { tello.cfg.pictureDir = dir }
+}
+
+// StartIntervalShooting takes a picture every period, eg. for a mapping survey, until StopIntervalShooting()
+// is called.  Each picture is saved as it arrives, named with the time it was taken and the drone's height and
+// yaw, eg. tello_20180521-143005.250_h1.5m_yaw+090.jpg, and described on the returned channel, which is closed
+// when shooting stops.  The channel is buffered, but if it is not consumed descriptions are lost.
+// Picture transfers take a while, so a picture is not requested until the previous one has arrived; if that
+// takes longer than period the next picture is taken as soon as it has.  Pictures arriving while shooting are
+// saved, and removed from memory, rather than being kept for SaveAllPics() or GrabFiles().
+func (tello *Tello) StartIntervalShooting(period time.Duration) (<-chan IntervalShot, error) {
+	if period <= 0 {
+		return nil, errors.New("Interval must be positive")
+	}
+	if !tello.ControlConnected() {
+		return nil, ErrNotConnected
+	}
+	tello.intervalMu.Lock()
+	defer tello.intervalMu.Unlock()
+	if tello.intervalStop != nil {
+		return nil, errors.New("Interval shooting already in progress")
+	}
+	stop := make(chan struct{})
+	tello.intervalStop = stop
+	shots := make(chan IntervalShot, intervalShotBufSize)
+	files, stopFiles := tello.ListenFiles()
+	go tello.intervalShooter(period, stop, shots, files, stopFiles)
+	return shots, nil
+}
+
+// StopIntervalShooting stops StartIntervalShooting(), a picture already requested is not saved.
+func (tello *Tello) StopIntervalShooting() {
+	tello.intervalMu.Lock()
+	defer tello.intervalMu.Unlock()
+	if tello.intervalStop != nil {
+		close(tello.intervalStop)
+		tello.intervalStop = nil
+	}
+}
+
+// IsIntervalShooting reports whether StartIntervalShooting() is in progress.
+func (tello *Tello) IsIntervalShooting() bool {
+	tello.intervalMu.Lock()
+	defer tello.intervalMu.Unlock()
+	return tello.intervalStop != nil
+}
+
+func (tello *Tello) intervalShooter(period time.Duration, stop chan struct{}, shots chan IntervalShot,
+	files chan FileData, stopFiles func()) {
+	defer close(shots)
+	defer func() {
+		// reassembleFile() may be blocked sending to us, so keep draining until the channel is closed
+		go stopFiles()
+		for range files {
+		}
+	}()
+	clock := tello.cfg.getClock()
+	var (
+		pending  *IntervalShot // the picture we are waiting for, if any
+		due      = true        // the period has elapsed since the last picture was requested
+		next     = clock.After(period)
+		transfer <-chan time.Time
+	)
+	report := func(shot *IntervalShot) {
+		select {
+		case shots <- *shot:
+		default:
+		}
+		pending, transfer = nil, nil
+	}
+	for {
+		if due && pending == nil {
+			due = false
+			pending = &IntervalShot{Taken: tello.now(), FlightData: tello.GetFlightData()}
+			transfer = clock.After(pictureTransferTimeout)
+			tello.TakePicture()
+		}
+		select {
+		case <-stop:
+			return
+		case <-next:
+			due = true
+			next = clock.After(period)
+		case f, ok := <-files:
+			if !ok {
+				return
+			}
+			if pending == nil || f.FileType != FtJPEG {
+				continue
+			}
+			pending.Path = filepath.Join(tello.cfg.pictureDir, pictureName(pending.Taken, pending.FlightData))
+			if pending.Err = ioutil.WriteFile(pending.Path, f.FileBytes, 0644); pending.Err != nil {
+				pending.Path = ""
+			}
+			tello.forgetFile(f)
+			report(pending)
+		case <-transfer:
+			pending.Err = &TimeoutError{Op: "picture"}
+			report(pending)
+		}
+	}
+}
+
+// pictureName returns a file name for a picture taken at t with flight data fd.
+func pictureName(t time.Time, fd FlightData) string {
+	return fmt.Sprintf("%s_%s_h%.1fm_yaw%+04.0f.jpg", fileNamePrefix(fd.SerialNumber, "_"), t.Format("20060102-150405.000"), fd.HeightM(), fd.IMU.Yaw)
+}
+
+// forgetFile removes a reassembled file from memory.
+func (tello *Tello) forgetFile(f FileData) {
+	if len(f.FileBytes) == 0 {
+		return
+	}
+	tello.fdMu.Lock()
+	defer tello.fdMu.Unlock()
+	for i, kept := range tello.files {
+		if len(kept.FileBytes) > 0 && &kept.FileBytes[0] == &f.FileBytes[0] {
+			tello.files = append(tello.files[:i], tello.files[i+1:]...)
+			return
+		}
+	}
+}
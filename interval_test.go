@@ -0,0 +1,109 @@
+// interval_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// receivePicture makes the drone behave as though a picture has just been transferred.
+func receivePicture(drone *Tello, data []byte) {
+	drone.fdMu.Lock()
+	drone.fileTemp = fileInternal{filetype: FtJPEG, accumSize: len(data),
+		pieces: []filePiece{{numChunks: 1, chunks: []fileChunk{{chunkData: data}}}}}
+	drone.fdMu.Unlock()
+	drone.reassembleFile()
+}
+
+func TestIntervalShooting(t *testing.T) {
+	if _, err := new(Tello).StartIntervalShooting(time.Second); err != ErrNotConnected {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+	drone := ackingDrone(t)
+	drone.cfg.pictureDir = t.TempDir()
+	drone.fd.Height = 12
+	drone.fd.IMU.Yaw = 45
+	shots, err := drone.StartIntervalShooting(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := drone.StartIntervalShooting(time.Hour); err == nil {
+		t.Error("Expected an error starting twice")
+	}
+	if !drone.IsIntervalShooting() {
+		t.Error("Expected to be shooting")
+	}
+
+	// the first picture is taken immediately
+	receivePicture(drone, []byte{0xff, 0xd8, 0xff})
+	var shot IntervalShot
+	select {
+	case shot = <-shots:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for a picture")
+	}
+	if shot.Err != nil {
+		t.Fatal(shot.Err)
+	}
+	if filepath.Dir(shot.Path) != drone.cfg.pictureDir || !strings.HasSuffix(shot.Path, "_h1.2m_yaw+045.jpg") {
+		t.Errorf("Unexpected path %s", shot.Path)
+	}
+	if data, err := ioutil.ReadFile(shot.Path); err != nil || !bytes.Equal(data, []byte{0xff, 0xd8, 0xff}) {
+		t.Errorf("Unexpected picture % x, %v", data, err)
+	}
+	if drone.NumPics() != 0 {
+		t.Error("Expected the saved picture to be removed from memory")
+	}
+
+	drone.StopIntervalShooting()
+	if _, ok := <-shots; ok {
+		t.Error("Expected the channel to be closed")
+	}
+	if drone.IsIntervalShooting() {
+		t.Error("Expected shooting to have stopped")
+	}
+	// pictures are no longer taken by the shooter, and it no longer holds up file listeners
+	receivePicture(drone, []byte{1})
+	if drone.NumPics() != 1 {
+		t.Error("Expected the picture to be kept")
+	}
+}
+
+func TestPictureName(t *testing.T) {
+	var fd FlightData
+	fd.Height = -3
+	fd.IMU.Yaw = -90.4
+	got := pictureName(time.Date(2018, 5, 21, 14, 30, 5, 250e6, time.UTC), fd)
+	if want := "tello_20180521-143005.250_h-0.3m_yaw-090.jpg"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	fd.SerialNumber = "0TQDG7REDB0N8X"
+	got = pictureName(time.Date(2018, 5, 21, 14, 30, 5, 250e6, time.UTC), fd)
+	if want := "tello_0TQDG7REDB0N8X_20180521-143005.250_h-0.3m_yaw-090.jpg"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
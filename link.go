@@ -0,0 +1,174 @@
+// link.go
+
+// Link quality estimation and congestion-aware sending.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	linkSmoothing      = 0.1                   // weight of each new sample in the link averages
+	linkGoodLatency    = 50 * time.Millisecond // ack latency which costs nothing in the score
+	linkBadLatency     = ackTimeout            // ack latency which scores zero
+	linkCongestedBelow = 40                    // LinkQuality() below which we throttle, if enabled
+	linkRecoveredAbove = 60                    // LinkQuality() above which we stop throttling
+)
+
+// linkStats holds the running measurements used by LinkQuality().
+type linkStats struct {
+	mu          sync.Mutex
+	wifiSeen    bool
+	ackLatency  time.Duration // smoothed
+	latencySeen bool
+	videoLoss   float64 // smoothed fraction of video packets lost
+	videoSeen   bool
+	lastFrame   uint8 // video frame and sub-packet numbers of the previous video packet
+	lastSub     uint8
+	lastEnd     bool // was the previous video packet the last of its frame?
+	congested   bool
+}
+
+// recordWifi notes that FlightData now holds the WiFi strength and interference.
+func (ls *linkStats) recordWifi() {
+	ls.mu.Lock()
+	ls.wifiSeen = true
+	ls.mu.Unlock()
+}
+
+// recordAckLatency adds a command round-trip time to the link statistics.
+func (ls *linkStats) recordAckLatency(rtt time.Duration) {
+	ls.mu.Lock()
+	if ls.latencySeen {
+		ls.ackLatency += time.Duration(linkSmoothing * float64(rtt-ls.ackLatency))
+	} else {
+		ls.ackLatency, ls.latencySeen = rtt, true
+	}
+	ls.mu.Unlock()
+}
+
+// recordVideoPacket checks the frame and sub-packet numbers at the start of each video packet
+// for gaps, and adds the result to the link statistics.
+func (ls *linkStats) recordVideoPacket(frame, sub uint8) {
+	end := sub&0x80 != 0
+	sub &= 0x7f
+	lost := 0
+	ls.mu.Lock()
+	if ls.videoSeen {
+		switch {
+		case frame == ls.lastFrame:
+			if sub > ls.lastSub+1 {
+				lost = int(sub - ls.lastSub - 1)
+			}
+		default:
+			lost = int(frame-ls.lastFrame-1) + int(sub) // we can't know how many packets a whole missing frame had
+			if !ls.lastEnd {
+				lost++
+			}
+		}
+		sample := float64(lost) / float64(lost+1)
+		ls.videoLoss += linkSmoothing * (sample - ls.videoLoss)
+	}
+	ls.videoSeen = true
+	ls.lastFrame, ls.lastSub, ls.lastEnd = frame, sub, end
+	ls.mu.Unlock()
+}
+
+// LinkQuality returns a score from 0 (unusable) to 100 (perfect) for the connection to the Tello.
+// It is the worst of the scores for WiFi strength and interference, command acknowledgement
+// latency and, if video is streaming, video packet loss.
+func (tello *Tello) LinkQuality() int {
+	tello.fdMu.RLock()
+	strength, interference := int(tello.fd.WifiStrength), int(tello.fd.WifiInterference)
+	tello.fdMu.RUnlock()
+	score := 100
+	ls := &tello.link
+	ls.mu.Lock()
+	if ls.wifiSeen {
+		score = strength - interference/2
+	}
+	if ls.latencySeen {
+		if s := latencyScore(ls.ackLatency); s < score {
+			score = s
+		}
+	}
+	if ls.videoSeen {
+		if s := 100 - int(ls.videoLoss*200); s < score {
+			score = s
+		}
+	}
+	ls.mu.Unlock()
+	if score < 0 {
+		return 0
+	}
+	if score > 100 {
+		return 100
+	}
+	return score
+}
+
+func latencyScore(lat time.Duration) int {
+	switch {
+	case lat <= linkGoodLatency:
+		return 100
+	case lat >= linkBadLatency:
+		return 0
+	}
+	return int(100 * (linkBadLatency - lat) / (linkBadLatency - linkGoodLatency))
+}
+
+// WithAdaptiveLink enables congestion-aware sending: while LinkQuality() is poor the video bitrate is
+// reduced to 1Mbps and stick updates are sent half as often, both are restored once the link recovers.
+func WithAdaptiveLink(enable bool) Option {
+	return func(tello *Tello) { tello.cfg.adaptiveLink = enable }
+}
+
+// adaptLink updates the congested state from LinkQuality(), with some hysteresis, and applies
+// or removes the throttling.  It returns true if we are congested.
+func (tello *Tello) adaptLink() bool {
+	if !tello.cfg.adaptiveLink {
+		return false
+	}
+	q := tello.LinkQuality()
+	ls := &tello.link
+	ls.mu.Lock()
+	was := ls.congested
+	switch {
+	case !was && q < linkCongestedBelow:
+		ls.congested = true
+	case was && q > linkRecoveredAbove:
+		ls.congested = false
+	}
+	now := ls.congested
+	ls.mu.Unlock()
+	if now != was {
+		tello.logf("Link quality %d, congestion throttling: %v\n", q, now)
+		if now {
+			tello.SetVideoBitrate(Vbr1M)
+		} else {
+			tello.SetVideoBitrate(VbrAuto)
+		}
+	}
+	return now
+}
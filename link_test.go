@@ -0,0 +1,104 @@
+// tello project link_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyScore(t *testing.T) {
+	if s := latencyScore(10 * time.Millisecond); s != 100 {
+		t.Errorf("Expected 100 for a fast link, got %d", s)
+	}
+	if s := latencyScore(time.Second); s != 0 {
+		t.Errorf("Expected 0 for a slow link, got %d", s)
+	}
+	if s := latencyScore((linkGoodLatency + linkBadLatency) / 2); s != 50 {
+		t.Errorf("Expected 50 half way, got %d", s)
+	}
+}
+
+func TestRecordVideoPacket(t *testing.T) {
+	var ls linkStats
+	for f := 0; f < 10; f++ {
+		ls.recordVideoPacket(uint8(f), 0)
+		ls.recordVideoPacket(uint8(f), 1)
+		ls.recordVideoPacket(uint8(f), 0x82)
+	}
+	if ls.videoLoss != 0 {
+		t.Errorf("Expected no loss, got %f", ls.videoLoss)
+	}
+	ls.recordVideoPacket(10, 0)
+	ls.recordVideoPacket(10, 3) // lost 1 and 2
+	if ls.videoLoss == 0 {
+		t.Error("Expected loss to be detected within a frame")
+	}
+	loss := ls.videoLoss
+	ls.recordVideoPacket(12, 0) // lost the end of frame 10 and all of frame 11
+	if ls.videoLoss <= loss {
+		t.Error("Expected loss to be detected across frames")
+	}
+	ls.recordVideoPacket(255, 0x80)
+	ls.videoLoss = 0
+	ls.recordVideoPacket(0, 0) // frame numbers wrap
+	if ls.videoLoss != 0 {
+		t.Errorf("Expected no loss on wrapping, got %f", ls.videoLoss)
+	}
+}
+
+func TestLinkQuality(t *testing.T) {
+	tello := new(Tello)
+	if q := tello.LinkQuality(); q != 100 {
+		t.Errorf("Expected 100 with no data, got %d", q)
+	}
+	tello.fd.WifiStrength, tello.fd.WifiInterference = 90, 20
+	tello.link.recordWifi()
+	if q := tello.LinkQuality(); q != 80 {
+		t.Errorf("Expected 80 from WiFi, got %d", q)
+	}
+	tello.link.recordAckLatency(linkBadLatency)
+	if q := tello.LinkQuality(); q != 0 {
+		t.Errorf("Expected latency to dominate, got %d", q)
+	}
+}
+
+func TestAdaptLink(t *testing.T) {
+	tello, fake := pushingDrone(t, WithAdaptiveLink(true))
+	tello.link.recordWifi()
+
+	tello.fd.WifiStrength = 30
+	if !tello.adaptLink() {
+		t.Error("Expected to be congested")
+	}
+	if pkts := fake.recv(200 * time.Millisecond); len(pkts) != 1 || pkts[0].messageID != MsgSetVideoBitrate || pkts[0].payload[0] != byte(Vbr1M) {
+		t.Errorf("Expected the bitrate to be reduced, got %+v", pkts)
+	}
+	tello.fd.WifiStrength = 50
+	if !tello.adaptLink() {
+		t.Error("Expected to stay congested until the link has fully recovered")
+	}
+	tello.fd.WifiStrength = 90
+	if tello.adaptLink() {
+		t.Error("Expected congestion to end")
+	}
+}
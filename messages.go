@@ -24,7 +24,10 @@ package tello
 import (
 	"encoding/binary"
 	"math"
+	"strings"
 	"time"
+
+	"github.com/SMerrony/tello/protocol"
 )
 
 const msgHdr = 0xcc // 204
@@ -38,7 +41,7 @@ type packet struct {
 	toDrone       bool
 	packetType    uint8 // 3-bit
 	packetSubtype uint8 // 3-bit
-	messageID     uint16
+	messageID     MessageID
 	sequence      uint16
 	payload       []byte
 	crc16         uint16
@@ -56,57 +59,66 @@ const (
 	ptFlip     = 6
 )
 
+// MessageID identifies a Tello message, it is carried in every packet on the control connection.
+type MessageID uint16
+
+// String returns the name of the message as listed by the protocol package, eg. "DoTakeoff",
+// or its hex value if it is not known.
+func (id MessageID) String() string {
+	return protocol.Name(uint16(id))
+}
+
 // Tello message IDs
 const (
-	msgDoConnect           = 0x0001 // 1
-	msgConnected           = 0x0002 // 2
-	msgQuerySSID           = 0x0011 // 17
-	msgSetSSID             = 0x0012 // 18
-	msgQuerySSIDPass       = 0x0013 // 19
-	msgSetSSIDPass         = 0x0014 // 20
-	msgQueryWifiRegion     = 0x0015 // 21
-	msgSetWifiRegion       = 0x0016 // 22
-	msgWifiStrength        = 0x001a // 26
-	msgSetVideoBitrate     = 0x0020 // 32
-	msgSetDynAdjRate       = 0x0021 // 33
-	msgEisSetting          = 0x0024 // 36
-	msgQueryVideoSPSPPS    = 0x0025 // 37
-	msgQueryVideoBitrate   = 0x0028 // 40
-	msgDoTakePic           = 0x0030 // 48
-	msgSwitchPicVideo      = 0x0031 // 49
-	msgDoStartRec          = 0x0032 // 50
-	msgExposureVals        = 0x0034 // 52 (Get or set?)
-	msgLightStrength       = 0x0035 // 53
-	msgQueryJPEGQuality    = 0x0037 // 55
-	msgError1              = 0x0043 // 67
-	msgError2              = 0x0044 // 68
-	msgQueryVersion        = 0x0045 // 69
-	msgSetDateTime         = 0x0046 // 70
-	msgQueryActivationTime = 0x0047 // 71
-	msgQueryLoaderVersion  = 0x0049 // 73
-	msgSetStick            = 0x0050 // 80
-	msgDoTakeoff           = 0x0054 // 84
-	msgDoLand              = 0x0055 // 85
-	msgFlightStatus        = 0x0056 // 86
-	msgSetHeightLimit      = 0x0058 // 88
-	msgDoFlip              = 0x005c // 92
-	msgDoThrowTakeoff      = 0x005d // 93
-	msgDoPalmLand          = 0x005e // 94
-	msgFileSize            = 0x0062 // 98
-	msgFileData            = 0x0063 // 99
-	msgFileDone            = 0x0064 // 100
-	msgDoSmartVideo        = 0x0080 // 128
-	msgSmartVideoStatus    = 0x0081 // 129
-	msgLogHeader           = 0x1050 // 4176
-	msgLogData             = 0x1051 // 4177
-	msgLogConfig           = 0x1052 // 4178
-	msgDoBounce            = 0x1053 // 4179
-	msgDoCalibration       = 0x1054 // 4180
-	msgSetLowBattThresh    = 0x1055 // 4181
-	msgQueryHeightLimit    = 0x1056 // 4182
-	msgQueryLowBattThresh  = 0x1057 // 4183
-	msgSetAttitude         = 0x1058 // 4184
-	msgQueryAttitude       = 0x1059 // 4185
+	MsgDoConnect           MessageID = 0x0001 // 1
+	MsgConnected           MessageID = 0x0002 // 2
+	MsgQuerySSID           MessageID = 0x0011 // 17
+	MsgSetSSID             MessageID = 0x0012 // 18
+	MsgQuerySSIDPass       MessageID = 0x0013 // 19
+	MsgSetSSIDPass         MessageID = 0x0014 // 20
+	MsgQueryWifiRegion     MessageID = 0x0015 // 21
+	MsgSetWifiRegion       MessageID = 0x0016 // 22
+	MsgWifiStrength        MessageID = 0x001a // 26
+	MsgSetVideoBitrate     MessageID = 0x0020 // 32
+	MsgSetDynAdjRate       MessageID = 0x0021 // 33
+	MsgEisSetting          MessageID = 0x0024 // 36
+	MsgQueryVideoSPSPPS    MessageID = 0x0025 // 37
+	MsgQueryVideoBitrate   MessageID = 0x0028 // 40
+	MsgDoTakePic           MessageID = 0x0030 // 48
+	MsgSwitchPicVideo      MessageID = 0x0031 // 49
+	MsgDoStartRec          MessageID = 0x0032 // 50
+	MsgExposureVals        MessageID = 0x0034 // 52 (Get or set?)
+	MsgLightStrength       MessageID = 0x0035 // 53
+	MsgQueryJPEGQuality    MessageID = 0x0037 // 55
+	MsgError1              MessageID = 0x0043 // 67
+	MsgError2              MessageID = 0x0044 // 68
+	MsgQueryVersion        MessageID = 0x0045 // 69
+	MsgSetDateTime         MessageID = 0x0046 // 70
+	MsgQueryActivationTime MessageID = 0x0047 // 71
+	MsgQueryLoaderVersion  MessageID = 0x0049 // 73
+	MsgSetStick            MessageID = 0x0050 // 80
+	MsgDoTakeoff           MessageID = 0x0054 // 84
+	MsgDoLand              MessageID = 0x0055 // 85
+	MsgFlightStatus        MessageID = 0x0056 // 86
+	MsgSetHeightLimit      MessageID = 0x0058 // 88
+	MsgDoFlip              MessageID = 0x005c // 92
+	MsgDoThrowTakeoff      MessageID = 0x005d // 93
+	MsgDoPalmLand          MessageID = 0x005e // 94
+	MsgFileSize            MessageID = 0x0062 // 98
+	MsgFileData            MessageID = 0x0063 // 99
+	MsgFileDone            MessageID = 0x0064 // 100
+	MsgDoSmartVideo        MessageID = 0x0080 // 128
+	MsgSmartVideoStatus    MessageID = 0x0081 // 129
+	MsgLogHeader           MessageID = 0x1050 // 4176
+	MsgLogData             MessageID = 0x1051 // 4177
+	MsgLogConfig           MessageID = 0x1052 // 4178
+	MsgDoBounce            MessageID = 0x1053 // 4179
+	MsgDoCalibration       MessageID = 0x1054 // 4180
+	MsgSetLowBattThresh    MessageID = 0x1055 // 4181
+	MsgQueryHeightLimit    MessageID = 0x1056 // 4182
+	MsgQueryLowBattThresh  MessageID = 0x1057 // 4183
+	MsgSetAttitude         MessageID = 0x1058 // 4184
+	MsgQueryAttitude       MessageID = 0x1059 // 4185
 )
 
 // FlipType represents a flip direction.
@@ -192,65 +204,97 @@ type fileChunk struct {
 // This data is not all sent at once from the drone, different fields may be updated
 // at varying rates.
 type FlightData struct {
-	BatteryCritical          bool
-	BatteryLow               bool
-	BatteryMilliVolts        int16
-	BatteryPercentage        int8
-	BatteryState             bool
-	CameraState              uint8
-	DownVisualState          bool
-	DroneFlyTimeLeft         int16
-	DroneHover               bool
-	EastSpeed                int16
-	ElectricalMachineryState uint8
-	EmOpen                   bool
-	ErrorState               bool
-	FactoryMode              bool
-	Flying                   bool
-	FlyMode                  uint8
-	FlyTime                  int16
-	FrontIn                  bool
-	FrontLSC                 bool
-	FrontOut                 bool
-	GravityState             bool
-	GroundSpeed              int16
-	Height                   int16 // seems to be in decimetres
-	IMU                      IMUData
-	ImuCalibrationState      int8
-	ImuState                 bool
-	LightStrength            uint8
-	LightStrengthUpdated     time.Time
-	LowBatteryThreshold      uint8
-	MaxHeight                uint8
-	MVO                      MVOData
-	NorthSpeed               int16
-	OnGround                 bool
-	OutageRecording          bool
-	PowerState               bool
-	PressureState            bool
-	SmartVideoExitMode       int16
-	SSID                     string
-	ThrowFlyTimer            int8
-	Version                  string
-	VerticalSpeed            int16
-	VideoBitrate             VBR
-	WifiInterference         uint8
-	WifiStrength             uint8
-	WindState                bool
+	ActivationTime           time.Time     `json:"activation_time"` // when the drone was activated, zero if unknown or never
+	BatteryCritical          bool          `json:"battery_critical"`
+	BatteryLow               bool          `json:"battery_low"`
+	BatteryMilliVolts        int16         `json:"battery_milli_volts"`
+	BatteryPercentage        int8          `json:"battery_percentage"`
+	BatteryState             bool          `json:"battery_state"`
+	CameraState              uint8         `json:"camera_state"`
+	DownVisualState          bool          `json:"down_visual_state"`
+	DroneFlyTimeLeft         int16         `json:"drone_fly_time_left"`
+	DroneHover               bool          `json:"drone_hover"`
+	EastSpeed                int16         `json:"east_speed"`
+	ElectricalMachineryState uint8         `json:"electrical_machinery_state"`
+	EmOpen                   bool          `json:"em_open"`
+	ErrorState               bool          `json:"error_state"` // same as TemperatureHigh, kept for compatibility
+	FactoryMode              bool          `json:"factory_mode"`
+	Flying                   bool          `json:"flying"`
+	FlyMode                  uint8         `json:"fly_mode"`
+	FlyTime                  int16         `json:"fly_time"`
+	FrontIn                  bool          `json:"front_in"`
+	FrontLSC                 bool          `json:"front_lsc"`
+	FrontOut                 bool          `json:"front_out"`
+	GravityState             bool          `json:"gravity_state"`
+	GroundSpeed              int16         `json:"ground_speed"`          // horizontal speed derived from NorthSpeed and EastSpeed
+	GroundSpeedSmoothed      float32       `json:"ground_speed_smoothed"` // GroundSpeed with short-term noise filtered out
+	Height                   int16         `json:"height"`                // decimetres above the takeoff point, see HeightM() and HeightCm()
+	HeightSmoothed           float32       `json:"height_smoothed"`       // Height with short-term noise filtered out, see WithSmoothing()
+	IMU                      IMUData       `json:"imu"`
+	ImuCalibrationState      int8          `json:"imu_calibration_state"`
+	ImuState                 bool          `json:"imu_state"` // false if the IMU has a problem, see EvIMUWarning
+	LightStrength            uint8         `json:"light_strength"`
+	LightStrengthUpdated     time.Time     `json:"light_strength_updated"`
+	LowBatteryThreshold      uint8         `json:"low_battery_threshold"`
+	MaxHeight                uint8         `json:"max_height"`
+	MissionPad               MissionPad    `json:"mission_pad"`
+	MVO                      MVOData       `json:"mvo"`
+	NorthSpeed               int16         `json:"north_speed"`
+	OnGround                 bool          `json:"on_ground"`
+	OutageRecording          bool          `json:"outage_recording"`
+	PowerState               bool          `json:"power_state"`
+	PressureState            bool          `json:"pressure_state"`
+	SerialNumber             string        `json:"serial_number"`       // if known, see WithSerialNumber()
+	SessionDistance          float32       `json:"session_distance"`    // metres flown since ControlConnect(), integrated from MVO velocity
+	SessionFlyTime           time.Duration `json:"session_fly_time_ns"` // time spent flying since ControlConnect()
+	SmartVideoExitMode       int16         `json:"smart_video_exit_mode"`
+	SSID                     string        `json:"ssid"`
+	State                    FlightState   `json:"state"`            // derived by the package from the status data and command acks
+	TemperatureHigh          bool          `json:"temperature_high"` // the drone is overheating and may force-land, see EvOverheat
+	ThrowFlyTimer            int8          `json:"throw_fly_timer"`
+	ToF                      int16         `json:"tof"`               // downward distance sensor reading in centimetres, only sent via the text SDK
+	TotalDistance            float32       `json:"total_distance"`    // as SessionDistance, but kept across reconnections, see ResetOdometer()
+	TotalFlyTime             time.Duration `json:"total_fly_time_ns"` // as SessionFlyTime, but kept across reconnections
+	Version                  string        `json:"firmware_version"`
+	VerticalSpeed            int16         `json:"vertical_speed"`          // climb rate, positive when ascending
+	VerticalSpeedSmoothed    float32       `json:"vertical_speed_smoothed"` // VerticalSpeed with short-term noise filtered out
+	VideoBitrate             VBR           `json:"video_bitrate"`
+	WifiInterference         uint8         `json:"wifi_interference"`
+	WifiRegion               string        `json:"wifi_region"` // country code which sets the permitted WiFi power, eg. "US"
+	WifiStrength             uint8         `json:"wifi_strength"`
+	WifiStrengthSmoothed     float32       `json:"wifi_strength_smoothed"` // WifiStrength with short-term noise filtered out
+	WindState                bool          `json:"wind_state"`             // the drone is struggling against the wind, see EvWindWarning
+}
+
+// HeightM returns the Height in metres.
+func (fd FlightData) HeightM() float32 {
+	return float32(fd.Height) / 10
+}
+
+// HeightCm returns the Height in centimetres.
+func (fd FlightData) HeightCm() int {
+	return int(fd.Height) * 10
 }
 
 // MVOData comes from the flight log messages
 type MVOData struct {
-	PositionX, PositionY, PositionZ float32
-	VelocityX, VelocityY, VelocityZ int16
+	PositionX float32 `json:"position_x"`
+	PositionY float32 `json:"position_y"`
+	PositionZ float32 `json:"position_z"`
+	VelocityX int16   `json:"velocity_x"` // cm/s
+	VelocityY int16   `json:"velocity_y"` // cm/s
+	VelocityZ int16   `json:"velocity_z"` // cm/s
 }
 
 // IMUData comes from the flight log messages
 type IMUData struct {
-	QuaternionW,
-	QuaternionX, QuaternionY, QuaternionZ float32
-	Temperature int16
-	Yaw         float32 // derived from Quat fields, -180 > degrees > +180
+	QuaternionW  float32 `json:"quaternion_w"`
+	QuaternionX  float32 `json:"quaternion_x"`
+	QuaternionY  float32 `json:"quaternion_y"`
+	QuaternionZ  float32 `json:"quaternion_z"`
+	Temperature  int16   `json:"temperature"`
+	Yaw          float32 `json:"yaw"`           // derived from Quat fields, -180 > degrees > +180
+	BaroAltitude float32 `json:"baro_altitude"` // barometric altitude in metres, this drifts and is not zeroed at takeoff
 }
 
 // StickMessage holds the signed 16-bit values of a joystick update.
@@ -279,6 +323,28 @@ const (
 
 // utility funcs for message handling
 
+// parsePacket checks that buff holds a complete packet with valid CRCs before converting it
+// with bufferToPacket, a *PacketError is returned if it does not.
+func parsePacket(buff []byte) (pkt packet, err error) {
+	if len(buff) < minPktSize {
+		return pkt, &PacketError{Reason: "too short", Data: buff}
+	}
+	if buff[0] != msgHdr {
+		return pkt, &PacketError{Reason: "bad header", Data: buff}
+	}
+	size := int(uint16(buff[1])+uint16(buff[2])<<8) >> 3
+	if size < minPktSize || size > len(buff) {
+		return pkt, &PacketError{Reason: "bad size", Data: buff}
+	}
+	if calculateCRC8(buff[0:3]) != buff[3] {
+		return pkt, &PacketError{Reason: "bad CRC8", Data: buff}
+	}
+	if calculateCRC16(buff[0:size-2]) != uint16(buff[size-1])<<8|uint16(buff[size-2]) {
+		return pkt, &PacketError{Reason: "bad CRC16", Data: buff}
+	}
+	return bufferToPacket(buff), nil
+}
+
 // bufferToPacket takes a raw buffer of bytes and populates our packet struct
 func bufferToPacket(buff []byte) (pkt packet) {
 	pkt.header = buff[0]
@@ -288,7 +354,7 @@ func bufferToPacket(buff []byte) (pkt packet) {
 	pkt.toDrone = (buff[4] & 0x40) == 1
 	pkt.packetType = uint8((buff[4] >> 3) & 0x07)
 	pkt.packetSubtype = uint8(buff[4] & 0x07)
-	pkt.messageID = (uint16(buff[6]) << 8) | uint16(buff[5])
+	pkt.messageID = MessageID(buff[6])<<8 | MessageID(buff[5])
 	pkt.sequence = (uint16(buff[8]) << 8) | uint16(buff[7])
 	payloadSize := pkt.size13 - 11
 	if payloadSize > 0 {
@@ -300,7 +366,7 @@ func bufferToPacket(buff []byte) (pkt packet) {
 }
 
 // newPacket returns a packet with some fields populated
-func newPacket(pt uint8, cmd uint16, seq uint16, payloadSize int) (pkt packet) {
+func newPacket(pt uint8, cmd MessageID, seq uint16, payloadSize int) (pkt packet) {
 	pkt.header = msgHdr
 	pkt.toDrone = true
 	pkt.packetType = pt
@@ -314,10 +380,16 @@ func newPacket(pt uint8, cmd uint16, seq uint16, payloadSize int) (pkt packet) {
 
 // pack the packet into raw buffer format and calculate CRCs etc.
 func packetToBuffer(pkt packet) (buff []byte) {
-	// create a buffer of the right size
+	return appendPacket(make([]byte, 0, minPktSize+len(pkt.payload)), pkt)
+}
+
+// appendPacket packs the packet onto the end of dst, it does not allocate if dst has room.
+func appendPacket(dst []byte, pkt packet) []byte {
 	payloadSize := len(pkt.payload)
 	packetSize := minPktSize + payloadSize
-	buff = make([]byte, packetSize)
+	start := len(dst)
+	dst = append(dst, make([]byte, packetSize)...)
+	buff := dst[start:]
 
 	// copy each field, manipulating if necessary
 	buff[0] = pkt.header
@@ -336,20 +408,19 @@ func packetToBuffer(pkt packet) (buff []byte) {
 	buff[7] = byte(pkt.sequence)
 	buff[8] = byte(pkt.sequence >> 8)
 
-	for p := 0; p < payloadSize; p++ {
-		buff[9+p] = pkt.payload[p]
-	}
+	copy(buff[9:], pkt.payload)
 	crc16 := calculateCRC16(buff[0 : 9+payloadSize])
 	buff[9+payloadSize] = byte(crc16)
 	buff[10+payloadSize] = byte(crc16 >> 8)
 
-	return buff
+	return dst
 }
 
 func payloadToFlightData(pl []byte) (fd FlightData) {
 	fd.Height = int16(pl[0]) + int16(pl[1])<<8
 	fd.NorthSpeed = int16(uint16(pl[2]) | uint16(pl[3])<<8)
 	fd.EastSpeed = int16(pl[4]) | int16(pl[5])<<8
+	fd.GroundSpeed = groundSpeed(fd.NorthSpeed, fd.EastSpeed)
 	fd.VerticalSpeed = int16(pl[6]) | int16(pl[7])<<8
 	fd.FlyTime = int16(pl[8]) | int16(pl[9])<<8
 
@@ -384,11 +455,34 @@ func payloadToFlightData(pl []byte) (fd FlightData) {
 	fd.FrontIn = (pl[22] & 1) == 1
 	fd.FrontOut = (pl[22] >> 1 & 1) == 1
 	fd.FrontLSC = (pl[22] >> 2 & 1) == 1
-	fd.ErrorState = (pl[23] & 1) == 1
+	fd.TemperatureHigh = (pl[23] & 1) == 1
+	fd.ErrorState = fd.TemperatureHigh
 
 	return fd
 }
 
+// payloadToActivationTime decodes the reply to an activation time query: a result byte followed
+// by the activation time in seconds since the Unix epoch (little-endian), 0 if never activated.
+func payloadToActivationTime(pl []byte) (at time.Time, ok bool) {
+	if len(pl) < 5 || pl[0] != 0 {
+		return at, false
+	}
+	secs := uint32(pl[1]) | uint32(pl[2])<<8 | uint32(pl[3])<<16 | uint32(pl[4])<<24
+	if secs == 0 {
+		return at, true
+	}
+	return time.Unix(int64(secs), 0), true
+}
+
+// payloadToWifiRegion decodes the reply to a WiFi region query: a result byte followed by an
+// ASCII country code.
+func payloadToWifiRegion(pl []byte) (region string, ok bool) {
+	if len(pl) < 3 || pl[0] != 0 {
+		return "", false
+	}
+	return strings.TrimRight(string(pl[1:]), "\x00"), true
+}
+
 func payloadToFileInfo(pl []byte) (fType FileType, fSize uint32, fID uint16) {
 	fType = FileType(pl[0])
 	fSize = uint32(pl[1]) + uint32(pl[2])<<8 + uint32(pl[3])<<16 + uint32(pl[4])<<24
@@ -24,6 +24,9 @@ package tello
 import (
 	"bytes"
 	"testing"
+	"time"
+
+	"github.com/SMerrony/tello/protocol"
 )
 
 // use go test -count=1 to bypass test caching
@@ -35,7 +38,7 @@ func TestPacketToBuffer(t *testing.T) {
 	p.header = msgHdr
 	p.toDrone = true
 	p.packetType = ptSet
-	p.messageID = msgDoTakeoff
+	p.messageID = MsgDoTakeoff
 	p.sequence = 0
 
 	b := packetToBuffer(p)
@@ -63,3 +66,136 @@ func TestByteToFloat32(t *testing.T) {
 		t.Errorf("Expected 15 got, %f\n", r)
 	}
 }
+
+func TestHeightUnits(t *testing.T) {
+	fd := FlightData{Height: 15}
+	if fd.HeightM() != 1.5 {
+		t.Errorf("Expected 1.5m, got %f", fd.HeightM())
+	}
+	if fd.HeightCm() != 150 {
+		t.Errorf("Expected 150cm, got %d", fd.HeightCm())
+	}
+}
+
+func TestAppendPacket(t *testing.T) {
+	pkt := newPacket(ptData2, MsgSetStick, 0, 11)
+	pkt.payload[3] = 0x42
+	want := packetToBuffer(pkt)
+	buff := appendPacket([]byte{1, 2}, pkt)
+	if !bytes.Equal(buff[:2], []byte{1, 2}) || !bytes.Equal(buff[2:], want) {
+		t.Errorf("Expected % x after the existing bytes, got % x", want, buff)
+	}
+	if got := bufferToPacket(want); got.messageID != MsgSetStick || got.payload[3] != 0x42 {
+		t.Errorf("Round trip failed, got %+v", got)
+	}
+}
+
+func BenchmarkPacketToBuffer(b *testing.B) {
+	pkt := newPacket(ptData2, MsgSetStick, 0, 11)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		packetToBuffer(pkt)
+	}
+}
+
+func BenchmarkAppendPacket(b *testing.B) {
+	pkt := newPacket(ptData2, MsgSetStick, 0, 11)
+	buff := make([]byte, 0, minPktSize+11)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buff = appendPacket(buff[:0], pkt)
+	}
+}
+
+// bufferToPacket copies the payload, as several callers keep it after the read buffer is reused,
+// so one allocation per packet is expected.
+func BenchmarkBufferToPacket(b *testing.B) {
+	buff := packetToBuffer(newPacket(ptData2, MsgSetStick, 0, 11))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bufferToPacket(buff)
+	}
+}
+
+func TestPayloadToActivationTime(t *testing.T) {
+	at, ok := payloadToActivationTime([]byte{0, 0x00, 0xe1, 0xf5, 0x05})
+	if !ok || !at.Equal(time.Unix(100000000, 0)) {
+		t.Errorf("Expected %v, got %v (%v)", time.Unix(100000000, 0), at, ok)
+	}
+	if at, ok := payloadToActivationTime([]byte{0, 0, 0, 0, 0}); !ok || !at.IsZero() {
+		t.Errorf("Expected a zero time for an unactivated drone, got %v (%v)", at, ok)
+	}
+	if _, ok := payloadToActivationTime([]byte{1}); ok {
+		t.Error("Expected a failed query to be rejected")
+	}
+}
+
+func TestPayloadToWifiRegion(t *testing.T) {
+	if r, ok := payloadToWifiRegion([]byte{0, 'G', 'B', 0}); !ok || r != "GB" {
+		t.Errorf("Expected GB, got %q (%v)", r, ok)
+	}
+	if _, ok := payloadToWifiRegion([]byte{0}); ok {
+		t.Error("Expected a short reply to be rejected")
+	}
+}
+
+// the protocol package has its own codec, check that the two agree
+func TestProtocolPackageAgrees(t *testing.T) {
+	pkt := newPacket(ptSet, MsgSetLowBattThresh, 0x1234, 1)
+	pkt.payload[0] = 25
+	buff := packetToBuffer(pkt)
+	want := protocol.Packet{Type: ptSet, ToDrone: true, MessageID: uint16(MsgSetLowBattThresh), Sequence: 0x1234, Payload: []byte{25}}
+	if !bytes.Equal(buff, protocol.Encode(want)) {
+		t.Errorf("Encodings differ, % x vs % x", buff, protocol.Encode(want))
+	}
+	if _, err := protocol.Parse(buff); err != nil {
+		t.Error(err)
+	}
+
+	pl := make([]byte, 24)
+	pl[0], pl[12], pl[15], pl[16], pl[17] = 17, 64, 0x10, 0x0e, 0x03
+	fd := payloadToFlightData(pl)
+	v, err := protocol.Decode(protocol.Packet{FromDrone: true, MessageID: uint16(MsgFlightStatus), Payload: pl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := v.(protocol.FlightStatus)
+	if fs.Height != fd.Height || fs.BatteryPercentage != fd.BatteryPercentage ||
+		fs.BatteryMilliVolts != fd.BatteryMilliVolts || fs.Flying != fd.Flying || fs.OnGround != fd.OnGround {
+		t.Errorf("Flight status decodes differ, %+v vs %+v", fs, fd)
+	}
+	if MsgFlightStatus.String() != "FlightStatus" {
+		t.Errorf("Got name %s", MsgFlightStatus)
+	}
+}
+
+// every exported message ID must be in the protocol package's table under the same name
+func TestMessageIDs(t *testing.T) {
+	ids := []MessageID{
+		MsgDoConnect, MsgConnected, MsgQuerySSID, MsgSetSSID, MsgQuerySSIDPass, MsgSetSSIDPass,
+		MsgQueryWifiRegion, MsgSetWifiRegion, MsgWifiStrength, MsgSetVideoBitrate, MsgSetDynAdjRate,
+		MsgEisSetting, MsgQueryVideoSPSPPS, MsgQueryVideoBitrate, MsgDoTakePic, MsgSwitchPicVideo,
+		MsgDoStartRec, MsgExposureVals, MsgLightStrength, MsgQueryJPEGQuality, MsgError1, MsgError2,
+		MsgQueryVersion, MsgSetDateTime, MsgQueryActivationTime, MsgQueryLoaderVersion, MsgSetStick,
+		MsgDoTakeoff, MsgDoLand, MsgFlightStatus, MsgSetHeightLimit, MsgDoFlip, MsgDoThrowTakeoff,
+		MsgDoPalmLand, MsgFileSize, MsgFileData, MsgFileDone, MsgDoSmartVideo, MsgSmartVideoStatus,
+		MsgLogHeader, MsgLogData, MsgLogConfig, MsgDoBounce, MsgDoCalibration, MsgSetLowBattThresh,
+		MsgQueryHeightLimit, MsgQueryLowBattThresh, MsgSetAttitude, MsgQueryAttitude,
+	}
+	if len(ids) != len(protocol.Messages()) {
+		t.Errorf("Got %d message IDs, the protocol table has %d", len(ids), len(protocol.Messages()))
+	}
+	for _, id := range ids {
+		m, ok := protocol.Lookup(uint16(id))
+		if !ok {
+			t.Errorf("Message 0x%04x is not in the protocol table", uint16(id))
+			continue
+		}
+		if id.String() != m.Name {
+			t.Errorf("Message 0x%04x is %s, the protocol table has %s", uint16(id), id, m.Name)
+		}
+	}
+	if s := MessageID(0x1234).String(); s != "0x1234" {
+		t.Errorf("Got %s for an unknown message", s)
+	}
+}
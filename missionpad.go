@@ -0,0 +1,95 @@
+// missionpad.go
+
+// This file contains the mission pad support for the Tello EDU.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MissionPadDirection selects which camera(s) a Tello EDU uses to look for mission pads.
+type MissionPadDirection int
+
+// Mission pad detection directions...
+const (
+	MpDownward MissionPadDirection = iota // use the downward-facing camera only
+	MpForward                             // use the forward-facing camera only
+	MpBoth                                // alternate between both cameras
+)
+
+// MissionPadNone is the MissionPad ID reported when no pad is detected.
+const MissionPadNone = -1
+
+// MissionPad holds the latest mission pad detection data from a Tello EDU.
+// The position is that of the drone relative to the pad.
+type MissionPad struct {
+	ID      int       `json:"id"` // 1 - 8, or MissionPadNone
+	X       int16     `json:"x"`  // cm
+	Y       int16     `json:"y"`
+	Z       int16     `json:"z"`
+	Pitch   int16     `json:"pitch"` // degrees
+	Roll    int16     `json:"roll"`
+	Yaw     int16     `json:"yaw"`
+	Updated time.Time `json:"updated"`
+}
+
+// ParseMissionPadState extracts the mission pad fields from a Tello EDU text state packet,
+// ok is false if the packet does not contain mission pad data.
+func ParseMissionPadState(state string) (mp MissionPad, ok bool) {
+	fields := map[string]string{}
+	for _, kv := range strings.Split(strings.TrimSpace(state), ";") {
+		if i := strings.IndexByte(kv, ':'); i > 0 {
+			fields[kv[:i]] = kv[i+1:]
+		}
+	}
+	mid, present := fields["mid"]
+	if !present {
+		return mp, false
+	}
+	id, err := strconv.Atoi(mid)
+	if err != nil {
+		return mp, false
+	}
+	if id < 0 {
+		// -1 and -2 both mean that no pad is in view
+		return MissionPad{ID: MissionPadNone, Updated: time.Now()}, true
+	}
+	mp.ID = id
+	mp.X = atoi16(fields["x"])
+	mp.Y = atoi16(fields["y"])
+	mp.Z = atoi16(fields["z"])
+	if pry := strings.Split(fields["mpry"], ","); len(pry) == 3 {
+		mp.Pitch = atoi16(pry[0])
+		mp.Roll = atoi16(pry[1])
+		mp.Yaw = atoi16(pry[2])
+	}
+	mp.Updated = time.Now()
+	return mp, true
+}
+
+func atoi16(s string) int16 {
+	i, _ := strconv.Atoi(strings.TrimSpace(s))
+	return int16(i)
+}
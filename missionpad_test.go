@@ -0,0 +1,46 @@
+// tello project missionpad_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "testing"
+
+func TestParseMissionPadState(t *testing.T) {
+	mp, ok := ParseMissionPadState("mid:3;x:-12;y:40;z:95;mpry:1,-2,87;pitch:0;roll:0;yaw:87;\r\n")
+	if !ok {
+		t.Fatal("Expected mission pad data to be found")
+	}
+	if mp.ID != 3 || mp.X != -12 || mp.Y != 40 || mp.Z != 95 {
+		t.Errorf("Unexpected pad position: %+v", mp)
+	}
+	if mp.Pitch != 1 || mp.Roll != -2 || mp.Yaw != 87 {
+		t.Errorf("Unexpected pad attitude: %+v", mp)
+	}
+
+	mp, ok = ParseMissionPadState("mid:-2;x:0;y:0;z:0;mpry:0,0,0;\r\n")
+	if !ok || mp.ID != MissionPadNone {
+		t.Errorf("Expected no pad, got %+v", mp)
+	}
+
+	if _, ok = ParseMissionPadState("pitch:0;roll:0;yaw:87;\r\n"); ok {
+		t.Error("Expected no mission pad data in a non-EDU state packet")
+	}
+}
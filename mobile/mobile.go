@@ -0,0 +1,325 @@
+// mobile.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+/*
+Package mobile is a simplified facade over the tello package for use with gomobile bind, so that
+Android and iOS apps can use the same protocol implementation natively:
+
+	gomobile bind -target=android github.com/SMerrony/tello/mobile
+
+Only the types gomobile can bind are used: string, int, float64, bool, []byte, error, and
+interfaces for callbacks, which are implemented by the app.  FlightData and the Data of events
+are passed as JSON.  Callbacks are made from Goroutines of their own, apps must switch to their
+UI thread before touching their views.
+*/
+package mobile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/SMerrony/tello"
+)
+
+const (
+	commandTimeout        = 10 * time.Second // how long the commands which wait for the drone may take
+	keyframeRequestPeriod = time.Second      // how often the video SPS and PPS are requested
+)
+
+// EventListener receives the tello package's Events, kind is the EventType name, eg. "FlightState",
+// and message a one-line description.
+type EventListener interface {
+	OnEvent(kind, message, dataJSON string)
+}
+
+// TelemetryListener receives the drone's FlightData as JSON.
+type TelemetryListener interface {
+	OnTelemetry(flightDataJSON string)
+}
+
+// VideoListener receives the raw H.264 video stream.
+type VideoListener interface {
+	OnVideo(data []byte)
+}
+
+// PictureListener receives pictures taken by TakePicture() as JPEG data.
+type PictureListener interface {
+	OnPicture(jpeg []byte)
+}
+
+// Drone is a Tello as seen from a mobile app.
+type Drone struct {
+	tello *tello.Tello
+
+	mu                                                 sync.Mutex // protects the following
+	stopEvents, stopTelemetry, stopVideo, stopPictures func()
+}
+
+// NewDrone returns a Drone using the usual address of a Tello.
+func NewDrone() *Drone {
+	return &Drone{tello: tello.NewTello()}
+}
+
+// NewDroneAt returns a Drone at the given address and control port, eg. a simulator.
+func NewDroneAt(addr string, port int) *Drone {
+	return &Drone{tello: tello.NewTello(tello.WithAddress(addr, port), tello.WithLocalControlPort(tello.AnyPort),
+		tello.WithVideoPort(tello.AnyPort))}
+}
+
+// Connect connects to the drone.
+func (d *Drone) Connect() error {
+	return d.tello.ControlConnectDefault()
+}
+
+// Connected returns true while the drone is connected.
+func (d *Drone) Connected() bool {
+	return d.tello.ControlConnected()
+}
+
+// Disconnect stops every listener, lands the drone if it is airborne and disconnects.
+func (d *Drone) Disconnect() error {
+	d.SetEventListener(nil)
+	d.SetTelemetryListener(nil, 0)
+	d.SetPictureListener(nil)
+	d.StopVideo()
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return d.tello.Close(ctx)
+}
+
+func waitCtx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), commandTimeout)
+}
+
+// TakeOff takes off, it returns once the drone has acknowledged the command.
+func (d *Drone) TakeOff() error {
+	ctx, cancel := waitCtx()
+	defer cancel()
+	return d.tello.TakeOffAndWait(ctx)
+}
+
+// ThrowTakeOff starts the motors for a 'throw and go' launch.
+func (d *Drone) ThrowTakeOff() error {
+	ctx, cancel := waitCtx()
+	defer cancel()
+	return d.tello.ThrowTakeOffCtx(ctx)
+}
+
+// Land lands, it returns once the drone has acknowledged the command.
+func (d *Drone) Land() error {
+	ctx, cancel := waitCtx()
+	defer cancel()
+	return d.tello.LandAndWait(ctx)
+}
+
+// PalmLand lands on a hand held below the drone.
+func (d *Drone) PalmLand() error {
+	ctx, cancel := waitCtx()
+	defer cancel()
+	return d.tello.PalmLandCtx(ctx)
+}
+
+// Hover stops any automatic flight and centres the sticks.
+func (d *Drone) Hover() {
+	d.tello.Hover()
+}
+
+// Flip flips in direction dir, 0 to 7 as for tello.FlipType, eg. 0 is forwards.
+func (d *Drone) Flip(dir int) error {
+	ctx, cancel := waitCtx()
+	defer cancel()
+	return d.tello.FlipCtx(ctx, tello.FlipType(dir))
+}
+
+// SetSticks sets the sticks, each between -1 and 1: rx moves right, ry forwards, lx turns clockwise
+// and ly climbs.
+func (d *Drone) SetSticks(rx, ry, lx, ly float64) {
+	d.tello.UpdateSticks(tello.StickMessage{Rx: stick(rx), Ry: stick(ry), Lx: stick(lx), Ly: stick(ly)})
+}
+
+func stick(v float64) int16 {
+	return int16(math.Max(-1, math.Min(1, v)) * math.MaxInt16)
+}
+
+// SetSportsMode switches between sports (fast) and normal flight.
+func (d *Drone) SetSportsMode(sports bool) {
+	d.tello.SetSportsMode(sports)
+}
+
+// Battery returns the battery percentage.
+func (d *Drone) Battery() int {
+	return int(d.tello.GetFlightData().BatteryPercentage)
+}
+
+// Height returns the height above the takeoff point in metres.
+func (d *Drone) Height() float64 {
+	return float64(d.tello.GetFlightData().HeightM())
+}
+
+// FlightState returns the high-level flight state, eg. "Hovering".
+func (d *Drone) FlightState() string {
+	return d.tello.GetFlightState().String()
+}
+
+// FlightDataJSON returns the latest FlightData as JSON.
+func (d *Drone) FlightDataJSON() string {
+	return toJSON(d.tello.GetFlightData())
+}
+
+func toJSON(v interface{}) string {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "null"
+	}
+	return string(b)
+}
+
+// replace stops the listener in *stop, if any, and sets it to start(), unless start is nil.
+func (d *Drone) replace(stop *func(), start func() func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if *stop != nil {
+		(*stop)()
+		*stop = nil
+	}
+	if start != nil {
+		*stop = start()
+	}
+}
+
+// SetEventListener passes every Event to l, nil stops.
+func (d *Drone) SetEventListener(l EventListener) {
+	if l == nil {
+		d.replace(&d.stopEvents, nil)
+		return
+	}
+	d.replace(&d.stopEvents, func() func() {
+		events, stop := d.tello.ListenEvents()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for ev := range events {
+				l.OnEvent(ev.Type.String(), ev.String(), toJSON(ev.Data))
+			}
+		}()
+		return func() { stop(); <-done }
+	})
+}
+
+// SetTelemetryListener passes the FlightData to l every periodMs milliseconds, nil stops.
+// An error is returned, and any listener left in place, if periodMs is not positive.
+func (d *Drone) SetTelemetryListener(l TelemetryListener, periodMs int) error {
+	if l == nil {
+		d.replace(&d.stopTelemetry, nil)
+		return nil
+	}
+	if periodMs <= 0 {
+		return errors.New("Telemetry period must be positive")
+	}
+	d.replace(&d.stopTelemetry, func() func() {
+		ticker := time.NewTicker(time.Duration(periodMs) * time.Millisecond)
+		quit, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(done)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-quit:
+					return
+				case <-ticker.C:
+					l.OnTelemetry(d.FlightDataJSON())
+				}
+			}
+		}()
+		return func() { close(quit); <-done }
+	})
+	return nil
+}
+
+// StartVideo connects to the video stream and passes it to l until StopVideo() is called.
+func (d *Drone) StartVideo(l VideoListener) (err error) {
+	d.replace(&d.stopVideo, func() func() {
+		var video <-chan []byte
+		if video, err = d.tello.VideoConnectDefault(); err != nil {
+			return nil
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			keyframes := time.NewTicker(keyframeRequestPeriod)
+			defer keyframes.Stop()
+			d.tello.GetVideoSpsPps()
+			for {
+				select {
+				case data, ok := <-video:
+					if !ok {
+						return
+					}
+					l.OnVideo(data)
+				case <-keyframes.C:
+					d.tello.GetVideoSpsPps() // so a decoder can start, or recover, promptly
+				}
+			}
+		}()
+		return func() { d.tello.VideoDisconnect(); <-done }
+	})
+	return err
+}
+
+// StopVideo stops the video stream.
+func (d *Drone) StopVideo() {
+	d.replace(&d.stopVideo, nil)
+}
+
+// SetPictureListener passes pictures taken by TakePicture() to l, nil stops.
+func (d *Drone) SetPictureListener(l PictureListener) {
+	if l == nil {
+		d.replace(&d.stopPictures, nil)
+		return
+	}
+	d.replace(&d.stopPictures, func() func() {
+		files, stop := d.tello.ListenFiles()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for f := range files {
+				if f.FileType == tello.FtJPEG {
+					l.OnPicture(f.FileBytes)
+				}
+			}
+		}()
+		return func() { stop(); <-done }
+	})
+}
+
+// TakePicture asks the drone for a picture, which is passed to the PictureListener once it has arrived.
+func (d *Drone) TakePicture() error {
+	ctx, cancel := waitCtx()
+	defer cancel()
+	return d.tello.TakePictureCtx(ctx)
+}
@@ -0,0 +1,138 @@
+// mobile_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mobile
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello/sim"
+)
+
+type recorder struct {
+	events    chan string
+	telemetry chan string
+	video     chan []byte
+}
+
+func (r *recorder) OnEvent(kind, message, dataJSON string) {
+	select {
+	case r.events <- kind + " " + message + " " + dataJSON:
+	default:
+	}
+}
+
+func (r *recorder) OnTelemetry(fd string) {
+	select {
+	case r.telemetry <- fd:
+	default:
+	}
+}
+
+func (r *recorder) OnVideo(data []byte) {
+	select {
+	case r.video <- data:
+	default:
+	}
+}
+
+func TestDrone(t *testing.T) {
+	s := sim.New(sim.WithVideo())
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	d := NewDroneAt("127.0.0.1", s.Addr().Port)
+	if err := d.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Disconnect()
+	r := &recorder{events: make(chan string, 100), telemetry: make(chan string, 1), video: make(chan []byte, 1)}
+	d.SetEventListener(r)
+	if err := d.SetTelemetryListener(r, 0); err == nil {
+		t.Error("Expected an error for a zero telemetry period")
+	}
+	if err := d.SetTelemetryListener(r, 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.StartVideo(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.TakeOff(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for d.FlightState() != "Hovering" && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if d.FlightState() != "Hovering" || d.Height() <= 0 || d.Battery() <= 0 {
+		t.Errorf("Expected to be hovering, got %s at %.1fm", d.FlightState(), d.Height())
+	}
+	select {
+	case ev := <-r.events:
+		if ev != `FlightState Tello Grounded -> TakingOff {"From":"Grounded","To":"TakingOff"}` {
+			t.Errorf("Unexpected event %s", ev)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected a flight state event")
+	}
+	select {
+	case fd := <-r.telemetry:
+		var v map[string]interface{}
+		if err := json.Unmarshal([]byte(fd), &v); err != nil || v["height"] == nil {
+			t.Errorf("Unexpected telemetry %s (%v)", fd, err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected telemetry")
+	}
+	select {
+	case <-r.video:
+	case <-time.After(3 * time.Second):
+		t.Error("Expected video")
+	}
+
+	d.SetSticks(0, 2, 0, 0) // clamped to full forward
+	if err := d.Flip(0); err != nil {
+		t.Errorf("Flip failed with %v", err)
+	}
+	if err := d.Land(); err != nil {
+		t.Fatal(err)
+	}
+	d.StopVideo()
+	if err := d.Disconnect(); err != nil && !strings.Contains(err.Error(), "not connected") {
+		t.Errorf("Disconnect failed with %v", err)
+	}
+	if d.Connected() {
+		t.Error("Expected to be disconnected")
+	}
+}
+
+func TestStick(t *testing.T) {
+	for v, want := range map[float64]int16{0: 0, 1: 32767, -1: -32767, 5: 32767, -0.5: -16383} {
+		if got := stick(v); got != want {
+			t.Errorf("stick(%v) = %d, want %d", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// odometer.go
+
+// Flight timer and odometer, accumulated from the status and flight log messages.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"math"
+	"time"
+)
+
+// odoMaxGap is the longest interval we will integrate over, after a longer gap in the data
+// (eg. a reconnection) we simply restart from the next sample.
+const odoMaxGap = time.Second
+
+// accumulateFlyTime adds the time since the previous status message to the flight timers if we are flying.
+// fdMu must be held by the caller.
+func (tello *Tello) accumulateFlyTime(now time.Time) {
+	if tello.fd.Flying && !tello.odoLastStatus.IsZero() {
+		if dt := now.Sub(tello.odoLastStatus); dt > 0 && dt <= odoMaxGap {
+			tello.fd.SessionFlyTime += dt
+			tello.fd.TotalFlyTime += dt
+		}
+	}
+	tello.odoLastStatus = now
+}
+
+// accumulateDistance integrates the MVO velocity since the previous MVO sample into the odometers if we are flying.
+// fdMu must be held by the caller.
+func (tello *Tello) accumulateDistance(now time.Time) {
+	if tello.fd.Flying && !tello.odoLastMVO.IsZero() {
+		if dt := now.Sub(tello.odoLastMVO); dt > 0 && dt <= odoMaxGap {
+			vx, vy, vz := float64(tello.fd.MVO.VelocityX), float64(tello.fd.MVO.VelocityY), float64(tello.fd.MVO.VelocityZ)
+			d := float32(math.Sqrt(vx*vx+vy*vy+vz*vz) / 100 * dt.Seconds()) // cm/s -> m
+			tello.fd.SessionDistance += d
+			tello.fd.TotalDistance += d
+		}
+	}
+	tello.odoLastMVO = now
+}
+
+// ResetOdometer zeroes both the session and the total flight timers and odometers.
+func (tello *Tello) ResetOdometer() {
+	tello.fdMu.Lock()
+	tello.fd.SessionFlyTime, tello.fd.TotalFlyTime = 0, 0
+	tello.fd.SessionDistance, tello.fd.TotalDistance = 0, 0
+	tello.fdMu.Unlock()
+}
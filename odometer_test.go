@@ -0,0 +1,81 @@
+// tello project odometer_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOdometer(t *testing.T) {
+	tello := new(Tello)
+	t0 := time.Now()
+
+	// nothing accumulates on the ground
+	tello.accumulateFlyTime(t0)
+	tello.accumulateDistance(t0)
+	tello.accumulateFlyTime(t0.Add(100 * time.Millisecond))
+	if tello.fd.TotalFlyTime != 0 {
+		t.Errorf("Expected no flight time on the ground, got %v", tello.fd.TotalFlyTime)
+	}
+
+	tello.fd.Flying = true
+	tello.fd.MVO.VelocityX, tello.fd.MVO.VelocityY = 30, 40 // 50cm/s
+	for i := 1; i <= 10; i++ {
+		now := t0.Add(time.Duration(i) * 200 * time.Millisecond)
+		tello.accumulateFlyTime(now)
+		tello.accumulateDistance(now)
+	}
+	if tello.fd.SessionFlyTime != 1900*time.Millisecond || tello.fd.TotalFlyTime != tello.fd.SessionFlyTime {
+		t.Errorf("Expected 1.9s flight time, got %v and %v", tello.fd.SessionFlyTime, tello.fd.TotalFlyTime)
+	}
+	if d := tello.fd.TotalDistance; d < 0.99 || d > 1.01 {
+		t.Errorf("Expected 1m travelled, got %f", d)
+	}
+
+	// a gap in the data is not integrated
+	tello.accumulateFlyTime(t0.Add(time.Minute))
+	if tello.fd.TotalFlyTime != 1900*time.Millisecond {
+		t.Errorf("Expected the gap to be ignored, got %v", tello.fd.TotalFlyTime)
+	}
+
+	tello.ResetOdometer()
+	if tello.fd.TotalFlyTime != 0 || tello.fd.TotalDistance != 0 || tello.fd.SessionDistance != 0 {
+		t.Errorf("Expected odometer to be reset, got %+v", tello.fd)
+	}
+}
+
+func TestMVOValidityFlags(t *testing.T) {
+	const key = 0x5a
+	rec := make([]byte, 100)
+	rec[0], rec[1], rec[4], rec[6] = logRecordSeparator, byte(len(rec)), byte(logRecNewMVO), key
+	rec[10+2], rec[10+4] = 30, 40 // VelocityX and VelocityY
+	rec[10+76] = logValidVelX
+	for i := logRecordHeaderLen; i < len(rec); i++ {
+		rec[i] ^= key
+	}
+	tello := new(Tello)
+	tello.parseLogPacket(append([]byte{0}, rec...))
+	if tello.fd.MVO.VelocityX != 30 || tello.fd.MVO.VelocityY != 0 {
+		t.Errorf("Expected only the valid velocity, got %+v", tello.fd.MVO)
+	}
+}
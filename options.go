@@ -0,0 +1,284 @@
+// options.go
+
+// This file contains the configuration options for NewTello().
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"log"
+	"time"
+
+	"github.com/SMerrony/tello/pid"
+)
+
+// FailsafePolicy decides what we ask of the drone when contact with it seems to have been lost.
+type FailsafePolicy int
+
+// Failsafe policies...
+const (
+	FailsafeNone  FailsafePolicy = iota // just mark the connection as lost, the drone's own failsafe will take over
+	FailsafeHover                       // zero the sticks in case the drone can still hear us
+	FailsafeLand                        // ask the drone to land in case it can still hear us
+)
+
+// Option configures a Tello created via NewTello().
+type Option func(*Tello)
+
+// config holds the per-instance settings, zero values mean 'use the package default'.
+type config struct {
+	droneAddr                  string
+	dronePort, localCtrlPort   int
+	videoPort                  int
+	keepAlivePeriod            time.Duration
+	connectTimeout             time.Duration
+	connectRetry               *ConnectRetry
+	contactTimeout             time.Duration
+	logger                     *log.Logger
+	failsafe, lowBattery       FailsafePolicy
+	batteryReserve             *time.Duration
+	tracer                     Tracer
+	adaptiveLink               bool
+	groundedHeartbeat          bool
+	closeNoLand                bool
+	preflight                  *PreflightConfig
+	flightLogDir               string
+	historySize                int
+	smoothing                  SmoothingConfig
+	staleAfter                 [numTelemetryCategories]time.Duration
+	adaptiveBitrate            *BitrateConfig
+	rttProbePeriod             time.Duration
+	sendInterval               time.Duration
+	transport                  Transport
+	clock                      Clock
+	videoBufSize, stickBufSize int
+	stickSlew                  int
+	stickTTL                   time.Duration
+	pids                       [numPIDAxes]*pid.Controller
+	decoder                    Decoder
+	pictureDir                 string
+	unknownCapture             int
+	serialNumber               string
+	envelope                   Envelope
+}
+
+// NewTello returns a Tello configured with the given options, anything not set by an option
+// takes the package default.  N.B. A zero-value Tello, eg. new(Tello), is also ready to use.
+func NewTello(opts ...Option) *Tello {
+	tello := new(Tello)
+	for _, opt := range opts {
+		opt(tello)
+	}
+	return tello
+}
+
+// WithAddress sets the network address and control port of the drone used by ControlConnectDefault().
+func WithAddress(udpAddr string, droneUDPPort int) Option {
+	return func(tello *Tello) {
+		tello.cfg.droneAddr = udpAddr
+		tello.cfg.dronePort = droneUDPPort
+	}
+}
+
+// WithSequence sets the sequence number of the last command sent, so that numbering continues from
+// that of an earlier Tello, see Sequence().  Some firmware versions ignore commands whose sequence
+// number is lower than one they have already seen, so this is needed when a program restarts, or
+// hands over to another, while the drone stays on.
+func WithSequence(seq uint16) Option {
+	return func(tello *Tello) { tello.ctrlSeq = seq }
+}
+
+// WithLocalControlPort sets the local UDP port used by ControlConnectDefault().
+func WithLocalControlPort(port int) Option {
+	return func(tello *Tello) { tello.cfg.localCtrlPort = port }
+}
+
+// WithVideoPort sets the local UDP port the drone is asked to stream video to.
+func WithVideoPort(port int) Option {
+	return func(tello *Tello) { tello.cfg.videoPort = port }
+}
+
+// WithKeepAlivePeriod sets how often stick updates are sent to the drone.
+func WithKeepAlivePeriod(period time.Duration) Option {
+	return func(tello *Tello) { tello.cfg.keepAlivePeriod = period }
+}
+
+// WithConnectTimeout sets how long ControlConnect() waits for the drone to respond, the default is 3s.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(tello *Tello) { tello.cfg.connectTimeout = timeout }
+}
+
+// ConnectRetry configures how ControlConnect() repeats its connection request while waiting
+// for the drone to respond, as the first datagram sent after joining the drone's WiFi is often lost.
+type ConnectRetry struct {
+	Retries int           // resend the request up to this many times, 0 means never
+	Backoff time.Duration // wait this long before the first resend, doubling each time, default 250ms
+}
+
+// WithConnectRetry sets how the connection request is repeated, the default is 3 retries with
+// a 250ms backoff, so the request is sent at 0, 0.25, 0.75 and 1.75s.  No request is sent after
+// the connect timeout, see WithConnectTimeout().
+func WithConnectRetry(retry ConnectRetry) Option {
+	return func(tello *Tello) { tello.cfg.connectRetry = &retry }
+}
+
+// WithContactTimeout sets how long we wait without hearing from the drone before
+// deciding that contact has been lost.
+func WithContactTimeout(timeout time.Duration) Option {
+	return func(tello *Tello) { tello.cfg.contactTimeout = timeout }
+}
+
+// WithLogger directs the package's log output for this Tello to the given Logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(tello *Tello) { tello.cfg.logger = logger }
+}
+
+// WithFailsafe sets the action taken when contact with the drone is lost.
+func WithFailsafe(policy FailsafePolicy) Option {
+	return func(tello *Tello) { tello.cfg.failsafe = policy }
+}
+
+// WithBatteryReserve sets the flight time to keep in hand for landing, this is subtracted by
+// EstimatedFlightTimeLeft() and reaching it triggers the low-battery supervisor.  A reserve of 0
+// disables it, so that the supervisor acts only when no flight time is left.
+func WithBatteryReserve(reserve time.Duration) Option {
+	if reserve < 0 {
+		reserve = 0
+	}
+	return func(tello *Tello) { tello.cfg.batteryReserve = &reserve }
+}
+
+// WithLowBatteryAction sets what we ask of the drone when the battery reserve is reached,
+// an EvBatteryReserve Event is sent whatever the policy.
+func WithLowBatteryAction(policy FailsafePolicy) Option {
+	return func(tello *Tello) { tello.cfg.lowBattery = policy }
+}
+
+// WithVideoBufferSize sets the number of video packets buffered in the channel returned by VideoConnect().
+func WithVideoBufferSize(n int) Option {
+	return func(tello *Tello) { tello.cfg.videoBufSize = n }
+}
+
+// WithStickBufferSize sets the number of StickMessages buffered in the channel returned by StartStickListener().
+func WithStickBufferSize(n int) Option {
+	return func(tello *Tello) { tello.cfg.stickBufSize = n }
+}
+
+// the following return the configured value, or the package default if none has been set
+
+func (c *config) getDroneAddr() string {
+	if c.droneAddr == "" {
+		return defaultTelloAddr
+	}
+	return c.droneAddr
+}
+
+func (c *config) getDronePort() int {
+	if c.dronePort == 0 {
+		return defaultTelloControlPort
+	}
+	return c.dronePort
+}
+
+func (c *config) getLocalCtrlPort() int {
+	if c.localCtrlPort == 0 {
+		return defaultLocalControlPort
+	}
+	return c.localCtrlPort
+}
+
+func (c *config) getVideoPort() int {
+	if c.videoPort == 0 {
+		return defaultTelloVideoPort
+	}
+	return c.videoPort
+}
+
+func (c *config) getKeepAlivePeriod() time.Duration {
+	if c.keepAlivePeriod == 0 {
+		return keepAlivePeriodMs * time.Millisecond
+	}
+	return c.keepAlivePeriod
+}
+
+func (c *config) getConnectTimeout() time.Duration {
+	if c.connectTimeout == 0 {
+		return defaultConnectTimeout
+	}
+	return c.connectTimeout
+}
+
+func (c *config) getConnectRetry() ConnectRetry {
+	retry := ConnectRetry{Retries: defaultConnectRetries}
+	if c.connectRetry != nil {
+		retry = *c.connectRetry
+	}
+	if retry.Backoff <= 0 {
+		retry.Backoff = defaultConnectBackoff
+	}
+	return retry
+}
+
+func (c *config) getContactTimeout() time.Duration {
+	if c.contactTimeout == 0 {
+		return lightStrengthTimeout
+	}
+	return c.contactTimeout
+}
+
+func (c *config) getBatteryReserve() time.Duration {
+	if c.batteryReserve == nil {
+		return defaultReserve
+	}
+	return *c.batteryReserve
+}
+
+func (c *config) getVideoBufSize() int {
+	if c.videoBufSize == 0 {
+		return defaultVideoBufSize
+	}
+	return c.videoBufSize
+}
+
+func (c *config) getStickBufSize() int {
+	if c.stickBufSize == 0 {
+		return defaultStickBufSize
+	}
+	return c.stickBufSize
+}
+
+// logf logs via the configured Logger, or the standard logger if none is set.
+func (tello *Tello) logf(format string, v ...interface{}) {
+	if tello.cfg.logger != nil {
+		tello.cfg.logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// logln logs via the configured Logger, or the standard logger if none is set.
+func (tello *Tello) logln(v ...interface{}) {
+	if tello.cfg.logger != nil {
+		tello.cfg.logger.Println(v...)
+		return
+	}
+	log.Println(v...)
+}
@@ -0,0 +1,49 @@
+// tello project options_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewTelloOptions(t *testing.T) {
+	drone := NewTello()
+	if drone.cfg.getDronePort() != defaultTelloControlPort || drone.cfg.getKeepAlivePeriod() != keepAlivePeriodMs*time.Millisecond {
+		t.Error("Expected package defaults when no options given")
+	}
+
+	drone = NewTello(WithAddress("10.0.0.2", 9000), WithVideoPort(7000),
+		WithKeepAlivePeriod(20*time.Millisecond), WithFailsafe(FailsafeLand))
+	if drone.cfg.getDroneAddr() != "10.0.0.2" || drone.cfg.getDronePort() != 9000 {
+		t.Errorf("Address option not applied, got %s:%d", drone.cfg.getDroneAddr(), drone.cfg.getDronePort())
+	}
+	if drone.cfg.getVideoPort() != 7000 {
+		t.Errorf("Expected video port 7000, got %d", drone.cfg.getVideoPort())
+	}
+	if drone.cfg.getKeepAlivePeriod() != 20*time.Millisecond {
+		t.Errorf("Expected keepalive 20ms, got %v", drone.cfg.getKeepAlivePeriod())
+	}
+	if drone.cfg.failsafe != FailsafeLand {
+		t.Errorf("Expected FailsafeLand, got %d", drone.cfg.failsafe)
+	}
+}
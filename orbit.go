@@ -0,0 +1,188 @@
+// orbit.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	// AutoOrbitMinRadiusM is the smallest radius allowed for AutoOrbit() in metres.
+	AutoOrbitMinRadiusM = 0.5
+	// AutoOrbitMaxRadiusM is the largest radius allowed for AutoOrbit() in metres.
+	AutoOrbitMaxRadiusM = 10.0
+	autoOrbitYawFullDeg = 30.0 // bearing error at which we turn at full rate
+	autoOrbitRangeFullM = 1.0  // range error at which we move in or out at full speed
+)
+
+// CancelAutoOrbit stops any in-flight AutoOrbit navigation.
+// The drone should stop moving and turning.
+func (tello *Tello) CancelAutoOrbit() {
+	tello.autoOrbitMu.Lock()
+	tello.autoOrbit = false
+	tello.autoOrbitMu.Unlock()
+}
+
+// IsAutoOrbiting tests whether we are currently orbiting a point.
+func (tello *Tello) IsAutoOrbiting() (set bool) {
+	tello.autoOrbitMu.RLock()
+	set = tello.autoOrbit
+	tello.autoOrbitMu.RUnlock()
+	return set
+}
+
+// AutoOrbit starts circling the point radius metres in front of the drone, keeping the camera
+// pointed at it, for the given number of revolutions.  Positive revolutions move the drone
+// to its right, negative ones to its left.
+// A speed value of 1 makes the drone go round as fast as the autopilots go, and a lower value
+// makes it go slower.
+// This is a programmatic alternative to StartSmartVideo(SvCircle) which uses the MVO
+// position, so it needs the same good light and textured floor as AutoFlyToXY().
+// It may not be combined with AutoTurnToYaw() or AutoFlyToXY() but AutoFlyToHeight() may
+// be used meanwhile.
+// The func returns immediately and a Goroutine handles the navigation until either
+// it is complete or cancelled via CancelAutoOrbit().
+// The caller may optionally listen on the 'done' channel for a signal that
+// the navigation is complete (or has been cancelled).
+func (tello *Tello) AutoOrbit(radius, speed, revolutions float32) (done chan error, err error) {
+	if speed < 0.25 {
+		tello.logln("WARN: AutoOrbit speed too low, increasing to 0.25")
+		speed = 0.25
+	}
+	if speed > 1 {
+		tello.logln("WARN: AutoOrbit speed too high, decreasing to 1.0 (max speed)")
+		speed = 1
+	}
+	if radius < AutoOrbitMinRadiusM || radius > AutoOrbitMaxRadiusM {
+		return nil, errors.New("Orbit radius out of range")
+	}
+	if revolutions == 0 {
+		return nil, errors.New("Orbit needs a non-zero number of revolutions")
+	}
+	if tello.IsAutoTurning() || tello.IsAutoXY() || tello.IsFollowing() {
+		return nil, errors.New("Cannot orbit while AutoFlying horizontally, turning or following")
+	}
+
+	tello.autoOrbitMu.Lock()
+	if tello.autoOrbit {
+		tello.autoOrbitMu.Unlock()
+		return nil, errors.New("Already orbiting")
+	}
+	tello.autoOrbit = true
+	tello.autoOrbitMu.Unlock()
+
+	dir := float32(1)
+	if revolutions < 0 {
+		dir = -1
+	}
+	target := 2 * math.Pi * math.Abs(float64(revolutions))
+
+	// the centre is straight ahead of us
+	tello.fdMu.RLock()
+	yaw := tello.fd.IMU.Yaw
+	posX, posY := tello.fd.MVO.PositionX, tello.fd.MVO.PositionY
+	tello.fdMu.RUnlock()
+	dx, dy := bodyToWorld(yaw, 0, radius)
+	centreX, centreY := posX+dx, posY+dy
+	lastAngle := math.Atan2(float64(posY-centreY), float64(posX-centreX))
+
+	done = make(chan error, 1) // buffered so it won't block
+
+	go func() {
+		var travelled float64
+		returnedError := errors.New("AutoOrbit cancelled")
+		for {
+			// has the orbit been cancelled?
+			if !tello.IsAutoOrbiting() {
+				tello.ctrlMu.Lock()
+				tello.ctrlRx, tello.ctrlRy, tello.ctrlLx = 0, 0, 0
+				tello.ctrlMu.Unlock()
+				tello.sendStickUpdate()
+				done <- returnedError
+				close(done)
+				return
+			}
+
+			tello.fdMu.RLock()
+			yaw = tello.fd.IMU.Yaw
+			posX, posY = tello.fd.MVO.PositionX, tello.fd.MVO.PositionY
+			lowLight := tello.fd.LightStrength == 1
+			tello.fdMu.RUnlock()
+
+			if lowLight {
+				returnedError = errors.New("cancelling AutoOrbit due to low light")
+				tello.CancelAutoOrbit()
+				continue
+			}
+
+			angle := math.Atan2(float64(posY-centreY), float64(posX-centreX))
+			travelled += math.Abs(angleDelta(lastAngle, angle))
+			lastAngle = angle
+			if travelled >= target {
+				returnedError = nil
+				tello.CancelAutoOrbit()
+				continue
+			}
+
+			right, forward := calcXYdeltas(yaw, posX, posY, centreX, centreY)
+			rx, ry, lx := orbitSticks(right, forward, radius, speed, dir)
+
+			tello.ctrlMu.Lock()
+			if !tello.IsAutoOrbiting() { // cancelled meanwhile, eg. by Hover(), so leave the sticks alone
+				tello.ctrlMu.Unlock()
+				continue
+			}
+			tello.ctrlRx, tello.ctrlRy, tello.ctrlLx = rx, ry, lx
+			tello.ctrlMu.Unlock()
+
+			time.Sleep(autopilotPeriodMs * time.Millisecond)
+		}
+	}()
+
+	return done, nil
+}
+
+// orbitSticks returns the stick values to circle a centre which is right and forward metres
+// from the drone: sideways at a constant speed in direction dir, turning to face the centre
+// and moving in or out to hold the radius.
+func orbitSticks(right, forward, radius, speed, dir float32) (rx, ry, lx int16) {
+	rx = int16(dir * autoPilotSpeedSlow * speed)
+	bearing := math.Atan2(float64(right), float64(forward)) * 180 / math.Pi
+	lx = clampStick(float32(bearing/autoOrbitYawFullDeg) * autoPilotSpeedFast * speed)
+	rangeErr := float32(math.Hypot(float64(right), float64(forward))) - radius
+	ry = clampStick(rangeErr / autoOrbitRangeFullM * autoPilotSpeedSlow * speed)
+	return rx, ry, lx
+}
+
+// angleDelta returns the signed change from angle a to b in radians, in the range -Pi to Pi.
+func angleDelta(a, b float64) float64 {
+	d := math.Mod(b-a, 2*math.Pi)
+	switch {
+	case d > math.Pi:
+		d -= 2 * math.Pi
+	case d < -math.Pi:
+		d += 2 * math.Pi
+	}
+	return d
+}
@@ -0,0 +1,109 @@
+// orbit_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestOrbitSticks(t *testing.T) {
+	// centre dead ahead at the right range, so just move sideways
+	rx, ry, lx := orbitSticks(0, 2, 2, 1, 1)
+	if rx != autoPilotSpeedSlow || ry != 0 || lx != 0 {
+		t.Errorf("On station: got %d, %d, %d", rx, ry, lx)
+	}
+	// centre to our right and too far away, going left
+	rx, ry, lx = orbitSticks(1, 3, 2, 0.5, -1)
+	if rx != -autoPilotSpeedSlow/2 || ry <= 0 || lx <= 0 {
+		t.Errorf("Off station: got %d, %d, %d", rx, ry, lx)
+	}
+	// centre well behind us to the left, turn hard left and close in
+	_, ry, lx = orbitSticks(-1, -1, 3, 1, 1)
+	if lx != math.MinInt16 || ry >= 0 {
+		t.Errorf("Behind: got ry %d, lx %d", ry, lx)
+	}
+}
+
+func TestAngleDelta(t *testing.T) {
+	tests := []struct{ a, b, want float64 }{
+		{0, 1, 1},
+		{1, 0, -1},
+		{3, -3, 2*math.Pi - 6},
+		{-3, 3, 6 - 2*math.Pi},
+	}
+	for _, tt := range tests {
+		if got := angleDelta(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("angleDelta(%v, %v) = %v, expected %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAutoOrbit(t *testing.T) {
+	drone := new(Tello)
+	if _, err := drone.AutoOrbit(0.1, 1, 1); err == nil {
+		t.Error("Expected a tiny radius to be refused")
+	}
+	const radius = 2
+	done, err := drone.AutoOrbit(radius, 1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := drone.AutoOrbit(radius, 1, 1); err == nil {
+		t.Error("Expected a second orbit to be refused")
+	}
+
+	// move half way round the centre, which is straight ahead at (0, 2)
+	go func() {
+		for deg := 45; deg <= 180; deg += 45 {
+			time.Sleep(100 * time.Millisecond)
+			a := -math.Pi/2 + float64(deg)*math.Pi/180
+			drone.fdMu.Lock()
+			drone.fd.MVO.PositionX = float32(radius * math.Cos(a))
+			drone.fd.MVO.PositionY = float32(radius + radius*math.Sin(a))
+			drone.fdMu.Unlock()
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	drone.ctrlMu.RLock()
+	rx := drone.ctrlRx
+	drone.ctrlMu.RUnlock()
+	if rx <= 0 {
+		t.Errorf("Expected to be moving right, got %d", rx)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(3 * time.Second):
+		drone.CancelAutoOrbit()
+		t.Fatal("Orbit did not complete")
+	}
+	drone.ctrlMu.RLock()
+	defer drone.ctrlMu.RUnlock()
+	if drone.ctrlRx != 0 || drone.ctrlRy != 0 || drone.ctrlLx != 0 {
+		t.Error("Expected the sticks to be centred after the orbit")
+	}
+}
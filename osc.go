@@ -0,0 +1,148 @@
+// osc.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"time"
+)
+
+// OSCConfig configures StartOSC().
+type OSCConfig struct {
+	Addr   string        // the UDP address to send to, eg. "127.0.0.1:9000" for TouchDesigner or Max/MSP
+	Prefix string        // prepended to every OSC address, "" means "/tello"
+	Period time.Duration // how often the telemetry is sent, 0 means 50ms, it must not be negative
+}
+
+const (
+	defaultOSCPrefix = "/tello"
+	defaultOSCPeriod = 50 * time.Millisecond
+)
+
+// StartOSC sends the drone's motion as Open Sound Control messages over UDP, for driving visuals
+// or music from creative-coding tools, and returns a func to stop.  Each period it sends, with
+// the default prefix:
+//
+//	/tello/attitude   f f f  pitch, roll and yaw in degrees, from the IMU
+//	/tello/height     f      height above the takeoff point in metres
+//	/tello/velocity   f f f  x, y and z velocity in m/s, from the MVO
+//	/tello/speed      f      horizontal speed in m/s
+//	/tello/battery    i      battery percentage
+//	/tello/flying     i      1 while airborne, otherwise 0
+func (tello *Tello) StartOSC(cfg OSCConfig) (stop func(), err error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = defaultOSCPrefix
+	}
+	switch {
+	case cfg.Period < 0:
+		return nil, errors.New("OSC period must be positive")
+	case cfg.Period == 0:
+		cfg.Period = defaultOSCPeriod
+	}
+	conn, err := net.Dial("udp", cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		defer conn.Close()
+		clock := tello.cfg.getClock()
+		for {
+			for _, msg := range oscTelemetry(cfg.Prefix, tello.GetFlightData()) {
+				conn.Write(msg) // best effort, like the telemetry itself
+			}
+			select {
+			case <-done:
+				return
+			case <-clock.After(cfg.Period):
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}, nil
+}
+
+// oscTelemetry returns the OSC messages describing fd.
+func oscTelemetry(prefix string, fd FlightData) [][]byte {
+	pitch, roll, yaw := QuatToEulerDeg(fd.IMU.QuaternionX, fd.IMU.QuaternionY, fd.IMU.QuaternionZ, fd.IMU.QuaternionW)
+	vx, vy, vz := float32(fd.MVO.VelocityX)/100, float32(fd.MVO.VelocityY)/100, float32(fd.MVO.VelocityZ)/100
+	var flying int32
+	if fd.Flying || fd.State.IsAirborne() {
+		flying = 1
+	}
+	return [][]byte{
+		oscMessage(prefix+"/attitude", pitch, roll, yaw),
+		oscMessage(prefix+"/height", fd.HeightM()),
+		oscMessage(prefix+"/velocity", vx, vy, vz),
+		oscMessage(prefix+"/speed", float32(math.Hypot(float64(vx), float64(vy)))),
+		oscMessage(prefix+"/battery", int32(fd.BatteryPercentage)),
+		oscMessage(prefix+"/flying", flying),
+	}
+}
+
+// oscMessage encodes an OSC 1.0 message, args may be float32, int32 or string.
+func oscMessage(addr string, args ...interface{}) []byte {
+	tags := ","
+	for _, arg := range args {
+		switch arg.(type) {
+		case float32:
+			tags += "f"
+		case int32:
+			tags += "i"
+		case string:
+			tags += "s"
+		default:
+			panic("unsupported OSC argument")
+		}
+	}
+	msg := appendOSCString(nil, addr)
+	msg = appendOSCString(msg, tags)
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case float32:
+			msg = appendOSCUint32(msg, math.Float32bits(v))
+		case int32:
+			msg = appendOSCUint32(msg, uint32(v))
+		case string:
+			msg = appendOSCString(msg, v)
+		}
+	}
+	return msg
+}
+
+func appendOSCUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// appendOSCString appends s, null terminated and padded to a multiple of 4 bytes.
+func appendOSCString(b []byte, s string) []byte {
+	b = append(b, s...)
+	return append(b, make([]byte, 4-len(s)%4)...)
+}
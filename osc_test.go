@@ -0,0 +1,85 @@
+// osc_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestOSCMessage(t *testing.T) {
+	got := oscMessage("/tello/h", float32(1.5), int32(-2), "ab")
+	want := []byte{
+		'/', 't', 'e', 'l', 'l', 'o', '/', 'h', 0, 0, 0, 0, // the address always gets a terminating null
+		',', 'f', 'i', 's', 0, 0, 0, 0,
+		0x3f, 0xc0, 0, 0,
+		0xff, 0xff, 0xff, 0xfe,
+		'a', 'b', 0, 0,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Got % x\nwant % x", got, want)
+	}
+}
+
+func TestStartOSC(t *testing.T) {
+	sink, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	drone := new(Tello)
+	if _, err := drone.StartOSC(OSCConfig{Addr: sink.LocalAddr().String(), Period: -time.Millisecond}); err == nil {
+		t.Error("Expected a negative period to be refused")
+	}
+	drone.fd.Height = 12
+	drone.fd.BatteryPercentage = 80
+	drone.fd.MVO.VelocityX, drone.fd.MVO.VelocityY = 30, 40
+	stop, err := drone.StartOSC(OSCConfig{Addr: sink.LocalAddr().String(), Prefix: "/drone1", Period: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	want := map[string][]byte{
+		"/drone1/height":  oscMessage("/drone1/height", float32(1.2)),
+		"/drone1/speed":   oscMessage("/drone1/speed", float32(0.5)),
+		"/drone1/battery": oscMessage("/drone1/battery", int32(80)),
+		"/drone1/flying":  oscMessage("/drone1/flying", int32(0)),
+	}
+	buff := make([]byte, 512)
+	for len(want) > 0 {
+		sink.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := sink.Read(buff)
+		if err != nil {
+			t.Fatalf("Still waiting for %d messages - %v", len(want), err)
+		}
+		addr := string(buff[:bytes.IndexByte(buff[:n], 0)])
+		if w, ok := want[addr]; ok {
+			if !bytes.Equal(buff[:n], w) {
+				t.Errorf("%s: got % x, want % x", addr, buff[:n], w)
+			}
+			delete(want, addr)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// packethook.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"fmt"
+	"time"
+)
+
+// Direction says which way a packet passed to a PacketHook was travelling.
+type Direction int
+
+// Packet directions...
+const (
+	DirSent     Direction = iota // encoded and sent to the Tello
+	DirReceived                  // received from the Tello and decoded
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirSent:
+		return "sent"
+	case DirReceived:
+		return "received"
+	}
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
+// Packet is a control packet as passed to a PacketHook, the hook may keep it.
+type Packet struct {
+	Time      time.Time
+	Type      uint8 // the packet type, eg. 5 for commands which set something
+	MessageID MessageID
+	Sequence  uint16
+	Payload   []byte
+	Raw       []byte // the whole packet, eg. for protocol.CorpusWriter
+}
+
+// PacketHook is called with every binary packet sent to or received from the Tello, eg. for logging,
+// capturing a fuzz corpus or live protocol visualisation.  Text-SDK traffic and malformed packets are
+// not passed on.  It is called from the Goroutines which send and receive packets, so it must return
+// promptly; it may be called concurrently for the two directions.
+type PacketHook func(dir Direction, pkt Packet)
+
+// SetPacketHook sets the PacketHook, nil removes it.
+func (tello *Tello) SetPacketHook(hook PacketHook) {
+	tello.packetHookMu.Lock()
+	tello.packetHook = hook
+	tello.packetHookMu.Unlock()
+}
+
+// hookPacket passes buff to the PacketHook, if one is set and buff is a binary packet.
+func (tello *Tello) hookPacket(dir Direction, buff []byte, at time.Time) {
+	tello.packetHookMu.RLock()
+	hook := tello.packetHook
+	tello.packetHookMu.RUnlock()
+	if hook == nil {
+		return
+	}
+	if _, ok := packetID(buff); !ok {
+		return
+	}
+	raw := append([]byte(nil), buff...)
+	pkt := bufferToPacket(raw)
+	hook(dir, Packet{
+		Time:      at,
+		Type:      pkt.packetType,
+		MessageID: pkt.messageID,
+		Sequence:  pkt.sequence,
+		Payload:   pkt.payload,
+		Raw:       raw,
+	})
+}
@@ -0,0 +1,74 @@
+// packethook_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello/protocol"
+)
+
+func TestPacketHook(t *testing.T) {
+	drone, _ := simDrone(t)
+	var mu sync.Mutex
+	seen := map[Direction]map[MessageID]Packet{DirSent: {}, DirReceived: {}}
+	drone.SetPacketHook(func(dir Direction, pkt Packet) {
+		mu.Lock()
+		seen[dir][pkt.MessageID] = pkt
+		mu.Unlock()
+	})
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := drone.TakeOffAndWait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond) // let some telemetry arrive
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, want := range []struct {
+		dir Direction
+		id  MessageID
+	}{{DirSent, MsgDoTakeoff}, {DirSent, MsgSetStick}, {DirReceived, MsgDoTakeoff}, {DirReceived, MsgFlightStatus}} {
+		pkt, ok := seen[want.dir][want.id]
+		if !ok {
+			t.Errorf("Expected %s %s to be hooked", want.dir, want.id)
+			continue
+		}
+		p, err := protocol.Parse(pkt.Raw)
+		if err != nil || MessageID(p.MessageID) != pkt.MessageID || p.Sequence != pkt.Sequence || p.Type != pkt.Type {
+			t.Errorf("%s %s: raw packet %+v does not match %+v (%v)", want.dir, want.id, p, pkt, err)
+		}
+		if pkt.Time.IsZero() {
+			t.Errorf("%s %s: no time", want.dir, want.id)
+		}
+	}
+	if pkt := seen[DirReceived][MsgFlightStatus]; len(pkt.Payload) < 24 {
+		t.Errorf("Expected a flight status payload, got % x", pkt.Payload)
+	}
+}
@@ -0,0 +1,102 @@
+// photo.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "fmt"
+
+// PhotoStatus is the stage reached by a picture, see PhotoEvent.
+type PhotoStatus int
+
+// PhotoStatus values...
+const (
+	PhotoAccepted PhotoStatus = iota // the drone has accepted the take-picture command
+	PhotoRefused                     // the drone could not take the picture, Err says why
+	PhotoReceived                    // the picture has been transferred and reassembled
+	PhotoAborted                     // the picture was lost during transfer, Err says why
+)
+
+func (ps PhotoStatus) String() string {
+	switch ps {
+	case PhotoAccepted:
+		return "accepted"
+	case PhotoRefused:
+		return "refused"
+	case PhotoReceived:
+		return "received"
+	case PhotoAborted:
+		return "aborted"
+	}
+	return fmt.Sprintf("PhotoStatus(%d)", int(ps))
+}
+
+// PhotoEvent is the Data of an EvPhoto Event, which is sent as a picture progresses.
+type PhotoEvent struct {
+	Status    PhotoStatus
+	FileID    uint16 // the drone's ID for the picture, once the transfer has started
+	Size      int    // the size of the picture, once the transfer has started
+	Remaining int    // further pictures the drone reports it can take, -1 if not reported
+	Err       error  // a *PhotoError for PhotoRefused and PhotoAborted
+}
+
+// PhotoError reports that the drone could not take a picture, or that a picture was lost in transfer.
+// When the drone refused the picture it wraps the equivalent *CommandError.
+type PhotoError struct {
+	Code   byte   // the drone's non-zero result code, zero for transfer problems
+	FileID uint16 // the picture being transferred, for transfer problems
+	Reason string // why the transfer failed; the meanings of the result codes are not documented
+}
+
+func (e *PhotoError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("Tello could not take picture, result code %d", e.Code)
+	}
+	return fmt.Sprintf("Tello picture %d lost in transfer (%s)", e.FileID, e.Reason)
+}
+
+// Unwrap returns the *CommandError for a refused picture, so errors.As() works as for other commands.
+func (e *PhotoError) Unwrap() error {
+	if e.Code == 0 {
+		return nil
+	}
+	return &CommandError{MessageID: MsgDoTakePic, Result: e.Code}
+}
+
+// photoResult handles the drone's response to a take-picture command.
+func (tello *Tello) photoResult(payload []byte) {
+	ev := PhotoEvent{Status: PhotoAccepted, Remaining: -1}
+	if len(payload) > 1 {
+		ev.Remaining = int(payload[1])
+	}
+	if len(payload) > 0 && payload[0] != 0 {
+		ev.Status = PhotoRefused
+		ev.Err = &PhotoError{Code: payload[0]}
+		tello.logf("Take picture refused - %v\n", ev.Err)
+	}
+	tello.emitEvent(EvPhoto, ev)
+}
+
+// photoAborted reports that a picture was lost in transfer.
+func (tello *Tello) photoAborted(fID uint16, size int, reason string) {
+	err := &PhotoError{FileID: fID, Reason: reason}
+	tello.logf("%v\n", err)
+	tello.emitEvent(EvPhoto, PhotoEvent{Status: PhotoAborted, FileID: fID, Size: size, Remaining: -1, Err: err})
+}
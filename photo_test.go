@@ -0,0 +1,116 @@
+// photo_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPhotoEvents(t *testing.T) {
+	drone, fake := pushingDrone(t)
+	fake.serve(func(pkt packet) {
+		if pkt.messageID != MsgDoTakePic {
+			return
+		}
+		fake.pushSeq(MsgDoTakePic, 0, 0, 5)
+		fake.pushSeq(MsgFileSize, 0, byte(FtJPEG), 100, 0, 0, 0, 1, 0)
+		fake.pushSeq(MsgFileData, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0xff, 0xd8, 0xff)
+		fake.pushSeq(MsgFileSize, 0, byte(FtJPEG), 100, 0, 0, 0, 2, 0) // the first picture is abandoned
+		fake.pushSeq(MsgFileSize, 0, 9, 100, 0, 0, 0, 3, 0)
+	})
+	events, stop := drone.ListenEvents()
+	defer stop()
+
+	drone.TakePicture()
+	var got []PhotoEvent
+	for len(got) < 3 {
+		select {
+		case ev := <-events:
+			if ev.Type == EvPhoto {
+				got = append(got, ev.Data.(PhotoEvent))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for photo events, got %+v", got)
+		}
+	}
+	if got[0].Status != PhotoAccepted || got[0].Remaining != 5 || got[0].Err != nil {
+		t.Errorf("Expected the picture to be accepted, got %+v", got[0])
+	}
+	var pe *PhotoError
+	if got[1].Status != PhotoAborted || got[1].FileID != 1 || !errors.As(got[1].Err, &pe) || pe.FileID != 1 {
+		t.Errorf("Expected picture 1 to be aborted, got %+v", got[1])
+	}
+	if got[2].Status != PhotoAborted || got[2].FileID != 3 {
+		t.Errorf("Expected picture 3 to be rejected, got %+v", got[2])
+	}
+}
+
+func TestPhotoRefused(t *testing.T) {
+	drone := new(Tello)
+	events, stop := drone.ListenEvents()
+	defer stop()
+	drone.photoResult([]byte{2})
+	ev := (<-events).Data.(PhotoEvent)
+	if ev.Status != PhotoRefused || ev.Remaining != -1 {
+		t.Errorf("Expected a refusal, got %+v", ev)
+	}
+	if ev.Err.Error() != "Tello could not take picture, result code 2" {
+		t.Errorf("Unexpected error %v", ev.Err)
+	}
+
+	// as returned by TakePictureCtx()
+	err := resultError(MsgDoTakePic, []byte{42})
+	var ce *CommandError
+	if !errors.As(err, &ce) || ce.Result != 42 || ce.MessageID != MsgDoTakePic {
+		t.Errorf("Expected a PhotoError wrapping a CommandError, got %v", err)
+	}
+	if err.Error() != "Tello could not take picture, result code 42" {
+		t.Errorf("Unexpected error %v", err)
+	}
+	drone.reassembleFile()
+	if ev := (<-events).Data.(PhotoEvent); ev.Status != PhotoReceived {
+		t.Errorf("Expected a received picture, got %+v", ev)
+	}
+}
+
+func TestOutOfOrderChunks(t *testing.T) {
+	drone, fake := pushingDrone(t)
+	files, stop := drone.ListenFiles()
+	defer stop()
+	fake.pushSeq(MsgFileSize, 5, byte(FtJPEG), 4, 0, 0, 0, 1, 0)
+	fake.pushSeq(MsgFileData, 8, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0xff, 0xd9)
+	fake.pushSeq(MsgFileData, 7, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0xff, 0xd8) // overtaken on the way
+	select {
+	case fd := <-files:
+		if !bytes.Equal(fd.FileBytes, []byte{0xff, 0xd8, 0xff, 0xd9}) {
+			t.Errorf("Unexpected picture % x", fd.FileBytes)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for the picture, was a chunk dropped?")
+	}
+	if s := drone.Stats(); s.Stale != 0 {
+		t.Errorf("Expected no stale packets, got %d", s.Stale)
+	}
+}
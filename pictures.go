@@ -22,6 +22,7 @@
 package tello
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"sort"
@@ -36,24 +37,30 @@ func (tello *Tello) TakePicture() (err error) {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgDoTakePic, tello.ctrlSeq, 0)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	pkt := newPacket(ptSet, MsgDoTakePic, tello.ctrlSeq, 0)
+	tello.enqueue(packetToBuffer(pkt))
 	//log.Println("Sent take picture request")
 	return nil
 }
 
+// TakePictureCtx is as TakePicture() but waits for the Tello to acknowledge the request, see TakeOffAndWait().
+// N.B. The picture itself arrives later, see ListenFiles().
+func (tello *Tello) TakePictureCtx(ctx context.Context) (err error) {
+	return tello.commandAndWait(ctx, ptSet, MsgDoTakePic, nil)
+}
+
 func (tello *Tello) sendFileSize() {
 	tello.ctrlMu.Lock()
 	defer tello.ctrlMu.Unlock()
 	tello.ctrlSeq++
-	tello.ctrlConn.Write(packetToBuffer(newPacket(ptData1, msgFileSize, tello.ctrlSeq, 1)))
+	tello.enqueue(packetToBuffer(newPacket(ptData1, MsgFileSize, tello.ctrlSeq, 1)))
 }
 
 func (tello *Tello) sendFileAckPiece(done byte, fID uint16, pieceNum uint32) {
 	tello.ctrlMu.Lock()
 	defer tello.ctrlMu.Unlock()
 	tello.ctrlSeq++
-	pkt := newPacket(ptData1, msgFileData, tello.ctrlSeq, 7)
+	pkt := newPacket(ptData1, MsgFileData, tello.ctrlSeq, 7)
 	pkt.payload[0] = done
 	pkt.payload[1] = byte(fID)
 	pkt.payload[2] = byte(fID >> 8)
@@ -61,28 +68,27 @@ func (tello *Tello) sendFileAckPiece(done byte, fID uint16, pieceNum uint32) {
 	pkt.payload[4] = byte(pieceNum >> 8)
 	pkt.payload[5] = byte(pieceNum >> 16)
 	pkt.payload[6] = byte(pieceNum >> 24)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.enqueue(packetToBuffer(pkt))
 }
 
 func (tello *Tello) sendFileDone(fID uint16, size int) {
 	tello.ctrlMu.Lock()
 	defer tello.ctrlMu.Unlock()
 	tello.ctrlSeq++
-	pkt := newPacket(ptGet, msgFileDone, tello.ctrlSeq, 6)
+	pkt := newPacket(ptGet, MsgFileDone, tello.ctrlSeq, 6)
 	pkt.payload[0] = byte(fID)
 	pkt.payload[1] = byte(fID >> 8)
 	pkt.payload[2] = byte(size)
 	pkt.payload[3] = byte(size >> 8)
 	pkt.payload[4] = byte(size >> 16)
 	pkt.payload[5] = byte(size >> 24)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.enqueue(packetToBuffer(pkt))
 }
 
 // reassembleFile reassembles a chunked file in tello.fileTemp into a contiguous byte array in tello.files
 func (tello *Tello) reassembleFile() {
 	var fd FileData
 	tello.fdMu.Lock()
-	defer tello.fdMu.Unlock()
 
 	fd.FileType = tello.fileTemp.filetype
 	fd.FileSize = tello.fileTemp.accumSize
@@ -99,9 +105,20 @@ func (tello *Tello) reassembleFile() {
 		}
 	}
 	tello.files = append(tello.files, fd)
+	fID := tello.fileTemp.fID
 	tello.fileTemp = fileInternal{}
-	for l := range tello.filesListeners { // Notify file listeners
-		l <- fd
+	tello.fdMu.Unlock()
+	tello.emitEvent(EvPhoto, PhotoEvent{Status: PhotoReceived, FileID: fID, Size: fd.FileSize, Remaining: -1})
+
+	// notify file listeners, without holding up access to the flight data; a listener which is not
+	// keeping up misses the file rather than blocking us, and anyone waiting on filesMu
+	tello.filesMu.Lock()
+	defer tello.filesMu.Unlock()
+	for l := range tello.filesListeners {
+		select {
+		case l <- fd:
+		default:
+		}
 	}
 }
 
@@ -149,13 +166,21 @@ func (tello *Tello) GrabFiles() []FileData {
 	return res
 }
 
-// ListenFiles returns a channel that will be filled when a new file is reassembled, and a function to stop listening
+const filesChanSize = 4
+
+// ListenFiles returns a channel that will be filled when a new file is reassembled, and a function to stop listening.
+// N.B. Files are dropped, rather than queued, for a listener which has fallen filesChanSize files behind.
 func (tello *Tello) ListenFiles() (chan FileData, func()) {
-	tello.fdMu.Lock()
-	defer tello.fdMu.Unlock()
-	res := make(chan FileData)
+	tello.filesMu.Lock()
+	defer tello.filesMu.Unlock()
+	if tello.filesListeners == nil {
+		tello.filesListeners = map[chan FileData]chan FileData{}
+	}
+	res := make(chan FileData, filesChanSize)
 	tello.filesListeners[res] = res
 	return res, func() {
+		tello.filesMu.Lock()
+		defer tello.filesMu.Unlock()
 		if _, present := tello.filesListeners[res]; present {
 			delete(tello.filesListeners, res)
 			close(res)
@@ -0,0 +1,154 @@
+// pid.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+/*
+Package pid provides a PID (proportional, integral, derivative) controller with gains which may be
+tuned while it is running, integral anti-windup, and telemetry of its setpoint, error and terms.
+
+The tello package's autopilots use these controllers when configured via tello.WithPID(), and the
+live controllers are available via Tello.PID() for tuning, eg.
+
+	drone := tello.NewTello(tello.WithPID(tello.PIDHeight, pid.Gains{Kp: 3000, Ki: 200, Kd: 500}))
+	...
+	drone.PID(tello.PIDHeight).SetGains(pid.Gains{Kp: 2500, Ki: 200, Kd: 800})
+	log.Printf("%+v", drone.PID(tello.PIDHeight).Telemetry())
+*/
+package pid
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Gains are the tuning constants of a Controller.  Ki is per second and Kd is in seconds.
+type Gains struct {
+	Kp, Ki, Kd float64
+}
+
+// Telemetry describes the most recent update of a Controller.
+type Telemetry struct {
+	Setpoint, Measured float64
+	Error              float64 // Setpoint - Measured
+	P, I, D            float64 // the terms which were summed to give Output
+	Output             float64 // after limiting
+	Saturated          bool    // was the output limited?
+	Time               time.Time
+}
+
+// Controller is a PID controller whose output is limited to a range.  It is safe for concurrent use.
+type Controller struct {
+	mu        sync.Mutex
+	gains     Gains
+	min, max  float64
+	integral  float64 // the accumulated I term, rather than the raw error, so Ki may be changed smoothly
+	prevErr   float64
+	havePrev  bool
+	telemetry Telemetry
+}
+
+// New returns a Controller with the given gains whose output is limited to min..max.
+func New(gains Gains, min, max float64) *Controller {
+	if min > max {
+		min, max = max, min
+	}
+	return &Controller{gains: gains, min: min, max: max}
+}
+
+// Gains returns the current gains.
+func (c *Controller) Gains() Gains {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gains
+}
+
+// SetGains changes the gains, it may be called while the controller is in use.
+func (c *Controller) SetGains(gains Gains) {
+	c.mu.Lock()
+	c.gains = gains
+	if gains.Ki == 0 {
+		c.integral = 0
+	}
+	c.mu.Unlock()
+}
+
+// Limits returns the output range.
+func (c *Controller) Limits() (min, max float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.min, c.max
+}
+
+// Reset clears the controller's history, it should be called before controlling towards a new setpoint.
+func (c *Controller) Reset() {
+	c.mu.Lock()
+	c.integral, c.prevErr, c.havePrev = 0, 0, false
+	c.telemetry = Telemetry{}
+	c.mu.Unlock()
+}
+
+// Update returns the output for the measured value given the setpoint, dt is the time since
+// the last update and is ignored on the first update after New() or Reset().
+// To avoid windup, the integral is held while the output is saturated in the direction
+// it would grow, and is itself limited to the output range.
+func (c *Controller) Update(setpoint, measured float64, dt time.Duration) (output float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := setpoint - measured
+	secs := dt.Seconds()
+	g := c.gains
+
+	p := g.Kp * err
+	var d float64
+	if c.havePrev && secs > 0 {
+		d = g.Kd * (err - c.prevErr) / secs
+		integral := c.integral + g.Ki*err*secs
+		integral = math.Max(c.min, math.Min(c.max, integral))
+		// only integrate if it doesn't push a saturated output further out
+		unclamped := p + integral + d
+		if (unclamped <= c.max || integral < c.integral) && (unclamped >= c.min || integral > c.integral) {
+			c.integral = integral
+		}
+	}
+	c.prevErr, c.havePrev = err, true
+
+	sum := p + c.integral + d
+	output = math.Max(c.min, math.Min(c.max, sum))
+	c.telemetry = Telemetry{
+		Setpoint:  setpoint,
+		Measured:  measured,
+		Error:     err,
+		P:         p,
+		I:         c.integral,
+		D:         d,
+		Output:    output,
+		Saturated: output != sum,
+		Time:      time.Now(),
+	}
+	return output
+}
+
+// Telemetry returns details of the most recent Update().
+func (c *Controller) Telemetry() Telemetry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.telemetry
+}
@@ -0,0 +1,102 @@
+// pid_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pid
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProportional(t *testing.T) {
+	c := New(Gains{Kp: 2}, -10, 10)
+	if out := c.Update(3, 1, 0); out != 4 {
+		t.Errorf("Expected 4, got %v", out)
+	}
+	if out := c.Update(100, 0, time.Second); out != 10 {
+		t.Errorf("Expected the output to be limited to 10, got %v", out)
+	}
+	tm := c.Telemetry()
+	if tm.Setpoint != 100 || tm.Error != 100 || !tm.Saturated {
+		t.Errorf("Unexpected telemetry %+v", tm)
+	}
+}
+
+func TestIntegralAndDerivative(t *testing.T) {
+	c := New(Gains{Ki: 1}, -10, 10)
+	c.Update(1, 0, 0) // first update only primes the history
+	for i := 0; i < 3; i++ {
+		c.Update(1, 0, time.Second)
+	}
+	if i := c.Telemetry().I; i != 3 {
+		t.Errorf("Expected an integral of 3, got %v", i)
+	}
+
+	c = New(Gains{Kd: 1}, -10, 10)
+	c.Update(0, 0, 0)
+	if out := c.Update(0, -2, 500*time.Millisecond); out != 4 {
+		t.Errorf("Expected a derivative of 4, got %v", out)
+	}
+}
+
+func TestAntiWindup(t *testing.T) {
+	c := New(Gains{Kp: 1, Ki: 1}, -5, 5)
+	c.Update(100, 0, 0)
+	for i := 0; i < 50; i++ {
+		c.Update(100, 0, time.Second)
+	}
+	if i := c.Telemetry().I; i != 0 {
+		t.Errorf("Expected no windup while saturated, got an integral of %v", i)
+	}
+	// once the error falls the integral may grow, but never beyond the limits
+	c = New(Gains{Ki: 1}, -5, 5)
+	c.Update(100, 0, 0)
+	for i := 0; i < 50; i++ {
+		c.Update(100, 0, time.Second)
+	}
+	if i := c.Telemetry().I; i != 5 {
+		t.Errorf("Expected the integral to be limited to 5, got %v", i)
+	}
+	// and unwinds immediately when the error reverses
+	if out := c.Update(-1, 0, time.Second); out != 4 {
+		t.Errorf("Expected the integral to unwind to 4, got %v", out)
+	}
+}
+
+func TestTuning(t *testing.T) {
+	c := New(Gains{Kp: 1, Ki: 1}, 10, -10) // limits swapped
+	if min, max := c.Limits(); min != -10 || max != 10 {
+		t.Errorf("Expected limits -10..10, got %v..%v", min, max)
+	}
+	c.Update(1, 0, 0)
+	c.Update(1, 0, time.Second)
+	c.SetGains(Gains{Kp: 3})
+	if g := c.Gains(); g.Kp != 3 || g.Ki != 0 {
+		t.Errorf("Gains not set, got %+v", g)
+	}
+	if out := c.Update(1, 0, time.Second); out != 3 {
+		t.Errorf("Expected the integral to be dropped with Ki, got %v", out)
+	}
+	c.Reset()
+	if tm := c.Telemetry(); tm != (Telemetry{}) {
+		t.Errorf("Expected Reset to clear the telemetry, got %+v", tm)
+	}
+}
@@ -0,0 +1,94 @@
+// ports.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "net"
+
+// AnyPort may be passed to WithLocalControlPort(), WithVideoPort(), ControlConnect() or
+// VideoConnect() to bind a free ephemeral port rather than a fixed one, so that several Tellos
+// can be flown from one process without their ports clashing.  The ports actually bound are
+// available from LocalControlPort() and VideoPort(), and the drone is always told the video
+// port which is really being listened on.  The Transport's connections must report their
+// *net.UDPAddr local address, as UDPTransport's do.
+const AnyPort = -1
+
+// bindPort converts a port which may be AnyPort to the port to ask the Transport for.
+func bindPort(port int) int {
+	if port == AnyPort {
+		return 0
+	}
+	return port
+}
+
+// boundPort returns the local port conn is bound to, or requested if that cannot be discovered.
+func boundPort(conn net.Conn, requested int) int {
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && addr.Port != 0 {
+		return addr.Port
+	}
+	return requested
+}
+
+// LocalControlPort returns the local UDP port of the control connection, or 0 if not connected.
+func (tello *Tello) LocalControlPort() int {
+	tello.ctrlMu.RLock()
+	defer tello.ctrlMu.RUnlock()
+	if tello.ctrlState == connDisconnected || tello.ctrlConn == nil {
+		return 0
+	}
+	return boundPort(tello.ctrlConn, 0)
+}
+
+// reserveVideoPort binds a free port for video, so that it can be advertised to the drone before
+// VideoConnect() is called when the video port is configured as AnyPort.  videoMu must be held.
+func (tello *Tello) reserveVideoPort() int {
+	if tello.videoReserved == nil {
+		conn, err := tello.cfg.getTransport().ListenPackets(0)
+		if err != nil {
+			tello.logf("Could not reserve a video port, using %d - %v\n", defaultTelloVideoPort, err)
+			return defaultTelloVideoPort
+		}
+		tello.videoReserved = conn
+	}
+	return boundPort(tello.videoReserved, defaultTelloVideoPort)
+}
+
+// takeVideoReservation returns the reserved video connection if it is bound to port (or port is 0),
+// otherwise the reservation is released and nil returned.  videoMu must be held.
+func (tello *Tello) takeVideoReservation(port int) net.Conn {
+	conn := tello.videoReserved
+	tello.videoReserved = nil
+	if conn == nil || port == 0 || boundPort(conn, 0) == port {
+		return conn
+	}
+	conn.Close()
+	return nil
+}
+
+// releaseVideoReservation closes any reserved video port.
+func (tello *Tello) releaseVideoReservation() {
+	tello.videoMu.Lock()
+	if tello.videoReserved != nil {
+		tello.videoReserved.Close()
+		tello.videoReserved = nil
+	}
+	tello.videoMu.Unlock()
+}
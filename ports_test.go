@@ -0,0 +1,80 @@
+// ports_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello/sim"
+)
+
+// two Tellos in one process, each on ephemeral ports, must each get their own video
+func TestAnyPortTwoDrones(t *testing.T) {
+	var drones [2]*Tello
+	var videos [2]<-chan []byte
+	for i := range drones {
+		s := sim.New(sim.WithVideo())
+		if err := s.Listen("127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		drones[i] = NewTello(WithAddress("127.0.0.1", s.Addr().Port), WithLocalControlPort(AnyPort), WithVideoPort(AnyPort))
+		defer drones[i].ControlDisconnect()
+		if err := drones[i].ControlConnectDefault(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctrl0, ctrl1 := drones[0].LocalControlPort(), drones[1].LocalControlPort()
+	if ctrl0 <= 0 || ctrl1 <= 0 || ctrl0 == ctrl1 {
+		t.Errorf("Expected distinct control ports, got %d and %d", ctrl0, ctrl1)
+	}
+	vid0, vid1 := drones[0].VideoPort(), drones[1].VideoPort()
+	if vid0 <= 0 || vid1 <= 0 || vid0 == vid1 {
+		t.Errorf("Expected distinct acknowledged video ports, got %d and %d", vid0, vid1)
+	}
+	for i, drone := range drones {
+		var err error
+		if videos[i], err = drone.VideoConnectDefault(); err != nil {
+			t.Fatal(err)
+		}
+		drone.videoMu.Lock()
+		port := drone.videoPort
+		drone.videoMu.Unlock()
+		if port != drone.VideoPort() {
+			t.Errorf("Drone %d listening on %d but advertised %d", i, port, drone.VideoPort())
+		}
+		drone.GetVideoSpsPps()
+	}
+	for i, video := range videos {
+		select {
+		case <-video:
+		case <-time.After(3 * time.Second):
+			t.Errorf("No video for drone %d", i)
+		}
+	}
+
+	drones[0].ControlDisconnect()
+	if p := drones[0].LocalControlPort(); p != 0 {
+		t.Errorf("Expected no control port when disconnected, got %d", p)
+	}
+}
@@ -0,0 +1,197 @@
+// preflight.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPreflight is matched by every *PreflightError, test for it with errors.Is().
+var ErrPreflight = errors.New("Tello failed its pre-flight check")
+
+const (
+	defaultPreflightMinBattery = 20 // percent
+	defaultPreflightMinWifi    = 30
+)
+
+// KnownGoodFirmware lists the firmware versions known to work well with this package, others are
+// reported as a warning by PreflightCheck().
+var KnownGoodFirmware = []string{"01.04.35.01", "01.04.78.01", "01.04.91.01", "01.04.92.01"}
+
+// PreflightConfig sets the limits applied by PreflightCheck(), zero values take the defaults shown.
+type PreflightConfig struct {
+	MinBattery      int8     // the lowest battery percentage for takeoff, default 20
+	MinWifiStrength uint8    // the weakest WiFi signal for takeoff, default 30
+	KnownFirmware   []string // firmware versions known to be good, default KnownGoodFirmware
+	RequireKnown    bool     // fail, rather than warn, if the firmware is not known to be good
+}
+
+func (pc PreflightConfig) withDefaults() PreflightConfig {
+	if pc.MinBattery <= 0 {
+		pc.MinBattery = defaultPreflightMinBattery
+	}
+	if pc.MinWifiStrength == 0 {
+		pc.MinWifiStrength = defaultPreflightMinWifi
+	}
+	if pc.KnownFirmware == nil {
+		pc.KnownFirmware = KnownGoodFirmware
+	}
+	return pc
+}
+
+// WithPreflightGate makes TakeOff() and ThrowTakeOff() refuse to launch unless PreflightCheck()
+// passes with the given limits.  TakeOffAndWait() and ThrowTakeOffCtx() then return a
+// *PreflightError, TakeOff() and ThrowTakeOff() just log the failures and do nothing.
+func WithPreflightGate(cfg PreflightConfig) Option {
+	return func(tello *Tello) {
+		cfg = cfg.withDefaults()
+		tello.cfg.preflight = &cfg
+	}
+}
+
+// PreflightReport is the result of PreflightCheck().
+type PreflightReport struct {
+	BatteryPercentage int8
+	ImuState          bool   // false if the IMU has a problem
+	ImuCalibrating    bool   // is an IMU calibration in progress?
+	Temperature       int16  // the IMU temperature from the flight log, 0 if not yet known
+	TemperatureHigh   bool   // is the drone overheating?
+	WifiStrength      uint8  // 0 if not yet known
+	FirmwareVersion   string // "" if not yet known
+	FirmwareKnownGood bool
+	Failures          []string // why the Tello should not take off, empty if all is well
+	Warnings          []string // things worth knowing which do not prevent a takeoff
+}
+
+// OK returns true if there are no Failures.
+func (pr *PreflightReport) OK() bool {
+	return len(pr.Failures) == 0
+}
+
+// PreflightError is returned by the takeoff commands when the pre-flight gate is closed,
+// see WithPreflightGate().  errors.Is(err, ErrPreflight) is true for every PreflightError.
+type PreflightError struct {
+	Report *PreflightReport
+}
+
+func (e *PreflightError) Error() string {
+	return "Tello failed its pre-flight check: " + strings.Join(e.Report.Failures, ", ")
+}
+
+// Is makes a PreflightError match ErrPreflight.
+func (e *PreflightError) Is(target error) bool { return target == ErrPreflight }
+
+// PreflightCheck assesses whether the Tello is fit to take off from the latest flight data,
+// using the limits set by WithPreflightGate() or the defaults.  If the firmware version is not
+// yet known it is requested, so a later check can include it.
+func (tello *Tello) PreflightCheck() *PreflightReport {
+	cfg := PreflightConfig{}.withDefaults()
+	if tello.cfg.preflight != nil {
+		cfg = *tello.cfg.preflight
+	}
+	tello.link.mu.Lock()
+	wifiSeen := tello.link.wifiSeen
+	tello.link.mu.Unlock()
+
+	tello.fdMu.RLock()
+	statusSeen := !tello.odoLastStatus.IsZero()
+	pr := &PreflightReport{
+		BatteryPercentage: tello.fd.BatteryPercentage,
+		ImuState:          tello.fd.ImuState,
+		ImuCalibrating:    tello.calRunning || tello.fd.ImuCalibrationState != 0,
+		Temperature:       tello.fd.IMU.Temperature,
+		TemperatureHigh:   tello.fd.TemperatureHigh,
+		FirmwareVersion:   tello.fd.Version,
+	}
+	if wifiSeen {
+		pr.WifiStrength = tello.fd.WifiStrength
+	}
+	tello.fdMu.RUnlock()
+
+	fail := func(format string, a ...interface{}) { pr.Failures = append(pr.Failures, fmt.Sprintf(format, a...)) }
+	warn := func(format string, a ...interface{}) { pr.Warnings = append(pr.Warnings, fmt.Sprintf(format, a...)) }
+	if !tello.ControlConnected() {
+		fail("not connected")
+	}
+	if !statusSeen {
+		fail("no flight status received yet")
+	} else {
+		if pr.BatteryPercentage < cfg.MinBattery {
+			fail("battery at %d%%, below %d%%", pr.BatteryPercentage, cfg.MinBattery)
+		}
+		if !pr.ImuState {
+			fail("IMU problem reported")
+		}
+		if pr.ImuCalibrating {
+			fail("IMU calibration in progress")
+		}
+		if pr.TemperatureHigh {
+			fail("temperature too high")
+		}
+	}
+	switch {
+	case !wifiSeen:
+		warn("WiFi strength not yet known")
+	case pr.WifiStrength < cfg.MinWifiStrength:
+		fail("WiFi strength %d, below %d", pr.WifiStrength, cfg.MinWifiStrength)
+	}
+	for _, v := range cfg.KnownFirmware {
+		if v == pr.FirmwareVersion {
+			pr.FirmwareKnownGood = true
+		}
+	}
+	switch {
+	case pr.FirmwareVersion == "":
+		tello.GetVersion()
+		if cfg.RequireKnown {
+			fail("firmware version not yet known")
+		} else {
+			warn("firmware version not yet known")
+		}
+	case !pr.FirmwareKnownGood && cfg.RequireKnown:
+		fail("firmware %s is not known to be good", pr.FirmwareVersion)
+	case !pr.FirmwareKnownGood:
+		warn("firmware %s is not known to be good", pr.FirmwareVersion)
+	}
+	return pr
+}
+
+// takeOffGate returns ErrDisarmed or a *PreflightError if we should not take off.
+func (tello *Tello) takeOffGate() error {
+	if err := tello.armGate(); err != nil {
+		return err
+	}
+	return tello.preflightGate()
+}
+
+// preflightGate returns a *PreflightError if WithPreflightGate() is set and the check fails.
+func (tello *Tello) preflightGate() error {
+	if tello.cfg.preflight == nil {
+		return nil
+	}
+	if pr := tello.PreflightCheck(); !pr.OK() {
+		return &PreflightError{Report: pr}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// preflight_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello/sim"
+)
+
+func TestPreflightCheck(t *testing.T) {
+	drone := NewTello()
+	if pr := drone.PreflightCheck(); pr.OK() {
+		t.Error("Expected the check to fail when not connected")
+	}
+
+	drone, s := simDrone(t, WithPreflightGate(PreflightConfig{MinBattery: 50}))
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	var pr *PreflightReport
+	deadline := time.Now().Add(3 * time.Second)
+	for pr = drone.PreflightCheck(); !pr.OK() || len(pr.Warnings) > 0; pr = drone.PreflightCheck() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Check did not pass, got %+v", pr)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if pr.BatteryPercentage != 100 || !pr.ImuState || pr.WifiStrength != 90 || !pr.FirmwareKnownGood {
+		t.Errorf("Unexpected report %+v", pr)
+	}
+
+	s.SetBattery(40)
+	deadline = time.Now().Add(3 * time.Second)
+	for drone.PreflightCheck().OK() && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	err := drone.TakeOffAndWait(context.Background())
+	var pe *PreflightError
+	if !errors.Is(err, ErrPreflight) || !errors.As(err, &pe) || len(pe.Report.Failures) != 1 {
+		t.Fatalf("Expected a battery failure, got %v", err)
+	}
+	drone.TakeOff()
+	time.Sleep(200 * time.Millisecond)
+	if s.State().Phase != sim.Grounded {
+		t.Error("Expected TakeOff() to be refused")
+	}
+}
+
+func TestPreflightFirmware(t *testing.T) {
+	drone, _ := simDrone(t, WithPreflightGate(PreflightConfig{RequireKnown: true, KnownFirmware: []string{"01.00.00.00"}}))
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for drone.PreflightCheck().FirmwareVersion == "" && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	pr := drone.PreflightCheck()
+	if pr.OK() || pr.FirmwareKnownGood || pr.FirmwareVersion != "01.04.92.01" {
+		t.Errorf("Expected the unknown firmware to fail, got %+v", pr)
+	}
+}
@@ -0,0 +1,164 @@
+// corpus.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A corpus is a recorded session in a line-oriented text format which diffs well, eg.
+//
+//	# tello corpus v1
+//	+0.000000 > 636f6e6e5f7265713a9617
+//	+0.015211 < cc6000...
+//
+// Each line gives the time since the first packet, the direction (> to the drone, < from it) and
+// the raw datagram in hex.  Lines starting with # are comments.
+
+const corpusHeader = "# tello corpus v1"
+
+// CorpusPacket is one datagram of a recorded session.
+type CorpusPacket struct {
+	Offset  time.Duration // since the start of the session
+	ToDrone bool
+	Data    []byte
+}
+
+// CorpusWriter records datagrams to a corpus, sanitizing them as it goes.
+// It is safe for concurrent use.
+type CorpusWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	err   error
+}
+
+// NewCorpusWriter starts a corpus on w.
+func NewCorpusWriter(w io.Writer) *CorpusWriter {
+	cw := &CorpusWriter{w: w}
+	_, cw.err = fmt.Fprintln(w, corpusHeader)
+	return cw
+}
+
+// Write records a datagram seen at time at, Sanitize() is applied to it first.
+// Once a write has failed every later call returns the same error.
+func (cw *CorpusWriter) Write(at time.Time, toDrone bool, data []byte) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.err != nil {
+		return cw.err
+	}
+	if cw.start.IsZero() {
+		cw.start = at
+	}
+	offset := at.Sub(cw.start)
+	if offset < 0 {
+		offset = 0
+	}
+	dir := '<'
+	if toDrone {
+		dir = '>'
+	}
+	_, cw.err = fmt.Fprintf(cw.w, "+%.6f %c %x\n", offset.Seconds(), dir, Sanitize(data, toDrone))
+	return cw.err
+}
+
+// Err returns the first error writing the corpus, if any.
+func (cw *CorpusWriter) Err() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.err
+}
+
+// ReadCorpus reads every datagram in a corpus.
+func ReadCorpus(r io.Reader) ([]CorpusPacket, error) {
+	var pkts []CorpusPacket
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 || (fields[1] != ">" && fields[1] != "<") {
+			return pkts, fmt.Errorf("corpus line %d: expected offset, direction and hex", n)
+		}
+		secs, err := strconv.ParseFloat(strings.TrimPrefix(fields[0], "+"), 64)
+		if err != nil {
+			return pkts, fmt.Errorf("corpus line %d: %w", n, err)
+		}
+		data, err := hex.DecodeString(fields[2])
+		if err != nil {
+			return pkts, fmt.Errorf("corpus line %d: %w", n, err)
+		}
+		pkts = append(pkts, CorpusPacket{
+			Offset:  time.Duration(secs * float64(time.Second)),
+			ToDrone: fields[1] == ">",
+			Data:    data,
+		})
+	}
+	return pkts, sc.Err()
+}
+
+// placeholders for sanitized values
+const (
+	SanitizedSSID     = "TELLO-000000"
+	SanitizedPassword = "xxxxxxxx"
+)
+
+// Sanitize returns a copy of a datagram with anything which could identify the drone or its owner
+// replaced: the WiFi name and password, the activation time and the flight log header.  The CRCs
+// are recalculated.  Datagrams which are not valid packets are returned unchanged.
+func Sanitize(data []byte, toDrone bool) []byte {
+	pkt, err := Parse(data)
+	if err != nil {
+		return append([]byte(nil), data...)
+	}
+	fromDrone := !toDrone
+	pl := pkt.Payload
+	switch {
+	case pkt.MessageID == 0x0011 && fromDrone && len(pl) > 2: // QuerySSID reply
+		pkt.Payload = append(append([]byte(nil), pl[:2]...), SanitizedSSID...)
+	case pkt.MessageID == 0x0012 && !fromDrone: // SetSSID
+		pkt.Payload = []byte(SanitizedSSID)
+	case pkt.MessageID == 0x0013 && fromDrone && len(pl) > 1: // QuerySSIDPass reply
+		pkt.Payload = append(append([]byte(nil), pl[:1]...), SanitizedPassword...)
+	case pkt.MessageID == 0x0014 && !fromDrone: // SetSSIDPass
+		pkt.Payload = []byte(SanitizedPassword)
+	case pkt.MessageID == 0x0047 && fromDrone && len(pl) >= 5: // QueryActivationTime reply
+		pkt.Payload = append([]byte{pl[0], 0, 0, 0, 0}, pl[5:]...)
+	case pkt.MessageID == 0x1050 && fromDrone && len(pl) > 2: // LogHeader, may hold serial numbers
+		pkt.Payload = append(append([]byte(nil), pl[:2]...), bytes.Repeat([]byte{0}, len(pl)-2)...)
+	default:
+		return append([]byte(nil), data[:Size(data)]...)
+	}
+	return Encode(pkt)
+}
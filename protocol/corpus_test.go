@@ -0,0 +1,85 @@
+// corpus_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package protocol
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCorpusRoundTrip(t *testing.T) {
+	var b bytes.Buffer
+	cw := NewCorpusWriter(&b)
+	start := time.Now()
+	req := Encode(Packet{Type: TypeGet, ToDrone: true, MessageID: 0x0045, Sequence: 1})
+	reply := Encode(Packet{Type: TypeData1, FromDrone: true, MessageID: 0x0045, Sequence: 1, Payload: []byte("\x0001.04.92.01")})
+	cw.Write(start, true, []byte("conn_req:\x96\x17"))
+	cw.Write(start.Add(1500*time.Microsecond), true, req)
+	cw.Write(start.Add(2*time.Millisecond), false, reply)
+	if err := cw.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), corpusHeader+"\n+0.000000 > 636f6e6e5f7265713a9617\n+0.001500 > ") {
+		t.Errorf("Got corpus:\n%s", b.String())
+	}
+	pkts, err := ReadCorpus(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkts) != 3 || !pkts[1].ToDrone || pkts[2].ToDrone || pkts[2].Offset != 2*time.Millisecond ||
+		!bytes.Equal(pkts[1].Data, req) || !bytes.Equal(pkts[2].Data, reply) {
+		t.Errorf("Read back %+v", pkts)
+	}
+	if _, err := ReadCorpus(strings.NewReader("+0.1 ? cc\n")); err == nil {
+		t.Error("Expected an error for a bad direction")
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		pkt     Packet
+		toDrone bool
+		want    []byte
+	}{
+		{"SSID reply", Packet{FromDrone: true, MessageID: 0x0011, Payload: []byte("\x00\x00MyHomeDrone")}, false, []byte("\x00\x00" + SanitizedSSID)},
+		{"set SSID", Packet{ToDrone: true, MessageID: 0x0012, Payload: []byte("MyHomeDrone")}, true, []byte(SanitizedSSID)},
+		{"set password", Packet{ToDrone: true, MessageID: 0x0014, Payload: []byte("hunter2")}, true, []byte(SanitizedPassword)},
+		{"activation time", Packet{FromDrone: true, MessageID: 0x0047, Payload: []byte{0, 1, 2, 3, 4}}, false, []byte{0, 0, 0, 0, 0}},
+		{"log header", Packet{FromDrone: true, MessageID: 0x1050, Payload: []byte{7, 0, 'S', 'N'}}, false, []byte{7, 0, 0, 0}},
+		{"untouched", Packet{FromDrone: true, MessageID: 0x0045, Payload: []byte("\x0001.04")}, false, []byte("\x0001.04")},
+	} {
+		out, err := Parse(Sanitize(Encode(tc.pkt), tc.toDrone))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !bytes.Equal(out.Payload, tc.want) || out.MessageID != tc.pkt.MessageID {
+			t.Errorf("%s: got % x, want % x", tc.name, out.Payload, tc.want)
+		}
+	}
+	if junk := []byte("not a packet"); !bytes.Equal(Sanitize(junk, true), junk) {
+		t.Error("Expected a non-packet to be unchanged")
+	}
+}
@@ -0,0 +1,285 @@
+// export.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package protocol
+
+//go:generate go run gen.go
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// The message table is exported for other tools as a JSON description and a Wireshark Lua
+// dissector, both generated by go generate into this directory and checked by the tests so
+// that they cannot drift from the Go definitions.
+
+// Description is a machine-readable description of the protocol, built from the message table and
+// the annotated payload layout types, see Describe().
+type Description struct {
+	Header        int                  `json:"header"`
+	MinPacketSize int                  `json:"minPacketSize"`
+	PacketTypes   map[string]string    `json:"packetTypes"`
+	Messages      []MessageDescription `json:"messages"`
+	Layouts       map[string][]Field   `json:"layouts"` // by layout name, as given in MessageDescription
+}
+
+// MessageDescription describes one message ID, naming the layouts of its payloads.
+type MessageDescription struct {
+	ID      uint16 `json:"id"`
+	Name    string `json:"name"`
+	Type    uint8  `json:"type"`
+	Dir     string `json:"direction"`
+	Request string `json:"request,omitempty"`
+	Reply   string `json:"reply,omitempty"`
+	Ack     bool   `json:"ack,omitempty"`
+	Doc     string `json:"doc,omitempty"`
+}
+
+// layoutName returns the name of a payload layout, or "" for none.
+func layoutName(layout interface{}) string {
+	if layout == nil {
+		return ""
+	}
+	t := reflect.TypeOf(layout)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// Describe returns a description of the packet format, message IDs, directions and payload layouts,
+// which tools such as simulators may use to stay consistent with the client.  It is the content of
+// protocol.json.
+func Describe() Description {
+	d := Description{
+		Header:        Header,
+		MinPacketSize: MinPacketSize,
+		PacketTypes:   make(map[string]string),
+		Layouts:       make(map[string][]Field),
+	}
+	for _, t := range []uint8{TypeExtended, TypeGet, TypeData1, TypeData2, TypeSet, TypeFlip} {
+		d.PacketTypes[fmt.Sprint(t)] = TypeName(t)
+	}
+	for _, m := range Messages() {
+		d.Messages = append(d.Messages, MessageDescription{
+			ID: m.ID, Name: m.Name, Type: m.Type, Dir: m.Dir.String(),
+			Request: layoutName(m.Request), Reply: layoutName(m.Reply), Ack: m.Ack, Doc: m.Doc,
+		})
+		for _, layout := range []interface{}{m.Request, m.Reply} {
+			if layout != nil {
+				d.Layouts[layoutName(layout)] = Fields(layout)
+			}
+		}
+	}
+	return d
+}
+
+// WriteJSON writes a machine-readable description of the packet format, message IDs and
+// payload layouts.
+func WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Describe())
+}
+
+// WriteLua writes a Wireshark dissector for the control protocol, load it by copying it to
+// Wireshark's personal plugins folder.
+func WriteLua(w io.Writer) error {
+	d := Describe()
+	names := make([]string, 0, len(d.Layouts))
+	for name := range d.Layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	type luaLayout struct {
+		Name   string
+		Fields []Field
+	}
+	var layouts []luaLayout
+	for _, name := range names {
+		layouts = append(layouts, luaLayout{name, d.Layouts[name]})
+	}
+	return luaTemplate.Execute(w, struct {
+		Header, MinPacketSize int
+		Messages              []MessageDescription
+		Layouts               []luaLayout
+	}{Header, MinPacketSize, d.Messages, layouts})
+}
+
+// luaProtoField maps our field kinds to Wireshark ProtoField constructors.
+var luaProtoField = map[string]string{
+	"u8": "uint8", "i8": "int8", "u16": "uint16", "i16": "int16", "u32": "uint32", "i32": "int32",
+	"f32": "float", "bool": "bool", "string": "string", "bytes": "bytes",
+}
+
+var luaTemplate = template.Must(template.New("lua").Funcs(template.FuncMap{
+	"lower": strings.ToLower,
+	"hex":   func(id uint16) string { return fmt.Sprintf("0x%04x", id) },
+	"ctor":  func(kind string) string { return luaProtoField[kind] },
+	"quote": func(s string) string { return fmt.Sprintf("%q", s) },
+	"bool":  func(b bool) string { return fmt.Sprint(b) },
+}).Parse(`-- Wireshark dissector for the Tello control protocol.
+-- Generated from github.com/SMerrony/tello/protocol by go generate, DO NOT EDIT.
+
+local tello = Proto("tello", "Tello control protocol")
+local f = tello.fields
+local unpack = table.unpack or unpack
+
+local packet_types = { [0] = "Extended", [1] = "Get", [2] = "Data1", [4] = "Data2", [5] = "Set", [6] = "Flip" }
+
+local message_names = {
+{{- range .Messages}}
+	[{{hex .ID}}] = {{quote .Name}},
+{{- end}}
+}
+
+f.header = ProtoField.uint8("tello.header", "Header", base.HEX)
+f.size = ProtoField.uint16("tello.size", "Size", base.DEC)
+f.crc8 = ProtoField.uint8("tello.crc8", "CRC8", base.HEX)
+f.from_drone = ProtoField.bool("tello.from_drone", "From drone", 8, nil, 0x80)
+f.to_drone = ProtoField.bool("tello.to_drone", "To drone", 8, nil, 0x40)
+f.type = ProtoField.uint8("tello.type", "Packet type", base.DEC, packet_types, 0x38)
+f.subtype = ProtoField.uint8("tello.subtype", "Packet subtype", base.DEC, nil, 0x07)
+f.id = ProtoField.uint16("tello.id", "Message ID", base.HEX, message_names)
+f.seq = ProtoField.uint16("tello.seq", "Sequence", base.DEC)
+f.payload = ProtoField.bytes("tello.payload", "Payload")
+f.crc16 = ProtoField.uint16("tello.crc16", "CRC16", base.HEX)
+f.conn = ProtoField.string("tello.conn", "Connection")
+f.video_port = ProtoField.uint16("tello.video_port", "Video port", base.DEC)
+{{range .Layouts}}{{$layout := .Name}}{{range .Fields}}
+f.{{lower $layout}}_{{lower .Name}} = ProtoField.{{ctor .Kind}}("tello.{{lower $layout}}.{{lower .Name}}", {{quote .Name}})
+{{- end}}{{end}}
+
+-- each field is { ProtoField, kind, offset, size, first bit, bits, optional }
+local layouts = {
+{{- range .Layouts}}{{$layout := .Name}}
+	{{.Name}} = {
+	{{- range .Fields}}
+		{ f.{{lower $layout}}_{{lower .Name}}, "{{.Kind}}", {{.Offset}}, {{.Size}}, {{.BitStart}}, {{.Bits}}, {{bool .Optional}} },
+	{{- end}}
+	},
+{{- end}}
+}
+
+-- the request is the payload sent to the drone, the reply the payload sent by it
+local messages = {
+{{- range .Messages}}{{if or .Request .Reply}}
+	[{{hex .ID}}] = { request = {{if .Request}}layouts.{{.Request}}{{else}}nil{{end}}, reply = {{if .Reply}}layouts.{{.Reply}}{{else}}nil{{end}} },
+{{- end}}{{end}}
+}
+
+local function add_layout(tree, buf, layout)
+	for _, fd in ipairs(layout) do
+		local pf, kind, off, size, bitstart, bits, optional = unpack(fd)
+		if size == 0 then
+			if buf:len() > off then
+				tree:add(pf, buf(off))
+			end
+		elseif buf:len() < off + size then
+			if not optional then
+				tree:add_expert_info(PI_MALFORMED, PI_ERROR, "Payload too short")
+				return
+			end
+		elseif bits > 0 then
+			local v = buf(off, size):le_uint64():rshift(bitstart):band(UInt64(2 ^ bits - 1)):tonumber()
+			if kind == "bool" then
+				tree:add(pf, buf(off, size), v ~= 0)
+			else
+				tree:add(pf, buf(off, size), v)
+			end
+		elseif kind == "bool" then
+			tree:add(pf, buf(off, size), buf(off, size):uint() ~= 0)
+		else
+			tree:add_le(pf, buf(off, size))
+		end
+	end
+end
+
+function tello.dissector(buf, pinfo, tree)
+	if buf:len() >= 9 and (buf(0, 9):string() == "conn_req:" or buf(0, 9):string() == "conn_ack:") then
+		pinfo.cols.protocol = "Tello"
+		pinfo.cols.info = buf(0, 8):string()
+		local t = tree:add(tello, buf())
+		t:add(f.conn, buf(0, 8))
+		if buf:len() >= 11 then
+			t:add_le(f.video_port, buf(9, 2))
+		end
+		return buf:len()
+	end
+	if buf:len() < {{.MinPacketSize}} or buf(0, 1):uint() ~= {{.Header}} then
+		return 0
+	end
+	local size = math.floor(buf(1, 2):le_uint() / 8)
+	if size < {{.MinPacketSize}} or size > buf:len() then
+		return 0
+	end
+	pinfo.cols.protocol = "Tello"
+	local flags = buf(4, 1):uint()
+	local id = buf(5, 2):le_uint()
+	local name = message_names[id] or string.format("0x%04x", id)
+	local from_drone = flags >= 0x80
+	local to_drone = flags % 0x80 >= 0x40
+	if from_drone then
+		pinfo.cols.info = name .. " from drone"
+	elseif to_drone then
+		pinfo.cols.info = name .. " to drone"
+	else
+		pinfo.cols.info = name
+	end
+
+	local t = tree:add(tello, buf(0, size), "Tello " .. name)
+	t:add(f.header, buf(0, 1))
+	t:add(f.size, buf(1, 2), size)
+	t:add(f.crc8, buf(3, 1))
+	t:add(f.from_drone, buf(4, 1))
+	t:add(f.to_drone, buf(4, 1))
+	t:add(f.type, buf(4, 1))
+	t:add(f.subtype, buf(4, 1))
+	t:add_le(f.id, buf(5, 2))
+	t:add_le(f.seq, buf(7, 2))
+	if size > {{.MinPacketSize}} then
+		local pl = buf(9, size - {{.MinPacketSize}})
+		local pt = t:add(f.payload, pl)
+		local m = messages[id]
+		if m then
+			local layout = m.reply
+			if to_drone and not from_drone then
+				layout = m.request
+			elseif not to_drone and not from_drone and layout == nil then
+				layout = m.request
+			end
+			if layout then
+				add_layout(pt, pl:tvb(), layout)
+			end
+		end
+	end
+	t:add_le(f.crc16, buf(size - 2, 2))
+	return size
+end
+
+DissectorTable.get("udp.port"):add(8889, tello)
+`))
@@ -0,0 +1,101 @@
+// export_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// the checked-in files must match the Go definitions, run go generate if this fails
+func TestGeneratedFilesUpToDate(t *testing.T) {
+	for name, write := range map[string]func(*bytes.Buffer) error{
+		"protocol.json": func(b *bytes.Buffer) error { return WriteJSON(b) },
+		"tello.lua":     func(b *bytes.Buffer) error { return WriteLua(b) },
+	} {
+		var want bytes.Buffer
+		if err := write(&want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("%s is stale, run go generate in the protocol directory", name)
+		}
+	}
+}
+
+func TestJSONDescription(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	var d struct {
+		Messages []struct {
+			ID    uint16
+			Name  string
+			Reply string
+		}
+		Layouts map[string][]Field
+	}
+	if err := json.Unmarshal(b.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Messages) != len(Messages()) {
+		t.Errorf("Got %d messages, want %d", len(d.Messages), len(Messages()))
+	}
+	for _, m := range d.Messages {
+		if m.Reply != "" && d.Layouts[m.Reply] == nil {
+			t.Errorf("%s refers to missing layout %s", m.Name, m.Reply)
+		}
+	}
+	if fs := d.Layouts["Sticks"]; len(fs) != 9 || fs[1].Name != "Ry" || fs[1].BitStart != 11 || fs[1].Bits != 11 {
+		t.Errorf("Got Sticks layout %+v", fs)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	d := Describe()
+	for _, m := range d.Messages {
+		for _, layout := range []string{m.Request, m.Reply} {
+			if layout != "" && d.Layouts[layout] == nil {
+				t.Errorf("%s refers to missing layout %s", m.Name, layout)
+			}
+		}
+	}
+	var b bytes.Buffer
+	if err := WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Description
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Messages, d.Messages) || len(decoded.Layouts) != len(d.Layouts) {
+		t.Error("protocol.json does not round trip to Describe()")
+	}
+}
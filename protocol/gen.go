@@ -0,0 +1,49 @@
+// gen.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build ignore
+// +build ignore
+
+// gen writes the JSON description and Wireshark dissector, run it with go generate.
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+
+	"github.com/SMerrony/tello/protocol"
+)
+
+func main() {
+	for name, write := range map[string]func(*bytes.Buffer) error{
+		"protocol.json": func(b *bytes.Buffer) error { return protocol.WriteJSON(b) },
+		"tello.lua":     func(b *bytes.Buffer) error { return protocol.WriteLua(b) },
+	} {
+		var b bytes.Buffer
+		if err := write(&b); err != nil {
+			log.Fatalf("Generating %s - %v", name, err)
+		}
+		if err := os.WriteFile(name, b.Bytes(), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// layout.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package protocol
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Payload layouts are Go structs whose fields carry a `tello` tag giving the byte offset of the
+// field within the payload, optionally followed by
+//
+//	bits=S+N    the field is N bits starting at bit S of the little-endian value at the offset
+//	optional    older firmware may omit the field, it is left zero if the payload is too short
+//
+// Integer fields are little-endian, string and []byte fields run to the end of the payload.
+
+// Field describes one field of a payload layout.
+type Field struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`           // u8, i8, u16, i16, u32, i32, f32, bool, string or bytes
+	Offset   int    `json:"offset"`         // byte offset within the payload
+	Size     int    `json:"size,omitempty"` // bytes occupied, 0 for string and bytes which run to the end
+	BitStart int    `json:"bitStart,omitempty"`
+	Bits     int    `json:"bits,omitempty"` // non-zero for bit fields
+	Optional bool   `json:"optional,omitempty"`
+	Doc      string `json:"doc,omitempty"` // from the field's `doc` tag
+	index    int
+}
+
+var kindSizes = map[reflect.Kind]struct {
+	name string
+	size int
+}{
+	reflect.Uint8:   {"u8", 1},
+	reflect.Int8:    {"i8", 1},
+	reflect.Uint16:  {"u16", 2},
+	reflect.Int16:   {"i16", 2},
+	reflect.Uint32:  {"u32", 4},
+	reflect.Int32:   {"i32", 4},
+	reflect.Float32: {"f32", 4},
+	reflect.Bool:    {"bool", 1},
+	reflect.String:  {"string", 0},
+}
+
+var layouts sync.Map // reflect.Type -> []Field
+
+// Fields returns the fields of a payload layout, which may be a struct or a pointer to one.
+// It panics if the layout's tags are malformed, as that is a programming error.
+func Fields(layout interface{}) []Field {
+	t := reflect.TypeOf(layout)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if fs, ok := layouts.Load(t); ok {
+		return append([]Field(nil), fs.([]Field)...)
+	}
+	fs := parseLayout(t)
+	layouts.Store(t, fs)
+	return append([]Field(nil), fs...)
+}
+
+func parseLayout(t reflect.Type) []Field {
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("protocol: layout %s is not a struct", t))
+	}
+	var fs []Field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("tello")
+		if !ok {
+			continue
+		}
+		f := Field{Name: sf.Name, Doc: sf.Tag.Get("doc"), index: i}
+		if sf.Type.Kind() == reflect.Slice && sf.Type.Elem().Kind() == reflect.Uint8 {
+			f.Kind = "bytes"
+		} else if k, ok := kindSizes[sf.Type.Kind()]; ok {
+			f.Kind, f.Size = k.name, k.size
+		} else {
+			panic(fmt.Sprintf("protocol: %s.%s has unsupported type %s", t, sf.Name, sf.Type))
+		}
+		parts := strings.Split(tag, ",")
+		off, err := strconv.Atoi(parts[0])
+		if err != nil || off < 0 {
+			panic(fmt.Sprintf("protocol: %s.%s has bad offset %q", t, sf.Name, parts[0]))
+		}
+		f.Offset = off
+		for _, p := range parts[1:] {
+			switch {
+			case p == "optional":
+				f.Optional = true
+			case strings.HasPrefix(p, "bits="):
+				var start, n int
+				if _, err := fmt.Sscanf(p, "bits=%d+%d", &start, &n); err != nil || n < 1 || start+n > 64 ||
+					f.Kind == "string" || f.Kind == "bytes" || f.Kind == "f32" {
+					panic(fmt.Sprintf("protocol: %s.%s has bad bit field %q", t, sf.Name, p))
+				}
+				f.BitStart, f.Bits = start, n
+				f.Size = (start + n + 7) / 8
+			default:
+				panic(fmt.Sprintf("protocol: %s.%s has unknown tag option %q", t, sf.Name, p))
+			}
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// DecodePayload decodes payload according to layout, returning a value of the layout's struct
+// type.  If the payload is too short for a required field the fields before it are returned
+// along with an error.
+func DecodePayload(layout interface{}, payload []byte) (interface{}, error) {
+	t := reflect.TypeOf(layout)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	v := reflect.New(t).Elem()
+	for _, f := range Fields(layout) {
+		if len(payload) < f.Offset+f.Size || (f.Size == 0 && len(payload) < f.Offset) {
+			if f.Optional {
+				continue
+			}
+			return v.Interface(), &Error{Reason: fmt.Sprintf("payload too short for %s.%s", t.Name(), f.Name), Data: payload}
+		}
+		fv := v.Field(f.index)
+		switch f.Kind {
+		case "string":
+			fv.SetString(strings.TrimRight(string(payload[f.Offset:]), "\x00"))
+			continue
+		case "bytes":
+			fv.SetBytes(append([]byte(nil), payload[f.Offset:]...))
+			continue
+		}
+		var raw uint64
+		for i := f.Size - 1; i >= 0; i-- {
+			raw = raw<<8 | uint64(payload[f.Offset+i])
+		}
+		if f.Bits > 0 {
+			raw = raw >> uint(f.BitStart) & (1<<uint(f.Bits) - 1)
+		}
+		switch fv.Kind() {
+		case reflect.Bool:
+			fv.SetBool(raw != 0)
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+			fv.SetUint(raw)
+		case reflect.Int8, reflect.Int16, reflect.Int32:
+			bits := f.Bits
+			if bits == 0 {
+				bits = f.Size * 8
+			}
+			fv.SetInt(int64(raw<<uint(64-bits)) >> uint(64-bits))
+		case reflect.Float32:
+			fv.SetFloat(float64(math.Float32frombits(uint32(raw))))
+		}
+	}
+	return v.Interface(), nil
+}
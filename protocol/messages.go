@@ -0,0 +1,167 @@
+// messages.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package protocol
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Direction says which way a message travels.
+type Direction uint8
+
+// Message directions...
+const (
+	DirToDrone Direction = 1 << iota
+	DirFromDrone
+	DirBoth = DirToDrone | DirFromDrone
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirToDrone:
+		return "to drone"
+	case DirFromDrone:
+		return "from drone"
+	case DirBoth:
+		return "both"
+	}
+	return fmt.Sprintf("Direction(%d)", uint8(d))
+}
+
+// Message describes one message ID.
+type Message struct {
+	ID      uint16
+	Name    string
+	Type    uint8       // the packet type the client sends it with
+	Dir     Direction   // which way(s) it is sent, commands are sent to the drone and answered
+	Request interface{} // layout of the payload sent to the drone, nil if empty or unknown
+	Reply   interface{} // layout of the payload sent by the drone, nil if empty or unknown
+	Ack     bool        // the drone repeats it until the client answers with the same message ID
+	Doc     string
+}
+
+// The message table, names match the client's constants.
+var messages = []Message{
+	{ID: 0x0001, Name: "DoConnect", Type: TypeExtended, Dir: DirToDrone, Doc: "unused, connection is the text conn_req"},
+	{ID: 0x0002, Name: "Connected", Type: TypeExtended, Dir: DirFromDrone, Doc: "unused, connection is acknowledged by the text conn_ack"},
+	{ID: 0x0011, Name: "QuerySSID", Type: TypeGet, Dir: DirBoth, Reply: SSID{}},
+	{ID: 0x0012, Name: "SetSSID", Type: TypeSet, Dir: DirBoth, Reply: Result{}},
+	{ID: 0x0013, Name: "QuerySSIDPass", Type: TypeGet, Dir: DirBoth},
+	{ID: 0x0014, Name: "SetSSIDPass", Type: TypeSet, Dir: DirBoth, Reply: Result{}},
+	{ID: 0x0015, Name: "QueryWifiRegion", Type: TypeGet, Dir: DirBoth, Reply: WifiRegion{}},
+	{ID: 0x0016, Name: "SetWifiRegion", Type: TypeSet, Dir: DirBoth, Reply: Result{}},
+	{ID: 0x001a, Name: "WifiStrength", Type: TypeData1, Dir: DirFromDrone, Reply: WifiStrength{}},
+	{ID: 0x0020, Name: "SetVideoBitrate", Type: TypeSet, Dir: DirBoth, Request: VideoBitrate{}, Reply: Result{}},
+	{ID: 0x0021, Name: "SetDynAdjRate", Type: TypeSet, Dir: DirBoth, Reply: Result{}},
+	{ID: 0x0024, Name: "EisSetting", Type: TypeSet, Dir: DirBoth, Reply: Result{}},
+	{ID: 0x0025, Name: "QueryVideoSPSPPS", Type: TypeData2, Dir: DirToDrone, Doc: "the SPS and PPS arrive on the video stream"},
+	{ID: 0x0028, Name: "QueryVideoBitrate", Type: TypeGet, Dir: DirBoth, Reply: VideoBitrate{}},
+	{ID: 0x0030, Name: "DoTakePic", Type: TypeSet, Dir: DirBoth, Reply: TakePicture{}},
+	{ID: 0x0031, Name: "SwitchPicVideo", Type: TypeSet, Dir: DirBoth, Request: VideoMode{}, Reply: Result{}},
+	{ID: 0x0032, Name: "DoStartRec", Type: TypeSet, Dir: DirBoth},
+	{ID: 0x0034, Name: "ExposureVals", Type: TypeSet, Dir: DirBoth},
+	{ID: 0x0035, Name: "LightStrength", Type: TypeData1, Dir: DirFromDrone, Reply: LightStrength{}},
+	{ID: 0x0037, Name: "QueryJPEGQuality", Type: TypeGet, Dir: DirBoth},
+	{ID: 0x0043, Name: "Error1", Dir: DirFromDrone},
+	{ID: 0x0044, Name: "Error2", Dir: DirFromDrone},
+	{ID: 0x0045, Name: "QueryVersion", Type: TypeGet, Dir: DirBoth, Reply: Version{}},
+	{ID: 0x0046, Name: "SetDateTime", Type: TypeData1, Dir: DirBoth, Request: DateTime{}, Ack: true, Doc: "the drone asks, the client replies with the time"},
+	{ID: 0x0047, Name: "QueryActivationTime", Type: TypeGet, Dir: DirBoth, Reply: ActivationTime{}},
+	{ID: 0x0049, Name: "QueryLoaderVersion", Type: TypeGet, Dir: DirBoth},
+	{ID: 0x0050, Name: "SetStick", Type: TypeData2, Dir: DirToDrone, Request: Sticks{}},
+	{ID: 0x0054, Name: "DoTakeoff", Type: TypeSet, Dir: DirBoth, Reply: Result{}},
+	{ID: 0x0055, Name: "DoLand", Type: TypeSet, Dir: DirBoth, Request: Land{}, Reply: Result{}},
+	{ID: 0x0056, Name: "FlightStatus", Type: TypeData1, Dir: DirFromDrone, Reply: FlightStatus{}},
+	{ID: 0x0058, Name: "SetHeightLimit", Type: TypeSet, Dir: DirBoth, Reply: Result{}},
+	{ID: 0x005c, Name: "DoFlip", Type: TypeFlip, Dir: DirBoth, Request: Flip{}, Reply: Result{}},
+	{ID: 0x005d, Name: "DoThrowTakeoff", Type: TypeGet, Dir: DirBoth, Reply: Result{}},
+	{ID: 0x005e, Name: "DoPalmLand", Type: TypeSet, Dir: DirBoth, Request: Land{}, Reply: Result{}},
+	{ID: 0x0062, Name: "FileSize", Type: TypeData1, Dir: DirBoth, Reply: FileSize{}, Ack: true, Doc: "the client acknowledges with an empty payload"},
+	{ID: 0x0063, Name: "FileData", Type: TypeData1, Dir: DirBoth, Request: FileDataAck{}, Reply: FileData{}, Ack: true, Doc: "each complete piece of 8 chunks is acknowledged"},
+	{ID: 0x0064, Name: "FileDone", Type: TypeGet, Dir: DirBoth, Request: FileDone{}},
+	{ID: 0x0080, Name: "DoSmartVideo", Type: TypeSet, Dir: DirBoth, Request: SmartVideo{}, Reply: Result{}},
+	{ID: 0x0081, Name: "SmartVideoStatus", Type: TypeData1, Dir: DirFromDrone},
+	{ID: 0x1050, Name: "LogHeader", Type: TypeData1, Dir: DirBoth, Request: LogHeaderAck{}, Reply: LogHeader{}, Ack: true, Doc: "log data is only sent once this is acknowledged"},
+	{ID: 0x1051, Name: "LogData", Type: TypeData1, Dir: DirFromDrone, Reply: LogData{}},
+	{ID: 0x1052, Name: "LogConfig", Type: TypeData1, Dir: DirFromDrone},
+	{ID: 0x1053, Name: "DoBounce", Type: TypeSet, Dir: DirBoth, Request: Bounce{}, Reply: Result{}},
+	{ID: 0x1054, Name: "DoCalibration", Type: TypeSet, Dir: DirBoth, Request: Calibration{}, Reply: Result{}},
+	{ID: 0x1055, Name: "SetLowBattThresh", Type: TypeSet, Dir: DirBoth, Request: LowBatteryThreshold{}, Reply: Result{}},
+	{ID: 0x1056, Name: "QueryHeightLimit", Type: TypeGet, Dir: DirBoth, Reply: Limit{}},
+	{ID: 0x1057, Name: "QueryLowBattThresh", Type: TypeGet, Dir: DirBoth, Reply: Limit{}},
+	{ID: 0x1058, Name: "SetAttitude", Type: TypeSet, Dir: DirBoth},
+	{ID: 0x1059, Name: "QueryAttitude", Type: TypeGet, Dir: DirBoth},
+}
+
+var byID = func() map[uint16]*Message {
+	m := make(map[uint16]*Message, len(messages))
+	for i := range messages {
+		m[messages[i].ID] = &messages[i]
+	}
+	return m
+}()
+
+// Messages returns every known message, in ID order.
+func Messages() []Message {
+	ms := append([]Message(nil), messages...)
+	sort.Slice(ms, func(i, j int) bool { return ms[i].ID < ms[j].ID })
+	return ms
+}
+
+// Lookup returns the description of a message ID.
+func Lookup(id uint16) (Message, bool) {
+	m, ok := byID[id]
+	if !ok {
+		return Message{}, false
+	}
+	return *m, true
+}
+
+// Name returns the name of a message ID, or its hex value if it is not known.
+func Name(id uint16) string {
+	if m, ok := byID[id]; ok {
+		return m.Name
+	}
+	return fmt.Sprintf("0x%04x", id)
+}
+
+// Decode decodes a packet's payload according to its message's layout, choosing the request or
+// reply layout from the packet's direction flags.  It returns nil, nil if the message or its
+// layout is not known.
+func Decode(pkt Packet) (interface{}, error) {
+	m, ok := byID[pkt.MessageID]
+	if !ok {
+		return nil, nil
+	}
+	layout := m.Reply
+	switch {
+	case pkt.ToDrone && !pkt.FromDrone:
+		layout = m.Request
+	case !pkt.ToDrone && !pkt.FromDrone && layout == nil:
+		layout = m.Request
+	}
+	if layout == nil {
+		return nil, nil
+	}
+	return DecodePayload(layout, pkt.Payload)
+}
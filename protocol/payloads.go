@@ -0,0 +1,223 @@
+// payloads.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package protocol
+
+// Payload layouts for the messages whose format is known, see layout.go for the tag syntax.
+
+// Result is the reply to most commands, 0 means success.
+type Result struct {
+	Result uint8 `tello:"0"`
+}
+
+// WifiStrength is sent regularly by the Tello.
+type WifiStrength struct {
+	Strength     uint8 `tello:"0" doc:"signal strength, percent"`
+	Interference uint8 `tello:"1"`
+}
+
+// LightStrength is sent regularly by the Tello.
+type LightStrength struct {
+	Strength uint8 `tello:"0"`
+}
+
+// FlightStatus is the Tello's main telemetry message, sent about ten times per second.
+type FlightStatus struct {
+	Height                   int16 `tello:"0" doc:"decimetres"`
+	NorthSpeed               int16 `tello:"2"`
+	EastSpeed                int16 `tello:"4"`
+	VerticalSpeed            int16 `tello:"6"`
+	FlyTime                  int16 `tello:"8"`
+	ImuState                 bool  `tello:"10,bits=0+1"`
+	PressureState            bool  `tello:"10,bits=1+1"`
+	DownVisualState          bool  `tello:"10,bits=2+1"`
+	PowerState               bool  `tello:"10,bits=3+1"`
+	BatteryState             bool  `tello:"10,bits=4+1"`
+	GravityState             bool  `tello:"10,bits=5+1"`
+	WindState                bool  `tello:"10,bits=7+1"`
+	ImuCalibrationState      int8  `tello:"11"`
+	BatteryPercentage        int8  `tello:"12"`
+	DroneFlyTimeLeft         int16 `tello:"13"`
+	BatteryMilliVolts        int16 `tello:"15"`
+	Flying                   bool  `tello:"17,bits=0+1"`
+	OnGround                 bool  `tello:"17,bits=1+1"`
+	EmOpen                   bool  `tello:"17,bits=2+1"`
+	DroneHover               bool  `tello:"17,bits=3+1"`
+	OutageRecording          bool  `tello:"17,bits=4+1"`
+	BatteryLow               bool  `tello:"17,bits=5+1"`
+	BatteryCritical          bool  `tello:"17,bits=6+1"`
+	FactoryMode              bool  `tello:"17,bits=7+1"`
+	FlyMode                  uint8 `tello:"18"`
+	ThrowFlyTimer            int8  `tello:"19"`
+	CameraState              uint8 `tello:"20"`
+	ElectricalMachineryState uint8 `tello:"21"`
+	FrontIn                  bool  `tello:"22,bits=0+1"`
+	FrontOut                 bool  `tello:"22,bits=1+1"`
+	FrontLSC                 bool  `tello:"22,bits=2+1"`
+	TemperatureHigh          bool  `tello:"23,bits=0+1"`
+}
+
+// Sticks is the joystick message, each axis is 11 bits centred on 1024 with a range of ±660.
+type Sticks struct {
+	Rx     uint16 `tello:"0,bits=0+11" doc:"roll"`
+	Ry     uint16 `tello:"0,bits=11+11" doc:"pitch"`
+	Ly     uint16 `tello:"0,bits=22+11" doc:"throttle"`
+	Lx     uint16 `tello:"0,bits=33+11" doc:"yaw"`
+	Fast   bool   `tello:"0,bits=44+1" doc:"sports mode"`
+	Hour   uint8  `tello:"6"`
+	Minute uint8  `tello:"7"`
+	Second uint8  `tello:"8"`
+	Millis uint16 `tello:"9"`
+}
+
+// Version is the reply to a version query.
+type Version struct {
+	Result  uint8  `tello:"0"`
+	Version string `tello:"1"`
+}
+
+// SSID is the reply to an SSID query.
+type SSID struct {
+	Result uint8  `tello:"0"`
+	SSID   string `tello:"2"`
+}
+
+// Limit is the reply to a height limit or low battery threshold query.
+type Limit struct {
+	Result uint8 `tello:"0"`
+	Value  uint8 `tello:"1"`
+}
+
+// ActivationTime is the reply to an activation time query.
+type ActivationTime struct {
+	Result  uint8  `tello:"0"`
+	Seconds uint32 `tello:"1" doc:"seconds since the Unix epoch, 0 if never activated"`
+}
+
+// WifiRegion is the reply to a WiFi region query.
+type WifiRegion struct {
+	Result uint8  `tello:"0"`
+	Region string `tello:"1"`
+}
+
+// VideoBitrate sets, or is the reply to a query of, the video bit-rate.
+type VideoBitrate struct {
+	Bitrate uint8 `tello:"0" doc:"0 auto, 1-5 is 1-4Mbps"`
+}
+
+// VideoMode switches between normal and wide video modes.
+type VideoMode struct {
+	Mode uint8 `tello:"0" doc:"0 normal, 1 wide"`
+}
+
+// TakePicture is the reply to a take picture command.
+type TakePicture struct {
+	Result    uint8 `tello:"0"`
+	Remaining uint8 `tello:"1,optional" doc:"pictures remaining, meaning inferred"`
+}
+
+// FileSize announces a file, eg. a picture, which the Tello is about to send.
+type FileSize struct {
+	Type   uint8  `tello:"0" doc:"1 JPEG"`
+	Size   uint32 `tello:"1"`
+	FileID uint16 `tello:"5"`
+}
+
+// FileData is one chunk of a file sent by the Tello.
+type FileData struct {
+	FileID uint16 `tello:"0"`
+	Piece  uint32 `tello:"2"`
+	Chunk  uint32 `tello:"6"`
+	Length uint16 `tello:"10"`
+	Data   []byte `tello:"12"`
+}
+
+// FileDataAck acknowledges a complete piece of a file.
+type FileDataAck struct {
+	Done   uint8  `tello:"0"`
+	FileID uint16 `tello:"1"`
+	Piece  uint32 `tello:"3"`
+}
+
+// FileDone acknowledges a complete file.
+type FileDone struct {
+	FileID uint16 `tello:"0"`
+	Size   uint32 `tello:"2"`
+}
+
+// Land starts or stops landing.
+type Land struct {
+	Stop uint8 `tello:"0" doc:"1 cancels a landing in progress"`
+}
+
+// Flip performs a flip.
+type Flip struct {
+	Direction uint8 `tello:"0"`
+}
+
+// SmartVideo starts or stops a smart video manoeuvre.
+type SmartVideo struct {
+	Command uint8 `tello:"0" doc:"bit 0 starts, the remaining bits select the manoeuvre"`
+}
+
+// Bounce toggles bounce mode.
+type Bounce struct {
+	Mode uint8 `tello:"0" doc:"0x30 on, 0x31 off"`
+}
+
+// Calibration starts a calibration.
+type Calibration struct {
+	Type uint8 `tello:"0"`
+}
+
+// LowBatteryThreshold sets the low battery warning level.
+type LowBatteryThreshold struct {
+	Threshold uint8 `tello:"0" doc:"percent"`
+}
+
+// DateTime is sent in reply to the Tello's request for the date and time.
+type DateTime struct {
+	Result uint8  `tello:"0"`
+	Year   uint16 `tello:"1"`
+	Month  uint16 `tello:"3"`
+	Day    uint16 `tello:"5"`
+	Hour   uint16 `tello:"7"`
+	Minute uint16 `tello:"9"`
+	Second uint16 `tello:"11"`
+	Millis uint16 `tello:"13"`
+}
+
+// LogHeader starts the Tello's flight log.
+type LogHeader struct {
+	ID   uint16 `tello:"0"`
+	Data []byte `tello:"2"`
+}
+
+// LogHeaderAck acknowledges a LogHeader.
+type LogHeaderAck struct {
+	Result uint8  `tello:"0"`
+	ID     uint16 `tello:"1"`
+}
+
+// LogData carries flight log records.
+type LogData struct {
+	Data []byte `tello:"0"`
+}
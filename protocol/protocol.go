@@ -0,0 +1,183 @@
+// protocol.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+/*
+Package protocol describes the Tello's UDP control protocol; the packet framing, the message IDs
+and the layouts of the payloads which are known, so that tools may parse and pretty-print captured
+traffic without a drone.
+
+	pkt, err := protocol.Parse(buff)
+	if err != nil {
+		log.Fatal(err)
+	}
+	payload, err := protocol.Decode(pkt)
+	fmt.Printf("%s %+v\n", protocol.Name(pkt.MessageID), payload)
+
+The message table is also available from Describe(), a machine-readable description of the
+message IDs, directions and payload layouts built from the annotated layout types.  It is published
+as protocol.json, along with tello.lua, a Wireshark dissector; both are regenerated from the Go
+definitions by go generate and the tests fail if they are out of date.
+
+The package has its own copy of the codec rather than sharing the tello package's, so that a
+mistake in one is caught by the other rather than being faithfully reproduced by both.
+*/
+package protocol
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Header is the first byte of every packet.
+const Header = 0xcc
+
+// MinPacketSize is the size of a packet with no payload.
+const MinPacketSize = 11
+
+// MaxPacketSize is the largest packet the 13-bit size field can describe.
+const MaxPacketSize = 1<<13 - 1
+
+// Packet types, 3 and 7 are currently unknown.
+const (
+	TypeExtended = 0
+	TypeGet      = 1
+	TypeData1    = 2
+	TypeData2    = 4
+	TypeSet      = 5
+	TypeFlip     = 6
+)
+
+// TypeName returns a readable name for a packet type.
+func TypeName(t uint8) string {
+	switch t {
+	case TypeExtended:
+		return "Extended"
+	case TypeGet:
+		return "Get"
+	case TypeData1:
+		return "Data1"
+	case TypeData2:
+		return "Data2"
+	case TypeSet:
+		return "Set"
+	case TypeFlip:
+		return "Flip"
+	}
+	return fmt.Sprintf("Type%d", t)
+}
+
+// Packet is a single decoded protocol packet.
+type Packet struct {
+	Type      uint8 // 3-bit packet type
+	Subtype   uint8 // 3-bit packet subtype
+	FromDrone bool  // set on packets sent by the Tello
+	ToDrone   bool  // set on packets sent to the Tello
+	MessageID uint16
+	Sequence  uint16
+	Payload   []byte
+}
+
+// ErrBadPacket is matched by every *Error, test for it with errors.Is().
+var ErrBadPacket = errors.New("Bad packet")
+
+// Error reports a malformed packet.
+type Error struct {
+	Reason string // what is wrong with the packet
+	Data   []byte // the raw packet
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Bad packet, %s: % x", e.Reason, e.Data)
+}
+
+// Is makes an Error match ErrBadPacket.
+func (e *Error) Is(target error) bool { return target == ErrBadPacket }
+
+// Size returns the size of the packet at the start of buff, as given by its header, or 0 if
+// buff does not start with a packet header.
+func Size(buff []byte) int {
+	if len(buff) < 4 || buff[0] != Header || CRC8(buff[0:3]) != buff[3] {
+		return 0
+	}
+	return int(uint16(buff[1])|uint16(buff[2])<<8) >> 3
+}
+
+// Parse checks and decodes a raw packet, any bytes beyond the size given in its header are ignored.
+// The returned Payload is a copy.
+func Parse(buff []byte) (pkt Packet, err error) {
+	if len(buff) < MinPacketSize {
+		return pkt, &Error{Reason: "too short", Data: buff}
+	}
+	if buff[0] != Header {
+		return pkt, &Error{Reason: "bad header", Data: buff}
+	}
+	if CRC8(buff[0:3]) != buff[3] {
+		return pkt, &Error{Reason: "bad CRC8", Data: buff}
+	}
+	size := int(uint16(buff[1])|uint16(buff[2])<<8) >> 3
+	if size < MinPacketSize || size > len(buff) {
+		return pkt, &Error{Reason: "bad size", Data: buff}
+	}
+	if CRC16(buff[0:size-2]) != uint16(buff[size-1])<<8|uint16(buff[size-2]) {
+		return pkt, &Error{Reason: "bad CRC16", Data: buff}
+	}
+	pkt.FromDrone = buff[4]&0x80 != 0
+	pkt.ToDrone = buff[4]&0x40 != 0
+	pkt.Type = (buff[4] >> 3) & 0x07
+	pkt.Subtype = buff[4] & 0x07
+	pkt.MessageID = uint16(buff[5]) | uint16(buff[6])<<8
+	pkt.Sequence = uint16(buff[7]) | uint16(buff[8])<<8
+	if size > MinPacketSize {
+		pkt.Payload = append([]byte(nil), buff[9:size-2]...)
+	}
+	return pkt, nil
+}
+
+// Encode packs the packet into its raw form, calculating the size and CRCs.
+// The payload is truncated if it would not fit in MaxPacketSize.
+func Encode(pkt Packet) []byte {
+	payload := pkt.Payload
+	if len(payload) > MaxPacketSize-MinPacketSize {
+		payload = payload[:MaxPacketSize-MinPacketSize]
+	}
+	size := MinPacketSize + len(payload)
+	buff := make([]byte, size)
+	buff[0] = Header
+	buff[1] = byte(size << 3)
+	buff[2] = byte(size >> 5)
+	buff[3] = CRC8(buff[0:3])
+	buff[4] = pkt.Subtype&0x07 | (pkt.Type&0x07)<<3
+	if pkt.ToDrone {
+		buff[4] |= 0x40
+	}
+	if pkt.FromDrone {
+		buff[4] |= 0x80
+	}
+	buff[5] = byte(pkt.MessageID)
+	buff[6] = byte(pkt.MessageID >> 8)
+	buff[7] = byte(pkt.Sequence)
+	buff[8] = byte(pkt.Sequence >> 8)
+	copy(buff[9:], payload)
+	crc := CRC16(buff[0 : size-2])
+	buff[size-2] = byte(crc)
+	buff[size-1] = byte(crc >> 8)
+	return buff
+}
@@ -0,0 +1,156 @@
+// protocol_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeParse(t *testing.T) {
+	in := Packet{Type: TypeSet, Subtype: 3, ToDrone: true, MessageID: 0x1055, Sequence: 0x1234, Payload: []byte{25}}
+	buff := Encode(in)
+	if len(buff) != MinPacketSize+1 || Size(buff) != len(buff) {
+		t.Fatalf("Encoded size %d, header says %d", len(buff), Size(buff))
+	}
+	out, err := Parse(append(buff, 0xff)) // trailing junk is ignored
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Round trip got %+v, want %+v", out, in)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	good := Encode(Packet{Type: TypeGet, MessageID: 0x0045, Payload: []byte{1, 2}})
+	badCRC16 := append([]byte(nil), good...)
+	badCRC16[len(badCRC16)-1] ^= 1
+	badCRC8 := append([]byte(nil), good...)
+	badCRC8[3] ^= 1
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"too short", good[:5]},
+		{"bad header", append([]byte{0xcd}, good[1:]...)},
+		{"bad CRC8", badCRC8},
+		{"bad size", good[:len(good)-1]},
+		{"bad CRC16", badCRC16},
+	} {
+		_, err := Parse(tc.data)
+		var perr *Error
+		if !errors.As(err, &perr) || !errors.Is(err, ErrBadPacket) {
+			t.Errorf("%s: got %v", tc.name, err)
+			continue
+		}
+		if perr.Reason != tc.name {
+			t.Errorf("Got reason %q, want %q", perr.Reason, tc.name)
+		}
+	}
+}
+
+func TestDecodeFlightStatus(t *testing.T) {
+	pl := make([]byte, 24)
+	pl[0], pl[1] = 0xfe, 0xff // height -2
+	pl[10] = 0x81             // IMU and wind
+	pl[12] = 87
+	pl[17] = 0x21 // flying, battery low
+	pl[23] = 1
+	v, err := Decode(Packet{FromDrone: true, MessageID: 0x0056, Payload: pl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := v.(FlightStatus)
+	if fs.Height != -2 || !fs.ImuState || fs.PressureState || !fs.WindState || fs.BatteryPercentage != 87 ||
+		!fs.Flying || fs.OnGround || !fs.BatteryLow || !fs.TemperatureHigh {
+		t.Errorf("Decoded %+v", fs)
+	}
+}
+
+func TestDecodeSticks(t *testing.T) {
+	// rx 1024+660, ry 1024, ly 1024-660, lx 1024, fast
+	packed := uint64(1684) | uint64(1024)<<11 | uint64(364)<<22 | uint64(1024)<<33 | 1<<44
+	pl := make([]byte, 11)
+	for i := 0; i < 6; i++ {
+		pl[i] = byte(packed >> (8 * uint(i)))
+	}
+	pl[6], pl[9], pl[10] = 13, 0x34, 0x12
+	v, err := Decode(Packet{ToDrone: true, MessageID: 0x0050, Payload: pl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Sticks{Rx: 1684, Ry: 1024, Ly: 364, Lx: 1024, Fast: true, Hour: 13, Millis: 0x1234}
+	if v.(Sticks) != want {
+		t.Errorf("Got %+v, want %+v", v, want)
+	}
+}
+
+func TestDecodeDirection(t *testing.T) {
+	// FileData is an ack going to the drone and a chunk coming from it
+	ack, err := Decode(Packet{ToDrone: true, MessageID: 0x0063, Payload: []byte{1, 2, 0, 3, 0, 0, 0}})
+	if err != nil || ack.(FileDataAck) != (FileDataAck{Done: 1, FileID: 2, Piece: 3}) {
+		t.Errorf("Ack decoded as %+v, %v", ack, err)
+	}
+	chunk, err := Decode(Packet{FromDrone: true, MessageID: 0x0063,
+		Payload: []byte{2, 0, 3, 0, 0, 0, 4, 0, 0, 0, 2, 0, 0xff, 0xd8}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := chunk.(FileData); c.FileID != 2 || c.Piece != 3 || c.Chunk != 4 || c.Length != 2 || !bytes.Equal(c.Data, []byte{0xff, 0xd8}) {
+		t.Errorf("Chunk decoded as %+v", c)
+	}
+}
+
+func TestDecodeShortAndOptional(t *testing.T) {
+	v, err := Decode(Packet{FromDrone: true, MessageID: 0x0030, Payload: []byte{0}})
+	if err != nil || v.(TakePicture) != (TakePicture{}) {
+		t.Errorf("Optional field, got %+v, %v", v, err)
+	}
+	v, err = Decode(Packet{FromDrone: true, MessageID: 0x001a, Payload: []byte{90}})
+	if !errors.Is(err, ErrBadPacket) || v.(WifiStrength).Strength != 90 {
+		t.Errorf("Short payload, got %+v, %v", v, err)
+	}
+	if v, err := Decode(Packet{MessageID: 0x7777}); v != nil || err != nil {
+		t.Errorf("Unknown message, got %v, %v", v, err)
+	}
+}
+
+func TestMessageTable(t *testing.T) {
+	seen := map[string]bool{}
+	for _, m := range Messages() {
+		if seen[m.Name] {
+			t.Errorf("Duplicate name %s", m.Name)
+		}
+		seen[m.Name] = true
+		for _, layout := range []interface{}{m.Request, m.Reply} {
+			if layout != nil {
+				Fields(layout) // panics on a malformed tag
+			}
+		}
+	}
+	if Name(0x0056) != "FlightStatus" || Name(0xbeef) != "0xbeef" {
+		t.Errorf("Got names %s, %s", Name(0x0056), Name(0xbeef))
+	}
+}
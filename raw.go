@@ -0,0 +1,71 @@
+// raw.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"errors"
+)
+
+const maxRawPayload = 1<<13 - 1 - minPktSize // packet sizes are 13-bit
+
+// validRaw checks the arguments of the SendRaw... funcs.
+func validRaw(packetType uint8, payload []byte) error {
+	if packetType > 7 {
+		return errors.New("Packet type must be 0-7")
+	}
+	if len(payload) > maxRawPayload {
+		return errors.New("Payload too large")
+	}
+	return nil
+}
+
+// SendRawCommand sends a message with any ID, packet type (0-7) and payload through the established
+// connection, the sequence number and CRCs are filled in as usual.  This allows undocumented commands
+// to be tried, see HandleMessage() to receive any response.
+// The known packet types are 0 (extended), 1 (get), 2 and 4 (data), 5 (set) and 6 (flip).
+// N.B. There is no protection against commands which make the drone misbehave.
+func (tello *Tello) SendRawCommand(messageID MessageID, packetType uint8, payload []byte) error {
+	if err := validRaw(packetType, payload); err != nil {
+		return err
+	}
+	tello.ctrlMu.Lock()
+	defer tello.ctrlMu.Unlock()
+	if tello.ctrlState != connConnected {
+		return ErrNotConnected
+	}
+	tello.ctrlSeq++
+	pkt := newPacket(packetType, messageID, tello.ctrlSeq, len(payload))
+	copy(pkt.payload, payload)
+	tello.enqueue(packetToBuffer(pkt))
+	return nil
+}
+
+// SendRawCommandAndWait is as SendRawCommand() but waits for the drone to reply with a message with the
+// same ID and sequence number, as it does for most commands, resending as necessary.  The payload of the
+// reply is returned.
+func (tello *Tello) SendRawCommandAndWait(ctx context.Context, messageID MessageID, packetType uint8, payload []byte) ([]byte, error) {
+	if err := validRaw(packetType, payload); err != nil {
+		return nil, err
+	}
+	return tello.sendAndWait(ctx, packetType, messageID, payload)
+}
@@ -0,0 +1,80 @@
+// raw_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSendRawCommand(t *testing.T) {
+	drone := new(Tello)
+	if err := drone.SendRawCommand(0x1234, ptSet, nil); err != ErrNotConnected {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+	drone.ctrlState = connConnected
+	drone.sendQ.running = true // queue the packets without a writer
+	if err := drone.SendRawCommand(0x1234, 8, nil); err == nil {
+		t.Error("Expected an error for a bad packet type")
+	}
+	if err := drone.SendRawCommand(0x1234, ptSet, make([]byte, maxRawPayload+1)); err == nil {
+		t.Error("Expected an error for a large payload")
+	}
+	if err := drone.SendRawCommand(0x1234, 3, []byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := drone.SendRawCommand(0x1234, 3, make([]byte, maxRawPayload)); err != nil {
+		t.Fatal(err)
+	}
+	cmds := drone.sendQ.queues[prioCommand]
+	if len(cmds) != 2 {
+		t.Fatalf("Expected 2 packets, got %d", len(cmds))
+	}
+	pkt, err := parsePacket(cmds[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkt.messageID != 0x1234 || pkt.packetType != 3 || pkt.sequence != 1 || !bytes.Equal(pkt.payload, []byte{1, 2}) {
+		t.Errorf("Unexpected packet %+v", pkt)
+	}
+	if pkt, err = parsePacket(cmds[1]); err != nil || len(pkt.payload) != maxRawPayload {
+		t.Errorf("Expected a maximum size packet, got %v", err)
+	}
+}
+
+func TestSendRawCommandAndWait(t *testing.T) {
+	drone := ackingDrone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := drone.SendRawCommandAndWait(ctx, 0x4321, ptGet, []byte{9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reply, []byte{0}) {
+		t.Errorf("Unexpected reply % x", reply)
+	}
+	if _, err := drone.SendRawCommandAndWait(ctx, 0x4321, 9, nil); err == nil {
+		t.Error("Expected an error for a bad packet type")
+	}
+}
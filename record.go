@@ -0,0 +1,286 @@
+// record.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRecordingIdle     = 2 * time.Second
+	defaultRecordingMarkers  = time.Second
+	recordingHousekeeping    = 100 * time.Millisecond // how often idle segments and markers are checked
+	recordingPartialSuffix   = ".part"
+	recordingSegmentSuffix   = ".h264"
+	recordingIndexSuffix     = ".index.jsonl"
+	recordingRequestKeyframe = time.Second // how often we ask for a keyframe while waiting to start a segment
+)
+
+// RecordingConfig configures StartRecording().
+type RecordingConfig struct {
+	Dir            string        // the directory for the files, "" means the current directory
+	Prefix         string        // the start of each file name, default "tello-" and the drone's serial number, if known
+	MaxDuration    time.Duration // start a new segment when this long, 0 means no limit
+	MaxBytes       int64         // start a new segment when this big, 0 means no limit
+	IdleTimeout    time.Duration // finish the segment if the video stops for this long, default 2s
+	MarkerInterval time.Duration // how often telemetry is written to the index, default 1s, negative means never
+}
+
+// RecordingMarker is a line of a recording's index file, which is in JSON Lines format.
+type RecordingMarker struct {
+	Time       time.Time   `json:"time"`
+	Event      string      `json:"event"`   // "segment_start", "segment_end" or "telemetry"
+	Segment    string      `json:"segment"` // the base name of the segment file
+	Offset     int64       `json:"offset"`  // the size of the segment at this point
+	FlightData *FlightData `json:"flight_data,omitempty"`
+}
+
+// Recording saves the raw H.264 video to a series of segment files, each starting at a keyframe
+// so that it is playable on its own.  A segment is written with a .part suffix, which is removed
+// once it is complete, so a segment without one is never truncated.  Segments are finished and
+// a new one started when the configured limits are reached, when the video stops (eg. because
+// the drone's battery has died) and when the recording is stopped.
+// An index file alongside the segments records when each segment started and ended, and
+// periodic telemetry markers which locate the drone's flight data within the segments.
+type Recording struct {
+	tello  *Tello
+	cfg    RecordingConfig
+	base   string // the path and name shared by the index and segments
+	index  *os.File
+	stop   func()
+	done   chan struct{}
+	mu     sync.Mutex // protects the fields below
+	segs   []string
+	err    error
+	seq    int
+	seg    *os.File
+	segW   *bufio.Writer
+	segLen int64
+	segAt  time.Time
+}
+
+// StartRecording starts saving the video to files as configured, it stops when Stop() is called.
+// Video must be connected as usual for anything to be recorded.
+func (tello *Tello) StartRecording(cfg RecordingConfig) (*Recording, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = fileNamePrefix(tello.GetFlightData().SerialNumber, "-")
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaultRecordingIdle
+	}
+	if cfg.MarkerInterval == 0 {
+		cfg.MarkerInterval = defaultRecordingMarkers
+	}
+	r := &Recording{
+		tello: tello,
+		cfg:   cfg,
+		base:  filepath.Join(cfg.Dir, cfg.Prefix+"-"+tello.now().Format("20060102-150405")),
+		done:  make(chan struct{}),
+	}
+	var err error
+	if r.index, err = os.OpenFile(r.base+recordingIndexSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); err != nil {
+		return nil, err
+	}
+	frames, stop := tello.ListenStampedVideo()
+	r.stop = stop
+	go r.run(frames)
+	return r, nil
+}
+
+// Stop finishes the current segment and the index, it returns the first error encountered while recording.
+func (r *Recording) Stop() error {
+	r.stop()
+	<-r.done
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Segments returns the paths of the completed segments.
+func (r *Recording) Segments() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.segs...)
+}
+
+// IndexPath returns the path of the index file.
+func (r *Recording) IndexPath() string {
+	return r.base + recordingIndexSuffix
+}
+
+func (r *Recording) run(frames <-chan StampedFrame) {
+	defer close(r.done)
+	clock := r.tello.cfg.getClock()
+	housekeeping := clock.After(recordingHousekeeping)
+	var lastData, lastMarker, lastRequest time.Time
+	rotate := false // waiting for a keyframe to start a new segment
+	for {
+		select {
+		case sf, ok := <-frames:
+			if !ok {
+				r.finishSegment(r.tello.now())
+				r.setErr(r.index.Close())
+				return
+			}
+			lastData = sf.Received
+			data := sf.Data
+			if r.seg == nil || rotate {
+				at := keyframeStart(data)
+				if at < 0 {
+					if r.seg != nil {
+						r.write(data)
+					}
+					if sf.Received.Sub(lastRequest) >= recordingRequestKeyframe {
+						r.tello.GetVideoSpsPps()
+						lastRequest = sf.Received
+					}
+					continue
+				}
+				if r.seg != nil {
+					r.write(data[:at])
+				}
+				r.finishSegment(sf.Received)
+				r.startSegment(sf.Received)
+				rotate = false
+				data = data[at:]
+			}
+			r.write(data)
+			rotate = r.full(sf.Received)
+			if cfg := r.cfg; cfg.MarkerInterval > 0 && sf.Received.Sub(lastMarker) >= cfg.MarkerInterval {
+				fd := sf.FlightData
+				r.mark("telemetry", sf.Received, &fd)
+				lastMarker = sf.Received
+			}
+		case <-housekeeping:
+			housekeeping = clock.After(recordingHousekeeping)
+			if r.seg != nil && r.tello.now().Sub(lastData) >= r.cfg.IdleTimeout {
+				r.finishSegment(lastData)
+				rotate = false
+			}
+		}
+	}
+}
+
+// full tests whether the current segment has reached a configured limit.
+func (r *Recording) full(now time.Time) bool {
+	return (r.cfg.MaxBytes > 0 && r.segLen >= r.cfg.MaxBytes) ||
+		(r.cfg.MaxDuration > 0 && now.Sub(r.segAt) >= r.cfg.MaxDuration)
+}
+
+func (r *Recording) startSegment(now time.Time) {
+	r.mu.Lock()
+	r.seq++
+	name := fmt.Sprintf("%s-%03d%s", r.base, r.seq, recordingSegmentSuffix)
+	r.mu.Unlock()
+	f, err := os.Create(name + recordingPartialSuffix)
+	if err != nil {
+		r.setErr(err)
+		return
+	}
+	r.seg, r.segW, r.segLen, r.segAt = f, bufio.NewWriter(f), 0, now
+	r.mark("segment_start", now, nil)
+}
+
+// finishSegment flushes and closes the current segment, if any, and gives it its final name.
+func (r *Recording) finishSegment(now time.Time) {
+	if r.seg == nil {
+		return
+	}
+	r.mark("segment_end", now, nil)
+	part := r.seg.Name()
+	err := r.segW.Flush()
+	if err == nil {
+		err = r.seg.Sync()
+	}
+	if cerr := r.seg.Close(); err == nil {
+		err = cerr
+	}
+	r.seg, r.segW = nil, nil
+	if err == nil {
+		name := part[:len(part)-len(recordingPartialSuffix)]
+		if err = os.Rename(part, name); err == nil {
+			r.mu.Lock()
+			r.segs = append(r.segs, name)
+			r.mu.Unlock()
+		}
+	}
+	r.setErr(err)
+}
+
+func (r *Recording) write(data []byte) {
+	if r.seg == nil {
+		return
+	}
+	n, err := r.segW.Write(data)
+	r.segLen += int64(n)
+	r.setErr(err)
+}
+
+// mark writes a line to the index.
+func (r *Recording) mark(event string, now time.Time, fd *FlightData) {
+	if r.seg == nil {
+		return
+	}
+	line, err := json.Marshal(RecordingMarker{
+		Time:       now,
+		Event:      event,
+		Segment:    filepath.Base(r.seg.Name()[:len(r.seg.Name())-len(recordingPartialSuffix)]),
+		Offset:     r.segLen,
+		FlightData: fd,
+	})
+	if err == nil {
+		_, err = r.index.Write(append(line, '\n'))
+	}
+	r.setErr(err)
+}
+
+// setErr records the first error.
+func (r *Recording) setErr(err error) {
+	if err == nil {
+		return
+	}
+	r.mu.Lock()
+	if r.err == nil {
+		r.err = err
+		r.tello.logf("Recording error - %v\n", err)
+	}
+	r.mu.Unlock()
+}
+
+// keyframeStart returns the offset of the start code of the first SPS in data, or -1 if there is none.
+func keyframeStart(data []byte) int {
+	for i := 0; i+3 < len(data); i++ {
+		if data[i] == 0 && data[i+1] == 0 && data[i+2] == 1 && data[i+3]&0x1f == nalSPS {
+			if i > 0 && data[i-1] == 0 {
+				return i - 1 // 4-byte start code
+			}
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,208 @@
+// record_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecordingRotation(t *testing.T) {
+	drone := NewTello()
+	drone.sendQ.running = true // queue the keyframe requests without a connection
+	dir := t.TempDir()
+	rec, err := drone.StartRecording(RecordingConfig{Dir: dir, MaxBytes: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	drone.stampVideo(0, 0x80, []byte{0, 0, 0, 1, 0x41, 1})             // before any keyframe, dropped
+	drone.stampVideo(1, 0x80, []byte{0, 0, 0, 1, 0x67, 0, 0, 1, 0x65}) // starts segment 1
+	drone.stampVideo(2, 0x80, []byte{0, 0, 0, 1, 0x41, 2})             // segment 1 is now full
+	drone.stampVideo(3, 0x80, []byte{0, 0, 0, 1, 0x41, 3})             // no keyframe yet, so still segment 1
+	drone.stampVideo(4, 0x80, []byte{0xaa, 0, 0, 0, 1, 0x67, 4})       // the tail goes to segment 1, segment 2 starts at the SPS
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	segs := rec.Segments()
+	if len(segs) != 2 {
+		t.Fatalf("Expected 2 segments, got %v", segs)
+	}
+	want := [][]byte{
+		{0, 0, 0, 1, 0x67, 0, 0, 1, 0x65, 0, 0, 0, 1, 0x41, 2, 0, 0, 0, 1, 0x41, 3, 0xaa},
+		{0, 0, 0, 1, 0x67, 4},
+	}
+	for i, seg := range segs {
+		got, err := os.ReadFile(seg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want[i]) {
+			t.Errorf("Segment %d: expected % x, got % x", i+1, want[i], got)
+		}
+	}
+	if parts, _ := filepath.Glob(filepath.Join(dir, "*"+recordingPartialSuffix)); len(parts) != 0 {
+		t.Errorf("Expected no partial segments, got %v", parts)
+	}
+
+	f, err := os.Open(rec.IndexPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []string
+	telemetry := 0
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var m RecordingMarker
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Event == "telemetry" {
+			if m.FlightData == nil {
+				t.Error("Expected a telemetry marker to carry flight data")
+			}
+			telemetry++
+			continue
+		}
+		events = append(events, m.Event+" "+m.Segment)
+	}
+	s1, s2 := filepath.Base(segs[0]), filepath.Base(segs[1])
+	wantEvents := []string{"segment_start " + s1, "segment_end " + s1, "segment_start " + s2, "segment_end " + s2}
+	if len(events) != len(wantEvents) {
+		t.Fatalf("Expected events %v, got %v", wantEvents, events)
+	}
+	for i := range events {
+		if events[i] != wantEvents[i] {
+			t.Errorf("Expected events %v, got %v", wantEvents, events)
+			break
+		}
+	}
+	if telemetry == 0 {
+		t.Error("Expected a telemetry marker")
+	}
+}
+
+func TestRecordingIdle(t *testing.T) {
+	drone := NewTello()
+	drone.sendQ.running = true
+	rec, err := drone.StartRecording(RecordingConfig{Dir: t.TempDir(), IdleTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	drone.stampVideo(0, 0x80, []byte{0, 0, 0, 1, 0x67, 1})
+	// the video stops, as it would when the battery dies, so the segment should be finished
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.Segments()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	segs := rec.Segments()
+	if len(segs) != 1 {
+		t.Fatalf("Expected the idle segment to be finished, got %v", segs)
+	}
+	if _, err := os.Stat(segs[0]); err != nil {
+		t.Error(err)
+	}
+}
+
+// manualClock is a Clock whose timers only fire when fire() is called.
+type manualClock struct {
+	mu     sync.Mutex
+	t      time.Time
+	timers []chan time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, ch)
+	return ch
+}
+
+// fire advances the clock by d and fires every pending timer.
+func (c *manualClock) fire(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+	for _, ch := range c.timers {
+		ch <- c.t
+	}
+	c.timers = nil
+}
+
+func TestRecordingIdleClock(t *testing.T) {
+	clock := &manualClock{t: time.Unix(1600000000, 0)}
+	drone := NewTello(WithClock(clock))
+	drone.sendQ.running = true
+	rec, err := drone.StartRecording(RecordingConfig{Dir: t.TempDir(), IdleTimeout: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	drone.stampVideo(0, 0x80, []byte{0, 0, 0, 1, 0x67, 1})
+	deadline := time.Now().Add(5 * time.Second)
+	for pending := 0; pending == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the housekeeping to be timed by the drone's Clock")
+		}
+		clock.mu.Lock()
+		pending = len(clock.timers)
+		clock.mu.Unlock()
+	}
+	// an hour passes on the drone's clock in no time at all
+	for len(rec.Segments()) == 0 && time.Now().Before(deadline) {
+		clock.fire(time.Hour)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if segs := rec.Segments(); len(segs) != 1 {
+		t.Fatalf("Expected the idle segment to be finished by the clock, got %v", segs)
+	}
+}
+
+func TestKeyframeStart(t *testing.T) {
+	for _, tc := range []struct {
+		data []byte
+		want int
+	}{
+		{[]byte{0, 0, 0, 1, 0x67}, 0},
+		{[]byte{9, 0, 0, 1, 0x67}, 1},
+		{[]byte{0, 0, 1, 0x41, 0, 0, 1, 0x67}, 4},
+		{[]byte{0, 0, 1, 0x41, 0, 0, 1}, -1},
+	} {
+		if got := keyframeStart(tc.data); got != tc.want {
+			t.Errorf("keyframeStart(% x) = %d, want %d", tc.data, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// recover.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"fmt"
+	"net"
+	"runtime/debug"
+)
+
+// ListenerPanic describes a panic recovered in one of the Goroutines listening to the Tello.
+// It is the Data of an EvListenerPanic event.
+type ListenerPanic struct {
+	Listener string      // "control" or "video"
+	Value    interface{} // the value passed to panic()
+	Stack    []byte      // the stack of the panicking Goroutine
+}
+
+func (lp *ListenerPanic) Error() string {
+	return fmt.Sprintf("Panic in Tello %s listener: %v", lp.Listener, lp.Value)
+}
+
+// recoverListener must be deferred directly by a listener Goroutine.  A panic is logged,
+// reported as an EvListenerPanic event and stop is then called in a new Goroutine to tidy
+// up the connection, so a bad packet cannot bring down the host application.
+func (tello *Tello) recoverListener(listener string, stop func()) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	lp := &ListenerPanic{Listener: listener, Value: r, Stack: debug.Stack()}
+	tello.logf("Error: %v\n%s", lp, lp.Stack)
+	tello.emitEvent(EvListenerPanic, lp)
+	go stop() // the listener must exit before the connection can be closed
+}
+
+// disconnectControl disconnects if conn is still the current control connection.
+func (tello *Tello) disconnectControl(conn net.Conn) {
+	tello.ctrlMu.RLock()
+	current := tello.ctrlConn == conn
+	tello.ctrlMu.RUnlock()
+	if current {
+		tello.ControlDisconnect()
+	}
+}
+
+// disconnectSession disconnects if done still belongs to the current control connection,
+// so that a lost connection is torn down exactly as ControlDisconnect() would.
+// The EvDisconnected event reports that contact was lost.
+func (tello *Tello) disconnectSession(done chan struct{}) {
+	tello.ctrlMu.Lock()
+	current := tello.ctrlDone == done
+	if current {
+		tello.ctrlLostContact = true
+	}
+	tello.ctrlMu.Unlock()
+	if current {
+		tello.ControlDisconnect()
+	}
+}
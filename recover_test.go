@@ -0,0 +1,58 @@
+// recover_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestListenerPanic(t *testing.T) {
+	drone, fake := pushingDrone(t, WithLogger(log.New(io.Discard, "", 0)))
+	events, stop := drone.ListenEvents()
+	defer stop()
+
+	// a bitrate reply with no payload makes the listener index out of range
+	fake.pushSeq(MsgQueryVideoBitrate, 0)
+
+	select {
+	case ev := <-events:
+		lp, ok := ev.Data.(*ListenerPanic)
+		if ev.Type != EvListenerPanic || !ok || lp.Listener != "control" {
+			t.Fatalf("Expected a control listener panic event, got %+v", ev)
+		}
+		if len(lp.Stack) == 0 {
+			t.Error("Expected the stack to be recorded")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the panic event")
+	}
+	deadline := time.Now().Add(time.Second)
+	for drone.ControlConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected to be disconnected after the panic")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,494 @@
+// relay.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// The relay protocol carries the packets of several UDP connections over one stream connection, each
+// frame being a header followed by a payload of up to 64KiB.  The header holds the frame kind, the
+// stream the frame belongs to, a per-stream sequence number for data frames, and the payload length.
+const (
+	relayOpenControl byte = iota + 1 // client to relay: dial the drone, payload is dronePort, localPort and droneAddr
+	relayOpenListen                  // client to relay: listen for packets, payload is localPort
+	relayOpened                      // relay to client: the stream is open
+	relayData                        // either way: one packet
+	relayClose                       // either way: the stream is closed, payload may be an error message
+	relayKeepAlive                   // either way: sent periodically so that a dead link is noticed
+)
+
+const (
+	relayHeaderSize      = 9
+	relayMaxPayload      = 1<<16 - 1
+	relayKeepAlivePeriod = time.Second
+	relayTimeout         = 3 * time.Second // the link is dead if nothing arrives for this long
+	relayReorderWindow   = 32              // out-of-order packets held per stream before we give up on a gap
+	relayStreamBufSize   = 256             // packets queued per stream before they are dropped
+)
+
+// ErrRelayClosed is returned when the relay link has been closed or has failed.
+var ErrRelayClosed = errors.New("Relay link closed")
+
+// relayLink sends and receives relay frames on a stream connection.
+type relayLink struct {
+	conn      net.Conn
+	wmu       sync.Mutex        // protects seq and serialises writes
+	seq       map[uint16]uint32 // the next sequence number to send on each stream
+	done      chan struct{}     // closed when the link is closed
+	closeOnce sync.Once
+}
+
+func newRelayLink(conn net.Conn) *relayLink {
+	rl := &relayLink{conn: conn, seq: map[uint16]uint32{}, done: make(chan struct{})}
+	go rl.keepAlive()
+	return rl
+}
+
+// send writes one frame.
+func (rl *relayLink) send(kind byte, stream uint16, payload []byte) error {
+	if len(payload) > relayMaxPayload {
+		return errors.New("Relay packet too large")
+	}
+	frame := make([]byte, relayHeaderSize+len(payload))
+	frame[0] = kind
+	binary.BigEndian.PutUint16(frame[1:], stream)
+	binary.BigEndian.PutUint16(frame[7:], uint16(len(payload)))
+	copy(frame[relayHeaderSize:], payload)
+	rl.wmu.Lock()
+	defer rl.wmu.Unlock()
+	if kind == relayData {
+		binary.BigEndian.PutUint32(frame[3:], rl.seq[stream])
+		rl.seq[stream]++
+	}
+	_, err := rl.conn.Write(frame)
+	return err
+}
+
+// receive reads the next frame, failing if nothing at all has arrived within relayTimeout.
+func (rl *relayLink) receive() (kind byte, stream uint16, seq uint32, payload []byte, err error) {
+	var hdr [relayHeaderSize]byte
+	rl.conn.SetReadDeadline(time.Now().Add(relayTimeout))
+	if _, err = io.ReadFull(rl.conn, hdr[:]); err != nil {
+		return
+	}
+	kind = hdr[0]
+	stream = binary.BigEndian.Uint16(hdr[1:])
+	seq = binary.BigEndian.Uint32(hdr[3:])
+	payload = make([]byte, binary.BigEndian.Uint16(hdr[7:]))
+	_, err = io.ReadFull(rl.conn, payload)
+	return
+}
+
+func (rl *relayLink) keepAlive() {
+	for {
+		select {
+		case <-rl.done:
+			return
+		case <-time.After(relayKeepAlivePeriod):
+			rl.send(relayKeepAlive, 0, nil)
+		}
+	}
+}
+
+func (rl *relayLink) close() {
+	rl.closeOnce.Do(func() {
+		close(rl.done)
+		rl.conn.Close()
+	})
+}
+
+// relayReorder delivers a stream's packets in sequence order, dropping duplicates and packets which
+// arrive too late.  A gap is skipped once relayReorderWindow later packets are waiting.
+type relayReorder struct {
+	next    uint32
+	started bool
+	held    map[uint32][]byte
+}
+
+func (ro *relayReorder) add(seq uint32, p []byte, deliver func([]byte)) {
+	if !ro.started {
+		ro.next, ro.started = seq, true
+	}
+	if int32(seq-ro.next) < 0 {
+		return // a duplicate, or too late
+	}
+	if ro.held == nil {
+		ro.held = map[uint32][]byte{}
+	}
+	ro.held[seq] = p
+	if len(ro.held) > relayReorderWindow {
+		first := true
+		for s := range ro.held {
+			if first || int32(s-ro.next) < 0 {
+				ro.next, first = s, false
+			}
+		}
+	}
+	for {
+		q, ok := ro.held[ro.next]
+		if !ok {
+			return
+		}
+		delete(ro.held, ro.next)
+		ro.next++
+		deliver(q)
+	}
+}
+
+// relayAddr is the net.Addr of a relayed connection.
+type relayAddr string
+
+func (ra relayAddr) Network() string { return "relay" }
+func (ra relayAddr) String() string  { return string(ra) }
+
+// relayConn is the client's end of a relayed UDP connection, it is packet-oriented as required by Transport.
+type relayConn struct {
+	rt     *RelayTransport
+	id     uint16
+	remote relayAddr
+	in     chan []byte
+	order  relayReorder // only used by the receiving Goroutine
+	done   chan struct{}
+	once   sync.Once
+	mu     sync.Mutex // protects deadline
+	dl     time.Time
+}
+
+// Read returns the next packet, or os.ErrDeadlineExceeded if the read deadline passes first.
+func (rc *relayConn) Read(b []byte) (int, error) {
+	rc.mu.Lock()
+	dl := rc.dl
+	rc.mu.Unlock()
+	var timeout <-chan time.Time
+	if !dl.IsZero() {
+		d := time.Until(dl)
+		if d <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case p := <-rc.in:
+		return copy(b, p), nil
+	case <-rc.done:
+		return 0, net.ErrClosed
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+// Write sends b as one packet.
+func (rc *relayConn) Write(b []byte) (int, error) {
+	select {
+	case <-rc.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	if err := rc.rt.link.send(relayData, rc.id, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close closes the connection at both ends of the relay.
+func (rc *relayConn) Close() error {
+	if rc.shut() {
+		rc.rt.link.send(relayClose, rc.id, nil)
+	}
+	return nil
+}
+
+// shut marks the connection as closed, it returns false if it already was.
+func (rc *relayConn) shut() (first bool) {
+	rc.once.Do(func() {
+		first = true
+		close(rc.done)
+		rc.rt.mu.Lock()
+		delete(rc.rt.conns, rc.id)
+		rc.rt.mu.Unlock()
+	})
+	return first
+}
+
+func (rc *relayConn) LocalAddr() net.Addr  { return relayAddr(rc.rt.link.conn.LocalAddr().String()) }
+func (rc *relayConn) RemoteAddr() net.Addr { return rc.remote }
+
+func (rc *relayConn) SetDeadline(t time.Time) error { return rc.SetReadDeadline(t) }
+
+func (rc *relayConn) SetReadDeadline(t time.Time) error {
+	rc.mu.Lock()
+	rc.dl = t
+	rc.mu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline does nothing, writes are queued by the relay link.
+func (rc *relayConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// RelayTransport is a Transport which reaches the drone via a relay, see ServeRelay(), on the far end
+// of a single stream connection such as TCP.  This lets the drone be piloted across networks, eg. with
+// the relay on a Raspberry Pi near the drone and the operator elsewhere.
+// Packets are delivered in order and duplicates are dropped, and keepalives are exchanged so that a dead
+// link is noticed within a few seconds, whereupon every relayed connection is closed.
+type RelayTransport struct {
+	link    *relayLink
+	mu      sync.Mutex // protects the following
+	nextID  uint16
+	conns   map[uint16]*relayConn
+	pending map[uint16]chan error // streams waiting to be opened
+	err     error                 // why the link failed
+}
+
+// NewRelayTransport returns a RelayTransport using conn, which must be a reliable stream connection to a
+// relay, eg. a TCP connection or a QUIC stream.  conn may have been dialled or accepted, so the relay can
+// connect out to the operator's machine if that is easier.
+func NewRelayTransport(conn net.Conn) *RelayTransport {
+	rt := &RelayTransport{
+		link:    newRelayLink(conn),
+		conns:   map[uint16]*relayConn{},
+		pending: map[uint16]chan error{},
+	}
+	go rt.receiver()
+	return rt
+}
+
+// DialRelay connects to a relay at the TCP address addr and returns a RelayTransport using it.
+func DialRelay(addr string) (*RelayTransport, error) {
+	conn, err := net.DialTimeout("tcp", addr, relayTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewRelayTransport(conn), nil
+}
+
+// DialControl asks the relay to open the control connection to the drone.
+func (rt *RelayTransport) DialControl(droneAddr string, dronePort, localPort int) (net.Conn, error) {
+	payload := make([]byte, 4, 4+len(droneAddr))
+	binary.BigEndian.PutUint16(payload, uint16(dronePort))
+	binary.BigEndian.PutUint16(payload[2:], uint16(localPort))
+	return rt.open(relayOpenControl, append(payload, droneAddr...), droneAddr)
+}
+
+// ListenPackets asks the relay to listen on localPort and forward the packets it receives.
+func (rt *RelayTransport) ListenPackets(localPort int) (net.Conn, error) {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(localPort))
+	return rt.open(relayOpenListen, payload, "")
+}
+
+// Close closes the link to the relay, and so every relayed connection.
+func (rt *RelayTransport) Close() error {
+	rt.link.close()
+	return nil
+}
+
+// Done returns a channel which is closed when the link to the relay has closed or failed.
+func (rt *RelayTransport) Done() <-chan struct{} {
+	return rt.link.done
+}
+
+// Err returns why the link to the relay failed, if it has.
+func (rt *RelayTransport) Err() error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.err
+}
+
+// open asks the relay to open a stream and waits for its answer.
+func (rt *RelayTransport) open(kind byte, payload []byte, remote string) (net.Conn, error) {
+	reply := make(chan error, 1)
+	rt.mu.Lock()
+	if rt.err != nil {
+		rt.mu.Unlock()
+		return nil, ErrRelayClosed
+	}
+	rt.nextID++
+	rc := &relayConn{
+		rt:     rt,
+		id:     rt.nextID,
+		remote: relayAddr(remote),
+		in:     make(chan []byte, relayStreamBufSize),
+		done:   make(chan struct{}),
+	}
+	rt.conns[rc.id] = rc
+	rt.pending[rc.id] = reply
+	rt.mu.Unlock()
+	err := rt.link.send(kind, rc.id, payload)
+	if err == nil {
+		select {
+		case err = <-reply:
+		case <-rt.link.done:
+			err = ErrRelayClosed
+		case <-time.After(relayTimeout):
+			err = &TimeoutError{Op: "relay to open connection"}
+		}
+	}
+	rt.mu.Lock()
+	delete(rt.pending, rc.id)
+	rt.mu.Unlock()
+	if err != nil {
+		rc.shut()
+		return nil, err
+	}
+	return rc, nil
+}
+
+// receiver handles frames from the relay until the link fails, then closes every connection.
+func (rt *RelayTransport) receiver() {
+	for {
+		kind, id, seq, payload, err := rt.link.receive()
+		if err != nil {
+			rt.mu.Lock()
+			if rt.err == nil {
+				rt.err = err
+			}
+			conns := make([]*relayConn, 0, len(rt.conns))
+			for _, rc := range rt.conns {
+				conns = append(conns, rc)
+			}
+			rt.mu.Unlock()
+			for _, rc := range conns {
+				rc.shut()
+			}
+			rt.link.close()
+			return
+		}
+		rt.mu.Lock()
+		rc := rt.conns[id]
+		reply := rt.pending[id]
+		rt.mu.Unlock()
+		switch {
+		case kind == relayOpened && reply != nil:
+			reply <- nil
+		case kind == relayClose && reply != nil:
+			reply <- errors.New(string(payload))
+		case kind == relayClose && rc != nil:
+			rc.shut()
+		case kind == relayData && rc != nil:
+			rc.order.add(seq, payload, func(p []byte) {
+				select {
+				case rc.in <- p:
+				default: // dropped, as UDP would
+				}
+			})
+		}
+	}
+}
+
+// ServeRelay relays the connections requested by a RelayTransport on the far end of conn, opening them
+// with transport, which is normally UDPTransport{}.  It returns when conn fails or is closed, having
+// closed every connection it opened.
+func ServeRelay(conn net.Conn, transport Transport) error {
+	link := newRelayLink(conn)
+	defer link.close()
+	var (
+		mu    sync.Mutex // protects conns, as the pumps remove themselves
+		conns = map[uint16]net.Conn{}
+		order = map[uint16]*relayReorder{}
+	)
+	defer func() {
+		mu.Lock()
+		for _, c := range conns {
+			c.Close()
+		}
+		mu.Unlock()
+	}()
+	pump := func(id uint16, c net.Conn) {
+		buff := make([]byte, 4096)
+		for {
+			n, err := c.Read(buff)
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) || err == io.EOF || err == io.ErrClosedPipe {
+					mu.Lock()
+					if conns[id] == c {
+						delete(conns, id)
+						link.send(relayClose, id, nil)
+					}
+					mu.Unlock()
+					return
+				}
+				continue // eg. ICMP port unreachable before the drone is up
+			}
+			link.send(relayData, id, buff[:n])
+		}
+	}
+	for {
+		kind, id, seq, payload, err := link.receive()
+		if err == io.EOF {
+			return nil // the client has gone
+		}
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case relayOpenControl, relayOpenListen:
+			var c net.Conn
+			switch {
+			case kind == relayOpenControl && len(payload) >= 4:
+				c, err = transport.DialControl(string(payload[4:]),
+					int(binary.BigEndian.Uint16(payload)), int(binary.BigEndian.Uint16(payload[2:])))
+			case kind == relayOpenListen && len(payload) == 2:
+				c, err = transport.ListenPackets(int(binary.BigEndian.Uint16(payload)))
+			default:
+				err = errors.New("Malformed relay request")
+			}
+			if err != nil {
+				link.send(relayClose, id, []byte(err.Error()))
+				continue
+			}
+			mu.Lock()
+			if old := conns[id]; old != nil {
+				old.Close()
+			}
+			conns[id] = c
+			order[id] = &relayReorder{}
+			mu.Unlock()
+			link.send(relayOpened, id, nil)
+			go pump(id, c)
+		case relayData:
+			mu.Lock()
+			c, ro := conns[id], order[id]
+			mu.Unlock()
+			if c != nil {
+				ro.add(seq, payload, func(p []byte) { c.Write(p) })
+			}
+		case relayClose:
+			mu.Lock()
+			c := conns[id]
+			delete(conns, id)
+			delete(order, id)
+			mu.Unlock()
+			if c != nil {
+				c.Close()
+			}
+		}
+	}
+}
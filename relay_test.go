@@ -0,0 +1,164 @@
+// relay_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// relayDrone is the Transport used by the relay end of the test, it connects to in-memory fakes.
+type relayDrone struct {
+	mu    sync.Mutex
+	video map[int]net.Conn // the fake drone's end of each ListenPackets() connection
+}
+
+func (rd *relayDrone) DialControl(droneAddr string, dronePort, localPort int) (net.Conn, error) {
+	ours, theirs := net.Pipe()
+	go func() {
+		buff := make([]byte, 1024)
+		for {
+			n, err := theirs.Read(buff)
+			if err != nil {
+				return
+			}
+			if bytes.HasPrefix(buff[:n], []byte("conn_req:")) {
+				theirs.Write([]byte("conn_ack:\x39\x30"))
+			}
+		}
+	}()
+	return ours, nil
+}
+
+func (rd *relayDrone) ListenPackets(localPort int) (net.Conn, error) {
+	if localPort == 1 {
+		return nil, errors.New("Not supported")
+	}
+	ours, theirs := net.Pipe()
+	rd.mu.Lock()
+	rd.video[localPort] = theirs
+	rd.mu.Unlock()
+	return ours, nil
+}
+
+func TestRelay(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	rd := &relayDrone{video: map[int]net.Conn{}}
+	served := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			served <- err
+			return
+		}
+		served <- ServeRelay(conn, rd)
+	}()
+
+	rt, err := DialRelay(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	drone := NewTello(WithTransport(rt))
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatalf("Connect via the relay failed with %v", err)
+	}
+	if drone.VideoPort() != 12345 {
+		t.Errorf("Expected the video port from the fake drone, got %d", drone.VideoPort())
+	}
+	video, err := drone.VideoConnectDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rd.mu.Lock()
+	droneVideo := rd.video[12345]
+	rd.mu.Unlock()
+	for _, pkt := range []string{"\x00\x80one", "\x01\x80two"} {
+		droneVideo.Write([]byte(pkt))
+	}
+	for _, want := range []string{"one", "two"} {
+		select {
+		case got := <-video:
+			if string(got) != want {
+				t.Errorf("Expected video %q, got %q", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for relayed video")
+		}
+	}
+
+	if _, err := rt.ListenPackets(1); err == nil || err.Error() != "Not supported" {
+		t.Errorf("Expected the relay's error, got %v", err)
+	}
+
+	drone.VideoDisconnect()
+	drone.ControlDisconnect()
+	rt.Close()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected the relay to finish cleanly, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for the relay to finish")
+	}
+	<-rt.Done()
+	if _, err := rt.ListenPackets(6038); err == nil {
+		t.Error("Expected an error once the relay is closed")
+	}
+}
+
+func TestRelayReorder(t *testing.T) {
+	var got []uint32
+	var ro relayReorder
+	add := func(seqs ...uint32) {
+		for _, s := range seqs {
+			ro.add(s, nil, func([]byte) { got = append(got, ro.next-1) })
+		}
+	}
+	add(10, 12, 11, 11, 9, 13)
+	want := []uint32{10, 11, 12, 13}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+
+	// a gap which is never filled is skipped once the window is full
+	got = nil
+	for s := uint32(15); s < 15+relayReorderWindow+1; s++ {
+		add(s)
+	}
+	if len(got) != relayReorderWindow+1 || got[0] != 15 {
+		t.Errorf("Expected the gap to be skipped, got %v", got)
+	}
+}
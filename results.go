@@ -0,0 +1,76 @@
+// results.go
+
+// This file handles the result codes the drone sends in response to commands.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "fmt"
+
+// CommandError reports that the drone refused a command, eg. a flip with too little battery.
+// It is returned by the ...AndWait() funcs and sent as the Data of an EvCommandRefused Event.
+type CommandError struct {
+	MessageID MessageID // the command which was refused
+	Result    byte      // the non-zero result code sent by the drone
+}
+
+func (e *CommandError) Error() string {
+	name, known := commandNames[e.MessageID]
+	if !known {
+		name = "command " + e.MessageID.String()
+	}
+	return fmt.Sprintf("Tello refused %s with result code %d", name, e.Result)
+}
+
+var commandNames = map[MessageID]string{
+	MsgDoTakeoff:        "takeoff",
+	MsgDoLand:           "land",
+	MsgDoFlip:           "flip",
+	MsgDoThrowTakeoff:   "throw takeoff",
+	MsgDoPalmLand:       "palm land",
+	MsgDoBounce:         "bounce",
+	MsgDoSmartVideo:     "smart video",
+	MsgSetVideoBitrate:  "set video bitrate",
+	MsgSwitchPicVideo:   "set video mode",
+	MsgSetLowBattThresh: "set low battery threshold",
+	MsgDoCalibration:    "calibration",
+}
+
+// resultError returns a CommandError if the response payload carries a non-zero result code.
+func resultError(messageID MessageID, payload []byte) error {
+	if len(payload) == 0 || payload[0] == 0 {
+		return nil
+	}
+	if messageID == MsgDoTakePic {
+		return &PhotoError{Code: payload[0]}
+	}
+	return &CommandError{MessageID: messageID, Result: payload[0]}
+}
+
+// checkCommandResult sends an EvCommandRefused Event if the drone refused the command in pkt,
+// it returns true if the command was accepted.
+func (tello *Tello) checkCommandResult(pkt packet) (ok bool) {
+	if err := resultError(pkt.messageID, pkt.payload); err != nil {
+		tello.emitEvent(EvCommandRefused, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+// tello project results_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckCommandResult(t *testing.T) {
+	tello := new(Tello)
+	events, stop := tello.ListenEvents()
+	defer stop()
+
+	if !tello.checkCommandResult(packet{messageID: MsgDoFlip, payload: []byte{0}}) {
+		t.Error("Expected a zero result to be accepted")
+	}
+	if tello.checkCommandResult(packet{messageID: MsgDoFlip, payload: []byte{1}}) {
+		t.Error("Expected a non-zero result to be refused")
+	}
+	select {
+	case ev := <-events:
+		var ce *CommandError
+		if ev.Type != EvCommandRefused || !errors.As(ev.Data.(error), &ce) {
+			t.Fatalf("Expected EvCommandRefused with a *CommandError, got %+v", ev)
+		}
+		if ce.MessageID != MsgDoFlip || ce.Result != 1 {
+			t.Errorf("Unexpected CommandError %+v", ce)
+		}
+		if ce.Error() != "Tello refused flip with result code 1" {
+			t.Errorf("Unexpected message: %s", ce.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No EvCommandRefused event received")
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("Unexpected extra event %+v", ev)
+	default:
+	}
+}
@@ -0,0 +1,153 @@
+// rtt.go
+
+// Round-trip time measurement for the control channel.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRTTProbePeriod = 5 * time.Second
+	rttWindow             = 20 // number of recent samples used for Min, Avg and Max
+)
+
+// RTTStats summarises the recent round-trip times of the control channel.
+type RTTStats struct {
+	Samples int           // total number of successful probes
+	Lost    int           // total number of probes which got no reply
+	Last    time.Duration // the most recent RTT
+	Min     time.Duration // minimum of the recent RTTs
+	Avg     time.Duration // mean of the recent RTTs
+	Max     time.Duration // maximum of the recent RTTs
+	Jitter  time.Duration // smoothed variation between consecutive RTTs, as per RFC 3550
+}
+
+type rttTracker struct {
+	mu     sync.Mutex
+	recent []time.Duration // ring of the last rttWindow samples
+	next   int
+	stats  RTTStats
+}
+
+func (rt *rttTracker) record(rtt time.Duration) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.stats.Samples > 0 {
+		d := rtt - rt.stats.Last
+		if d < 0 {
+			d = -d
+		}
+		rt.stats.Jitter += (d - rt.stats.Jitter) / 16
+	}
+	rt.stats.Samples++
+	rt.stats.Last = rtt
+	if len(rt.recent) < rttWindow {
+		rt.recent = append(rt.recent, rtt)
+	} else {
+		rt.recent[rt.next] = rtt
+		rt.next = (rt.next + 1) % rttWindow
+	}
+	rt.stats.Min, rt.stats.Max = rtt, rtt
+	var sum time.Duration
+	for _, r := range rt.recent {
+		sum += r
+		if r < rt.stats.Min {
+			rt.stats.Min = r
+		}
+		if r > rt.stats.Max {
+			rt.stats.Max = r
+		}
+	}
+	rt.stats.Avg = sum / time.Duration(len(rt.recent))
+}
+
+func (rt *rttTracker) lost() {
+	rt.mu.Lock()
+	rt.stats.Lost++
+	rt.mu.Unlock()
+}
+
+// RTT returns the round-trip time statistics for the control channel, these are measured
+// periodically while connected, see WithRTTProbePeriod().
+func (tello *Tello) RTT() RTTStats {
+	tello.rtt.mu.Lock()
+	defer tello.rtt.mu.Unlock()
+	return tello.rtt.stats
+}
+
+// MeasureRTT sends a lightweight query to the Tello and returns the time taken for its reply,
+// the result is also added to the RTT() statistics.
+func (tello *Tello) MeasureRTT(ctx context.Context) (rtt time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(ctx, ackTimeout)
+	defer cancel()
+	sent := time.Now()
+	_, err = tello.sendAndWait(ctx, ptGet, MsgQueryVersion, nil)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			tello.rtt.lost()
+		}
+		return 0, err
+	}
+	rtt = time.Since(sent)
+	tello.rtt.record(rtt)
+	return rtt, nil
+}
+
+// WithRTTProbePeriod sets how often the round-trip time is measured while connected,
+// a negative period disables the measurement.
+func WithRTTProbePeriod(period time.Duration) Option {
+	return func(tello *Tello) { tello.cfg.rttProbePeriod = period }
+}
+
+func (c *config) getRTTProbePeriod() time.Duration {
+	if c.rttProbePeriod == 0 {
+		return defaultRTTProbePeriod
+	}
+	return c.rttProbePeriod
+}
+
+// rttProber measures the RTT periodically until done is closed.
+func (tello *Tello) rttProber(done chan struct{}) {
+	period := tello.cfg.getRTTProbePeriod()
+	if period < 0 {
+		return
+	}
+	clock := tello.cfg.getClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-done
+		cancel()
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		case <-clock.After(period):
+			tello.MeasureRTT(ctx)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// tello project rtt_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRTTTracker(t *testing.T) {
+	var rt rttTracker
+	for _, ms := range []time.Duration{10, 30, 20} {
+		rt.record(ms * time.Millisecond)
+	}
+	rt.lost()
+	s := rt.stats
+	if s.Samples != 3 || s.Lost != 1 || s.Last != 20*time.Millisecond {
+		t.Errorf("Unexpected counts %+v", s)
+	}
+	if s.Min != 10*time.Millisecond || s.Max != 30*time.Millisecond || s.Avg != 20*time.Millisecond {
+		t.Errorf("Unexpected min/avg/max %+v", s)
+	}
+	if s.Jitter <= 0 {
+		t.Errorf("Expected some jitter, got %v", s.Jitter)
+	}
+
+	// old samples drop out of the window
+	for i := 0; i < rttWindow; i++ {
+		rt.record(5 * time.Millisecond)
+	}
+	if s := rt.stats; s.Min != 5*time.Millisecond || s.Max != 5*time.Millisecond {
+		t.Errorf("Expected only recent samples, got %+v", s)
+	}
+}
+
+func TestRTTProbe(t *testing.T) {
+	port := startFakeDrone(t)
+	drone := NewTello(WithRTTProbePeriod(20 * time.Millisecond))
+	if err := drone.ControlConnect("127.0.0.1", port, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer drone.ControlDisconnect()
+	deadline := time.Now().Add(2 * time.Second)
+	for drone.RTT().Samples < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s := drone.RTT(); s.Samples < 3 || s.Max <= 0 || s.Max > time.Second {
+		t.Errorf("Expected RTT samples from the fake drone, got %+v", s)
+	}
+	if v := drone.GetFlightData().Version; v != "v1" {
+		t.Errorf("Expected the version reply to be processed, got %q", v)
+	}
+}
@@ -0,0 +1,412 @@
+// script.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScriptDefaultTimeout is the time allowed for each step of a Script unless set otherwise.
+const ScriptDefaultTimeout = 30 * time.Second
+
+// ErrorPolicy determines what a Script does when one of its steps fails or times out.
+type ErrorPolicy int
+
+// Script error policies...
+const (
+	OnErrorAbort    ErrorPolicy = iota // stop the script, leaving the drone hovering
+	OnErrorContinue                    // log the error and go on to the next step
+	OnErrorLand                        // land the drone, then stop the script
+)
+
+// ScriptStep is a single command in a Script.
+type ScriptStep struct {
+	Name    string        // eg. "up 50", used in log messages and errors
+	Timeout time.Duration // 0 uses the Script's Timeout
+	OnError ErrorPolicy
+	Do      func(ctx context.Context, tello *Tello) error
+}
+
+// Script is a sequence of commands which are run one after the other, each step waiting for
+// the previous one to complete.
+// Scripts may be built in code, eg.
+//
+//	script := tello.NewScript().TakeOff().Up(50).Rotate(90).Photo().Land()
+//
+// or parsed from text, see ParseScript().
+type Script struct {
+	Steps   []ScriptStep
+	Timeout time.Duration // default per-step timeout, 0 means ScriptDefaultTimeout
+}
+
+// ScriptError is returned by Script.Run() when a step fails.
+type ScriptError struct {
+	Step int    // index of the failed step
+	Name string // name of the failed step
+	Err  error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("Script step %d (%s) failed: %v", e.Step+1, e.Name, e.Err)
+}
+
+// Unwrap returns the error from the failed step.
+func (e *ScriptError) Unwrap() error { return e.Err }
+
+// NewScript returns an empty Script.
+func NewScript() *Script {
+	return &Script{}
+}
+
+// Step appends a custom step to the Script.
+func (s *Script) Step(name string, do func(ctx context.Context, tello *Tello) error) *Script {
+	s.Steps = append(s.Steps, ScriptStep{Name: name, Do: do})
+	return s
+}
+
+// WithTimeout sets the timeout of the most recently added step.
+func (s *Script) WithTimeout(d time.Duration) *Script {
+	if len(s.Steps) > 0 {
+		s.Steps[len(s.Steps)-1].Timeout = d
+	}
+	return s
+}
+
+// OnError sets the error policy of the most recently added step.
+func (s *Script) OnError(policy ErrorPolicy) *Script {
+	if len(s.Steps) > 0 {
+		s.Steps[len(s.Steps)-1].OnError = policy
+	}
+	return s
+}
+
+// TakeOff appends a step which takes off and waits until the drone is hovering.
+func (s *Script) TakeOff() *Script {
+	return s.Step("takeoff", func(ctx context.Context, tello *Tello) error {
+		if err := tello.TakeOffAndWait(ctx); err != nil {
+			return err
+		}
+		return tello.WaitForTakeoff(ctx)
+	})
+}
+
+// Land appends a step which lands and waits until the drone is on the ground.
+func (s *Script) Land() *Script {
+	return s.Step("land", func(ctx context.Context, tello *Tello) error {
+		if err := tello.LandAndWait(ctx); err != nil {
+			return err
+		}
+		return tello.WaitForLanding(ctx)
+	})
+}
+
+// Up appends a step which climbs by cm centimetres, use a negative value to descend.
+// N.B. The Tello measures height in decimetres, so cm is rounded to the nearest 10.
+func (s *Script) Up(cm int) *Script {
+	return s.Step(fmt.Sprintf("up %d", cm), func(ctx context.Context, tello *Tello) error {
+		target := tello.GetFlightData().Height + int16(math.Round(float64(cm)/10))
+		done, err := tello.AutoFlyToHeight(target)
+		return waitAutopilot(ctx, done, err, tello.CancelAutoFlyToHeight)
+	})
+}
+
+// Down appends a step which descends by cm centimetres, rounded to the nearest 10 as for Up().
+func (s *Script) Down(cm int) *Script {
+	s.Up(-cm)
+	s.Steps[len(s.Steps)-1].Name = fmt.Sprintf("down %d", cm)
+	return s
+}
+
+// Forward appends a step which flies cm centimetres in the direction the drone is facing.
+// The MVO must be working, and the home point is set if it was not already.
+func (s *Script) Forward(cm int) *Script {
+	return s.move(fmt.Sprintf("forward %d", cm), 0, float32(cm)/100)
+}
+
+// Back appends a step which flies cm centimetres backwards.
+func (s *Script) Back(cm int) *Script {
+	return s.move(fmt.Sprintf("back %d", cm), 0, -float32(cm)/100)
+}
+
+// Left appends a step which flies cm centimetres to the left.
+func (s *Script) Left(cm int) *Script {
+	return s.move(fmt.Sprintf("left %d", cm), -float32(cm)/100, 0)
+}
+
+// Right appends a step which flies cm centimetres to the right.
+func (s *Script) Right(cm int) *Script {
+	return s.move(fmt.Sprintf("right %d", cm), float32(cm)/100, 0)
+}
+
+// move flies right and forward metres relative to the drone's current position and heading.
+func (s *Script) move(name string, right, forward float32) *Script {
+	return s.Step(name, func(ctx context.Context, tello *Tello) error {
+		if !tello.IsHomeSet() {
+			if err := tello.SetHome(); err != nil {
+				return err
+			}
+		}
+		fd := tello.GetFlightData()
+		tello.autoXYMu.RLock()
+		x, y := fd.MVO.PositionX-tello.homeX, fd.MVO.PositionY-tello.homeY
+		tello.autoXYMu.RUnlock()
+		dx, dy := bodyToWorld(fd.IMU.Yaw, right, forward)
+		done, err := tello.AutoFlyToXY(x+dx, y+dy)
+		return waitAutopilot(ctx, done, err, tello.CancelAutoFlyToXY)
+	})
+}
+
+// Rotate appends a step which turns by deg degrees, positive values turn clockwise.
+func (s *Script) Rotate(deg int) *Script {
+	return s.Step(fmt.Sprintf("rotate %d", deg), func(ctx context.Context, tello *Tello) error {
+		done, err := tello.AutoTurnByDeg(float32(deg))
+		return waitAutopilot(ctx, done, err, tello.CancelAutoTurn)
+	})
+}
+
+// Flip appends a step which flips the drone in the given direction.
+func (s *Script) Flip(dir FlipType) *Script {
+	return s.Step(fmt.Sprintf("flip %d", dir), func(ctx context.Context, tello *Tello) error {
+		return tello.FlipCtx(ctx, dir)
+	})
+}
+
+// Photo appends a step which takes a picture.
+func (s *Script) Photo() *Script {
+	return s.Step("photo", func(ctx context.Context, tello *Tello) error {
+		return tello.TakePictureCtx(ctx)
+	})
+}
+
+// Wait appends a step which pauses the script for d.
+func (s *Script) Wait(d time.Duration) *Script {
+	return s.Step("wait "+d.String(), func(ctx context.Context, tello *Tello) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tello.cfg.getClock().After(d):
+			return nil
+		}
+	})
+}
+
+// Run executes the Script's steps in order, each with its own timeout, until they are all done,
+// a step fails with OnErrorAbort or OnErrorLand, or ctx is done.
+// Any error is a *ScriptError.
+func (s *Script) Run(ctx context.Context, tello *Tello) (err error) {
+	for i, step := range s.Steps {
+		timeout := step.Timeout
+		if timeout == 0 {
+			timeout = s.Timeout
+		}
+		if timeout == 0 {
+			timeout = ScriptDefaultTimeout
+		}
+		stepCtx, cancel := context.WithTimeout(ctx, timeout)
+		tello.logf("Script step %d: %s\n", i+1, step.Name)
+		err := step.Do(stepCtx, tello)
+		cancel()
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			err = &TimeoutError{Op: step.Name}
+		}
+		serr := &ScriptError{Step: i, Name: step.Name, Err: err}
+		if ctx.Err() != nil {
+			return serr
+		}
+		switch step.OnError {
+		case OnErrorContinue:
+			tello.logln("WARN:", serr)
+			continue
+		case OnErrorLand:
+			tello.logln("WARN:", serr, "- landing")
+			landCtx, cancel := context.WithTimeout(ctx, timeout)
+			if lerr := tello.LandAndWait(landCtx); lerr != nil {
+				tello.logln("WARN: Script could not land:", lerr)
+			}
+			cancel()
+		}
+		return serr
+	}
+	return nil
+}
+
+// ParseScript parses a script written as commands separated by semicolons or newlines, eg.
+//
+//	takeoff; up 50; rotate 90; photo; land
+//
+// Distances are in centimetres, angles in degrees and waits in seconds.  As the Tello measures
+// height in decimetres, up and down must be multiples of 10.  The commands are
+//
+//	takeoff, land, up N, down N, forward N, back N, left N, right N,
+//	rotate N (cw N and ccw N are also accepted), photo, wait N and
+//	flip f|b|l|r|fl|fr|bl|br
+//
+// Any command may be followed by timeout=DURATION (eg. timeout=10s) and
+// onerror=abort|continue|land.  Text following a # is ignored.
+func ParseScript(src string) (s *Script, err error) {
+	s = NewScript()
+	for lineNo, line := range strings.Split(src, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		for _, stmt := range strings.Split(line, ";") {
+			fields := strings.Fields(stmt)
+			if len(fields) == 0 {
+				continue
+			}
+			if err = s.parseStatement(fields); err != nil {
+				return nil, fmt.Errorf("Script line %d: %q: %v", lineNo+1, strings.TrimSpace(stmt), err)
+			}
+		}
+	}
+	return s, nil
+}
+
+var scriptFlips = map[string]FlipType{
+	"f": FlipForward, "b": FlipBackward, "l": FlipLeft, "r": FlipRight,
+	"fl": FlipForwardLeft, "fr": FlipForwardRight, "bl": FlipBackwardLeft, "br": FlipBackwardRight,
+}
+
+func (s *Script) parseStatement(fields []string) error {
+	var args []string
+	var timeout time.Duration
+	policy := OnErrorAbort
+	for _, f := range fields[1:] {
+		switch {
+		case strings.HasPrefix(f, "timeout="):
+			d, err := time.ParseDuration(strings.TrimPrefix(f, "timeout="))
+			if err != nil || d <= 0 {
+				return errors.New("Invalid timeout")
+			}
+			timeout = d
+		case strings.HasPrefix(f, "onerror="):
+			switch strings.TrimPrefix(f, "onerror=") {
+			case "abort":
+				policy = OnErrorAbort
+			case "continue":
+				policy = OnErrorContinue
+			case "land":
+				policy = OnErrorLand
+			default:
+				return errors.New("Unknown error policy")
+			}
+		default:
+			args = append(args, f)
+		}
+	}
+
+	cmd := strings.ToLower(fields[0])
+	nArgs := 1
+	switch cmd {
+	case "takeoff", "land", "photo":
+		nArgs = 0
+	}
+	if len(args) != nArgs {
+		return fmt.Errorf("Expected %d argument(s)", nArgs)
+	}
+	var n int
+	if nArgs == 1 && cmd != "flip" && cmd != "wait" {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			return errors.New("Argument must be a whole number")
+		}
+	}
+
+	switch cmd {
+	case "takeoff":
+		s.TakeOff()
+	case "land":
+		s.Land()
+	case "photo":
+		s.Photo()
+	case "up", "down":
+		if n%10 != 0 {
+			return errors.New("Heights must be a multiple of 10cm")
+		}
+		if cmd == "up" {
+			s.Up(n)
+		} else {
+			s.Down(n)
+		}
+	case "forward":
+		s.Forward(n)
+	case "back":
+		s.Back(n)
+	case "left":
+		s.Left(n)
+	case "right":
+		s.Right(n)
+	case "rotate", "cw":
+		s.Rotate(n)
+	case "ccw":
+		s.Rotate(-n)
+	case "wait":
+		secs, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || secs < 0 {
+			return errors.New("Wait must be a number of seconds")
+		}
+		s.Wait(time.Duration(secs * float64(time.Second)))
+	case "flip":
+		dir, ok := scriptFlips[strings.ToLower(args[0])]
+		if !ok {
+			return errors.New("Unknown flip direction")
+		}
+		s.Flip(dir)
+	default:
+		return errors.New("Unknown command")
+	}
+	s.WithTimeout(timeout).OnError(policy)
+	return nil
+}
+
+// waitAutopilot waits for an autopilot started with the given done channel and error to finish,
+// cancelling it if ctx is done first.
+func waitAutopilot(ctx context.Context, done chan error, err error, cancel func()) error {
+	if err != nil {
+		return err
+	}
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// bodyToWorld converts a movement right and forward relative to a drone with the given yaw
+// into the MVO frame, it is the inverse of calcXYdeltas().
+func bodyToWorld(yawDeg, right, forward float32) (dx, dy float32) {
+	yaw := float64(yawDeg) * math.Pi / 180
+	sin, cos := float32(math.Sin(yaw)), float32(math.Cos(yaw))
+	return cos*right + sin*forward, -sin*right + cos*forward
+}
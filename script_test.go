@@ -0,0 +1,144 @@
+// script_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestParseScript(t *testing.T) {
+	s, err := ParseScript("takeoff; up 50 timeout=10s\n# a comment\nrotate 90 onerror=continue; photo\nflip bl; wait 0.5; land # done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"takeoff", "up 50", "rotate 90", "photo", "flip 5", "wait 500ms", "land"}
+	if len(s.Steps) != len(want) {
+		t.Fatalf("Expected %d steps, got %d", len(want), len(s.Steps))
+	}
+	for i, step := range s.Steps {
+		if step.Name != want[i] {
+			t.Errorf("Step %d: expected %q, got %q", i, want[i], step.Name)
+		}
+	}
+	if s.Steps[1].Timeout != 10*time.Second || s.Steps[2].OnError != OnErrorContinue || s.Steps[0].OnError != OnErrorAbort {
+		t.Error("Step options not parsed")
+	}
+
+	for _, bad := range []string{"jump", "up", "up fifty", "land 1", "flip x", "photo onerror=panic", "wait -1", "up 5 timeout=soon", "down 25"} {
+		if _, err := ParseScript(bad); err == nil {
+			t.Errorf("Expected %q to fail", bad)
+		}
+	}
+}
+
+func TestScriptErrorPolicies(t *testing.T) {
+	drone := &Tello{cfg: config{logger: log.New(io.Discard, "", 0)}}
+	fail := errors.New("failed")
+	var ran []string
+	step := func(name string, err error) func(context.Context, *Tello) error {
+		return func(context.Context, *Tello) error {
+			ran = append(ran, name)
+			return err
+		}
+	}
+
+	s := NewScript().
+		Step("a", step("a", fail)).OnError(OnErrorContinue).
+		Step("b", step("b", fail)).
+		Step("c", step("c", nil))
+	err := s.Run(context.Background(), drone)
+	var serr *ScriptError
+	if !errors.As(err, &serr) || serr.Step != 1 || !errors.Is(err, fail) {
+		t.Errorf("Expected step b to fail, got %v", err)
+	}
+	if len(ran) != 2 {
+		t.Errorf("Expected the script to stop after step b, ran %v", ran)
+	}
+
+	s = NewScript().Step("slow", func(ctx context.Context, _ *Tello) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}).WithTimeout(10 * time.Millisecond)
+	if err := s.Run(context.Background(), drone); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	s = NewScript().Step("wrapped", func(ctx context.Context, _ *Tello) error {
+		<-ctx.Done()
+		return fmt.Errorf("waiting: %w", ctx.Err())
+	}).WithTimeout(10 * time.Millisecond)
+	if err := s.Run(context.Background(), drone); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected a wrapped deadline to be a timeout, got %v", err)
+	}
+}
+
+func TestScriptRun(t *testing.T) {
+	drone := ackingDrone(t)
+	s, err := ParseScript("photo; wait 0.01; flip f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(context.Background(), drone); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScriptWaitClock(t *testing.T) {
+	clock := &manualClock{t: time.Unix(1600000000, 0)}
+	drone := NewTello(WithClock(clock))
+	wait := NewScript().Wait(time.Hour).Steps[0]
+	done := make(chan error, 1)
+	go func() { done <- wait.Do(context.Background(), drone) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for pending := 0; pending == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the wait to be timed by the drone's Clock")
+		}
+		clock.mu.Lock()
+		pending = len(clock.timers)
+		clock.mu.Unlock()
+	}
+	clock.fire(time.Hour)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the wait to end when the clock fired")
+	}
+}
+
+func TestBodyToWorld(t *testing.T) {
+	for _, yaw := range []float32{0, 45, 90, -135, 180} {
+		x, y := bodyToWorld(yaw, 1.5, -2)
+		dx, dy := calcXYdeltas(yaw, 0, 0, x, y)
+		if float32Abs(dx-1.5) > 1e-4 || float32Abs(dy+2) > 1e-4 {
+			t.Errorf("Yaw %v: expected (1.5,-2), got (%v,%v)", yaw, dx, dy)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// ext.go
+
+// This file contains the RoboMaster TT (Talent Tello) expansion-kit commands.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SMerrony/tello"
+)
+
+// MatrixColour is the colour of a pixel or character on the TT's 8x8 LED matrix.
+type MatrixColour byte
+
+// Matrix colours...
+const (
+	MatrixOff    MatrixColour = '0'
+	MatrixRed    MatrixColour = 'r'
+	MatrixBlue   MatrixColour = 'b'
+	MatrixPurple MatrixColour = 'p'
+)
+
+// ScrollDirection is the direction in which text scrolls across the LED matrix.
+type ScrollDirection byte
+
+// Scroll directions...
+const (
+	ScrollLeft  ScrollDirection = 'l'
+	ScrollRight ScrollDirection = 'r'
+	ScrollUp    ScrollDirection = 'u'
+	ScrollDown  ScrollDirection = 'd'
+)
+
+// SendExtCommand sends a command to the ESP32 expansion board of a RoboMaster TT and
+// returns its reply.  The "EXT " prefix is added for you.
+func (c *Client) SendExtCommand(cmd string) (reply string, err error) {
+	return c.Command("EXT " + cmd)
+}
+
+// SetLED sets the top LED to a steady colour, each component ranges from 0 to 255.
+func (c *Client) SetLED(r, g, b uint8) (err error) {
+	_, err = c.SendExtCommand(fmt.Sprintf("led %d %d %d", r, g, b))
+	return err
+}
+
+// BreatheLED makes the top LED pulse in the given colour at freq Hz (0.1 to 2.5).
+func (c *Client) BreatheLED(freq float32, r, g, b uint8) (err error) {
+	if freq < 0.1 || freq > 2.5 {
+		return errors.New("LED breathing frequency must be between 0.1 and 2.5 Hz")
+	}
+	_, err = c.SendExtCommand(fmt.Sprintf("led br %.1f %d %d %d", freq, r, g, b))
+	return err
+}
+
+// BlinkLED makes the top LED alternate between two colours at freq Hz (0.1 to 10).
+func (c *Client) BlinkLED(freq float32, r1, g1, b1, r2, g2, b2 uint8) (err error) {
+	if freq < 0.1 || freq > 10 {
+		return errors.New("LED blinking frequency must be between 0.1 and 10 Hz")
+	}
+	_, err = c.SendExtCommand(fmt.Sprintf("led bl %.1f %d %d %d %d %d %d", freq, r1, g1, b1, r2, g2, b2))
+	return err
+}
+
+// ShowMatrixPattern displays an 8x8 pattern on the LED matrix, rows are given top to bottom.
+func (c *Client) ShowMatrixPattern(rows [8][8]MatrixColour) (err error) {
+	_, err = c.SendExtCommand("mled g " + matrixPattern(rows))
+	return err
+}
+
+// ShowMatrixChar displays a single character (or "heart") on the LED matrix.
+func (c *Client) ShowMatrixChar(colour MatrixColour, char string) (err error) {
+	_, err = c.SendExtCommand("mled s " + string(colour) + " " + char)
+	return err
+}
+
+// ScrollMatrixText scrolls up to 70 characters of text across the LED matrix at freq Hz (0.1 to 2.5).
+func (c *Client) ScrollMatrixText(dir ScrollDirection, colour MatrixColour, freq float32, text string) (err error) {
+	if len(text) > 70 {
+		return errors.New("Matrix text is limited to 70 characters")
+	}
+	if freq < 0.1 || freq > 2.5 {
+		return errors.New("Matrix scroll frequency must be between 0.1 and 2.5 Hz")
+	}
+	_, err = c.SendExtCommand(fmt.Sprintf("mled %c %c %.1f %s", dir, colour, freq, text))
+	return err
+}
+
+// ClearMatrix turns off every pixel of the LED matrix.
+func (c *Client) ClearMatrix() (err error) {
+	_, err = c.SendExtCommand("mled sc")
+	return err
+}
+
+// SetMatrixBrightness sets the LED matrix brightness from 0 to 255.
+func (c *Client) SetMatrixBrightness(level uint8) (err error) {
+	_, err = c.SendExtCommand("mled sl " + strconv.Itoa(int(level)))
+	return err
+}
+
+// GetExtTOF returns the distance in mm measured by the forward-facing ToF sensor of the expansion kit.
+// 8192 is returned if nothing is in range.
+func (c *Client) GetExtTOF() (mm int, err error) {
+	reply, err := c.SendExtCommand("tof?")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(reply, "tof")))
+}
+
+// SetMotors starts (or stops) the motors spinning slowly on the ground, without taking off.
+// This is the SDK 3.0 motoron/motoroff command, used eg. to cool the drone or before a throw launch.
+// tello.ErrAirborne is returned if asked to start the motors while the state says we are flying.
+func (c *Client) SetMotors(on bool) (err error) {
+	if on {
+		if c.GetFlightData().Flying {
+			return tello.ErrAirborne
+		}
+		_, err = c.Command("motoron")
+	} else {
+		_, err = c.Command("motoroff")
+	}
+	return err
+}
+
+func matrixPattern(rows [8][8]MatrixColour) string {
+	var sb strings.Builder
+	for _, row := range rows {
+		for _, px := range row {
+			sb.WriteByte(byte(px))
+		}
+	}
+	return sb.String()
+}
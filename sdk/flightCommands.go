@@ -0,0 +1,130 @@
+// flightCommands.go
+
+// This file contains the text-SDK flight command API.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sdk
+
+import (
+	"github.com/SMerrony/tello"
+)
+
+// TakeOff sends a takeoff command to the Tello.
+func (c *Client) TakeOff() {
+	c.sendLogged("takeoff")
+}
+
+// Land sends a land command to the Tello.
+func (c *Client) Land() {
+	c.sendLogged("land")
+}
+
+// Emergency stops the motors immediately.
+func (c *Client) Emergency() {
+	c.sendLogged("emergency")
+}
+
+// Flip sends a flip flight command to the Tello.
+// The text SDK only supports the four basic directions, diagonal flips are ignored.
+func (c *Client) Flip(dir tello.FlipType) {
+	switch dir {
+	case tello.FlipForward:
+		c.sendLogged("flip f")
+	case tello.FlipBackward:
+		c.sendLogged("flip b")
+	case tello.FlipLeft:
+		c.sendLogged("flip l")
+	case tello.FlipRight:
+		c.sendLogged("flip r")
+	default:
+		c.logf("Flip type %d not supported by the text SDK\n", dir)
+	}
+}
+
+// UpdateSticks does a one-off update of the stick values which are then sent to the Tello.
+// N.B. All four axes are updated on every call to this func.
+func (c *Client) UpdateSticks(sm tello.StickMessage) {
+	c.ctrlMu.Lock()
+	c.ctrlLx = sm.Lx
+	c.ctrlLy = sm.Ly
+	c.ctrlRx = sm.Rx
+	c.ctrlRy = sm.Ry
+	c.ctrlMu.Unlock()
+	c.sendSticks()
+}
+
+// Hover simply sets the sticks to zero which should halt all motion.
+func (c *Client) Hover() {
+	c.UpdateSticks(tello.StickMessage{})
+}
+
+// Forward tells the drone to start moving forward at a given speed between 0 and 100.
+func (c *Client) Forward(pct int) {
+	c.UpdateSticks(tello.StickMessage{Ry: pctToStick(pct)})
+}
+
+// Backward tells the drone to start moving Backward at a given speed between 0 and 100.
+func (c *Client) Backward(pct int) {
+	c.UpdateSticks(tello.StickMessage{Ry: -pctToStick(pct)})
+}
+
+// Left tells the drone to start moving Left at a given speed between 0 and 100.
+func (c *Client) Left(pct int) {
+	c.UpdateSticks(tello.StickMessage{Rx: -pctToStick(pct)})
+}
+
+// Right tells the drone to start moving Right at a given speed between 0 and 100.
+func (c *Client) Right(pct int) {
+	c.UpdateSticks(tello.StickMessage{Rx: pctToStick(pct)})
+}
+
+// Up tells the drone to start moving Up at a given speed between 0 and 100.
+func (c *Client) Up(pct int) {
+	c.UpdateSticks(tello.StickMessage{Ly: pctToStick(pct)})
+}
+
+// Down tells the drone to start moving Down at a given speed between 0 and 100.
+func (c *Client) Down(pct int) {
+	c.UpdateSticks(tello.StickMessage{Ly: -pctToStick(pct)})
+}
+
+// Clockwise tells the drone to start rotating Clockwise at a given speed between 0 and 100.
+func (c *Client) Clockwise(pct int) {
+	c.UpdateSticks(tello.StickMessage{Lx: pctToStick(pct)})
+}
+
+// Anticlockwise tells the drone to start rotating Anticlockwise at a given speed between 0 and 100.
+func (c *Client) Anticlockwise(pct int) {
+	c.UpdateSticks(tello.StickMessage{Lx: -pctToStick(pct)})
+}
+
+func (c *Client) sendLogged(cmd string) {
+	if err := c.send(cmd); err != nil {
+		c.logf("Error sending %s - %v\n", cmd, err)
+	}
+}
+
+func pctToStick(pct int) (speed int16) {
+	if pct > 0 {
+		speed = int16(pct) * 327 // /100 * 32767
+	}
+	return speed
+}
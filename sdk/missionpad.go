@@ -0,0 +1,90 @@
+// missionpad.go
+
+// This file contains the Tello EDU mission pad commands.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sdk
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/SMerrony/tello"
+)
+
+const padChanSize = 10
+
+// EnableMissionPadDetection asks a Tello EDU to start looking for mission pads.  The detection
+// results arrive in the state packets and are stored in FlightData.MissionPad, changes are sent
+// to the channels returned by ListenMissionPads().
+func (c *Client) EnableMissionPadDetection() (err error) {
+	_, err = c.Command("mon")
+	return err
+}
+
+// DisableMissionPadDetection asks a Tello EDU to stop looking for mission pads.
+func (c *Client) DisableMissionPadDetection() (err error) {
+	_, err = c.Command("moff")
+	return err
+}
+
+// SetMissionPadDetectionDirection chooses which camera(s) are used for mission pad detection.
+// Detection must already have been enabled via EnableMissionPadDetection().
+func (c *Client) SetMissionPadDetectionDirection(dir tello.MissionPadDirection) (err error) {
+	if dir < tello.MpDownward || dir > tello.MpBoth {
+		return errors.New("Invalid mission pad detection direction")
+	}
+	_, err = c.Command("mdirection " + strconv.Itoa(int(dir)))
+	return err
+}
+
+// ListenMissionPads returns a channel which receives the mission pad data whenever the detected pad
+// changes, or the drone moves relative to it, and a function to stop listening.
+// A pad ID of tello.MissionPadNone is sent when the pad is lost.
+// N.B. Slow listeners miss updates rather than delaying the state listener.
+func (c *Client) ListenMissionPads() (<-chan tello.MissionPad, func()) {
+	c.padMu.Lock()
+	defer c.padMu.Unlock()
+	if c.padListeners == nil {
+		c.padListeners = map[chan tello.MissionPad]chan tello.MissionPad{}
+	}
+	res := make(chan tello.MissionPad, padChanSize)
+	c.padListeners[res] = res
+	return res, func() {
+		c.padMu.Lock()
+		defer c.padMu.Unlock()
+		if _, present := c.padListeners[res]; present {
+			delete(c.padListeners, res)
+			close(res)
+		}
+	}
+}
+
+func (c *Client) emitMissionPad(mp tello.MissionPad) {
+	c.padMu.RLock()
+	for l := range c.padListeners {
+		select {
+		case l <- mp:
+		default:
+		}
+	}
+	c.padMu.RUnlock()
+}
@@ -0,0 +1,404 @@
+// sdk.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+/*
+Package sdk provides a client for the official Tello text SDK ("command" mode).
+
+The text SDK is the documented UDP protocol used by the Tello EDU and the RoboMaster TT,
+whose firmwares do not always behave like the original Tello when spoken to with the binary
+protocol used by the main tello package.  Client implements tello.Drone, so applications
+can switch between the two transports.
+*/
+package sdk
+
+import (
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SMerrony/tello"
+)
+
+const (
+	defaultTelloAddr        = "192.168.10.1"
+	defaultTelloControlPort = 8889
+	defaultLocalStatePort   = 8890
+	defaultLocalVideoPort   = 11111
+)
+
+const (
+	keepAlivePeriodMs = 100                   // how often we resend the current rc values
+	rcReplyWait       = 50 * time.Millisecond // how long after an rc command any reply to it should have arrived
+	replyTimeout      = 7 * time.Second       // the Tello only replies to eg. takeoff when it has completed
+	stateTimeout      = 5 * time.Second       // we assume connection lost if no state for this period
+)
+
+// Client holds the current state of a text-SDK connection to a Tello.
+type Client struct {
+	Logger                         *log.Logger  // receives the Client's log output, the standard logger if nil
+	ctrlMu                         sync.RWMutex // this mutex protects the control fields
+	ctrlConn, stateConn, videoConn *net.UDPConn
+	ctrlConnected, ctrlConnecting  bool
+	ctrlDone                       chan struct{} // closed by ControlDisconnect() to stop the keepalive
+	ctrlRx, ctrlRy, ctrlLx, ctrlLy int16         // we are using the SDL convention like the tello package
+	ctrlAwaiting                   bool          // a Command() is waiting for its reply, so rc is not sent
+	ctrlLastRC                     time.Time     // when rc was last sent
+	cmdMu                          sync.Mutex    // only one blocking command may be outstanding
+	replies                        chan string   // replies to commands from the Tello
+	fdMu                           sync.RWMutex
+	fd                             tello.FlightData
+	stateUpdated                   time.Time
+	videoChan                      chan []byte
+	padMu                          sync.RWMutex // this mutex protects the mission pad listeners
+	padListeners                   map[chan tello.MissionPad]chan tello.MissionPad
+}
+
+var _ tello.Drone = (*Client)(nil)
+
+// ControlConnect attempts to put the Tello at the provided network addr into SDK mode.
+// It then starts listening for replies and state packets in Goroutines.
+func (c *Client) ControlConnect(udpAddr string, droneUDPPort int) (err error) {
+	c.ctrlMu.Lock()
+	switch {
+	case c.ctrlConnected:
+		c.ctrlMu.Unlock()
+		return tello.ErrAlreadyConnected
+	case c.ctrlConnecting:
+		c.ctrlMu.Unlock()
+		return tello.ErrConnecting
+	}
+	c.ctrlConnecting = true
+	c.ctrlMu.Unlock()
+	defer func() {
+		c.ctrlMu.Lock()
+		c.ctrlConnecting = false
+		c.ctrlMu.Unlock()
+	}()
+
+	droneAddr, err := net.ResolveUDPAddr("udp", udpAddr+":"+strconv.Itoa(droneUDPPort))
+	if err != nil {
+		return err
+	}
+	stateAddr, err := net.ResolveUDPAddr("udp", ":"+strconv.Itoa(defaultLocalStatePort))
+	if err != nil {
+		return err
+	}
+	ctrlConn, err := net.DialUDP("udp", nil, droneAddr)
+	if err != nil {
+		return err
+	}
+	stateConn, err := net.ListenUDP("udp", stateAddr)
+	if err != nil {
+		ctrlConn.Close()
+		return err
+	}
+	c.ctrlMu.Lock()
+	c.ctrlConn = ctrlConn
+	c.stateConn = stateConn
+	c.replies = make(chan string, 10)
+	c.ctrlMu.Unlock()
+
+	go c.replyListener(ctrlConn)
+	go c.stateListener(stateConn)
+
+	// enter SDK mode
+	if _, err = c.Command("command"); err != nil {
+		c.ctrlMu.Lock()
+		c.ctrlConn, c.stateConn = nil, nil
+		c.ctrlMu.Unlock()
+		ctrlConn.Close()
+		stateConn.Close()
+		return err
+	}
+
+	done := make(chan struct{})
+	c.ctrlMu.Lock()
+	c.ctrlConnected = true
+	c.ctrlDone = done
+	c.ctrlMu.Unlock()
+	c.fdMu.Lock()
+	c.stateUpdated = time.Now()
+	c.fdMu.Unlock()
+
+	go c.keepAlive(done)
+
+	return nil
+}
+
+// ControlConnectDefault attempts to put a Tello on the default network address into SDK mode.
+func (c *Client) ControlConnectDefault() (err error) {
+	return c.ControlConnect(defaultTelloAddr, defaultTelloControlPort)
+}
+
+// ControlDisconnect stops the listeners and closes the connections to the Tello.
+func (c *Client) ControlDisconnect() {
+	c.ctrlMu.Lock()
+	if c.ctrlConn != nil {
+		c.ctrlConn.Close()
+	}
+	if c.stateConn != nil {
+		c.stateConn.Close()
+	}
+	if c.ctrlDone != nil {
+		close(c.ctrlDone)
+		c.ctrlDone = nil
+	}
+	c.ctrlConnected = false
+	c.ctrlMu.Unlock()
+}
+
+// ControlConnected returns true if we are currently connected.
+func (c *Client) ControlConnected() (conn bool) {
+	c.ctrlMu.RLock()
+	conn = c.ctrlConnected
+	c.ctrlMu.RUnlock()
+	return conn
+}
+
+// Command sends a text-SDK command to the Tello and waits for its reply.
+// A reply of "error" (or anything starting with it) is returned as an error.
+// N.B. The stick values are not sent while waiting, as the Tello's reply to them would be taken for
+// the command's; the keepalive sends them once the reply has arrived.
+func (c *Client) Command(cmd string) (reply string, err error) {
+	c.cmdMu.Lock()
+	defer c.cmdMu.Unlock()
+
+	// hold back rc, and give the Tello time to reply to the last one
+	c.ctrlMu.Lock()
+	c.ctrlAwaiting = true
+	wait := rcReplyWait - time.Since(c.ctrlLastRC)
+	c.ctrlMu.Unlock()
+	defer func() {
+		c.ctrlMu.Lock()
+		c.ctrlAwaiting = false
+		c.ctrlMu.Unlock()
+	}()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	// discard any stale replies, eg. to rc or unacknowledged commands
+	for len(c.replies) > 0 {
+		<-c.replies
+	}
+	if err = c.send(cmd); err != nil {
+		return "", err
+	}
+	select {
+	case reply = <-c.replies:
+	case <-time.After(replyTimeout):
+		return "", &tello.TimeoutError{Op: "reply to " + cmd}
+	}
+	if strings.HasPrefix(reply, "error") {
+		return reply, errors.New("Tello replied to " + cmd + " with: " + reply)
+	}
+	return reply, nil
+}
+
+// send writes a text-SDK command to the Tello without waiting for a reply.
+func (c *Client) send(cmd string) (err error) {
+	c.ctrlMu.RLock()
+	defer c.ctrlMu.RUnlock()
+	if c.ctrlConn == nil {
+		return tello.ErrNotConnected
+	}
+	_, err = c.ctrlConn.Write([]byte(cmd))
+	return err
+}
+
+// GetFlightData returns the current known state of the Tello.
+// Only the fields carried by the SDK state packet are populated.
+func (c *Client) GetFlightData() tello.FlightData {
+	c.fdMu.RLock()
+	rfd := c.fd
+	c.fdMu.RUnlock()
+	return rfd
+}
+
+// GetSerialNumber asks the Tello for its serial number, which is also stored in FlightData.SerialNumber,
+// eg. to pass to tello.WithSerialNumber() for a later session using the binary protocol.
+func (c *Client) GetSerialNumber() (serial string, err error) {
+	if serial, err = c.Command("sn?"); err != nil {
+		return "", err
+	}
+	c.fdMu.Lock()
+	c.fd.SerialNumber = serial
+	c.fdMu.Unlock()
+	return serial, nil
+}
+
+func (c *Client) replyListener(conn *net.UDPConn) {
+	buff := make([]byte, 2048)
+	for {
+		n, err := conn.Read(buff)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			c.logf("Network Read Error - %v\n", err)
+			continue
+		}
+		select {
+		case c.replies <- strings.TrimSpace(string(buff[:n])):
+		default: // nobody is waiting, so we don't block
+		}
+	}
+}
+
+func (c *Client) stateListener(conn *net.UDPConn) {
+	buff := make([]byte, 2048)
+	for {
+		n, err := conn.Read(buff)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			c.logf("State Read Error - %v\n", err)
+			continue
+		}
+		c.handleState(string(buff[:n]))
+	}
+}
+
+// handleState stores the contents of a state packet, and tells any mission pad listeners
+// when the detected pad, or its position relative to the drone, has changed.
+func (c *Client) handleState(state string) {
+	c.fdMu.Lock()
+	parseState(state, &c.fd)
+	mp, padChanged := tello.ParseMissionPadState(state)
+	if padChanged {
+		prev := c.fd.MissionPad
+		padChanged = mp.ID != prev.ID || mp.X != prev.X || mp.Y != prev.Y || mp.Z != prev.Z
+		c.fd.MissionPad = mp
+	}
+	c.stateUpdated = time.Now()
+	c.fdMu.Unlock()
+	if padChanged {
+		c.emitMissionPad(mp)
+	}
+}
+
+// parseState updates fd with the fields found in a text-SDK state packet.
+func parseState(state string, fd *tello.FlightData) {
+	var templ, temph int
+	for _, kv := range strings.Split(strings.TrimSpace(state), ";") {
+		i := strings.IndexByte(kv, ':')
+		if i < 1 {
+			continue
+		}
+		key, val := kv[:i], kv[i+1:]
+		n, _ := strconv.Atoi(val)
+		switch key {
+		case "yaw":
+			fd.IMU.Yaw = float32(n)
+		case "vgx":
+			fd.MVO.VelocityX = int16(n)
+		case "vgy":
+			fd.MVO.VelocityY = int16(n)
+		case "vgz":
+			fd.MVO.VelocityZ = int16(n)
+		case "templ":
+			templ = n
+		case "temph":
+			temph = n
+		case "h":
+			fd.Height = int16(n / 10) // cm -> dm, as per the binary protocol
+			fd.Flying = n > 0
+			fd.OnGround = n == 0
+		case "tof":
+			fd.ToF = int16(n)
+		case "baro":
+			if f, err := strconv.ParseFloat(val, 32); err == nil {
+				fd.IMU.BaroAltitude = float32(f) / 100 // cm -> m
+			}
+		case "bat":
+			fd.BatteryPercentage = int8(n)
+		case "time":
+			fd.FlyTime = int16(n)
+		}
+	}
+	fd.IMU.Temperature = int16((templ + temph) / 2)
+}
+
+// keepAlive resends the stick values, and checks that state packets are arriving, until done is closed.
+func (c *Client) keepAlive(done <-chan struct{}) {
+	ticker := time.NewTicker(keepAlivePeriodMs * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		c.fdMu.RLock()
+		sinceLastState := time.Since(c.stateUpdated)
+		c.fdMu.RUnlock()
+		if sinceLastState >= stateTimeout {
+			c.logln("Seem to have lost contact")
+			c.logf("Last state was %v ago", sinceLastState)
+			c.ctrlMu.Lock()
+			c.ctrlConnected = false
+			c.ctrlMu.Unlock()
+			return
+		}
+		c.sendSticks()
+	}
+}
+
+// sendSticks sends the current stick values, unless a Command() is waiting for its reply.
+func (c *Client) sendSticks() {
+	c.ctrlMu.Lock()
+	defer c.ctrlMu.Unlock()
+	if c.ctrlConn == nil || c.ctrlAwaiting {
+		return
+	}
+	rc := "rc " + stickToRC(c.ctrlRx) + " " + stickToRC(c.ctrlRy) + " " +
+		stickToRC(c.ctrlLy) + " " + stickToRC(c.ctrlLx)
+	c.ctrlConn.Write([]byte(rc))
+	c.ctrlLastRC = time.Now()
+}
+
+// logf logs via the Client's Logger, or the standard logger if none is set.
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// logln logs via the Client's Logger, or the standard logger if none is set.
+func (c *Client) logln(v ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Println(v...)
+		return
+	}
+	log.Println(v...)
+}
+
+// stickToRC converts an SDL-convention stick value to the -100 to 100 range used by the rc command.
+func stickToRC(sv int16) string {
+	return strconv.Itoa(int(sv) * 100 / 32767)
+}
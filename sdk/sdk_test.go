@@ -0,0 +1,192 @@
+// tello project sdk_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sdk
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello"
+)
+
+// textDrone starts a fake text-SDK drone which answers "sn?" with its serial number, and everything
+// else, including rc, with "ok" after delay.  It returns the drone's port.
+func textDrone(t *testing.T, delay time.Duration) int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buff := make([]byte, 1024)
+		for {
+			n, from, err := conn.ReadFromUDP(buff)
+			if err != nil {
+				return
+			}
+			reply := "ok"
+			if string(buff[:n]) == "sn?" {
+				reply = "0TQDG7REDB0N8X"
+			}
+			time.AfterFunc(delay, func() { conn.WriteToUDP([]byte(reply), from) })
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestCommandIgnoresRCReplies(t *testing.T) {
+	port := textDrone(t, 10*time.Millisecond)
+	var c Client
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.ControlConnect("127.0.0.1", port)
+		}()
+	}
+	wg.Wait()
+	defer c.ControlDisconnect()
+	ok, refused := <-errs, <-errs
+	if ok != nil {
+		ok, refused = refused, ok
+	}
+	if ok != nil || !errors.Is(refused, tello.ErrConnecting) && !errors.Is(refused, tello.ErrAlreadyConnected) {
+		t.Fatalf("Expected one connection to succeed and the other to be refused, got %v and %v", ok, refused)
+	}
+
+	for i := 0; i < 10; i++ {
+		c.UpdateSticks(tello.StickMessage{Rx: int16(i * 1000)}) // the rc is answered after the serial number query is sent
+		sn, err := c.GetSerialNumber()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(sn, "0TQ") {
+			t.Fatalf("Expected the serial number, got the reply %q", sn)
+		}
+	}
+	if sn := c.GetFlightData().SerialNumber; sn != "0TQDG7REDB0N8X" {
+		t.Errorf("Expected the serial number in the flight data, got %q", sn)
+	}
+}
+
+func TestKeepAliveStops(t *testing.T) {
+	c := &Client{stateUpdated: time.Now(), ctrlConnected: true}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		c.keepAlive(done)
+		close(stopped)
+	}()
+	close(done) // as ControlDisconnect() does, even if a new connection has been made since
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("Expected the keepalive to stop")
+	}
+}
+
+func TestParseState(t *testing.T) {
+	var fd tello.FlightData
+	parseState("pitch:0;roll:0;yaw:-45;vgx:3;vgy:0;vgz:-1;templ:60;temph:62;tof:95;h:90;bat:77;baro:12.34;time:15;agx:0.00;agy:0.00;agz:-1000.00;\r\n", &fd)
+	if fd.IMU.Yaw != -45 {
+		t.Errorf("Expected yaw -45, got %f", fd.IMU.Yaw)
+	}
+	if fd.Height != 9 || !fd.Flying {
+		t.Errorf("Expected to be flying at 9dm, got %d", fd.Height)
+	}
+	if fd.ToF != 95 || fd.IMU.BaroAltitude < 0.1233 || fd.IMU.BaroAltitude > 0.1235 {
+		t.Errorf("Expected ToF 95cm and baro 0.1234m, got %d and %f", fd.ToF, fd.IMU.BaroAltitude)
+	}
+	if fd.BatteryPercentage != 77 {
+		t.Errorf("Expected battery 77%%, got %d", fd.BatteryPercentage)
+	}
+	if fd.IMU.Temperature != 61 {
+		t.Errorf("Expected temperature 61, got %d", fd.IMU.Temperature)
+	}
+	if fd.MVO.VelocityX != 3 || fd.MVO.VelocityZ != -1 {
+		t.Errorf("Unexpected velocities %+v", fd.MVO)
+	}
+}
+
+func TestMissionPadChanges(t *testing.T) {
+	c := new(Client)
+	pads, stop := c.ListenMissionPads()
+	defer stop()
+
+	c.handleState("mid:3;x:-12;y:40;z:95;mpry:1,-2,87;pitch:0;roll:0;yaw:87;\r\n")
+	c.handleState("mid:3;x:-12;y:40;z:95;mpry:1,-2,88;pitch:0;roll:0;yaw:88;\r\n") // only the attitude changed
+	c.handleState("mid:3;x:-10;y:40;z:95;mpry:1,-2,88;pitch:0;roll:0;yaw:88;\r\n")
+	c.handleState("mid:-1;x:-100;y:-100;z:-100;mpry:0,0,0;pitch:0;roll:0;yaw:88;\r\n")
+	c.handleState("pitch:0;roll:0;yaw:88;\r\n") // not an EDU
+
+	for _, want := range []tello.MissionPad{{ID: 3, X: -12}, {ID: 3, X: -10}, {ID: tello.MissionPadNone}} {
+		select {
+		case mp := <-pads:
+			if mp.ID != want.ID || mp.X != want.X {
+				t.Errorf("Expected pad %d at x %d, got %+v", want.ID, want.X, mp)
+			}
+		default:
+			t.Fatalf("Expected a change to pad %d at x %d", want.ID, want.X)
+		}
+	}
+	select {
+	case mp := <-pads:
+		t.Errorf("Expected no more changes, got %+v", mp)
+	default:
+	}
+	if c.GetFlightData().MissionPad.ID != tello.MissionPadNone {
+		t.Error("Expected the lost pad in the flight data")
+	}
+}
+
+func TestStickToRC(t *testing.T) {
+	if r := stickToRC(0); r != "0" {
+		t.Errorf("Expected 0, got %s", r)
+	}
+	if r := stickToRC(32767); r != "100" {
+		t.Errorf("Expected 100, got %s", r)
+	}
+	if r := stickToRC(-32767); r != "-100" {
+		t.Errorf("Expected -100, got %s", r)
+	}
+}
+
+func TestMatrixPattern(t *testing.T) {
+	var rows [8][8]MatrixColour
+	for r := range rows {
+		for c := range rows[r] {
+			rows[r][c] = MatrixOff
+		}
+	}
+	rows[0][0] = MatrixRed
+	rows[7][7] = MatrixBlue
+	p := matrixPattern(rows)
+	if len(p) != 64 || p[0] != 'r' || p[63] != 'b' || p[1] != '0' {
+		t.Errorf("Unexpected matrix pattern %s", p)
+	}
+}
@@ -0,0 +1,85 @@
+// video.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sdk
+
+import (
+	"net"
+	"strconv"
+)
+
+// VideoConnect asks the Tello to start streaming and starts a listener on the given local port.
+// A channel of raw H.264 video data is returned along with any error.
+// The channel will be closed if the connection is lost.
+func (c *Client) VideoConnect(localUDPPort int) (<-chan []byte, error) {
+	localAddr, err := net.ResolveUDPAddr("udp", ":"+strconv.Itoa(localUDPPort))
+	if err != nil {
+		return nil, err
+	}
+	videoConn, err := net.ListenUDP("udp", localAddr)
+	if err != nil {
+		c.logf("Error: VideoConnect - ListenUDP failed with %v\n", err)
+		return nil, err
+	}
+	if _, err = c.Command("streamon"); err != nil {
+		videoConn.Close()
+		return nil, err
+	}
+	c.ctrlMu.Lock()
+	c.videoConn = videoConn
+	c.videoChan = make(chan []byte, 100)
+	videoChan := c.videoChan
+	c.ctrlMu.Unlock()
+	go c.videoListener(videoConn, videoChan)
+	return videoChan, nil
+}
+
+// VideoConnectDefault asks the Tello to start streaming to the default SDK video port.
+func (c *Client) VideoConnectDefault() (<-chan []byte, error) {
+	return c.VideoConnect(defaultLocalVideoPort)
+}
+
+// VideoDisconnect asks the Tello to stop streaming and closes the video connection.
+func (c *Client) VideoDisconnect() {
+	c.sendLogged("streamoff")
+	c.ctrlMu.Lock()
+	if c.videoConn != nil {
+		c.videoConn.Close()
+		c.videoConn = nil
+	}
+	c.ctrlMu.Unlock()
+}
+
+func (c *Client) videoListener(conn *net.UDPConn, videoChan chan []byte) {
+	for {
+		vbuf := make([]byte, 2048)
+		n, _, err := conn.ReadFromUDP(vbuf)
+		if err != nil {
+			c.logln("Info: Closing Video Channel")
+			close(videoChan)
+			return
+		}
+		select {
+		case videoChan <- vbuf[:n]:
+		default: // so we don't block
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// signals.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// signalLandTimeout limits how long HandleSignals() waits for the landing to be acknowledged.
+const signalLandTimeout = 3 * time.Second
+
+// HandleSignals installs SIGINT (Ctrl-C) and SIGTERM handlers for CLI programs: on the first signal
+// the Tello is told to Hover() and then to land if airborne, everything is closed down via Close() and
+// the program exits with status 1.  A second signal exits immediately.
+// Without this, interrupting a script leaves the drone flying until its own failsafe lands it.
+// Call the returned func to remove the handlers again.
+func HandleSignals(tello *Tello) (stop func()) {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go tello.signalHandler(sigs, done, os.Exit)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+		})
+	}
+}
+
+// signalHandler waits for a signal on sigs, then lands, closes down and calls exit.
+func (tello *Tello) signalHandler(sigs <-chan os.Signal, done <-chan struct{}, exit func(int)) {
+	select {
+	case <-done:
+		return
+	case sig := <-sigs:
+		tello.logf("Received %v, landing and closing down\n", sig)
+	}
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		ctx, cancel := context.WithTimeout(context.Background(), signalLandTimeout)
+		defer cancel()
+		tello.Hover()
+		if tello.ControlConnected() && tello.GetFlightState().IsAirborne() {
+			if err := tello.LandAndWait(ctx); err != nil {
+				tello.logf("Error: landing failed - %v\n", err)
+			}
+		}
+		tello.Close(ctx)
+	}()
+	select {
+	case <-closed:
+	case sig := <-sigs:
+		tello.logf("Received %v again, exiting now\n", sig)
+	}
+	exit(1)
+}
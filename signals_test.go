@@ -0,0 +1,87 @@
+// signals_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello/sim"
+)
+
+func TestSignalHandlerLands(t *testing.T) {
+	drone, s := simDrone(t)
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	if err := drone.TakeOffAndWait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !drone.GetFlightState().IsAirborne() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !drone.GetFlightState().IsAirborne() {
+		t.Fatal("Never took off")
+	}
+
+	sigs := make(chan os.Signal, 2)
+	exited := make(chan int, 1)
+	go drone.signalHandler(sigs, nil, func(code int) { exited <- code })
+	sigs <- os.Interrupt
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Errorf("Expected exit status 1, got %d", code)
+		}
+	case <-time.After(2 * signalLandTimeout):
+		t.Fatal("Handler did not exit")
+	}
+	if phase := s.State().Phase; phase != sim.Landing && phase != sim.Grounded {
+		t.Errorf("Expected the drone to be landing, phase is %v", phase)
+	}
+	if drone.ControlConnected() {
+		t.Error("Expected the handler to disconnect")
+	}
+}
+
+func TestHandleSignalsStop(t *testing.T) {
+	drone := NewTello()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		drone.signalHandler(make(chan os.Signal), done, func(int) { t.Error("Unexpected exit") })
+		close(stopped)
+	}()
+	close(done)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Handler did not stop")
+	}
+
+	stop := HandleSignals(drone)
+	stop()
+	stop() // harmless
+}
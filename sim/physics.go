@@ -0,0 +1,161 @@
+// physics.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+// flight model constants, chosen to feel roughly like a real Tello
+const (
+	slowSpeed        = 1.5                   // m/s horizontal speed at full stick
+	fastSpeed        = 3.5                   // m/s horizontal speed at full stick in sports mode
+	climbSpeed       = 1.0                   // m/s vertical speed at full stick
+	yawRate          = 90                    // degrees/s at full stick
+	maxTilt          = 25                    // degrees of pitch or roll at fastSpeed
+	velocityLag      = 0.4                   // s, time constant of the response to the sticks
+	takeoffHeight    = 0.8                   // m, where the drone stops after taking off
+	takeoffSpeed     = 0.8                   // m/s
+	takeoffTolerance = 0.05                  // m, how close to takeoffHeight counts as arrived
+	landingSpeed     = 0.5                   // m/s
+	flightDrain      = 100.0 / (13 * 60)     // percent per second while the motors are running
+	idleDrain        = 100.0 / (3 * 60 * 60) // percent per second while sitting on the ground
+	criticalBattery  = 3                     // percent, below which the drone lands by itself
+	hoverThreshold   = 0.05                  // m/s, below which the drone reports that it is hovering
+)
+
+// Phase is the simulated drone's flight phase.
+type Phase int
+
+// Flight phases...
+const (
+	Grounded Phase = iota
+	TakingOff
+	Flying
+	Landing
+)
+
+// State is a snapshot of the simulated drone.
+// Positions are relative to where the drone was switched on: Y points along the initial heading,
+// X to its right and Z up, which matches the client's MVO and autopilot conventions.
+type State struct {
+	Phase       Phase
+	X, Y, Z     float64       // metres
+	VX, VY, VZ  float64       // metres per second
+	Yaw         float64       // degrees clockwise from the initial heading, -180..180
+	Pitch, Roll float64       // degrees, positive when nose up and right side down
+	Battery     float64       // percent
+	FlyTime     time.Duration // time spent with the motors running
+}
+
+// Airborne returns true if the motors are running.
+func (st State) Airborne() bool {
+	return st.Phase != Grounded
+}
+
+// Hovering returns true if the drone is flying but not moving.
+func (st State) Hovering() bool {
+	return st.Phase == Flying && math.Abs(st.VX) < hoverThreshold && math.Abs(st.VY) < hoverThreshold &&
+		math.Abs(st.VZ) < hoverThreshold
+}
+
+// advance moves the simulation on by dt seconds with the given stick positions.
+func (st *State) advance(dt float64, in sticks, maxHeight float64) {
+	if st.Phase == Grounded {
+		st.Battery = math.Max(0, st.Battery-idleDrain*dt)
+		st.VX, st.VY, st.VZ, st.Pitch, st.Roll = 0, 0, 0, 0, 0
+		return
+	}
+	st.FlyTime += time.Duration(dt * float64(time.Second))
+	st.Battery = math.Max(0, st.Battery-flightDrain*dt)
+	if st.Battery < criticalBattery && st.Phase != Landing {
+		st.Phase = Landing
+	}
+
+	// work out the velocities we are aiming for in the body frame
+	var right, forward, up, turn float64
+	switch st.Phase {
+	case TakingOff:
+		up = math.Min(takeoffSpeed, (takeoffHeight-st.Z)/velocityLag) // slow down as we arrive
+	case Landing:
+		up = -landingSpeed
+	case Flying:
+		speed := slowSpeed
+		if in.fast {
+			speed = fastSpeed
+		}
+		right, forward, up, turn = in.rx*speed, in.ry*speed, in.ly*climbSpeed, in.lx*yawRate
+	}
+
+	// ...rotate them into the world frame and let the drone catch up
+	yaw := st.Yaw * math.Pi / 180
+	sin, cos := math.Sin(yaw), math.Cos(yaw)
+	k := 1 - math.Exp(-dt/velocityLag)
+	st.VX += (cos*right + sin*forward - st.VX) * k
+	st.VY += (-sin*right + cos*forward - st.VY) * k
+	st.VZ += (up - st.VZ) * k
+
+	st.X += st.VX * dt
+	st.Y += st.VY * dt
+	st.Z += st.VZ * dt
+	st.Yaw = wrapDegrees(st.Yaw + turn*dt)
+
+	// the drone tilts into the direction it is moving
+	st.Pitch = -(sin*st.VX + cos*st.VY) / fastSpeed * maxTilt
+	st.Roll = (cos*st.VX - sin*st.VY) / fastSpeed * maxTilt
+
+	switch {
+	case st.Z > maxHeight:
+		st.Z, st.VZ = maxHeight, math.Min(st.VZ, 0)
+	case st.Phase == TakingOff && st.Z >= takeoffHeight-takeoffTolerance:
+		st.Phase = Flying
+	case st.Z <= 0 && st.Phase == Landing:
+		st.Phase = Grounded
+		st.Z, st.VX, st.VY, st.VZ, st.Pitch, st.Roll = 0, 0, 0, 0, 0, 0
+	case st.Z < 0:
+		st.Z, st.VZ = 0, math.Max(st.VZ, 0)
+	}
+}
+
+func wrapDegrees(deg float64) float64 {
+	deg = math.Mod(deg+180, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg - 180
+}
+
+// quaternion returns the drone's attitude as a unit quaternion, in the form sent by the IMU.
+func (st State) quaternion() (w, x, y, z float64) {
+	halfYaw := st.Yaw * math.Pi / 360
+	halfPitch := st.Pitch * math.Pi / 360
+	halfRoll := st.Roll * math.Pi / 360
+	cy, sy := math.Cos(halfYaw), math.Sin(halfYaw)
+	cp, sp := math.Cos(halfPitch), math.Sin(halfPitch)
+	cr, sr := math.Cos(halfRoll), math.Sin(halfRoll)
+	w = cr*cp*cy + sr*sp*sy
+	x = sr*cp*cy - cr*sp*sy
+	y = cr*sp*cy + sr*cp*sy
+	z = cr*cp*sy - sr*sp*cy
+	return w, x, y, z
+}
@@ -0,0 +1,452 @@
+// sim.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+/*
+Package sim provides a simulated Tello for developing and testing programs without a drone.
+
+A Drone listens on a UDP port and speaks the same binary protocol as a real Tello, so the
+main tello package can be pointed at it instead, eg.
+
+	d := sim.New(sim.WithVideo())
+	d.Listen("127.0.0.1:8889")
+	defer d.Close()
+	drone := tello.NewTello(tello.WithAddress("127.0.0.1", 8889))
+	drone.ControlConnectDefault()
+
+The drone takes off, lands and responds to the sticks with a simple flight model, drains its
+battery as it flies and sends flight status, wifi, light strength and MVO/IMU flight log
+messages much like the real thing.  If video is enabled a synthetic H.264 test pattern is
+streamed to the client's video port once it asks for it, eg. with GetVideoSpsPps().
+
+The flight model is deliberately simple: there is no wind, no drift, no obstacles and no
+ground effect, so code which works here may still need tuning on a real drone.
+*/
+package sim
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTickPeriod = 100 * time.Millisecond // how often the simulation is stepped and status sent
+	lostLinkTimeout   = 15 * time.Second       // how long without hearing from the client before landing
+	wifiEvery         = 5                      // send wifi and light strength every this many ticks
+	logHeaderEvery    = 5                      // repeat the log header every this many ticks until it is acknowledged
+	simLoaderVersion  = "01.01.02.00"          // the boot loader version reported
+)
+
+// Drone is a simulated Tello.
+type Drone struct {
+	tickPeriod time.Duration
+	video      bool
+	ssid       string
+	version    string
+	region     string
+	activated  time.Time
+	strictSeq  bool
+	logAckLoss int // the number of log header acks to ignore
+	clock      Clock
+
+	mu            sync.Mutex // protects all the fields below
+	conn          *net.UDPConn
+	client        *net.UDPAddr // where we send control traffic, set by the connection request
+	videoAddr     *net.UDPAddr
+	streaming     bool
+	muted         bool
+	lastHeard     time.Time
+	in            sticks
+	state         State
+	maxHeight     uint8
+	lowBattThresh uint8
+	bitrate       byte
+	seq           uint16 // sequence number of packets we originate
+	cmdSeq        uint16 // the latest command sequence number accepted, when strictSeq is set
+	cmdSeqSeen    bool
+	logID         uint16 // the ID of the log header, which the client must acknowledge
+	logAcked      bool   // has the client acknowledged the log header? log data is only sent once it has
+	done          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// Clock is the source of time for the simulation, it has the same methods as tello.Clock so
+// that the drone and the client can share a virtual clock in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Option is a functional option for New().
+type Option func(*Drone)
+
+// WithVideo makes the Drone stream a synthetic test pattern when the client asks for video.
+func WithVideo() Option {
+	return func(d *Drone) { d.video = true }
+}
+
+// WithTickPeriod sets how often the simulation is stepped and flight status is sent, the default is 100ms.
+func WithTickPeriod(period time.Duration) Option {
+	return func(d *Drone) { d.tickPeriod = period }
+}
+
+// WithClock sets the Clock which drives the simulation, the default is the system clock.
+func WithClock(clock Clock) Option {
+	return func(d *Drone) { d.clock = clock }
+}
+
+// WithSSID sets the wifi network name the Drone reports.
+func WithSSID(ssid string) Option {
+	return func(d *Drone) { d.ssid = ssid }
+}
+
+// WithLogAckLoss makes the Drone ignore the first n acknowledgements of its log header, to
+// exercise the client's handling of the unreliable log data handshake.
+func WithLogAckLoss(n int) Option {
+	return func(d *Drone) { d.logAckLoss = n }
+}
+
+// WithStrictSequence makes the Drone ignore commands whose sequence number is lower than that of
+// the latest command it accepted, even from a new connection, as some firmware versions do.
+func WithStrictSequence() Option {
+	return func(d *Drone) { d.strictSeq = true }
+}
+
+// WithFirmwareVersion sets the firmware version the Drone reports.
+func WithFirmwareVersion(version string) Option {
+	return func(d *Drone) { d.version = version }
+}
+
+// WithWifiRegion sets the WiFi country code the Drone reports.
+func WithWifiRegion(region string) Option {
+	return func(d *Drone) { d.region = region }
+}
+
+// WithActivationTime sets when the Drone reports it was activated, a zero time means never.
+func WithActivationTime(t time.Time) Option {
+	return func(d *Drone) { d.activated = t }
+}
+
+// WithBattery sets the Drone's initial battery level in percent, the default is a full battery.
+func WithBattery(percent float64) Option {
+	return func(d *Drone) { d.state.Battery = percent }
+}
+
+// New returns a simulated drone sitting on the ground, call Listen() to start it.
+func New(opts ...Option) *Drone {
+	d := &Drone{
+		tickPeriod:    defaultTickPeriod,
+		clock:         systemClock{},
+		ssid:          "TELLO-SIM",
+		version:       "01.04.92.01",
+		region:        "US",
+		activated:     time.Unix(1540000000, 0),
+		maxHeight:     10,
+		lowBattThresh: 10,
+	}
+	d.state.Battery = 100
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.tickPeriod <= 0 {
+		d.tickPeriod = defaultTickPeriod
+	}
+	if d.clock == nil {
+		d.clock = systemClock{}
+	}
+	return d
+}
+
+// Listen starts the simulation with the control port bound to addr, eg. "127.0.0.1:8889".
+// An addr with port 0 picks a free port, which Addr() then returns.
+func (d *Drone) Listen(addr string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn != nil {
+		return errors.New("Simulator already listening")
+	}
+	d.conn, err = net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+	d.done = make(chan struct{})
+	d.wg.Add(2)
+	go d.receiver(d.conn)
+	go d.ticker(d.done)
+	if d.video {
+		d.wg.Add(1)
+		go d.videoStreamer(d.done)
+	}
+	return nil
+}
+
+// Addr returns the address of the simulated control port, or nil if not listening.
+func (d *Drone) Addr() *net.UDPAddr {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn == nil {
+		return nil
+	}
+	return d.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Close stops the simulation.
+// It is safe to call Close at any time, even if not listening.
+func (d *Drone) Close() error {
+	d.mu.Lock()
+	conn, done := d.conn, d.done
+	d.conn, d.done, d.client, d.videoAddr, d.streaming = nil, nil, nil, nil, false
+	d.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	close(done)
+	err := conn.Close()
+	d.wg.Wait()
+	return err
+}
+
+// State returns a snapshot of the simulated drone.
+func (d *Drone) State() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state
+}
+
+// SetBattery changes the battery level in percent, eg. to exercise low battery handling.
+func (d *Drone) SetBattery(percent float64) {
+	d.mu.Lock()
+	d.state.Battery = percent
+	d.mu.Unlock()
+}
+
+// SetMuted makes the drone behave as if it were out of range: while muted it ignores everything
+// from the client and sends nothing back.  It keeps flying, and lands by itself if the link is
+// down for too long.
+func (d *Drone) SetMuted(muted bool) {
+	d.mu.Lock()
+	d.muted = muted
+	d.mu.Unlock()
+}
+
+func (d *Drone) receiver(conn *net.UDPConn) {
+	defer d.wg.Done()
+	buff := make([]byte, 2048)
+	for {
+		n, from, err := conn.ReadFromUDP(buff)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		d.handle(conn, from, buff[:n])
+	}
+}
+
+// handle processes one message from the client, replying if necessary.
+func (d *Drone) handle(conn *net.UDPConn, from *net.UDPAddr, msg []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.muted {
+		return
+	}
+	d.lastHeard = d.clock.Now()
+
+	// the connection request carries the port the client wants the video sent to
+	if bytes.HasPrefix(msg, []byte("conn_req:")) && len(msg) == 11 {
+		d.client = from
+		d.videoAddr = &net.UDPAddr{IP: from.IP, Port: int(msg[9]) | int(msg[10])<<8, Zone: from.Zone}
+		d.streaming = false
+		d.logID++
+		d.logAcked = false
+		conn.WriteToUDP(append([]byte("conn_ack:"), msg[9:11]...), from)
+		return
+	}
+	pkt, ok := decodePacket(msg)
+	if !ok {
+		return
+	}
+	if d.strictSeq && pkt.sequence != 0 && (pkt.packetType == ptSet || pkt.packetType == ptGet) {
+		if d.cmdSeqSeen && int16(pkt.sequence-d.cmdSeq) < 0 {
+			return // the sequence has regressed
+		}
+		d.cmdSeq, d.cmdSeqSeen = pkt.sequence, true
+	}
+	d.client = from
+
+	reply := func(payload ...byte) {
+		conn.WriteToUDP(encodePacket(ptData1, pkt.messageID, pkt.sequence, payload), from)
+	}
+	switch pkt.messageID {
+	case msgLogHeader:
+		if len(pkt.payload) < 3 || uint16(pkt.payload[1])|uint16(pkt.payload[2])<<8 != d.logID {
+			break
+		}
+		if d.logAckLoss > 0 {
+			d.logAckLoss--
+			break
+		}
+		d.logAcked = true
+	case msgSetStick:
+		d.in = decodeSticks(pkt.payload)
+	case msgDoTakeoff, msgDoThrowTakeoff:
+		if d.state.Phase != Grounded {
+			reply(0)
+			break
+		}
+		if d.state.Battery < criticalBattery {
+			reply(1) // refused
+			break
+		}
+		d.state.Phase = TakingOff
+		d.in = sticks{}
+		reply(0)
+	case msgDoLand, msgDoPalmLand:
+		stopping := len(pkt.payload) > 0 && pkt.payload[0] == 1
+		switch {
+		case stopping && d.state.Phase == Landing && d.state.Battery >= criticalBattery:
+			d.state.Phase = Flying
+		case !stopping && d.state.Airborne():
+			d.state.Phase = Landing
+		}
+		reply(0)
+	case msgDoFlip, msgDoBounce, msgDoSmartVideo:
+		reply(0)
+	case msgQueryVideoSPSPPS:
+		d.streaming = d.video
+	case msgSetVideoBitrate:
+		if len(pkt.payload) > 0 {
+			d.bitrate = pkt.payload[0]
+		}
+		reply(0)
+	case msgQueryVideoBitrate:
+		reply(d.bitrate)
+	case msgQueryVersion:
+		reply(append([]byte{0}, d.version...)...)
+	case msgQueryLoaderVersion:
+		reply(append([]byte{0}, simLoaderVersion...)...)
+	case msgQuerySSID:
+		reply(append([]byte{0, 0}, d.ssid...)...)
+	case msgQueryWifiRegion:
+		reply(append([]byte{0}, d.region...)...)
+	case msgQueryActivationTime:
+		var secs uint32
+		if !d.activated.IsZero() {
+			secs = uint32(d.activated.Unix())
+		}
+		reply(0, byte(secs), byte(secs>>8), byte(secs>>16), byte(secs>>24))
+	case msgSetHeightLimit:
+		if len(pkt.payload) > 0 {
+			d.maxHeight = pkt.payload[0]
+		}
+		reply(0)
+	case msgQueryHeightLimit:
+		reply(0, d.maxHeight, 0)
+	case msgSetLowBattThresh:
+		if len(pkt.payload) > 0 {
+			d.lowBattThresh = pkt.payload[0]
+		}
+		reply(0)
+	case msgQueryLowBattThresh:
+		reply(0, d.lowBattThresh)
+	}
+}
+
+// ticker steps the simulation and sends the regular telemetry messages.
+func (d *Drone) ticker(done <-chan struct{}) {
+	defer d.wg.Done()
+	last := d.clock.Now()
+	for tick := 0; ; tick++ {
+		select {
+		case <-done:
+			return
+		case <-d.clock.After(d.tickPeriod):
+			now := d.clock.Now()
+			d.step(now.Sub(last), tick)
+			last = now
+		}
+	}
+}
+
+// step advances the simulation by dt and sends telemetry to the client, if there is one.
+func (d *Drone) step(dt time.Duration, tick int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.state.Airborne() && d.state.Phase != Landing && d.clock.Now().Sub(d.lastHeard) > lostLinkTimeout {
+		d.state.Phase = Landing
+	}
+	d.state.advance(dt.Seconds(), d.in, float64(d.maxHeight))
+	if d.client == nil || d.muted {
+		return
+	}
+	d.send(msgFlightStatus, flightStatus(d.state, d.lowBattThresh))
+	if d.logAcked {
+		d.send(msgLogData, flightLog(d.state, byte(tick)))
+	} else if tick%logHeaderEvery == 0 {
+		d.send(msgLogHeader, append([]byte{byte(d.logID), byte(d.logID >> 8)}, "SIM LOG"...))
+	}
+	if tick%wifiEvery == 0 {
+		d.send(msgWifiStrength, []byte{90, 0})
+		d.send(msgLightStrength, []byte{0})
+	}
+}
+
+// send transmits a drone-originated packet to the client, d.mu must be held.
+func (d *Drone) send(messageID uint16, payload []byte) {
+	d.seq++
+	d.conn.WriteToUDP(encodePacket(ptData2, messageID, d.seq, payload), d.client)
+}
+
+// videoStreamer sends test pattern frames to the client while it is streaming.
+func (d *Drone) videoStreamer(done <-chan struct{}) {
+	defer d.wg.Done()
+	for n := 0; ; {
+		select {
+		case <-done:
+			return
+		case <-d.clock.After(time.Second / videoFrameRate):
+		}
+		d.mu.Lock()
+		conn, addr, streaming, yaw := d.conn, d.videoAddr, d.streaming && !d.muted, d.state.Yaw
+		d.mu.Unlock()
+		if !streaming || addr == nil {
+			continue
+		}
+		// turning right moves the scene left across the picture
+		shift := int(yaw / 60 * videoWidthMbs * 16)
+		for _, pkt := range videoPackets(byte(n), testPatternFrame(n, shift)) {
+			conn.WriteToUDP(pkt, addr)
+		}
+		n++
+	}
+}
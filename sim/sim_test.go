@@ -0,0 +1,270 @@
+// sim_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sim
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello"
+	"github.com/SMerrony/tello/protocol"
+)
+
+// waitFor polls cond until it is true or the timeout expires.
+func waitFor(t *testing.T, what string, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func freeUDPPort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestFlyWithClient(t *testing.T) {
+	d := New(WithTickPeriod(20*time.Millisecond), WithVideo())
+	if err := d.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	drone := tello.NewTello(tello.WithAddress("127.0.0.1", d.Addr().Port), tello.WithLocalControlPort(freeUDPPort(t)),
+		tello.WithVideoPort(freeUDPPort(t)))
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	defer drone.ControlDisconnect()
+
+	waitFor(t, "flight status", time.Second, func() bool { return drone.GetFlightData().BatteryPercentage == 100 })
+	waitFor(t, "device info", time.Second, func() bool {
+		fd := drone.GetFlightData()
+		return fd.WifiRegion == "US" && fd.ActivationTime.Equal(time.Unix(1540000000, 0))
+	})
+	if fs := drone.GetFlightState(); fs != tello.StateGrounded {
+		t.Errorf("Expected to be grounded, got %v", fs)
+	}
+
+	if err := drone.TakeOffAndWait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "takeoff", 3*time.Second, func() bool { return drone.GetFlightState() == tello.StateHovering })
+	if h := drone.GetFlightData().Height; h < 7 || h > 9 {
+		t.Errorf("Expected to hover at 8dm, got %d", h)
+	}
+
+	drone.Forward(100)
+	waitFor(t, "forward flight", 3*time.Second, func() bool { return drone.GetFlightData().MVO.PositionY > 1 })
+	if fd := drone.GetFlightData(); fd.NorthSpeed <= 0 || fd.GroundSpeed <= 0 {
+		t.Errorf("Expected northward ground speed, got north %d ground %d", fd.NorthSpeed, fd.GroundSpeed)
+	}
+	drone.Clockwise(100)
+	waitFor(t, "turn", 3*time.Second, func() bool { return drone.GetFlightData().IMU.Yaw > 45 })
+	drone.Hover()
+
+	vc, err := drone.VideoConnectDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	drone.GetVideoSpsPps()
+	select {
+	case frame := <-vc:
+		if !bytes.HasPrefix(frame, []byte{0, 0, 0, 1, 0x67}) {
+			t.Errorf("Expected video to start with an SPS, got % x", frame[:8])
+		}
+	case <-time.After(time.Second):
+		t.Error("No video received")
+	}
+
+	if err := drone.LandAndWait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "landing", 5*time.Second, func() bool { return drone.GetFlightState() == tello.StateGrounded })
+	if st := d.State(); st.Phase != Grounded || st.Z != 0 || st.FlyTime == 0 || st.Battery >= 100 {
+		t.Errorf("Unexpected state after landing %+v", st)
+	}
+}
+
+func TestTakeoffRefusedOnFlatBattery(t *testing.T) {
+	d := New(WithBattery(2))
+	if err := d.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	drone := tello.NewTello(tello.WithAddress("127.0.0.1", d.Addr().Port), tello.WithLocalControlPort(freeUDPPort(t)))
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	defer drone.ControlDisconnect()
+	if err := drone.TakeOffAndWait(context.Background()); err == nil {
+		t.Error("Expected takeoff to be refused")
+	}
+	if d.State().Airborne() {
+		t.Error("Drone took off with a flat battery")
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	st := State{Phase: Flying, Z: 1, Yaw: 90, Battery: 50}
+	for i := 0; i < 50; i++ {
+		st.advance(0.1, sticks{ry: 1}, 10)
+	}
+	// facing right, so forward is +X
+	if st.X < 5 || math.Abs(st.Y) > 0.01 {
+		t.Errorf("Expected to fly along X, got %.2f,%.2f", st.X, st.Y)
+	}
+	if st.Pitch >= 0 {
+		t.Errorf("Expected nose down when flying forward, got %.1f", st.Pitch)
+	}
+	if want := 50 - 5*flightDrain; math.Abs(st.Battery-want) > 1e-9 {
+		t.Errorf("Expected battery %.3f, got %.3f", want, st.Battery)
+	}
+
+	st.advance(0.5, sticks{lx: 1}, 10)
+	if st.Yaw != 135 {
+		t.Errorf("Expected yaw 135, got %.1f", st.Yaw)
+	}
+
+	st.Phase = Landing
+	for i := 0; i < 30 && st.Phase == Landing; i++ {
+		st.advance(0.1, sticks{}, 10)
+	}
+	if st.Phase != Grounded || st.Z != 0 {
+		t.Errorf("Expected to have landed, got %v at %.2f", st.Phase, st.Z)
+	}
+}
+
+func TestDecodeSticks(t *testing.T) {
+	// full right stick, centred, half left stick down, full left stick left, sports mode
+	packed := uint64(1684) | uint64(1024)<<11 | uint64(694)<<22 | uint64(364)<<33 | 1<<44
+	pl := make([]byte, 11)
+	for i := 0; i < 6; i++ {
+		pl[i] = byte(packed >> (8 * i))
+	}
+	s := decodeSticks(pl)
+	if s.rx != 1 || s.ry != 0 || s.ly != -0.5 || s.lx != -1 || !s.fast {
+		t.Errorf("Unexpected sticks %+v", s)
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	buff := encodePacket(ptData1, msgQueryVersion, 42, []byte{0, 'v', '1'})
+	pkt, ok := decodePacket(buff)
+	if !ok || pkt.messageID != msgQueryVersion || pkt.sequence != 42 || string(pkt.payload) != "\x00v1" {
+		t.Errorf("Bad round trip %+v", pkt)
+	}
+	buff[9] ^= 0xff
+	if _, ok := decodePacket(buff); ok {
+		t.Error("Expected corrupt packet to be rejected")
+	}
+}
+
+func TestTestPattern(t *testing.T) {
+	frame := testPatternFrame(3, 0)
+	nals := bytes.Split(frame, []byte{0, 0, 0, 1})[1:]
+	if len(nals) != 3 || nals[0][0] != 0x67 || nals[1][0] != 0x68 || nals[2][0] != 0x65 {
+		t.Fatalf("Expected SPS, PPS and IDR NAL units")
+	}
+	for _, nal := range nals {
+		for i := 2; i < len(nal); i++ {
+			if nal[i-2] == 0 && nal[i-1] == 0 && nal[i] < 3 {
+				t.Fatalf("Start code emulation in NAL %x at %d", nal[0], i)
+			}
+		}
+	}
+	// every macroblock is at least 384 bytes of samples
+	if len(nals[2]) < videoWidthMbs*videoHeightMbs*384 {
+		t.Errorf("Picture too small, %d bytes", len(nals[2]))
+	}
+
+	pkts := videoPackets(7, frame)
+	var joined []byte
+	for i, pkt := range pkts {
+		last := i == len(pkts)-1
+		if pkt[0] != 7 || int(pkt[1]&0x7f) != i || (pkt[1]&0x80 != 0) != last || len(pkt) > videoChunkSize+2 {
+			t.Fatalf("Bad video packet header % x", pkt[:2])
+		}
+		joined = append(joined, pkt[2:]...)
+	}
+	if !bytes.Equal(joined, frame) {
+		t.Error("Video packets do not reassemble to the frame")
+	}
+}
+
+func TestBitWriter(t *testing.T) {
+	var bw bitWriter
+	bw.ue(0)  // 1
+	bw.ue(3)  // 00100
+	bw.se(-1) // 011
+	bw.trailing()
+	if !bytes.Equal(bw.buf, []byte{0x91, 0xc0}) {
+		t.Errorf("Got % x", bw.buf)
+	}
+}
+
+// the simulator's message IDs and payloads must agree with the protocol description
+func TestMessagesMatchProtocol(t *testing.T) {
+	described := map[uint16]protocol.MessageDescription{}
+	for _, m := range protocol.Describe().Messages {
+		described[m.ID] = m
+	}
+	for name, id := range map[string]uint16{
+		"QuerySSID": msgQuerySSID, "QueryWifiRegion": msgQueryWifiRegion, "WifiStrength": msgWifiStrength,
+		"SetVideoBitrate": msgSetVideoBitrate, "QueryVideoSPSPPS": msgQueryVideoSPSPPS,
+		"QueryVideoBitrate": msgQueryVideoBitrate, "LightStrength": msgLightStrength, "QueryVersion": msgQueryVersion,
+		"QueryActivationTime": msgQueryActivationTime, "SetStick": msgSetStick, "DoTakeoff": msgDoTakeoff,
+		"DoLand": msgDoLand, "FlightStatus": msgFlightStatus, "SetHeightLimit": msgSetHeightLimit, "DoFlip": msgDoFlip,
+		"DoThrowTakeoff": msgDoThrowTakeoff, "DoPalmLand": msgDoPalmLand, "DoSmartVideo": msgDoSmartVideo,
+		"LogHeader": msgLogHeader, "LogData": msgLogData, "DoBounce": msgDoBounce,
+		"SetLowBattThresh": msgSetLowBattThresh, "QueryHeightLimit": msgQueryHeightLimit,
+		"QueryLowBattThresh": msgQueryLowBattThresh,
+	} {
+		if m, ok := described[id]; !ok || m.Name != name {
+			t.Errorf("Message %#04x is %q in the protocol description, the simulator has %s", id, m.Name, name)
+		}
+	}
+
+	var st State
+	st.Battery = 80
+	for id, payload := range map[uint16][]byte{
+		msgFlightStatus: flightStatus(st, 10),
+		msgLogData:      flightLog(st, 7),
+	} {
+		if _, err := protocol.Decode(protocol.Packet{FromDrone: true, MessageID: id, Payload: payload}); err != nil {
+			t.Errorf("%s payload does not match its protocol layout - %v", protocol.Name(id), err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// telemetry.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sim
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// flight log record types and the validity flags of an MVO record
+const (
+	logRecordSeparator = 'U'
+	logRecMVO          = 0x001d
+	logRecIMU          = 0x0800
+	logValidAll        = 0x77 // velocities and positions
+)
+
+const (
+	mvoRecordLen = 90
+	imuRecordLen = 120
+)
+
+// flightStatus builds the payload of a msgFlightStatus packet.
+func flightStatus(st State, lowBattThresh uint8) []byte {
+	pl := make([]byte, 24)
+	putInt16(pl[0:], math.Round(st.Z*10))
+	putInt16(pl[2:], math.Round(st.VY*10)) // north
+	putInt16(pl[4:], math.Round(st.VX*10)) // east
+	putInt16(pl[6:], math.Round(-st.VZ*10))
+	putInt16(pl[8:], math.Round(st.FlyTime.Seconds()*10))
+
+	pl[10] = 1 | 1<<1 | 1<<3 | 1<<4 // IMU, pressure sensor, power and battery all OK
+	if st.Airborne() {
+		pl[10] |= 1 << 2 // down vision in use
+	}
+
+	pl[12] = byte(math.Ceil(st.Battery))
+	left := st.Battery / flightDrain
+	putInt16(pl[13:], left)
+	putInt16(pl[15:], 3500+st.Battery*7) // a single LiPo cell, roughly
+
+	switch {
+	case st.Phase == Grounded:
+		pl[17] |= 1 << 1 // on ground
+	case st.Hovering():
+		pl[17] |= 1 | 1<<2 | 1<<3 // flying, motors running, hovering
+	default:
+		pl[17] |= 1 | 1<<2 // flying, motors running
+	}
+	if st.Battery < float64(lowBattThresh) {
+		pl[17] |= 1 << 5
+	}
+	if st.Battery < criticalBattery {
+		pl[17] |= 1 << 6
+	}
+	pl[18] = 6 // fly mode
+	return pl
+}
+
+// flightLog builds the payload of a msgLogData packet holding an MVO and an IMU record.
+func flightLog(st State, key byte) []byte {
+	mvo := make([]byte, mvoRecordLen)
+	putInt16(mvo[12:], math.Round(st.VX*100))
+	putInt16(mvo[14:], math.Round(st.VY*100))
+	putInt16(mvo[16:], math.Round(-st.VZ*100))
+	putFloat32(mvo[18:], st.Y)
+	putFloat32(mvo[22:], st.X)
+	putFloat32(mvo[26:], st.Z)
+	mvo[86] = logValidAll
+
+	imu := make([]byte, imuRecordLen)
+	w, x, y, z := st.quaternion()
+	putFloat32(imu[54:], st.Z+baroOffset)
+	putFloat32(imu[58:], w)
+	putFloat32(imu[62:], x)
+	putFloat32(imu[66:], y)
+	putFloat32(imu[70:], z)
+	putInt16(imu[116:], imuTemperature*100)
+
+	pl := []byte{0}
+	pl = append(pl, logRecord(logRecMVO, mvo, key)...)
+	return append(pl, logRecord(logRecIMU, imu, key)...)
+}
+
+// baroOffset is the simulated air pressure altitude of the take-off point.
+const baroOffset = 112.5
+
+// imuTemperature is the simulated IMU temperature in degrees Celsius.
+const imuTemperature = 45
+
+// logRecord frames rec, whose first 10 bytes are reserved for the header, as a flight log record
+// obfuscated with key.
+func logRecord(recType uint16, rec []byte, key byte) []byte {
+	rec[0] = logRecordSeparator
+	binary.LittleEndian.PutUint16(rec[1:], uint16(len(rec)))
+	rec[3] = calculateCRC8(rec[0:3])
+	binary.LittleEndian.PutUint16(rec[4:], recType)
+	rec[6] = key
+	for i := 10; i < len(rec)-2; i++ {
+		rec[i] ^= key
+	}
+	crc := calculateCRC16(rec[:len(rec)-2])
+	binary.LittleEndian.PutUint16(rec[len(rec)-2:], crc)
+	return rec
+}
+
+func putInt16(b []byte, v float64) {
+	v = math.Max(math.MinInt16, math.Min(math.MaxInt16, v))
+	binary.LittleEndian.PutUint16(b, uint16(int16(v)))
+}
+
+func putFloat32(b []byte, v float64) {
+	binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)))
+}
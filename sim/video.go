@@ -0,0 +1,219 @@
+// video.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sim
+
+// The test pattern is encoded as H.264 Baseline I_PCM macroblocks, ie. uncompressed samples wrapped
+// in just enough syntax for a standard decoder to accept them.  Every frame is a self-contained IDR
+// picture preceded by its SPS and PPS, so a client can start decoding at any frame.
+
+const (
+	videoWidthMbs  = 20 // 320 pixels
+	videoHeightMbs = 15 // 240 pixels
+	videoFrameRate = 10
+	videoChunkSize = 1460 // the largest video payload the Tello sends in one UDP packet
+)
+
+// the classic 75% colour bars, as Y, Cb, Cr
+var colourBars = [8][3]byte{
+	{180, 128, 128}, // white
+	{162, 44, 142},  // yellow
+	{131, 156, 44},  // cyan
+	{112, 72, 58},   // green
+	{84, 184, 198},  // magenta
+	{65, 100, 212},  // red
+	{35, 212, 114},  // blue
+	{16, 128, 128},  // black
+}
+
+// testPatternFrame returns an Annex B encoded frame of colour bars with a white square moving
+// across them, n is the frame number and shift moves the bars horizontally, eg. as the drone turns.
+func testPatternFrame(n int, shift int) []byte {
+	const width, height = videoWidthMbs * 16, videoHeightMbs * 16
+	boxX, boxY := (n*8)%width, height/2-16
+
+	var frame []byte
+	frame = appendNAL(frame, 0x67, sequenceParameterSet())
+	frame = appendNAL(frame, 0x68, pictureParameterSet())
+
+	var bw bitWriter
+	bw.ue(0)      // first_mb_in_slice
+	bw.ue(7)      // slice_type: I, all slices
+	bw.ue(0)      // pic_parameter_set_id
+	bw.bits(0, 4) // frame_num
+	bw.ue(uint(n % 256))
+	bw.bits(0, 2) // no_output_of_prior_pics_flag, long_term_reference_flag
+	bw.se(0)      // slice_qp_delta
+	pixel := func(x, y int) [3]byte {
+		if x >= boxX && x < boxX+32 && y >= boxY && y < boxY+32 {
+			return [3]byte{235, 128, 128}
+		}
+		return colourBars[((x+shift)%width+width)%width*8/width]
+	}
+	for mby := 0; mby < videoHeightMbs; mby++ {
+		for mbx := 0; mbx < videoWidthMbs; mbx++ {
+			bw.ue(25) // mb_type: I_PCM
+			bw.align()
+			for y := 0; y < 16; y++ {
+				for x := 0; x < 16; x++ {
+					bw.sample(pixel(mbx*16+x, mby*16+y)[0])
+				}
+			}
+			for c := 1; c <= 2; c++ {
+				for y := 0; y < 8; y++ {
+					for x := 0; x < 8; x++ {
+						bw.sample(pixel(mbx*16+x*2, mby*16+y*2)[c])
+					}
+				}
+			}
+		}
+	}
+	bw.trailing()
+	return appendNAL(frame, 0x65, bw.buf)
+}
+
+func sequenceParameterSet() []byte {
+	var bw bitWriter
+	bw.bits(66, 8) // profile_idc: Baseline
+	bw.bits(0xc0, 8)
+	bw.bits(30, 8) // level_idc: 3.0
+	bw.ue(0)       // seq_parameter_set_id
+	bw.ue(0)       // log2_max_frame_num_minus4
+	bw.ue(2)       // pic_order_cnt_type
+	bw.ue(1)       // max_num_ref_frames
+	bw.bits(0, 1)  // gaps_in_frame_num_value_allowed_flag
+	bw.ue(videoWidthMbs - 1)
+	bw.ue(videoHeightMbs - 1)
+	bw.bits(1, 1) // frame_mbs_only_flag
+	bw.bits(1, 1) // direct_8x8_inference_flag
+	bw.bits(0, 1) // frame_cropping_flag
+	bw.bits(0, 1) // vui_parameters_present_flag
+	bw.trailing()
+	return bw.buf
+}
+
+func pictureParameterSet() []byte {
+	var bw bitWriter
+	bw.ue(0)      // pic_parameter_set_id
+	bw.ue(0)      // seq_parameter_set_id
+	bw.bits(0, 2) // entropy_coding_mode_flag, bottom_field_pic_order_in_frame_present_flag
+	bw.ue(0)      // num_slice_groups_minus1
+	bw.ue(0)      // num_ref_idx_l0_default_active_minus1
+	bw.ue(0)      // num_ref_idx_l1_default_active_minus1
+	bw.bits(0, 3) // weighted_pred_flag, weighted_bipred_idc
+	bw.se(0)      // pic_init_qp_minus26
+	bw.se(0)      // pic_init_qs_minus26
+	bw.se(0)      // chroma_qp_index_offset
+	bw.bits(0, 3) // deblocking_filter_control_present_flag, constrained_intra_pred_flag, redundant_pic_cnt_present_flag
+	bw.trailing()
+	return bw.buf
+}
+
+// appendNAL appends a start code and NAL unit to buf, inserting emulation prevention bytes into rbsp.
+func appendNAL(buf []byte, header byte, rbsp []byte) []byte {
+	buf = append(buf, 0, 0, 0, 1, header)
+	zeros := 0
+	for _, b := range rbsp {
+		if zeros >= 2 && b <= 3 {
+			buf = append(buf, 3)
+			zeros = 0
+		}
+		buf = append(buf, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return buf
+}
+
+// videoPackets splits a frame into the packets the Tello sends, each starting with the frame number
+// and a sub-packet number whose top bit marks the last packet of the frame.
+func videoPackets(frameNum byte, frame []byte) (pkts [][]byte) {
+	for sub := 0; len(frame) > 0; sub++ {
+		n := len(frame)
+		if n > videoChunkSize {
+			n = videoChunkSize
+		}
+		hdr := byte(sub & 0x7f)
+		if n == len(frame) {
+			hdr |= 0x80
+		}
+		pkts = append(pkts, append([]byte{frameNum, hdr}, frame[:n]...))
+		frame = frame[n:]
+	}
+	return pkts
+}
+
+// bitWriter builds an RBSP most significant bit first.
+type bitWriter struct {
+	buf   []byte
+	nbits uint // bits used in the last byte, 0 if it is full
+}
+
+func (bw *bitWriter) bits(v uint, n uint) {
+	for i := n; i > 0; i-- {
+		if bw.nbits == 0 {
+			bw.buf = append(bw.buf, 0)
+		}
+		if v>>(i-1)&1 == 1 {
+			bw.buf[len(bw.buf)-1] |= 0x80 >> bw.nbits
+		}
+		bw.nbits = (bw.nbits + 1) % 8
+	}
+}
+
+// ue writes an unsigned Exp-Golomb code.
+func (bw *bitWriter) ue(v uint) {
+	v++
+	n := uint(0)
+	for t := v; t > 1; t >>= 1 {
+		n++
+	}
+	bw.bits(0, n)
+	bw.bits(v, n+1)
+}
+
+// se writes a signed Exp-Golomb code.
+func (bw *bitWriter) se(v int) {
+	if v > 0 {
+		bw.ue(uint(2*v - 1))
+	} else {
+		bw.ue(uint(-2 * v))
+	}
+}
+
+// align pads with zero bits to the next byte boundary.
+func (bw *bitWriter) align() {
+	bw.nbits = 0
+}
+
+func (bw *bitWriter) sample(b byte) {
+	bw.align()
+	bw.buf = append(bw.buf, b)
+}
+
+// trailing writes the RBSP stop bit and alignment.
+func (bw *bitWriter) trailing() {
+	bw.bits(1, 1)
+	bw.align()
+}
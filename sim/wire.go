@@ -0,0 +1,138 @@
+// wire.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sim
+
+import "math"
+
+// The simulator has its own copy of the packet codec rather than sharing the client's, so that
+// a mistake in one is caught by the other rather than being faithfully reproduced by both.
+
+const (
+	msgHdr     = 0xcc
+	minPktSize = 11
+)
+
+// packet types
+const (
+	ptGet   = 1
+	ptData1 = 2
+	ptData2 = 4
+	ptSet   = 5
+)
+
+// the message IDs the simulator understands
+const (
+	msgQuerySSID           = 0x0011
+	msgQueryWifiRegion     = 0x0015
+	msgWifiStrength        = 0x001a
+	msgSetVideoBitrate     = 0x0020
+	msgQueryVideoSPSPPS    = 0x0025
+	msgQueryVideoBitrate   = 0x0028
+	msgLightStrength       = 0x0035
+	msgQueryVersion        = 0x0045
+	msgQueryActivationTime = 0x0047
+	msgQueryLoaderVersion  = 0x0049
+	msgSetStick            = 0x0050
+	msgDoTakeoff           = 0x0054
+	msgDoLand              = 0x0055
+	msgFlightStatus        = 0x0056
+	msgSetHeightLimit      = 0x0058
+	msgDoFlip              = 0x005c
+	msgDoThrowTakeoff      = 0x005d
+	msgDoPalmLand          = 0x005e
+	msgDoSmartVideo        = 0x0080
+	msgLogHeader           = 0x1050
+	msgLogData             = 0x1051
+	msgDoBounce            = 0x1053
+	msgSetLowBattThresh    = 0x1055
+	msgQueryHeightLimit    = 0x1056
+	msgQueryLowBattThresh  = 0x1057
+)
+
+type packet struct {
+	packetType uint8
+	messageID  uint16
+	sequence   uint16
+	payload    []byte
+}
+
+// decodePacket checks the framing and CRCs of buff, returning false if it is not a valid packet.
+func decodePacket(buff []byte) (pkt packet, ok bool) {
+	if len(buff) < minPktSize || buff[0] != msgHdr {
+		return pkt, false
+	}
+	size := int(uint16(buff[1])|uint16(buff[2])<<8) >> 3
+	if size < minPktSize || size > len(buff) || calculateCRC8(buff[0:3]) != buff[3] {
+		return pkt, false
+	}
+	if calculateCRC16(buff[:size-2]) != uint16(buff[size-2])|uint16(buff[size-1])<<8 {
+		return pkt, false
+	}
+	pkt.packetType = (buff[4] >> 3) & 0x07
+	pkt.messageID = uint16(buff[5]) | uint16(buff[6])<<8
+	pkt.sequence = uint16(buff[7]) | uint16(buff[8])<<8
+	pkt.payload = append([]byte(nil), buff[9:size-2]...)
+	return pkt, true
+}
+
+// encodePacket builds a raw packet from the drone.
+func encodePacket(pt uint8, messageID, seq uint16, payload []byte) []byte {
+	size := minPktSize + len(payload)
+	buff := make([]byte, size)
+	buff[0] = msgHdr
+	buff[1] = byte(size << 3)
+	buff[2] = byte(size >> 5)
+	buff[3] = calculateCRC8(buff[0:3])
+	buff[4] = 0x80 | pt<<3
+	buff[5] = byte(messageID)
+	buff[6] = byte(messageID >> 8)
+	buff[7] = byte(seq)
+	buff[8] = byte(seq >> 8)
+	copy(buff[9:], payload)
+	crc := calculateCRC16(buff[:size-2])
+	buff[size-2] = byte(crc)
+	buff[size-1] = byte(crc >> 8)
+	return buff
+}
+
+// sticks are the normalised (-1..1) stick positions from a msgSetStick payload.
+type sticks struct {
+	rx, ry, lx, ly float64
+	fast           bool
+}
+
+func decodeSticks(pl []byte) (s sticks) {
+	if len(pl) < 6 {
+		return s
+	}
+	var packed uint64
+	for i := 5; i >= 0; i-- {
+		packed = packed<<8 | uint64(pl[i])
+	}
+	axis := func(shift uint) float64 {
+		v := (float64((packed>>shift)&0x07ff) - 1024) / 660
+		return math.Max(-1, math.Min(1, v))
+	}
+	s.rx, s.ry, s.ly, s.lx = axis(0), axis(11), axis(22), axis(33)
+	s.fast = packed&(1<<44) != 0
+	return s
+}
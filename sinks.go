@@ -0,0 +1,329 @@
+// sinks.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// VideoSink consumes the raw H.264 video stream, eg. to record it or forward it elsewhere.
+// See AddVideoSink().  File and RTP sinks are provided here, others such as WebRTC may be built on
+// third-party libraries.
+type VideoSink interface {
+	// WriteVideo is called with each piece of the video stream in turn, an error stops the sink.
+	WriteVideo(sf StampedFrame) error
+	// Close is called once no more video will be written.
+	Close() error
+}
+
+// VideoSinkStatus describes a sink added by AddVideoSink().
+type VideoSinkStatus struct {
+	Name   string
+	Frames uint64 // pieces of video written successfully
+	Err    error  // the error which stopped the sink, if any
+}
+
+// videoSink runs one VideoSink.
+type videoSink struct {
+	name   string
+	sink   VideoSink
+	stop   func()
+	done   chan struct{} // closed once the sink has been closed
+	mu     sync.Mutex    // protects the following
+	frames uint64
+	err    error
+}
+
+func (vs *videoSink) run(tello *Tello, frames <-chan StampedFrame) {
+	defer close(vs.done)
+	var err error
+	for sf := range frames {
+		if err != nil {
+			continue // drain the channel until removed
+		}
+		if err = vs.sink.WriteVideo(sf); err != nil {
+			vs.mu.Lock()
+			vs.err = err
+			vs.mu.Unlock()
+			tello.logf("Video sink %s failed - %v\n", vs.name, err)
+			tello.emitEvent(EvVideoSinkFailed, vs.status())
+			vs.sink.Close()
+			continue
+		}
+		vs.mu.Lock()
+		vs.frames++
+		vs.mu.Unlock()
+	}
+	if err == nil {
+		if err = vs.sink.Close(); err != nil {
+			vs.mu.Lock()
+			vs.err = err
+			vs.mu.Unlock()
+		}
+	}
+}
+
+func (vs *videoSink) status() VideoSinkStatus {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	return VideoSinkStatus{Name: vs.name, Frames: vs.frames, Err: vs.err}
+}
+
+// AddVideoSink starts passing the video stream to sink, which is known by name until removed via
+// RemoveVideoSink().  Several sinks, eg. a file, an RTP forwarder and a WebRTC server, may share the
+// stream.  Each sink runs in its own Goroutine with its own buffer, so a slow sink loses video rather than
+// holding up the others, and a sink which returns an error is closed without affecting the others.
+func (tello *Tello) AddVideoSink(name string, sink VideoSink) error {
+	tello.sinksMu.Lock()
+	defer tello.sinksMu.Unlock()
+	if _, present := tello.sinks[name]; present {
+		return errors.New("Video sink already added: " + name)
+	}
+	if tello.sinks == nil {
+		tello.sinks = map[string]*videoSink{}
+	}
+	frames, stop := tello.ListenStampedVideo()
+	vs := &videoSink{name: name, sink: sink, stop: stop, done: make(chan struct{})}
+	tello.sinks[name] = vs
+	go vs.run(tello, frames)
+	return nil
+}
+
+// RemoveVideoSink stops passing video to the named sink, waits for it to write any video already
+// buffered, and closes it.  It returns the sink's error, if any.
+func (tello *Tello) RemoveVideoSink(name string) error {
+	tello.sinksMu.Lock()
+	vs, present := tello.sinks[name]
+	delete(tello.sinks, name)
+	tello.sinksMu.Unlock()
+	if !present {
+		return errors.New("No such video sink: " + name)
+	}
+	vs.stop()
+	<-vs.done
+	return vs.status().Err
+}
+
+// VideoSinks returns the status of every sink added via AddVideoSink() and not yet removed.
+func (tello *Tello) VideoSinks() []VideoSinkStatus {
+	tello.sinksMu.Lock()
+	defer tello.sinksMu.Unlock()
+	res := make([]VideoSinkStatus, 0, len(tello.sinks))
+	for _, vs := range tello.sinks {
+		res = append(res, vs.status())
+	}
+	return res
+}
+
+// writerSink is a VideoSink which writes the raw H.264 stream to an io.Writer.
+type writerSink struct {
+	bw *bufio.Writer
+	w  io.Writer
+}
+
+// NewWriterSink returns a VideoSink which writes the raw H.264 stream to w, which is closed
+// with the sink if it is an io.Closer.
+func NewWriterSink(w io.Writer) VideoSink {
+	return &writerSink{bw: bufio.NewWriter(w), w: w}
+}
+
+// NewFileSink returns a VideoSink which records the raw H.264 stream to a new file at path,
+// it may be played with eg. ffplay.
+func NewFileSink(path string) (VideoSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterSink(f), nil
+}
+
+func (ws *writerSink) WriteVideo(sf StampedFrame) error {
+	_, err := ws.bw.Write(sf.Data)
+	return err
+}
+
+func (ws *writerSink) Close() error {
+	err := ws.bw.Flush()
+	if c, ok := ws.w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+const (
+	rtpClockRate   = 90000 // the RTP timestamp rate for video
+	rtpVersion     = 0x80
+	rtpHeaderSize  = 12
+	rtpDefaultMTU  = 1200 // maximum RTP payload, small enough to avoid fragmentation on most paths
+	rtpPayloadType = 96   // the first dynamic payload type, as usually used for H.264
+	nalFUA         = 28   // H.264 NAL unit type of a fragmentation unit, as per RFC 6184
+)
+
+// RTPSink is a VideoSink which forwards the video as RTP packets, packetized as per RFC 6184, to a UDP
+// address.  It may be played with eg. ffplay or VLC and an SDP file such as:
+//
+//	v=0
+//	o=- 0 0 IN IP4 127.0.0.1
+//	s=Tello
+//	c=IN IP4 127.0.0.1
+//	t=0 0
+//	m=video 5004 RTP/AVP 96
+//	a=rtpmap:96 H264/90000
+//	a=fmtp:96 packetization-mode=1
+type RTPSink struct {
+	conn    net.Conn
+	mtu     int
+	seq     uint16
+	ssrc    uint32
+	start   time.Time // the time of RTP timestamp zero
+	ts      uint32    // the latest RTP timestamp
+	pending []byte    // stream data not yet known to be a complete NAL unit
+}
+
+// NewRTPSink returns an RTPSink which sends to the UDP address addr, eg. "127.0.0.1:5004".
+func NewRTPSink(addr string) (*RTPSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return newRTPSink(conn), nil
+}
+
+func newRTPSink(conn net.Conn) *RTPSink {
+	return &RTPSink{conn: conn, mtu: rtpDefaultMTU, seq: uint16(rand.Uint32()), ssrc: rand.Uint32()}
+}
+
+// WriteVideo sends each complete NAL unit in the stream so far, the last NAL unit is held until the
+// start of the next one arrives.
+func (rs *RTPSink) WriteVideo(sf StampedFrame) error {
+	if rs.start.IsZero() {
+		rs.start = sf.Received
+	}
+	ts := uint32(sf.Received.Sub(rs.start) * rtpClockRate / time.Second)
+	rs.ts = ts
+	rs.pending = append(rs.pending, sf.Data...)
+	for {
+		start, end := nextNAL(rs.pending)
+		if start < 0 || end < 0 {
+			break
+		}
+		if err := rs.sendNAL(rs.pending[start:end], ts); err != nil {
+			return err
+		}
+		rs.pending = rs.pending[end:]
+	}
+	if len(rs.pending) == 0 {
+		rs.pending = nil
+	}
+	return nil
+}
+
+// Close sends any held NAL unit and closes the connection.
+func (rs *RTPSink) Close() error {
+	var err error
+	if start, _ := nextNAL(rs.pending); start >= 0 {
+		err = rs.sendNAL(rs.pending[start:], rs.ts)
+	}
+	if cerr := rs.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// sendNAL sends one NAL unit, without its start code, in one packet or as FU-A fragments.
+func (rs *RTPSink) sendNAL(nal []byte, ts uint32) error {
+	if len(nal) == 0 {
+		return nil
+	}
+	nalType := nal[0] & 0x1f
+	marker := nalType == 1 || nalType == nalIDR // we assume one slice per picture, as the Tello sends
+	if len(nal) <= rs.mtu {
+		return rs.send(nal, ts, marker)
+	}
+	fu := make([]byte, 2, rs.mtu)
+	fu[0] = nal[0]&0xe0 | nalFUA
+	for data, first := nal[1:], true; len(data) > 0; first = false {
+		n := rs.mtu - 2
+		if n > len(data) {
+			n = len(data)
+		}
+		fu[1] = nalType
+		if first {
+			fu[1] |= 0x80
+		}
+		last := n == len(data)
+		if last {
+			fu[1] |= 0x40
+		}
+		if err := rs.send(append(fu[:2], data[:n]...), ts, marker && last); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func (rs *RTPSink) send(payload []byte, ts uint32, marker bool) error {
+	pkt := make([]byte, rtpHeaderSize, rtpHeaderSize+len(payload))
+	pkt[0] = rtpVersion
+	pkt[1] = rtpPayloadType
+	if marker {
+		pkt[1] |= 0x80
+	}
+	pkt[2], pkt[3] = byte(rs.seq>>8), byte(rs.seq)
+	pkt[4], pkt[5], pkt[6], pkt[7] = byte(ts>>24), byte(ts>>16), byte(ts>>8), byte(ts)
+	pkt[8], pkt[9], pkt[10], pkt[11] = byte(rs.ssrc>>24), byte(rs.ssrc>>16), byte(rs.ssrc>>8), byte(rs.ssrc)
+	rs.seq++
+	_, err := rs.conn.Write(append(pkt, payload...))
+	return err
+}
+
+// nextNAL finds the first NAL unit in an Annex B byte stream, returning the offset of its first byte
+// after the start code, and the offset of the start code which follows it, -1 if not found.
+func nextNAL(data []byte) (start, end int) {
+	start, end = -1, -1
+	for i := 0; i+2 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+		if start < 0 {
+			start = i + 3
+			i += 2
+			continue
+		}
+		end = i
+		if data[i-1] == 0 {
+			end-- // 4-byte start code
+		}
+		return start, end
+	}
+	return start, end
+}
@@ -0,0 +1,186 @@
+// sinks_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// funcSink is a VideoSink calling a func for each piece of video.
+type funcSink func(sf StampedFrame) error
+
+func (fs funcSink) WriteVideo(sf StampedFrame) error { return fs(sf) }
+func (fs funcSink) Close() error                     { return nil }
+
+func TestVideoSinks(t *testing.T) {
+	drone := NewTello()
+	var buf bytes.Buffer
+	if err := drone.AddVideoSink("file", NewWriterSink(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	if err := drone.AddVideoSink("file", NewWriterSink(&buf)); err == nil {
+		t.Error("Expected an error adding a sink with the same name")
+	}
+	release := make(chan struct{})
+	drone.AddVideoSink("slow", funcSink(func(StampedFrame) error { <-release; return nil }))
+	broken := errors.New("broken")
+	n := 0
+	drone.AddVideoSink("failing", funcSink(func(StampedFrame) error {
+		if n++; n == 2 {
+			return broken
+		}
+		return nil
+	}))
+	events, stop := drone.ListenEvents()
+	defer stop()
+
+	for i := 0; i < 5; i++ {
+		drone.stampVideo(byte(i), 0x80, []byte{byte(i)})
+	}
+	select {
+	case ev := <-events:
+		if st, ok := ev.Data.(VideoSinkStatus); ev.Type != EvVideoSinkFailed || !ok || st.Name != "failing" || st.Err != broken {
+			t.Errorf("Unexpected event %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for the failing sink's event")
+	}
+	if len(drone.VideoSinks()) != 3 {
+		t.Errorf("Expected 3 sinks, got %+v", drone.VideoSinks())
+	}
+
+	// the slow sink hasn't held up the others
+	if err := drone.RemoveVideoSink("file"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{0, 1, 2, 3, 4}) {
+		t.Errorf("Expected all the video in the file sink, got % x", buf.Bytes())
+	}
+	if err := drone.RemoveVideoSink("failing"); err != broken {
+		t.Errorf("Expected the failing sink's error, got %v", err)
+	}
+	close(release)
+	if err := drone.RemoveVideoSink("slow"); err != nil {
+		t.Error(err)
+	}
+	if err := drone.RemoveVideoSink("slow"); err == nil {
+		t.Error("Expected an error removing an unknown sink")
+	}
+	if len(drone.VideoSinks()) != 0 {
+		t.Errorf("Expected no sinks, got %+v", drone.VideoSinks())
+	}
+}
+
+func TestRTPSink(t *testing.T) {
+	rx, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rx.Close()
+	rs, err := NewRTPSink(rx.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	idr := make([]byte, 2500)
+	idr[0] = 0x65
+	stream := append([]byte{0, 0, 0, 1, 0x67, 0x42, 0, 0, 1}, idr...)
+	stream = append(stream, 0, 0, 0, 1, 0x41, 0x9a)
+	start := time.Unix(1600000000, 0)
+	// the stream arrives in arbitrary pieces, as it does from the drone
+	cuts := []int{0, 7, 1000, 2000, len(stream)}
+	for i := 1; i < len(cuts); i++ {
+		sf := StampedFrame{Data: stream[cuts[i-1]:cuts[i]], Received: start.Add(time.Duration(i) * 10 * time.Millisecond)}
+		if err := rs.WriteVideo(sf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	type packet struct {
+		marker  bool
+		seq     uint16
+		payload []byte
+	}
+	var pkts []packet
+	buff := make([]byte, 2048)
+	for {
+		rx.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := rx.Read(buff)
+		if err != nil {
+			break
+		}
+		if buff[0] != rtpVersion || buff[1]&0x7f != rtpPayloadType {
+			t.Fatalf("Bad RTP header % x", buff[:rtpHeaderSize])
+		}
+		pkts = append(pkts, packet{buff[1]&0x80 != 0, uint16(buff[2])<<8 | uint16(buff[3]), append([]byte(nil), buff[rtpHeaderSize:n]...)})
+	}
+	// SPS, three FU-As for the IDR, and the P slice sent on Close
+	if len(pkts) != 5 {
+		t.Fatalf("Expected 5 packets, got %d", len(pkts))
+	}
+	for i := 1; i < len(pkts); i++ {
+		if pkts[i].seq != pkts[i-1].seq+1 {
+			t.Errorf("Expected consecutive sequence numbers, got %d then %d", pkts[i-1].seq, pkts[i].seq)
+		}
+	}
+	if !bytes.Equal(pkts[0].payload, []byte{0x67, 0x42}) || pkts[0].marker {
+		t.Errorf("Unexpected SPS packet %+v", pkts[0])
+	}
+	var got []byte
+	for i, p := range pkts[1:4] {
+		if p.payload[0] != 0x60|nalFUA || p.payload[1]&0x1f != nalIDR {
+			t.Errorf("Unexpected FU-A header % x", p.payload[:2])
+		}
+		if (p.payload[1]&0x80 != 0) != (i == 0) || (p.payload[1]&0x40 != 0) != (i == 2) || p.marker != (i == 2) {
+			t.Errorf("Unexpected FU-A flags % x, marker %v", p.payload[1], p.marker)
+		}
+		got = append(got, p.payload[2:]...)
+	}
+	if !bytes.Equal(got, idr[1:]) {
+		t.Error("Expected the FU-As to carry the whole IDR")
+	}
+	if !bytes.Equal(pkts[4].payload, []byte{0x41, 0x9a}) || !pkts[4].marker {
+		t.Errorf("Unexpected P slice packet %+v", pkts[4])
+	}
+}
+
+func TestNextNAL(t *testing.T) {
+	for _, tc := range []struct {
+		data       []byte
+		start, end int
+	}{
+		{[]byte{0, 0, 0, 1, 0x67, 1, 0, 0, 0, 1, 0x68}, 4, 6},
+		{[]byte{0, 0, 1, 0x67, 1, 0, 0, 1, 0x68}, 3, 5},
+		{[]byte{0, 0, 1, 0x67, 1}, 3, -1},
+		{[]byte{0x67, 1}, -1, -1},
+	} {
+		if start, end := nextNAL(tc.data); start != tc.start || end != tc.end {
+			t.Errorf("nextNAL(% x) = %d, %d, want %d, %d", tc.data, start, end, tc.start, tc.end)
+		}
+	}
+}
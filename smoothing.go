@@ -0,0 +1,83 @@
+// smoothing.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "sort"
+
+// Filter configures the smoothing of one kind of telemetry, see WithSmoothing().
+type Filter struct {
+	Median int     // if more than 1, the median of this many samples is taken first, removing spikes
+	Alpha  float64 // the weight given to each new value by the exponential moving average, 1 for none, default 0.2
+}
+
+// SmoothingConfig sets the filters which produce the smoothed FlightData fields, zero values take the defaults.
+// Status messages, which carry the height and speeds, arrive about 10 times per second and WiFi strength
+// messages about once per second.
+type SmoothingConfig struct {
+	Height Filter // for HeightSmoothed
+	Speed  Filter // for GroundSpeedSmoothed and VerticalSpeedSmoothed
+	Wifi   Filter // for WifiStrengthSmoothed
+}
+
+// WithSmoothing configures the filters behind the smoothed FlightData fields, eg. adding a median filter
+// to remove spikes from the height, or a smaller Alpha for a steadier but slower WiFi strength.
+func WithSmoothing(cfg SmoothingConfig) Option {
+	return func(tello *Tello) { tello.cfg.smoothing = cfg }
+}
+
+// filterState holds the history of one filtered value.
+type filterState struct {
+	recent []float64 // the last Median samples
+	next   int       // where the next sample goes once recent is full
+	value  float64
+	primed bool // has value been set by a sample?
+}
+
+// update adds a sample and returns the new filtered value.
+func (fs *filterState) update(f Filter, sample float64) float32 {
+	if f.Median > 1 {
+		if len(fs.recent) < f.Median {
+			fs.recent = append(fs.recent, sample)
+		} else {
+			fs.recent[fs.next] = sample
+			fs.next = (fs.next + 1) % len(fs.recent)
+		}
+		sorted := append([]float64(nil), fs.recent...)
+		sort.Float64s(sorted)
+		sample = sorted[len(sorted)/2]
+	}
+	alpha := f.Alpha
+	if alpha <= 0 || alpha > 1 {
+		alpha = speedSmoothing
+	}
+	if !fs.primed {
+		fs.value, fs.primed = sample, true
+	} else {
+		fs.value += alpha * (sample - fs.value)
+	}
+	return float32(fs.value)
+}
+
+// smoothingFilters holds the state of the filters behind the smoothed FlightData fields, protected by fdMu.
+type smoothingFilters struct {
+	height, ground, vertical, wifi filterState
+}
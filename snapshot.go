@@ -0,0 +1,94 @@
+// snapshot.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"time"
+)
+
+const (
+	snapshotJPEGQuality  = 90
+	snapshotRequestEvery = time.Second // how often we ask for a keyframe while waiting for one
+)
+
+const nalIDR = 5 // H.264 NAL unit type of a keyframe slice
+
+// Snapshot returns a JPEG of the next keyframe in the live video stream, asking the drone
+// for one if it does not arrive promptly.  This is much quicker than TakePicture() but gives
+// a video-resolution image.  Video must be connected and a Decoder set via WithDecoder().
+func (tello *Tello) Snapshot(ctx context.Context) ([]byte, error) {
+	img, err := tello.SnapshotImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: snapshotJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SnapshotImage is as Snapshot() but returns the decoded image rather than a JPEG.
+func (tello *Tello) SnapshotImage(ctx context.Context) (image.Image, error) {
+	if !tello.ControlConnected() {
+		return nil, ErrNotConnected
+	}
+	_, unsubscribe, err := tello.SubscribeDecodedFrames() // keeps the decoding going
+	if err != nil {
+		return nil, err
+	}
+	defer unsubscribe()
+	keyframe, stop := tello.decodedKeyframe()
+	defer stop()
+
+	tello.GetVideoSpsPps()
+	request := tello.cfg.getClock().After(snapshotRequestEvery)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-request:
+			tello.GetVideoSpsPps()
+			request = tello.cfg.getClock().After(snapshotRequestEvery)
+		case img, ok := <-keyframe:
+			if !ok {
+				return nil, errors.New("Video decoding stopped before a keyframe was decoded")
+			}
+			return img, nil
+		}
+	}
+}
+
+// hasNALType reports whether the H.264 data contains the start of a NAL unit of type nalType.
+func hasNALType(data []byte, nalType byte) bool {
+	for i := 0; i+3 < len(data); i++ {
+		if data[i] == 0 && data[i+1] == 0 && data[i+2] == 1 && data[i+3]&0x1f == nalType {
+			return true
+		}
+	}
+	return false
+}
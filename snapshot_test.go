@@ -0,0 +1,111 @@
+// snapshot_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+)
+
+// laggingDecoder returns the frame for each call to Decode from the next call, as an asynchronous
+// decoder might.
+type laggingDecoder struct {
+	fakeDecoder
+	last []image.Image
+}
+
+func (d *laggingDecoder) Decode(data []byte) (frames []image.Image, err error) {
+	frames, d.last = d.last, []image.Image{image.NewGray(image.Rect(0, 0, len(data), 1))}
+	return frames, nil
+}
+
+func TestSnapshot(t *testing.T) {
+	if _, err := new(Tello).Snapshot(context.Background()); err != ErrNotConnected {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+	for _, dec := range []Decoder{fakeDecoder{}, &laggingDecoder{}} {
+		testSnapshot(t, dec)
+	}
+}
+
+func testSnapshot(t *testing.T, dec Decoder) {
+	drone := ackingDrone(t)
+	drone.cfg.decoder = dec
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	type result struct {
+		jpg []byte
+		err error
+	}
+	res := make(chan result, 1)
+	go func() {
+		jpg, err := drone.Snapshot(ctx)
+		res <- result{jpg, err}
+	}()
+	for subscribed := false; !subscribed; time.Sleep(10 * time.Millisecond) {
+		drone.decMu.RLock()
+		subscribed = drone.decQ != nil && len(drone.decKeyWaiters) > 0
+		drone.decMu.RUnlock()
+	}
+	video := func(data []byte) {
+		drone.queueForDecoding(data)
+		time.Sleep(50 * time.Millisecond)
+	}
+	video([]byte{0, 0, 0, 1, 0x41, 0xff})          // a P-frame, which should be skipped
+	video([]byte{0, 0, 0, 1, 0x67, 0, 0, 1, 0x65}) // SPS and a keyframe
+	video([]byte{0, 0, 0, 1, 0x41, 0xff, 0xfe})    // needed to get the keyframe out of the laggingDecoder
+	r := <-res
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(r.jpg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 9 {
+		t.Errorf("%T: expected the keyframe, got an image %d wide", dec, img.Bounds().Dx())
+	}
+}
+
+func TestSnapshotDecodingStopped(t *testing.T) {
+	drone := NewTello(WithDecoder(fakeDecoder{}))
+	_, unsubscribe, _ := drone.SubscribeDecodedFrames()
+	keyframe, stop := drone.decodedKeyframe()
+	defer stop()
+	unsubscribe()
+	if _, ok := <-keyframe; ok {
+		t.Error("Expected the keyframe channel to be closed when decoding stops")
+	}
+}
+
+func TestHasNALType(t *testing.T) {
+	if !hasNALType([]byte{0xaa, 0, 0, 1, 0x65, 0x88}, nalIDR) {
+		t.Error("Expected to find an IDR")
+	}
+	if hasNALType([]byte{0, 0, 1, 0x41, 0x65}, nalIDR) || hasNALType([]byte{0, 0, 1}, nalIDR) {
+		t.Error("Did not expect to find an IDR")
+	}
+}
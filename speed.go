@@ -0,0 +1,44 @@
+// speed.go
+
+// Derived speed fields for FlightData.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "math"
+
+// speedSmoothing is the default weight given to each new sample by the exponential moving average
+// used for the smoothed fields, see SmoothingConfig.
+const speedSmoothing = 0.2
+
+// groundSpeed returns the horizontal speed derived from the northern and eastern components.
+func groundSpeed(north, east int16) int16 {
+	return int16(math.Round(math.Hypot(float64(north), float64(east))))
+}
+
+// smoothSpeeds updates the smoothed height and speed fields with the latest raw values.
+// fdMu must be held by the caller.
+func (tello *Tello) smoothSpeeds() {
+	cfg, f := tello.cfg.smoothing, &tello.filters
+	tello.fd.HeightSmoothed = f.height.update(cfg.Height, float64(tello.fd.Height))
+	tello.fd.GroundSpeedSmoothed = f.ground.update(cfg.Speed, float64(tello.fd.GroundSpeed))
+	tello.fd.VerticalSpeedSmoothed = f.vertical.update(cfg.Speed, float64(tello.fd.VerticalSpeed))
+}
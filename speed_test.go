@@ -0,0 +1,80 @@
+// tello project speed_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "testing"
+
+func TestGroundSpeed(t *testing.T) {
+	if s := groundSpeed(3, -4); s != 5 {
+		t.Errorf("Expected 5, got %d", s)
+	}
+	if s := groundSpeed(0, 0); s != 0 {
+		t.Errorf("Expected 0, got %d", s)
+	}
+}
+
+func TestSmoothSpeeds(t *testing.T) {
+	tello := new(Tello)
+	tello.fd.GroundSpeed, tello.fd.VerticalSpeed = 10, -10
+	for i := 0; i < 50; i++ {
+		tello.smoothSpeeds()
+	}
+	if g := tello.fd.GroundSpeedSmoothed; g < 9.9 || g > 10 {
+		t.Errorf("Expected smoothed ground speed to converge on 10, got %f", g)
+	}
+	if v := tello.fd.VerticalSpeedSmoothed; v > -9.9 || v < -10 {
+		t.Errorf("Expected smoothed vertical speed to converge on -10, got %f", v)
+	}
+	tello.fd.GroundSpeed = 0
+	tello.smoothSpeeds()
+	if g := tello.fd.GroundSpeedSmoothed; g < 7.9 || g > 8.1 {
+		t.Errorf("Expected a single sample to only move the average part way, got %f", g)
+	}
+}
+
+func TestSmoothingFilters(t *testing.T) {
+	var fs filterState
+	if v := fs.update(Filter{}, 50); v != 50 {
+		t.Errorf("Expected the first sample to prime the filter, got %f", v)
+	}
+	if v := fs.update(Filter{Alpha: 1}, 60); v != 60 {
+		t.Errorf("Expected no smoothing with Alpha 1, got %f", v)
+	}
+
+	// a median of 3 ignores a single spike
+	fs = filterState{}
+	median := Filter{Median: 3, Alpha: 1}
+	for i, sample := range []float64{10, 11, 90, 12, 13} {
+		if v := fs.update(median, sample); v > 13 {
+			t.Errorf("Sample %d, expected the spike to be removed, got %f", i, v)
+		}
+	}
+
+	tello := NewTello(WithSmoothing(SmoothingConfig{Height: Filter{Median: 3, Alpha: 1}}))
+	for _, h := range []int16{5, 6, 40, 7} {
+		tello.fd.Height = h
+		tello.smoothSpeeds()
+	}
+	if h := tello.fd.HeightSmoothed; h != 7 {
+		t.Errorf("Expected a smoothed height of 7, got %f", h)
+	}
+}
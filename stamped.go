@@ -0,0 +1,121 @@
+// stamped.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "time"
+
+// StampedFrame is a video frame together with the drone's state when it began to arrive,
+// so that images may be associated with the drone's pose, eg. for CV or SLAM.
+type StampedFrame struct {
+	Data       []byte     // raw H.264 data of the frame, the packets delivered on the VideoConnect() channel joined
+	Received   time.Time  // when the first packet of the frame arrived
+	FlightData FlightData // the latest flight data when the first packet arrived
+	StatusTime time.Time  // when the status fields of FlightData, eg. Height, were last updated
+	PoseTime   time.Time  // when the MVO and IMU fields of FlightData were last updated
+}
+
+// StatusAge returns how old the status fields of FlightData were when the data arrived.
+func (sf StampedFrame) StatusAge() time.Duration {
+	if sf.StatusTime.IsZero() {
+		return 0
+	}
+	return sf.Received.Sub(sf.StatusTime)
+}
+
+// PoseAge returns how old the MVO and IMU fields of FlightData were when the data arrived.
+func (sf StampedFrame) PoseAge() time.Duration {
+	if sf.PoseTime.IsZero() {
+		return 0
+	}
+	return sf.Received.Sub(sf.PoseTime)
+}
+
+// ListenStampedVideo returns a channel of StampedFrames, which carries the same data as the
+// channel returned by VideoConnect() while video is connected, reassembled into frames, and a
+// func to stop listening.  A frame whose last packet was lost is passed on when the next begins.
+// The channel is buffered, but if the listener falls behind frames are dropped rather than
+// holding up the video stream.  The channel stays open across video reconnections until the
+// stop func is called.
+func (tello *Tello) ListenStampedVideo() (<-chan StampedFrame, func()) {
+	tello.stampedMu.Lock()
+	defer tello.stampedMu.Unlock()
+	if tello.stampedListeners == nil {
+		tello.stampedListeners = map[chan StampedFrame]chan StampedFrame{}
+	}
+	res := make(chan StampedFrame, tello.cfg.getVideoBufSize())
+	tello.stampedListeners[res] = res
+	return res, func() {
+		tello.stampedMu.Lock()
+		defer tello.stampedMu.Unlock()
+		if _, present := tello.stampedListeners[res]; present {
+			delete(tello.stampedListeners, res)
+			close(res)
+		}
+	}
+}
+
+// stampVideo adds a video packet, with the frame and sub-packet numbers from its header, to the frame
+// being assembled.  The frame is stamped with the flight data when its first packet arrives, and passed
+// to any StampedFrame listeners when its last packet arrives or the next frame begins.
+func (tello *Tello) stampVideo(frame, sub uint8, data []byte) {
+	tello.stampedMu.Lock()
+	defer tello.stampedMu.Unlock()
+	sf := &tello.stampedFrame
+	if len(tello.stampedListeners) == 0 {
+		*sf = StampedFrame{}
+		return
+	}
+	if sf.Data != nil && frame != tello.stampedFrameNum {
+		tello.sendStamped() // the previous frame's last packet was lost
+	}
+	if sf.Data == nil {
+		*sf = StampedFrame{Data: []byte{}, Received: tello.now()}
+		tello.fdMu.RLock()
+		sf.FlightData = tello.fd
+		sf.StatusTime, sf.PoseTime = tello.odoLastStatus, tello.odoLastMVO
+		tello.fdMu.RUnlock()
+		tello.stampedFrameNum = frame
+	}
+	sf.Data = append(sf.Data, data...)
+	if sub&0x80 != 0 {
+		tello.sendStamped()
+	}
+}
+
+// resetStampedFrame discards any partly assembled frame, at the start of a video connection.
+func (tello *Tello) resetStampedFrame() {
+	tello.stampedMu.Lock()
+	tello.stampedFrame = StampedFrame{}
+	tello.stampedMu.Unlock()
+}
+
+// sendStamped passes the assembled frame to the StampedFrame listeners and starts a new one,
+// stampedMu must be held.
+func (tello *Tello) sendStamped() {
+	for ch := range tello.stampedListeners {
+		select {
+		case ch <- tello.stampedFrame:
+		default: // so we don't block
+		}
+	}
+	tello.stampedFrame = StampedFrame{}
+}
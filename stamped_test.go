@@ -0,0 +1,83 @@
+// stamped_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestStampedVideo(t *testing.T) {
+	clock := &stepClock{t: time.Unix(1600000000, 0)}
+	drone := NewTello(WithClock(clock))
+	drone.stampVideo(0, 0x80, []byte{1}) // no listeners, nothing to do
+
+	frames, stop := drone.ListenStampedVideo()
+	drone.fd.Height = 12
+	drone.fd.IMU.Yaw = 45
+	drone.odoLastStatus = clock.t.Add(-100 * time.Millisecond)
+	drone.odoLastMVO = clock.t.Add(-20 * time.Millisecond)
+	start := clock.t
+	drone.stampVideo(1, 0, []byte{1, 2})
+	clock.t = clock.t.Add(10 * time.Millisecond)
+	drone.fd.Height = 13
+	if len(frames) != 0 {
+		t.Error("Expected no frame before its last packet")
+	}
+	drone.stampVideo(1, 0x81, []byte{3})
+
+	sf := <-frames
+	if !bytes.Equal(sf.Data, []byte{1, 2, 3}) || !sf.Received.Equal(start) {
+		t.Errorf("Unexpected frame %+v", sf)
+	}
+	if sf.FlightData.Height != 12 || sf.FlightData.IMU.Yaw != 45 {
+		t.Errorf("Expected the frame to carry the flight data, got %+v", sf.FlightData)
+	}
+	if sf.StatusAge() != 100*time.Millisecond || sf.PoseAge() != 20*time.Millisecond {
+		t.Errorf("Unexpected ages %v, %v", sf.StatusAge(), sf.PoseAge())
+	}
+
+	// a frame whose last packet is lost is passed on when the next frame begins
+	drone.stampVideo(2, 0, []byte{4})
+	drone.stampVideo(3, 0, []byte{5})
+	if sf := <-frames; !bytes.Equal(sf.Data, []byte{4}) {
+		t.Errorf("Expected the incomplete frame, got %+v", sf)
+	}
+	drone.stampVideo(3, 0x81, []byte{6})
+	if sf := <-frames; !bytes.Equal(sf.Data, []byte{5, 6}) {
+		t.Errorf("Expected the next frame, got %+v", sf)
+	}
+
+	// a slow listener loses frames rather than blocking the video
+	for i := 0; i < defaultVideoBufSize+10; i++ {
+		drone.stampVideo(byte(i), 0x80, []byte{byte(i)})
+	}
+	if len(frames) != defaultVideoBufSize {
+		t.Errorf("Expected a full buffer of %d, got %d", defaultVideoBufSize, len(frames))
+	}
+
+	stop()
+	stop() // harmless
+	for range frames {
+	}
+}
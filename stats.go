@@ -0,0 +1,187 @@
+// stats.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"sync"
+	"time"
+)
+
+// MessageStats counts the packets of one message ID.
+type MessageStats struct {
+	Packets uint64
+	Bytes   uint64
+	Last    time.Time // when the most recent packet was sent or received
+}
+
+// Stats is a snapshot of the traffic on the control connection, see Tello.Stats().
+// The counters are reset on each connection.
+// N.B. A received packet which reuses the sequence number of the last one with its message ID, or
+// an earlier one, is silently dropped as a Duplicate or Stale, except for picture chunks which carry
+// their own position; so a sender (eg. a simulator) should number each message ID's packets in order.
+type Stats struct {
+	Connected   time.Time     // when the current (or last) connection was started, zero if never
+	Uptime      time.Duration // how long the current connection has been up, zero if not connected
+	PacketsSent uint64        // includes text-SDK commands
+	BytesSent   uint64
+	LastSent    time.Time
+	PacketsRecv uint64 // includes text-SDK replies and bad packets
+	BytesRecv   uint64
+	LastRecv    time.Time
+	BadPackets  uint64                     // packets received which could not be decoded
+	Duplicates  uint64                     // packets dropped as repeats of the last one received with their message ID, see below
+	Stale       uint64                     // packets dropped because a later one with their message ID had already arrived
+	Resends     uint64                     // commands resent because they were not acknowledged in time
+	Sent        map[MessageID]MessageStats // by message ID
+	Received    map[MessageID]MessageStats // by message ID
+	RTT         RTTStats                   // as returned by RTT()
+	LinkQuality int                        // as returned by LinkQuality()
+}
+
+// trafficStats accumulates the counters reported by Stats().
+type trafficStats struct {
+	mu        sync.Mutex
+	connected time.Time
+	totals    Stats // only the counters and times are used
+	sent      map[MessageID]MessageStats
+	received  map[MessageID]MessageStats
+	lastSeq   map[MessageID]uint16 // the latest sequence number received with each message ID
+}
+
+// selfOrdered lists the message IDs whose payload gives their position, so that they are handled
+// whatever order they arrive in: picture chunks are numbered, and repeats are discarded on reassembly.
+var selfOrdered = map[MessageID]bool{MsgFileData: true}
+
+// seqWindow is how far behind the latest sequence number of its message ID a packet may be and still
+// be considered a stale retransmission, rather than the drone having restarted its numbering.
+const seqWindow = 1024
+
+// reset clears the counters at the start of a connection.
+func (ts *trafficStats) reset(now time.Time) {
+	ts.mu.Lock()
+	ts.connected = now
+	ts.totals = Stats{}
+	ts.sent = map[MessageID]MessageStats{}
+	ts.received = map[MessageID]MessageStats{}
+	ts.lastSeq = map[MessageID]uint16{}
+	ts.mu.Unlock()
+}
+
+// packetID returns the message ID of a binary packet, ok is false for text-SDK traffic.
+func packetID(buff []byte) (id MessageID, ok bool) {
+	if len(buff) < minPktSize || buff[0] != msgHdr {
+		return 0, false
+	}
+	return MessageID(buff[6])<<8 | MessageID(buff[5]), true
+}
+
+func (ts *trafficStats) recordSent(buff []byte, now time.Time) {
+	ts.mu.Lock()
+	ts.totals.PacketsSent++
+	ts.totals.BytesSent += uint64(len(buff))
+	ts.totals.LastSent = now
+	if id, ok := packetID(buff); ok && ts.sent != nil {
+		ms := ts.sent[id]
+		ms.Packets++
+		ms.Bytes += uint64(len(buff))
+		ms.Last = now
+		ts.sent[id] = ms
+	}
+	ts.mu.Unlock()
+}
+
+func (ts *trafficStats) recordRecv(buff []byte, now time.Time) {
+	ts.mu.Lock()
+	ts.totals.PacketsRecv++
+	ts.totals.BytesRecv += uint64(len(buff))
+	ts.totals.LastRecv = now
+	if id, ok := packetID(buff); ok && ts.received != nil {
+		ms := ts.received[id]
+		ms.Packets++
+		ms.Bytes += uint64(len(buff))
+		ms.Last = now
+		ts.received[id] = ms
+	}
+	ts.mu.Unlock()
+}
+
+func (ts *trafficStats) recordBad() {
+	ts.mu.Lock()
+	ts.totals.BadPackets++
+	ts.mu.Unlock()
+}
+
+// checkSequence returns false, counting the packet, if pkt repeats or predates the latest packet
+// received with its message ID, so that it can be dropped before it overwrites newer data.
+// Packets with sequence number 0, which the drone uses for unsequenced messages, are always fresh,
+// as are those with the message IDs in selfOrdered.
+func (ts *trafficStats) checkSequence(pkt packet) (fresh bool) {
+	if pkt.sequence == 0 || selfOrdered[pkt.messageID] {
+		return true
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.lastSeq == nil {
+		ts.lastSeq = map[MessageID]uint16{}
+	}
+	last, seen := ts.lastSeq[pkt.messageID]
+	switch behind := int16(last - pkt.sequence); {
+	case !seen || behind < 0 || behind >= seqWindow:
+		ts.lastSeq[pkt.messageID] = pkt.sequence
+		return true
+	case behind == 0:
+		ts.totals.Duplicates++
+	default:
+		ts.totals.Stale++
+	}
+	return false
+}
+
+func (ts *trafficStats) recordResend() {
+	ts.mu.Lock()
+	ts.totals.Resends++
+	ts.mu.Unlock()
+}
+
+// Stats returns a snapshot of the traffic statistics for the control connection,
+// useful for dashboards and for diagnosing a drone which has stopped responding.
+func (tello *Tello) Stats() Stats {
+	ts := &tello.traffic
+	ts.mu.Lock()
+	s := ts.totals
+	s.Connected = ts.connected
+	s.Sent = make(map[MessageID]MessageStats, len(ts.sent))
+	for id, ms := range ts.sent {
+		s.Sent[id] = ms
+	}
+	s.Received = make(map[MessageID]MessageStats, len(ts.received))
+	for id, ms := range ts.received {
+		s.Received[id] = ms
+	}
+	ts.mu.Unlock()
+	if tello.ControlConnected() {
+		s.Uptime = tello.now().Sub(s.Connected)
+	}
+	s.RTT = tello.RTT()
+	s.LinkQuality = tello.LinkQuality()
+	return s
+}
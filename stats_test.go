@@ -0,0 +1,116 @@
+// stats_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	if s := NewTello().Stats(); s.PacketsSent != 0 || !s.Connected.IsZero() || s.Uptime != 0 {
+		t.Errorf("Expected empty stats before connecting, got %+v", s)
+	}
+
+	// a fake drone which ignores the first copy of each command, and then sends some junk
+	drone, fake := pushingDrone(t, WithLogger(log.New(io.Discard, "", 0)))
+	seen := map[uint16]bool{}
+	fake.serve(func(pkt packet) {
+		if !seen[pkt.sequence] {
+			seen[pkt.sequence] = true
+			return
+		}
+		fake.reply(pkt, 0)
+		fake.pushRaw([]byte{msgHdr, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := drone.sendAndWait(ctx, ptSet, MsgDoTakeoff, nil); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for drone.Stats().BadPackets == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s := drone.Stats()
+	if s.Resends != 1 || s.Sent[MsgDoTakeoff].Packets != 2 || s.Sent[MsgDoTakeoff].Bytes != 2*minPktSize {
+		t.Errorf("Expected the takeoff to be sent twice, got %d resends and %+v", s.Resends, s.Sent[MsgDoTakeoff])
+	}
+	if s.Received[MsgDoTakeoff].Packets != 1 || s.Received[MsgDoTakeoff].Last.IsZero() {
+		t.Errorf("Expected one takeoff ack, got %+v", s.Received[MsgDoTakeoff])
+	}
+	if s.BadPackets != 1 || s.PacketsRecv != 2 {
+		t.Errorf("Expected 2 packets received, 1 bad, got %d and %d", s.PacketsRecv, s.BadPackets)
+	}
+	if s.PacketsSent != 2 || s.BytesSent != 2*minPktSize || s.LastSent.IsZero() {
+		t.Errorf("Unexpected sent totals %+v", s)
+	}
+	if s.Uptime <= 0 || s.Connected.IsZero() {
+		t.Errorf("Expected an uptime, got %v since %v", s.Uptime, s.Connected)
+	}
+
+	// the snapshot must not share the maps
+	s.Sent[MsgDoLand] = MessageStats{Packets: 1}
+	if _, found := drone.Stats().Sent[MsgDoLand]; found {
+		t.Error("Stats() snapshot shares its maps")
+	}
+}
+
+func TestCheckSequence(t *testing.T) {
+	var ts trafficStats
+	ts.reset(time.Now())
+	pkt := func(id MessageID, seq uint16) packet { return packet{messageID: id, sequence: seq} }
+	for i, c := range []struct {
+		pkt   packet
+		fresh bool
+	}{
+		{pkt(MsgFlightStatus, 10), true},
+		{pkt(MsgFlightStatus, 10), false}, // duplicate
+		{pkt(MsgFlightStatus, 12), true},
+		{pkt(MsgFlightStatus, 11), false}, // overtaken
+		{pkt(MsgWifiStrength, 11), true},  // each message ID is tracked separately
+		{pkt(MsgFlightStatus, 0), true},   // unsequenced
+		{pkt(MsgFlightStatus, 0), true},
+		{pkt(MsgFlightStatus, 12+seqWindow), true}, // restarted numbering
+		{pkt(MsgLogData, 0xfffe), true},
+		{pkt(MsgLogData, 1), true}, // wrapped around
+		{pkt(MsgLogData, 0xffff), false},
+		{pkt(MsgFileData, 20), true},
+		{pkt(MsgFileData, 19), true}, // chunks carry their own order
+	} {
+		if fresh := ts.checkSequence(c.pkt); fresh != c.fresh {
+			t.Errorf("%d: expected fresh %v for %#04x seq %d", i, c.fresh, c.pkt.messageID, c.pkt.sequence)
+		}
+	}
+	if ts.totals.Duplicates != 1 || ts.totals.Stale != 2 {
+		t.Errorf("Expected 1 duplicate and 2 stale packets, got %d and %d", ts.totals.Duplicates, ts.totals.Stale)
+	}
+	ts.reset(time.Now())
+	if !ts.checkSequence(pkt(MsgFlightStatus, 5)) {
+		t.Error("Expected sequence numbers to be forgotten on reset")
+	}
+}
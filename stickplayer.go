@@ -0,0 +1,135 @@
+// stickplayer.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Waveform gives a stick position, from -1 to 1, at time t since the start of playback, see StickPlayer.
+type Waveform func(t time.Duration) float64
+
+// Step is centred until at, and then holds level.
+func Step(at time.Duration, level float64) Waveform {
+	return func(t time.Duration) float64 {
+		if t < at {
+			return 0
+		}
+		return level
+	}
+}
+
+// Pulse holds level from at for width, and is centred otherwise.
+func Pulse(at, width time.Duration, level float64) Waveform {
+	return func(t time.Duration) float64 {
+		if t < at || t >= at+width {
+			return 0
+		}
+		return level
+	}
+}
+
+// Ramp is centred until from, moves steadily to level at to, and then holds level.
+func Ramp(from, to time.Duration, level float64) Waveform {
+	return func(t time.Duration) float64 {
+		switch {
+		case t <= from:
+			return 0
+		case t >= to:
+			return level
+		}
+		return level * float64(t-from) / float64(to-from)
+	}
+}
+
+// Sine oscillates between -amplitude and amplitude, starting from the centre, with the given period.
+func Sine(amplitude float64, period time.Duration) Waveform {
+	return func(t time.Duration) float64 {
+		return amplitude * math.Sin(2*math.Pi*float64(t)/float64(period))
+	}
+}
+
+// StickPlayer plays pre-programmed stick movements, eg. to characterise how the drone responds to
+// its inputs, or to regression-test control code against the simulator.
+type StickPlayer struct {
+	Rx, Ry, Lx, Ly Waveform      // as for StickMessage, nil axes are left centred
+	Duration       time.Duration // how long to play for, the sticks are then centred
+	Period         time.Duration // time between stick updates, default the keepalive period
+}
+
+// StickSample records one stick update made by StickPlayer.Play().
+type StickSample struct {
+	Offset time.Duration // the scheduled time of the update since the start of playback
+	Late   time.Duration // how far behind schedule it was actually sent to the drone
+	Sticks StickMessage
+	Data   FlightData // as it was when the update was made
+}
+
+// stickValue converts a Waveform position to the SDL convention used by StickMessage.
+func stickValue(w Waveform, t time.Duration) int16 {
+	if w == nil {
+		return 0
+	}
+	v := w(t)
+	if math.IsNaN(v) {
+		return 0
+	}
+	return int16(math.Round(math.Max(-1, math.Min(1, v)) * math.MaxInt16))
+}
+
+// Play feeds the waveforms to tello via UpdateSticks() every Period, scheduling each update from the start
+// time so that delays do not accumulate.  Each update is sent to the drone at once, rather than with the next
+// keepalive, so that Periods shorter than the keepalive's are played faithfully.  It returns a sample for
+// every update made, and ctx.Err() if ctx is done before Duration has elapsed.  The sticks are centred when it returns.
+func (sp StickPlayer) Play(ctx context.Context, tello *Tello) (samples []StickSample, err error) {
+	period := sp.Period
+	if period <= 0 {
+		period = tello.cfg.getKeepAlivePeriod()
+	}
+	clock := tello.cfg.getClock()
+	defer func() {
+		tello.UpdateSticks(StickMessage{})
+		tello.sendStickUpdate()
+	}()
+	start := clock.Now()
+	for n := 0; ; n++ {
+		offset := time.Duration(n) * period
+		if offset > sp.Duration {
+			return samples, nil
+		}
+		if wait := start.Add(offset).Sub(clock.Now()); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return samples, ctx.Err()
+			case <-clock.After(wait):
+			}
+		} else if ctx.Err() != nil {
+			return samples, ctx.Err()
+		}
+		sm := StickMessage{Rx: stickValue(sp.Rx, offset), Ry: stickValue(sp.Ry, offset), Lx: stickValue(sp.Lx, offset), Ly: stickValue(sp.Ly, offset)}
+		tello.UpdateSticks(sm)
+		tello.sendStickUpdate()
+		samples = append(samples, StickSample{Offset: offset, Late: clock.Now().Sub(start.Add(offset)), Sticks: sm, Data: tello.GetFlightData()})
+	}
+}
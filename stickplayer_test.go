@@ -0,0 +1,110 @@
+// stickplayer_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWaveforms(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		w    Waveform
+		at   time.Duration
+		want float64
+	}{
+		{"step before", Step(time.Second, 0.5), 999 * time.Millisecond, 0},
+		{"step after", Step(time.Second, 0.5), time.Second, 0.5},
+		{"pulse during", Pulse(time.Second, time.Second, -1), 1500 * time.Millisecond, -1},
+		{"pulse after", Pulse(time.Second, time.Second, -1), 2 * time.Second, 0},
+		{"ramp middle", Ramp(time.Second, 3*time.Second, 1), 2 * time.Second, 0.5},
+		{"ramp end", Ramp(time.Second, 3*time.Second, 1), 4 * time.Second, 1},
+		{"sine quarter", Sine(0.8, 4*time.Second), time.Second, 0.8},
+	} {
+		if got := tc.w(tc.at); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: expected %f, got %f", tc.name, tc.want, got)
+		}
+	}
+	if v := stickValue(Step(0, 2), 0); v != math.MaxInt16 {
+		t.Errorf("Expected the stick to be limited, got %d", v)
+	}
+}
+
+func TestStickPlayerSim(t *testing.T) {
+	drone, s := simDrone(t)
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := drone.TakeOffAndWait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := drone.WaitForTakeoff(ctx); err != nil {
+		t.Fatal(err)
+	}
+	before := s.State().Z
+
+	sp := StickPlayer{Ly: Pulse(0, 500*time.Millisecond, 1), Duration: 700 * time.Millisecond, Period: 50 * time.Millisecond}
+	samples, err := sp.Play(ctx, drone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 15 {
+		t.Errorf("Expected 15 samples, got %d", len(samples))
+	}
+	if samples[0].Sticks.Ly != math.MaxInt16 || samples[len(samples)-1].Sticks.Ly != 0 {
+		t.Errorf("Expected full throttle then centred, got %+v and %+v", samples[0], samples[len(samples)-1])
+	}
+	for i, smp := range samples {
+		if smp.Offset != time.Duration(i)*sp.Period || smp.Late < 0 {
+			t.Errorf("Update %d scheduled at %v, %v late", i, smp.Offset, smp.Late)
+		}
+	}
+	if after := s.State().Z; after <= before {
+		t.Errorf("Expected the drone to climb, height went from %f to %f", before, after)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := sp.Play(ctx, drone); err != context.Canceled {
+		t.Errorf("Expected playback to be cancelled, got %v", err)
+	}
+}
+
+func TestStickPlayerSendsEachUpdate(t *testing.T) {
+	drone, fake := pushingDrone(t, WithKeepAlivePeriod(time.Hour))
+	fake.recv(50 * time.Millisecond) // the first keepalive
+	sp := StickPlayer{Ry: Step(0, 1), Duration: 80 * time.Millisecond, Period: 20 * time.Millisecond}
+	samples, err := sp.Play(context.Background(), drone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the updates must reach the drone without waiting for a keepalive, though the writer may
+	// replace one not yet sent with the next
+	if n := countID(fake.recv(200*time.Millisecond), MsgSetStick); n < len(samples)-1 {
+		t.Errorf("Expected a stick packet for most of the %d updates, got %d", len(samples), n)
+	}
+}
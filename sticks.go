@@ -0,0 +1,86 @@
+// sticks.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "time"
+
+// stickAxes holds the four stick values, using the same SDL convention as StickMessage.
+type stickAxes struct {
+	rx, ry, lx, ly int16
+}
+
+// WithStickSlewRate limits how far each stick axis may move towards its target on each
+// stick update sent to the Tello (every 40ms by default), so step changes from coarse
+// sources such as a keyboard become ramps.  maxStep is in StickMessage units, eg. 3276
+// takes 400ms to go from centre to full deflection.  The default of 0 means no limit.
+// N.B. Hover() and the failsafes always stop the sticks immediately.
+func WithStickSlewRate(maxStep int) Option {
+	return func(tello *Tello) { tello.cfg.stickSlew = maxStep }
+}
+
+// WithStickTTL makes stick values set via UpdateSticks() (or a StickListener) expire if they are
+// not refreshed within ttl, the sticks then return to neutral so the drone hovers rather than
+// carrying on with the last command if the input source stalls.  The default of 0 means never.
+// N.B. This includes the values set by Forward(), Left() etc.
+func WithStickTTL(ttl time.Duration) Option {
+	return func(tello *Tello) { tello.cfg.stickTTL = ttl }
+}
+
+// expireSticks returns the sticks to neutral if the values from the last UpdateSticks() have
+// outlived the configured TTL.  ctrlMu must be held.
+func (tello *Tello) expireSticks() {
+	if tello.ctrlSticksExpire.IsZero() || tello.now().Before(tello.ctrlSticksExpire) {
+		return
+	}
+	tello.ctrlSticksExpire = time.Time{}
+	if tello.ctrlRx != 0 || tello.ctrlRy != 0 || tello.ctrlLx != 0 || tello.ctrlLy != 0 {
+		tello.logln("Stick input has expired, returning to neutral")
+		tello.ctrlRx, tello.ctrlRy, tello.ctrlLx, tello.ctrlLy = 0, 0, 0, 0
+	}
+}
+
+// slewAxis returns the value to send for an axis currently at sent and heading for target.
+func slewAxis(sent, target int16, maxStep int) int16 {
+	if maxStep <= 0 {
+		return target
+	}
+	diff := int(target) - int(sent)
+	switch {
+	case diff > maxStep:
+		return int16(int(sent) + maxStep)
+	case diff < -maxStep:
+		return int16(int(sent) - maxStep)
+	}
+	return target
+}
+
+// nextSticks returns the stick values for the next update, moving the values last sent
+// towards target at the configured rate.  ctrlMu must be held.
+func (tello *Tello) nextSticks(target stickAxes) stickAxes {
+	maxStep := tello.cfg.stickSlew
+	s := &tello.ctrlSent
+	s.rx = slewAxis(s.rx, target.rx, maxStep)
+	s.ry = slewAxis(s.ry, target.ry, maxStep)
+	s.lx = slewAxis(s.lx, target.lx, maxStep)
+	s.ly = slewAxis(s.ly, target.ly, maxStep)
+	return *s
+}
@@ -0,0 +1,109 @@
+// sticks_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlewAxis(t *testing.T) {
+	tests := []struct {
+		sent, target int16
+		maxStep      int
+		want         int16
+	}{
+		{0, 32767, 0, 32767},
+		{0, 32767, 1000, 1000},
+		{0, -32768, 1000, -1000},
+		{32000, 32767, 1000, 32767},
+		{-32768, 32767, 40000, 7232},
+		{-32768, 32767, 70000, 32767},
+		{500, 0, 1000, 0},
+	}
+	for _, tt := range tests {
+		if got := slewAxis(tt.sent, tt.target, tt.maxStep); got != tt.want {
+			t.Errorf("slewAxis(%d, %d, %d) = %d, expected %d", tt.sent, tt.target, tt.maxStep, got, tt.want)
+		}
+	}
+}
+
+func TestStickSlewRate(t *testing.T) {
+	drone := NewTello(WithStickSlewRate(1000))
+	drone.UpdateSticks(StickMessage{Rx: 2500, Ly: -1500})
+	want := []stickAxes{{rx: 1000, ly: -1000}, {rx: 2000, ly: -1500}, {rx: 2500, ly: -1500}}
+	for i, w := range want {
+		drone.ctrlMu.Lock()
+		got := drone.nextSticks(drone.stickTargets(0, 0))
+		drone.ctrlMu.Unlock()
+		if got != w {
+			t.Errorf("Update %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+	drone.Hover()
+	drone.ctrlMu.Lock()
+	got := drone.nextSticks(drone.stickTargets(0, 0))
+	drone.ctrlMu.Unlock()
+	if got != (stickAxes{}) {
+		t.Errorf("Expected Hover() to stop the sticks at once, got %+v", got)
+	}
+}
+
+// stepClock is a Clock which only moves when told to.
+type stepClock struct{ t time.Time }
+
+func (c *stepClock) Now() time.Time                         { return c.t }
+func (c *stepClock) After(d time.Duration) <-chan time.Time { return make(chan time.Time) }
+
+func TestStickTTL(t *testing.T) {
+	clock := &stepClock{t: time.Unix(1600000000, 0)}
+	drone := NewTello(WithClock(clock), WithStickTTL(200*time.Millisecond))
+	drone.UpdateSticks(StickMessage{Ry: 10000})
+	sticks := func() stickAxes {
+		drone.ctrlMu.Lock()
+		defer drone.ctrlMu.Unlock()
+		drone.expireSticks()
+		return drone.nextSticks(drone.stickTargets(0, 0))
+	}
+	clock.t = clock.t.Add(150 * time.Millisecond)
+	if s := sticks(); s.ry != 10000 {
+		t.Errorf("Expected the sticks to be held within the TTL, got %+v", s)
+	}
+	drone.UpdateSticks(StickMessage{Ry: 10000}) // refreshed
+	clock.t = clock.t.Add(150 * time.Millisecond)
+	if s := sticks(); s.ry != 10000 {
+		t.Errorf("Expected refreshing to extend the TTL, got %+v", s)
+	}
+	clock.t = clock.t.Add(100 * time.Millisecond)
+	if s := sticks(); s != (stickAxes{}) {
+		t.Errorf("Expected the sticks to expire, got %+v", s)
+	}
+
+	// sticks set other than by UpdateSticks() are not affected once expired
+	drone.ctrlMu.Lock()
+	drone.ctrlLx = 5000
+	drone.ctrlMu.Unlock()
+	clock.t = clock.t.Add(time.Second)
+	if s := sticks(); s.lx != 5000 {
+		t.Errorf("Expected only UpdateSticks() values to expire, got %+v", s)
+	}
+}
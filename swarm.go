@@ -0,0 +1,224 @@
+// swarm.go
+
+// This file contains support for flying several Tellos together.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FormationOffset is the position of a drone relative to the trajectory flown by a Swarm.
+type FormationOffset struct {
+	X, Y   float32 // metres
+	Height int16   // decimetres
+}
+
+// Swarm manages a group of Tellos which are flown together in formation.
+// Each drone must be connected before being added, and must have its home point set
+// (see SetHome) before XY manoeuvres are used.
+// Group manoeuvres return immediately, use WaitAll() as a barrier before the next one.
+type Swarm struct {
+	mu      sync.Mutex // mu protects members and pending
+	members []swarmMember
+	pending []chan error // done channels of manoeuvres we have not yet waited for
+}
+
+type swarmMember struct {
+	id     string
+	drone  *Tello
+	offset FormationOffset
+}
+
+// Add includes a drone in the swarm at the given offset from the shared trajectory.
+// The drone is identified by its configured address and port, eg. "192.168.1.23:8889",
+// use AddWithID to choose another ID.
+func (s *Swarm) Add(drone *Tello, offset FormationOffset) {
+	s.AddWithID(fmt.Sprintf("%s:%d", drone.cfg.getDroneAddr(), drone.cfg.getDronePort()), drone, offset)
+}
+
+// AddWithID includes a drone in the swarm at the given offset from the shared trajectory,
+// identified by id in the swarm's video frames.  IDs should be unique within the swarm.
+func (s *Swarm) AddWithID(id string, drone *Tello, offset FormationOffset) {
+	s.mu.Lock()
+	s.members = append(s.members, swarmMember{id: id, drone: drone, offset: offset})
+	s.mu.Unlock()
+}
+
+// Drone returns the drone in the swarm with the given ID.
+func (s *Swarm) Drone(id string) (drone *Tello, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.members {
+		if m.id == id {
+			return m.drone, true
+		}
+	}
+	return nil, false
+}
+
+// Drones returns the drones in the swarm in the order they were added.
+func (s *Swarm) Drones() (drones []*Tello) {
+	s.mu.Lock()
+	for _, m := range s.members {
+		drones = append(drones, m.drone)
+	}
+	s.mu.Unlock()
+	return drones
+}
+
+// WaitAll blocks until every manoeuvre started since the last WaitAll has completed, or ctx is done.
+// It returns the first error reported by any drone, or the context's error.
+// If ctx is done first the outstanding manoeuvres are kept, so WaitAll may be called again.
+func (s *Swarm) WaitAll(ctx context.Context) (err error) {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	for i, done := range pending {
+		select {
+		case dErr := <-done:
+			if dErr != nil && err == nil {
+				err = dErr
+			}
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.pending = append(pending[i:], s.pending...)
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// TakeOff launches every drone at the same moment, then has them climb to the formation
+// height (in decimetres) plus their own height offset.  As a Tello climbs to its takeoff height
+// by itself, its throttle cannot be staggered; instead the climbs to the formation height are
+// started stagger apart, once each drone has taken off, in the order the drones were added, so
+// that the drones do not disturb each other.  WaitAll() reports any drone which refuses to take
+// off, or fails to take off before ctx is done.
+func (s *Swarm) TakeOff(ctx context.Context, height int16, stagger time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, m := range s.members {
+		done := make(chan error, 1)
+		s.pending = append(s.pending, done)
+		go func(m swarmMember, delay time.Duration) {
+			if err := m.drone.TakeOffAndWait(ctx); err != nil {
+				done <- err
+				return
+			}
+			if err := m.drone.WaitForTakeoff(ctx); err != nil {
+				done <- err
+				return
+			}
+			select {
+			case <-ctx.Done():
+				done <- ctx.Err()
+				return
+			case <-m.drone.cfg.getClock().After(delay):
+			}
+			hDone, err := m.drone.AutoFlyToHeight(height + m.offset.Height)
+			if err != nil {
+				done <- err
+				return
+			}
+			done <- <-hDone
+		}(m, time.Duration(i)*stagger)
+	}
+}
+
+// SetHome establishes the current position of each drone as its home point.
+func (s *Swarm) SetHome() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.members {
+		if err = m.drone.SetHome(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlyToHeight starts every drone moving to the formation height in decimetres plus its own offset.
+func (s *Swarm) FlyToHeight(height int16) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.members {
+		done, err := m.drone.AutoFlyToHeight(height + m.offset.Height)
+		if err != nil {
+			return err
+		}
+		s.pending = append(s.pending, done)
+	}
+	return nil
+}
+
+// FlyToXY starts every drone moving to the formation position (x, y) in metres plus its own offset.
+func (s *Swarm) FlyToXY(x, y float32) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.members {
+		done, err := m.drone.AutoFlyToXY(x+m.offset.X, y+m.offset.Y)
+		if err != nil {
+			return err
+		}
+		s.pending = append(s.pending, done)
+	}
+	return nil
+}
+
+// FollowTrajectory flies the formation through each (x, y) waypoint in turn, waiting for
+// every drone to reach a waypoint before moving on to the next.
+func (s *Swarm) FollowTrajectory(ctx context.Context, waypoints [][2]float32) (err error) {
+	for _, wp := range waypoints {
+		if err = s.FlyToXY(wp[0], wp[1]); err != nil {
+			return err
+		}
+		if err = s.WaitAll(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Hover halts the motion of every drone.
+func (s *Swarm) Hover() {
+	s.mu.Lock()
+	for _, m := range s.members {
+		m.drone.Hover()
+	}
+	s.mu.Unlock()
+}
+
+// Land sends a normal Land request to every drone.
+func (s *Swarm) Land() {
+	s.mu.Lock()
+	for _, m := range s.members {
+		m.drone.Land()
+	}
+	s.mu.Unlock()
+}
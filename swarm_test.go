@@ -0,0 +1,69 @@
+// tello project swarm_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSwarmWaitAll(t *testing.T) {
+	var s Swarm
+	d1 := make(chan error, 1)
+	d2 := make(chan error, 1)
+	s.pending = []chan error{d1, d2}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	d1 <- nil
+	if err := s.WaitAll(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	if len(s.pending) != 1 {
+		t.Fatalf("Expected 1 outstanding manoeuvre, got %d", len(s.pending))
+	}
+
+	d2 <- errors.New("test failure")
+	if err := s.WaitAll(context.Background()); err == nil {
+		t.Error("Expected error from second drone")
+	}
+	if len(s.pending) != 0 {
+		t.Errorf("Expected no outstanding manoeuvres, got %d", len(s.pending))
+	}
+}
+
+func TestSwarmTakeOffRefused(t *testing.T) {
+	drone, fake := pushingDrone(t)
+	fake.serve(func(pkt packet) { fake.reply(pkt, 1) })
+	var s Swarm
+	s.Add(drone, FormationOffset{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.TakeOff(ctx, 10, time.Second)
+	var cmdErr *CommandError
+	if err := s.WaitAll(ctx); !errors.As(err, &cmdErr) {
+		t.Errorf("Expected the refusal to be reported, got %v", err)
+	}
+}
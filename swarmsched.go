@@ -0,0 +1,65 @@
+// swarmsched.go
+
+// This file contains support for running commands on every drone in a Swarm at the same moment.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"runtime"
+	"time"
+)
+
+// schedulerSpin is how long before a scheduled time AtTime stops sleeping and polls the clock,
+// as sleeps may overrun by a millisecond or more.
+const schedulerSpin = 2 * time.Millisecond
+
+// AtTime runs cmd for every drone in the swarm at the given time by the host's clock, so that
+// choreography such as simultaneous flips executes within a few milliseconds on every drone, eg.
+//
+//	s.AtTime(time.Now().Add(time.Second), (*tello.Tello).ForwardFlip)
+//
+// Each drone's command is run in its own Goroutine, so a slow command does not delay the others.
+// If at has passed the commands are run immediately.  AtTime returns immediately, use WaitAll()
+// to wait for the commands to have been run.
+func (s *Swarm) AtTime(at time.Time, cmd func(drone *Tello)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.members {
+		done := make(chan error, 1)
+		s.pending = append(s.pending, done)
+		go func(drone *Tello) {
+			waitUntil(at)
+			cmd(drone)
+			done <- nil
+		}(m.drone)
+	}
+}
+
+// waitUntil sleeps until shortly before at, then yields until at is reached.
+func waitUntil(at time.Time) {
+	if d := time.Until(at) - schedulerSpin; d > 0 {
+		time.Sleep(d)
+	}
+	for time.Now().Before(at) {
+		runtime.Gosched()
+	}
+}
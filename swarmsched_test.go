@@ -0,0 +1,71 @@
+// swarmsched_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSwarmAtTime(t *testing.T) {
+	var s Swarm
+	for _, id := range []string{"a", "b", "c"} {
+		s.AddWithID(id, NewTello(), FormationOffset{})
+	}
+	var (
+		mu  sync.Mutex
+		ran = map[*Tello]time.Time{}
+	)
+	at := time.Now().Add(50 * time.Millisecond)
+	s.AtTime(at, func(drone *Tello) {
+		mu.Lock()
+		ran[drone] = time.Now()
+		mu.Unlock()
+	})
+	if err := s.WaitAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 3 {
+		t.Fatalf("Expected 3 commands run, got %d", len(ran))
+	}
+	// a loaded machine may run them all late, but they should still run together
+	var first, last time.Time
+	for _, when := range ran {
+		if when.Before(at) {
+			t.Errorf("Command ran %v early", at.Sub(when))
+		}
+		if first.IsZero() || when.Before(first) {
+			first = when
+		}
+		if when.After(last) {
+			last = when
+		}
+	}
+	if late := first.Sub(at); late > time.Second {
+		t.Errorf("Commands ran %v late", late)
+	}
+	if spread := last.Sub(first); spread > 50*time.Millisecond {
+		t.Errorf("Commands ran %v apart", spread)
+	}
+}
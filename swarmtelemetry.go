@@ -0,0 +1,127 @@
+// swarmtelemetry.go
+
+// This file contains support for monitoring every drone in a Swarm.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SwarmTelemetry is a snapshot of the flight data of every drone in a Swarm.
+type SwarmTelemetry struct {
+	Time   time.Time
+	Drones map[string]FlightData // the latest FlightData of each connected drone, keyed by drone ID
+	Lost   []string              // the IDs of any drones which are not connected
+}
+
+// Telemetry returns the current flight data of every drone in the swarm.
+func (s *Swarm) Telemetry() SwarmTelemetry {
+	s.mu.Lock()
+	members := append([]swarmMember(nil), s.members...)
+	s.mu.Unlock()
+
+	st := SwarmTelemetry{Time: swarmClock(members).Now(), Drones: make(map[string]FlightData, len(members))}
+	for _, m := range members {
+		if !m.drone.ControlConnected() {
+			st.Lost = append(st.Lost, m.id)
+			continue
+		}
+		st.Drones[m.id] = m.drone.GetFlightData()
+	}
+	return st
+}
+
+// swarmClock returns the Clock of the first of members, as the swarm has none of its own.
+func swarmClock(members []swarmMember) Clock {
+	if len(members) == 0 {
+		return systemClock{}
+	}
+	return members[0].drone.cfg.getClock()
+}
+
+// StreamTelemetry starts a Goroutine which sends the swarm's Telemetry() to a channel every period,
+// until stop is called, when the channel is closed.  The period is timed by the Clock of the
+// swarm's first drone, see WithClock(), and must be positive.
+// N.B. This streamer does not block on the channel, so unconsumed updates are lost.
+func (s *Swarm) StreamTelemetry(period time.Duration) (telemetry <-chan SwarmTelemetry, stop func(), err error) {
+	if period <= 0 {
+		return nil, nil, errors.New("Swarm telemetry period must be positive")
+	}
+	s.mu.Lock()
+	clock := swarmClock(s.members)
+	s.mu.Unlock()
+	stChan := make(chan SwarmTelemetry, 2)
+	done := make(chan struct{})
+	go func() {
+		defer close(stChan)
+		for {
+			select {
+			case stChan <- s.Telemetry():
+			default:
+			}
+			select {
+			case <-clock.After(period):
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return stChan, func() { once.Do(func() { close(done) }) }, nil
+}
+
+// LowestBattery returns the ID and battery percentage of the connected drone with the least charge.
+// ok is false if no drones are connected.
+func (st SwarmTelemetry) LowestBattery() (id string, percent int8, ok bool) {
+	for _, did := range st.ids() {
+		if fd := st.Drones[did]; !ok || fd.BatteryPercentage < percent {
+			id, percent, ok = did, fd.BatteryPercentage, true
+		}
+	}
+	return id, percent, ok
+}
+
+// AllAirborne returns true if every drone in the swarm is connected and off the ground.
+func (st SwarmTelemetry) AllAirborne() bool {
+	if len(st.Lost) > 0 || len(st.Drones) == 0 {
+		return false
+	}
+	for _, fd := range st.Drones {
+		if !fd.Flying && !fd.State.IsAirborne() {
+			return false
+		}
+	}
+	return true
+}
+
+// ids returns the IDs of the connected drones in order, so that ties are resolved consistently.
+func (st SwarmTelemetry) ids() (ids []string) {
+	for id := range st.Drones {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,106 @@
+// swarmtelemetry_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSwarmTelemetry(t *testing.T) {
+	var s Swarm
+	for _, d := range []struct {
+		id      string
+		battery int8
+		flying  bool
+	}{{"a", 80, true}, {"b", 35, true}, {"c", 35, true}} {
+		drone := NewTello()
+		drone.setCtrlState(connConnected)
+		drone.fdMu.Lock()
+		drone.fd.BatteryPercentage, drone.fd.Flying = d.battery, d.flying
+		drone.fdMu.Unlock()
+		s.AddWithID(d.id, drone, FormationOffset{})
+	}
+
+	st := s.Telemetry()
+	if len(st.Drones) != 3 || len(st.Lost) != 0 {
+		t.Fatalf("Unexpected telemetry %+v", st)
+	}
+	if id, pc, ok := st.LowestBattery(); !ok || id != "b" || pc != 35 {
+		t.Errorf("Expected b at 35%%, got %s at %d%% (%v)", id, pc, ok)
+	}
+	if !st.AllAirborne() {
+		t.Error("Expected all drones airborne")
+	}
+
+	c, _ := s.Drone("c")
+	c.setCtrlState(connDisconnected)
+	st = s.Telemetry()
+	if len(st.Lost) != 1 || st.Lost[0] != "c" || st.AllAirborne() {
+		t.Errorf("Expected c to be lost and the swarm not all airborne, got %+v", st)
+	}
+	b, _ := s.Drone("b")
+	b.fdMu.Lock()
+	b.fd.Flying = false
+	b.fdMu.Unlock()
+	c.setCtrlState(connConnected)
+	if s.Telemetry().AllAirborne() {
+		t.Error("Expected b to be on the ground")
+	}
+
+	if _, _, ok := (SwarmTelemetry{}).LowestBattery(); ok {
+		t.Error("Expected no lowest battery without drones")
+	}
+
+	var timed Swarm
+	clock := &stepClock{t: time.Unix(1600000000, 0)}
+	timed.AddWithID("a", NewTello(WithClock(clock)), FormationOffset{})
+	if st := timed.Telemetry(); !st.Time.Equal(clock.t) {
+		t.Errorf("Expected the telemetry to be timed by the drone's clock, got %v", st.Time)
+	}
+}
+
+func TestSwarmStreamTelemetry(t *testing.T) {
+	var s Swarm
+	s.AddWithID("a", NewTello(), FormationOffset{})
+	if _, _, err := s.StreamTelemetry(0); err == nil {
+		t.Error("Expected an error for a zero period")
+	}
+	stream, stop, err := s.StreamTelemetry(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case st := <-stream:
+			if len(st.Lost) != 1 {
+				t.Errorf("Expected the unconnected drone to be lost, got %+v", st)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("No telemetry streamed")
+		}
+	}
+	stop()
+	stop()
+	for range stream { // drain until closed
+	}
+}
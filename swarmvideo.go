@@ -0,0 +1,87 @@
+// swarmvideo.go
+
+// This file contains support for receiving video from every drone in a Swarm.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "sync"
+
+// SwarmFrame is a video frame from one drone in a Swarm.
+type SwarmFrame struct {
+	DroneID string // as given to Add() or AddWithID()
+	Data    []byte // raw H.264 video, as from VideoConnect()
+}
+
+// VideoConnect starts video from every drone in the swarm, each streaming to its own
+// free local port, which is negotiated with the drone in its connection request, so that
+// the streams cannot clash even if the drones were configured with the same video port.
+// The frames are merged into one channel, tagged with the ID of the drone they came from,
+// for camera arrays and the like.  The channel is closed once every drone's video has stopped.
+// The drones must already be connected; if any drone's video cannot be started, those already
+// started are stopped and the error returned.
+// Use GetVideoSpsPps() periodically, as for a single drone.
+func (s *Swarm) VideoConnect() (<-chan SwarmFrame, error) {
+	s.mu.Lock()
+	members := append([]swarmMember(nil), s.members...)
+	s.mu.Unlock()
+
+	frames := make(chan SwarmFrame, defaultVideoBufSize*len(members))
+	var wg sync.WaitGroup
+	for i, m := range members {
+		video, err := m.drone.VideoConnect(m.drone.cfg.getDroneAddr(), AnyPort)
+		if err != nil {
+			for _, started := range members[:i] {
+				started.drone.VideoDisconnect()
+			}
+			return nil, err
+		}
+		wg.Add(1)
+		go func(id string, video <-chan []byte) {
+			defer wg.Done()
+			for data := range video {
+				select {
+				case frames <- SwarmFrame{DroneID: id, Data: data}:
+				default: // so we don't block
+				}
+			}
+		}(m.id, video)
+	}
+	go func() {
+		wg.Wait()
+		close(frames)
+	}()
+	return frames, nil
+}
+
+// VideoDisconnect stops video from every drone in the swarm.
+func (s *Swarm) VideoDisconnect() {
+	for _, drone := range s.Drones() {
+		drone.VideoDisconnect()
+	}
+}
+
+// GetVideoSpsPps asks every drone in the swarm to send SPS and PPS in its video stream.
+func (s *Swarm) GetVideoSpsPps() {
+	for _, drone := range s.Drones() {
+		drone.GetVideoSpsPps()
+	}
+}
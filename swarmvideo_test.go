@@ -0,0 +1,82 @@
+// swarmvideo_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSwarmVideo(t *testing.T) {
+	port := freeUDPPort(t) // both drones are configured with the same video port
+	var s Swarm
+	for _, id := range []string{"left", "right"} {
+		drone, _ := simDrone(t, WithVideoPort(port))
+		if err := drone.ControlConnectDefault(); err != nil {
+			t.Fatal(err)
+		}
+		s.AddWithID(id, drone, FormationOffset{})
+	}
+	frames, err := s.VideoConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, _ := s.Drone("left")
+	right, _ := s.Drone("right")
+	deadline := time.Now().Add(5 * time.Second)
+	for left.VideoPort() == port || right.VideoPort() == port { // the drones acknowledge their new ports
+		if time.Now().After(deadline) {
+			t.Fatalf("Video ports not renegotiated, got %d and %d", left.VideoPort(), right.VideoPort())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if left.VideoPort() == right.VideoPort() {
+		t.Errorf("Both drones stream video to port %d", left.VideoPort())
+	}
+
+	s.GetVideoSpsPps()
+	seen := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for len(seen) < 2 {
+		select {
+		case f := <-frames:
+			seen[f.DroneID] = true
+		case <-timeout:
+			t.Fatalf("Only had video from %v", seen)
+		}
+	}
+	if !seen["left"] || !seen["right"] {
+		t.Errorf("Unexpected drone IDs %v", seen)
+	}
+
+	s.VideoDisconnect()
+	for range frames { // drain until closed
+	}
+}
+
+func TestSwarmDefaultID(t *testing.T) {
+	var s Swarm
+	s.Add(NewTello(WithAddress("10.0.0.7", 8889)), FormationOffset{})
+	if _, ok := s.Drone("10.0.0.7:8889"); !ok {
+		t.Error("Drone not found by its address")
+	}
+}
@@ -23,10 +23,12 @@ package tello
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"image"
 	"log"
 	"net"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -43,19 +45,63 @@ const (
 
 const keepAlivePeriodMs = 40
 
+const (
+	defaultConnectTimeout = 3 * time.Second
+	defaultConnectRetries = 3
+	defaultConnectBackoff = 250 * time.Millisecond
+	connectPollPeriod     = 100 * time.Millisecond // how often ControlConnect() checks for a response
+)
+
+const defaultStickBufSize = 10
+
+const stickPayloadSize = 11 // packed axes and timestamp
+
+const listenerPollPeriod = 250 * time.Millisecond // how often blocked listeners check if they should stop
+
+var errListenerDone = errors.New("listener stopped")
+
 const lightStrengthTimeout = time.Second * 5 // we assume connection lost if no update for this period
 
 // Tello holds the current state of a connection to a Tello drone.
 type Tello struct {
 	ctrlMu                         sync.RWMutex // this mutex protects the control fields
-	ctrlConn, videoConn            *net.UDPConn
-	videoStopChan                  chan bool
-	ctrlConnecting, ctrlConnected  bool
+	ctrlConn, videoConn            net.Conn
+	ctrlState                      connState
+	ctrlDone                       chan struct{} // closed when the current control connection ends
+	ctrlStopped                    chan struct{} // closed by the control listener when it has stopped
+	ctrlWriterStopped              chan struct{} // closed by the packet writer when it has stopped
+	ctrlLostContact                bool          // set when contact is lost, reported by EvDisconnected
+	ctrlVideoPort                  int           // video port acknowledged by the drone, 0 if not yet known
 	ctrlSeq                        uint16
-	ctrlRx, ctrlRy, ctrlLx, ctrlLy int16 // we are using the SDL convention: vals range from -32768 to 32767
-	ctrlSportsMode                 bool  // are we in 'sports' (a.k.a. 'Fast') mode?
-	ctrlBouncing                   bool  // do we think we are bouncing?
+	ctrlRx, ctrlRy, ctrlLx, ctrlLy int16         // we are using the SDL convention: vals range from -32768 to 32767
+	ctrlSent                       stickAxes     // the stick values last sent, which lag the above if slew limiting
+	ctrlSticksExpire               time.Time     // when the values from UpdateSticks() expire, zero if never
+	ctrlSportsMode                 bool          // are we in 'sports' (a.k.a. 'Fast') mode?
+	ctrlBouncing                   bool          // do we think we are bouncing?
+	ctrlBounceReq                  byte          // the bouncing mode last requested, see bounceRequest()
+	ctrlStopLanding                bool          // was the last land message a StopLanding()?
+	ctrlSmartVideo                 SvCmd         // the smart video manoeuvre in progress, if any
+	ctrlHeadless                   bool          // are stick inputs relative to headingRef?
+	ctrlAutoHook                   AutopilotHook // set by SetAutopilotHook()
+	ctrlEnvelope                   *Envelope     // set by SetEnvelope(), nil if not called
+	ctrlDisarmed                   bool          // see WithArmInterlock()
+	videoMu                        sync.Mutex    // videoMu protects the video fields
 	videoChan                      chan []byte
+	videoPort                      int           // the local port videoConn listens on, 0 if not listening
+	videoReserved                  net.Conn      // bound to advertise an AnyPort video port before VideoConnect(), see reserveVideoPort()
+	videoDone, videoStopped        chan struct{} // as for ctrlDone and ctrlStopped
+	stampedMu                      sync.RWMutex  // protects stampedListeners, stampedFrame and stampedFrameNum
+	stampedListeners               map[chan StampedFrame]chan StampedFrame
+	stampedFrame                   StampedFrame // being assembled by stampVideo(), Data is nil if none
+	stampedFrameNum                uint8
+	decMu                          sync.RWMutex  // protects decQ, decDone, decListeners, decFed, decOut and decKeyWaiters
+	decQ                           chan []byte   // video data for the decoding goroutine, nil if there are no subscribers
+	decDone                        chan struct{} // closed when the latest decoding goroutine has finished with the Decoder
+	decListeners                   map[chan image.Image]chan image.Image
+	decFed, decOut                 uint64                      // slices passed to the Decoder, and frames it has returned
+	decKeyWaiters                  map[chan image.Image]uint64 // see decodedKeyframe(), the decFed count of the keyframe awaited
+	sinksMu                        sync.Mutex                  // protects sinks
+	sinks                          map[string]*videoSink
 	stickChan                      chan StickMessage // this will receive stick updates from the user
 	stickListening                 bool              // are we currently listening on stickChan?
 	stickListeningMu               sync.RWMutex
@@ -64,109 +110,268 @@ type Tello struct {
 	fd                             FlightData   // our private amalgamated store of the latest data
 	fdStreaming                    bool         // are we currently sending FlightData out?
 	files                          []FileData
+	filesMu                        sync.Mutex // protects filesListeners, and is held while (non-blockingly) notifying them
 	filesListeners                 map[chan FileData]chan FileData
 	fileTemp                       fileInternal
+	msgMu                          sync.RWMutex // protects the following
+	msgHandlers                    map[MessageID][]msgHandler
+	msgNextID                      int
+	unknownSeen                    map[MessageID]bool // unknown message IDs already logged
+	unknownRing                    []RawMessage
+	unknownNext                    int
+	packetHookMu                   sync.RWMutex // protects packetHook
+	packetHook                     PacketHook
+	intervalMu                     sync.Mutex
+	intervalStop                   chan struct{} // closed to stop StartIntervalShooting(), nil if not shooting
 	autoHeightMu, autoYawMu        sync.RWMutex
 	autoHeight, autoYaw            bool         // flags to indicate if autoflight is active
 	autoXYMu                       sync.RWMutex // autoXYMu protects originX/Y/Valid/Yaw
 	autoXY                         bool         // flag for XY autoflight
-	homeValid                      bool         // has an home point been set?
-	homeX, homeY                   float32      // set on request to provide a frame of reference
-	homeYaw                        float32      // 0 - 360 degrees, yaw when origin set
+	autoOrbitMu                    sync.RWMutex
+	autoOrbit                      bool // flag for AutoOrbit
+	autoFollowMu                   sync.RWMutex
+	autoFollow                     bool      // flag for Follow
+	homeValid                      bool      // has an home point been set?
+	homeX, homeY                   float32   // set on request to provide a frame of reference
+	homeYaw                        float32   // 0 - 360 degrees, yaw when origin set
+	yawOffset                      float32   // added to the IMU yaw to give the MVO heading, protected by autoXYMu
+	roomFrame                      RoomFrame // set by SetRoomFrame(), protected by autoXYMu
+	roomFrameValid                 bool
+	takeoffX, takeoffY             float32            // MVO position when we last left the ground, protected by autoXYMu
+	takeoffValid                   bool               // have takeoffX/Y been recorded? protected by autoXYMu
+	rthCancel                      context.CancelFunc // stops ReturnToHome(), nil if not returning, protected by autoXYMu
+	evMu                           sync.RWMutex       // evMu protects evListeners
+	evListeners                    map[chan Event]chan Event
+	cfg                            config     // set via NewTello() options
+	ackMu                          sync.Mutex // ackMu protects acks
+	acks                           map[ackKey]chan []byte
+	odoLastStatus, odoLastMVO      time.Time       // times of the last samples integrated by the odometer, protected by fdMu
+	battSamples                    []batterySample // recent in-flight battery readings, protected by fdMu
+	battWarned                     bool            // has the battery reserve been reported this flight? protected by fdMu
+	warnFlags                      uint8           // warnings active in the last status message, protected by fdMu
+	calType                        CalibrationType // the calibration last requested, protected by fdMu
+	calRequested, calRunning       bool            // awaiting the ack, and following the progress of a calibration, protected by fdMu
+	calState                       int8            // ImuCalibrationState when last checked, protected by fdMu
+	calProgressed                  bool            // has calState been non-zero during this calibration? protected by fdMu
+	headingRef                     float32         // the yaw at takeoff, 'forward' in headless mode, protected by fdMu
+	headingRefValid                bool            // has headingRef been recorded? protected by fdMu
+	stateSince                     time.Time       // when fd.State last changed, protected by fdMu
+	watches                        watchList
+	link                           linkStats
+	abr                            bitrateState
+	rtt                            rttTracker
+	vstats                         videoTracker
+	traffic                        trafficStats
+	flog                           flightLogger
+	history                        historyRing
+	filters                        smoothingFilters
+	staleness                      staleWatch
+	sendQ                          sendQueue
 }
 
-// ControlConnect attempts to connect to a Tello at the provided network addr.
+// connState is the lifecycle state of the control connection.
+type connState int
+
+const (
+	connDisconnected connState = iota
+	connConnecting
+	connConnected
+)
+
+// ControlConnect attempts to connect to a Tello at the provided network addr, from localUDPPort
+// which may be AnyPort.
 // It then starts listening for responses on the control channel and processes them in a Goroutine.
+// A Tello may be connected again after ControlDisconnect() or lost contact; the sticks, sports mode
+// and other per-connection state are then reset, but sequence numbers continue.
 func (tello *Tello) ControlConnect(udpAddr string, droneUDPPort int, localUDPPort int) (err error) {
-	// first check that we are not already connected or connecting
-	tello.ctrlMu.RLock()
-	if tello.ctrlConnected {
-		tello.ctrlMu.RUnlock()
-		return errors.New("Tello already connected")
-	}
-	if tello.ctrlConnecting {
-		tello.ctrlMu.RUnlock()
-		return errors.New("Tello connection attempt already in progress")
-	}
-	tello.ctrlMu.RUnlock()
-	tello.filesListeners = map[chan FileData]chan FileData{}
+	_, span := tello.startSpan(context.Background(), "tello.connect")
+	defer func() { endSpan(span, err) }()
+	span.SetAttribute("net.peer.name", udpAddr)
+	span.SetAttribute("net.peer.port", droneUDPPort)
 
-	droneAddr, err := net.ResolveUDPAddr("udp", udpAddr+":"+strconv.Itoa(droneUDPPort))
-	if err != nil {
-		return err
-	}
-	localAddr, err := net.ResolveUDPAddr("udp", ":"+strconv.Itoa(localUDPPort))
-	if err != nil {
-		return err
-	}
+	// first check that we are not already connected or connecting
 	tello.ctrlMu.Lock()
-	tello.ctrlConn, err = net.DialUDP("udp", localAddr, droneAddr)
+	switch tello.ctrlState {
+	case connConnected:
+		tello.ctrlMu.Unlock()
+		return ErrAlreadyConnected
+	case connConnecting:
+		tello.ctrlMu.Unlock()
+		return ErrConnecting
+	}
+	tello.ctrlState = connConnecting
+	tello.resetSession()
 	tello.ctrlMu.Unlock()
+
+	tello.fdMu.Lock()
+	tello.fd.LightStrengthUpdated = time.Time{} // don't judge this connection by the last one
+	tello.fd.SessionFlyTime, tello.fd.SessionDistance = 0, 0
+	tello.fd.SerialNumber = tello.cfg.serialNumber
+	tello.fileTemp = fileInternal{}
+	tello.odoLastStatus, tello.odoLastMVO = time.Time{}, time.Time{}
+	tello.fdMu.Unlock()
+	tello.setLogDataSeen(false)
+	tello.clearAcks()
+
+	conn, err := tello.cfg.getTransport().DialControl(udpAddr, droneUDPPort, bindPort(localUDPPort))
 	if err != nil {
-		if tello.ctrlConn != nil {
-			tello.ctrlConn.Close()
-		}
+		tello.setCtrlState(connDisconnected)
 		return err
 	}
-
-	// start the control listener Goroutine
-	go tello.controlResponseListener()
+	done := tello.startControl(conn)
+	span.AddEvent("dialled")
 
 	// say hello to the Tello
-	tello.sendConnectRequest(defaultTelloVideoPort)
+	tello.sendConnectRequest(uint16(tello.advertisedVideoPort()))
+	span.AddEvent("connection request sent")
 
-	// wait up to 3 seconds for the Tello to respond
-	for t := 0; t < 10; t++ {
-		tello.ctrlMu.RLock()
-		if tello.ctrlConnected {
-			tello.ctrlMu.RUnlock()
-			break
+	// wait for the Tello to respond, repeating the request in case it was lost
+	clock := tello.cfg.getClock()
+	retry := tello.cfg.getConnectRetry()
+	deadline := clock.Now().Add(tello.cfg.getConnectTimeout())
+	resendAt := clock.Now().Add(retry.Backoff)
+	for clock.Now().Before(deadline) && !tello.ControlConnected() {
+		if retry.Retries > 0 && !clock.Now().Before(resendAt) {
+			tello.sendConnectRequest(uint16(tello.advertisedVideoPort()))
+			span.AddEvent("connection request resent")
+			retry.Retries--
+			retry.Backoff *= 2
+			resendAt = clock.Now().Add(retry.Backoff)
+		}
+		wait := connectPollPeriod
+		if until := resendAt.Sub(clock.Now()); retry.Retries > 0 && until < wait {
+			wait = until
+		}
+		select {
+		case <-done:
+			return ErrNotConnected // ControlDisconnect() was called while we were waiting
+		case <-clock.After(wait):
 		}
-		tello.ctrlMu.RUnlock()
-		time.Sleep(333 * time.Millisecond)
 	}
-	tello.ctrlMu.RLock()
-	if !tello.ctrlConnected {
-		tello.ctrlMu.RUnlock()
-		tello.ctrlMu.Lock()
-		tello.ctrlConn.Close()
-		tello.ctrlConnecting = false
-		tello.ctrlMu.Unlock()
-		return errors.New("Timeout waiting for response to connection request from Tello")
+	if !tello.ControlConnected() {
+		tello.closeControl(connConnecting)
+		return &TimeoutError{Op: "response to connection request"}
 	}
-	tello.ctrlMu.RUnlock()
+	span.AddEvent("connection acknowledged")
+
+	// these help to diagnose drones which are region-limited or not activated
+	tello.GetActivationTime()
+	tello.GetWifiRegion()
 
-	// start the keepalive transmitter
-	go tello.keepAlive()
+	// start the keepalive transmitter, RTT measurement, bitrate control and telemetry watchdog
+	go tello.keepAlive(done)
+	go tello.rttProber(done)
+	go tello.bitrateAdapter(done)
+	go tello.telemetryWatchdog(done)
 
 	return nil
 }
 
-// ControlConnectDefault attempts to connect to a Tello on the default network addresses.
+// ControlConnectDefault attempts to connect to a Tello on the default network addresses
+// (or those set via NewTello() options).
 // It then starts listening for responses on the control channel and processes them in a Goroutine.
 func (tello *Tello) ControlConnectDefault() (err error) {
-	return tello.ControlConnect(defaultTelloAddr, defaultTelloControlPort, defaultLocalControlPort)
+	return tello.ControlConnect(tello.cfg.getDroneAddr(), tello.cfg.getDronePort(), tello.cfg.getLocalCtrlPort())
 }
 
 // ControlDisconnect stops the control channel listener and closes the connection to a Tello.
+// Any video connection is also closed, as are the channels returned by ListenFiles().
+// Commands waiting for an acknowledgement fail with ErrNotConnected.
+// It is safe to call ControlDisconnect at any time, even if not connected, and the Tello
+// may then be connected again with ControlConnect().
 func (tello *Tello) ControlDisconnect() {
 	// TODO should/can we tell the Tello we are disconnecting?
-	tello.ctrlMu.Lock()
-	tello.ctrlConn.Close()
-	tello.ctrlConnected = false
-	tello.ctrlMu.Unlock()
-	tello.fdMu.Lock()
+	tello.VideoDisconnect()
+	tello.releaseVideoReservation()
+	tello.closeControl(connConnected)
+	tello.filesMu.Lock()
 	for l := range tello.filesListeners {
 		delete(tello.filesListeners, l)
 		close(l)
 	}
-	tello.fdMu.Unlock()
+	tello.filesMu.Unlock()
+}
+
+// startControl starts the control listener and packet writer Goroutines for conn,
+// the returned channel is closed when the connection ends.
+func (tello *Tello) startControl(conn net.Conn) (done chan struct{}) {
+	done = make(chan struct{})
+	stopped := make(chan struct{})
+	tello.traffic.reset(tello.now())
+	writerStopped := tello.startWriter(conn, done)
+	tello.ctrlMu.Lock()
+	tello.ctrlConn = conn
+	tello.ctrlDone = done
+	tello.ctrlStopped = stopped
+	tello.ctrlWriterStopped = writerStopped
+	tello.ctrlMu.Unlock()
+	go tello.controlResponseListener(conn, done, stopped)
+	return done
+}
+
+// closeControl closes the control connection if it is currently in the given state
+// (or connecting).  The listener and writer are stopped before the connection is closed,
+// so no read or write can race with the Close.
+func (tello *Tello) closeControl(from connState) {
+	tello.ctrlMu.Lock()
+	if tello.ctrlState != from && tello.ctrlState != connConnecting {
+		tello.ctrlMu.Unlock()
+		return
+	}
+	conn, done, stopped, writerStopped := tello.ctrlConn, tello.ctrlDone, tello.ctrlStopped, tello.ctrlWriterStopped
+	tello.ctrlDone, tello.ctrlStopped, tello.ctrlWriterStopped = nil, nil, nil
+	wasConnected, lost := tello.ctrlState == connConnected, tello.ctrlLostContact
+	tello.ctrlState = connDisconnected
+	tello.ctrlLostContact = false
+	tello.ctrlMu.Unlock()
+
+	if done != nil {
+		close(done)
+		<-stopped
+		<-writerStopped
+	}
+	if conn != nil {
+		conn.Close()
+	}
+	if wasConnected {
+		tello.emitEvent(EvDisconnected, lost)
+	}
+}
+
+// resetSession clears the per-connection control state so that a reused Tello starts each
+// connection centred and in normal mode.  Sequence numbers carry on from the last connection,
+// so a drone which is still flying cannot mistake new commands for stale ones.
+// ctrlMu must be held.
+func (tello *Tello) resetSession() {
+	tello.ctrlVideoPort = 0
+	tello.ctrlRx, tello.ctrlRy, tello.ctrlLx, tello.ctrlLy = 0, 0, 0, 0
+	tello.ctrlSent = stickAxes{}
+	tello.ctrlSticksExpire = time.Time{}
+	tello.ctrlSportsMode = false
+	tello.ctrlBouncing, tello.ctrlBounceReq = false, 0
+	tello.ctrlStopLanding = false
+	tello.ctrlSmartVideo = 0
+}
+
+func (tello *Tello) setCtrlState(cs connState) {
+	tello.ctrlMu.Lock()
+	tello.ctrlState = cs
+	tello.ctrlMu.Unlock()
+}
+
+// Sequence returns the sequence number of the last command sent.  Numbering continues across
+// reconnections of this Tello, pass the number to WithSequence() to continue it in another.
+func (tello *Tello) Sequence() (seq uint16) {
+	tello.ctrlMu.RLock()
+	seq = tello.ctrlSeq
+	tello.ctrlMu.RUnlock()
+	return seq
 }
 
 // ControlConnected returns true if we are currently connected.
 func (tello *Tello) ControlConnected() (c bool) {
 	tello.ctrlMu.RLock()
-	c = tello.ctrlConnected
+	c = tello.ctrlState == connConnected
 	tello.ctrlMu.RUnlock()
 	return c
 }
@@ -178,7 +383,7 @@ func (tello *Tello) ControlConnected() (c bool) {
 // 	defer tello.ctrlMu.Unlock()
 
 // 	tello.ctrlSeq++
-// 	pkt := newPacket(ptGet, msgQueryAttitude, tello.ctrlSeq, 0)
+// 	pkt := newPacket(ptGet, MsgQueryAttitude, tello.ctrlSeq, 0)
 // 	tello.ctrlConn.Write(packetToBuffer(pkt))
 // }
 
@@ -197,8 +402,8 @@ func (tello *Tello) GetLowBatteryThreshold() {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptGet, msgQueryLowBattThresh, tello.ctrlSeq, 0)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	pkt := newPacket(ptGet, MsgQueryLowBattThresh, tello.ctrlSeq, 0)
+	tello.enqueue(packetToBuffer(pkt))
 }
 
 // GetMaxHeight asks the Tello to send us its current maximum permitted height.
@@ -207,8 +412,8 @@ func (tello *Tello) GetMaxHeight() {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptGet, msgQueryHeightLimit, tello.ctrlSeq, 0)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	pkt := newPacket(ptGet, MsgQueryHeightLimit, tello.ctrlSeq, 0)
+	tello.enqueue(packetToBuffer(pkt))
 }
 
 // GetSSID asks the Tello to send us its current Wifi AP ID.
@@ -217,8 +422,47 @@ func (tello *Tello) GetSSID() {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptGet, msgQuerySSID, tello.ctrlSeq, 0)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	pkt := newPacket(ptGet, MsgQuerySSID, tello.ctrlSeq, 0)
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// GetActivationTime asks the Tello when it was activated, which is stored in FlightData.ActivationTime.
+// This is requested automatically on connection; a drone which has never been activated may refuse to take off.
+func (tello *Tello) GetActivationTime() {
+	tello.ctrlMu.Lock()
+	defer tello.ctrlMu.Unlock()
+
+	tello.ctrlSeq++
+	pkt := newPacket(ptGet, MsgQueryActivationTime, tello.ctrlSeq, 0)
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// GetWifiRegion asks the Tello for its WiFi country/region code, which is stored in FlightData.WifiRegion.
+// This is requested automatically on connection; the region limits the drone's WiFi transmit power.
+func (tello *Tello) GetWifiRegion() {
+	tello.ctrlMu.Lock()
+	defer tello.ctrlMu.Unlock()
+
+	tello.ctrlSeq++
+	pkt := newPacket(ptGet, MsgQueryWifiRegion, tello.ctrlSeq, 0)
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// WithSerialNumber sets the drone's serial number, which is reported in FlightData.SerialNumber and
+// included in the names of the files we save, so that those from the drones of a swarm can be told apart.
+// N.B. The binary protocol has no serial number query, one may be read beforehand with sdk.Client's
+// GetSerialNumber() or from the label in the drone's battery bay.
+func WithSerialNumber(serial string) Option {
+	return func(tello *Tello) { tello.cfg.serialNumber = serial }
+}
+
+// fileNamePrefix returns "tello", followed by sep and the drone's serial number if it is known,
+// to start the names of the files we save.
+func fileNamePrefix(serial, sep string) string {
+	if serial == "" {
+		return "tello"
+	}
+	return "tello" + sep + serial
 }
 
 // GetVersion asks the Tello to send us its Version string
@@ -227,8 +471,20 @@ func (tello *Tello) GetVersion() {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptGet, msgQueryVersion, tello.ctrlSeq, 0)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	pkt := newPacket(ptGet, MsgQueryVersion, tello.ctrlSeq, 0)
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// GetLoaderVersionAndWait asks the Tello for the version of its boot loader and waits for the reply.
+func (tello *Tello) GetLoaderVersionAndWait(ctx context.Context) (version string, err error) {
+	reply, err := tello.sendAndWait(ctx, ptGet, MsgQueryLoaderVersion, nil)
+	if err != nil {
+		return "", err
+	}
+	if err = resultError(MsgQueryLoaderVersion, reply); err != nil || len(reply) < 2 {
+		return "", err
+	}
+	return strings.TrimRight(string(reply[1:]), "\x00"), nil
 }
 
 // SetLowBatteryThreshold set the warning threshold to a percentage value (0-100).
@@ -238,9 +494,15 @@ func (tello *Tello) SetLowBatteryThreshold(thr uint8) {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgSetLowBattThresh, tello.ctrlSeq, 1)
+	pkt := newPacket(ptSet, MsgSetLowBattThresh, tello.ctrlSeq, 1)
 	pkt.payload[0] = thr
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// SetLowBatteryThresholdCtx is as SetLowBatteryThreshold() but waits for the Tello to acknowledge it,
+// see TakeOffAndWait().
+func (tello *Tello) SetLowBatteryThresholdCtx(ctx context.Context, thr uint8) (err error) {
+	return tello.commandAndWait(ctx, ptSet, MsgSetLowBattThresh, []byte{thr})
 }
 
 // StreamFlightData starts a Goroutine which sends FlightData to a channel.
@@ -248,101 +510,176 @@ func (tello *Tello) SetLowBatteryThreshold(thr uint8) {
 //   If asAvailable is false then updates are sent every periodMs
 //   N.B. This streamer does not block on the channel, so unconsumed updates are lost.
 func (tello *Tello) StreamFlightData(asAvailable bool, periodMs time.Duration) (<-chan FlightData, error) {
-	tello.fdMu.RLock()
-	if tello.fdStreaming {
-		tello.fdMu.RUnlock()
-		return nil, errors.New("Already streaming data from this Tello")
-	}
-	tello.fdMu.RUnlock()
-	fdChan := make(chan FlightData, 2)
 	if asAvailable {
 		log.Fatal("asAvailable FlightData stream not yet implemented") // TODO
-	} else {
-		go func() {
-			for {
-				if !tello.ControlConnected() {
-					tello.fdMu.Lock()
-					tello.fdStreaming = false
-					tello.fdMu.Unlock()
-					close(fdChan)
-					return
-				}
-				tello.fdMu.RLock()
-				select {
-				case fdChan <- tello.fd:
-				default:
-				}
-				tello.fdMu.RUnlock()
-				time.Sleep(periodMs * time.Millisecond)
-			}
-		}()
 	}
+	// check and claim the streamer in one step, so concurrent callers cannot both start one
 	tello.fdMu.Lock()
+	if tello.fdStreaming {
+		tello.fdMu.Unlock()
+		return nil, errors.New("Already streaming data from this Tello")
+	}
 	tello.fdStreaming = true
 	tello.fdMu.Unlock()
 
+	fdChan := make(chan FlightData, 2)
+	go func() {
+		for {
+			if !tello.ControlConnected() {
+				tello.fdMu.Lock()
+				tello.fdStreaming = false
+				tello.fdMu.Unlock()
+				close(fdChan)
+				return
+			}
+			select {
+			case fdChan <- tello.GetFlightData():
+			default:
+			}
+			time.Sleep(periodMs * time.Millisecond)
+		}
+	}()
 	return fdChan, nil
 }
 
-func (tello *Tello) controlResponseListener() {
+// readUntilDone reads from conn using short deadlines, so that it can return errListenerDone
+// promptly once done is closed.
+func readUntilDone(conn net.Conn, buff []byte, done <-chan struct{}) (n int, err error) {
+	for {
+		select {
+		case <-done:
+			return 0, errListenerDone
+		default:
+		}
+		conn.SetReadDeadline(time.Now().Add(listenerPollPeriod))
+		n, err = conn.Read(buff)
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			continue
+		}
+		return n, err
+	}
+}
+
+func (tello *Tello) controlResponseListener(conn net.Conn, done <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	defer tello.recoverListener("control", func() { tello.disconnectControl(conn) })
 	buff := make([]byte, 4096)
 
 	for {
-		n, err := tello.ctrlConn.Read(buff)
+		n, err := readUntilDone(conn, buff, done)
+		if err == errListenerDone {
+			return
+		}
+		if err == nil {
+			tello.traffic.recordRecv(buff[:n], tello.now())
+		}
 
 		// the initial connect response is different...
 		tello.ctrlMu.RLock()
-		connecting := tello.ctrlConnecting
+		connecting := tello.ctrlState == connConnecting
 		tello.ctrlMu.RUnlock()
-		if connecting && n == 11 {
-			if bytes.ContainsAny(buff, "conn_ack:") {
-				// TODO handle returned video port?
-				//log.Printf("Debug: conn_ack received, buffer len: %d\n", n)
-				tello.ctrlMu.Lock()
-				tello.ctrlConnecting = false
-				tello.ctrlConnected = true
-				tello.ctrlMu.Unlock()
-			} else {
-				log.Printf("Unexpected response to connection request <%s>\n", string(buff))
+		if n == 11 && bytes.HasPrefix(buff[:n], []byte("conn_ack:")) {
+			// the ack carries the port the drone will stream video to, it is sent again
+			// when VideoConnect() asks for video on a different port
+			//log.Printf("Debug: conn_ack received, buffer len: %d\n", n)
+			tello.ctrlMu.Lock()
+			if tello.ctrlState == connConnecting {
+				tello.ctrlState = connConnected
 			}
+			if tello.ctrlState == connConnected {
+				tello.ctrlVideoPort = int(buff[9]) | int(buff[10])<<8
+			}
+			tello.ctrlMu.Unlock()
+			continue
+		}
+		if connecting && n == 11 {
+			tello.logf("Unexpected response to connection request <%s>\n", string(buff))
 			continue
 		}
 
 		if err != nil {
-			if strings.HasSuffix(err.Error(), "use of closed network connection") {
+			if errors.Is(err, net.ErrClosed) {
 				return
 			}
-			log.Printf("Network Read Error - %v\n", err)
+			tello.logf("Network Read Error - %v\n", err)
 		} else {
-			if buff[0] != msgHdr {
-				log.Printf("Unexpected network message from Tello <%d>\n", buff[0])
+			if pkt, err := parsePacket(buff[:n]); err != nil {
+				tello.traffic.recordBad()
+				tello.logf("%v\n", err)
 			} else {
-				pkt := bufferToPacket(buff)
+				tello.hookPacket(DirReceived, buff[:n], tello.now())
+				if !tello.traffic.checkSequence(pkt) {
+					tello.reack(pkt) // the drone repeats some messages until they are acknowledged
+					continue
+				}
+				if pkt.messageID == MsgDoBounce {
+					tello.bounceAck(pkt) // before the ack is passed on, so that BounceCtx() returns with the mode known
+				}
+				tello.resolveAck(pkt)
+				handled := tello.dispatchMessage(pkt)
 				switch pkt.messageID {
-				case msgDoLand: // ignore for now
-				case msgDoTakeoff: // ignore for now
-				case msgDoTakePic:
-					log.Printf("Take Picture echoed with response: <%v>\n", pkt.payload)
-				case msgFileSize: // initial response to Take Picture command
+				case MsgDoLand:
+					// the same message is used to start and stop landing
+					tello.ctrlMu.Lock()
+					stopping := tello.ctrlStopLanding
+					tello.ctrlStopLanding = false
+					tello.ctrlMu.Unlock()
+					if tello.checkCommandResult(pkt) {
+						tello.updateFlightState(func(cur FlightState) FlightState {
+							switch {
+							case stopping && cur == StateLanding:
+								return StateHovering
+							case !stopping && cur.IsAirborne():
+								return StateLanding
+							}
+							return cur
+						})
+					}
+				case MsgDoTakeoff:
+					if tello.checkCommandResult(pkt) {
+						tello.updateFlightState(func(cur FlightState) FlightState {
+							if cur == StateGrounded {
+								return StateTakingOff
+							}
+							return cur
+						})
+					}
+				case MsgDoFlip, MsgDoThrowTakeoff, MsgDoPalmLand, MsgDoSmartVideo, MsgSetVideoBitrate:
+					tello.checkCommandResult(pkt)
+				case MsgDoBounce:
+					// handled by bounceAck() before the ack was resolved
+				case MsgDoCalibration:
+					tello.calibrationAck(pkt)
+				case MsgDoTakePic:
+					tello.photoResult(pkt.payload)
+				case MsgFileSize: // initial response to Take Picture command
+					if len(pkt.payload) < 7 {
+						tello.photoAborted(0, 0, "short file size message")
+						break
+					}
 					ft, fs, fID := payloadToFileInfo(pkt.payload)
 					//log.Printf("Take pic response: type: %d, size: %d, ID: %d\n", ft, fs, fID)
 					if ft != FtJPEG {
-						log.Printf("Unexpected file type <%d> received in response to take picture command\n", ft)
+						tello.photoAborted(fID, int(fs), fmt.Sprintf("unexpected file type %d", ft))
 					} else {
 						// set up for receiving picture chunks
 						// tello.files[fID] = FileData{FileType: ft, FileSize: fs, FileBytes: make([]byte, fs)}
 						tello.fdMu.Lock()
 						//tello.filesBusy = true
+						old := tello.fileTemp
 						tello.fileTemp.fID = fID
 						tello.fileTemp.filetype = ft
 						tello.fileTemp.expectedSize = int(fs)
 						tello.fileTemp.accumSize = 0
 						tello.fileTemp.pieces = make([]filePiece, 1024)
 						tello.fdMu.Unlock()
+						if old.accumSize > 0 && old.fID != fID {
+							tello.photoAborted(old.fID, old.expectedSize, "superseded by a new picture")
+						}
 						// acknowledge the file size
 						tello.sendFileSize()
 					}
-				case msgFileData:
+				case MsgFileData:
 					thisChunk := payloadToFileChunk(pkt.payload)
 					tello.fdMu.Lock()
 					//log.Printf("Got pic chunk - ID: %d, Piece: %d, Chunk: %d\n", thisChunk.fID, thisChunk.pieceNum, thisChunk.chunkNum)
@@ -363,19 +700,22 @@ func (tello *Tello) controlResponseListener() {
 							tello.fileTemp.pieces[thisChunk.pieceNum].numChunks++
 						}
 					}
+					pieceDone := tello.fileTemp.pieces[thisChunk.pieceNum].numChunks == 8
+					accumSize := tello.fileTemp.accumSize
+					fileDone := accumSize == tello.fileTemp.expectedSize
 					tello.fdMu.Unlock()
-					if tello.fileTemp.pieces[thisChunk.pieceNum].numChunks == 8 {
+					if pieceDone {
 						// piece has 8 chunks, it's complete
 						tello.sendFileAckPiece(0, thisChunk.fID, thisChunk.pieceNum)
 						//log.Printf("Acknowledging piece: %d\n", thisChunk.pieceNum)
 					}
-					if tello.fileTemp.accumSize == tello.fileTemp.expectedSize {
+					if fileDone {
 						tello.sendFileAckPiece(1, thisChunk.fID, thisChunk.pieceNum)
-						tello.sendFileDone(thisChunk.fID, tello.fileTemp.accumSize)
+						tello.sendFileDone(thisChunk.fID, accumSize)
 						tello.reassembleFile()
 					}
-				//case msgFileDone:
-				case msgFlightStatus:
+				//case MsgFileDone:
+				case MsgFlightStatus:
 					tmpFd := payloadToFlightData(pkt.payload)
 					tello.fdMu.Lock()
 					// not all fields are sent...
@@ -400,6 +740,7 @@ func (tello *Tello) controlResponseListener() {
 					tello.fd.FrontLSC = tmpFd.FrontLSC
 					tello.fd.FrontOut = tmpFd.FrontOut
 					tello.fd.GravityState = tmpFd.GravityState
+					tello.fd.GroundSpeed = tmpFd.GroundSpeed
 					tello.fd.Height = tmpFd.Height
 					tello.fd.ImuCalibrationState = tmpFd.ImuCalibrationState
 					tello.fd.ImuState = tmpFd.ImuState
@@ -408,66 +749,97 @@ func (tello *Tello) controlResponseListener() {
 					tello.fd.OutageRecording = tmpFd.OutageRecording
 					tello.fd.PowerState = tmpFd.PowerState
 					tello.fd.PressureState = tmpFd.PressureState
+					tello.fd.TemperatureHigh = tmpFd.TemperatureHigh
 					tello.fd.ThrowFlyTimer = tmpFd.ThrowFlyTimer
 					tello.fd.VerticalSpeed = -tmpFd.VerticalSpeed // seems to be inverted
 					tello.fd.WindState = tmpFd.WindState
+					tello.smoothSpeeds()
+					tello.accumulateFlyTime(tello.now())
+					tello.recordBattery(tello.now())
 					tello.fdMu.Unlock()
-				case msgLightStrength:
+					tello.superviseBattery()
+					tello.checkWarnings(tmpFd)
+					tello.checkCalibration(tmpFd.ImuCalibrationState)
+					tello.updateFlightState(func(cur FlightState) FlightState {
+						return nextFlightState(cur, tmpFd, tello.now().Sub(tello.stateSince))
+					})
+					tello.recordHistory()
+				case MsgLightStrength:
 					// Light strength is sent regularly by the drone, seems a good candidate for "still here"-type functionality
 					// log.Printf("Light strength received - Size: %d, Type: %d\n", pkt.size13, pkt.packetType)
 					tello.fdMu.Lock()
 					tello.fd.LightStrength = uint8(pkt.payload[0])
-					tello.fd.LightStrengthUpdated = time.Now()
+					tello.fd.LightStrengthUpdated = tello.now()
 					tello.fdMu.Unlock()
-				case msgLogConfig: // ignore for now
-				case msgLogHeader:
-					//log.Printf("Log Header received - Size: %d, Type: %d\n%s\n% x\n", pkt.size13, pkt.packetType, pkt.payload, pkt.payload)
-					tello.ackLogHeader(pkt.payload[0:2])
-				case msgLogData:
+				case MsgLogConfig: // ignore for now
+				case MsgLogHeader:
+					tello.ackLogHeader(pkt.payload)
+				case MsgLogData:
 					//log.Printf("Log messgae payload: % x\n", pkt.payload)
+					tello.setLogDataSeen(true)
 					tello.parseLogPacket(pkt.payload)
-				case msgQueryHeightLimit:
+					tello.recordFlightLog(pkt)
+				case MsgQueryHeightLimit:
 					//log.Printf("Max Height Limit recieved: % x\n", pkt.payload)
 					tello.fdMu.Lock()
 					tello.fd.MaxHeight = uint8(pkt.payload[1])
 					tello.fdMu.Unlock()
-				case msgQueryLowBattThresh:
+				case MsgQueryLowBattThresh:
 					tello.fdMu.Lock()
 					tello.fd.LowBatteryThreshold = uint8(pkt.payload[1])
 					tello.fdMu.Unlock()
-				case msgQuerySSID:
+				case MsgQuerySSID:
 					//log.Printf("SSID recieved: % x\n", pkt.payload)
 					tello.fdMu.Lock()
 					tello.fd.SSID = string(pkt.payload[2:])
 					tello.fdMu.Unlock()
-				case msgQueryVersion:
+				case MsgQueryActivationTime:
+					if at, ok := payloadToActivationTime(pkt.payload); ok {
+						tello.fdMu.Lock()
+						tello.fd.ActivationTime = at
+						tello.fdMu.Unlock()
+					} else {
+						tello.logf("Unexpected activation time reply: % x\n", pkt.payload)
+					}
+				case MsgQueryWifiRegion:
+					if region, ok := payloadToWifiRegion(pkt.payload); ok {
+						tello.fdMu.Lock()
+						tello.fd.WifiRegion = region
+						tello.fdMu.Unlock()
+					} else {
+						tello.logf("Unexpected WiFi region reply: % x\n", pkt.payload)
+					}
+				case MsgQueryVersion:
 					//log.Printf("Version recieved: % x\n", pkt.payload)
 					tello.fdMu.Lock()
 					tello.fd.Version = string(pkt.payload[1:])
 					tello.fdMu.Unlock()
-				case msgQueryVideoBitrate:
-					log.Printf("Video Bitrate recieved: % x\n", pkt.payload)
+				case MsgQueryVideoBitrate:
+					tello.logf("Video Bitrate recieved: % x\n", pkt.payload)
+					vbr := VBR(pkt.payload[0])
 					tello.fdMu.Lock()
-					tello.fd.VideoBitrate = VBR(pkt.payload[0])
+					tello.fd.VideoBitrate = vbr
 					tello.fdMu.Unlock()
-					log.Printf("Got Video Bitrate: %d\n", tello.fd.VideoBitrate)
-				case msgSetDateTime:
+					tello.logf("Got Video Bitrate: %d\n", vbr)
+				case MsgSetDateTime:
 					//log.Println("DateTime request received from Tello")
 					tello.sendDateTime()
-				case msgSetLowBattThresh: // ignore for now (could be error return)
-				case msgSmartVideoStatus: // ignore
-				case msgSwitchPicVideo: // ignore
-				case msgWifiStrength:
+				case MsgSetLowBattThresh, MsgSwitchPicVideo:
+					tello.checkCommandResult(pkt)
+				case MsgSmartVideoStatus: // ignore
+				case MsgWifiStrength:
 					// log.Printf("Wifi strength received - Size: %d, Type: %d\n", pkt.size13, pkt.packetType)
 					tello.fdMu.Lock()
 					tello.fd.WifiStrength = uint8(pkt.payload[0])
 					tello.fd.WifiInterference = uint8(pkt.payload[1])
+					tello.fd.WifiStrengthSmoothed = tello.filters.wifi.update(tello.cfg.smoothing.Wifi, float64(tello.fd.WifiStrength))
 					//log.Printf("Parsed Wifi Strength: %d, Interference: %d\n", tello.fd.WifiStrength, tello.fd.WifiInterference)
 					tello.fdMu.Unlock()
+					tello.link.recordWifi()
 				default:
-					log.Printf("Unknown message from Tello - ID: <%d>, Size %d, Type: %d\n% x\n",
-						pkt.messageID, pkt.size13, pkt.packetType, pkt.payload)
+					tello.unknownMessage(pkt, handled)
 				}
+				tello.checkWatchers()
 			}
 		}
 
@@ -480,8 +852,7 @@ func (tello *Tello) sendConnectRequest(videoPort uint16) {
 	msgBuff[9] = byte(videoPort & 0xff)
 	msgBuff[10] = byte(videoPort >> 8)
 	tello.ctrlMu.Lock()
-	tello.ctrlConnecting = true
-	tello.ctrlConn.Write(msgBuff)
+	tello.enqueue(msgBuff)
 	tello.ctrlMu.Unlock()
 }
 
@@ -495,7 +866,7 @@ func (tello *Tello) sendDateTime() {
 	pkt.header = msgHdr
 	pkt.toDrone = true
 	pkt.packetType = ptData1
-	pkt.messageID = msgSetDateTime
+	pkt.messageID = MsgSetDateTime
 	tello.ctrlSeq++
 	pkt.sequence = tello.ctrlSeq
 	pkt.payload = make([]byte, 15)
@@ -522,37 +893,58 @@ func (tello *Tello) sendDateTime() {
 	buff := packetToBuffer(pkt)
 
 	// send the command packet
-	tello.ctrlConn.Write(buff)
+	tello.enqueue(buff)
 	//log.Println("Sent DateTime Response")
 }
 
-func (tello *Tello) keepAlive() {
+func (tello *Tello) keepAlive(done chan struct{}) {
 	var sinceLastLSupdate time.Duration
-	for {
+	var lastBeat time.Time
+	clock := tello.cfg.getClock()
+	period := tello.cfg.getKeepAlivePeriod()
+	for tick := 0; ; tick++ {
 		if tello.ControlConnected() {
-			tello.sendStickUpdate()
+			tello.sendKeepAlive(tick, &lastBeat)
 			tello.fdMu.RLock()
 			if tello.fd.LightStrengthUpdated.IsZero() {
 				// we've not started yet - fake it
 				//log.Println("DEBUG - No last light strength update time detected")
 				sinceLastLSupdate = time.Second
 			} else {
-				sinceLastLSupdate = time.Since(tello.fd.LightStrengthUpdated)
+				sinceLastLSupdate = clock.Now().Sub(tello.fd.LightStrengthUpdated)
 			}
 			tello.fdMu.RUnlock()
-			if sinceLastLSupdate >= lightStrengthTimeout {
+			if sinceLastLSupdate >= tello.cfg.getContactTimeout() {
 				// too long since we last received a LS update, must have lost contact
-				log.Println("Seem to have lost contact")
-				log.Printf("Last update was %v ago", sinceLastLSupdate)
-				tello.ctrlMu.Lock()
-				tello.ctrlConnected = false
-				tello.ctrlMu.Unlock()
+				tello.logln("Seem to have lost contact")
+				tello.logf("Last update was %v ago", sinceLastLSupdate)
+				tello.applyFailsafe()
+				tello.disconnectSession(done)
 				return // disconnected - so stop this Goroutine
 			}
 		} else {
 			return // we've disconnected
 		}
-		time.Sleep(keepAlivePeriodMs * time.Millisecond)
+		select {
+		case <-done:
+			return // this connection has been closed
+		case <-clock.After(period):
+		}
+	}
+}
+
+// applyFailsafe carries out the configured FailsafePolicy.
+func (tello *Tello) applyFailsafe() {
+	tello.applyPolicy(tello.cfg.failsafe)
+}
+
+func (tello *Tello) applyPolicy(policy FailsafePolicy) {
+	switch policy {
+	case FailsafeHover:
+		tello.Hover()
+	case FailsafeLand:
+		tello.Hover()
+		tello.Land()
 	}
 }
 
@@ -584,7 +976,7 @@ func (tello *Tello) StartStickListener() (sChan chan<- StickMessage, err error)
 	tello.stickListeningMu.Unlock()
 	// start the stick listener
 	tello.stopStickListener = make(chan bool)
-	tello.stickChan = make(chan StickMessage, 10)
+	tello.stickChan = make(chan StickMessage, tello.cfg.getStickBufSize())
 	go tello.stickListener()
 	return tello.stickChan, nil
 }
@@ -600,13 +992,22 @@ func (tello *Tello) StopStickListener() {
 
 // UpdateSticks does a one-off update of the stick values which are then sent to the Tello.
 // N.B. All four axes are updated on every call to this func.
+// The update is ignored if it asks for nonzero throttle while the Tello is disarmed, see SetSticks().
 func (tello *Tello) UpdateSticks(sm StickMessage) {
-	tello.ctrlMu.Lock()
+	if err := tello.SetSticks(sm); err != nil {
+		tello.logf("Warning: ignoring stick update - %v\n", err)
+	}
+}
+
+// setSticks stores new stick values.  ctrlMu must be held.
+func (tello *Tello) setSticks(sm StickMessage) {
 	tello.ctrlLx = sm.Lx
 	tello.ctrlLy = sm.Ly
 	tello.ctrlRx = sm.Rx
 	tello.ctrlRy = sm.Ry
-	tello.ctrlMu.Unlock()
+	if ttl := tello.cfg.stickTTL; ttl > 0 {
+		tello.ctrlSticksExpire = tello.now().Add(ttl)
+	}
 }
 
 func jsFloatToTello(fv float64) uint64 {
@@ -621,6 +1022,9 @@ func jsInt16ToTello(sv int16) uint64 {
 }
 
 func (tello *Tello) sendStickUpdate() {
+	yaw, ref := tello.headlessHeading()
+	override, overridden := tello.autopilotOverride(yaw, ref)
+	readings := tello.readEnvelope()
 	tello.ctrlMu.Lock()
 	defer tello.ctrlMu.Unlock()
 	// create the command packet
@@ -630,15 +1034,26 @@ func (tello *Tello) sendStickUpdate() {
 	pkt.header = msgHdr
 	pkt.toDrone = true
 	pkt.packetType = ptData2
-	pkt.messageID = msgSetStick
+	pkt.messageID = MsgSetStick
 	pkt.sequence = 0
-	pkt.payload = make([]byte, 11)
+	var payload [stickPayloadSize]byte
+	pkt.payload = payload[:]
 
 	// This packing of the joystick data is just vile...
-	packedAxes := jsInt16ToTello(tello.ctrlRx) & 0x07ff
-	packedAxes |= (jsInt16ToTello(tello.ctrlRy) & 0x07ff) << 11
-	packedAxes |= (jsInt16ToTello(tello.ctrlLy) & 0x07ff) << 22
-	packedAxes |= (jsInt16ToTello(tello.ctrlLx) & 0x07ff) << 33
+	tello.expireSticks()
+	target := tello.stickTargets(yaw, ref)
+	if overridden {
+		target = override
+	}
+	target = tello.limitEnvelope(target, readings)
+	if tello.ctrlDisarmed {
+		target.ly = 0 // whatever the source, eg. an autopilot
+	}
+	sticks := tello.nextSticks(target)
+	packedAxes := jsInt16ToTello(sticks.rx) & 0x07ff
+	packedAxes |= (jsInt16ToTello(sticks.ry) & 0x07ff) << 11
+	packedAxes |= (jsInt16ToTello(sticks.ly) & 0x07ff) << 22
+	packedAxes |= (jsInt16ToTello(sticks.lx) & 0x07ff) << 33
 	if tello.ctrlSportsMode {
 		packedAxes |= 1 << 44
 	}
@@ -658,11 +1073,11 @@ func (tello *Tello) sendStickUpdate() {
 	pkt.payload[9] = byte(ms & 0xff)
 	pkt.payload[10] = byte(ms >> 8)
 
-	// pack the packet into raw format and calculate CRCs etc.
-	buff := packetToBuffer(pkt)
+	// pack the packet into raw format and calculate CRCs etc., reusing an old buffer as this happens often
+	buff := appendPacket(tello.sendQ.stickBuffer(), pkt)
 
 	// send the command packet
-	tello.ctrlConn.Write(buff)
+	tello.enqueue(buff)
 
 	// log.Printf("Stick Vals: Lx: %d, Ly: %d, Rx: %d, Ry: %d - Stick packet: %x\n",
 	//	tello.ctrlLx, tello.ctrlLy, tello.ctrlRx, tello.ctrlRy, buff)
@@ -22,9 +22,18 @@
 package tello
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/SMerrony/tello/sim"
 )
 
 func TestJsFloatToTello(t *testing.T) {
@@ -161,3 +170,317 @@ func TestGetSSID(t *testing.T) {
 	drone.ControlDisconnect()
 	log.Println("Disconnected normally from Tello")
 }
+
+// startFakeDrone starts a minimal loopback drone which only answers connection requests,
+// it returns the port it is listening on.
+func startFakeDrone(t *testing.T) int {
+	fake, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fake.Close() })
+	go func() {
+		buff := make([]byte, 1024)
+		for {
+			n, addr, err := fake.ReadFromUDP(buff)
+			if err != nil {
+				return
+			}
+			if bytes.HasPrefix(buff[:n], []byte("conn_req:")) {
+				// reply with a different video port to the one requested
+				fake.WriteToUDP([]byte("conn_ack:\x39\x30"), addr)
+			} else if n >= minPktSize && buff[0] == msgHdr {
+				if pkt := bufferToPacket(buff[:n]); pkt.messageID == MsgQueryVersion {
+					reply := newPacket(ptGet, MsgQueryVersion, pkt.sequence, 3)
+					copy(reply.payload, "\x00v1")
+					fake.WriteToUDP(packetToBuffer(reply), addr)
+				}
+			}
+		}
+	}()
+	return fake.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestLifecycleAnyOrder(t *testing.T) {
+	drone := new(Tello)
+	// none of these should panic or block when not connected
+	drone.ControlDisconnect()
+	drone.VideoDisconnect()
+	drone.VideoDisconnect()
+
+	port := startFakeDrone(t)
+	for i := 0; i < 3; i++ {
+		if err := drone.ControlConnect("127.0.0.1", port, 0); err != nil {
+			t.Fatalf("Connect %d failed with %v", i, err)
+		}
+		if err := drone.ControlConnect("127.0.0.1", port, 0); err == nil {
+			t.Error("Expected an error connecting twice")
+		}
+		if !drone.ControlConnected() {
+			t.Error("Expected to be connected")
+		}
+		if drone.VideoPort() != 12345 {
+			t.Errorf("Expected negotiated video port 12345, got %d", drone.VideoPort())
+		}
+		drone.ControlDisconnect()
+		drone.ControlDisconnect()
+		if drone.ControlConnected() {
+			t.Error("Expected to be disconnected")
+		}
+	}
+}
+
+// simDrone returns a Tello configured to talk to a new simulator
+func simDrone(t *testing.T, opts ...Option) (*Tello, *sim.Drone) {
+	s := sim.New(sim.WithVideo())
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	opts = append([]Option{WithAddress("127.0.0.1", s.Addr().Port), WithLocalControlPort(AnyPort), WithVideoPort(AnyPort)}, opts...)
+	drone := NewTello(opts...)
+	t.Cleanup(drone.ControlDisconnect)
+	return drone, s
+}
+
+func TestReconnectResetsSession(t *testing.T) {
+	drone, _ := simDrone(t)
+	var lastSeq uint16
+	for i := 0; i < 3; i++ {
+		if err := drone.ControlConnectDefault(); err != nil {
+			t.Fatalf("Connect %d failed with %v", i, err)
+		}
+		drone.ctrlMu.RLock()
+		rx, sports, seq := drone.ctrlRx, drone.ctrlSportsMode, drone.ctrlSeq
+		drone.ctrlMu.RUnlock()
+		if rx != 0 || sports {
+			t.Errorf("Connect %d inherited sticks %d and sports mode %v", i, rx, sports)
+		}
+		if seq < lastSeq {
+			t.Errorf("Connect %d restarted the sequence at %d after %d", i, seq, lastSeq)
+		}
+		if drone.VideoPort() <= 0 {
+			t.Errorf("Connect %d has no acknowledged video port", i)
+		}
+		if _, err := drone.VideoConnectDefault(); err != nil {
+			t.Fatalf("Video connect %d failed with %v", i, err)
+		}
+		if err := drone.TakeOffAndWait(context.Background()); err != nil {
+			t.Errorf("Takeoff after connect %d failed with %v", i, err)
+		}
+		drone.SetSportsMode(true)
+		drone.UpdateSticks(StickMessage{Rx: 10000})
+		drone.ctrlMu.RLock()
+		lastSeq = drone.ctrlSeq
+		drone.ctrlMu.RUnlock()
+
+		drone.ControlDisconnect()
+		if drone.ControlConnected() {
+			t.Errorf("Still connected after disconnect %d", i)
+		}
+		drone.videoMu.Lock()
+		video := drone.videoConn
+		drone.videoMu.Unlock()
+		if video != nil {
+			t.Errorf("Video still connected after disconnect %d", i)
+		}
+	}
+}
+
+func TestDisconnectFailsPendingCommands(t *testing.T) {
+	drone, s := simDrone(t)
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	s.SetMuted(true)
+	errc := make(chan error, 1)
+	go func() { errc <- drone.TakeOffAndWait(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+	drone.ControlDisconnect()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrNotConnected) {
+			t.Errorf("Expected ErrNotConnected, got %v", err)
+		}
+	case <-time.After(ackTimeout):
+		t.Fatal("Pending command did not fail on disconnect")
+	}
+
+	// the Tello can then be reused
+	s.SetMuted(false)
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatalf("Reconnect failed with %v", err)
+	}
+	if err := drone.TakeOffAndWait(context.Background()); err != nil {
+		t.Errorf("Takeoff after reconnect failed with %v", err)
+	}
+}
+
+func TestLostContactDisconnects(t *testing.T) {
+	drone, s := simDrone(t, WithContactTimeout(300*time.Millisecond), WithKeepAlivePeriod(20*time.Millisecond))
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := drone.VideoConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	events, stop := drone.ListenEvents()
+	defer stop()
+	s.SetMuted(true)
+	deadline := time.Now().Add(3 * time.Second)
+	for drone.ControlConnected() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if drone.ControlConnected() {
+		t.Fatal("Expected lost contact to disconnect")
+	}
+	select {
+	case ev := <-events:
+		if ev.Type != EvDisconnected || ev.Data != true {
+			t.Errorf("Expected EvDisconnected reporting lost contact, got %v %v", ev.Type, ev.Data)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected an EvDisconnected event")
+	}
+	drone.videoMu.Lock()
+	video := drone.videoConn
+	drone.videoMu.Unlock()
+	if video != nil {
+		t.Error("Expected lost contact to close the video connection")
+	}
+	s.SetMuted(false)
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatalf("Reconnect after lost contact failed with %v", err)
+	}
+}
+
+func TestSerialNumber(t *testing.T) {
+	drone, _ := simDrone(t, WithSerialNumber("0TQSIM00000001"))
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	if sn := drone.GetFlightData().SerialNumber; sn != "0TQSIM00000001" {
+		t.Fatalf("Expected the configured serial number, got %q", sn)
+	}
+	rec, err := drone.StartRecording(RecordingConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	if name := filepath.Base(rec.IndexPath()); !strings.HasPrefix(name, "tello-0TQSIM00000001-") {
+		t.Errorf("Expected the serial number in the recording's name, got %s", name)
+	}
+}
+
+// lossyDrone is a fake drone which ignores the first drop connection requests and acknowledges
+// the rest, it returns its port and a function counting the requests.
+func lossyDrone(t *testing.T, drop int) (port int, requests func() int) {
+	fake, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fake.Close() })
+	var (
+		mu sync.Mutex
+		n  int
+	)
+	go func() {
+		buff := make([]byte, 64)
+		for {
+			l, from, err := fake.ReadFromUDP(buff)
+			if err != nil {
+				return
+			}
+			if l != 11 || !bytes.HasPrefix(buff, []byte("conn_req:")) {
+				continue
+			}
+			mu.Lock()
+			n++
+			ack := n > drop
+			mu.Unlock()
+			if ack {
+				fake.WriteToUDP(append([]byte("conn_ack:"), buff[9:11]...), from)
+			}
+		}
+	}()
+	return fake.LocalAddr().(*net.UDPAddr).Port, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return n
+	}
+}
+
+func TestConnectRetry(t *testing.T) {
+	port, requests := lossyDrone(t, 2)
+	drone := NewTello(WithAddress("127.0.0.1", port), WithLocalControlPort(AnyPort), WithVideoPort(AnyPort),
+		WithConnectRetry(ConnectRetry{Retries: 3, Backoff: 20 * time.Millisecond}))
+	defer drone.ControlDisconnect()
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatalf("Expected the third connection request to succeed, got %v", err)
+	}
+	if n := requests(); n != 3 {
+		t.Errorf("Expected 3 connection requests, got %d", n)
+	}
+
+	port, requests = lossyDrone(t, 1)
+	drone = NewTello(WithAddress("127.0.0.1", port), WithLocalControlPort(AnyPort), WithVideoPort(AnyPort),
+		WithConnectRetry(ConnectRetry{}), WithConnectTimeout(300*time.Millisecond))
+	defer drone.ControlDisconnect()
+	var te *TimeoutError
+	if err := drone.ControlConnectDefault(); !errors.As(err, &te) {
+		t.Errorf("Expected a timeout without retries, got %v", err)
+	}
+	if n := requests(); n != 1 {
+		t.Errorf("Expected 1 connection request, got %d", n)
+	}
+}
+
+func TestSequenceAcrossReconnects(t *testing.T) {
+	s := sim.New(sim.WithStrictSequence())
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	newDrone := func(opts ...Option) *Tello {
+		drone := NewTello(append([]Option{WithAddress("127.0.0.1", s.Addr().Port), WithLocalControlPort(AnyPort),
+			WithVideoPort(AnyPort)}, opts...)...)
+		if err := drone.ControlConnectDefault(); err != nil {
+			t.Fatal(err)
+		}
+		return drone
+	}
+	command := func(drone *Tello) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		return drone.SetVideoBitrateCtx(ctx, Vbr2M)
+	}
+
+	first := newDrone()
+	for i := 0; i < 5; i++ {
+		if err := command(first); err != nil {
+			t.Fatalf("Command %d failed with %v", i, err)
+		}
+	}
+	first.ControlDisconnect()
+	if err := first.ControlConnectDefault(); err != nil {
+		t.Fatal(err)
+	}
+	if err := command(first); err != nil {
+		t.Errorf("Command after reconnecting failed with %v", err)
+	}
+	first.ControlDisconnect()
+
+	// a new Tello starts numbering again, so the drone ignores it...
+	restarted := newDrone()
+	if err := command(restarted); err == nil {
+		t.Error("Expected the drone to ignore a regressed sequence number")
+	}
+	restarted.ControlDisconnect()
+
+	// ...unless it continues from the first
+	continued := newDrone(WithSequence(first.Sequence()))
+	defer continued.ControlDisconnect()
+	if err := command(continued); err != nil {
+		t.Errorf("Command continuing the sequence failed with %v", err)
+	}
+}
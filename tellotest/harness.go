@@ -0,0 +1,138 @@
+// harness.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+/*
+Package tellotest provides a harness for testing code which uses the tello package, with a
+simulated drone from the sim package and the real client talking over loopback UDP.
+
+Both sides run on a virtual Clock, so keepalives, contact and acknowledgement timeouts and
+battery failsafes can be exercised in a fraction of the real time they would take, eg.
+
+	h := tellotest.New(t)
+	drone := h.NewTello(tello.WithFailsafe(tello.FailsafeLand))
+	if err := h.Run(drone.ControlConnectDefault); err != nil {
+		t.Fatal(err)
+	}
+	h.Sim.SetMuted(true) // out of range
+	h.WaitFor("contact to be lost", 10*time.Second, func() bool { return !drone.ControlConnected() })
+
+Time is advanced in small steps, with a moment of real time after each step for the client and
+simulator goroutines and the network to catch up; a heavily loaded machine may therefore need a
+longer Settle time.
+*/
+package tellotest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello"
+	"github.com/SMerrony/tello/sim"
+)
+
+const (
+	step          = 10 * time.Millisecond // virtual time advanced per step
+	defaultSettle = time.Millisecond      // real time allowed per step for everything to catch up
+	runLimit      = time.Minute           // virtual time after which Run() gives up
+)
+
+// Harness connects clients to a simulated drone on loopback UDP, with time under the test's control.
+type Harness struct {
+	Clock  *Clock
+	Sim    *sim.Drone
+	Settle time.Duration // real time allowed after each step of virtual time, the default is 1ms
+	tb     testing.TB
+}
+
+// New starts a simulated drone with the given options on a free loopback port, it is
+// stopped when the test finishes.
+func New(tb testing.TB, opts ...sim.Option) *Harness {
+	tb.Helper()
+	h := &Harness{Clock: NewClock(), Settle: defaultSettle, tb: tb}
+	h.Sim = sim.New(append(opts, sim.WithClock(h.Clock))...)
+	if err := h.Sim.Listen("127.0.0.1:0"); err != nil {
+		tb.Fatalf("Could not start simulator - %v", err)
+	}
+	tb.Cleanup(func() { h.Sim.Close() })
+	return h
+}
+
+// NewTello returns a client set up to reach the simulator using the virtual clock and free local
+// ports, the given options are applied afterwards.  It is disconnected when the test finishes.
+func (h *Harness) NewTello(opts ...tello.Option) *tello.Tello {
+	h.tb.Helper()
+	base := []tello.Option{
+		tello.WithAddress("127.0.0.1", h.Sim.Addr().Port),
+		tello.WithLocalControlPort(h.freePort()),
+		tello.WithVideoPort(h.freePort()),
+		tello.WithClock(h.Clock),
+	}
+	drone := tello.NewTello(append(base, opts...)...)
+	h.tb.Cleanup(drone.ControlDisconnect)
+	return drone
+}
+
+func (h *Harness) freePort() int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		h.tb.Fatalf("Could not find a free port - %v", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// Advance moves virtual time on by d, a step at a time.
+func (h *Harness) Advance(d time.Duration) {
+	for ; d > 0; d -= step {
+		h.Clock.Advance(step)
+		time.Sleep(h.Settle)
+	}
+}
+
+// WaitFor advances virtual time until cond returns true, failing the test if it has not done so
+// within limit.
+func (h *Harness) WaitFor(what string, limit time.Duration, cond func() bool) {
+	h.tb.Helper()
+	for waited := time.Duration(0); !cond(); waited += step {
+		if waited >= limit {
+			h.tb.Fatalf("Timed out after %v waiting for %s", limit, what)
+		}
+		h.Advance(step)
+	}
+}
+
+// Run calls fn, eg. a blocking connect or command, advancing virtual time until it returns.
+func (h *Harness) Run(fn func() error) error {
+	h.tb.Helper()
+	result := make(chan error, 1)
+	go func() { result <- fn() }()
+	for waited := time.Duration(0); waited < runLimit; waited += step {
+		select {
+		case err := <-result:
+			return err
+		default:
+			h.Advance(step)
+		}
+	}
+	h.tb.Fatalf("Call still blocked after %v", runLimit)
+	return nil
+}
@@ -0,0 +1,130 @@
+// harness_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tellotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello"
+	"github.com/SMerrony/tello/sim"
+)
+
+func TestClock(t *testing.T) {
+	c := NewClock()
+	start := c.Now()
+	late, early := c.After(2*time.Second), c.After(time.Second)
+	c.Advance(1500 * time.Millisecond)
+	select {
+	case at := <-early:
+		if at != start.Add(time.Second) {
+			t.Errorf("Fired at %v", at.Sub(start))
+		}
+	default:
+		t.Error("Expected early timer to fire")
+	}
+	select {
+	case <-late:
+		t.Error("Late timer fired too soon")
+	default:
+	}
+	c.Advance(time.Second)
+	if len(late) != 1 || c.Now() != start.Add(2500*time.Millisecond) {
+		t.Error("Expected late timer to have fired")
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	h := New(t)
+	drone := h.NewTello()
+	if err := h.Run(drone.ControlConnectDefault); err != nil {
+		t.Fatal(err)
+	}
+	h.Advance(20 * time.Second)
+	if !drone.ControlConnected() {
+		t.Error("Expected to stay connected")
+	}
+}
+
+func TestConnectTimeout(t *testing.T) {
+	h := New(t)
+	h.Sim.SetMuted(true)
+	drone := h.NewTello(tello.WithConnectTimeout(10 * time.Second))
+	start := h.Clock.Now()
+	if err := h.Run(drone.ControlConnectDefault); err == nil {
+		t.Fatal("Expected connection to time out")
+	}
+	if waited := h.Clock.Now().Sub(start); waited < 10*time.Second {
+		t.Errorf("Gave up after only %v", waited)
+	}
+}
+
+func TestContactLostAndReconnect(t *testing.T) {
+	h := New(t)
+	drone := h.NewTello(tello.WithFailsafe(tello.FailsafeHover))
+	if err := h.Run(drone.ControlConnectDefault); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Run(func() error { return drone.TakeOffAndWait(context.Background()) }); err != nil {
+		t.Fatal(err)
+	}
+	h.WaitFor("takeoff", 5*time.Second, func() bool { return drone.GetFlightState() == tello.StateHovering })
+
+	h.Sim.SetMuted(true)
+	h.WaitFor("contact to be lost", 6*time.Second, func() bool { return !drone.ControlConnected() })
+	// with nobody in control the drone should land itself
+	h.WaitFor("simulator to land", 20*time.Second, func() bool { return h.Sim.State().Phase == sim.Grounded })
+
+	h.Sim.SetMuted(false)
+	if err := h.Run(drone.ControlConnectDefault); err != nil {
+		t.Fatalf("Could not reconnect - %v", err)
+	}
+	h.WaitFor("flight status", time.Second, func() bool { return drone.GetFlightState() == tello.StateGrounded })
+}
+
+func TestLowBatteryFailsafe(t *testing.T) {
+	h := New(t)
+	drone := h.NewTello(tello.WithLowBatteryAction(tello.FailsafeLand))
+	events, stop := drone.ListenEvents()
+	defer stop()
+	if err := h.Run(drone.ControlConnectDefault); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Run(func() error { return drone.TakeOffAndWait(context.Background()) }); err != nil {
+		t.Fatal(err)
+	}
+	h.WaitFor("takeoff", 5*time.Second, func() bool { return drone.GetFlightState() == tello.StateHovering })
+
+	h.Sim.SetBattery(6)
+	h.WaitFor("landing", 10*time.Second, func() bool { return h.Sim.State().Phase == sim.Grounded })
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == tello.EvBatteryReserve {
+				return
+			}
+		default:
+			t.Fatal("Expected a battery reserve event")
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// trace.go
+
+// Optional tracing of command round-trips and connection phases.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import "context"
+
+// Tracer starts Spans, by default a no-op Tracer is used.  It has the same shape as the
+// OpenTelemetry tracing API so an adapter is only a few lines, eg.
+//
+//	type otelTracer struct{ t trace.Tracer }
+//
+//	func (o otelTracer) Start(ctx context.Context, name string) (context.Context, tello.Span) {
+//		ctx, s := o.t.Start(ctx, name)
+//		return ctx, otelSpan{s}
+//	}
+//
+// and install it with WithTracer(otelTracer{otel.Tracer("tello")}).
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	AddEvent(name string)
+	RecordError(err error)
+	End()
+}
+
+// WithTracer sets the Tracer used for command round-trips and connection phases.
+func WithTracer(tracer Tracer) Option {
+	return func(tello *Tello) { tello.cfg.tracer = tracer }
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) AddEvent(name string)                       {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
+
+func (c *config) getTracer() Tracer {
+	if c.tracer == nil {
+		return noopTracer{}
+	}
+	return c.tracer
+}
+
+// startSpan starts a Span with the configured Tracer.
+func (tello *Tello) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	return tello.cfg.getTracer().Start(ctx, name)
+}
+
+// endSpan records err, if any, and ends the Span.
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
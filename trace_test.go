@@ -0,0 +1,75 @@
+// tello project trace_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type recordedSpan struct {
+	name   string
+	attrs  map[string]interface{}
+	events []string
+	err    error
+	ended  bool
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (rt *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	s := &recordedSpan{name: name, attrs: map[string]interface{}{}}
+	rt.spans = append(rt.spans, s)
+	return ctx, s
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *recordedSpan) AddEvent(name string)                       { s.events = append(s.events, name) }
+func (s *recordedSpan) RecordError(err error)                      { s.err = err }
+func (s *recordedSpan) End()                                       { s.ended = true }
+
+func TestTracing(t *testing.T) {
+	// the default tracer does nothing
+	if _, err := new(Tello).sendAndWait(context.Background(), ptSet, MsgDoLand, nil); err == nil {
+		t.Error("Expected an error when not connected")
+	}
+
+	rt := &recordingTracer{}
+	drone := NewTello(WithTracer(rt))
+	_, err := drone.sendAndWait(context.Background(), ptSet, MsgDoLand, nil)
+	if len(rt.spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(rt.spans))
+	}
+	s := rt.spans[0]
+	if s.name != "tello.command" || !s.ended || s.err != err {
+		t.Errorf("Unexpected span %+v", s)
+	}
+	if s.attrs["tello.message_id"] != int(MsgDoLand) {
+		t.Errorf("Expected message ID attribute, got %v", s.attrs)
+	}
+}
@@ -0,0 +1,78 @@
+// transport.go
+
+// Pluggable network transports.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"net"
+	"strconv"
+)
+
+// Transport opens the network connections to a drone, UDPTransport is used unless another is set
+// via WithTransport().  Other implementations might tunnel or relay the packets, or connect to a
+// simulator or an in-memory fake drone.
+// The returned connections must be packet-oriented: each Read returns a single packet and each
+// Write sends one, and they must support read deadlines.
+type Transport interface {
+	// DialControl opens the two-way control connection between localPort and the drone.
+	DialControl(droneAddr string, dronePort, localPort int) (net.Conn, error)
+	// ListenPackets opens a receive-only connection on localPort, eg. for video or state data.
+	ListenPackets(localPort int) (net.Conn, error)
+}
+
+// UDPTransport is the default Transport which talks directly to the drone over UDP.
+type UDPTransport struct{}
+
+// DialControl opens a UDP connection from localPort to the drone.
+func (UDPTransport) DialControl(droneAddr string, dronePort, localPort int) (net.Conn, error) {
+	raddr, err := net.ResolveUDPAddr("udp", droneAddr+":"+strconv.Itoa(dronePort))
+	if err != nil {
+		return nil, err
+	}
+	laddr, err := net.ResolveUDPAddr("udp", ":"+strconv.Itoa(localPort))
+	if err != nil {
+		return nil, err
+	}
+	return net.DialUDP("udp", laddr, raddr)
+}
+
+// ListenPackets listens for UDP packets on localPort.
+func (UDPTransport) ListenPackets(localPort int) (net.Conn, error) {
+	laddr, err := net.ResolveUDPAddr("udp", ":"+strconv.Itoa(localPort))
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP("udp", laddr)
+}
+
+// WithTransport sets the Transport used to reach the drone.
+func WithTransport(transport Transport) Option {
+	return func(tello *Tello) { tello.cfg.transport = transport }
+}
+
+func (c *config) getTransport() Transport {
+	if c.transport == nil {
+		return UDPTransport{}
+	}
+	return c.transport
+}
@@ -0,0 +1,75 @@
+// tello project transport_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+)
+
+// pipeTransport connects the control channel to an in-memory fake drone.
+type pipeTransport struct {
+	drone net.Conn // the fake drone's end of the control connection
+}
+
+func (pt *pipeTransport) DialControl(droneAddr string, dronePort, localPort int) (net.Conn, error) {
+	ours, theirs := net.Pipe()
+	pt.drone = theirs
+	go func() {
+		buff := make([]byte, 1024)
+		for {
+			n, err := theirs.Read(buff)
+			if err != nil {
+				return
+			}
+			if bytes.HasPrefix(buff[:n], []byte("conn_req:")) {
+				theirs.Write([]byte("conn_ack:\x39\x30"))
+			}
+		}
+	}()
+	return ours, nil
+}
+
+func (pt *pipeTransport) ListenPackets(localPort int) (net.Conn, error) {
+	return nil, errors.New("Not supported")
+}
+
+func TestWithTransport(t *testing.T) {
+	pt := &pipeTransport{}
+	drone := NewTello(WithTransport(pt))
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatalf("Connect via in-memory transport failed with %v", err)
+	}
+	if drone.VideoPort() != 12345 {
+		t.Errorf("Expected video port from the fake drone, got %d", drone.VideoPort())
+	}
+	if _, err := drone.VideoConnectDefault(); err == nil {
+		t.Error("Expected the transport's error from VideoConnectDefault")
+	}
+	drone.ControlDisconnect()
+	if drone.ControlConnected() {
+		t.Error("Expected to be disconnected")
+	}
+	pt.drone.Close()
+}
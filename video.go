@@ -22,71 +22,147 @@
 package tello
 
 import (
-	"log"
+	"context"
 	"net"
-	"strconv"
 )
 
 const (
 	defaultTelloVideoPort = 6038
+	defaultVideoBufSize   = 100
 )
 
-// VideoConnect attempts to connect to a Tello video channel at the provided addr and starts a listener.
+// VideoConnect listens for the Tello's video stream on localUDPPort and starts a listener.
+// The video arrives from the drone at udpAddr, but is accepted on localUDPPort from any address.
+// If the control connection asked the drone to stream to a different port, the drone is asked to
+// switch to localUDPPort, so the advertised port always matches the one listened on.
 // A channel of raw H.264 video frames is returned along with any error.
 // The channel will be closed if the connection is lost.
-func (tello *Tello) VideoConnect(udpAddr string, droneUDPPort int) (<-chan []byte, error) {
-	droneAddr, err := net.ResolveUDPAddr("udp", ":"+strconv.Itoa(droneUDPPort))
-	if err != nil {
-		return nil, err
+func (tello *Tello) VideoConnect(udpAddr string, localUDPPort int) (<-chan []byte, error) {
+	tello.videoMu.Lock()
+	if tello.videoConn != nil {
+		tello.videoMu.Unlock()
+		return nil, ErrAlreadyConnected
 	}
-	tello.videoConn, err = net.ListenUDP("udp", droneAddr)
-	if err != nil {
-		log.Printf("Error: VideoConnect - ListenUDP failed with %v\n", err)
-		return nil, err
+	localUDPPort = bindPort(localUDPPort)
+	if tello.videoConn = tello.takeVideoReservation(localUDPPort); tello.videoConn == nil {
+		var err error
+		tello.videoConn, err = tello.cfg.getTransport().ListenPackets(localUDPPort)
+		if err != nil {
+			tello.logf("Error: VideoConnect - ListenPackets failed with %v\n", err)
+			tello.videoConn = nil
+			tello.videoMu.Unlock()
+			return nil, err
+		}
 	}
-	tello.videoStopChan = make(chan bool, 2)
-	tello.videoChan = make(chan []byte, 100)
-	go tello.videoResponseListener()
+	localUDPPort = boundPort(tello.videoConn, localUDPPort)
+	tello.videoPort = localUDPPort
+	tello.videoChan = make(chan []byte, tello.cfg.getVideoBufSize())
+	tello.videoDone = make(chan struct{})
+	tello.videoStopped = make(chan struct{})
+	tello.vstats.reset()
+	tello.resetStampedFrame()
+	go tello.videoResponseListener(tello.videoConn, tello.videoChan, tello.videoDone, tello.videoStopped)
+	videoChan := tello.videoChan
+	tello.videoMu.Unlock()
 	//log.Println("Video connection setup complete")
-	return tello.videoChan, nil
+
+	tello.ctrlMu.RLock()
+	readvertise := tello.ctrlState == connConnected && tello.ctrlVideoPort != localUDPPort
+	tello.ctrlMu.RUnlock()
+	if readvertise {
+		tello.sendConnectRequest(uint16(localUDPPort))
+	}
+	return videoChan, nil
 }
 
-// VideoConnectDefault attempts to connect to a Tello video channel using default addresses, then starts a listener.
+// VideoConnectDefault attempts to connect to a Tello video channel using default addresses
+// (or those set via NewTello() options), then starts a listener.
 // A channel of raw H.264 video frames is returned along with any error.
+// If the drone has acknowledged a video port when connecting, that port is used.
 func (tello *Tello) VideoConnectDefault() (<-chan []byte, error) {
-	return tello.VideoConnect(defaultTelloAddr, defaultTelloVideoPort)
+	return tello.VideoConnect(tello.cfg.getDroneAddr(), tello.VideoPort())
+}
+
+// advertisedVideoPort is the port to ask the drone to stream video to: the one we are listening
+// on if video is connected, otherwise the configured port, reserving one if that is AnyPort.
+func (tello *Tello) advertisedVideoPort() int {
+	tello.videoMu.Lock()
+	defer tello.videoMu.Unlock()
+	switch {
+	case tello.videoPort != 0:
+		return tello.videoPort
+	case tello.cfg.videoPort == AnyPort:
+		return tello.reserveVideoPort()
+	}
+	return tello.cfg.getVideoPort()
+}
+
+// VideoPort returns the local UDP port the drone streams video to; this is the port
+// negotiated when the control connection was established, or the configured port (which
+// may be AnyPort) if we have not yet connected.
+func (tello *Tello) VideoPort() (port int) {
+	tello.ctrlMu.RLock()
+	port = tello.ctrlVideoPort
+	tello.ctrlMu.RUnlock()
+	if port == 0 {
+		port = tello.cfg.getVideoPort()
+	}
+	return port
 }
 
 // VideoDisconnect closes the connection to the video channel.
+// It is safe to call VideoDisconnect at any time, even if not connected.
 func (tello *Tello) VideoDisconnect() {
 	// TODO Should we tell the Tello we are stopping video listening?
-	tello.videoStopChan <- true
-	tello.videoConn.Close()
+	tello.videoMu.Lock()
+	conn, done, stopped := tello.videoConn, tello.videoDone, tello.videoStopped
+	tello.videoConn, tello.videoDone, tello.videoStopped, tello.videoPort = nil, nil, nil, 0
+	tello.videoMu.Unlock()
+	if conn == nil {
+		return
+	}
+	close(done)
+	<-stopped // the listener closes the video channel
+	conn.Close()
 }
 
-func (tello *Tello) videoResponseListener() {
+// dropVideoConn closes conn from the listener side, if it is still the current video connection.
+func (tello *Tello) dropVideoConn(conn net.Conn) {
+	tello.videoMu.Lock()
+	if tello.videoConn == conn {
+		conn.Close()
+		tello.videoConn, tello.videoDone, tello.videoStopped, tello.videoPort = nil, nil, nil, 0
+	}
+	tello.videoMu.Unlock()
+}
+
+func (tello *Tello) videoResponseListener(conn net.Conn, videoChan chan []byte, done <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	defer close(videoChan)
+	defer tello.recoverListener("video", func() { tello.dropVideoConn(conn) })
 	for {
 		vbuf := make([]byte, 2048)
-		if tello.videoConn == nil {
-			// must have been closed
-			log.Println("Info: videoResponseListener closing")
-			close(tello.videoChan)
+		n, err := readUntilDone(conn, vbuf, done)
+		if err == errListenerDone {
+			tello.logln("Info: Closing Video Channel")
 			return
 		}
-		n, _, err := tello.videoConn.ReadFromUDP(vbuf)
 		if err != nil {
-			log.Printf("Error reading from video channel - %v\n", err)
-			close(tello.videoChan)
+			tello.logf("Error reading from video channel - %v\n", err)
+			tello.dropVideoConn(conn)
 			return
 		}
+		if n < 2 {
+			continue
+		}
+		tello.link.recordVideoPacket(vbuf[0], vbuf[1])
+		tello.vstats.recordPacket(vbuf[0], vbuf[2:n], tello.now())
 		select {
-		case tello.videoChan <- vbuf[2:n]:
-		case <-tello.videoStopChan:
-			log.Println("Info: Closing Video Channel")
-			close(tello.videoChan)
-			return
+		case videoChan <- vbuf[2:n]:
 		default: // so we don't block
 		}
+		tello.stampVideo(vbuf[0], vbuf[1], vbuf[2:n])
+		tello.queueForDecoding(vbuf[2:n])
 	}
 }
 
@@ -96,8 +172,8 @@ func (tello *Tello) GetVideoBitrate() {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptGet, msgQueryVideoBitrate, tello.ctrlSeq, 0)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	pkt := newPacket(ptGet, MsgQueryVideoBitrate, tello.ctrlSeq, 0)
+	tello.enqueue(packetToBuffer(pkt))
 }
 
 // SetVideoBitrate ask the Tello to use the specified bitrate (or auto) for video encoding.
@@ -106,9 +182,14 @@ func (tello *Tello) SetVideoBitrate(vbr VBR) {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgSetVideoBitrate, tello.ctrlSeq, 1)
+	pkt := newPacket(ptSet, MsgSetVideoBitrate, tello.ctrlSeq, 1)
 	pkt.payload[0] = byte(vbr)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// SetVideoBitrateCtx is as SetVideoBitrate() but waits for the Tello to acknowledge it, see TakeOffAndWait().
+func (tello *Tello) SetVideoBitrateCtx(ctx context.Context, vbr VBR) (err error) {
+	return tello.commandAndWait(ctx, ptSet, MsgSetVideoBitrate, []byte{byte(vbr)})
 }
 
 // GetVideoSpsPps asks the Tello to send SPS and PPS in video stream.
@@ -118,8 +199,9 @@ func (tello *Tello) GetVideoSpsPps() {
 	tello.ctrlMu.Lock()
 	defer tello.ctrlMu.Unlock()
 
-	pkt := newPacket(ptData2, msgQueryVideoSPSPPS, 0, 0)
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	pkt := newPacket(ptData2, MsgQueryVideoSPSPPS, 0, 0)
+	tello.enqueue(packetToBuffer(pkt))
+	tello.vstats.requestKeyframe(tello.now())
 }
 
 // SetVideoNormal requests video format to be (native) ~4:3 ratio.
@@ -128,9 +210,14 @@ func (tello *Tello) SetVideoNormal() {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgSwitchPicVideo, tello.ctrlSeq, 1)
+	pkt := newPacket(ptSet, MsgSwitchPicVideo, tello.ctrlSeq, 1)
 	pkt.payload[0] = vmNormal
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// SetVideoNormalCtx is as SetVideoNormal() but waits for the Tello to acknowledge it, see TakeOffAndWait().
+func (tello *Tello) SetVideoNormalCtx(ctx context.Context) (err error) {
+	return tello.commandAndWait(ctx, ptSet, MsgSwitchPicVideo, []byte{vmNormal})
 }
 
 // SetVideoWide requests video format to be (cropped) 16:9 ratio.
@@ -139,7 +226,12 @@ func (tello *Tello) SetVideoWide() {
 	defer tello.ctrlMu.Unlock()
 
 	tello.ctrlSeq++
-	pkt := newPacket(ptSet, msgSwitchPicVideo, tello.ctrlSeq, 1)
+	pkt := newPacket(ptSet, MsgSwitchPicVideo, tello.ctrlSeq, 1)
 	pkt.payload[0] = vmWide
-	tello.ctrlConn.Write(packetToBuffer(pkt))
+	tello.enqueue(packetToBuffer(pkt))
+}
+
+// SetVideoWideCtx is as SetVideoWide() but waits for the Tello to acknowledge it, see TakeOffAndWait().
+func (tello *Tello) SetVideoWideCtx(ctx context.Context) (err error) {
+	return tello.commandAndWait(ctx, ptSet, MsgSwitchPicVideo, []byte{vmWide})
 }
@@ -22,7 +22,9 @@
 package tello
 
 import (
+	"bytes"
 	"log"
+	"net"
 	"testing"
 	"time"
 )
@@ -72,3 +74,69 @@ func TestVideoBitrate(t *testing.T) {
 	drone.ControlDisconnect()
 	log.Println("Disconnected normally from Tello")
 }
+
+func freeUDPPort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestVideoConnectAdvertisesPort(t *testing.T) {
+	configured, other := freeUDPPort(t), freeUDPPort(t)
+	drone, fake := pushingDrone(t, WithVideoPort(configured))
+	if p := drone.advertisedVideoPort(); p != configured {
+		t.Errorf("Expected to advertise the configured port %d, got %d", configured, p)
+	}
+	drone.ctrlMu.Lock()
+	drone.ctrlVideoPort = configured
+	drone.ctrlMu.Unlock()
+
+	// connecting video on the advertised port sends nothing, on another port asks the drone to switch
+	expectConnReq := func(port int) {
+		t.Helper()
+		buff := make([]byte, 64)
+		for {
+			fake.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			n, _, err := fake.conn.ReadFromUDP(buff)
+			if err != nil {
+				t.Fatalf("Expected a connection request for port %d - %v", port, err)
+			}
+			if !bytes.HasPrefix(buff[:n], []byte("conn_req:")) {
+				continue // stick packets
+			}
+			if got := int(buff[9]) | int(buff[10])<<8; got != port {
+				t.Fatalf("Expected a connection request for port %d, got %d", port, got)
+			}
+			fake.pushRaw(append([]byte("conn_ack:"), buff[9], buff[10]))
+			return
+		}
+	}
+	if _, err := drone.VideoConnect("127.0.0.1", other); err != nil {
+		t.Fatal(err)
+	}
+	if p := drone.advertisedVideoPort(); p != other {
+		t.Errorf("Expected to advertise the listening port %d, got %d", other, p)
+	}
+	expectConnReq(other)
+	deadline := time.Now().Add(2 * time.Second)
+	for drone.VideoPort() != other {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the acknowledged video port to be %d, got %d", other, drone.VideoPort())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	drone.VideoDisconnect()
+	if _, err := drone.VideoConnectDefault(); err != nil { // the acknowledged port, so no request
+		t.Fatal(err)
+	}
+	drone.VideoDisconnect()
+	if _, err := drone.VideoConnect("127.0.0.1", configured); err != nil {
+		t.Fatal(err)
+	}
+	expectConnReq(configured)
+	drone.VideoDisconnect()
+}
@@ -0,0 +1,171 @@
+// videostats.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	videoSmoothing            = 0.1             // weight of each new sample in the frame pacing averages
+	keyframeReplyTimeout      = 2 * time.Second // a keyframe request with no SPS after this long is counted as lost
+	nalSPS               byte = 7               // H.264 NAL unit type of a sequence parameter set, which precedes each keyframe
+)
+
+// VideoStats describes the timing of the video stream, see Tello.VideoStats().
+// The counters are reset each time the video is connected.
+//
+// Latency is an estimate of the delay from the camera to the application, which is useful for
+// comparing buffer sizes and bitrates objectively.  It is derived from the time between asking for a
+// keyframe with GetVideoSpsPps() and its arrival, less the time for the request to reach the drone
+// (half the control RTT) and the average wait for the next frame to be captured (half a frame
+// interval), plus the time taken to drain the packets queued in the channel returned by VideoConnect().
+type VideoStats struct {
+	Packets         uint64
+	Bytes           uint64
+	Frames          uint64        // numbered frames started, whether or not they were complete
+	Keyframes       uint64        // frames which began with an SPS
+	FrameRate       float64       // smoothed frames per second
+	FrameInterval   time.Duration // smoothed time between the starts of consecutive frames
+	FrameJitter     time.Duration // smoothed variation between consecutive frame intervals, as per RFC 3550
+	MaxFrameGap     time.Duration // the longest time between the starts of consecutive frames
+	KeyframeLatency RTTStats      // from GetVideoSpsPps() to the arrival of the SPS; Lost counts requests never answered
+	Queued          int           // packets waiting to be read from the video channel
+	QueueDelay      time.Duration // estimated time for the Queued packets to be read, at the current packet rate
+	Latency         time.Duration // the estimated glass-to-app latency, zero until a keyframe latency has been measured
+}
+
+// videoTracker accumulates the statistics reported by VideoStats().
+type videoTracker struct {
+	mu          sync.Mutex
+	stats       VideoStats
+	keyframes   rttTracker // only record() and lost() are used
+	seen        bool
+	lastFrame   uint8
+	frameAt     time.Time // when the current frame started
+	packetAt    time.Time // when the last packet arrived
+	pktInterval time.Duration
+	requested   time.Time // when an unanswered keyframe request was made, zero if none
+}
+
+// reset clears the statistics at the start of a video connection.
+func (vt *videoTracker) reset() {
+	vt.mu.Lock()
+	vt.stats = VideoStats{}
+	vt.seen = false
+	vt.pktInterval = 0
+	vt.requested = time.Time{}
+	vt.mu.Unlock()
+	vt.keyframes.mu.Lock()
+	vt.keyframes.recent, vt.keyframes.next, vt.keyframes.stats = nil, 0, RTTStats{}
+	vt.keyframes.mu.Unlock()
+}
+
+// requestKeyframe notes the time of a keyframe request, an earlier unanswered request takes precedence
+// unless it has timed out.
+func (vt *videoTracker) requestKeyframe(now time.Time) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	if !vt.requested.IsZero() {
+		if now.Sub(vt.requested) < keyframeReplyTimeout {
+			return
+		}
+		vt.keyframes.lost()
+	}
+	vt.requested = now
+}
+
+// recordPacket adds a video packet, with the frame number from its header, to the statistics.
+func (vt *videoTracker) recordPacket(frame uint8, data []byte, now time.Time) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	s := &vt.stats
+	s.Packets++
+	s.Bytes += uint64(len(data))
+	if vt.seen {
+		vt.pktInterval += time.Duration(videoSmoothing * float64(now.Sub(vt.packetAt)-vt.pktInterval))
+	}
+	vt.packetAt = now
+	if !vt.seen || frame != vt.lastFrame {
+		if vt.seen {
+			interval := now.Sub(vt.frameAt)
+			if s.Frames > 1 {
+				d := interval - s.FrameInterval
+				if d < 0 {
+					d = -d
+				}
+				s.FrameJitter += (d - s.FrameJitter) / 16
+				s.FrameInterval += time.Duration(videoSmoothing * float64(interval-s.FrameInterval))
+			} else {
+				s.FrameInterval = interval
+			}
+			if interval > s.MaxFrameGap {
+				s.MaxFrameGap = interval
+			}
+			if s.FrameInterval > 0 {
+				s.FrameRate = float64(time.Second) / float64(s.FrameInterval)
+			}
+		}
+		s.Frames++
+		vt.frameAt = now
+		if hasNALType(data, nalSPS) {
+			s.Keyframes++
+			if !vt.requested.IsZero() {
+				vt.keyframes.record(now.Sub(vt.requested))
+				vt.requested = time.Time{}
+			}
+		}
+	}
+	vt.seen = true
+	vt.lastFrame = frame
+}
+
+// VideoStats returns the timing statistics for the video stream, including an estimate of its latency.
+// Keyframe requests are timed for the latency estimate, so it is only available if GetVideoSpsPps() is
+// called periodically, as most video applications do.
+func (tello *Tello) VideoStats() VideoStats {
+	vt := &tello.vstats
+	vt.mu.Lock()
+	s := vt.stats
+	pktInterval := vt.pktInterval
+	vt.mu.Unlock()
+	vt.keyframes.mu.Lock()
+	s.KeyframeLatency = vt.keyframes.stats
+	vt.keyframes.mu.Unlock()
+
+	tello.videoMu.Lock()
+	if tello.videoConn != nil {
+		s.Queued = len(tello.videoChan)
+	}
+	tello.videoMu.Unlock()
+	s.QueueDelay = time.Duration(s.Queued) * pktInterval
+
+	if s.KeyframeLatency.Samples > 0 {
+		s.Latency = s.KeyframeLatency.Avg - tello.RTT().Avg/2 - s.FrameInterval/2
+		if s.Latency < 0 {
+			s.Latency = 0
+		}
+		s.Latency += s.QueueDelay
+	}
+	return s
+}
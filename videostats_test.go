@@ -0,0 +1,84 @@
+// videostats_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVideoStats(t *testing.T) {
+	clock := &stepClock{t: time.Unix(1600000000, 0)}
+	drone := NewTello(WithClock(clock))
+	drone.sendQ.running = true // queue the keyframe requests without a connection
+	packet := func(frame uint8, data ...byte) {
+		drone.vstats.recordPacket(frame, data, clock.t)
+	}
+
+	// 30 fps, two packets per frame
+	for f := uint8(0); f < 10; f++ {
+		packet(f, 0, 0, 1, 0x41)
+		clock.t = clock.t.Add(10 * time.Millisecond)
+		packet(f, 0xff)
+		clock.t = clock.t.Add(time.Second/30 - 10*time.Millisecond)
+	}
+	s := drone.VideoStats()
+	if s.Packets != 20 || s.Frames != 10 || s.Keyframes != 0 {
+		t.Errorf("Unexpected counts %+v", s)
+	}
+	if s.FrameInterval != time.Second/30 || s.FrameRate < 29.9 || s.FrameRate > 30.1 || s.FrameJitter != 0 {
+		t.Errorf("Unexpected frame pacing %v, %v fps, jitter %v", s.FrameInterval, s.FrameRate, s.FrameJitter)
+	}
+	if s.Latency != 0 {
+		t.Errorf("Expected no latency estimate without a keyframe request, got %v", s.Latency)
+	}
+
+	// a keyframe arrives 150ms after it is requested, a repeated request doesn't restart the timing
+	drone.GetVideoSpsPps()
+	clock.t = clock.t.Add(100 * time.Millisecond)
+	drone.GetVideoSpsPps()
+	clock.t = clock.t.Add(50 * time.Millisecond)
+	packet(10, 0, 0, 0, 1, 0x67)
+	s = drone.VideoStats()
+	if s.Keyframes != 1 || s.KeyframeLatency.Samples != 1 || s.KeyframeLatency.Last != 150*time.Millisecond {
+		t.Errorf("Unexpected keyframe stats %+v", s)
+	}
+	if s.MaxFrameGap != 150*time.Millisecond+time.Second/30 {
+		t.Errorf("Unexpected max frame gap %v", s.MaxFrameGap)
+	}
+	if want := 150*time.Millisecond - s.FrameInterval/2; s.Latency != want {
+		t.Errorf("Expected latency %v, got %v", want, s.Latency)
+	}
+
+	// an unanswered request is lost
+	drone.GetVideoSpsPps()
+	clock.t = clock.t.Add(keyframeReplyTimeout)
+	drone.GetVideoSpsPps()
+	if s = drone.VideoStats(); s.KeyframeLatency.Lost != 1 {
+		t.Errorf("Expected a lost keyframe request, got %+v", s.KeyframeLatency)
+	}
+
+	drone.vstats.reset()
+	if s = drone.VideoStats(); s.Packets != 0 || s.KeyframeLatency.Samples != 0 {
+		t.Errorf("Expected reset stats, got %+v", s)
+	}
+}
@@ -0,0 +1,87 @@
+// wait.go
+
+// This file contains helpers which block until the drone reaches a given condition.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const waitPollPeriod = 50 * time.Millisecond
+
+// WaitFor blocks until cond returns true for the latest FlightData, ctx is done, or the
+// connection is lost.
+func (tello *Tello) WaitFor(ctx context.Context, cond func(fd FlightData) bool) (err error) {
+	ticker := time.NewTicker(waitPollPeriod)
+	defer ticker.Stop()
+	for {
+		if cond(tello.GetFlightData()) {
+			return nil
+		}
+		if !tello.ControlConnected() {
+			return ErrNotConnected
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForTakeoff blocks until the drone has finished taking off and is hovering or flying.
+func (tello *Tello) WaitForTakeoff(ctx context.Context) (err error) {
+	return tello.waitForState(ctx, func(fs FlightState) bool {
+		return fs == StateHovering || fs == StateFlying
+	})
+}
+
+// WaitForLanding blocks until the drone is on the ground.
+func (tello *Tello) WaitForLanding(ctx context.Context) (err error) {
+	return tello.waitForState(ctx, func(fs FlightState) bool {
+		return fs == StateGrounded
+	})
+}
+
+// WaitUntilHeight blocks until the drone's height is within tolerance of dm, both in decimetres.
+func (tello *Tello) WaitUntilHeight(ctx context.Context, dm, tolerance int16) (err error) {
+	return tello.WaitFor(ctx, func(fd FlightData) bool {
+		delta := fd.Height - dm
+		return delta <= tolerance && delta >= -tolerance
+	})
+}
+
+// waitForState is like WaitFor, but fails immediately if the drone is emergency stopped.
+func (tello *Tello) waitForState(ctx context.Context, cond func(fs FlightState) bool) (err error) {
+	var stopped bool
+	err = tello.WaitFor(ctx, func(fd FlightData) bool {
+		stopped = fd.State == StateEmergencyStopped
+		return stopped || cond(fd.State)
+	})
+	if err == nil && stopped && !cond(StateEmergencyStopped) {
+		return errors.New("Tello has emergency stopped")
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+// tello project wait_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitUntilHeight(t *testing.T) {
+	drone := new(Tello)
+	drone.ctrlState = connConnected
+	go func() {
+		for h := int16(0); h <= 10; h++ {
+			drone.fdMu.Lock()
+			drone.fd.Height = h
+			drone.fdMu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := drone.WaitUntilHeight(ctx, 10, 0); err != nil {
+		t.Errorf("WaitUntilHeight failed with %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := drone.WaitForTakeoff(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	drone.fdMu.Lock()
+	drone.fd.State = StateEmergencyStopped
+	drone.fdMu.Unlock()
+	if err := drone.WaitForLanding(context.Background()); err == nil {
+		t.Error("Expected an error when emergency stopped")
+	}
+}
@@ -0,0 +1,72 @@
+// warnings.go
+
+// Surfacing of the safety warnings in the flight status.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+// warning flags, a set flag means the warning is active
+const (
+	warnOverheat uint8 = 1 << iota
+	warnWind
+	warnIMU
+)
+
+var warningEvents = []struct {
+	flag uint8
+	ev   EventType
+}{
+	{warnOverheat, EvOverheat},
+	{warnWind, EvWindWarning},
+	{warnIMU, EvIMUWarning},
+}
+
+// statusWarnings returns the warning flags which are active in the given status data.
+func statusWarnings(fd FlightData) (flags uint8) {
+	if fd.TemperatureHigh {
+		flags |= warnOverheat
+	}
+	if fd.WindState {
+		flags |= warnWind
+	}
+	if !fd.ImuState {
+		flags |= warnIMU
+	}
+	return flags
+}
+
+// checkWarnings sends an Event for each warning that has been raised or cleared since the last status message.
+// The IMU is only checked once its first MVO and IMU data has arrived, the drone reports a problem until then.
+func (tello *Tello) checkWarnings(fd FlightData) {
+	flags := statusWarnings(fd)
+	tello.fdMu.Lock()
+	if tello.odoLastMVO.IsZero() {
+		flags &^= warnIMU
+	}
+	changed := flags ^ tello.warnFlags
+	tello.warnFlags = flags
+	tello.fdMu.Unlock()
+	for _, w := range warningEvents {
+		if changed&w.flag != 0 {
+			tello.emitEvent(w.ev, flags&w.flag != 0)
+		}
+	}
+}
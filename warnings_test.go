@@ -0,0 +1,67 @@
+// tello project warnings_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckWarnings(t *testing.T) {
+	tello := new(Tello)
+	events, stop := tello.ListenEvents()
+	defer stop()
+
+	expect := func(et EventType, raised bool) {
+		t.Helper()
+		select {
+		case ev := <-events:
+			if ev.Type != et || ev.Data.(bool) != raised {
+				t.Errorf("Expected event %d raised: %v, got %+v", et, raised, ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected event %d raised: %v, got nothing", et, raised)
+		}
+	}
+	expectNone := func() {
+		t.Helper()
+		select {
+		case ev := <-events:
+			t.Errorf("Unexpected event %+v", ev)
+		default:
+		}
+	}
+
+	tello.checkWarnings(FlightData{}) // the IMU is not ready until the first MVO and IMU data
+	expectNone()
+	tello.odoLastMVO = time.Now()
+	tello.checkWarnings(FlightData{ImuState: true})
+	expectNone()
+	tello.checkWarnings(FlightData{ImuState: true, TemperatureHigh: true, WindState: true})
+	expect(EvOverheat, true)
+	expect(EvWindWarning, true)
+	expectNone()
+	tello.checkWarnings(FlightData{ImuState: false, TemperatureHigh: true})
+	expect(EvWindWarning, false)
+	expect(EvIMUWarning, true)
+	expectNone()
+}
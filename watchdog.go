@@ -0,0 +1,178 @@
+// watchdog.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"sync"
+	"time"
+)
+
+// TelemetryCategory identifies a kind of telemetry watched by the stale telemetry watchdog.
+type TelemetryCategory int
+
+// The categories of telemetry, with the default time after which each is considered stale.
+const (
+	TelemetryStatus    TelemetryCategory = iota // flight status messages, default 1s
+	TelemetryFlightLog                          // flight log data, ie. MVO and IMU, default 2s
+	TelemetryWifi                               // WiFi strength messages, default 5s
+	TelemetryLight                              // light strength messages, default 5s
+	TelemetryVideo                              // video packets, only while the video is connected, default 2s
+	numTelemetryCategories
+)
+
+var telemetryNames = [...]string{"Status", "FlightLog", "Wifi", "Light", "Video"}
+
+func (tc TelemetryCategory) String() string {
+	if tc < 0 || int(tc) >= len(telemetryNames) {
+		return "Unknown"
+	}
+	return telemetryNames[tc]
+}
+
+var defaultStaleAfter = [numTelemetryCategories]time.Duration{time.Second, 2 * time.Second, 5 * time.Second, 5 * time.Second, 2 * time.Second}
+
+// telemetryMessages are the message IDs of each category, except video.
+var telemetryMessages = [...]MessageID{MsgFlightStatus, MsgLogData, MsgWifiStrength, MsgLightStrength}
+
+const staleCheckPeriod = 250 * time.Millisecond
+
+// WithStaleTelemetryTimeout sets how long a category of telemetry may be silent before an
+// EvStaleTelemetry event is sent, a negative timeout stops the category being watched.
+func WithStaleTelemetryTimeout(category TelemetryCategory, timeout time.Duration) Option {
+	return func(tello *Tello) {
+		if category >= 0 && category < numTelemetryCategories {
+			tello.cfg.staleAfter[category] = timeout
+		}
+	}
+}
+
+func (c *config) getStaleAfter(category TelemetryCategory) time.Duration {
+	if c.staleAfter[category] == 0 {
+		return defaultStaleAfter[category]
+	}
+	return c.staleAfter[category]
+}
+
+// StaleTelemetry is the Data of an EvStaleTelemetry event, sent when a category of telemetry stops
+// arriving and again when it resumes.  If LinkAge is short the connection is still alive and the drone
+// has just stopped sending this category, eg. the video may need to be restarted with GetVideoSpsPps(),
+// whereas a long LinkAge means that nothing at all is arriving and the link has been lost.
+type StaleTelemetry struct {
+	Category TelemetryCategory
+	Stale    bool          // true when the telemetry has become stale, false when it resumes
+	Age      time.Duration // since the category was last received, or the watch began
+	LinkAge  time.Duration // since anything at all was last received from the drone
+}
+
+// staleWatch holds the state of the watchdog for the current connection.
+type staleWatch struct {
+	mu         sync.Mutex
+	stale      [numTelemetryCategories]bool
+	videoSince time.Time // when the video was first seen connected without any packets, zero if not
+}
+
+// telemetryWatchdog checks the age of each category of telemetry until done is closed.
+func (tello *Tello) telemetryWatchdog(done chan struct{}) {
+	tello.staleness.mu.Lock()
+	tello.staleness.stale = [numTelemetryCategories]bool{}
+	tello.staleness.videoSince = time.Time{}
+	tello.staleness.mu.Unlock()
+	clock := tello.cfg.getClock()
+	for {
+		select {
+		case <-done:
+			return
+		case <-clock.After(staleCheckPeriod):
+			tello.checkTelemetry(clock.Now())
+		}
+	}
+}
+
+// checkTelemetry sends an EvStaleTelemetry event for each category which has become stale, or fresh, by now.
+func (tello *Tello) checkTelemetry(now time.Time) {
+	var last [numTelemetryCategories]time.Time
+	ts := &tello.traffic
+	ts.mu.Lock()
+	connected, lastRecv := ts.connected, ts.totals.LastRecv
+	for i, id := range telemetryMessages {
+		last[i] = ts.received[id].Last
+	}
+	ts.mu.Unlock()
+	tello.vstats.mu.Lock()
+	last[TelemetryVideo] = tello.vstats.packetAt
+	tello.vstats.mu.Unlock()
+	tello.videoMu.Lock()
+	videoOn := tello.videoConn != nil
+	tello.videoMu.Unlock()
+	if lastRecv.IsZero() {
+		lastRecv = connected
+	}
+
+	sw := &tello.staleness
+	sw.mu.Lock()
+	var evs []StaleTelemetry
+	for tc := TelemetryCategory(0); tc < numTelemetryCategories; tc++ {
+		limit := tello.cfg.getStaleAfter(tc)
+		if limit < 0 {
+			continue
+		}
+		since := last[tc]
+		if tc == TelemetryVideo {
+			switch {
+			case !videoOn:
+				sw.videoSince = time.Time{}
+				sw.stale[tc] = false
+				continue
+			case sw.videoSince.IsZero():
+				sw.videoSince = now
+			}
+			if since.Before(sw.videoSince) {
+				since = sw.videoSince // don't count time before the video was connected
+			}
+		}
+		if since.IsZero() {
+			since = connected
+		}
+		age := now.Sub(since)
+		if stale := age >= limit; stale != sw.stale[tc] {
+			sw.stale[tc] = stale
+			evs = append(evs, StaleTelemetry{Category: tc, Stale: stale, Age: age, LinkAge: now.Sub(lastRecv)})
+		}
+	}
+	sw.mu.Unlock()
+	for _, ev := range evs {
+		tello.emitEvent(EvStaleTelemetry, ev)
+	}
+}
+
+// StaleTelemetryCategories returns the categories of telemetry which are currently stale.
+func (tello *Tello) StaleTelemetryCategories() (stale []TelemetryCategory) {
+	sw := &tello.staleness
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	for tc, s := range sw.stale {
+		if s {
+			stale = append(stale, TelemetryCategory(tc))
+		}
+	}
+	return stale
+}
@@ -0,0 +1,72 @@
+// watchdog_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStaleTelemetry(t *testing.T) {
+	clock := &stepClock{t: time.Unix(1600000000, 0)}
+	drone := NewTello(WithClock(clock), WithStaleTelemetryTimeout(TelemetryLight, -1))
+	events, stop := drone.ListenEvents()
+	defer stop()
+	drone.traffic.reset(clock.t)
+	at := func(ms int, ids ...MessageID) {
+		clock.t = time.Unix(1600000000, 0).Add(time.Duration(ms) * time.Millisecond)
+		for _, id := range ids {
+			drone.traffic.recordRecv(packetToBuffer(newPacket(ptData1, id, 0, 0)), clock.t)
+		}
+		drone.checkTelemetry(clock.t)
+	}
+	at(500, MsgFlightStatus, MsgLogData, MsgWifiStrength)
+	at(1000)
+	if len(events) != 0 {
+		t.Fatalf("Expected nothing stale yet, got %v", <-events)
+	}
+	at(1600, MsgWifiStrength) // status is stale, but the link is alive
+	at(1800, MsgFlightStatus) // status has resumed
+	at(2600)                  // the flight log is stale, and nothing has arrived for a while
+
+	want := []StaleTelemetry{
+		{Category: TelemetryStatus, Stale: true, Age: 1100 * time.Millisecond, LinkAge: 0},
+		{Category: TelemetryStatus, Stale: false, Age: 0, LinkAge: 0},
+		{Category: TelemetryFlightLog, Stale: true, Age: 2100 * time.Millisecond, LinkAge: 800 * time.Millisecond},
+	}
+	for i, w := range want {
+		select {
+		case ev := <-events:
+			if ev.Type != EvStaleTelemetry || ev.Data.(StaleTelemetry) != w {
+				t.Errorf("Event %d: expected %+v, got %+v", i, w, ev.Data)
+			}
+		default:
+			t.Fatalf("Event %d: expected %+v, got nothing", i, w)
+		}
+	}
+	if stale := drone.StaleTelemetryCategories(); len(stale) != 1 || stale[0] != TelemetryFlightLog {
+		t.Errorf("Expected only the flight log to be stale, got %v", stale)
+	}
+	if len(events) != 0 {
+		t.Errorf("Unexpected %+v", <-events)
+	}
+}
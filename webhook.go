@@ -0,0 +1,161 @@
+// webhook.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultWebhookEvents are the events sent by a Webhook which does not choose its own: takeoffs,
+// landings and other flight state changes, reaching the battery reserve, and disconnection.
+var DefaultWebhookEvents = []EventType{EvFlightState, EvBatteryReserve, EvDisconnected}
+
+// Webhook POSTs Events as JSON to a URL, eg. to notify a phone or a logging service during
+// autonomous flights, see AddWebhook().  The body is a WebhookPayload.
+type Webhook struct {
+	URL     string
+	Events  []EventType   // the events to send, nil means DefaultWebhookEvents
+	Header  http.Header   // added to every request, eg. for an Authorization header
+	Retries int           // how many times a failed POST is retried, 0 means 3 and a negative value none
+	Backoff time.Duration // the wait before the first retry, doubled for each retry, 0 means 1s
+	Client  *http.Client  // nil means a client with a 10s timeout
+}
+
+// WebhookPayload is the JSON body POSTed by a Webhook.
+type WebhookPayload struct {
+	Type    string      `json:"type"` // the EventType, eg. "FlightState"
+	Time    time.Time   `json:"time"`
+	Message string      `json:"message"`          // a one-line description, suitable for a notification
+	Data    interface{} `json:"data"`             // the Event's Data, errors are sent as their message
+	Serial  string      `json:"serial,omitempty"` // the drone's serial number, if known
+}
+
+const (
+	defaultWebhookRetries = 3
+	defaultWebhookBackoff = time.Second
+	webhookTimeout        = 10 * time.Second
+)
+
+// AddWebhook starts POSTing the chosen Events to wh.URL, and returns a func to stop.
+// Events are sent one at a time, in order, from a Goroutine of their own; a POST which fails or
+// gets a response other than 2xx is retried with exponential backoff and logged if it never succeeds.
+// N.B. Like any ListenEvents() consumer, events which occur while a slow endpoint is being retried
+// may be lost.
+func (tello *Tello) AddWebhook(wh Webhook) (stop func()) {
+	wh = wh.withDefaults()
+	wanted := map[EventType]bool{}
+	for _, et := range wh.Events {
+		wanted[et] = true
+	}
+	events, stopListening := tello.ListenEvents()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for ev := range events {
+			if wanted[ev.Type] {
+				if err := tello.postWebhook(ctx, wh, ev); err != nil && ctx.Err() == nil {
+					tello.logf("Warning: webhook %s failed - %v\n", ev.Type, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		stopListening()
+		<-stopped
+	}
+}
+
+func (wh Webhook) withDefaults() Webhook {
+	if wh.Events == nil {
+		wh.Events = DefaultWebhookEvents
+	}
+	if wh.Retries == 0 {
+		wh.Retries = defaultWebhookRetries
+	}
+	if wh.Backoff == 0 {
+		wh.Backoff = defaultWebhookBackoff
+	}
+	if wh.Client == nil {
+		wh.Client = &http.Client{Timeout: webhookTimeout}
+	}
+	return wh
+}
+
+// postWebhook POSTs ev, retrying as configured by wh.
+func (tello *Tello) postWebhook(ctx context.Context, wh Webhook, ev Event) error {
+	wp := webhookPayload(ev)
+	wp.Serial = tello.GetFlightData().SerialNumber
+	body, err := json.Marshal(wp)
+	if err != nil {
+		return err
+	}
+	clock := tello.cfg.getClock()
+	wait := wh.Backoff
+	for try := 0; ; try++ {
+		err = postJSON(ctx, wh, body)
+		if err == nil || try >= wh.Retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func postJSON(ctx context.Context, wh Webhook, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, vs := range wh.Header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := wh.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// webhookPayload describes ev for a Webhook.
+func webhookPayload(ev Event) WebhookPayload {
+	wp := WebhookPayload{Type: ev.Type.String(), Time: ev.Time, Message: ev.String(), Data: ev.Data}
+	if err, ok := ev.Data.(error); ok {
+		wp.Data = err.Error()
+	}
+	return wp
+}
@@ -0,0 +1,116 @@
+// webhook_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var got []WebhookPayload
+	var tries int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer xyz" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected headers %v", r.Header)
+		}
+		if tries++; tries == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable) // the first POST is retried
+			return
+		}
+		var wp WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&wp); err != nil {
+			t.Error(err)
+		}
+		got = append(got, wp)
+	}))
+	defer srv.Close()
+
+	drone := new(Tello)
+	stop := drone.AddWebhook(Webhook{URL: srv.URL, Header: http.Header{"Authorization": {"Bearer xyz"}}, Backoff: time.Millisecond})
+	drone.emitEvent(EvFlightState, FlightStateChange{From: StateGrounded, To: StateTakingOff})
+	drone.emitEvent(EvManualNeutral, nil) // not sent by default
+	drone.emitEvent(EvBatteryReserve, 90*time.Second)
+	drone.emitEvent(EvDisconnected, true)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n >= 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []struct{ typ, msg string }{
+		{"FlightState", "Tello Grounded -> TakingOff"},
+		{"BatteryReserve", "Tello BatteryReserve: 1m30s left"},
+		{"Disconnected", "Tello lost contact"},
+	}
+	if len(got) != len(want) || tries != 4 {
+		t.Fatalf("Expected %d events after %d tries, got %+v after %d", len(want), len(want)+1, got, tries)
+	}
+	for i, w := range want {
+		if got[i].Type != w.typ || got[i].Message != w.msg || got[i].Time.IsZero() {
+			t.Errorf("Event %d: expected %s %q, got %+v", i, w.typ, w.msg, got[i])
+		}
+	}
+	if d, ok := got[0].Data.(map[string]interface{}); !ok || d["From"] != "Grounded" || d["To"] != "TakingOff" {
+		t.Errorf("Unexpected flight state data %v", got[0].Data)
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	var mu sync.Mutex
+	var tries int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tries++
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	drone := new(Tello)
+	wh := Webhook{URL: srv.URL, Events: []EventType{EvManualNeutral}, Retries: 2, Backoff: time.Millisecond}
+	if err := drone.postWebhook(context.Background(), wh.withDefaults(), Event{Type: EvManualNeutral}); err == nil {
+		t.Error("Expected an error")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if tries != 3 {
+		t.Errorf("Expected 3 tries, got %d", tries)
+	}
+}
@@ -0,0 +1,233 @@
+// websocket.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The relay protocol may also be carried over a WebSocket, so that a browser-based ground station,
+// ie. this package compiled to WASM, can reach the drone via a thin relay, see ServeWebSocketRelay()
+// and DialWebSocketRelay().  Only what the relay needs of RFC 6455 is implemented: the relay stream is
+// sent as binary messages, whose boundaries are ignored.
+
+const (
+	wsGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsCloseTimeout = time.Second // how long Close() may spend sending the close frame
+)
+
+// WebSocket opcodes...
+const (
+	wsContinuation byte = 0x0
+	wsText         byte = 0x1
+	wsBinary       byte = 0x2
+	wsClose        byte = 0x8
+	wsPing         byte = 0x9
+	wsPong         byte = 0xa
+)
+
+var errWebSocketHandshake = errors.New("WebSocket handshake failed")
+
+func wsAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// wsConn is a byte stream carried in the binary messages of a WebSocket.
+type wsConn struct {
+	net.Conn
+	br        *bufio.Reader
+	client    bool   // clients mask what they send
+	remaining uint64 // unread payload in the current frame
+	mask      [4]byte
+	masked    bool
+	maskPos   int
+	wmu       sync.Mutex // serialises frames
+}
+
+// Read returns the payload of binary messages, answering pings and returning io.EOF on a close.
+func (wc *wsConn) Read(b []byte) (int, error) {
+	for wc.remaining == 0 {
+		op, payload, err := wc.readHeader()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case wsBinary, wsText, wsContinuation:
+			continue
+		case wsPing:
+			wc.writeFrame(wsPong, payload)
+		case wsClose:
+			wc.writeFrame(wsClose, nil)
+			return 0, io.EOF
+		}
+	}
+	if uint64(len(b)) > wc.remaining {
+		b = b[:wc.remaining]
+	}
+	n, err := wc.br.Read(b)
+	for i := 0; i < n && wc.masked; i++ {
+		b[i] ^= wc.mask[wc.maskPos%4]
+		wc.maskPos++
+	}
+	wc.remaining -= uint64(n)
+	return n, err
+}
+
+// readHeader reads the next frame header, and the whole payload of control frames.
+func (wc *wsConn) readHeader() (op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(wc.br, hdr[:]); err != nil {
+		return
+	}
+	op = hdr[0] & 0x0f
+	wc.masked = hdr[1]&0x80 != 0
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(wc.br, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(wc.br, ext[:])
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if err == nil && wc.masked {
+		_, err = io.ReadFull(wc.br, wc.mask[:])
+	}
+	if err != nil {
+		return
+	}
+	wc.remaining, wc.maskPos = n, 0
+	if op >= wsClose { // control frames are short and must be read whole
+		payload = make([]byte, n)
+		_, err = io.ReadFull(wc, payload)
+	}
+	return
+}
+
+// Write sends b as one binary message.
+func (wc *wsConn) Write(b []byte) (int, error) {
+	if err := wc.writeFrame(wsBinary, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (wc *wsConn) writeFrame(op byte, payload []byte) error {
+	frame := make([]byte, 2, 14+len(payload))
+	frame[0] = 0x80 | op // always final
+	switch n := len(payload); {
+	case n < 126:
+		frame[1] = byte(n)
+	case n <= 0xffff:
+		frame[1] = 126
+		frame = append(frame, byte(n>>8), byte(n))
+	default:
+		frame[1] = 127
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(frame, ext[:]...)
+	}
+	start := len(frame)
+	frame = append(frame, payload...)
+	if wc.client {
+		var mask [4]byte
+		rand.Read(mask[:])
+		frame[1] |= 0x80
+		frame = append(frame[:start], append(mask[:], frame[start:]...)...)
+		for i := range payload {
+			frame[start+4+i] ^= mask[i%4]
+		}
+	}
+	wc.wmu.Lock()
+	defer wc.wmu.Unlock()
+	_, err := wc.Conn.Write(frame)
+	return err
+}
+
+// Close sends a close frame, without waiting for the answer, and closes the connection.
+func (wc *wsConn) Close() error {
+	wc.Conn.SetWriteDeadline(time.Now().Add(wsCloseTimeout))
+	wc.writeFrame(wsClose, nil)
+	return wc.Conn.Close()
+}
+
+// upgradeWebSocket answers a WebSocket handshake and takes over its connection.
+// Any origin is accepted.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "Expected a WebSocket", http.StatusBadRequest)
+		return nil, errWebSocketHandshake
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Cannot take over the connection", http.StatusInternalServerError)
+		return nil, errWebSocketHandshake
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAccept(key) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{Conn: conn, br: brw.Reader}, nil
+}
+
+// ServeWebSocketRelay returns an http.Handler which relays for a RelayTransport connecting via
+// DialWebSocketRelay(), eg. from a browser, opening the drone's connections with transport, which is
+// normally UDPTransport{}.  One operator is served at a time, as the drone's ports can only be opened
+// once; others are answered with 503 Service Unavailable.
+func ServeWebSocketRelay(transport Transport) http.Handler {
+	busy := make(chan struct{}, 1)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case busy <- struct{}{}:
+			defer func() { <-busy }()
+		default:
+			http.Error(w, "Relay busy", http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgradeWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		ServeRelay(conn, transport)
+	})
+}
@@ -0,0 +1,176 @@
+//go:build js && wasm
+// +build js,wasm
+
+// websocket_js.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall/js"
+	"time"
+)
+
+// DialWebSocketRelay connects to a relay served by ServeWebSocketRelay() at the ws:// or wss:// URL
+// wsURL, using the browser's WebSocket, and returns a RelayTransport using it.
+func DialWebSocketRelay(wsURL string) (*RelayTransport, error) {
+	conn, err := dialBrowserWebSocket(wsURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewRelayTransport(conn), nil
+}
+
+// browserWS is a byte stream carried in the binary messages of a browser WebSocket.
+type browserWS struct {
+	ws       js.Value
+	url      string
+	funcs    []js.Func
+	notify   chan struct{} // signalled when a message is queued
+	pending  []byte        // the unread part of the current message
+	closed   chan struct{}
+	once     sync.Once
+	mu       sync.Mutex // protects the following
+	queue    [][]byte   // messages received, the callbacks must not block so this is unbounded
+	deadline time.Time
+}
+
+func dialBrowserWebSocket(wsURL string) (net.Conn, error) {
+	bw := &browserWS{url: wsURL, notify: make(chan struct{}, 1), closed: make(chan struct{})}
+	opened := make(chan error, 1)
+	bw.ws = js.Global().Get("WebSocket").New(wsURL)
+	bw.ws.Set("binaryType", "arraybuffer")
+	answer := func(err error) {
+		select {
+		case opened <- err:
+		default:
+		}
+	}
+	bw.on("open", func(js.Value) { answer(nil) })
+	bw.on("error", func(js.Value) { answer(errWebSocketHandshake) })
+	bw.on("close", func(js.Value) { bw.shut() })
+	bw.on("message", func(ev js.Value) {
+		arr := js.Global().Get("Uint8Array").New(ev.Get("data"))
+		b := make([]byte, arr.Length())
+		js.CopyBytesToGo(b, arr)
+		bw.mu.Lock()
+		bw.queue = append(bw.queue, b)
+		bw.mu.Unlock()
+		select {
+		case bw.notify <- struct{}{}:
+		default:
+		}
+	})
+	select {
+	case err := <-opened:
+		if err != nil {
+			bw.Close()
+			return nil, err
+		}
+	case <-time.After(relayTimeout):
+		bw.Close()
+		return nil, errWebSocketHandshake
+	}
+	return bw, nil
+}
+
+func (bw *browserWS) on(event string, fn func(ev js.Value)) {
+	f := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		fn(args[0])
+		return nil
+	})
+	bw.funcs = append(bw.funcs, f)
+	bw.ws.Call("addEventListener", event, f)
+}
+
+func (bw *browserWS) shut() {
+	bw.once.Do(func() { close(bw.closed) })
+}
+
+func (bw *browserWS) Read(b []byte) (int, error) {
+	var timeout <-chan time.Time
+	for len(bw.pending) == 0 {
+		bw.mu.Lock()
+		if len(bw.queue) > 0 {
+			bw.pending, bw.queue = bw.queue[0], bw.queue[1:]
+			bw.mu.Unlock()
+			continue
+		}
+		deadline := bw.deadline
+		bw.mu.Unlock()
+		if timeout == nil && !deadline.IsZero() {
+			timer := time.NewTimer(time.Until(deadline))
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-bw.notify:
+		case <-bw.closed:
+			return 0, io.EOF
+		case <-timeout:
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+	n := copy(b, bw.pending)
+	bw.pending = bw.pending[n:]
+	return n, nil
+}
+
+func (bw *browserWS) Write(b []byte) (int, error) {
+	select {
+	case <-bw.closed:
+		return 0, errors.New("WebSocket closed")
+	default:
+	}
+	arr := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(arr, b)
+	bw.ws.Call("send", arr)
+	return len(b), nil
+}
+
+func (bw *browserWS) Close() error {
+	bw.shut()
+	bw.ws.Call("close")
+	for _, f := range bw.funcs {
+		f.Release()
+	}
+	bw.funcs = nil
+	return nil
+}
+
+func (bw *browserWS) LocalAddr() net.Addr  { return relayAddr("browser") }
+func (bw *browserWS) RemoteAddr() net.Addr { return relayAddr(bw.url) }
+
+func (bw *browserWS) SetDeadline(t time.Time) error { return bw.SetReadDeadline(t) }
+
+func (bw *browserWS) SetReadDeadline(t time.Time) error {
+	bw.mu.Lock()
+	bw.deadline = t
+	bw.mu.Unlock()
+	return nil
+}
+
+func (bw *browserWS) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,106 @@
+// websocket_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/tello/sim"
+)
+
+func TestWebSocketFrames(t *testing.T) {
+	a, b := net.Pipe()
+	client := &wsConn{Conn: a, br: bufio.NewReader(a), client: true}
+	server := &wsConn{Conn: b, br: bufio.NewReader(b)}
+	defer client.Close()
+
+	big := bytes.Repeat([]byte("0123456789"), 7000) // needs a 64-bit length
+	go func() {
+		client.Write([]byte("hi"))
+		client.writeFrame(wsPing, []byte("p"))
+		client.Write(big)
+	}()
+	got := make([]byte, 2+len(big))
+	if _, err := io.ReadFull(server, got[:2]); err != nil || string(got[:2]) != "hi" {
+		t.Fatalf("Got %q, %v", got[:2], err)
+	}
+	pong := make(chan []byte, 1)
+	go func() { // the server answers the ping while reading on
+		op, payload, err := client.readHeader()
+		if err != nil || op != wsPong {
+			t.Errorf("Expected a pong, got %d %v", op, err)
+		}
+		pong <- payload
+	}()
+	if _, err := io.ReadFull(server, got[2:]); err != nil || !bytes.Equal(got[2:], big) {
+		t.Fatalf("Big message corrupted, %v", err)
+	}
+	if p := <-pong; string(p) != "p" {
+		t.Errorf("Expected the ping payload to be echoed, got %q", p)
+	}
+}
+
+func TestWebSocketRelay(t *testing.T) {
+	s := sim.New()
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	srv := httptest.NewServer(ServeWebSocketRelay(UDPTransport{}))
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/relay"
+	rt, err := DialWebSocketRelay(wsURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	if _, err := DialWebSocketRelay(wsURL); err == nil {
+		t.Error("Expected a second operator to be turned away")
+	}
+	if resp, err := http.Get(srv.URL); err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected the relay to be busy, got %v", err)
+	}
+
+	drone := NewTello(WithTransport(rt), WithAddress("127.0.0.1", s.Addr().Port), WithLocalControlPort(AnyPort))
+	if err := drone.ControlConnectDefault(); err != nil {
+		t.Fatalf("Connect via the WebSocket relay failed with %v", err)
+	}
+	defer drone.ControlDisconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := drone.TakeOffAndWait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := drone.LandAndWait(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,293 @@
+// wifi.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+/*
+Package wifi finds nearby Tello access points and associates the host's WiFi with one, so that
+connecting to a drone can be fully automated, eg. in kiosk or classroom setups:
+
+	nets, err := wifi.Scan()
+	...
+	err = wifi.Connect(nets[0].SSID, "")
+
+It shells out to the platform's own tools: nmcli (NetworkManager) on Linux, airport and networksetup
+on macOS, and netsh on Windows, so these must be installed and the user allowed to change the WiFi.
+*/
+package wifi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Network is a WiFi network found by Scan().
+type Network struct {
+	SSID   string
+	Signal int // 0 to 100
+}
+
+// ErrUnsupported is returned on platforms with no known WiFi tools.
+var ErrUnsupported = errors.New("WiFi association is not supported on " + runtime.GOOS)
+
+// telloPrefixes are the SSID prefixes of the Tello, the Tello EDU and the RoboMaster TT.
+var telloPrefixes = []string{"TELLO-", "RMTT-"}
+
+// IsTello returns true if ssid looks like a Tello's access point, eg. TELLO-5A3C2B.
+func IsTello(ssid string) bool {
+	for _, p := range telloPrefixes {
+		if strings.HasPrefix(strings.ToUpper(ssid), p) {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs a command and returns its output, tests replace it.
+var run = func(name string, args ...string) ([]byte, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("%s failed - %v: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+const airport = "/System/Library/PrivateFrameworks/Apple80211.framework/Versions/Current/Resources/airport"
+
+// Scan returns the Tello networks in range, strongest first.
+func Scan() ([]Network, error) {
+	return scan(runtime.GOOS)
+}
+
+func scan(goos string) ([]Network, error) {
+	var (
+		out   []byte
+		err   error
+		parse func([]byte) []Network
+	)
+	switch goos {
+	case "linux":
+		out, err = run("nmcli", "-t", "-f", "SSID,SIGNAL", "device", "wifi", "list", "--rescan", "yes")
+		parse = parseNmcli
+	case "darwin":
+		out, err = run(airport, "-s")
+		parse = parseAirport
+	case "windows":
+		out, err = run("netsh", "wlan", "show", "networks", "mode=bssid")
+		parse = parseNetsh
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tellos(parse(out)), nil
+}
+
+// tellos returns the Tello networks in nets, strongest first and without duplicates.
+func tellos(nets []Network) []Network {
+	best := map[string]int{}
+	for _, n := range nets {
+		if s, seen := best[n.SSID]; IsTello(n.SSID) && (!seen || n.Signal > s) {
+			best[n.SSID] = n.Signal
+		}
+	}
+	res := make([]Network, 0, len(best))
+	for ssid, signal := range best {
+		res = append(res, Network{SSID: ssid, Signal: signal})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Signal != res[j].Signal {
+			return res[i].Signal > res[j].Signal
+		}
+		return res[i].SSID < res[j].SSID
+	})
+	return res
+}
+
+// parseNmcli parses the terse output of nmcli, where colons in SSIDs are escaped.
+func parseNmcli(out []byte) (nets []Network) {
+	for _, line := range strings.Split(string(out), "\n") {
+		i := strings.LastIndex(line, ":")
+		if i < 0 {
+			continue
+		}
+		signal, err := strconv.Atoi(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			continue
+		}
+		nets = append(nets, Network{SSID: strings.ReplaceAll(line[:i], `\:`, ":"), Signal: signal})
+	}
+	return nets
+}
+
+var airportLine = regexp.MustCompile(`^\s*(.+?)\s+[0-9a-fA-F]{2}(?::[0-9a-fA-F]{2}){5}\s+(-?\d+)\s`)
+
+// parseAirport parses the output of airport -s, converting the RSSI in dBm to a percentage.
+func parseAirport(out []byte) (nets []Network) {
+	for _, line := range strings.Split(string(out), "\n") {
+		m := airportLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		rssi, _ := strconv.Atoi(m[2])
+		nets = append(nets, Network{SSID: m[1], Signal: dBmToPercent(rssi)})
+	}
+	return nets
+}
+
+func dBmToPercent(dBm int) int {
+	switch {
+	case dBm <= -100:
+		return 0
+	case dBm >= -50:
+		return 100
+	}
+	return 2 * (dBm + 100)
+}
+
+// parseNetsh parses the output of netsh wlan show networks mode=bssid, keeping each SSID's strongest BSSID.
+func parseNetsh(out []byte) (nets []Network) {
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := netshField(line)
+		switch {
+		case !ok:
+		case strings.HasPrefix(key, "SSID "):
+			nets = append(nets, Network{SSID: value})
+		case key == "Signal" && len(nets) > 0:
+			if signal, err := strconv.Atoi(strings.TrimSuffix(value, "%")); err == nil && signal > nets[len(nets)-1].Signal {
+				nets[len(nets)-1].Signal = signal
+			}
+		}
+	}
+	return nets
+}
+
+func netshField(line string) (key, value string, ok bool) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), true
+}
+
+// Connect associates the host's WiFi with the network ssid, password may be empty for an open network,
+// as a Tello's is unless a password has been set.
+func Connect(ssid, password string) error {
+	return connect(runtime.GOOS, ssid, password)
+}
+
+func connect(goos, ssid, password string) error {
+	switch goos {
+	case "linux":
+		args := []string{"device", "wifi", "connect", ssid}
+		if password != "" {
+			args = append(args, "password", password)
+		}
+		_, err := run("nmcli", args...)
+		return err
+	case "darwin":
+		out, err := run("networksetup", "-listallhardwareports")
+		if err != nil {
+			return err
+		}
+		iface := airportDevice(out)
+		if iface == "" {
+			return errors.New("No WiFi interface found")
+		}
+		args := []string{"-setairportnetwork", iface, ssid}
+		if password != "" {
+			args = append(args, password)
+		}
+		_, err = run("networksetup", args...)
+		return err
+	case "windows":
+		return connectNetsh(ssid, password)
+	}
+	return ErrUnsupported
+}
+
+// airportDevice finds the WiFi device in the output of networksetup -listallhardwareports.
+func airportDevice(out []byte) string {
+	wifi := false
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := netshField(line)
+		switch {
+		case !ok:
+		case key == "Hardware Port":
+			wifi = value == "Wi-Fi" || value == "AirPort"
+		case key == "Device" && wifi:
+			return value
+		}
+	}
+	return ""
+}
+
+// connectNetsh adds a profile for ssid, as netsh can only connect to networks with one, then connects.
+func connectNetsh(ssid, password string) error {
+	f, err := os.CreateTemp("", "tello-wifi-*.xml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(netshProfile(ssid, password))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := run("netsh", "wlan", "add", "profile", "filename="+f.Name(), "user=current"); err != nil {
+		return err
+	}
+	_, err = run("netsh", "wlan", "connect", "name="+ssid, "ssid="+ssid)
+	return err
+}
+
+// netshProfile returns a WLAN profile for an open or WPA2-personal network.
+func netshProfile(ssid, password string) string {
+	auth, enc, key := "open", "none", ""
+	if password != "" {
+		auth, enc = "WPA2PSK", "AES"
+		key = "<sharedKey><keyType>passPhrase</keyType><protected>false</protected><keyMaterial>" +
+			xmlEscape(password) + "</keyMaterial></sharedKey>"
+	}
+	name := xmlEscape(ssid)
+	return `<?xml version="1.0"?>
+<WLANProfile xmlns="http://www.microsoft.com/networking/WLAN/profile/v1">
+<name>` + name + `</name>
+<SSIDConfig><SSID><name>` + name + `</name></SSID></SSIDConfig>
+<connectionType>ESS</connectionType>
+<connectionMode>manual</connectionMode>
+<MSM><security><authEncryption><authentication>` + auth + `</authentication><encryption>` + enc +
+		`</encryption><useOneX>false</useOneX></authEncryption>` + key + `</security></MSM>
+</WLANProfile>
+`
+}
+
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;").Replace(s)
+}
@@ -0,0 +1,137 @@
+// wifi_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package wifi
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRun replaces run, answering each command by its name and recording the calls.
+func fakeRun(t *testing.T, outputs map[string]string) *[][]string {
+	var calls [][]string
+	saved := run
+	t.Cleanup(func() { run = saved })
+	run = func(name string, args ...string) ([]byte, error) {
+		calls = append(calls, append([]string{name}, args...))
+		return []byte(outputs[name]), nil
+	}
+	return &calls
+}
+
+func TestScan(t *testing.T) {
+	fakeRun(t, map[string]string{
+		"nmcli": "HomeNet:90\nTELLO-5A3C2B:72\nTELLO-5A3C2B:40\nweird\\:ssid:10\nRMTT-0F1E2D:55\n:30\n",
+		airport: `                            SSID BSSID             RSSI CHANNEL HT CC SECURITY (auth/unicast/group)
+                        HomeNet 00:11:22:33:44:55 -45  6       Y  GB WPA2(PSK/AES/AES)
+                   TELLO-5A3C2B 60:60:1f:aa:bb:cc -64  2       Y  -- NONE
+                   TELLO A B    60:60:1f:aa:bb:cd -70  2       Y  -- NONE
+`,
+		"netsh": "\r\nThere are 2 networks currently visible.\r\n\r\nSSID 1 : TELLO-5A3C2B\r\n    Network type            : Infrastructure\r\n" +
+			"    Authentication          : Open\r\n    BSSID 1                 : 60:60:1f:aa:bb:cc\r\n         Signal             : 81%  \r\n" +
+			"SSID 2 : HomeNet\r\n    BSSID 1                 : 00:11:22:33:44:55\r\n         Signal             : 99%\r\n",
+	})
+	for goos, want := range map[string][]Network{
+		"linux":   {{"TELLO-5A3C2B", 72}, {"RMTT-0F1E2D", 55}},
+		"darwin":  {{"TELLO-5A3C2B", 72}},
+		"windows": {{"TELLO-5A3C2B", 81}},
+	} {
+		got, err := scan(goos)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, %v, want %v", goos, got, err, want)
+		}
+	}
+	if _, err := scan("plan9"); err != ErrUnsupported {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestParseNmcliEscapes(t *testing.T) {
+	got := parseNmcli([]byte("a\\:b:10\n"))
+	if len(got) != 1 || got[0].SSID != "a:b" || got[0].Signal != 10 {
+		t.Errorf("Got %v", got)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	calls := fakeRun(t, map[string]string{
+		"networksetup": "Hardware Port: Ethernet\nDevice: en0\n\nHardware Port: Wi-Fi\nDevice: en1\n",
+	})
+	if err := connect("linux", "TELLO-5A3C2B", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := connect("darwin", "TELLO-5A3C2B", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"nmcli", "device", "wifi", "connect", "TELLO-5A3C2B"},
+		{"networksetup", "-listallhardwareports"},
+		{"networksetup", "-setairportnetwork", "en1", "TELLO-5A3C2B", "secret"},
+	}
+	if !reflect.DeepEqual(*calls, want) {
+		t.Errorf("Got %v\nwant %v", *calls, want)
+	}
+}
+
+func TestConnectNetsh(t *testing.T) {
+	var profile string
+	saved := run
+	defer func() { run = saved }()
+	var calls []string
+	run = func(name string, args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args[:2], " "))
+		for _, a := range args {
+			if strings.HasPrefix(a, "filename=") {
+				b, err := os.ReadFile(strings.TrimPrefix(a, "filename="))
+				if err != nil {
+					t.Error(err)
+				}
+				profile = string(b)
+			}
+		}
+		return nil, nil
+	}
+	if err := connect("windows", "TELLO-<1>", "p&ss"); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || calls[0] != "wlan add" || calls[1] != "wlan connect" {
+		t.Errorf("Unexpected netsh calls %v", calls)
+	}
+	for _, want := range []string{"<name>TELLO-&lt;1&gt;</name>", "<authentication>WPA2PSK</authentication>", "<keyMaterial>p&amp;ss</keyMaterial>"} {
+		if !strings.Contains(profile, want) {
+			t.Errorf("Profile lacks %s:\n%s", want, profile)
+		}
+	}
+	if p := netshProfile("TELLO-1", ""); !strings.Contains(p, "<authentication>open</authentication>") || strings.Contains(p, "sharedKey") {
+		t.Errorf("Expected an open profile, got\n%s", p)
+	}
+}
+
+func TestIsTello(t *testing.T) {
+	for ssid, want := range map[string]bool{"TELLO-ABC": true, "tello-abc": true, "RMTT-1": true, "MyTello": false} {
+		if IsTello(ssid) != want {
+			t.Errorf("IsTello(%q) != %v", ssid, want)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// writer.go
+
+// All outgoing control packets are sent by a single writer Goroutine, in priority order.
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+)
+
+// sendPriority orders the outgoing packets, higher priorities are sent first.
+type sendPriority int
+
+const (
+	prioQuery     sendPriority = iota // requests for information
+	prioCommand                       // everything else
+	prioStick                         // stick updates, only the latest is kept
+	prioEmergency                     // landing, which also bypasses the rate limit
+	numPriorities
+)
+
+const (
+	defaultSendInterval = 5 * time.Millisecond // minimum time between packets, ie. at most 200 per second
+	sendQueueLen        = 32                   // maximum packets queued per priority
+	maxSpareStickBufs   = 2                    // stick packet buffers kept for reuse
+)
+
+// sendQueue holds the packets waiting for the writer.
+type sendQueue struct {
+	mu      sync.Mutex
+	running bool // is a writer accepting packets?
+	queues  [numPriorities][][]byte
+	wake    chan struct{} // signals the writer that a packet has been queued
+	spare   [][]byte      // stick packet buffers no longer in use, see stickBuffer()
+}
+
+// packetPriority classifies a raw outgoing packet.
+func packetPriority(buff []byte) sendPriority {
+	if len(buff) < minPktSize || buff[0] != msgHdr {
+		if bytes.Equal(buff, []byte("emergency")) {
+			return prioEmergency
+		}
+		return prioCommand // connection request or text-SDK command
+	}
+	// read the fields in place rather than decoding the packet, this is called for every stick update
+	messageID := MessageID(buff[5]) | MessageID(buff[6])<<8
+	switch {
+	case messageID == MsgDoLand || messageID == MsgDoPalmLand:
+		return prioEmergency
+	case messageID == MsgSetStick:
+		return prioStick
+	case (buff[4]>>3)&0x07 == ptGet:
+		return prioQuery
+	}
+	return prioCommand
+}
+
+// enqueue passes a raw packet to the writer, it never blocks.
+// Packets are silently dropped if no writer is running, ie. we are not connected.
+func (tello *Tello) enqueue(buff []byte) {
+	prio := packetPriority(buff)
+	q := &tello.sendQ
+	q.mu.Lock()
+	if !q.running {
+		q.mu.Unlock()
+		return
+	}
+	switch {
+	case prio == prioStick:
+		if len(q.queues[prio]) > 0 {
+			q.recycle(q.queues[prio][0]) // superseded before it was sent
+		}
+		q.queues[prio] = append(q.queues[prio][:0], buff)
+	case len(q.queues[prio]) >= sendQueueLen:
+		tello.logf("Warning: send queue full, dropping oldest packet of priority %d\n", prio)
+		q.queues[prio] = append(q.queues[prio][1:], buff)
+	default:
+		q.queues[prio] = append(q.queues[prio], buff)
+	}
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// head returns the priority of the next packet to be sent, ok is false if none is queued.
+func (q *sendQueue) head() (prio sendPriority, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for prio = numPriorities - 1; prio >= 0; prio-- {
+		if len(q.queues[prio]) > 0 {
+			return prio, true
+		}
+	}
+	return 0, false
+}
+
+// pop removes and returns the next packet to be sent.
+func (q *sendQueue) pop() (buff []byte, prio sendPriority, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for prio = numPriorities - 1; prio >= 0; prio-- {
+		if len(q.queues[prio]) > 0 {
+			buff = q.queues[prio][0]
+			if len(q.queues[prio]) == 1 {
+				q.queues[prio] = q.queues[prio][:0] // keep the backing array for the next packet
+			} else {
+				q.queues[prio] = q.queues[prio][1:]
+			}
+			return buff, prio, true
+		}
+	}
+	return nil, 0, false
+}
+
+// flush discards every queued packet except landing and emergency stops.
+func (q *sendQueue) flush() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for prio := sendPriority(0); prio < prioEmergency; prio++ {
+		for _, buff := range q.queues[prio] {
+			if prio == prioStick {
+				q.recycle(buff)
+			}
+		}
+		q.queues[prio] = q.queues[prio][:0]
+	}
+}
+
+// stickBuffer returns an empty buffer for building a stick packet, reusing one which has
+// already been sent if possible, so that the regular stick updates do not allocate.
+func (q *sendQueue) stickBuffer() []byte {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n := len(q.spare); n > 0 {
+		buff := q.spare[n-1]
+		q.spare = q.spare[:n-1]
+		return buff[:0]
+	}
+	return make([]byte, 0, minPktSize+stickPayloadSize)
+}
+
+// recycle keeps a stick packet buffer which is no longer queued or being sent for reuse,
+// q.mu must be held.
+func (q *sendQueue) recycle(buff []byte) {
+	if len(q.spare) < maxSpareStickBufs && cap(buff) >= minPktSize+stickPayloadSize {
+		q.spare = append(q.spare, buff)
+	}
+}
+
+// WithSendInterval sets the minimum time between outgoing control packets,
+// landing requests are never delayed.
+func WithSendInterval(interval time.Duration) Option {
+	return func(tello *Tello) { tello.cfg.sendInterval = interval }
+}
+
+func (c *config) getSendInterval() time.Duration {
+	if c.sendInterval == 0 {
+		return defaultSendInterval
+	}
+	return c.sendInterval
+}
+
+// startWriter prepares the send queue and starts the writer, the returned channel is closed
+// when the writer has stopped.
+func (tello *Tello) startWriter(conn net.Conn, done <-chan struct{}) (stopped chan struct{}) {
+	q := &tello.sendQ
+	q.mu.Lock()
+	q.running = true
+	for p := range q.queues {
+		q.queues[p] = nil
+	}
+	if q.wake == nil {
+		q.wake = make(chan struct{}, 1)
+	}
+	q.mu.Unlock()
+	stopped = make(chan struct{})
+	go tello.packetWriter(conn, done, stopped)
+	return stopped
+}
+
+// packetWriter is the only Goroutine which writes to the control connection.  Once done is closed
+// it sends whatever is still queued, so a final Land() is not lost, and stops.
+func (tello *Tello) packetWriter(conn net.Conn, done <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	q := &tello.sendQ
+	interval := tello.cfg.getSendInterval()
+	clock := tello.cfg.getClock()
+	var last time.Time
+	stopping := false
+	for {
+		prio, ok := q.head()
+		if !ok {
+			select {
+			case <-done:
+				q.mu.Lock()
+				q.running = false
+				q.mu.Unlock()
+				for buff, _, ok := q.pop(); ok; buff, _, ok = q.pop() {
+					conn.Write(buff)
+					now := clock.Now()
+					tello.traffic.recordSent(buff, now)
+					tello.hookPacket(DirSent, buff, now)
+				}
+				return
+			case <-q.wake:
+				continue
+			}
+		}
+		if wait := interval - clock.Now().Sub(last); !stopping && prio != prioEmergency && wait > 0 {
+			select {
+			case <-done:
+				stopping = true
+			case <-clock.After(wait):
+			}
+			continue // a more urgent packet may have arrived
+		}
+		if buff, prio, ok := q.pop(); ok {
+			if _, err := conn.Write(buff); err != nil {
+				tello.logf("Error writing to Tello - %v\n", err)
+			}
+			last = clock.Now()
+			tello.traffic.recordSent(buff, last)
+			tello.hookPacket(DirSent, buff, last)
+			if prio == prioStick {
+				q.mu.Lock()
+				q.recycle(buff)
+				q.mu.Unlock()
+			}
+		}
+	}
+}
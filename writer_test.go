@@ -0,0 +1,148 @@
+// tello project writer_test.go
+
+// Copyright (C) 2018  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tello
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPacketPriority(t *testing.T) {
+	tests := []struct {
+		buff []byte
+		want sendPriority
+	}{
+		{packetToBuffer(newPacket(ptSet, MsgDoLand, 1, 1)), prioEmergency},
+		{[]byte("emergency"), prioEmergency},
+		{packetToBuffer(newPacket(ptData2, MsgSetStick, 0, 11)), prioStick},
+		{packetToBuffer(newPacket(ptSet, MsgDoTakeoff, 1, 0)), prioCommand},
+		{[]byte("conn_req:lh"), prioCommand},
+		{packetToBuffer(newPacket(ptGet, MsgQueryVersion, 1, 0)), prioQuery},
+	}
+	for _, tc := range tests {
+		if got := packetPriority(tc.buff); got != tc.want {
+			t.Errorf("Expected priority %d for % x, got %d", tc.want, tc.buff, got)
+		}
+	}
+}
+
+func TestPacketWriter(t *testing.T) {
+	sink, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	conn, err := net.DialUDP("udp", nil, sink.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tello := NewTello(WithSendInterval(20 * time.Millisecond))
+	tello.enqueue([]byte("dropped")) // not running yet
+
+	// queue everything up before the writer starts, so we can check the order
+	tello.sendQ.running = true
+	tello.sendQ.wake = make(chan struct{}, 1)
+	tello.enqueue(packetToBuffer(newPacket(ptGet, MsgQueryVersion, 1, 0)))
+	tello.enqueue(packetToBuffer(newPacket(ptSet, MsgDoTakeoff, 2, 0)))
+	tello.enqueue(packetToBuffer(newPacket(ptData2, MsgSetStick, 0, 11)))
+	tello.enqueue(packetToBuffer(newPacket(ptData2, MsgSetStick, 1, 11)))
+	tello.enqueue(packetToBuffer(newPacket(ptSet, MsgDoLand, 3, 1)))
+	done, stopped := make(chan struct{}), make(chan struct{})
+	start := time.Now()
+	go tello.packetWriter(conn, done, stopped)
+
+	want := []struct {
+		id  MessageID
+		seq uint16
+	}{{MsgDoLand, 3}, {MsgSetStick, 1}, {MsgDoTakeoff, 2}, {MsgQueryVersion, 1}}
+	buff := make([]byte, 64)
+	for _, w := range want {
+		sink.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := sink.ReadFromUDP(buff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkt := bufferToPacket(buff[:n]); pkt.messageID != w.id || pkt.sequence != w.seq {
+			t.Errorf("Expected message %d seq %d, got %d seq %d", w.id, w.seq, pkt.messageID, pkt.sequence)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected the rate limit to space out the packets, all sent in %v", elapsed)
+	}
+
+	// anything still queued is sent when stopping
+	tello.enqueue(packetToBuffer(newPacket(ptSet, MsgDoTakeoff, 4, 0)))
+	close(done)
+	<-stopped
+	sink.SetReadDeadline(time.Now().Add(time.Second))
+	if n, _, err := sink.ReadFromUDP(buff); err != nil || bufferToPacket(buff[:n]).sequence != 4 {
+		t.Errorf("Expected the queued packet to be flushed, got %v", err)
+	}
+	tello.enqueue([]byte("dropped")) // stopped
+	if _, ok := tello.sendQ.head(); ok {
+		t.Error("Expected packets to be dropped once the writer has stopped")
+	}
+}
+
+// sendOneStick sends a stick update and takes it off the queue as the writer would.
+func sendOneStick(tello *Tello) {
+	tello.sendStickUpdate()
+	q := &tello.sendQ
+	if buff, prio, ok := q.pop(); ok && prio == prioStick {
+		q.mu.Lock()
+		q.recycle(buff)
+		q.mu.Unlock()
+	}
+}
+
+func TestStickUpdateAllocs(t *testing.T) {
+	tello := new(Tello)
+	tello.sendQ.running = true
+	tello.sendQ.wake = make(chan struct{}, 1)
+	tello.UpdateSticks(StickMessage{Rx: 1000, Ly: -1000})
+	sendOneStick(tello) // the first update allocates a buffer
+	if allocs := testing.AllocsPerRun(100, func() { sendOneStick(tello) }); allocs != 0 {
+		t.Errorf("Expected stick updates not to allocate, got %.1f allocations each", allocs)
+	}
+
+	// superseded updates are recycled too
+	tello.sendStickUpdate()
+	if allocs := testing.AllocsPerRun(100, tello.sendStickUpdate); allocs != 0 {
+		t.Errorf("Expected coalesced stick updates not to allocate, got %.1f allocations each", allocs)
+	}
+	if buff, _, _ := tello.sendQ.pop(); len(buff) != minPktSize+stickPayloadSize || packetPriority(buff) != prioStick {
+		t.Errorf("Unexpected stick packet % x", buff)
+	}
+}
+
+func BenchmarkStickUpdate(b *testing.B) {
+	tello := new(Tello)
+	tello.sendQ.running = true
+	tello.sendQ.wake = make(chan struct{}, 1)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sendOneStick(tello)
+	}
+}